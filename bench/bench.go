@@ -0,0 +1,378 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench generates reproducible loads against a mock or real cluster and reports their latency and
+// throughput, so the performance of the client can be compared between versions by users and CI alike.
+//
+// A benchmark loads the keys by Load, and then runs a workload by Run:
+//
+//	cfg := bench.Config{Workload: bench.PointGet, Concurrency: 16, Duration: time.Minute}
+//	if err := bench.Load(ctx, client.KVStore, cfg); err != nil {
+//		return err
+//	}
+//	report, err := bench.Run(ctx, client.KVStore, cfg)
+//	fmt.Println(report)
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+)
+
+// Workload is the kind of the operations run by a benchmark.
+type Workload int
+
+// The workloads. The read workloads read the keys loaded by Load, and the write workloads except BulkInsert overwrite
+// them.
+const (
+	// PointGet gets a random key from a snapshot.
+	PointGet Workload = iota
+	// BatchGet gets BatchSize random keys from a snapshot.
+	BatchGet
+	// SmallTxn commits a transaction setting TxnSize random keys, 4 by default.
+	SmallTxn
+	// BigTxn commits a transaction setting TxnSize random keys, 1024 by default.
+	BigTxn
+	// Scan scans BatchSize pairs from a random key of a snapshot.
+	Scan
+	// BulkInsert commits an insert-only transaction inserting TxnSize new keys, 1024 by default.
+	BulkInsert
+)
+
+func (w Workload) String() string {
+	switch w {
+	case PointGet:
+		return "point-get"
+	case BatchGet:
+		return "batch-get"
+	case SmallTxn:
+		return "small-txn"
+	case BigTxn:
+		return "big-txn"
+	case Scan:
+		return "scan"
+	case BulkInsert:
+		return "bulk-insert"
+	}
+	return fmt.Sprintf("Workload(%d)", int(w))
+}
+
+// The defaults of Config.
+const (
+	DefaultKeys         = 10000
+	DefaultValueSize    = 64
+	DefaultBatchSize    = 16
+	DefaultSmallTxnSize = 4
+	DefaultBigTxnSize   = 1024
+)
+
+// Config is the config of a benchmark.
+type Config struct {
+	// Workload is the kind of the operations.
+	Workload Workload
+	// Concurrency is the number of the workers running the operations, 1 by default.
+	Concurrency int
+	// Duration bounds the time of the run.
+	Duration time.Duration
+	// Ops bounds the number of the operations of the run. At least one of Duration and Ops must be set.
+	Ops int
+	// Keys is the number of the keys loaded and accessed, DefaultKeys by default.
+	Keys int
+	// ValueSize is the size of the values written, DefaultValueSize by default.
+	ValueSize int
+	// BatchSize is the number of the keys of a BatchGet or the pairs of a Scan, DefaultBatchSize by default.
+	BatchSize int
+	// TxnSize is the number of the keys set by a transaction of SmallTxn or BigTxn.
+	TxnSize int
+	// Seed seeds the random keys and values, the worker i uses Seed+i. The same seed generates the same operations
+	// of each worker.
+	Seed int64
+	// KeyPrefix prefixes the keys of the benchmark.
+	KeyPrefix []byte
+	// BlindWrite makes the transactions of BulkInsert write the keys blindly by KVTxn.SetInsertOnlyBlindWrite.
+	BlindWrite bool
+	// CPUProfile is the file the CPU profile of the run is written to if it's not empty.
+	CPUProfile string
+	// HeapProfile is the file the heap profile after the run is written to if it's not empty.
+	HeapProfile string
+}
+
+func (c Config) withDefaults() Config {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.Keys <= 0 {
+		c.Keys = DefaultKeys
+	}
+	if c.ValueSize <= 0 {
+		c.ValueSize = DefaultValueSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.TxnSize <= 0 {
+		c.TxnSize = DefaultSmallTxnSize
+		if c.Workload == BigTxn || c.Workload == BulkInsert {
+			c.TxnSize = DefaultBigTxnSize
+		}
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	if c.Workload < PointGet || c.Workload > BulkInsert {
+		return errors.Errorf("unknown workload %v", c.Workload)
+	}
+	if c.Duration <= 0 && c.Ops <= 0 {
+		return errors.New("either the duration or the number of the operations must be set")
+	}
+	if c.Keys < c.Concurrency {
+		return errors.Errorf("the number of the keys %d is less than the concurrency %d", c.Keys, c.Concurrency)
+	}
+	return nil
+}
+
+func (c *Config) key(i int) []byte {
+	return fmt.Appendf(append([]byte(nil), c.KeyPrefix...), "%010d", i)
+}
+
+// loadBatchSize is the number of the keys loaded by a transaction.
+const loadBatchSize = 1024
+
+// Load writes the keys accessed by the workloads with random values.
+func Load(ctx context.Context, store *tikv.KVStore, cfg Config) error {
+	cfg = cfg.withDefaults()
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	value := make([]byte, cfg.ValueSize)
+	for start := 0; start < cfg.Keys; start += loadBatchSize {
+		txn, err := store.Begin()
+		if err != nil {
+			return err
+		}
+		for i := start; i < min(start+loadBatchSize, cfg.Keys); i++ {
+			rng.Read(value)
+			if err = txn.Set(cfg.key(i), value); err != nil {
+				txn.Rollback()
+				return err
+			}
+		}
+		if err = txn.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run runs the workload of cfg and reports the latency and throughput of the operations. The failed operations are
+// counted by Report.Errors instead of failing the run, and Run only fails if the benchmark can't be run.
+func Run(ctx context.Context, store *tikv.KVStore, cfg Config) (*Report, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.CPUProfile != "" {
+		f, err := os.Create(cfg.CPUProfile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		if err = pprof.StartCPUProfile(f); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var (
+		wg        sync.WaitGroup
+		ops       atomic.Int64
+		errCount  atomic.Int64
+		latencies = make([][]time.Duration, cfg.Concurrency)
+	)
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		w := &worker{cfg: &cfg, store: store, id: i, rng: rand.New(rand.NewSource(cfg.Seed + int64(i)))}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && (cfg.Ops <= 0 || ops.Add(1) <= int64(cfg.Ops)) {
+				opStart := time.Now()
+				err := w.run(ctx)
+				if ctx.Err() != nil {
+					// The operation interrupted by the end of the run isn't counted.
+					return
+				}
+				latencies[w.id] = append(latencies[w.id], time.Since(opStart))
+				if err != nil {
+					errCount.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if cfg.HeapProfile != "" {
+		if err := writeHeapProfile(cfg.HeapProfile); err != nil {
+			return nil, err
+		}
+	}
+	return newReport(cfg.Workload, latencies, int(errCount.Load()), elapsed), nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	return errors.WithStack(pprof.WriteHeapProfile(f))
+}
+
+// worker runs the operations of a workload.
+type worker struct {
+	cfg   *Config
+	store *tikv.KVStore
+	id    int
+	rng   *rand.Rand
+	value []byte
+}
+
+func (w *worker) run(ctx context.Context) error {
+	switch w.cfg.Workload {
+	case PointGet:
+		snapshot, err := w.snapshot()
+		if err != nil {
+			return err
+		}
+		_, err = snapshot.Get(ctx, w.cfg.key(w.rng.Intn(w.cfg.Keys)))
+		if tikverr.IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	case BatchGet:
+		snapshot, err := w.snapshot()
+		if err != nil {
+			return err
+		}
+		keys := make([][]byte, w.cfg.BatchSize)
+		for i := range keys {
+			keys[i] = w.cfg.key(w.rng.Intn(w.cfg.Keys))
+		}
+		_, err = snapshot.BatchGet(ctx, keys)
+		return err
+	case SmallTxn, BigTxn:
+		return w.write(ctx)
+	case BulkInsert:
+		return w.insert(ctx)
+	case Scan:
+		snapshot, err := w.snapshot()
+		if err != nil {
+			return err
+		}
+		snapshot.SetScanBatchSize(w.cfg.BatchSize)
+		it, err := snapshot.Iter(w.cfg.key(w.rng.Intn(w.cfg.Keys)), nil)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for i := 0; i < w.cfg.BatchSize && it.Valid(); i++ {
+			if err = it.Next(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("unknown workload %v", w.cfg.Workload)
+}
+
+func (w *worker) snapshot() (*txnsnapshot.KVSnapshot, error) {
+	ts, err := w.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	if err != nil {
+		return nil, err
+	}
+	return w.store.GetSnapshot(ts), nil
+}
+
+// write commits a transaction setting random keys. Each worker writes its own keys, so the workers don't conflict
+// with each other.
+func (w *worker) write(ctx context.Context) error {
+	if w.value == nil {
+		w.value = make([]byte, w.cfg.ValueSize)
+	}
+	txn, err := w.store.Begin()
+	if err != nil {
+		return err
+	}
+	keysPerWorker := w.cfg.Keys / w.cfg.Concurrency
+	for i := 0; i < w.cfg.TxnSize; i++ {
+		w.rng.Read(w.value)
+		if err = txn.Set(w.cfg.key(w.rng.Intn(keysPerWorker)*w.cfg.Concurrency+w.id), w.value); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+	return txn.Commit(ctx)
+}
+
+// insert commits a transaction inserting new keys. The keys are prefixed by the start ts of the transaction, so they
+// are different from the keys of other transactions and runs.
+func (w *worker) insert(ctx context.Context) error {
+	if w.value == nil {
+		w.value = make([]byte, w.cfg.ValueSize)
+	}
+	txn, err := w.store.Begin()
+	if err != nil {
+		return err
+	}
+	txn.SetInsertOnlyBlindWrite(w.cfg.BlindWrite)
+	memBuffer := txn.GetMemBuffer()
+	for i := 0; i < w.cfg.TxnSize; i++ {
+		w.rng.Read(w.value)
+		key := fmt.Appendf(append([]byte(nil), w.cfg.KeyPrefix...), "i%020d%010d", txn.StartTS(), i)
+		if err = memBuffer.SetWithFlags(key, w.value, kv.SetPresumeKeyNotExists); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+	return txn.Commit(ctx)
+}
+
+// NewMockStore creates a store of a mock cluster of a single store for the benchmarks without a real cluster.
+func NewMockStore() (*tikv.KVStore, error) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	if err != nil {
+		return nil, err
+	}
+	testutils.BootstrapWithSingleStore(cluster)
+	return tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+}
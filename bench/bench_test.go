@@ -0,0 +1,108 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	store, err := NewMockStore()
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	cfg := Config{Concurrency: 2, Ops: 20, Keys: 100, TxnSize: 8, KeyPrefix: []byte("bench")}
+	require.Nil(t, Load(ctx, store, cfg))
+
+	for _, workload := range []Workload{PointGet, BatchGet, SmallTxn, BigTxn, Scan, BulkInsert} {
+		cfg.Workload = workload
+		report, err := Run(ctx, store, cfg)
+		require.Nil(t, err)
+		require.Equal(t, 20, report.Ops, workload)
+		require.Equal(t, 0, report.Errors, workload)
+		require.True(t, report.Latency.Min <= report.Latency.P50 && report.Latency.P50 <= report.Latency.Max)
+		require.Contains(t, report.String(), workload.String())
+	}
+
+	cfg.BlindWrite = true
+	report, err := Run(ctx, store, cfg)
+	require.Nil(t, err)
+	require.Equal(t, 0, report.Errors)
+
+	_, err = Run(ctx, store, Config{Keys: 100})
+	require.NotNil(t, err)
+	_, err = Run(ctx, store, Config{Ops: 1, Keys: 1, Concurrency: 2})
+	require.NotNil(t, err)
+
+	// The run is bounded by the duration and profiled.
+	dir := t.TempDir()
+	cfg = Config{Workload: PointGet, Duration: 100 * time.Millisecond, Keys: 100, KeyPrefix: []byte("bench"),
+		CPUProfile: filepath.Join(dir, "cpu.pprof"), HeapProfile: filepath.Join(dir, "heap.pprof")}
+	report, err = Run(ctx, store, cfg)
+	require.Nil(t, err)
+	require.Positive(t, report.Ops)
+	require.Less(t, report.Elapsed, time.Second)
+	for _, f := range []string{cfg.CPUProfile, cfg.HeapProfile} {
+		info, err := os.Stat(f)
+		require.Nil(t, err)
+		require.Positive(t, info.Size())
+	}
+}
+
+func TestServePprof(t *testing.T) {
+	srv, err := ServePprof("127.0.0.1:0")
+	require.Nil(t, err)
+	defer srv.Close()
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/goroutine?debug=1", srv.Addr))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func BenchmarkWorkloads(b *testing.B) {
+	store, err := NewMockStore()
+	require.Nil(b, err)
+	defer store.Close()
+	ctx := context.Background()
+	cfg := Config{Concurrency: 4, Keys: 1000, KeyPrefix: []byte("bench")}
+	require.Nil(b, Load(ctx, store, cfg))
+
+	for _, workload := range []Workload{PointGet, BatchGet, SmallTxn, BigTxn, Scan, BulkInsert} {
+		b.Run(workload.String(), func(b *testing.B) {
+			cfg := cfg
+			cfg.Workload, cfg.Ops = workload, b.N
+			b.ResetTimer()
+			report, err := Run(ctx, store, cfg)
+			require.Nil(b, err)
+			b.ReportMetric(float64(report.Latency.P99.Microseconds()), "p99-us")
+		})
+	}
+	b.Run("bulk-insert-blind-write", func(b *testing.B) {
+		cfg := cfg
+		cfg.Workload, cfg.Ops, cfg.BlindWrite = BulkInsert, b.N, true
+		b.ResetTimer()
+		report, err := Run(ctx, store, cfg)
+		require.Nil(b, err)
+		b.ReportMetric(float64(report.Latency.P99.Microseconds()), "p99-us")
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Report is the result of a benchmark.
+type Report struct {
+	Workload Workload
+	// Ops is the number of the operations, including the failed ones.
+	Ops int
+	// Errors is the number of the failed operations.
+	Errors int
+	// Elapsed is the time of the run.
+	Elapsed time.Duration
+	// Throughput is the number of the operations per second.
+	Throughput float64
+	// Latency is the distribution of the latencies of the operations.
+	Latency LatencyStats
+}
+
+// LatencyStats is the distribution of the latencies of the operations.
+type LatencyStats struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	P999 time.Duration
+	Max  time.Duration
+}
+
+func newReport(workload Workload, latencies [][]time.Duration, errCount int, elapsed time.Duration) *Report {
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	r := &Report{Workload: workload, Ops: len(all), Errors: errCount, Elapsed: elapsed}
+	if elapsed > 0 {
+		r.Throughput = float64(len(all)) / elapsed.Seconds()
+	}
+	if len(all) == 0 {
+		return r
+	}
+	slices.Sort(all)
+	var sum time.Duration
+	for _, l := range all {
+		sum += l
+	}
+	percentile := func(p float64) time.Duration {
+		return all[min(int(p*float64(len(all))), len(all)-1)]
+	}
+	r.Latency = LatencyStats{
+		Min:  all[0],
+		Mean: sum / time.Duration(len(all)),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+		P999: percentile(0.999),
+		Max:  all[len(all)-1],
+	}
+	return r
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%s: ops %d, errors %d, elapsed %v, throughput %.1f ops/s, latency min %v, mean %v, p50 %v, p90 %v, p99 %v, p999 %v, max %v",
+		r.Workload, r.Ops, r.Errors, r.Elapsed, r.Throughput, r.Latency.Min, r.Latency.Mean, r.Latency.P50,
+		r.Latency.P90, r.Latency.P99, r.Latency.P999, r.Latency.Max)
+}
+
+// ServePprof serves the pprof endpoints under /debug/pprof/ at addr, so the profiles can be taken while a benchmark
+// is running. The Addr of the returned server is the address listened on, and it should be closed after the
+// benchmark.
+func ServePprof(addr string) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Addr: l.Addr().String(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(l)
+	return srv, nil
+}
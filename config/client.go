@@ -37,6 +37,7 @@ package config
 import (
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
 	"google.golang.org/grpc/encoding/gzip"
@@ -49,6 +50,21 @@ const (
 	DefGrpcInitialConnWindowSize  = 1 << 27 // 128MiB
 	DefMaxConcurrencyRequestLimit = math.MaxInt64
 	DefBatchPolicy                = BatchPolicyStandard
+	// DefBatchConnIdleTimeout is the default value for the idle timeout of the batch connections.
+	DefBatchConnIdleTimeout = 3 * time.Minute
+	// DefBatchDeadlineFastPathThreshold is the default value for the time left before the deadline of a request
+	// below which the batch of the request is sent without waiting for more requests.
+	DefBatchDeadlineFastPathThreshold = 5 * time.Millisecond
+	// DefAdaptiveBatchSizeLatencyThreshold is the default value for the tail latency of the batches above which the
+	// adaptive max batch size is halved.
+	DefAdaptiveBatchSizeLatencyThreshold = 20 * time.Millisecond
+)
+
+const (
+	// BatchConnIdleRecycleConnArray closes all the connections to the store when they are idle.
+	BatchConnIdleRecycleConnArray = "conn-array"
+	// BatchConnIdleRecycleStream only closes the BatchCommands streams of the idle connections.
+	BatchConnIdleRecycleStream = "stream"
 )
 
 const (
@@ -89,12 +105,47 @@ type TiKVClient struct {
 	BatchPolicy string `toml:"batch-policy" json:"batch-policy"`
 	// MaxBatchSize is the max batch size when calling batch commands API.
 	MaxBatchSize uint `toml:"max-batch-size" json:"max-batch-size"`
+	// AdaptiveBatchSizeFloor makes the max batch size of each store adapt to the tail latency of the batches between
+	// the floor and MaxBatchSize, so the requests aren't blocked by large batches when the store is slow. 0 disables it.
+	AdaptiveBatchSizeFloor uint `toml:"adaptive-batch-size-floor" json:"adaptive-batch-size-floor"`
+	// AdaptiveBatchSizeLatencyThreshold is the p99 latency of the batches waiting to be sent or the requests waiting
+	// for the responses above which the adaptive max batch size is halved. It should be set by the normal latency of
+	// the stores, e.g. a higher value for the stores across regions.
+	AdaptiveBatchSizeLatencyThreshold time.Duration `toml:"adaptive-batch-size-latency-threshold" json:"adaptive-batch-size-latency-threshold"`
 	// If TiKV load is greater than this, TiDB will wait for a while to avoid little batch.
 	OverloadThreshold uint `toml:"overload-threshold" json:"overload-threshold"`
 	// MaxBatchWaitTime in nanosecond is the max wait time for batch.
 	MaxBatchWaitTime time.Duration `toml:"max-batch-wait-time" json:"max-batch-wait-time"`
 	// BatchWaitSize is the max wait size for batch.
 	BatchWaitSize uint `toml:"batch-wait-size" json:"batch-wait-size"`
+	// BatchDeadlineFastPathThreshold makes a batch be sent without waiting for more requests if the time left before
+	// the deadline of any request in it is less than the threshold, so a request with a short timeout doesn't spend
+	// it waiting to be batched. 0 disables it.
+	BatchDeadlineFastPathThreshold time.Duration `toml:"batch-deadline-fast-path-threshold" json:"batch-deadline-fast-path-threshold"`
+	// ResendReadsOnStreamBroken resends the pending read requests over the recreated stream instead of failing them
+	// when the batch commands stream is broken, as long as their timeouts haven't been reached.
+	ResendReadsOnStreamBroken bool `toml:"resend-reads-on-stream-broken" json:"resend-reads-on-stream-broken"`
+	// PropagateBatchDeadline limits the max execution duration of each request in the batch commands to the time left
+	// before its timeout or the deadline of its context, so TiKV can drop the requests already timed out on the client.
+	PropagateBatchDeadline bool `toml:"propagate-batch-deadline" json:"propagate-batch-deadline"`
+	// BatchConnIdleTimeout is how long the batch connections to a store can be idle before they are recycled. 0 means
+	// the idle connections are never recycled, which keeps the streams warm for the latency-sensitive workloads.
+	BatchConnIdleTimeout time.Duration `toml:"batch-conn-idle-timeout" json:"batch-conn-idle-timeout"`
+	// BatchConnIdleRecycle is how the idle batch connections are recycled. "conn-array" closes all the gRPC connections
+	// to the store, and "stream" only closes the BatchCommands stream of each gRPC connection, so the connections are
+	// kept and the streams are re-established without dialing on the next request. Empty means "conn-array".
+	BatchConnIdleRecycle string `toml:"batch-conn-idle-recycle" json:"batch-conn-idle-recycle"`
+	// LogOutdatedBatchResponseCmd logs the command type of the outdated batch responses, whose requests are already
+	// finished, e.g. failed by an ambiguous send error. The outdated responses are counted per store anyway.
+	LogOutdatedBatchResponseCmd bool `toml:"log-outdated-batch-response-cmd" json:"log-outdated-batch-response-cmd"`
+	// DeliverLateBatchResponses keeps the async requests failing to be sent waiting for their responses until their
+	// deadlines instead of failing them at once, as the store may have received them despite the error, which avoids
+	// the spurious timeouts and retries after ambiguous send errors.
+	DeliverLateBatchResponses bool `toml:"deliver-late-batch-responses" json:"deliver-late-batch-responses"`
+	// EnableIntendedLatencyMetrics records the latencies of the batch requests from when they're intended to be sent,
+	// i.e. when the callers send them, including the time waiting for the admission and in the batch queue, and the
+	// requests canceled or timed out, which are hidden from the other duration metrics when the requests queue up.
+	EnableIntendedLatencyMetrics bool `toml:"enable-intended-latency-metrics" json:"enable-intended-latency-metrics"`
 	// EnableChunkRPC indicate the data encode in chunk format for coprocessor requests.
 	EnableChunkRPC bool `toml:"enable-chunk-rpc" json:"enable-chunk-rpc"`
 	// If a Region has not been accessed for more than the given duration (in seconds), it
@@ -118,6 +169,51 @@ type TiKVClient struct {
 	// EnableReplicaSelectorV2 was deprecated.
 	// TODO(crazycs520): remove this config in 8.6 LTS version.
 	EnableReplicaSelectorV2 bool `toml:"enable-replica-selector-v2" json:"enable-replica-selector-v2"`
+	// PartitionedRaftKV declares the TiKV cluster is deployed with partitioned-raft-kv. Requests to the stores
+	// whose version supports it carry the buckets version of the region as a hint.
+	PartitionedRaftKV bool `toml:"partitioned-raft-kv" json:"partitioned-raft-kv"`
+	// PerStoreOverrides overrides some configs for specific stores, e.g. to shield a degraded store. The first
+	// matching override takes effect. The overrides are read on every use, so they can be changed on the fly.
+	PerStoreOverrides []StoreOverride `toml:"per-store-overrides" json:"per-store-overrides"`
+	// RequestTimeouts overrides the default timeouts of the requests by the name of the request type, e.g. "Get",
+	// "Scan", "Prewrite" or "Cop". It only applies to the requests whose callers don't specify the timeouts.
+	RequestTimeouts map[string]time.Duration `toml:"request-timeouts" json:"request-timeouts"`
+	// MemoryControl is the config of the memory budget of the client.
+	MemoryControl MemoryControl `toml:"memory-control" json:"memory-control"`
+	// EnableRequestValidation validates the requests before they are sent, so the malformed requests fail with
+	// ErrInvalidRequest instead of being sent to the stores.
+	EnableRequestValidation bool `toml:"enable-request-validation" json:"enable-request-validation"`
+}
+
+// MemoryControl is the config of the memory budget of the client. The major allocations of the client, i.e. the
+// transaction buffers, the scan buffers and the requests waiting in the batch queues, are accounted against the
+// budget, and the pressure actions are taken while the usage exceeds it. It can be changed on the fly.
+type MemoryControl struct {
+	// Limit is the memory budget in bytes, 0 means no limit.
+	Limit uint64 `toml:"limit" json:"limit"`
+	// RejectLargeScan rejects new scans whose batch size is larger than the default under memory pressure.
+	RejectLargeScan bool `toml:"reject-large-scan" json:"reject-large-scan"`
+	// ForceFlushPipelinedTxn makes the pipelined transactions flush their buffers as soon as possible under memory
+	// pressure, instead of waiting for enough keys to be buffered.
+	ForceFlushPipelinedTxn bool `toml:"force-flush-pipelined-txn" json:"force-flush-pipelined-txn"`
+}
+
+// StoreOverride overrides the configs of the requests to the matching stores.
+type StoreOverride struct {
+	// StoreIDs and Addrs are the stores the override applies to.
+	StoreIDs []uint64 `toml:"store-ids" json:"store-ids"`
+	Addrs    []string `toml:"addrs" json:"addrs"`
+	// MaxConcurrencyRequestLimit overrides TiKVClient.MaxConcurrencyRequestLimit of every connection to the stores,
+	// 0 means no override.
+	MaxConcurrencyRequestLimit int64 `toml:"max-concurrency-request-limit" json:"max-concurrency-request-limit"`
+	// BackoffMultiplier multiplies the backoff time after sending failures and ServerIsBusy errors of the stores,
+	// values no more than 1 mean no override.
+	BackoffMultiplier float64 `toml:"backoff-multiplier" json:"backoff-multiplier"`
+}
+
+// Match returns whether the override applies to the store with the id or the address.
+func (o *StoreOverride) Match(storeID uint64, addr string) bool {
+	return (storeID != 0 && slices.Contains(o.StoreIDs, storeID)) || (addr != "" && slices.Contains(o.Addrs, addr))
 }
 
 // AsyncCommit is the config for the async commit feature. The switch to enable it is a system variable.
@@ -174,6 +270,12 @@ func DefaultTiKVClient() TiKVClient {
 		MaxBatchWaitTime:  0,
 		BatchWaitSize:     8,
 
+		BatchDeadlineFastPathThreshold:    DefBatchDeadlineFastPathThreshold,
+		AdaptiveBatchSizeLatencyThreshold: DefAdaptiveBatchSizeLatencyThreshold,
+
+		BatchConnIdleTimeout: DefBatchConnIdleTimeout,
+		BatchConnIdleRecycle: BatchConnIdleRecycleConnArray,
+
 		EnableChunkRPC: true,
 
 		RegionCacheTTL:       600,
@@ -193,6 +295,11 @@ func DefaultTiKVClient() TiKVClient {
 		ResolveLockLiteThreshold:   16,
 		MaxConcurrencyRequestLimit: DefMaxConcurrencyRequestLimit,
 		EnableReplicaSelectorV2:    true,
+
+		MemoryControl: MemoryControl{
+			RejectLargeScan:        true,
+			ForceFlushPipelinedTxn: true,
+		},
 	}
 }
 
@@ -207,6 +314,50 @@ func (config *TiKVClient) Valid() error {
 	if config.GetGrpcKeepAliveTimeout() < time.Millisecond*50 {
 		return fmt.Errorf("grpc-keepalive-timeout should be at least 0.05, but got %f", config.GrpcKeepAliveTimeout)
 	}
+	if config.BatchDeadlineFastPathThreshold < 0 {
+		return fmt.Errorf("batch-deadline-fast-path-threshold should not be negative, but got %s", config.BatchDeadlineFastPathThreshold)
+	}
+	if config.MaxBatchSize > 0 && config.AdaptiveBatchSizeFloor > config.MaxBatchSize {
+		return fmt.Errorf("adaptive-batch-size-floor should not be greater than max-batch-size %d, but got %d",
+			config.MaxBatchSize, config.AdaptiveBatchSizeFloor)
+	}
+	if config.AdaptiveBatchSizeFloor > 0 && config.AdaptiveBatchSizeLatencyThreshold <= 0 {
+		return fmt.Errorf("adaptive-batch-size-latency-threshold should be positive, but got %s",
+			config.AdaptiveBatchSizeLatencyThreshold)
+	}
+	if config.BatchConnIdleTimeout < 0 {
+		return fmt.Errorf("batch-conn-idle-timeout should not be negative, but got %s", config.BatchConnIdleTimeout)
+	}
+	switch config.BatchConnIdleRecycle {
+	case "", BatchConnIdleRecycleConnArray, BatchConnIdleRecycleStream:
+	default:
+		return fmt.Errorf("batch-conn-idle-recycle should be %s or %s, but got %s",
+			BatchConnIdleRecycleConnArray, BatchConnIdleRecycleStream, config.BatchConnIdleRecycle)
+	}
+	for name, timeout := range config.RequestTimeouts {
+		if timeout < 0 {
+			return fmt.Errorf("request-timeouts of %s should not be negative, but got %s", name, timeout)
+		}
+	}
+	for _, o := range config.PerStoreOverrides {
+		if o.MaxConcurrencyRequestLimit < 0 {
+			return fmt.Errorf("max-concurrency-request-limit of per-store-overrides should not be negative, but got %d", o.MaxConcurrencyRequestLimit)
+		}
+		if o.BackoffMultiplier < 0 || math.IsNaN(o.BackoffMultiplier) || math.IsInf(o.BackoffMultiplier, 0) {
+			return fmt.Errorf("backoff-multiplier of per-store-overrides should be a non-negative number, but got %f", o.BackoffMultiplier)
+		}
+	}
+	return nil
+}
+
+// GetStoreOverride returns the first override that applies to the store with the id or the address, or nil if
+// there is none.
+func (config *TiKVClient) GetStoreOverride(storeID uint64, addr string) *StoreOverride {
+	for i := range config.PerStoreOverrides {
+		if config.PerStoreOverrides[i].Match(storeID, addr) {
+			return &config.PerStoreOverrides[i]
+		}
+	}
 	return nil
 }
 
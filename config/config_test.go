@@ -35,6 +35,7 @@
 package config
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -89,3 +90,28 @@ func TestValidateGRPCKeepAliveTimeout(t *testing.T) {
 	assert.NotNil(t, cfg.Valid())
 	assert.Equal(t, "grpc-keepalive-timeout should be at least 0.05, but got 0.040000", cfg.Valid().Error())
 }
+
+func TestValidateStoreOverrides(t *testing.T) {
+	cfg := DefaultTiKVClient()
+	cfg.PerStoreOverrides = []StoreOverride{{StoreIDs: []uint64{1}, BackoffMultiplier: 2}}
+	assert.Nil(t, cfg.Valid())
+	for _, multiplier := range []float64{-1, math.NaN(), math.Inf(1)} {
+		cfg.PerStoreOverrides[0].BackoffMultiplier = multiplier
+		assert.NotNil(t, cfg.Valid())
+	}
+	cfg.PerStoreOverrides[0].BackoffMultiplier = 0
+	cfg.PerStoreOverrides[0].MaxConcurrencyRequestLimit = -1
+	assert.NotNil(t, cfg.Valid())
+}
+
+func TestValidateBatchConnIdleRecycle(t *testing.T) {
+	cfg := DefaultTiKVClient()
+	assert.Nil(t, cfg.Valid())
+	// The configs without the field, which default to "conn-array", are valid.
+	cfg.BatchConnIdleRecycle = ""
+	assert.Nil(t, cfg.Valid())
+	cfg.BatchConnIdleRecycle = BatchConnIdleRecycleStream
+	assert.Nil(t, cfg.Valid())
+	cfg.BatchConnIdleRecycle = "unknown"
+	assert.NotNil(t, cfg.Valid())
+}
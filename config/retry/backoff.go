@@ -41,6 +41,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -188,10 +189,11 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 	if b.fn == nil {
 		b.fn = make(map[string]backoffFn)
 	}
-	f, ok := b.fn[cfg.name]
+	fnKey := cfg.fnKey()
+	f, ok := b.fn[fnKey]
 	if !ok {
 		f = cfg.createBackoffFn(b.vars)
-		b.fn[cfg.name] = f
+		b.fn[fnKey] = f
 	}
 	realSleep := f(b.ctx, maxSleepMs)
 	if cfg.metric != nil {
@@ -210,6 +212,7 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 		b.backoffTimes = make(map[string]int)
 	}
 	b.backoffTimes[cfg.name]++
+	recordBackoff(b.ctx, cfg.name, realSleep)
 
 	stmtExec := b.ctx.Value(util.ExecDetailsKey)
 	if stmtExec != nil {
@@ -438,3 +441,92 @@ func MayBackoffForRegionError(regionErr *errorpb.Error, bo *Backoffer) error {
 	// For the real EpochNotMatch error, don't backoff and retry immediately.
 	return nil
 }
+
+// BackoffStat is the stat of a type of backoff.
+type BackoffStat struct {
+	Times   int64
+	SleepMS int64
+}
+
+type backoffCounter struct {
+	times   atomic.Int64
+	sleepMS atomic.Int64
+}
+
+// backoffCounters are the backoff counters by the name of backoff config.
+var backoffCounters sync.Map
+
+func recordBackoff(ctx context.Context, name string, sleepMS int) {
+	v, ok := backoffCounters.Load(name)
+	if !ok {
+		v, _ = backoffCounters.LoadOrStore(name, &backoffCounter{})
+	}
+	counter := v.(*backoffCounter)
+	counter.times.Add(1)
+	counter.sleepMS.Add(int64(sleepMS))
+	if collector, ok := ctx.Value(backoffStatsCollectorKey{}).(*BackoffStatsCollector); ok {
+		collector.record(name, sleepMS)
+	}
+}
+
+// BackoffStats returns the stats of all types of backoff happened in the process, keyed by the backoff name.
+func BackoffStats() map[string]BackoffStat {
+	stats := make(map[string]BackoffStat)
+	backoffCounters.Range(func(k, v any) bool {
+		counter := v.(*backoffCounter)
+		stats[k.(string)] = BackoffStat{Times: counter.times.Load(), SleepMS: counter.sleepMS.Load()}
+		return true
+	})
+	return stats
+}
+
+// BackoffStatsCollector accumulates the backoffs of an operation by the backoff name. It collects the backoffs of all
+// the Backoffers created from the context returned by WithBackoffStatsCollector, so it's safe for concurrent use.
+type BackoffStatsCollector struct {
+	mu    sync.Mutex
+	stats map[string]BackoffStat
+}
+
+type backoffStatsCollectorKey struct{}
+
+// NewBackoffStatsCollector creates an empty BackoffStatsCollector.
+func NewBackoffStatsCollector() *BackoffStatsCollector {
+	return &BackoffStatsCollector{stats: make(map[string]BackoffStat)}
+}
+
+// WithBackoffStatsCollector returns a context that makes the Backoffers created from it record their backoffs to c.
+func WithBackoffStatsCollector(ctx context.Context, c *BackoffStatsCollector) context.Context {
+	return context.WithValue(ctx, backoffStatsCollectorKey{}, c)
+}
+
+func (c *BackoffStatsCollector) record(name string, sleepMS int) {
+	c.mu.Lock()
+	stat := c.stats[name]
+	stat.Times++
+	stat.SleepMS += int64(sleepMS)
+	c.stats[name] = stat
+	c.mu.Unlock()
+}
+
+// Stats returns a copy of the collected stats keyed by the backoff name.
+func (c *BackoffStatsCollector) Stats() map[string]BackoffStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]BackoffStat, len(c.stats))
+	for name, stat := range c.stats {
+		stats[name] = stat
+	}
+	return stats
+}
+
+// Total returns the sum of the collected stats of all the backoff types.
+func (c *BackoffStatsCollector) Total() BackoffStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total BackoffStat
+	for _, stat := range c.stats {
+		total.Times += stat.Times
+		total.SleepMS += stat.SleepMS
+	}
+	return total
+}
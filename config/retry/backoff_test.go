@@ -180,3 +180,46 @@ func TestMayBackoffForRegionError(t *testing.T) {
 		assert.EqualError(t, err, regionErr.String())
 	}
 }
+
+func TestBackoffScaledConfig(t *testing.T) {
+	assert.Same(t, BoTiKVRPC, BoTiKVRPC.Scaled(1))
+	assert.Same(t, BoTiKVRPC, BoTiKVRPC.Scaled(0))
+	scaled := BoTiKVRPC.Scaled(4)
+	assert.Equal(t, BoTiKVRPC.String(), scaled.String())
+	assert.NotEqual(t, BoTiKVRPC.fnKey(), scaled.fnKey())
+
+	// The scaled config doesn't share the backoff function with the original one.
+	b := NewBackofferWithVars(context.TODO(), 100000, nil)
+	assert.Nil(t, b.Backoff(BoTiKVRPC, errors.New("rpc")))
+	assert.Nil(t, b.Backoff(scaled, errors.New("rpc")))
+	assert.Len(t, b.fn, 2)
+	assert.Equal(t, 2, b.GetBackoffTimes()[BoTiKVRPC.String()])
+}
+
+func TestBackoffStatsCollector(t *testing.T) {
+	before := BackoffStats()
+	collector := NewBackoffStatsCollector()
+	ctx := WithBackoffStatsCollector(context.Background(), collector)
+
+	b1 := NewBackofferWithVars(ctx, 2000, nil)
+	assert.Nil(t, b1.Backoff(BoRegionMiss, errors.New("region miss")))
+	assert.Nil(t, b1.Backoff(BoRegionMiss, errors.New("region miss")))
+	b2, cancel := b1.Fork()
+	defer cancel()
+	assert.Nil(t, b2.BackoffWithMaxSleepTxnLockFast(5, errors.New("lock")))
+	// The backoffers not created from the context are not collected.
+	assert.Nil(t, NewBackofferWithVars(context.Background(), 2000, nil).Backoff(BoRegionMiss, errors.New("region miss")))
+
+	stats := collector.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, int64(2), stats[BoRegionMiss.String()].Times)
+	assert.Equal(t, int64(b1.GetBackoffSleepMS()[BoRegionMiss.String()]), stats[BoRegionMiss.String()].SleepMS)
+	assert.Equal(t, BackoffStat{Times: 1, SleepMS: 5}, stats[BoTxnLockFast.String()])
+	total := collector.Total()
+	assert.Equal(t, int64(3), total.Times)
+	assert.Equal(t, int64(b1.GetTotalSleep()+5), total.SleepMS)
+
+	after := BackoffStats()
+	assert.Equal(t, before[BoRegionMiss.String()].Times+3, after[BoRegionMiss.String()].Times)
+	assert.Equal(t, before[BoTxnLockFast.String()].Times+1, after[BoTxnLockFast.String()].Times)
+}
@@ -36,6 +36,7 @@ package retry
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"math/rand"
 	"strings"
@@ -56,16 +57,41 @@ type Config struct {
 	metric *prometheus.Observer
 	fnCfg  *BackoffFnCfg
 	err    error
+	// scale multiplies the sleep time of the backoff function if it's not 0.
+	scale float64
 }
 
 // backoffFn is the backoff function which compute the sleep time and do sleep.
 type backoffFn func(ctx context.Context, maxSleepMs int) int
 
 func (c *Config) createBackoffFn(vars *kv.Variables) backoffFn {
+	base, maxSleep := c.fnCfg.base, c.fnCfg.cap
 	if strings.EqualFold(c.name, txnLockFastName) {
-		return newBackoffFn(vars.BackoffLockFast, c.fnCfg.cap, c.fnCfg.jitter)
+		base = vars.BackoffLockFast
 	}
-	return newBackoffFn(c.fnCfg.base, c.fnCfg.cap, c.fnCfg.jitter)
+	if c.scale != 0 {
+		base, maxSleep = int(float64(base)*c.scale), int(float64(maxSleep)*c.scale)
+	}
+	return newBackoffFn(base, maxSleep, c.fnCfg.jitter)
+}
+
+// fnKey is the key of the backoff function of the config in a Backoffer.
+func (c *Config) fnKey() string {
+	if c.scale == 0 {
+		return c.name
+	}
+	return fmt.Sprintf("%s*%g", c.name, c.scale)
+}
+
+// Scaled returns a copy of the config whose sleep time is multiplied by scale. The backoff is still recorded under
+// the name of c. It returns c itself if scale is not positive or 1.
+func (c *Config) Scaled(scale float64) *Config {
+	if scale <= 0 || scale == 1 {
+		return c
+	}
+	cfg := *c
+	cfg.scale = scale
+	return &cfg
 }
 
 // BackoffFnCfg is the configuration for the backoff func which implements exponential backoff with
@@ -135,6 +161,8 @@ var (
 	BoMaxTsNotSynced           = NewConfig("maxTsNotSynced", &metrics.BackoffHistogramEmpty, NewBackoffFnCfg(2, 500, NoJitter), tikverr.ErrTiKVMaxTimestampNotSynced)
 	BoMaxRegionNotInitialized  = NewConfig("regionNotInitialized", &metrics.BackoffHistogramEmpty, NewBackoffFnCfg(2, 1000, NoJitter), tikverr.ErrRegionNotInitialized)
 	BoIsWitness                = NewConfig("isWitness", &metrics.BackoffHistogramIsWitness, NewBackoffFnCfg(1000, 10000, EqualJitter), tikverr.ErrIsWitness)
+	// BoWriteConflict is used by the client-side helpers that retry a whole transaction on write conflict.
+	BoWriteConflict = NewConfig("writeConflict", &metrics.BackoffHistogramWriteConflict, NewBackoffFnCfg(2, 1000, FullJitter), tikverr.ErrWriteConflictRetryExceeded)
 	// TxnLockFast's `base` load from vars.BackoffLockFast when create BackoffFn.
 	BoTxnLockFast = NewConfig(txnLockFastName, &metrics.BackoffHistogramLockFast, NewBackoffFnCfg(2, 3000, EqualJitter), tikverr.ErrResolveLockTimeout)
 )
@@ -103,6 +103,8 @@ var (
 	ErrUnknown = errors.New("unknown")
 	// ErrResultUndetermined is the error when execution result is unknown.
 	ErrResultUndetermined = errors.New("execution result undetermined")
+	// ErrWriteConflictRetryExceeded is the error when a transaction keeps meeting write conflicts after retries.
+	ErrWriteConflictRetryExceeded = errors.New("write conflict retry exceeded")
 )
 
 type ErrQueryInterruptedWithSignal struct {
@@ -176,9 +178,11 @@ func IsErrWriteConflict(err error) bool {
 	return errors.As(err, &e)
 }
 
-// NewErrWriteConflict generates an ErrWriteConflict with conflict and increase the TiKVTxnWriteConflictCounter metrics.
+// NewErrWriteConflict generates an ErrWriteConflict with conflict and increase the TiKVTxnWriteConflictCounter and
+// TiKVWriteConflictByRangeCounter metrics.
 func NewErrWriteConflict(conflict *kvrpcpb.WriteConflict) *ErrWriteConflict {
 	metrics.TiKVTxnWriteConflictCounter.Inc()
+	metrics.TiKVWriteConflictByRangeCounter.WithLabelValues(metrics.KeyRangeBucket(conflict.GetKey())).Inc()
 	return &ErrWriteConflict{WriteConflict: conflict}
 }
 
@@ -220,14 +224,31 @@ func (e *ErrTxnTooLarge) Error() string {
 	return fmt.Sprintf("txn too large, size: %v.", e.Size)
 }
 
+// ErrKeyTooLarge is the error when a key is too large.
 type ErrKeyTooLarge struct {
 	KeySize int
+	// Limit is the max size of a key, or 0 if it's unknown.
+	Limit int
 }
 
 func (e *ErrKeyTooLarge) Error() string {
+	if e.Limit > 0 {
+		return fmt.Sprintf("key size too large, size: %v, limit: %v.", e.KeySize, e.Limit)
+	}
 	return fmt.Sprintf("key size too large, size: %v.", e.KeySize)
 }
 
+// ErrValueTooLarge is the error when the value of a key is too large.
+type ErrValueTooLarge struct {
+	Key   []byte
+	Size  int
+	Limit int
+}
+
+func (e *ErrValueTooLarge) Error() string {
+	return fmt.Sprintf("value size too large, key: %s, size: %v, limit: %v.", redact.Key(e.Key), e.Size, e.Limit)
+}
+
 // ErrEntryTooLarge is the error when a key value entry is too large.
 type ErrEntryTooLarge struct {
 	Limit uint64
@@ -281,6 +302,131 @@ func (e *ErrTxnAbortedByGC) Error() string {
 	return fmt.Sprintf("GC life time is shorter than transaction duration, transaction start ts is %v (%v), txn safe point is %v (%v)", e.TxnStartTS, e.TxnStartTSTime, e.TxnSafePoint, e.TxnSafePointTime)
 }
 
+// ErrSnapshotExpired is the error that the ts of a snapshot is behind the txn safe point, so the data of the snapshot
+// may have been garbage collected.
+type ErrSnapshotExpired struct {
+	SnapshotTS   uint64
+	TxnSafePoint uint64
+}
+
+func (e *ErrSnapshotExpired) Error() string {
+	return fmt.Sprintf("snapshot ts %d is behind the txn safe point %d", e.SnapshotTS, e.TxnSafePoint)
+}
+
+// ErrRequestRejected is the error that a request is rejected by the admission controller of the client before it's
+// sent to the store.
+type ErrRequestRejected struct {
+	Addr  string
+	Cause error
+}
+
+func (e *ErrRequestRejected) Error() string {
+	return fmt.Sprintf("request to %s is rejected by admission control: %v", e.Addr, e.Cause)
+}
+
+// Unwrap returns the cause of the rejection.
+func (e *ErrRequestRejected) Unwrap() error {
+	return e.Cause
+}
+
+// ErrInvalidRequest is the error that a request fails the validation of the client before it's sent to the store,
+// see tikvrpc.Request.Validate.
+type ErrInvalidRequest struct {
+	Type   string
+	Field  string
+	Reason string
+}
+
+func (e *ErrInvalidRequest) Error() string {
+	return fmt.Sprintf("invalid %s request: %s %s", e.Type, e.Field, e.Reason)
+}
+
+// ErrClusterIDMismatch is the error that the cluster ID of PD or a store doesn't match the one the client expects, e.g.
+// the client is pointed to a wrong cluster by mistake. It's never retried to avoid writing data to the wrong cluster.
+type ErrClusterIDMismatch struct {
+	// Source is where the mismatch is found, "pd" or "tikv".
+	Source string
+	// Addr is the address of the store reporting the mismatch, empty if Source is "pd".
+	Addr     string
+	Expected uint64
+	// Actual is the cluster ID of Source, 0 if it's unknown.
+	Actual uint64
+}
+
+func (e *ErrClusterIDMismatch) Error() string {
+	if e.Addr != "" {
+		return fmt.Sprintf("cluster ID mismatch: expected %d, got %d from %s %s", e.Expected, e.Actual, e.Source, e.Addr)
+	}
+	return fmt.Sprintf("cluster ID mismatch: expected %d, got %d from %s", e.Expected, e.Actual, e.Source)
+}
+
+// ErrLockResolveBudgetExceeded is the error that KVSnapshot.ForEach stops because resolving the locks in the range
+// exceeds the budget. The pairs before ResumeKey have been iterated, so it can be resumed from ResumeKey.
+type ErrLockResolveBudgetExceeded struct {
+	ResumeKey     []byte
+	ResolvedLocks int
+	ResolveTime   time.Duration
+}
+
+func (e *ErrLockResolveBudgetExceeded) Error() string {
+	return fmt.Sprintf("lock resolution budget exceeded after resolving %d locks in %s, resume from key %s",
+		e.ResolvedLocks, e.ResolveTime, redact.Key(e.ResumeKey))
+}
+
+// ErrRunawayOperation is the cause of the context of an operation canceled by the runaway watchdog because it
+// exceeds the thresholds.
+type ErrRunawayOperation struct {
+	Name    string
+	Reason  string
+	Elapsed time.Duration
+	RU      float64
+}
+
+func (e *ErrRunawayOperation) Error() string {
+	return fmt.Sprintf("runaway operation %s canceled: %s exceeded, elapsed %s, RU %.2f", e.Name, e.Reason, e.Elapsed, e.RU)
+}
+
+// ErrAPIVersionMismatch is the error that the API version or mode of the client doesn't match the storage config of
+// the TiKV cluster.
+type ErrAPIVersionMismatch struct {
+	StoreID uint64
+	Addr    string
+	Mode    string
+	// Expected is the API version required by the client, or the API version served by the other stores.
+	Expected kvrpcpb.APIVersion
+	// Actual is the API version served by the store.
+	Actual kvrpcpb.APIVersion
+}
+
+func (e *ErrAPIVersionMismatch) Error() string {
+	if e.StoreID == 0 {
+		return fmt.Sprintf("API version mismatch for %s mode, expected %s, the cluster serves %s", e.Mode, e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("API version mismatch for %s mode, expected %s, store %d (%s) serves %s", e.Mode, e.Expected, e.StoreID, e.Addr, e.Actual)
+}
+
+// ErrMemoryPressure is the error that a request is rejected because the memory usage of the client exceeds the
+// limit of config.TiKVClient.MemoryControl.
+type ErrMemoryPressure struct {
+	Usage uint64
+	Limit uint64
+}
+
+func (e *ErrMemoryPressure) Error() string {
+	return fmt.Sprintf("memory usage of the client %d exceeds the limit %d", e.Usage, e.Limit)
+}
+
+// ErrUnknownValueTransformer is the error that a value is tagged with a transformer not registered in the client, so
+// it can't be restored.
+type ErrUnknownValueTransformer struct {
+	Key []byte
+	ID  byte
+}
+
+func (e *ErrUnknownValueTransformer) Error() string {
+	return fmt.Sprintf("the value of key %s is transformed by unknown transformer %d", redact.Key(e.Key), e.ID)
+}
+
 // ErrTokenLimit is the error that token is up to the limit.
 type ErrTokenLimit struct {
 	StoreID uint64
@@ -5,7 +5,9 @@ import (
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/tikv/client-go/v2/metrics"
 )
 
 func TestExtractDebugInfoStrFromKeyErr(t *testing.T) {
@@ -66,3 +68,27 @@ func TestExtractDebugInfoStrFromKeyErr(t *testing.T) {
 		DebugInfo:       debugInfo,
 	}))
 }
+
+func TestWriteConflictByRange(t *testing.T) {
+	metrics.SetKeyRangeBucketer(func(key []byte) string { return string(key[:1]) })
+	defer metrics.SetKeyRangeBucketer(nil)
+
+	counter := func(bucket string) float64 {
+		return testutil.ToFloat64(metrics.TiKVWriteConflictByRangeCounter.WithLabelValues(bucket))
+	}
+	a, b := counter("a"), counter("b")
+	NewErrWriteConflictWithArgs(1, 2, 3, []byte("a1"), kvrpcpb.WriteConflict_Optimistic)
+	NewErrWriteConflictWithArgs(1, 2, 3, []byte("a2"), kvrpcpb.WriteConflict_Optimistic)
+	NewErrWriteConflictWithArgs(1, 2, 3, []byte("b1"), kvrpcpb.WriteConflict_Optimistic)
+	assert.Equal(t, a+2, counter("a"))
+	assert.Equal(t, b+1, counter("b"))
+	// The bucketer isn't called with the nil key.
+	all := counter(metrics.DefaultKeyRangeBucket)
+	NewErrWriteConflict(nil)
+	assert.Equal(t, all+1, counter(metrics.DefaultKeyRangeBucket))
+
+	metrics.SetKeyRangeBucketer(nil)
+	all = counter(metrics.DefaultKeyRangeBucket)
+	NewErrWriteConflictWithArgs(1, 2, 3, []byte("a1"), kvrpcpb.WriteConflict_Optimistic)
+	assert.Equal(t, all+1, counter(metrics.DefaultKeyRangeBucket))
+}
@@ -39,6 +39,7 @@ package tikv_test
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	stderrs "errors"
 	"fmt"
 	"math"
@@ -344,6 +345,22 @@ func (s *testCommitterSuite) TestContextCancelRetryable() {
 	s.True(ok, fmt.Sprintf("err: %s", err))
 }
 
+func (s *testCommitterSuite) TestCommitErrorDetails() {
+	txn1 := s.begin()
+	txn2 := s.begin()
+	s.Nil(txn1.Set([]byte("a"), []byte("1")))
+	s.Nil(txn2.Set([]byte("a"), []byte("2")))
+	s.Nil(txn1.Commit(context.Background()))
+	s.Nil(txn1.CommitErrorDetails())
+
+	err := txn2.Commit(context.Background())
+	// The error is returned as is, with the details collected before the failure kept by the transaction.
+	_, ok := err.(*tikverr.ErrWriteConflictInLatch)
+	s.True(ok, fmt.Sprintf("err: %s", err))
+	s.Require().NotNil(txn2.CommitErrorDetails())
+	s.Equal(1, txn2.CommitErrorDetails().WriteKeys)
+}
+
 func (s *testCommitterSuite) TestContextCancelCausingUndetermined() {
 	// For a normal transaction, if RPC returns context.Canceled error while sending commit
 	// requests, the transaction should go to the undetermined state.
@@ -2839,3 +2856,332 @@ func (s *testCommitterSuite) TestFailWithUndeterminedResult() {
 	s.NotNil(err)
 	s.True(tikverr.IsErrorUndetermined(err))
 }
+
+func (s *testCommitterSuite) TestDryRun() {
+	s.mustCommit(map[string]string{"a1": "v"})
+
+	txn := s.begin()
+	s.Nil(txn.Set([]byte("a2"), []byte("v")))
+	s.Nil(txn.Set([]byte("b1"), []byte("v")))
+	s.Nil(txn.Delete([]byte("b2")))
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("a1"), []byte("v"), kv.SetPresumeKeyNotExists))
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("c1"), []byte("v"), kv.SetPresumeKeyNotExists))
+	report, err := txn.DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(5, report.Mutations)
+	s.Equal(14, report.Bytes)
+	s.Equal(3, report.Regions)
+	s.Equal(3, report.PrewriteRPCs)
+	s.Equal(3, report.CommitRPCs)
+	s.Equal([][]byte{[]byte("a1")}, report.ExistingKeys)
+	err = txn.Commit(context.Background())
+	var existErr *tikverr.ErrKeyExist
+	s.ErrorAs(err, &existErr)
+
+	// The transaction is committed as usual after a dry run.
+	txn = s.begin()
+	s.Nil(txn.Set([]byte("a2"), []byte("v2")))
+	s.Nil(txn.Set([]byte("c2"), []byte("v2")))
+	report, err = txn.DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(2, report.Regions)
+	s.Empty(report.ExistingKeys)
+	s.Nil(txn.Commit(context.Background()))
+	s.checkValues(map[string]string{"a2": "v2", "c2": "v2"})
+
+	report, err = s.begin().DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(&transaction.DryRunReport{}, report)
+}
+
+func (s *testCommitterSuite) TestExportImportMutations() {
+	s.mustCommit(map[string]string{"a1": "v", "b1": "v"})
+
+	txn := s.begin()
+	s.Nil(txn.Set([]byte("a2"), []byte("v2")))
+	s.Nil(txn.Delete([]byte("b1")))
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("c1"), []byte("v2"), kv.SetPresumeKeyNotExists))
+	var buf bytes.Buffer
+	s.Nil(txn.ExportMutations(&buf))
+	s.Nil(txn.Rollback())
+	exported := buf.Bytes()
+
+	txn = s.begin()
+	s.Nil(txn.ImportMutations(bytes.NewReader(exported)))
+	s.Equal(3, txn.Len())
+	flags, err := txn.GetMemBuffer().GetFlags([]byte("c1"))
+	s.Nil(err)
+	s.True(flags.HasPresumeKeyNotExists())
+	s.Nil(txn.Commit(context.Background()))
+	s.checkValues(map[string]string{"a1": "v", "a2": "v2", "c1": "v2"})
+	_, err = s.begin().Get(context.Background(), []byte("b1"))
+	s.True(tikverr.IsErrNotFound(err))
+
+	// The inserted key exists now, so committing the mutations again fails.
+	txn = s.begin()
+	s.Nil(txn.ImportMutations(bytes.NewReader(exported)))
+	var existErr *tikverr.ErrKeyExist
+	s.ErrorAs(txn.Commit(context.Background()), &existErr)
+
+	s.Error(s.begin().ImportMutations(bytes.NewReader([]byte("invalid"))))
+	s.Error(s.begin().ImportMutations(bytes.NewReader(exported[:len(exported)-1])))
+}
+
+func (s *testCommitterSuite) TestInsertOnlyCommit() {
+	defer kv.TxnCommitBatchSize.Store(kv.TxnCommitBatchSize.Load())
+	kv.TxnCommitBatchSize.Store(1)
+	s.mustCommit(map[string]string{"a1": "v"})
+
+	// The prewrite requests of insert-only transactions are batched by TxnInsertOnlyCommitBatchSize if it's set.
+	txn := s.begin()
+	for _, k := range []string{"a2", "a3", "a4"} {
+		s.Nil(txn.GetMemBuffer().SetWithFlags([]byte(k), []byte("v"), kv.SetPresumeKeyNotExists))
+	}
+	report, err := txn.DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(3, report.PrewriteRPCs)
+	defer kv.TxnInsertOnlyCommitBatchSize.Store(0)
+	kv.TxnInsertOnlyCommitBatchSize.Store(64 * 1024)
+	report, err = txn.DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(1, report.PrewriteRPCs)
+	s.Equal(3, report.CommitRPCs)
+	s.Nil(txn.Set([]byte("a5"), []byte("v")))
+	report, err = txn.DryRun(context.Background())
+	s.Nil(err)
+	s.Equal(4, report.PrewriteRPCs)
+	s.Nil(txn.Commit(context.Background()))
+
+	// An existing key is overwritten by a blind write.
+	txn = s.begin()
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("a1"), []byte("v2"), kv.SetPresumeKeyNotExists))
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("a6"), []byte("v2"), kv.SetPresumeKeyNotExists))
+	txn.SetInsertOnlyBlindWrite(true)
+	s.Nil(txn.Commit(context.Background()))
+	s.checkValues(map[string]string{"a1": "v2", "a6": "v2"})
+
+	txn = s.begin()
+	s.Nil(txn.GetMemBuffer().SetWithFlags([]byte("a1"), []byte("v3"), kv.SetPresumeKeyNotExists))
+	var existErr *tikverr.ErrKeyExist
+	s.ErrorAs(txn.Commit(context.Background()), &existErr)
+}
+
+func (s *testCommitterSuite) TestOffHeapMemBuffer() {
+	ctx := context.Background()
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.SetOffHeapMemBuffer())
+	for i := 0; i < 1000; i++ {
+		key := binary.BigEndian.AppendUint32([]byte("k"), uint32(i))
+		s.Require().Nil(txn.Set(key, bytes.Repeat(key, 256)))
+	}
+	// The keys and values read from the memory buffer are still valid after it's released.
+	lastKey := binary.BigEndian.AppendUint32([]byte("k"), 999)
+	bufVal, err := txn.Get(ctx, lastKey)
+	s.Require().Nil(err)
+	iter, err := txn.Iter(nil, nil)
+	s.Require().Nil(err)
+	bufKey := iter.Key()
+	iter.Close()
+	s.NotNil(txn.ReleaseMemBuffer())
+	s.Require().Nil(txn.Commit(ctx))
+	s.Nil(txn.ReleaseMemBuffer())
+	s.Zero(txn.GetMemBuffer().Mem())
+	s.Equal(binary.BigEndian.AppendUint32([]byte("k"), 0), bufKey)
+	s.Equal(bytes.Repeat(lastKey, 256), bufVal)
+	val, err := s.begin().Get(ctx, lastKey)
+	s.Nil(err)
+	s.Equal(bufVal, val)
+
+	// It can't be set after the transaction is written.
+	txn, err = s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("1")))
+	s.NotNil(txn.SetOffHeapMemBuffer())
+	s.Nil(txn.Rollback())
+	s.Nil(txn.ReleaseMemBuffer())
+}
+
+func (s *testCommitterSuite) TestCommitOrderOptions() {
+	ctx := context.Background()
+	for _, inBackground := range []bool{true, false} {
+		txn, err := s.store.Begin()
+		s.Require().Nil(err)
+		txn.SetEnableAsyncCommit(false)
+		txn.SetEnable1PC(false)
+		txn.SetStrictPrimaryFirst(true)
+		txn.SetSecondaryConcurrency(1)
+		txn.SetCommitSecondariesInBackground(inBackground)
+		for _, key := range []string{"a", "b", "x", "y"} {
+			s.Require().Nil(txn.Set([]byte(key), []byte(key)))
+		}
+		select {
+		case <-txn.SecondariesCommitted():
+			s.Fail("secondaries are committed before commit")
+		default:
+		}
+		s.Require().Nil(txn.Commit(ctx))
+		if !inBackground {
+			select {
+			case <-txn.SecondariesCommitted():
+			default:
+				s.Fail("secondaries should be committed when Commit returns")
+			}
+		}
+		<-txn.SecondariesCommitted()
+		s.checkValues(map[string]string{"a": "a", "b": "b", "x": "x", "y": "y"})
+	}
+
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Nil(txn.Rollback())
+	<-txn.SecondariesCommitted()
+}
+
+func (s *testCommitterSuite) TestSecondariesCommittedCallback() {
+	for _, asyncCommit := range []bool{false, true} {
+		for _, inBackground := range []bool{true, false} {
+			txn, err := s.store.Begin()
+			s.Require().Nil(err)
+			txn.SetEnableAsyncCommit(asyncCommit)
+			txn.SetEnable1PC(false)
+			txn.SetCommitSecondariesInBackground(inBackground)
+			called := make(chan error, 1)
+			txn.SetSecondariesCommittedCallback(func(err error) { called <- err })
+			s.Require().Nil(txn.Set([]byte("a"), []byte("1")))
+			s.Require().Nil(txn.Set([]byte("x"), []byte("2")))
+			s.Require().Nil(txn.Commit(context.Background()))
+			<-txn.SecondariesCommitted()
+			s.Nil(<-called)
+			s.Nil(txn.SecondariesCommitError())
+			s.checkValues(map[string]string{"x": "2"})
+		}
+	}
+}
+
+type writeObserverFunc func(startTS, commitTS uint64, mutations transaction.CommitterMutations)
+
+func (f writeObserverFunc) OnCommitted(startTS, commitTS uint64, mutations transaction.CommitterMutations) {
+	f(startTS, commitTS, mutations)
+}
+
+func (s *testCommitterSuite) TestWriteObserver() {
+	ctx := context.Background()
+	for _, enable1PC := range []bool{false, true} {
+		var (
+			called         int
+			observedCommit uint64
+		)
+		observer := writeObserverFunc(func(startTS, commitTS uint64, mutations transaction.CommitterMutations) {
+			called++
+			observedCommit = commitTS
+			s.Greater(commitTS, startTS)
+			s.Equal(2, mutations.Len())
+			s.Equal([]byte("a"), mutations.GetKey(0))
+			s.Equal([]byte("1"), mutations.GetValue(0))
+			s.Equal([]byte("x"), mutations.GetKey(1))
+		})
+		txn, err := s.store.Begin()
+		s.Require().Nil(err)
+		txn.SetEnable1PC(enable1PC)
+		txn.SetWriteObserver(observer)
+		s.Require().Nil(txn.Set([]byte("a"), []byte("1")))
+		s.Require().Nil(txn.Delete([]byte("x")))
+		s.Require().Nil(txn.Commit(ctx))
+		s.Equal(1, called)
+		s.Equal(txn.CommitTS(), observedCommit)
+
+		// Read-only and failed transactions are not observed.
+		txn, err = s.store.Begin()
+		s.Require().Nil(err)
+		txn.SetWriteObserver(observer)
+		s.Require().Nil(txn.Commit(ctx))
+		txn1, err := s.store.Begin()
+		s.Require().Nil(err)
+		txn1.SetWriteObserver(observer)
+		s.Require().Nil(txn1.Set([]byte("a"), []byte("2")))
+		txn2, err := s.store.Begin()
+		s.Require().Nil(err)
+		s.Require().Nil(txn2.Set([]byte("a"), []byte("3")))
+		s.Require().Nil(txn2.Commit(ctx))
+		s.NotNil(txn1.Commit(ctx))
+		s.Equal(1, called)
+	}
+}
+
+func (s *testCommitterSuite) TestLogicallyCommittedCallback() {
+	for _, mode := range []string{"2pc", "async_commit", "1pc"} {
+		txn, err := s.store.Begin()
+		s.Require().Nil(err)
+		txn.SetEnableAsyncCommit(mode == "async_commit")
+		txn.SetEnable1PC(mode == "1pc")
+		var (
+			mu     sync.Mutex
+			events []string
+		)
+		txn.SetLogicallyCommittedCallback(func(commitTS uint64) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, "logically committed")
+			s.Greater(commitTS, txn.StartTS())
+		})
+		txn.SetSecondariesCommittedCallback(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, "secondaries committed")
+		})
+		s.Require().Nil(txn.Set([]byte("a"), []byte(mode)))
+		if mode != "1pc" {
+			s.Require().Nil(txn.Set([]byte("x"), []byte(mode)))
+		}
+		s.Require().Nil(txn.Commit(context.Background()))
+		<-txn.SecondariesCommitted()
+		mu.Lock()
+		s.Equal([]string{"logically committed", "secondaries committed"}, events, mode)
+		mu.Unlock()
+	}
+
+	// The callback is not called for failed transactions.
+	txn1, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn1.SetLogicallyCommittedCallback(func(uint64) { s.Fail("failed txn is logically committed") })
+	s.Require().Nil(txn1.Set([]byte("a"), []byte("1")))
+	txn2, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn2.Set([]byte("a"), []byte("2")))
+	s.Require().Nil(txn2.Commit(context.Background()))
+	s.NotNil(txn1.Commit(context.Background()))
+}
+
+func (s *testCommitterSuite) TestMemoryControl() {
+	// The memory usage is only accounted when the memory budget is set.
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 1 << 40
+	})()
+	base := tikv.GetMemoryUsage().TxnBuffer
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.False(txn.MemHookSet())
+	var hooked uint64
+	txn.SetMemoryFootprintChangeHook(func(mem uint64) { hooked = mem })
+	s.True(txn.MemHookSet())
+	s.Require().Nil(txn.Set([]byte("a"), make([]byte, 64*1024)))
+	s.Greater(hooked, uint64(0))
+	s.Equal(base+hooked, tikv.GetMemoryUsage().TxnBuffer)
+
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 1
+	})
+	var pressure *tikverr.ErrMemoryPressure
+	// Only large scans are rejected under memory pressure.
+	iter, err := txn.Iter([]byte("a"), nil)
+	s.Require().Nil(err)
+	iter.Close()
+	txn.GetSnapshot().SetScanBatchSize(1024)
+	_, err = txn.Iter([]byte("a"), nil)
+	s.True(errors.As(err, &pressure))
+	s.Equal(uint64(1), pressure.Limit)
+
+	s.Require().Nil(txn.Rollback())
+	s.Equal(base, tikv.GetMemoryUsage().TxnBuffer)
+}
@@ -979,6 +979,66 @@ func (s *testLockSuite) TestResolveLocksForRead() {
 	s.Equal(committedLocks, committed)
 }
 
+func (s *testLockSuite) TestResolveLocksOfManyTxns() {
+	var locks []*txnlock.Lock
+	var txns []uint64
+	for i := 0; i < 8; i++ {
+		key, primary := []byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprintf("p%d", i))
+		startTS, _ := s.lockKey(key, key, primary, primary, 3000, false, false)
+		txns = append(txns, startTS)
+		locks = append(locks, s.mustGetLock(key), s.mustGetLock(primary))
+	}
+
+	// Each CheckTxnStatus is delayed by 100ms, the statuses should be checked concurrently.
+	s.Nil(failpoint.Enable("tikvclient/getTxnStatusDelay", "return"))
+	defer func() {
+		s.Nil(failpoint.Disable("tikvclient/getTxnStatusDelay"))
+	}()
+	readStartTS, err := s.store.GetOracle().GetTimestamp(context.Background(), &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	s.Nil(err)
+	bo := tikv.NewBackoffer(context.Background(), getMaxBackoff)
+	lr := s.store.NewLockResolver()
+	defer lr.Close()
+	start := time.Now()
+	_, resolved, _, err := lr.ResolveLocksForRead(bo, readStartTS, locks, false)
+	s.Nil(err)
+	s.Less(time.Since(start), 500*time.Millisecond)
+	// The min commit ts of the locks are pushed.
+	s.Len(resolved, len(locks))
+	for i, txnID := range txns {
+		s.Equal(txnID, resolved[2*i])
+		s.Equal(txnID, resolved[2*i+1])
+	}
+}
+
+func (s *testLockSuite) TestResolvedTxnStatusSharedByResolvers() {
+	startTS, commitTS := s.lockKey([]byte("k1"), []byte("v1"), []byte("k2"), []byte("v2"), 3000, true, false)
+	lock := s.mustGetLock([]byte("k1"))
+	lr1 := s.store.NewLockResolver()
+	defer lr1.Close()
+	bo := tikv.NewBackoffer(context.Background(), getMaxBackoff)
+	_, err := lr1.ResolveLocks(bo, 0, []*txnlock.Lock{lock})
+	s.Nil(err)
+
+	// The status is cached, so it's got without sending any request.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lr2 := s.store.NewLockResolver()
+	defer lr2.Close()
+	status, err := lr2.GetTxnStatus(tikv.NewBackoffer(ctx, getMaxBackoff), startTS, []byte("k2"), 0, math.MaxUint64, false, false, nil)
+	s.Nil(err)
+	s.True(status.IsCommitted())
+	s.Equal(commitTS, status.CommitTS())
+	s.Equal(kvrpcpb.Action_NoAction, status.Action())
+
+	startTS, _ = s.lockKey([]byte("k3"), []byte("v3"), []byte("k4"), []byte("v4"), 3000, true, false)
+	_, err = lr2.GetTxnStatus(tikv.NewBackoffer(ctx, getMaxBackoff), startTS, []byte("k4"), 0, math.MaxUint64, false, false, nil)
+	s.NotNil(err)
+
+	s.NotNil(txnlock.SetSharedResolvedCacheSize(-1))
+	s.Nil(txnlock.SetSharedResolvedCacheSize(txnlock.DefaultSharedResolvedCacheSize))
+}
+
 func (s *testLockSuite) TestLockWaitTimeLimit() {
 	k1 := []byte("k1")
 	k2 := []byte("k2")
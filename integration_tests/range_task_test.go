@@ -38,10 +38,14 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"path/filepath"
 	"sort"
+	"sync/atomic"
 	"testing"
 
+	"github.com/pingcap/kvproto/pkg/errorpb"
 	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/config/retry"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/testutils"
 	"github.com/tikv/client-go/v2/tikv"
@@ -263,3 +267,95 @@ func (s *testRangeTaskSuite) TestRangeTaskError() {
 		s.testRangeTaskErrorImpl(concurrency)
 	}
 }
+
+func (s *testRangeTaskSuite) TestRunRangeTasks() {
+	for concurrency := 1; concurrency < 5; concurrency++ {
+		for i, r := range s.testRanges {
+			ranges := make(chan *kv.KeyRange, 100)
+			stat, err := rangetask.RunRangeTasks(context.Background(), s.store, []kv.KeyRange{r}, concurrency,
+				func(bo *retry.Backoffer, task rangetask.RegionTask) (*errorpb.Error, error) {
+					ranges <- &task.KeyRange
+					return nil, nil
+				})
+			s.Nil(err)
+			s.checkRanges(collect(ranges), s.expectedRanges[i])
+			s.Equal(len(s.expectedRanges[i]), stat.CompletedRegions)
+			s.Zero(stat.RegionErrors)
+		}
+	}
+}
+
+func (s *testRangeTaskSuite) TestRunRangeTasksRetryAndError() {
+	// The region errors are retried.
+	var failed atomic.Bool
+	stat, err := rangetask.RunRangeTasks(context.Background(), s.store, []kv.KeyRange{makeRange("a", "d")}, 2,
+		func(bo *retry.Backoffer, task rangetask.RegionTask) (*errorpb.Error, error) {
+			if bytes.Equal(task.StartKey, []byte("b")) && failed.CompareAndSwap(false, true) {
+				return &errorpb.Error{EpochNotMatch: &errorpb.EpochNotMatch{}}, nil
+			}
+			return nil, nil
+		})
+	s.Nil(err)
+	s.Equal(3, stat.CompletedRegions)
+	s.Equal(1, stat.RegionErrors)
+
+	// The other errors stop all the tasks.
+	_, err = rangetask.RunRangeTasks(context.Background(), s.store, []kv.KeyRange{makeRange("", "")}, 2,
+		func(bo *retry.Backoffer, task rangetask.RegionTask) (*errorpb.Error, error) {
+			if bytes.Equal(task.StartKey, []byte("c")) {
+				return nil, errors.New("test error")
+			}
+			return nil, nil
+		})
+	s.EqualError(err, "test error")
+}
+
+func (s *testRangeTaskSuite) TestRangeTaskCheckpoint() {
+	checkpoint := rangetask.NewFileCheckpointStore(filepath.Join(s.T().TempDir(), "checkpoint"))
+	ranges := make(chan *kv.KeyRange, 100)
+	failed := true
+	handler := func(ctx context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+		if failed && bytes.Equal(r.StartKey, []byte("c")) {
+			return rangetask.TaskStat{FailedRegions: 1}, errors.New("test error")
+		}
+		ranges <- &r
+		return rangetask.TaskStat{CompletedRegions: 1}, nil
+	}
+	runner := rangetask.NewRangeTaskRunnerWithID("test-checkpoint-runner", "job-1", s.store, 1, handler)
+	runner.SetRegionsPerTask(1)
+	runner.SetCheckpointStore(checkpoint)
+	s.NotNil(runner.RunOnRange(context.Background(), []byte("a"), []byte("e")))
+	s.checkRanges(collect(ranges), []kv.KeyRange{makeRange("a", "b"), makeRange("b", "c")})
+	completed, err := checkpoint.LoadCompleted(context.Background(), "job-1")
+	s.Nil(err)
+	s.Equal([]kv.KeyRange{makeRange("a", "c")}, completed)
+
+	// The completed ranges are skipped after restarting.
+	failed = false
+	runner = rangetask.NewRangeTaskRunnerWithID("test-checkpoint-runner", "job-1", s.store, 1, handler)
+	runner.SetRegionsPerTask(1)
+	runner.SetCheckpointStore(checkpoint)
+	s.Nil(runner.RunOnRange(context.Background(), []byte("a"), []byte("e")))
+	s.checkRanges(collect(ranges), []kv.KeyRange{makeRange("c", "d"), makeRange("d", "e")})
+	// The checkpoints are cleared after the whole range is completed.
+	completed, err = checkpoint.LoadCompleted(context.Background(), "job-1")
+	s.Nil(err)
+	s.Empty(completed)
+
+	// The checkpoints are kept if the task is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner = rangetask.NewRangeTaskRunnerWithID("test-checkpoint-runner", "job-2", s.store, 1,
+		func(_ context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+			if bytes.Equal(r.StartKey, []byte("b")) {
+				cancel()
+			}
+			return rangetask.TaskStat{CompletedRegions: 1}, nil
+		})
+	runner.SetRegionsPerTask(1)
+	runner.SetCheckpointStore(checkpoint)
+	_ = runner.RunOnRange(ctx, []byte("a"), []byte("e"))
+	completed, err = checkpoint.LoadCompleted(context.Background(), "job-2")
+	s.Nil(err)
+	s.NotEmpty(completed)
+}
@@ -36,11 +36,19 @@ package tikv_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/txnkv"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
@@ -201,3 +209,264 @@ func (s *testScanSuite) TestScan() {
 		committer4.Cleanup(context.Background())
 	}
 }
+
+func (s *testScanSuite) TestParallelScan() {
+	prefix := []byte("parallel")
+	makeKey := func(i int) []byte {
+		return append(append([]byte(nil), prefix...), fmt.Sprintf("%10d", i)...)
+	}
+	const rowNum = 1000
+	txn := s.beginTxn()
+	for i := 0; i < rowNum; i++ {
+		s.Require().Nil(txn.Set(makeKey(i), s.makeValue(i)))
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+	mockTableID := int64(999)
+	for _, i := range []int{100, 300, 600, 900} {
+		_, err := s.store.SplitRegions(context.Background(), [][]byte{makeKey(i)}, false, &mockTableID)
+		s.Require().Nil(err)
+	}
+
+	snapshot := s.beginTxn().GetSnapshot()
+	start, end := makeKey(50), makeKey(950)
+	scan := func(order txnsnapshot.ScanOrder) []int {
+		var rows []int
+		err := snapshot.ParallelScan(context.Background(), start, end, txnsnapshot.ParallelScanOptions{Concurrency: 3, Order: order},
+			func(key, value []byte) error {
+				i, err := strconv.Atoi(string(value))
+				s.Require().Nil(err)
+				s.Equal(makeKey(i), key)
+				rows = append(rows, i)
+				return nil
+			})
+		s.Require().Nil(err)
+		return rows
+	}
+	expected := make([]int, 0, 900)
+	for i := 50; i < 950; i++ {
+		expected = append(expected, i)
+	}
+	s.Equal(expected, scan(txnsnapshot.ScanOrderKey))
+	rows := scan(txnsnapshot.ScanOrderCompletion)
+	sort.Ints(rows)
+	s.Equal(expected, rows)
+
+	// The error returned by fn stops the scan.
+	stop := errors.New("stop")
+	count := 0
+	err := snapshot.ParallelScan(context.Background(), start, end, txnsnapshot.ParallelScanOptions{Concurrency: 3},
+		func(key, value []byte) error {
+			count++
+			if count == 10 {
+				return stop
+			}
+			return nil
+		})
+	s.ErrorIs(err, stop)
+	s.Equal(10, count)
+}
+
+func (s *testScanSuite) TestScanRanges() {
+	prefix := []byte("ranges")
+	makeKey := func(i int) []byte {
+		return append(append([]byte(nil), prefix...), fmt.Sprintf("%10d", i)...)
+	}
+	const rowNum = 500
+	txn := s.beginTxn()
+	for i := 0; i < rowNum; i++ {
+		s.Require().Nil(txn.Set(makeKey(i), s.makeValue(i)))
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+	mockTableID := int64(999)
+	for _, i := range []int{100, 250, 400} {
+		_, err := s.store.SplitRegions(context.Background(), [][]byte{makeKey(i)}, false, &mockTableID)
+		s.Require().Nil(err)
+	}
+
+	// A key of the ranges is locked by a transaction committed after the snapshot ts.
+	txn1 := s.beginTxn()
+	s.Require().Nil(txn1.Set(makeKey(300), s.makeValue(-1)))
+	committer, err := txn1.NewCommitter(1)
+	s.Require().Nil(err)
+	s.Require().Nil(committer.PrewriteAllMutations(context.Background()))
+	snapTxn := s.beginTxn()
+	snapshot := snapTxn.GetSnapshot()
+	committer.SetCommitTS(snapTxn.StartTS() + 1)
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		time.Sleep(100 * time.Millisecond)
+		s.Nil(committer.CommitMutations(context.Background()))
+	}()
+	defer func() { <-committed }()
+
+	ranges := []kv.KeyRange{
+		{StartKey: makeKey(350), EndKey: makeKey(450)},
+		{StartKey: makeKey(10), EndKey: makeKey(20)},
+		{StartKey: makeKey(90), EndKey: makeKey(350)},
+		{StartKey: makeKey(60), EndKey: makeKey(60)},
+	}
+	scan := func(order txnsnapshot.ScanOrder) []int {
+		var rows []int
+		err := snapshot.ScanRanges(context.Background(), ranges, txnsnapshot.ParallelScanOptions{Concurrency: 2, Order: order},
+			func(key, value []byte) error {
+				i, err := strconv.Atoi(string(value))
+				s.Require().Nil(err)
+				s.Equal(makeKey(i), key)
+				rows = append(rows, i)
+				return nil
+			})
+		s.Require().Nil(err)
+		return rows
+	}
+	var expected []int
+	for i := 10; i < 20; i++ {
+		expected = append(expected, i)
+	}
+	for i := 90; i < 450; i++ {
+		expected = append(expected, i)
+	}
+	s.Equal(expected, scan(txnsnapshot.ScanOrderKey))
+	rows := scan(txnsnapshot.ScanOrderCompletion)
+	sort.Ints(rows)
+	s.Equal(expected, rows)
+
+	for _, overlapped := range [][]kv.KeyRange{
+		{{StartKey: makeKey(10), EndKey: makeKey(20)}, {StartKey: makeKey(15), EndKey: makeKey(30)}},
+		{{StartKey: makeKey(10)}, {StartKey: makeKey(100), EndKey: makeKey(200)}},
+	} {
+		err = snapshot.ScanRanges(context.Background(), overlapped, txnsnapshot.ParallelScanOptions{},
+			func(key, value []byte) error {
+				s.Fail("overlapped ranges are scanned")
+				return nil
+			})
+		s.Error(err)
+	}
+}
+
+func (s *testScanSuite) TestScanBufferReuse() {
+	prefix := []byte("reuse")
+	makeKey := func(i int) []byte {
+		return append(append([]byte(nil), prefix...), fmt.Sprintf("%10d", i)...)
+	}
+	const rowNum = 100
+	txn := s.beginTxn()
+	for i := 0; i < rowNum; i++ {
+		s.Require().Nil(txn.Set(makeKey(i), s.makeValue(i)))
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+
+	snapshot := s.beginTxn().GetSnapshot()
+	snapshot.SetScanBatchSize(10)
+	snapshot.SetScanBufferReuse(true)
+	for _, reverse := range []bool{false, true} {
+		var (
+			it  tikv.Iterator
+			err error
+		)
+		if reverse {
+			it, err = snapshot.IterReverse(makeKey(rowNum), makeKey(0))
+		} else {
+			it, err = snapshot.Iter(makeKey(0), makeKey(rowNum))
+		}
+		s.Require().Nil(err)
+		scanner := it.(*txnsnapshot.Scanner)
+		var (
+			keys, values [][]byte
+			second       []byte
+		)
+		for scanner.Valid() {
+			if len(keys) == 10 {
+				second = scanner.Key()
+			}
+			keys = append(keys, scanner.KeyCopy(nil))
+			values = append(values, scanner.ValueCopy(nil))
+			s.Require().Nil(scanner.Next())
+		}
+		s.Require().Len(keys, rowNum)
+		for i := range keys {
+			row := i
+			if reverse {
+				row = rowNum - 1 - i
+			}
+			s.Equal(makeKey(row), keys[i])
+			s.Equal(s.makeValue(row), values[i])
+		}
+		// The buffer of the second batch is overwritten by the following ones.
+		s.NotEqual(keys[10], second)
+	}
+}
+
+func (s *testScanSuite) TestScanFilter() {
+	makeKey := func(group string, i int) []byte {
+		return []byte(fmt.Sprintf("filter/%s%03d", group, i))
+	}
+	const rowNum = 30
+	txn := s.beginTxn()
+	for _, group := range []string{"a", "b"} {
+		for i := 0; i < rowNum; i++ {
+			s.Require().Nil(txn.Set(makeKey(group, i), s.makeValue(i)))
+		}
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+	version := txn.CommitTS()
+	txn = s.beginTxn()
+	// The updated keys don't match the regex.
+	s.Require().Nil(txn.Set(makeKey("a", 1), []byte("updated")))
+	s.Require().Nil(txn.Delete(makeKey("a", 3)))
+	s.Require().Nil(txn.Commit(context.Background()))
+
+	snapshot := s.beginTxn().GetSnapshot()
+	snapshot.SetScanBatchSize(4)
+	snapshot.SetScanFilter(&txnsnapshot.ScanFilter{
+		KeyPrefix:   []byte("filter/a"),
+		KeyRegex:    regexp.MustCompile(`[02468]$`),
+		MinValueLen: 2,
+	})
+	var expected [][]byte
+	for i := 10; i < rowNum; i += 2 {
+		expected = append(expected, makeKey("a", i))
+	}
+	collect := func(it tikv.Iterator, err error) [][]byte {
+		s.Require().Nil(err)
+		var keys [][]byte
+		for it.Valid() {
+			keys = append(keys, it.Key())
+			s.Require().Nil(it.Next())
+		}
+		return keys
+	}
+	s.Equal(expected, collect(snapshot.Iter([]byte("filter"), nil)))
+	keys := collect(snapshot.IterReverse(nil, nil))
+	slices.Reverse(keys)
+	s.Equal(expected, keys)
+	s.Equal(expected[2:], collect(snapshot.Iter(makeKey("a", 14), makeKey("b", 0))))
+	s.Empty(collect(snapshot.Iter(makeKey("b", 0), nil)))
+
+	// The version constraint is evaluated by TiKV.
+	snapshot.SetScanFilter(&txnsnapshot.ScanFilter{KeyPrefix: []byte("filter/a"), Version: version})
+	it, err := snapshot.Iter(makeKey("a", 1), nil)
+	s.Require().Nil(err)
+	s.Equal(s.makeValue(1), it.Value())
+	s.Require().Nil(it.Next())
+	s.Require().Nil(it.Next())
+	s.Equal(makeKey("a", 3), it.Key())
+	it.Close()
+	snapshot.SetScanFilter(&txnsnapshot.ScanFilter{KeyPrefix: []byte("filter/a")})
+	it, err = snapshot.Iter(makeKey("a", 1), nil)
+	s.Require().Nil(err)
+	s.Equal([]byte("updated"), it.Value())
+	s.Require().Nil(it.Next())
+	s.Require().Nil(it.Next())
+	s.Equal(makeKey("a", 4), it.Key())
+	it.Close()
+	snapshot.SetScanFilter(&txnsnapshot.ScanFilter{Version: math.MaxUint64})
+	_, err = snapshot.Iter(nil, nil)
+	s.ErrorContains(err, "greater than the snapshot version")
+
+	snapshot.SetScanFilter(&txnsnapshot.ScanFilter{MaxValueLen: 1})
+	s.Len(collect(snapshot.Iter(makeKey("b", 0), []byte("filter/c"))), 10)
+	snapshot.SetKeyOnly(true)
+	_, err = snapshot.Iter(makeKey("b", 0), nil)
+	s.ErrorContains(err, "key-only")
+}
@@ -47,11 +47,13 @@ import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
@@ -241,6 +243,60 @@ func (s *testSnapshotSuite) TestSkipLargeTxnLock() {
 	s.Greater(status.CommitTS(), txn1.StartTS())
 }
 
+func (s *testSnapshotSuite) TestForEachLockResolveBudget() {
+	ctx := context.Background()
+	keys := make([][]byte, 0, 5)
+	txn := s.beginTxn()
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		key := encodeKey(s.prefix, "foreach_"+k)
+		keys = append(keys, key)
+		s.Nil(txn.Set(key, []byte(k)))
+	}
+	s.Nil(txn.Commit(ctx))
+
+	// Leave expired locks on b, c and d.
+	txn1 := s.beginTxn()
+	for _, key := range keys[1:4] {
+		s.Nil(txn1.Set(key, []byte("locked")))
+	}
+	committer, err := txn1.NewCommitter(1)
+	s.Nil(err)
+	committer.SetLockTTL(1)
+	s.Nil(committer.PrewriteAllMutations(ctx))
+	time.Sleep(50 * time.Millisecond)
+
+	snapshot := s.beginTxn().GetSnapshot()
+	snapshot.SetLockResolveBudget(txnsnapshot.LockResolveBudget{MaxLocks: 1})
+	var values []string
+	collect := func(key, value []byte) error {
+		values = append(values, string(value))
+		return nil
+	}
+	end := encodeKey(s.prefix, "foreach_z")
+	err = snapshot.ForEach(keys[0], end, collect)
+	var budgetErr *tikverr.ErrLockResolveBudgetExceeded
+	s.Require().ErrorAs(err, &budgetErr)
+	s.Equal(1, budgetErr.ResolvedLocks)
+	s.Equal(keys[2], budgetErr.ResumeKey)
+	s.Equal([]string{"a", "b"}, values)
+
+	// Each call resolves one more lock, resume from the key until all the locks are resolved.
+	s.Require().ErrorAs(snapshot.ForEach(budgetErr.ResumeKey, end, collect), &budgetErr)
+	s.Equal(keys[3], budgetErr.ResumeKey)
+	s.Nil(snapshot.ForEach(budgetErr.ResumeKey, end, collect))
+	s.Equal([]string{"a", "b", "c", "d", "e"}, values)
+
+	// The error returned by fn stops the iteration.
+	stop := errors.New("stop")
+	values = nil
+	err = snapshot.ForEach(keys[0], end, func(key, value []byte) error {
+		values = append(values, string(value))
+		return stop
+	})
+	s.ErrorIs(err, stop)
+	s.Equal([]string{"a"}, values)
+}
+
 func (s *testSnapshotSuite) TestPointGetSkipTxnLock() {
 	x := []byte("x_key_TestPointGetSkipTxnLock")
 	y := []byte("y_key_TestPointGetSkipTxnLock")
@@ -404,6 +460,68 @@ func (s *testSnapshotSuite) TestRCRead() {
 	}
 }
 
+func (s *testSnapshotSuite) TestRefreshTS() {
+	key := encodeKey(s.prefix, "refresh")
+	txn := s.beginTxn()
+	s.Nil(txn.Set(key, []byte("v1")))
+	s.Nil(txn.Commit(context.Background()))
+
+	txn = s.beginTxn()
+	snapshot := txn.GetSnapshot()
+	oldTS := txn.StartTS()
+	v, err := snapshot.Get(context.Background(), key)
+	s.Nil(err)
+	s.Equal([]byte("v1"), v)
+
+	txn = s.beginTxn()
+	s.Nil(txn.Set(key, []byte("v2")))
+	s.Nil(txn.Commit(context.Background()))
+	s.Less(oldTS, txn.CommitTS())
+
+	// The value cached for the old ts is still read before refreshing.
+	v, err = snapshot.Get(context.Background(), key)
+	s.Nil(err)
+	s.Equal([]byte("v1"), v)
+
+	newTS, err := snapshot.RefreshTS(context.Background())
+	s.Nil(err)
+	s.Less(txn.CommitTS(), newTS)
+	v, err = snapshot.Get(context.Background(), key)
+	s.Nil(err)
+	s.Equal([]byte("v2"), v)
+}
+
+type scopeRecordingOracle struct {
+	oracle.Oracle
+	scopes []string
+}
+
+func (o *scopeRecordingOracle) GetTimestamp(ctx context.Context, opt *oracle.Option) (uint64, error) {
+	o.scopes = append(o.scopes, opt.TxnScope)
+	return o.Oracle.GetTimestamp(ctx, opt)
+}
+
+func (s *testSnapshotSuite) TestRefreshTSScope() {
+	o := &scopeRecordingOracle{Oracle: s.store.GetOracle()}
+	s.store.SetOracle(o)
+	defer s.store.SetOracle(o.Oracle)
+
+	snapshot := s.store.GetSnapshot(0)
+	_, err := snapshot.RefreshTS(context.Background())
+	s.Nil(err)
+	snapshot.SetReadReplicaScope("dc1")
+	_, err = snapshot.RefreshTS(context.Background())
+	s.Nil(err)
+	s.Equal([]string{oracle.GlobalTxnScope, "dc1"}, o.scopes)
+}
+
+func (s *testSnapshotSuite) TestRefreshTSStaleRead() {
+	snapshot := s.store.GetSnapshot(1)
+	snapshot.SetIsStalenessReadOnly(true)
+	_, err := snapshot.RefreshTS(context.Background())
+	s.NotNil(err)
+}
+
 func (s *testSnapshotSuite) TestSnapshotCacheBypassMaxUint64() {
 	txn := s.beginTxn()
 	s.Nil(txn.Set([]byte("x"), []byte("x")))
@@ -478,3 +596,206 @@ func (s *testSnapshotSuite) TestReplicaReadAdjuster() {
 		}
 	}
 }
+
+func TestSnapshotReplicaConsistencyCheck(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	storeIDs, _, _, _ := testutils.BootstrapWithMultiStores(cluster, 3)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	key := []byte("replica-check-key")
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set(key, []byte("v1")))
+	require.Nil(t, txn.Commit(context.Background()))
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+
+	for _, c := range []struct {
+		otherValue []byte
+		mismatch   bool
+	}{
+		{[]byte("v1"), false},
+		{[]byte("v2"), true},
+		{nil, true},
+	} {
+		var mismatches []txnsnapshot.ReplicaMismatch
+		snapshot := store.GetSnapshot(ts)
+		snapshot.SetReplicaConsistencyCheck(1, func(m txnsnapshot.ReplicaMismatch) {
+			mismatches = append(mismatches, m)
+		})
+		var verified int
+		snapshot.SetRPCInterceptor(interceptor.NewRPCInterceptor("fake-replica", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+			return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+				if req.Type == tikvrpc.CmdGet && req.ReplicaRead {
+					// The mock store doesn't serve follower reads.
+					verified++
+					return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: c.otherValue, NotFound: c.otherValue == nil}}, nil
+				}
+				return next(target, req)
+			}
+		}))
+		val, err := snapshot.Get(context.Background(), key)
+		require.Nil(t, err)
+		require.Equal(t, []byte("v1"), val)
+		require.Equal(t, 1, verified)
+		if !c.mismatch {
+			require.Empty(t, mismatches)
+			continue
+		}
+		require.Len(t, mismatches, 1)
+		m := mismatches[0]
+		require.Equal(t, key, m.Key)
+		require.Equal(t, ts, m.TS)
+		require.Equal(t, storeIDs[0], m.StoreID)
+		require.NotEqual(t, storeIDs[0], m.OtherStoreID)
+		require.Equal(t, []byte("v1"), m.Value)
+		require.Equal(t, c.otherValue, m.OtherValue)
+	}
+}
+
+func (s *testSnapshotSuite) TestScanChecksum() {
+	txn := s.beginTxn()
+	for i := 0; i < 10; i++ {
+		s.Nil(txn.Set(encodeKey(s.prefix, s08d("key", i)), valueBytes(i)))
+	}
+	s.Nil(txn.Commit(context.Background()))
+
+	var mismatches []txnsnapshot.ScanChecksumMismatch
+	snapshot := s.store.GetSnapshot(math.MaxUint64)
+	snapshot.SetScanChecksum(func(m txnsnapshot.ScanChecksumMismatch) {
+		mismatches = append(mismatches, m)
+	})
+	scan := func() int {
+		iter, err := snapshot.Iter(encodeKey(s.prefix, ""), encodeKey(s.prefix, "z"))
+		s.Nil(err)
+		defer iter.Close()
+		cnt := 0
+		for ; iter.Valid(); s.Nil(iter.Next()) {
+			cnt++
+		}
+		return cnt
+	}
+	s.Equal(10, scan())
+	s.Equal(10, scan())
+	s.Empty(mismatches)
+
+	// Corrupt a value in the response of the scan.
+	snapshot.SetRPCInterceptor(interceptor.NewRPCInterceptor("corrupt-scan", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			resp, err := next(target, req)
+			if err == nil && req.Type == tikvrpc.CmdScan {
+				if pairs := resp.Resp.(*kvrpcpb.ScanResponse).Pairs; len(pairs) > 0 {
+					pairs[0].Value = []byte("corrupted")
+				}
+			}
+			return resp, err
+		}
+	}))
+	s.Equal(10, scan())
+	s.Len(mismatches, 1)
+	s.Equal(10, mismatches[0].Pairs)
+	s.Equal(10, mismatches[0].PrevPairs)
+	s.NotEqual(mismatches[0].PrevChecksum, mismatches[0].Checksum)
+}
+
+func TestBatchGetWithPartialResult(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	_, regionIDs, _ := testutils.BootstrapWithMultiRegions(cluster, []byte("m"))
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("x"), []byte("y")}
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for _, key := range keys {
+		require.Nil(t, txn.Set(key, key))
+	}
+	require.Nil(t, txn.Commit(context.Background()))
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+
+	// The keys in the second region fail to be read.
+	snapshot := store.GetSnapshot(ts)
+	snapshot.SetRPCInterceptor(interceptor.NewRPCInterceptor("fail-region", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			if req.Type == tikvrpc.CmdBatchGet && req.RegionId == regionIDs[1] {
+				return &tikvrpc.Response{Resp: &kvrpcpb.BatchGetResponse{Error: &kvrpcpb.KeyError{Abort: "region is down"}}}, nil
+			}
+			return next(target, req)
+		}
+	}))
+	m, failures, err := snapshot.BatchGetWithPartialResult(context.Background(), keys)
+	require.Nil(t, err)
+	require.Equal(t, map[string][]byte{"a": []byte("a"), "b": []byte("b")}, m)
+	require.Len(t, failures, 1)
+	require.Equal(t, regionIDs[1], failures[0].RegionID)
+	require.Equal(t, []byte("m"), failures[0].StartKey)
+	require.Equal(t, [][]byte{[]byte("x"), []byte("y")}, failures[0].Keys)
+	require.ErrorContains(t, failures[0].Err, "region is down")
+
+	// The failed keys are not cached, so they are read again.
+	snapshot.SetRPCInterceptor(nil)
+	m, failures, err = snapshot.BatchGetWithPartialResult(context.Background(), append(keys, []byte("z")))
+	require.Nil(t, err)
+	require.Empty(t, failures)
+	require.Len(t, m, 4)
+	require.Equal(t, []byte("y"), m["y"])
+}
+
+func TestBatchExists(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	testutils.BootstrapWithMultiRegions(cluster, []byte("m"))
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	for _, key := range []string{"a", "c", "x"} {
+		require.Nil(t, txn.Set([]byte(key), []byte(key)))
+	}
+	require.Nil(t, txn.Commit(context.Background()))
+	ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+	require.Nil(t, err)
+
+	snapshot := store.GetSnapshot(ts)
+	// The cached keys are not read again.
+	_, err = snapshot.BatchGet(context.Background(), [][]byte{[]byte("a"), []byte("b")})
+	require.Nil(t, err)
+	// A key may be read more than once if it's locked by the secondaries being committed.
+	var mu sync.Mutex
+	readKeys := make(map[string]struct{})
+	snapshot.SetRPCInterceptor(interceptor.NewRPCInterceptor("record-keys", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			if req.Type == tikvrpc.CmdBatchGet {
+				// The regions are read concurrently.
+				mu.Lock()
+				for _, k := range req.BatchGet().Keys {
+					readKeys[string(k)] = struct{}{}
+				}
+				mu.Unlock()
+			}
+			return next(target, req)
+		}
+	}))
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("x"), []byte("y"), []byte("c")}
+	bitmap, err := snapshot.BatchExists(context.Background(), keys)
+	require.Nil(t, err)
+	exists := make([]bool, len(keys))
+	for i := range keys {
+		exists[i] = bitmap.Exists(i)
+	}
+	require.Equal(t, []bool{true, false, true, true, false, true}, exists)
+	require.Equal(t, 4, bitmap.Count())
+	require.False(t, bitmap.Exists(len(keys)))
+	require.Equal(t, map[string]struct{}{"c": {}, "x": {}, "y": {}}, readKeys)
+	// The values are not cached.
+	require.Equal(t, 2, len(snapshot.SnapCache()))
+}
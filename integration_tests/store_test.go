@@ -36,15 +36,22 @@ package tikv_test
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/oracle/oracles"
+	"github.com/tikv/client-go/v2/testutils"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv"
@@ -192,3 +199,79 @@ func (s *testStoreSuite) TestFailBusyServerKV() {
 	s.Nil(err)
 	s.Equal(val, []byte("value"))
 }
+
+func TestChaosSoak(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.NoError(t, err)
+	testutils.BootstrapWithMultiStores(cluster, 3)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.NoError(t, err)
+	defer store.Close()
+	tikv.StoreProbe{KVStore: store}.SetMockStoreLiveness(testutils.MockStoreLiveness(cluster))
+
+	chaos := testutils.NewChaosScheduler(cluster, testutils.ChaosConfig{
+		Seed:     1,
+		Interval: 5 * time.Millisecond,
+		SplitKey: func(r *rand.Rand) []byte { return []byte(fmt.Sprintf("chaos%d-%d", r.Intn(4), r.Intn(10))) },
+	})
+	chaos.Start()
+	invariants := testutils.NewChaosInvariants()
+
+	const workers = 4
+	var (
+		wg        sync.WaitGroup
+		committed atomic.Int64
+	)
+	deadline := time.Now().Add(time.Second)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// A key whose commit result is undetermined isn't checked anymore.
+			undetermined := make(map[string]struct{})
+			for i := 0; time.Now().Before(deadline); i++ {
+				key := []byte(fmt.Sprintf("chaos%d-%d", w, i%10))
+				txn, err := store.Begin()
+				if !assert.NoError(t, err) {
+					return
+				}
+				value := []byte(fmt.Sprintf("v%d", i))
+				assert.NoError(t, txn.Set(key, value))
+				if err = txn.Commit(context.Background()); err != nil {
+					undetermined[string(key)] = struct{}{}
+					continue
+				}
+				invariants.RecordCommit(key, value, txn.CommitTS())
+				committed.Add(1)
+
+				ts, err := store.CurrentTimestamp(oracle.GlobalTxnScope)
+				if !assert.NoError(t, err) {
+					return
+				}
+				readKey := []byte(fmt.Sprintf("chaos%d-%d", w, (i*7)%10))
+				if _, ok := undetermined[string(readKey)]; ok {
+					continue
+				}
+				val, err := store.GetSnapshot(ts).Get(context.Background(), readKey)
+				if tikverr.IsErrNotFound(err) {
+					val, err = nil, nil
+				}
+				if err == nil {
+					assert.NoError(t, invariants.CheckRead(readKey, val, ts))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	chaos.Stop()
+
+	applied := make(map[testutils.ChaosOp]int)
+	for _, e := range chaos.Events() {
+		if e.Applied {
+			applied[e.Op]++
+		}
+	}
+	require.Len(t, applied, 4)
+	require.Positive(t, committed.Load())
+	t.Logf("committed %d txns under chaos %v", committed.Load(), applied)
+}
@@ -35,6 +35,7 @@
 package tikv_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -46,6 +47,8 @@ import (
 	kverr "github.com/tikv/client-go/v2/error"
 	tikvstore "github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/chunked"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
 )
 
 func TestTiclient(t *testing.T) {
@@ -156,6 +159,133 @@ func (s *testTiclientSuite) TestLargeRequest() {
 	s.False(errors.As(err, &retryableErr))
 }
 
+func (s *testTiclientSuite) TestWriteSizeLimits() {
+	txn := s.beginTxn()
+	s.NotNil(txn.SetWriteSizeLimits(transaction.WriteSizeLimits{MaxValueSize: 8, ChunkSize: 16}))
+	s.Nil(txn.SetWriteSizeLimits(transaction.WriteSizeLimits{MaxKeySize: 64, MaxValueSize: 8}))
+	var keyErr *kverr.ErrKeyTooLarge
+	s.ErrorAs(txn.Set(bytes.Repeat([]byte("k"), 65), []byte("v")), &keyErr)
+	s.Equal(64, keyErr.Limit)
+	var valueErr *kverr.ErrValueTooLarge
+	s.ErrorAs(txn.Set(encodeKey(s.prefix, "large"), []byte("123456789")), &valueErr)
+	s.Equal(9, valueErr.Size)
+	s.Nil(txn.Set(encodeKey(s.prefix, "small"), []byte("12345678")))
+	s.Nil(txn.Rollback())
+
+	// The large values are chunked and reassembled.
+	chunkedKey, plainKey := encodeKey(s.prefix, "chunked"), encodeKey(s.prefix, "chunked_plain")
+	largeValue := bytes.Repeat([]byte("0123456789"), 5)
+	txn = s.beginTxn()
+	s.Nil(txn.SetWriteSizeLimits(transaction.WriteSizeLimits{MaxKeySize: 64, MaxValueSize: 16, ChunkSize: 16}))
+	// The chunk keys are longer than the key.
+	s.ErrorAs(txn.Set(bytes.Repeat([]byte("k"), 60), largeValue), &keyErr)
+	s.Nil(txn.Set(chunkedKey, largeValue))
+	s.Nil(txn.Set(plainKey, []byte("plain")))
+	val, err := txn.Get(context.Background(), chunkedKey)
+	s.Nil(err)
+	s.Equal(largeValue, val)
+	s.Nil(txn.Commit(context.Background()))
+
+	txn = s.beginTxn()
+	s.Nil(txn.SetWriteSizeLimits(transaction.WriteSizeLimits{MaxValueSize: 16, ChunkSize: 16}))
+	m, err := txn.BatchGet(context.Background(), [][]byte{chunkedKey, plainKey})
+	s.Nil(err)
+	s.Equal(map[string][]byte{string(chunkedKey): largeValue, string(plainKey): []byte("plain")}, m)
+	// The iterator skips the chunks and reassembles the values.
+	it, err := txn.Iter(chunkedKey, append(plainKey, 0))
+	s.Nil(err)
+	var keys, values [][]byte
+	for ; it.Valid(); s.Nil(it.Next()) {
+		keys = append(keys, it.Key())
+		values = append(values, it.Value())
+	}
+	it.Close()
+	s.Equal([][]byte{chunkedKey, plainKey}, keys)
+	s.Equal([][]byte{largeValue, []byte("plain")}, values)
+	// The chunks of the values chunked by the transaction itself are deleted when they're overwritten.
+	s.Nil(txn.Set(chunkedKey, largeValue))
+	s.Nil(txn.Set(chunkedKey, largeValue[:20]))
+	s.Nil(txn.Set(plainKey, largeValue))
+	s.Nil(txn.Delete(plainKey))
+	s.Nil(txn.Commit(context.Background()))
+
+	// The manifest is read as is if chunking is disabled.
+	txn = s.beginTxn()
+	val, err = txn.Get(context.Background(), chunkedKey)
+	s.Nil(err)
+	s.NotEqual(largeValue[:20], val)
+	_, err = txn.Get(context.Background(), chunked.ChunkKey(chunkedKey, 1))
+	s.Nil(err)
+	for _, k := range [][]byte{chunked.ChunkKey(chunkedKey, 2), plainKey, chunked.ChunkKey(plainKey, 0)} {
+		_, err = txn.Get(context.Background(), k)
+		s.True(kverr.IsErrNotFound(err))
+	}
+
+	// The writes bypassing Set are checked by Commit.
+	s.Nil(txn.SetWriteSizeLimits(transaction.WriteSizeLimits{MaxValueSize: 16}))
+	s.Nil(txn.GetMemBuffer().Set(plainKey, largeValue))
+	s.ErrorAs(txn.Commit(context.Background()), &valueErr)
+}
+
+type xorTransformer byte
+
+func (t xorTransformer) ID() byte { return byte(t) }
+
+func (t xorTransformer) Transform(_, value []byte) ([]byte, error) {
+	res := make([]byte, len(value))
+	for i, b := range value {
+		res[i] = b ^ byte(t)
+	}
+	return res, nil
+}
+
+func (t xorTransformer) Restore(key, value []byte) ([]byte, error) {
+	return t.Transform(key, value)
+}
+
+func (s *testTiclientSuite) TestValueTransformers() {
+	transformers, err := tikvstore.NewValueTransformers(xorTransformer(1))
+	s.Nil(err)
+	k1, k2 := encodeKey(s.prefix, "vt1"), encodeKey(s.prefix, "vt2")
+
+	txn := s.beginTxn()
+	txn.SetValueTransformers(transformers)
+	s.Nil(txn.Set(k1, []byte("v1")))
+	s.Nil(txn.Set(k2, []byte("v2")))
+	val, err := txn.Get(context.Background(), k1)
+	s.Nil(err)
+	s.Equal([]byte("v1"), val)
+	s.Nil(txn.Commit(context.Background()))
+
+	txn = s.beginTxn()
+	txn.SetValueTransformers(transformers)
+	m, err := txn.BatchGet(context.Background(), [][]byte{k1, k2})
+	s.Nil(err)
+	s.Equal(map[string][]byte{string(k1): []byte("v1"), string(k2): []byte("v2")}, m)
+	it, err := txn.Iter(k1, encodeKey(s.prefix, "vt3"))
+	s.Nil(err)
+	s.True(it.Valid())
+	s.Equal([]byte("v1"), it.Value())
+	s.Nil(it.Next())
+	s.Equal([]byte("v2"), it.Value())
+	it.Close()
+
+	snapshot := s.store.GetSnapshot(txn.StartTS())
+	snapshot.SetValueTransformers(transformers)
+	val, err = snapshot.Get(context.Background(), k2)
+	s.Nil(err)
+	s.Equal([]byte("v2"), val)
+	m, err = snapshot.BatchGet(context.Background(), [][]byte{k1, k2})
+	s.Nil(err)
+	s.Equal(map[string][]byte{string(k1): []byte("v1"), string(k2): []byte("v2")}, m)
+
+	// The values are stored transformed.
+	txn = s.beginTxn()
+	val, err = txn.Get(context.Background(), k1)
+	s.Nil(err)
+	s.NotEqual([]byte("v1"), val)
+}
+
 func (s *testTiclientSuite) TestSplitRegionIn2PC() {
 	if *withTiKV {
 		s.T().Skip("scatter will timeout with single node TiKV")
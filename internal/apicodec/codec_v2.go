@@ -266,6 +266,14 @@ func (c *codecV2) EncodeRequest(req *tikvrpc.Request) (*tikvrpc.Request, error)
 		r := *req.UnsafeDestroyRange()
 		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
 		req.Req = &r
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := *req.PrepareFlashbackToVersion()
+		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
+		req.Req = &r
+	case tikvrpc.CmdFlashbackToVersion:
+		r := *req.FlashbackToVersion()
+		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
+		req.Req = &r
 	case tikvrpc.CmdPhysicalScanLock:
 		r := *req.PhysicalScanLock()
 		r.StartKey = c.EncodeKey(r.StartKey)
@@ -568,6 +576,18 @@ func (c *codecV2) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 		if err != nil {
 			return nil, err
 		}
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := resp.Resp.(*kvrpcpb.PrepareFlashbackToVersionResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
+	case tikvrpc.CmdFlashbackToVersion:
+		r := resp.Resp.(*kvrpcpb.FlashbackToVersionResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
 	case tikvrpc.CmdPhysicalScanLock:
 		r := resp.Resp.(*kvrpcpb.PhysicalScanLockResponse)
 		r.Locks, err = c.decodeLockInfos(r.Locks)
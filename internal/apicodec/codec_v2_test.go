@@ -1,6 +1,7 @@
 package apicodec
 
 import (
+	"bytes"
 	"math"
 	"testing"
 
@@ -100,6 +101,33 @@ func (suite *testCodecV2Suite) TestEncodeRequest() {
 				re.Equal(append(keyspacePrefix, []byte("key1")...), encoded.Commit().PrimaryKey)
 			},
 		},
+		{
+			name: "CmdFlashbackToVersion",
+			req: &tikvrpc.Request{
+				Type: tikvrpc.CmdFlashbackToVersion,
+				Req: &kvrpcpb.FlashbackToVersionRequest{
+					StartKey: []byte("a"),
+				},
+			},
+			validate: func(encoded *tikvrpc.Request) {
+				re.Equal(append(keyspacePrefix, 'a'), encoded.FlashbackToVersion().StartKey)
+				re.Equal(keyspaceEndKey, encoded.FlashbackToVersion().EndKey)
+			},
+		},
+		{
+			name: "CmdPrepareFlashbackToVersion",
+			req: &tikvrpc.Request{
+				Type: tikvrpc.CmdPrepareFlashbackToVersion,
+				Req: &kvrpcpb.PrepareFlashbackToVersionRequest{
+					StartKey: []byte("a"),
+					EndKey:   []byte("z"),
+				},
+			},
+			validate: func(encoded *tikvrpc.Request) {
+				re.Equal(append(keyspacePrefix, 'a'), encoded.PrepareFlashbackToVersion().StartKey)
+				re.Equal(append(keyspacePrefix, 'z'), encoded.PrepareFlashbackToVersion().EndKey)
+			},
+		},
 	}
 
 	for _, req := range requests {
@@ -154,6 +182,41 @@ func (suite *testCodecV2Suite) TestEncodeV2KeyRanges() {
 	re.Equal(expect, encodedKeyRanges)
 }
 
+func (suite *testCodecV2Suite) TestDecodeRange() {
+	re := suite.Require()
+	otherPrefix := []byte{'r', 0, 0, 1}
+	testCases := []struct {
+		encodedStart, encodedEnd []byte
+		start, end               []byte
+		err                      bool
+	}{
+		{keyspacePrefix, keyspaceEndKey, []byte{}, []byte{}, false},
+		{append(keyspacePrefix, 'a'), append(keyspacePrefix, 'z'), []byte("a"), []byte("z"), false},
+		// Ranges crossing the keyspace boundaries are clamped to the keyspace.
+		{otherPrefix, append(keyspacePrefix, 'z'), []byte{}, []byte("z"), false},
+		{append(keyspacePrefix, 'a'), []byte{}, []byte("a"), []byte{}, false},
+		{[]byte{}, append(keyspaceEndKey, 'a'), []byte{}, []byte{}, false},
+		// Ranges outside the keyspace.
+		{otherPrefix, keyspacePrefix, nil, nil, true},
+		{keyspaceEndKey, []byte{}, nil, nil, true},
+	}
+	for _, tc := range testCases {
+		start, end, err := suite.codec.DecodeRange(tc.encodedStart, tc.encodedEnd)
+		if tc.err {
+			re.Error(err)
+			continue
+		}
+		re.NoError(err)
+		re.Equal(tc.start, start)
+		re.Equal(tc.end, end)
+
+		// Encoding the decoded range gets the part of the range inside the keyspace.
+		encodedStart, encodedEnd := suite.codec.EncodeRange(start, end)
+		re.True(bytes.Compare(encodedStart, keyspacePrefix) >= 0)
+		re.True(bytes.Compare(encodedEnd, keyspaceEndKey) <= 0)
+	}
+}
+
 func (suite *testCodecV2Suite) TestNewCodecV2() {
 	re := suite.Require()
 	testCases := []struct {
@@ -322,6 +385,37 @@ func (suite *testCodecV2Suite) TestDecodeEpochNotMatch() {
 	}
 }
 
+func (suite *testCodecV2Suite) TestDecodeFlashbackResponse() {
+	re := suite.Require()
+	codec := suite.codec
+	newRegionErr := func() *errorpb.Error {
+		return &errorpb.Error{
+			EpochNotMatch: &errorpb.EpochNotMatch{
+				CurrentRegions: []*metapb.Region{{
+					Id:       1,
+					StartKey: codec.memCodec.encodeKey(append(keyspacePrefix, 'a')),
+					EndKey:   codec.memCodec.encodeKey(append(keyspacePrefix, 'z')),
+				}},
+			},
+		}
+	}
+	expected := &metapb.Region{Id: 1, StartKey: []byte("a"), EndKey: []byte("z")}
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdPrepareFlashbackToVersion, &kvrpcpb.PrepareFlashbackToVersionRequest{})
+	resp, err := codec.DecodeResponse(req, &tikvrpc.Response{
+		Resp: &kvrpcpb.PrepareFlashbackToVersionResponse{RegionError: newRegionErr()},
+	})
+	re.NoError(err)
+	re.Equal(expected, resp.Resp.(*kvrpcpb.PrepareFlashbackToVersionResponse).RegionError.EpochNotMatch.CurrentRegions[0])
+
+	req = tikvrpc.NewRequest(tikvrpc.CmdFlashbackToVersion, &kvrpcpb.FlashbackToVersionRequest{})
+	resp, err = codec.DecodeResponse(req, &tikvrpc.Response{
+		Resp: &kvrpcpb.FlashbackToVersionResponse{RegionError: newRegionErr()},
+	})
+	re.NoError(err)
+	re.Equal(expected, resp.Resp.(*kvrpcpb.FlashbackToVersionResponse).RegionError.EpochNotMatch.CurrentRegions[0])
+}
+
 func (suite *testCodecV2Suite) TestDecodeKeyError() {
 	re := suite.Require()
 	errors := []struct {
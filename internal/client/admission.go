@@ -0,0 +1,86 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// AdmissionInfo is the information of a batch commands request to be admitted.
+type AdmissionInfo struct {
+	Type tikvrpc.CmdType
+	// Priority is the priority of the request in the batch queue, higher values are sent first.
+	Priority uint64
+	Addr     string
+	StoreID  uint64
+	// QueueDepth is the number of requests waiting in the batch queue of the store.
+	QueueDepth int
+}
+
+// AdmissionController decides whether and when a request is put into the batch commands queue of a store.
+type AdmissionController interface {
+	// Admit is called before the request is put into the queue. It may block to delay the request, which also blocks
+	// the caller of SendRequestAsync. It returns the priority of the request in the queue, or an error to reject it,
+	// in which case the request fails with ErrRequestRejected without being retried.
+	Admit(ctx context.Context, info AdmissionInfo) (priority uint64, err error)
+}
+
+// AdmissionFeedback can be implemented by an AdmissionController to learn the results of the admitted requests, e.g.
+// to adapt the admission to the latency of the stores.
+type AdmissionFeedback interface {
+	// Done is called when an admitted request finishes, with the latency since it's admitted and the error of the
+	// request.
+	Done(ctx context.Context, info AdmissionInfo, latency time.Duration, err error)
+}
+
+// WithAdmissionController is used to set the admission controller of the batch commands requests.
+func WithAdmissionController(controller AdmissionController) Opt {
+	return func(c *option) {
+		c.admission = controller
+	}
+}
+
+// admit asks the admission controller, if any, whether the request can be put into the queue of batchConn and
+// returns its priority. The returned done, if not nil, must be called with the error of the request when it finishes.
+func (c *RPCClient) admit(
+	ctx context.Context, addr string, batchConn *batchConn, req *tikvrpc.Request, priority uint64,
+) (_ uint64, done func(error), _ error) {
+	if c.option == nil || c.option.admission == nil {
+		return priority, nil, nil
+	}
+	info := AdmissionInfo{
+		Type:       req.Type,
+		Priority:   priority,
+		Addr:       addr,
+		StoreID:    req.Context.GetPeer().GetStoreId(),
+		QueueDepth: len(batchConn.batchCommandsCh),
+	}
+	priority, err := c.option.admission.Admit(ctx, info)
+	if err != nil {
+		return 0, nil, errors.WithStack(&tikverr.ErrRequestRejected{Addr: addr, Cause: err})
+	}
+	if feedback, ok := c.option.admission.(AdmissionFeedback); ok {
+		start := time.Now()
+		done = func(err error) {
+			feedback.Done(ctx, info, time.Since(start), err)
+		}
+	}
+	return priority, done, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// adaptiveBatchSizeInterval is the min interval between two adjustments of the adaptive max batch size.
+	adaptiveBatchSizeInterval = 100 * time.Millisecond
+	// adaptiveBatchSizeSamples is the max number of the latencies of each kind kept for an adjustment.
+	adaptiveBatchSizeSamples = 512
+	// adaptiveBatchSizePercentile is the percentile of the latencies compared with the thresholds.
+	adaptiveBatchSizePercentile = 0.99
+)
+
+// latencySamples keeps the latest latencies observed since the last adjustment.
+type latencySamples struct {
+	lats []time.Duration
+	next int
+}
+
+func (s *latencySamples) observe(d time.Duration) {
+	if len(s.lats) < adaptiveBatchSizeSamples {
+		s.lats = append(s.lats, d)
+		return
+	}
+	s.lats[s.next] = d
+	s.next = (s.next + 1) % adaptiveBatchSizeSamples
+}
+
+// percentile returns the p-th percentile of the latencies, 0 if there is none.
+func (s *latencySamples) percentile(p float64) time.Duration {
+	if len(s.lats) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(s.lats)
+	slices.Sort(sorted)
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(idx, 0), len(sorted)-1)]
+}
+
+func (s *latencySamples) reset() {
+	s.lats, s.next = s.lats[:0], 0
+}
+
+// recvLatencies keeps the latencies of the responses received by a batchCommandsClient, so the clients of a
+// batchConn don't contend on a lock.
+type recvLatencies struct {
+	mu      sync.Mutex
+	samples latencySamples
+}
+
+// observe records the time from a request is sent to the batch conn to its response is received.
+func (l *recvLatencies) observe(d time.Duration) {
+	l.mu.Lock()
+	l.samples.observe(d)
+	l.mu.Unlock()
+}
+
+// takePercentile returns the p-th percentile of the latencies and resets them.
+func (l *recvLatencies) takePercentile(p float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lat := l.samples.percentile(p)
+	l.samples.reset()
+	return lat
+}
+
+// adaptiveBatchSize tunes the max batch size of a batchConn between the floor and config.TiKVClient.MaxBatchSize.
+// When the tail latency of the batches waiting to be sent or the requests waiting for the responses exceeds
+// config.TiKVClient.AdaptiveBatchSizeLatencyThreshold, e.g. the store is slow, the size is halved so a batch doesn't
+// block the requests behind it for long. Otherwise, it grows back gradually.
+type adaptiveBatchSize struct {
+	floor, ceiling int
+	threshold      time.Duration
+	// current is updated by the send loop and read by the stats.
+	current atomic.Int64
+	gauge   prometheus.Gauge
+
+	// sendLats and lastAdjust are only accessed by the send loop.
+	sendLats   latencySamples
+	lastAdjust time.Time
+	// recvLats are the latencies observed by each batchCommandsClient, which are registered before the send loop
+	// starts.
+	recvLats []*recvLatencies
+}
+
+// newAdaptiveBatchSize returns nil if the floor is 0 or not less than the ceiling, i.e. the max batch size is fixed.
+func newAdaptiveBatchSize(floor, ceiling uint, threshold time.Duration, gauge prometheus.Gauge) *adaptiveBatchSize {
+	if floor == 0 || floor >= ceiling {
+		return nil
+	}
+	s := &adaptiveBatchSize{floor: int(floor), ceiling: int(ceiling), threshold: threshold, gauge: gauge}
+	s.current.Store(int64(ceiling))
+	s.gauge.Set(float64(ceiling))
+	return s
+}
+
+// newRecvLatencies registers the latencies of the responses of a batchCommandsClient.
+func (s *adaptiveBatchSize) newRecvLatencies() *recvLatencies {
+	l := &recvLatencies{}
+	s.recvLats = append(s.recvLats, l)
+	return l
+}
+
+// get returns the current max batch size.
+func (s *adaptiveBatchSize) get() int {
+	return int(s.current.Load())
+}
+
+// observeSend records the time from the head request of a batch is fetched to the batch is sent.
+func (s *adaptiveBatchSize) observeSend(d time.Duration) {
+	s.sendLats.observe(d)
+}
+
+// adjust adjusts the max batch size by the latencies observed since the last adjustment if it's been
+// adaptiveBatchSizeInterval, and returns the max batch size.
+func (s *adaptiveBatchSize) adjust(now time.Time) int {
+	current := s.get()
+	if now.Sub(s.lastAdjust) < adaptiveBatchSizeInterval {
+		return current
+	}
+	s.lastAdjust = now
+	sendLat := s.sendLats.percentile(adaptiveBatchSizePercentile)
+	s.sendLats.reset()
+	var recvLat time.Duration
+	for _, l := range s.recvLats {
+		recvLat = max(recvLat, l.takePercentile(adaptiveBatchSizePercentile))
+	}
+
+	next := current
+	if sendLat > s.threshold || recvLat > s.threshold {
+		next = max(current/2, s.floor)
+	} else {
+		next = min(current+max(s.ceiling/16, 1), s.ceiling)
+	}
+	if next != current {
+		s.current.Store(int64(next))
+		s.gauge.Set(float64(next))
+	}
+	return next
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tikv/client-go/v2/config"
+)
+
+// batchIdlePoller detects the idle batchConns of the process in place of a timer per batchConn. The batchSendLoop of
+// a connection parks on its request channel and only wakes up for requests, so idle connections don't wake up their
+// goroutines at all. The poller sleeps until the earliest time a connection may become idle, so it wakes up at most
+// about once per idle timeout when all the connections are idle, no matter how many stores there are. It runs only
+// while there are connections to watch.
+type batchIdlePoller struct {
+	mu      sync.Mutex
+	conns   map[*batchConn]struct{}
+	running bool
+	// wake interrupts the sleep of the poller when a connection is registered or the last one is unregistered.
+	wake chan struct{}
+	// wakeups is the number of times the poller wakes up.
+	wakeups atomic.Uint64
+}
+
+var batchIdleDetector = newBatchIdlePoller()
+
+func newBatchIdlePoller() *batchIdlePoller {
+	return &batchIdlePoller{
+		conns: make(map[*batchConn]struct{}),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// register starts watching the connection, it's a no-op if the idle connections are not recycled.
+func (p *batchIdlePoller) register(a *batchConn) {
+	if a.idleTimeout <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns[a] = struct{}{}
+	if !p.running {
+		p.running = true
+		go p.run()
+		return
+	}
+	p.notify()
+}
+
+func (p *batchIdlePoller) unregister(a *batchConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, a)
+	if len(p.conns) == 0 && p.running {
+		// Let the poller exit instead of sleeping.
+		p.notify()
+	}
+}
+
+func (p *batchIdlePoller) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *batchIdlePoller) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-p.wake:
+			// The timer is reset below, which drops the value not received.
+			timer.Stop()
+		}
+		p.wakeups.Add(1)
+		next, ok := p.poll(time.Now())
+		if !ok {
+			return
+		}
+		timer.Reset(next)
+	}
+}
+
+// poll handles the connections idle at now, and returns how long to sleep before the next poll. It returns false and
+// stops the poller if there are no connections to watch.
+func (p *batchIdlePoller) poll(now time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	if len(p.conns) == 0 {
+		p.running = false
+		p.mu.Unlock()
+		return 0, false
+	}
+	conns := make([]*batchConn, 0, len(p.conns))
+	for a := range p.conns {
+		conns = append(conns, a)
+	}
+	p.mu.Unlock()
+
+	var next time.Duration
+	for _, a := range conns {
+		if d := a.checkIdle(now); d > 0 && (next == 0 || d < next) {
+			next = d
+		}
+	}
+	if next == 0 {
+		// All the connections are recycled and waiting to be closed.
+		next = config.DefBatchConnIdleTimeout
+	}
+	return next, true
+}
+
+// checkIdle recycles the connection if no request is fetched for the idle timeout, and returns how long it can stay
+// active at least, or 0 if it's recycled as a whole.
+func (a *batchConn) checkIdle(now time.Time) time.Duration {
+	if a.isIdle() {
+		return 0
+	}
+	lastActive := a.lastActive.Load()
+	if remain := a.idleTimeout - now.Sub(time.Unix(0, lastActive)); remain > 0 {
+		return remain
+	}
+	if a.idleRecycle == config.BatchConnIdleRecycleStream {
+		// Only the streams are closed, the connection keeps serving the later requests. The streams are recycled once
+		// until the connection becomes active again.
+		if a.idleHandled != lastActive {
+			a.idleHandled = lastActive
+			a.recycleIdleStreams()
+		}
+		return a.idleTimeout
+	}
+	atomic.AddUint32(&a.idle, 1)
+	atomic.CompareAndSwapUint32(a.idleNotify, 0, 1)
+	return 0
+}
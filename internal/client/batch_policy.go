@@ -0,0 +1,183 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+// BatchSchedulerPolicy decides how the send loop of a batch connection batches the requests, replacing the turbo batch
+// trigger of the preset policies. Each send loop creates its own policy, so the methods are called sequentially.
+type BatchSchedulerPolicy interface {
+	// TurboWaitTime returns the max time to wait for more requests before sending a batch, 0 disables the waiting.
+	TurboWaitTime() time.Duration
+	// NeedFetchMore returns whether to wait for more requests, given the arrival interval of the head request of the
+	// batch and its previous one.
+	NeedFetchMore(reqArrivalInterval time.Duration) bool
+	// PreferredBatchWaitSize returns the number of requests to wait for, given the average size of the recent batches
+	// that waited and config.TiKVClient.BatchWaitSize.
+	PreferredBatchWaitSize(avgBatchWaitSize float64, defBatchWaitSize int) int
+}
+
+var customBatchPolicies = struct {
+	sync.RWMutex
+	m map[string]func() BatchSchedulerPolicy
+}{m: make(map[string]func() BatchSchedulerPolicy)}
+
+// RegisterBatchSchedulerPolicy registers a batch policy named name, so it can be set as config.TiKVClient.BatchPolicy
+// or by SetBatchPolicy. newPolicy is called to create the policy of each batch connection switching to it. The name
+// can't be empty or conflict with the preset policies and the custom options.
+func RegisterBatchSchedulerPolicy(name string, newPolicy func() BatchSchedulerPolicy) error {
+	if newPolicy == nil {
+		return errors.Errorf("the batch policy %q has no constructor", name)
+	}
+	if _, ok := presetBatchPolicies[name]; ok {
+		return errors.Errorf("the batch policy %q is preset", name)
+	}
+	if trimmed := strings.TrimSpace(name); trimmed == "" || strings.HasPrefix(trimmed, config.BatchPolicyCustom) ||
+		strings.HasPrefix(trimmed, "{") {
+		return errors.Errorf("invalid batch policy name %q", name)
+	}
+	customBatchPolicies.Lock()
+	defer customBatchPolicies.Unlock()
+	if _, ok := customBatchPolicies.m[name]; ok {
+		return errors.Errorf("the batch policy %q is already registered", name)
+	}
+	customBatchPolicies.m[name] = newPolicy
+	return nil
+}
+
+// newBatchSchedulerPolicy creates the policy named policy, which is registered by RegisterBatchSchedulerPolicy or
+// parsed by newTurboBatchTriggerFromPolicy. It returns the default policy and false if the policy is invalid, or the
+// constructor of the registered policy panics or returns nil.
+func newBatchSchedulerPolicy(policy string) (BatchSchedulerPolicy, bool) {
+	customBatchPolicies.RLock()
+	newPolicy, ok := customBatchPolicies.m[policy]
+	customBatchPolicies.RUnlock()
+	if ok {
+		if p := newCustomBatchPolicy(policy, newPolicy); p != nil {
+			return p, true
+		}
+		trigger, _ := newTurboBatchTriggerFromPolicy(config.DefBatchPolicy)
+		return &trigger, false
+	}
+	trigger, ok := newTurboBatchTriggerFromPolicy(policy)
+	return &trigger, ok
+}
+
+// newCustomBatchPolicy calls the constructor of a registered policy, and returns nil if it panics.
+func newCustomBatchPolicy(policy string, newPolicy func() BatchSchedulerPolicy) (p BatchSchedulerPolicy) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.BgLogger().Error("failed to create batch policy", zap.String("policy", policy), zap.Any("r", r),
+				zap.Stack("stack"))
+			p = nil
+		}
+	}()
+	return newPolicy()
+}
+
+// ValidateBatchPolicy checks the batch policy, which is one of the preset policies, i.e. "basic", "standard" and
+// "positive", a policy registered by RegisterBatchSchedulerPolicy, or "custom" followed by the options of the turbo
+// batch trigger in JSON, e.g. `custom{"v":1,"t":0.0001,"n":5,"w":0.2,"p":0.8,"q":0.8}`.
+func ValidateBatchPolicy(policy string) error {
+	if _, ok := presetBatchPolicies[policy]; ok {
+		return nil
+	}
+	customBatchPolicies.RLock()
+	_, ok := customBatchPolicies.m[policy]
+	customBatchPolicies.RUnlock()
+	if ok {
+		return nil
+	}
+	// Like the config, the options can be set without the "custom" prefix.
+	rawOpts, _ := strings.CutPrefix(policy, config.BatchPolicyCustom)
+	var opts turboBatchOptions
+	dec := json.NewDecoder(strings.NewReader(strings.TrimSpace(rawOpts)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return errors.Wrapf(err, "invalid batch policy %q, it should be %s, %s, %s or %s followed by the options in JSON",
+			policy, config.BatchPolicyBasic, config.BatchPolicyStandard, config.BatchPolicyPositive, config.BatchPolicyCustom)
+	}
+	if dec.More() {
+		return errors.Errorf("invalid options of batch policy %q, unexpected data after the options", policy)
+	}
+	return opts.validate()
+}
+
+func (o *turboBatchOptions) validate() error {
+	if o.V < turboBatchAlways || o.V > turboBatchProbBased {
+		return errors.Errorf("invalid batch policy option v=%d, it should be %d, %d or %d",
+			o.V, turboBatchAlways, turboBatchTimeBased, turboBatchProbBased)
+	}
+	if o.T < 0 || o.N < 0 {
+		return errors.Errorf("invalid batch policy options t=%v n=%d, they should not be negative", o.T, o.N)
+	}
+	for _, opt := range []struct {
+		name  string
+		value float64
+	}{{"w", o.W}, {"p", o.P}, {"q", o.Q}} {
+		if opt.value < 0 || opt.value > 1 {
+			return errors.Errorf("invalid batch policy option %s=%v, it should be within [0, 1]", opt.name, opt.value)
+		}
+	}
+	return nil
+}
+
+// SetBatchPolicy updates the batch policy of the client, overriding config.TiKVClient.BatchPolicy. The send loops of
+// the batch connections switch to the policy before sending their next batches, and the connections established
+// later use it as well.
+func (c *RPCClient) SetBatchPolicy(policy string) error {
+	if err := ValidateBatchPolicy(policy); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.batchPolicy = &policy
+	for _, array := range c.conns {
+		if array.batchConn != nil {
+			array.batchConn.policy.Store(&policy)
+		}
+	}
+	logutil.BgLogger().Info("batch policy updated", zap.String("policy", policy))
+	return nil
+}
+
+// GetBatchPolicy returns the batch policy of the client, which is set by SetBatchPolicy or the config.
+func (c *RPCClient) GetBatchPolicy() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.batchPolicy != nil {
+		return *c.batchPolicy
+	}
+	return config.GetGlobalConfig().TiKVClient.BatchPolicy
+}
+
+// SetBatchPolicy updates the batch policy of the client, or returns an error if the client isn't (a wrapper of)
+// RPCClient.
+func SetBatchPolicy(c Client, policy string) error {
+	if rpcClient := unwrapRPCClient(c); rpcClient != nil {
+		return rpcClient.SetBatchPolicy(policy)
+	}
+	return errors.Errorf("the batch policy of %T can't be updated", c)
+}
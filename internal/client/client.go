@@ -41,6 +41,7 @@ import (
 	"io"
 	"math"
 	"runtime/trace"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -60,6 +61,7 @@ import (
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/apicodec"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
@@ -117,6 +119,56 @@ type ClientEventListener interface {
 	OnHealthFeedback(feedback *kvrpcpb.HealthFeedback)
 }
 
+// ConnEventListener can be implemented by a ClientEventListener to observe the recycling of the idle batch
+// connections, see config.TiKVClient.BatchConnIdleRecycle.
+type ConnEventListener interface {
+	// OnConnRecycled is called when the idle connections or streams to the store at addr are recycled.
+	OnConnRecycled(addr string, recycle string)
+	// OnConnReestablished is called when the connections or streams to addr are re-established after being recycled.
+	OnConnReestablished(addr string, recycle string)
+}
+
+// OutdatedResponseListener can be implemented by a ClientEventListener to observe the outdated batch responses, whose
+// requests are already finished, e.g. failed by an ambiguous send error.
+type OutdatedResponseListener interface {
+	// OnOutdatedResponse is called with the store address, the request ID and the command type, e.g. "Get", of an
+	// outdated response.
+	OnOutdatedResponse(addr string, requestID uint64, cmd string)
+}
+
+// DrainEventListener can be implemented by a ClientEventListener to observe the stores draining the connections, e.g.
+// being restarted gracefully, so the new requests can be routed to other stores while the in-flight ones complete.
+type DrainEventListener interface {
+	// OnStoreDraining is called when a connection to the store at addr receives a GOAWAY of the store shutting down.
+	OnStoreDraining(addr string)
+	// OnStoreReconnected is called when the connection is established again after OnStoreDraining.
+	OnStoreReconnected(addr string)
+}
+
+const (
+	connRecycled      = "recycled"
+	connReestablished = "reestablished"
+)
+
+// notifyConnEvent records the recycling event of the connections to addr and notifies the listener, if any.
+func notifyConnEvent(listener *atomic.Pointer[ClientEventListener], addr, recycle, event string) {
+	metrics.TiKVBatchConnRecycleCounter.WithLabelValues(recycle, event).Inc()
+	if listener == nil {
+		return
+	}
+	l := listener.Load()
+	if l == nil {
+		return
+	}
+	if connListener, ok := (*l).(ConnEventListener); ok {
+		if event == connRecycled {
+			connListener.OnConnRecycled(addr, recycle)
+		} else {
+			connListener.OnConnReestablished(addr, recycle)
+		}
+	}
+}
+
 // ClientExt is a client has extended interfaces.
 type ClientExt interface {
 	// CloseAddrVer closes gRPC connections to the address with additional `ver` parameter.
@@ -171,17 +223,23 @@ type connArray struct {
 	done chan struct{}
 
 	monitor *connMonitor
+	// credentials is not nil if the requests carry the credentials of a CredentialProvider.
+	credentials *credentialCache
+	// eventListener is notified when the connections receive a GOAWAY.
+	eventListener *atomic.Pointer[ClientEventListener]
 
 	metrics struct {
 		rpcLatHist        *rpcMetrics
 		rpcSrcLatSum      sync.Map
+		rpcTraffic        sync.Map
 		rpcNetLatExternal prometheus.Observer
 		rpcNetLatInternal prometheus.Observer
 	}
 }
 
 func newConnArray(maxSize uint, addr string, ver uint64, security config.Security,
-	idleNotify *uint32, enableBatch bool, dialTimeout time.Duration, m *connMonitor, eventListener *atomic.Pointer[ClientEventListener], opts []grpc.DialOption) (*connArray, error) {
+	idleNotify *uint32, enableBatch bool, dialTimeout time.Duration, m *connMonitor, eventListener *atomic.Pointer[ClientEventListener], credentials *credentialCache,
+	opts []grpc.DialOption) (*connArray, error) {
 	a := &connArray{
 		ver:           ver,
 		index:         0,
@@ -190,6 +248,7 @@ func newConnArray(maxSize uint, addr string, ver uint64, security config.Securit
 		done:          make(chan struct{}),
 		dialTimeout:   dialTimeout,
 		monitor:       m,
+		credentials:   credentials,
 	}
 	a.metrics.rpcLatHist = deriveRPCMetrics(metrics.TiKVSendReqHistogram.MustCurryWith(prometheus.Labels{metrics.LblStore: addr}))
 	a.metrics.rpcNetLatExternal = metrics.TiKVRPCNetLatencyHistogram.WithLabelValues(addr, "false")
@@ -237,25 +296,35 @@ func (c *connMonitor) Stop() {
 	)
 }
 
-func (c *connMonitor) start() {
+const (
+	connMonitorMinInterval = time.Second
+	connMonitorMaxInterval = 8 * time.Second
+)
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
+func (c *connMonitor) start() {
+	// The states are checked every second, and the interval is doubled up to
+	// connMonitorMaxInterval while no state changes, so the monitor costs little
+	// when there are many stable connections.
+	interval := connMonitorMinInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			changed := false
 			c.m.Range(func(_, value interface{}) bool {
 				conn := value.(*monitoredConn)
-				nowState := conn.GetState()
-				for state := connectivity.Idle; state <= connectivity.Shutdown; state++ {
-					if state == nowState {
-						metrics.TiKVGrpcConnectionState.WithLabelValues(conn.Name, conn.Target(), nowState.String()).Set(1)
-					} else {
-						metrics.TiKVGrpcConnectionState.WithLabelValues(conn.Name, conn.Target(), state.String()).Set(0)
-					}
+				if conn.updateStateMetrics() {
+					changed = true
 				}
 				return true
 			})
+			if changed {
+				interval = connMonitorMinInterval
+			} else {
+				interval = min(interval*2, connMonitorMaxInterval)
+			}
+			timer.Reset(interval)
 		case <-c.stop:
 			return
 		}
@@ -265,12 +334,21 @@ func (c *connMonitor) start() {
 type monitoredConn struct {
 	*grpc.ClientConn
 	Name string
+	// lastState is the state reported by the latest metrics update, it's only
+	// accessed by the connMonitor.
+	lastState connectivity.State
+	stats     *connStatsHandler
 }
 
 func (a *connArray) monitoredDial(ctx context.Context, connName, target string, opts ...grpc.DialOption) (conn *monitoredConn, err error) {
 	conn = &monitoredConn{
-		Name: connName,
+		Name:      connName,
+		lastState: -1,
+		stats:     newConnStatsHandler(a.target),
 	}
+	conn.stats.onDrain = a.onDrain
+	conn.stats.onReconnect = a.onReconnect
+	opts = append(opts, grpc.WithStatsHandler(conn.stats))
 	conn.ClientConn, err = grpc.DialContext(ctx, target, opts...)
 	if err != nil {
 		return nil, err
@@ -279,6 +357,52 @@ func (a *connArray) monitoredDial(ctx context.Context, connName, target string,
 	return conn, nil
 }
 
+// onDrain notifies the listener that the target is draining the connections.
+func (a *connArray) onDrain() {
+	if l := a.drainEventListener(); l != nil {
+		l.OnStoreDraining(a.target)
+	}
+}
+
+// onReconnect notifies the listener that the target is connected again after draining the connections.
+func (a *connArray) onReconnect() {
+	if l := a.drainEventListener(); l != nil {
+		l.OnStoreReconnected(a.target)
+	}
+}
+
+func (a *connArray) drainEventListener() DrainEventListener {
+	if a.eventListener == nil {
+		return nil
+	}
+	if l := a.eventListener.Load(); l != nil {
+		if drainListener, ok := (*l).(DrainEventListener); ok {
+			return drainListener
+		}
+	}
+	return nil
+}
+
+// updateStateMetrics updates the state metrics if the state has changed since the last update.
+func (c *monitoredConn) updateStateMetrics() (changed bool) {
+	nowState := c.GetState()
+	if nowState == c.lastState {
+		return false
+	}
+	for state := connectivity.Idle; state <= connectivity.Shutdown; state++ {
+		if state == nowState {
+			metrics.TiKVGrpcConnectionState.WithLabelValues(c.Name, c.Target(), state.String()).Set(1)
+		} else if state == c.lastState || c.lastState < 0 {
+			metrics.TiKVGrpcConnectionState.WithLabelValues(c.Name, c.Target(), state.String()).Set(0)
+		}
+	}
+	if c.lastState >= 0 {
+		c.stats.onStateChange()
+	}
+	c.lastState = nowState
+	return true
+}
+
 func (c *monitoredConn) Close() error {
 	if c.ClientConn != nil {
 		err := c.ClientConn.Close()
@@ -290,6 +414,7 @@ func (c *monitoredConn) Close() error {
 
 func (a *connArray) Init(addr string, security config.Security, idleNotify *uint32, enableBatch bool, eventListener *atomic.Pointer[ClientEventListener], opts ...grpc.DialOption) error {
 	a.target = addr
+	a.eventListener = eventListener
 
 	opt := grpc.WithTransportCredentials(insecure.NewCredentials())
 	if len(security.ClusterSSLCA) != 0 {
@@ -313,7 +438,13 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 	allowBatch := (cfg.TiKVClient.MaxBatchSize > 0) && enableBatch
 	if allowBatch {
 		a.batchConn = newBatchConn(uint(len(a.v)), cfg.TiKVClient.MaxBatchSize, idleNotify)
+		a.batchConn.setIdlePolicy(cfg.TiKVClient.BatchConnIdleTimeout, cfg.TiKVClient.BatchConnIdleRecycle)
+		a.batchConn.target = a.target
+		a.batchConn.concurrencyLimit = cfg.TiKVClient.MaxConcurrencyRequestLimit
+		a.batchConn.policy.Store(&cfg.TiKVClient.BatchPolicy)
 		a.batchConn.initMetrics(a.target)
+		a.batchConn.adaptiveSize = newAdaptiveBatchSize(cfg.TiKVClient.AdaptiveBatchSizeFloor, cfg.TiKVClient.MaxBatchSize,
+			cfg.TiKVClient.AdaptiveBatchSizeLatencyThreshold, metrics.TiKVBatchAdaptiveMaxSize.WithLabelValues(a.target))
 	}
 	keepAlive := cfg.TiKVClient.GrpcKeepAliveTime
 	for i := range a.v {
@@ -374,16 +505,22 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 				tikvClientCfg:    cfg.TiKVClient,
 				tikvLoad:         &a.tikvTransportLayerLoad,
 				dialTimeout:      a.dialTimeout,
+				fallback:         &a.batchConn.fallback,
 				tryLock:          tryLock{sync.NewCond(new(sync.Mutex)), false},
 				eventListener:    eventListener,
 				metrics:          &a.batchConn.metrics,
+				credentials:      a.credentials,
 			}
 			batchClient.maxConcurrencyRequestLimit.Store(cfg.TiKVClient.MaxConcurrencyRequestLimit)
+			if a.batchConn.adaptiveSize != nil {
+				batchClient.recvLats = a.batchConn.adaptiveSize.newRecvLatencies()
+			}
 			a.batchCommandsClients = append(a.batchCommandsClients, batchClient)
 		}
 	}
 	go tikvrpc.CheckStreamTimeoutLoop(a.streamTimeout, a.done)
 	if allowBatch {
+		batchIdleDetector.register(a.batchConn)
 		go a.batchSendLoop(cfg.TiKVClient)
 	}
 
@@ -421,6 +558,14 @@ func (a *connArray) updateRPCMetrics(req *tikvrpc.Request, resp *tikvrpc.Respons
 
 	a.metrics.rpcLatHist.get(req.Type, stale, internal).Observe(seconds)
 
+	trafficKey := uint64(req.Type) | uint64(req.TrafficClass)<<16
+	trafficCounter, ok := a.metrics.rpcTraffic.Load(trafficKey)
+	if !ok {
+		trafficCounter = metrics.TiKVTrafficRequestCounter.WithLabelValues(req.TrafficClass.Name(), req.Type.String())
+		a.metrics.rpcTraffic.Store(trafficKey, trafficCounter)
+	}
+	trafficCounter.(prometheus.Counter).Inc()
+
 	srcLatSum, ok := a.metrics.rpcSrcLatSum.Load(source)
 	if !ok {
 		srcLatSum = deriveRPCMetrics(metrics.TiKVSendReqSummary.MustCurryWith(
@@ -452,6 +597,11 @@ type option struct {
 	security        config.Security
 	dialTimeout     time.Duration
 	codec           apicodec.Codec
+	admission       AdmissionController
+
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	credentials        *credentialCache
 }
 
 // Opt is the option for the client.
@@ -471,6 +621,39 @@ func WithGRPCDialOptions(grpcDialOptions ...grpc.DialOption) Opt {
 	}
 }
 
+// WithUnaryInterceptors appends the unary interceptors to the connections to TiKV. They are chained in order after
+// the built-in ones, e.g. the tracing interceptor.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Opt {
+	return func(c *option) {
+		c.unaryInterceptors = append(c.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends the stream interceptors to the connections to TiKV. They are chained in order after
+// the built-in ones. Note that the batch commands are sent over a long-lived stream, so the interceptors are called
+// when the stream is created rather than for each request in it.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Opt {
+	return func(c *option) {
+		c.streamInterceptors = append(c.streamInterceptors, interceptors...)
+	}
+}
+
+// dialOptions returns the grpc.DialOption used to create the connections, including the user-supplied interceptors.
+func (o *option) dialOptions() []grpc.DialOption {
+	opts := make([]grpc.DialOption, 0, len(o.gRPCDialOptions)+3)
+	opts = append(opts, o.gRPCDialOptions...)
+	if o.credentials != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(o.credentials))
+	}
+	if len(o.unaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(o.unaryInterceptors...))
+	}
+	if len(o.streamInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(o.streamInterceptors...))
+	}
+	return opts
+}
+
 // WithCodec is used to set RPCClient's codec.
 func WithCodec(codec apicodec.Codec) Opt {
 	return func(c *option) {
@@ -478,6 +661,31 @@ func WithCodec(codec apicodec.Codec) Opt {
 	}
 }
 
+type requestCodecKey struct{}
+
+// WithRequestCodec returns a copy of ctx that makes the requests sent with it encoded by codec instead of the codec of
+// the client, e.g. to access the raw range of a keyspace by the client of its transactional data.
+func WithRequestCodec(ctx context.Context, codec apicodec.Codec) context.Context {
+	return context.WithValue(ctx, requestCodecKey{}, codec)
+}
+
+// GetRequestCodec returns the codec set by WithRequestCodec, nil if it's not set.
+func GetRequestCodec(ctx context.Context) apicodec.Codec {
+	codec, _ := ctx.Value(requestCodecKey{}).(apicodec.Codec)
+	return codec
+}
+
+// getCodec returns the codec encoding the requests sent with ctx, nil if they aren't encoded.
+func (c *RPCClient) getCodec(ctx context.Context) apicodec.Codec {
+	if codec := GetRequestCodec(ctx); codec != nil {
+		return codec
+	}
+	if c.option == nil {
+		return nil
+	}
+	return c.option.codec
+}
+
 // RPCClient is RPC client struct.
 // TODO: Add flow control between RPC clients in TiDB ond RPC servers in TiKV.
 // Since we use shared client connection to communicate to the same TiKV, it's possible
@@ -498,6 +706,10 @@ type RPCClient struct {
 	connMonitor *connMonitor
 
 	eventListener *atomic.Pointer[ClientEventListener]
+	// recycled is the addresses whose idle connections are recycled and not re-established yet.
+	recycled map[string]struct{}
+	// batchPolicy overrides the batch policy of the config if it's not nil, see SetBatchPolicy.
+	batchPolicy *string
 }
 
 var _ Client = &RPCClient{}
@@ -512,6 +724,7 @@ func NewRPCClient(opts ...Opt) *RPCClient {
 		},
 		connMonitor:   &connMonitor{},
 		eventListener: new(atomic.Pointer[ClientEventListener]),
+		recycled:      make(map[string]struct{}),
 	}
 	for _, opt := range opts {
 		opt(cli.option)
@@ -566,13 +779,21 @@ func (c *RPCClient) createConnArray(addr string, enableBatch bool, opts ...func(
 			c.option.dialTimeout,
 			c.connMonitor,
 			c.eventListener,
-			c.option.gRPCDialOptions)
+			c.option.credentials,
+			c.option.dialOptions())
 
 		if err != nil {
 			return nil, err
 		}
+		if c.batchPolicy != nil && array.batchConn != nil {
+			array.batchConn.policy.Store(c.batchPolicy)
+		}
 		c.conns[addr] = array
 		c.vers[addr] = ver
+		if _, ok := c.recycled[addr]; ok {
+			delete(c.recycled, addr)
+			notifyConnEvent(c.eventListener, addr, config.BatchConnIdleRecycleConnArray, connReestablished)
+		}
 	}
 	return array, nil
 }
@@ -605,6 +826,10 @@ func (c *RPCClient) recycleIdleConnArray() {
 
 	for i, addr := range addrs {
 		c.CloseAddrVer(addr, vers[i])
+		c.Lock()
+		c.recycled[addr] = struct{}{}
+		c.Unlock()
+		notifyConnEvent(c.eventListener, addr, config.BatchConnIdleRecycleConnArray, connRecycled)
 	}
 
 	metrics.TiKVBatchClientRecycle.Observe(time.Since(start).Seconds())
@@ -654,7 +879,33 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	if config.GetGlobalConfig().TiKVClient.MaxBatchSize > 0 && enableBatch {
 		if batchReq := req.ToBatchCommandsRequest(); batchReq != nil {
 			defer trace.StartRegion(ctx, req.Type.String()).End()
-			return wrapErrConn(sendBatchRequest(ctx, addr, req.ForwardedHost, connArray.batchConn, batchReq, timeout, pri))
+			connArray.batchConn.observeStoreID(req.Context.GetPeer().GetStoreId())
+			var done func(error)
+			if pri, done, err = c.admit(ctx, addr, connArray.batchConn, req, pri); err != nil {
+				observeIntendedLatency(req.Type, start, err)
+				return nil, err
+			}
+			if !connArray.batchConn.fallback.active() {
+				resp, err = sendBatchRequest(ctx, addr, req.ForwardedHost, connArray.batchConn, batchReq, timeout, pri)
+				if !isBatchCommandsUnsupported(err) {
+					if done != nil {
+						done(err)
+					}
+					observeIntendedLatency(req.Type, start, err)
+					return wrapErrConn(resp, err)
+				}
+			}
+			// The target doesn't support BatchCommands, send it by a unary RPC below instead. It's still admitted and
+			// accounted as a batch request until it finishes.
+			if done != nil {
+				defer func() { done(err) }()
+			}
+			var reqSize int64
+			if memctl.Enabled() {
+				reqSize = int64(batchReq.Size())
+			}
+			memctl.Consume(memctl.KindBatchQueue, reqSize)
+			defer memctl.Consume(memctl.KindBatchQueue, -reqSize)
 		}
 	}
 
@@ -693,12 +944,17 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 
 // SendRequest sends a Request to server and receives Response.
 func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if config.GetGlobalConfig().TiKVClient.EnableRequestValidation {
+		if err := req.Validate(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
 	// In unit test, the option or codec may be nil. Here should skip the encode/decode process.
-	if c.option == nil || c.option.codec == nil {
+	codec := c.getCodec(ctx)
+	if codec == nil {
 		return c.sendRequest(ctx, addr, req, timeout)
 	}
 
-	codec := c.option.codec
 	req, err := codec.EncodeRequest(req)
 	if err != nil {
 		return nil, err
@@ -1043,3 +1299,75 @@ func (m *rpcMetrics) get(cmd tikvrpc.CmdType, stale bool, internal bool) prometh
 	}
 	return lat.(prometheus.Observer)
 }
+
+// BatchQueueStat is the stat of the batch commands queue to a target.
+type BatchQueueStat struct {
+	Target string
+	// Pending is the number of requests waiting in the queue to be sent.
+	Pending int
+	// Conns is the number of batch commands connections to the target.
+	Conns int
+	// Inflight is the number of requests sent to the target but not responded.
+	Inflight int
+	// MaxPending and MaxInflight are the high watermarks of Pending and Inflight since the connections are created.
+	MaxPending  int
+	MaxInflight int
+	// MaxBatchSize is the max size of the batches sent to the target, which adapts to the latency of the target if
+	// config.TiKVClient.AdaptiveBatchSizeFloor is set.
+	MaxBatchSize int
+}
+
+// batchQueueStats returns the stats of the batch commands queues, sorted by the target.
+func (c *RPCClient) batchQueueStats() []BatchQueueStat {
+	c.RLock()
+	defer c.RUnlock()
+	stats := make([]BatchQueueStat, 0, len(c.conns))
+	for target, array := range c.conns {
+		if array.batchConn == nil {
+			continue
+		}
+		maxBatchSize := int(config.GetGlobalConfig().TiKVClient.MaxBatchSize)
+		if array.batchConn.adaptiveSize != nil {
+			maxBatchSize = array.batchConn.adaptiveSize.get()
+		}
+		stats = append(stats, BatchQueueStat{
+			Target:       target,
+			Pending:      len(array.batchConn.batchCommandsCh),
+			Conns:        len(array.batchConn.batchCommandsClients),
+			Inflight:     int(array.batchConn.inflight()),
+			MaxPending:   int(array.batchConn.maxPending.Load()),
+			MaxInflight:  int(array.batchConn.maxInflight.Load()),
+			MaxBatchSize: maxBatchSize,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
+
+// GetBatchQueueStats returns the stats of the batch commands queues of the client, or nil if the client isn't
+// (a wrapper of) RPCClient.
+func GetBatchQueueStats(c Client) []BatchQueueStat {
+	if rpcClient := unwrapRPCClient(c); rpcClient != nil {
+		return rpcClient.batchQueueStats()
+	}
+	return nil
+}
+
+// wrappedClient is a Client wrapping another one, e.g. the one created by NewInterceptedClient.
+type wrappedClient interface {
+	unwrap() Client
+}
+
+// unwrapRPCClient returns the RPCClient wrapped by c, or nil if c isn't (a wrapper of) RPCClient.
+func unwrapRPCClient(c Client) *RPCClient {
+	for {
+		switch inner := c.(type) {
+		case *RPCClient:
+			return inner
+		case wrappedClient:
+			c = inner.unwrap()
+		default:
+			return nil
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
@@ -35,6 +36,7 @@ import (
 // SendRequestAsync sends a request to the target address asynchronously.
 func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
 	var err error
+	intendedStart := time.Now()
 
 	if atomic.CompareAndSwapUint32(&c.idleNotify, 1, 0) {
 		go c.recycleIdleConnArray()
@@ -54,6 +56,12 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 		cb.Invoke(nil, errors.New("unsupported request type: "+req.Type.String()))
 		return
 	}
+	if config.GetGlobalConfig().TiKVClient.EnableRequestValidation {
+		if err = req.Validate(); err != nil {
+			cb.Invoke(nil, errors.WithStack(err))
+			return
+		}
+	}
 
 	regionRPC := trace.StartRegion(ctx, req.Type.String())
 	spanRPC := opentracing.SpanFromContext(ctx)
@@ -62,9 +70,10 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 		ctx = opentracing.ContextWithSpan(ctx, spanRPC)
 	}
 
-	useCodec := c.option != nil && c.option.codec != nil
+	codec := c.getCodec(ctx)
+	useCodec := codec != nil
 	if useCodec {
-		req, err = c.option.codec.EncodeRequest(req)
+		req, err = codec.EncodeRequest(req)
 		if err != nil {
 			cb.Invoke(nil, err)
 			return
@@ -80,6 +89,29 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 		cb.Invoke(nil, err)
 		return
 	}
+	connArray.batchConn.observeStoreID(req.Context.GetPeer().GetStoreId())
+	if connArray.batchConn.fallback.active() {
+		// The target doesn't support BatchCommands, send it by a unary RPC in background instead. sendRequest admits
+		// and accounts it as a batch request.
+		regionRPC.End()
+		go func() {
+			resp, err := c.sendRequest(ctx, addr, req, DefaultTimeout(req.Type))
+			if useCodec && err == nil {
+				resp, err = codec.DecodeResponse(req, resp)
+			}
+			if spanRPC != nil {
+				spanRPC.Finish()
+			}
+			cb.Schedule(resp, err)
+		}()
+		return
+	}
+	pri, done, err := c.admit(ctx, addr, connArray.batchConn, req, req.GetResourceControlContext().GetOverridePriority())
+	if err != nil {
+		observeIntendedLatency(req.Type, intendedStart, err)
+		cb.Invoke(nil, err)
+		return
+	}
 
 	var (
 		entry = &batchCommandsEntry{
@@ -89,17 +121,29 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 			forwardedHost: req.ForwardedHost,
 			canceled:      0,
 			err:           nil,
-			pri:           req.GetResourceControlContext().GetOverridePriority(),
+			pri:           pri,
 			start:         time.Now(),
 		}
 		stop func() bool
+		// reqSize is accounted as the batch queue memory until the callback is invoked.
+		reqSize int64
 	)
+	if memctl.Enabled() {
+		reqSize = int64(batchReq.Size())
+	}
+	entry.deadline = entryDeadline(ctx, entry.start, 0)
+	entry.req = propagateDeadline(batchReq, entry.deadline, entry.start)
+	memctl.Consume(memctl.KindBatchQueue, reqSize)
 
 	// defer post actions
 	entry.cb.Inject(func(resp *tikvrpc.Response, err error) (*tikvrpc.Response, error) {
 		if stop != nil {
 			stop()
 		}
+		memctl.Consume(memctl.KindBatchQueue, -reqSize)
+		if done != nil {
+			done(err)
+		}
 
 		elapsed := time.Since(entry.start)
 
@@ -111,6 +155,7 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 			metrics.BatchRequestDurationRecv.Observe(time.Duration(recvLat).Seconds())
 		}
 		metrics.BatchRequestDurationDone.Observe(elapsed.Seconds())
+		observeIntendedLatency(req.Type, intendedStart, err)
 
 		// rpc metrics
 		connArray.updateRPCMetrics(req, resp, elapsed)
@@ -128,7 +173,7 @@ func (c *RPCClient) SendRequestAsync(ctx context.Context, addr string, req *tikv
 
 		// codec
 		if useCodec && err == nil {
-			resp, err = c.option.codec.DecodeResponse(req, resp)
+			resp, err = codec.DecodeResponse(req, resp)
 		}
 
 		return resp, WrapErrConn(err, connArray)
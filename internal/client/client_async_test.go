@@ -24,9 +24,12 @@ import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/internal/client/mockserver"
+	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util/async"
 )
@@ -267,3 +270,57 @@ func TestSendRequestAsyncAndCloseClientBeforeSend(t *testing.T) {
 	cli.SendRequestAsync(ctx, addr, req, cb)
 	require.True(t, called)
 }
+
+func TestIntendedLatencyMetrics(t *testing.T) {
+	defer config.UpdateGlobal(func(conf *config.Config) { conf.TiKVClient.EnableIntendedLatencyMetrics = true })()
+	ctx := context.Background()
+	srv, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	addr := srv.Addr()
+	cli := NewRPCClient()
+	defer func() {
+		cli.Close()
+		srv.Stop()
+	}()
+
+	read := func(result string) (count uint64, sum float64) {
+		var m dto.Metric
+		observer := metrics.TiKVBatchRequestIntendedHistogram.WithLabelValues(tikvrpc.CmdEmpty.String(), result)
+		require.NoError(t, observer.(prometheus.Metric).Write(&m))
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+	okCount, _ := read(intendedResultOK)
+	timeoutCount, timeoutSum := read(intendedResultTimeout)
+	canceledCount, _ := read(intendedResultCanceled)
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err := cli.SendRequest(ctx, addr, req, time.Second)
+	require.NoError(t, err)
+	count, _ := read(intendedResultOK)
+	require.Equal(t, okCount+1, count)
+
+	// The async request timed out is recorded with the whole time since it's sent.
+	sendCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	handle := func(req *tikvpb.BatchCommandsRequest) (*tikvpb.BatchCommandsResponse, error) {
+		<-sendCtx.Done()
+		return nil, errors.New("timeout")
+	}
+	srv.OnBatchCommandsRequest.Store(&handle)
+	defer srv.OnBatchCommandsRequest.Store(nil)
+	rl := async.NewRunLoop()
+	cli.SendRequestAsync(sendCtx, addr, tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{}), async.NewCallback(rl, func(_ *tikvrpc.Response, err error) {
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}))
+	rl.Exec(ctx)
+	count, sum := read(intendedResultTimeout)
+	require.Equal(t, timeoutCount+1, count)
+	require.GreaterOrEqual(t, sum-timeoutSum, 0.05)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = cli.SendRequest(canceledCtx, addr, tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{}), time.Second)
+	require.ErrorIs(t, err, context.Canceled)
+	count, _ = read(intendedResultCanceled)
+	require.Equal(t, canceledCount+1, count)
+}
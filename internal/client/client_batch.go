@@ -39,6 +39,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"runtime"
 	"runtime/trace"
@@ -55,6 +56,7 @@ import (
 	"github.com/tikv/client-go/v2/config/retry"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
@@ -77,11 +79,258 @@ type batchCommandsEntry struct {
 	canceled int32
 	err      error
 	pri      uint64
+	// streamBroken indicates the request is failed because the stream it's sent by is broken.
+	streamBroken bool
+	// waitingLate is set when the request fails to be sent but waits for its response until its deadline, see
+	// config.TiKVClient.DeliverLateBatchResponses.
+	waitingLate atomic.Bool
+	// lateTimer fails the request waiting for its late response at its deadline. It's stopped once the request is
+	// finished.
+	lateTimer atomic.Pointer[time.Timer]
 
 	// start indicates when the batch commands entry is generated and sent to the batch conn channel.
-	start   time.Time
-	sendLat int64
-	recvLat int64
+	start time.Time
+	// deadline is when the request times out, zero if it has no deadline.
+	deadline time.Time
+	sendLat  int64
+	recvLat  int64
+}
+
+// entryDeadline returns the deadline of a request started at start, which is the earlier one of the timeout and the
+// deadline of ctx. A non-positive timeout means the request has no timeout.
+func entryDeadline(ctx context.Context, start time.Time, timeout time.Duration) time.Time {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = start.Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+// batchRequestContext returns the context of the request in the batch commands, nil if it has none.
+func batchRequestContext(req *tikvpb.BatchCommandsRequest_Request) *kvrpcpb.Context {
+	switch cmd := req.GetCmd().(type) {
+	case *tikvpb.BatchCommandsRequest_Request_Get:
+		return cmd.Get.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Scan:
+		return cmd.Scan.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Prewrite:
+		return cmd.Prewrite.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Commit:
+		return cmd.Commit.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Cleanup:
+		return cmd.Cleanup.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_BatchGet:
+		return cmd.BatchGet.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_BatchRollback:
+		return cmd.BatchRollback.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_ScanLock:
+		return cmd.ScanLock.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_ResolveLock:
+		return cmd.ResolveLock.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_GC:
+		return cmd.GC.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_DeleteRange:
+		return cmd.DeleteRange.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawGet:
+		return cmd.RawGet.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchGet:
+		return cmd.RawBatchGet.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawPut:
+		return cmd.RawPut.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchPut:
+		return cmd.RawBatchPut.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawDelete:
+		return cmd.RawDelete.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchDelete:
+		return cmd.RawBatchDelete.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawDeleteRange:
+		return cmd.RawDeleteRange.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_RawScan:
+		return cmd.RawScan.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Coprocessor:
+		return cmd.Coprocessor.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_PessimisticLock:
+		return cmd.PessimisticLock.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_PessimisticRollback:
+		return cmd.PessimisticRollback.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_CheckTxnStatus:
+		return cmd.CheckTxnStatus.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_CheckSecondaryLocks:
+		return cmd.CheckSecondaryLocks.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_TxnHeartBeat:
+		return cmd.TxnHeartBeat.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_FlashbackToVersion:
+		return cmd.FlashbackToVersion.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_PrepareFlashbackToVersion:
+		return cmd.PrepareFlashbackToVersion.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_Flush:
+		return cmd.Flush.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_BufferBatchGet:
+		return cmd.BufferBatchGet.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_GetHealthFeedback:
+		return cmd.GetHealthFeedback.GetContext()
+	case *tikvpb.BatchCommandsRequest_Request_BroadcastTxnStatus:
+		return cmd.BroadcastTxnStatus.GetContext()
+	default:
+		return nil
+	}
+}
+
+// withBatchRequestContext returns a copy of the request with its context replaced by rpcCtx. The request of the caller
+// is left unchanged, because it's reused by the retries.
+func withBatchRequestContext(req *tikvpb.BatchCommandsRequest_Request, rpcCtx *kvrpcpb.Context) *tikvpb.BatchCommandsRequest_Request {
+	switch cmd := req.Cmd.(type) {
+	case *tikvpb.BatchCommandsRequest_Request_Get:
+		c := *cmd.Get
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Get{Get: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Scan:
+		c := *cmd.Scan
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Scan{Scan: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Prewrite:
+		c := *cmd.Prewrite
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Prewrite{Prewrite: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Commit:
+		c := *cmd.Commit
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Commit{Commit: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Cleanup:
+		c := *cmd.Cleanup
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Cleanup{Cleanup: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_BatchGet:
+		c := *cmd.BatchGet
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_BatchGet{BatchGet: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_BatchRollback:
+		c := *cmd.BatchRollback
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_BatchRollback{BatchRollback: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_ScanLock:
+		c := *cmd.ScanLock
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_ScanLock{ScanLock: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_ResolveLock:
+		c := *cmd.ResolveLock
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_ResolveLock{ResolveLock: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_GC:
+		c := *cmd.GC
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_GC{GC: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_DeleteRange:
+		c := *cmd.DeleteRange
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_DeleteRange{DeleteRange: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawGet:
+		c := *cmd.RawGet
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawGet{RawGet: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchGet:
+		c := *cmd.RawBatchGet
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawBatchGet{RawBatchGet: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawPut:
+		c := *cmd.RawPut
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawPut{RawPut: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchPut:
+		c := *cmd.RawBatchPut
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawBatchPut{RawBatchPut: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawDelete:
+		c := *cmd.RawDelete
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawDelete{RawDelete: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawBatchDelete:
+		c := *cmd.RawBatchDelete
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawBatchDelete{RawBatchDelete: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawDeleteRange:
+		c := *cmd.RawDeleteRange
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawDeleteRange{RawDeleteRange: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_RawScan:
+		c := *cmd.RawScan
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawScan{RawScan: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Coprocessor:
+		c := *cmd.Coprocessor
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Coprocessor{Coprocessor: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_PessimisticLock:
+		c := *cmd.PessimisticLock
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_PessimisticLock{PessimisticLock: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_PessimisticRollback:
+		c := *cmd.PessimisticRollback
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_PessimisticRollback{PessimisticRollback: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_CheckTxnStatus:
+		c := *cmd.CheckTxnStatus
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_CheckTxnStatus{CheckTxnStatus: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_CheckSecondaryLocks:
+		c := *cmd.CheckSecondaryLocks
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_CheckSecondaryLocks{CheckSecondaryLocks: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_TxnHeartBeat:
+		c := *cmd.TxnHeartBeat
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_TxnHeartBeat{TxnHeartBeat: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_FlashbackToVersion:
+		c := *cmd.FlashbackToVersion
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_FlashbackToVersion{FlashbackToVersion: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_PrepareFlashbackToVersion:
+		c := *cmd.PrepareFlashbackToVersion
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_PrepareFlashbackToVersion{PrepareFlashbackToVersion: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_Flush:
+		c := *cmd.Flush
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Flush{Flush: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_BufferBatchGet:
+		c := *cmd.BufferBatchGet
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_BufferBatchGet{BufferBatchGet: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_GetHealthFeedback:
+		c := *cmd.GetHealthFeedback
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_GetHealthFeedback{GetHealthFeedback: &c}}
+	case *tikvpb.BatchCommandsRequest_Request_BroadcastTxnStatus:
+		c := *cmd.BroadcastTxnStatus
+		c.Context = rpcCtx
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_BroadcastTxnStatus{BroadcastTxnStatus: &c}}
+	default:
+		return req
+	}
+}
+
+// propagateDeadline limits the max execution duration of the request to the time left before the deadline, so TiKV
+// can drop the request once it's timed out on the client. The shorter max execution duration set before is kept. It
+// returns the request to send in the batch, the limit is only set on a copy of the request.
+func propagateDeadline(req *tikvpb.BatchCommandsRequest_Request, deadline, now time.Time) *tikvpb.BatchCommandsRequest_Request {
+	if deadline.IsZero() || !config.GetGlobalConfig().TiKVClient.PropagateBatchDeadline {
+		return req
+	}
+	rpcCtx := batchRequestContext(req)
+	if rpcCtx == nil {
+		return req
+	}
+	// 0 means unlimited, so a request about to time out is still limited to 1ms.
+	left := uint64(max(deadline.Sub(now).Milliseconds(), 1))
+	if rpcCtx.MaxExecutionDurationMs != 0 && left >= rpcCtx.MaxExecutionDurationMs {
+		return req
+	}
+	limited := *rpcCtx
+	limited.MaxExecutionDurationMs = left
+	return withBatchRequestContext(req, &limited)
 }
 
 func (b *batchCommandsEntry) isCanceled() bool {
@@ -126,6 +375,12 @@ type batchCommandsBuilder struct {
 	forwardingReqs map[string]*tikvpb.BatchCommandsRequest
 
 	latestReqStartTime time.Time
+
+	// deadlineThreshold is the time left before the deadline of a request below which the request is urgent.
+	deadlineThreshold time.Duration
+	// urgent is the number of the urgent requests in the batch, which make the batch be sent without waiting for more
+	// requests.
+	urgent int
 }
 
 func (b *batchCommandsBuilder) len() int {
@@ -137,6 +392,9 @@ func (b *batchCommandsBuilder) push(entry *batchCommandsEntry) {
 	if entry.start.After(b.latestReqStartTime) {
 		b.latestReqStartTime = entry.start
 	}
+	if b.deadlineThreshold > 0 && !entry.deadline.IsZero() && time.Until(entry.deadline) < b.deadlineThreshold {
+		b.urgent++
+	}
 }
 
 const highTaskPriority = 10
@@ -223,6 +481,7 @@ func (b *batchCommandsBuilder) reset() {
 	}
 	b.requests = b.requests[:0]
 	b.requestIDs = b.requestIDs[:0]
+	b.urgent = 0
 
 	for k := range b.forwardingReqs {
 		delete(b.forwardingReqs, k)
@@ -279,25 +538,61 @@ type batchConn struct {
 
 	// Notify rpcClient to check the idle flag
 	idleNotify *uint32
-	idleDetect *time.Timer
+	// idleTimeout is 0 if the idle connections are not recycled, they are detected by batchIdleDetector otherwise.
+	idleTimeout time.Duration
+	idleRecycle string
+	// lastActive is the unix nano time the latest request is fetched.
+	lastActive atomic.Int64
+	// idleHandled is the lastActive when the streams are recycled, it's only accessed by batchIdleDetector.
+	idleHandled int64
 
 	fetchMoreTimer *time.Timer
 
 	index uint32
 
+	// maxPending and maxInflight are the high watermarks of the requests waiting to be sent and the requests sent but
+	// not responded.
+	maxPending  atomic.Int64
+	maxInflight atomic.Int64
+
+	// target, storeID and concurrencyLimit are used to apply the store override of the concurrency limit. storeID is
+	// learned from the requests, and concurrencyLimit is the limit without the override. storeOverridden is only
+	// accessed by the send loop.
+	target           string
+	storeID          atomic.Uint64
+	concurrencyLimit int64
+	storeOverridden  bool
+
+	// fallback records whether the requests fall back to unary RPCs because BatchCommands isn't supported.
+	fallback batchFallback
+
+	// policy is the batch policy applied by the send loop, which can be updated by RPCClient.SetBatchPolicy.
+	policy atomic.Pointer[string]
+	// adaptiveSize tunes the max batch size by the latency, nil if the max batch size is fixed.
+	adaptiveSize *adaptiveBatchSize
+
 	metrics batchConnMetrics
 }
 
 func newBatchConn(connCount, maxBatchSize uint, idleNotify *uint32) *batchConn {
-	return &batchConn{
+	a := &batchConn{
 		batchCommandsCh:        make(chan *batchCommandsEntry, maxBatchSize),
 		batchCommandsClients:   make([]*batchCommandsClient, 0, connCount),
 		tikvTransportLayerLoad: 0,
 		closed:                 make(chan struct{}),
 		reqBuilder:             newBatchCommandsBuilder(maxBatchSize),
 		idleNotify:             idleNotify,
-		idleDetect:             time.NewTimer(idleTimeout),
+		idleTimeout:            config.DefBatchConnIdleTimeout,
+		idleRecycle:            config.BatchConnIdleRecycleConnArray,
 	}
+	a.lastActive.Store(time.Now().UnixNano())
+	return a
+}
+
+// setIdlePolicy sets how long the connections can be idle before they are recycled and how they are recycled. It
+// must be called before the connection is registered to batchIdleDetector.
+func (a *batchConn) setIdlePolicy(timeout time.Duration, recycle string) {
+	a.idleTimeout, a.idleRecycle = timeout, recycle
 }
 
 func (a *batchConn) initMetrics(target string) {
@@ -315,27 +610,36 @@ func (a *batchConn) initMetrics(target string) {
 	a.metrics.bestBatchSize = metrics.TiKVBatchBestSize.WithLabelValues(target)
 }
 
+// inflight returns the number of the requests sent but not responded.
+func (a *batchConn) inflight() int64 {
+	var n int64
+	for _, cli := range a.batchCommandsClients {
+		n += cli.sent.Load()
+	}
+	return n
+}
+
+func updateWatermark(watermark *atomic.Int64, n int64) {
+	for {
+		old := watermark.Load()
+		if n <= old || watermark.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
 func (a *batchConn) isIdle() bool {
 	return atomic.LoadUint32(&a.idle) != 0
 }
 
 // fetchAllPendingRequests fetches all pending requests from the channel.
 func (a *batchConn) fetchAllPendingRequests(maxBatchSize int) (headRecvTime time.Time, headArrivalInterval time.Duration) {
-	// Block on the first element.
+	// Block on the first element. The loop parks here without any timer while the connection is idle, the idle
+	// connections are detected by batchIdleDetector.
 	latestReqStartTime := a.reqBuilder.latestReqStartTime
 	var headEntry *batchCommandsEntry
 	select {
 	case headEntry = <-a.batchCommandsCh:
-		if !a.idleDetect.Stop() {
-			<-a.idleDetect.C
-		}
-		a.idleDetect.Reset(idleTimeout)
-	case <-a.idleDetect.C:
-		a.idleDetect.Reset(idleTimeout)
-		atomic.AddUint32(&a.idle, 1)
-		atomic.CompareAndSwapUint32(a.idleNotify, 0, 1)
-		// This batchConn to be recycled
-		return time.Now(), 0
 	case <-a.closed:
 		return time.Now(), 0
 	}
@@ -343,6 +647,7 @@ func (a *batchConn) fetchAllPendingRequests(maxBatchSize int) (headRecvTime time
 		return time.Now(), 0
 	}
 	headRecvTime = time.Now()
+	a.lastActive.Store(headRecvTime.UnixNano())
 	if headEntry.start.After(latestReqStartTime) && !latestReqStartTime.IsZero() {
 		headArrivalInterval = headEntry.start.Sub(latestReqStartTime)
 	}
@@ -363,13 +668,23 @@ func (a *batchConn) fetchAllPendingRequests(maxBatchSize int) (headRecvTime time
 	return
 }
 
+// recycleIdleStreams closes the BatchCommands streams of each connection, they are re-established by the next
+// requests sent over the connections.
+func (a *batchConn) recycleIdleStreams() {
+	for _, c := range a.batchCommandsClients {
+		if c.recycleStreams() {
+			notifyConnEvent(c.eventListener, a.target, config.BatchConnIdleRecycleStream, connRecycled)
+		}
+	}
+}
+
 // fetchMorePendingRequests fetches more pending requests from the channel.
 func (a *batchConn) fetchMorePendingRequests(
 	maxBatchSize int,
 	batchWaitSize int,
 	maxWaitTime time.Duration,
 ) {
-	// Try to collect `batchWaitSize` requests, or wait `maxWaitTime`.
+	// Try to collect `batchWaitSize` requests, or wait `maxWaitTime`. The wait ends early if an urgent request comes.
 	if a.fetchMoreTimer == nil {
 		a.fetchMoreTimer = time.NewTimer(maxWaitTime)
 	} else {
@@ -385,6 +700,12 @@ func (a *batchConn) fetchMorePendingRequests(
 				return
 			}
 			a.reqBuilder.push(entry)
+			if a.reqBuilder.urgent > 0 {
+				if !a.fetchMoreTimer.Stop() {
+					<-a.fetchMoreTimer.C
+				}
+				return
+			}
 		case <-a.fetchMoreTimer.C:
 			return
 		}
@@ -415,8 +736,6 @@ func (a *batchConn) fetchMorePendingRequests(
 	}
 }
 
-const idleTimeout = 3 * time.Minute
-
 var (
 	// presetBatchPolicies defines a set of [turboBatchOptions] as batch policies.
 	presetBatchPolicies = map[string]turboBatchOptions{
@@ -491,11 +810,13 @@ func (t *turboBatchTrigger) turboWaitSeconds() float64 {
 	return t.opts.T
 }
 
-func (t *turboBatchTrigger) turboWaitTime() time.Duration {
+// TurboWaitTime implements BatchSchedulerPolicy.
+func (t *turboBatchTrigger) TurboWaitTime() time.Duration {
 	return time.Duration(t.opts.T * float64(time.Second))
 }
 
-func (t *turboBatchTrigger) needFetchMore(reqArrivalInterval time.Duration) bool {
+// NeedFetchMore implements BatchSchedulerPolicy.
+func (t *turboBatchTrigger) NeedFetchMore(reqArrivalInterval time.Duration) bool {
 	if t.opts.V == turboBatchTimeBased {
 		thisArrivalInterval := reqArrivalInterval.Seconds()
 		if t.maxArrivalInterval == 0 {
@@ -522,7 +843,8 @@ func (t *turboBatchTrigger) needFetchMore(reqArrivalInterval time.Duration) bool
 	}
 }
 
-func (t *turboBatchTrigger) preferredBatchWaitSize(avgBatchWaitSize float64, defBatchWaitSize int) int {
+// PreferredBatchWaitSize implements BatchSchedulerPolicy.
+func (t *turboBatchTrigger) PreferredBatchWaitSize(avgBatchWaitSize float64, defBatchWaitSize int) int {
 	if t.opts.V == turboBatchAlways {
 		return defBatchWaitSize
 	}
@@ -552,24 +874,44 @@ func (a *batchConn) batchSendLoop(cfg config.TiKVClient) {
 		}
 	}()
 
-	trigger, ok := newTurboBatchTriggerFromPolicy(cfg.BatchPolicy)
+	policy := cfg.BatchPolicy
+	if p := a.policy.Load(); p != nil {
+		policy = *p
+	}
+	trigger, ok := newBatchSchedulerPolicy(policy)
 	if !ok {
 		initBatchPolicyWarn.Do(func() {
-			logutil.BgLogger().Warn("fallback to default batch policy due to invalid value", zap.String("value", cfg.BatchPolicy))
+			logutil.BgLogger().Warn("fallback to default batch policy due to invalid value", zap.String("value", policy))
 		})
 	}
-	turboBatchWaitTime := trigger.turboWaitTime()
+	turboBatchWaitTime := trigger.TurboWaitTime()
+	a.reqBuilder.deadlineThreshold = cfg.BatchDeadlineFastPathThreshold
 
 	avgBatchWaitSize := float64(cfg.BatchWaitSize)
+	maxBatchSize := int(cfg.MaxBatchSize)
 	for {
 		sendLoopStartTime := time.Now()
 		a.reqBuilder.reset()
+		if a.adaptiveSize != nil {
+			maxBatchSize = a.adaptiveSize.adjust(sendLoopStartTime)
+		}
 
-		headRecvTime, headArrivalInterval := a.fetchAllPendingRequests(int(cfg.MaxBatchSize))
+		headRecvTime, headArrivalInterval := a.fetchAllPendingRequests(maxBatchSize)
 		if a.reqBuilder.len() == 0 {
 			// the conn is closed or recycled.
 			return
 		}
+		if p := a.policy.Load(); p != nil && *p != policy {
+			// The policy is updated by RPCClient.SetBatchPolicy, which is validated, and the estimations of the old
+			// trigger are dropped.
+			policy = *p
+			if trigger, ok = newBatchSchedulerPolicy(policy); !ok {
+				initBatchPolicyWarn.Do(func() {
+					logutil.BgLogger().Warn("fallback to default batch policy due to invalid value", zap.String("value", policy))
+				})
+			}
+			turboBatchWaitTime = trigger.TurboWaitTime()
+		}
 
 		// curl -X PUT -d 'return(true)' http://0.0.0.0:10080/fail/tikvclient/mockBlockOnBatchClient
 		if val, err := util.EvalFailpoint("mockBlockOnBatchClient"); err == nil {
@@ -578,20 +920,25 @@ func (a *batchConn) batchSendLoop(cfg config.TiKVClient) {
 			}
 		}
 
-		if batchSize := a.reqBuilder.len(); batchSize < int(cfg.MaxBatchSize) {
+		if batchSize := a.reqBuilder.len(); batchSize < maxBatchSize && a.reqBuilder.urgent == 0 {
 			if cfg.MaxBatchWaitTime > 0 && atomic.LoadUint64(&a.tikvTransportLayerLoad) > uint64(cfg.OverloadThreshold) {
 				// If the target TiKV is overload, wait a while to collect more requests.
 				metrics.TiKVBatchWaitOverLoad.Inc()
-				a.fetchMorePendingRequests(int(cfg.MaxBatchSize), int(cfg.BatchWaitSize), cfg.MaxBatchWaitTime)
-			} else if turboBatchWaitTime > 0 && headArrivalInterval > 0 && trigger.needFetchMore(headArrivalInterval) {
-				batchWaitSize := trigger.preferredBatchWaitSize(avgBatchWaitSize, int(cfg.BatchWaitSize))
-				a.fetchMorePendingRequests(int(cfg.MaxBatchSize), batchWaitSize, turboBatchWaitTime)
+				a.fetchMorePendingRequests(maxBatchSize, int(cfg.BatchWaitSize), cfg.MaxBatchWaitTime)
+			} else if turboBatchWaitTime > 0 && headArrivalInterval > 0 && trigger.NeedFetchMore(headArrivalInterval) {
+				batchWaitSize := trigger.PreferredBatchWaitSize(avgBatchWaitSize, int(cfg.BatchWaitSize))
+				a.fetchMorePendingRequests(maxBatchSize, batchWaitSize, turboBatchWaitTime)
 				a.metrics.batchMoreRequests.Observe(float64(a.reqBuilder.len() - batchSize))
 			}
 		}
+		if a.reqBuilder.urgent > 0 {
+			// The requests near their deadlines are sent without waiting for more requests.
+			metrics.TiKVBatchDeadlineFastPathCounter.Add(float64(a.reqBuilder.urgent))
+		}
 		length := a.reqBuilder.len()
 		avgBatchWaitSize = 0.2*float64(length) + 0.8*avgBatchWaitSize
 		a.metrics.pendingRequests.Observe(float64(len(a.batchCommandsCh) + length))
+		updateWatermark(&a.maxPending, int64(len(a.batchCommandsCh)+length))
 		a.metrics.bestBatchSize.Observe(avgBatchWaitSize)
 		a.metrics.headArrivalInterval.Observe(headArrivalInterval.Seconds())
 		a.metrics.sendLoopWaitHeadDur.Observe(headRecvTime.Sub(sendLoopStartTime).Seconds())
@@ -601,9 +948,37 @@ func (a *batchConn) batchSendLoop(cfg config.TiKVClient) {
 
 		sendLoopEndTime := time.Now()
 		a.metrics.sendLoopSendDur.Observe(sendLoopEndTime.Sub(sendLoopStartTime).Seconds())
-		if dur := sendLoopEndTime.Sub(headRecvTime); dur > batchSendTailLatThreshold {
+		dur := sendLoopEndTime.Sub(headRecvTime)
+		if dur > batchSendTailLatThreshold {
 			a.metrics.batchSendTailLat.Observe(dur.Seconds())
 		}
+		if a.adaptiveSize != nil {
+			a.adaptiveSize.observeSend(dur)
+		}
+	}
+}
+
+// observeStoreID records the store ID of the target, which is used to match the store overrides.
+func (a *batchConn) observeStoreID(storeID uint64) {
+	if storeID != 0 && a.storeID.Load() != storeID {
+		a.storeID.Store(storeID)
+	}
+}
+
+// applyStoreOverride updates the concurrency limit of the batch clients by the store override of the target.
+func (a *batchConn) applyStoreOverride() {
+	limit, overridden := a.concurrencyLimit, false
+	if o := config.GetGlobalConfig().TiKVClient.GetStoreOverride(a.storeID.Load(), a.target); o != nil && o.MaxConcurrencyRequestLimit > 0 {
+		limit, overridden = o.MaxConcurrencyRequestLimit, true
+	}
+	if !overridden && !a.storeOverridden {
+		return
+	}
+	a.storeOverridden = overridden
+	for _, c := range a.batchCommandsClients {
+		if c.maxConcurrencyRequestLimit.Load() != limit {
+			c.maxConcurrencyRequestLimit.Store(limit)
+		}
 	}
 }
 
@@ -618,6 +993,7 @@ func (a *batchConn) getClientAndSend() {
 			time.Sleep(time.Duration(timeout * int(time.Millisecond)))
 		}
 	}
+	a.applyStoreOverride()
 
 	// Choose a connection by round-robbin.
 	var (
@@ -673,6 +1049,7 @@ func (a *batchConn) getClientAndSend() {
 		batch += len(req.RequestIds)
 		cli.send(forwardedHost, req)
 	}
+	updateWatermark(&a.maxInflight, a.inflight())
 	if batch > 0 {
 		a.metrics.batchSize.Observe(float64(batch))
 	}
@@ -715,6 +1092,32 @@ func (l *tryLock) unlockForRecreate() {
 type batchCommandsStream struct {
 	tikvpb.Tikv_BatchCommandsClient
 	forwardedHost string
+
+	credentials *credentialCache
+	// credentialExpiry is the expiry of the credential the stream is created with, the stream should be replaced
+	// before it. It's protected by tryLock.
+	credentialExpiry time.Time
+	// retired is set when the stream is replaced by a new one. Its batchRecvLoop exits after receiving the responses
+	// of the requests sent before, and fails the ones left unresponded.
+	retired atomic.Bool
+	// rotating is set when a new stream is being created to replace the stream.
+	rotating atomic.Bool
+	// firstRequestID and lastRequestID bound the IDs of the requests sent on the stream if sent is set. They're
+	// protected by tryLock, and read after the stream is retired.
+	sent           bool
+	firstRequestID uint64
+	lastRequestID  uint64
+}
+
+// onSent records the IDs of the requests sent on the stream, which are sent in the ascending order.
+func (s *batchCommandsStream) onSent(requestIDs []uint64) {
+	if len(requestIDs) == 0 {
+		return
+	}
+	if !s.sent {
+		s.sent, s.firstRequestID = true, requestIDs[0]
+	}
+	s.lastRequestID = requestIDs[len(requestIDs)-1]
 }
 
 func (s *batchCommandsStream) recv() (resp *tikvpb.BatchCommandsResponse, err error) {
@@ -743,6 +1146,14 @@ func (s *batchCommandsStream) recreate(conn *grpc.ClientConn) error {
 	if s.forwardedHost != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, forwardMetadataKey, s.forwardedHost)
 	}
+	if s.credentials != nil {
+		// The credential is attached to the stream by the per-RPC credentials when it's created.
+		cred, err := s.credentials.get(ctx)
+		if err != nil {
+			return err
+		}
+		s.credentialExpiry = cred.Expiry
+	}
 	streamClient, err := tikvClient.BatchCommands(ctx)
 	if err != nil {
 		return errors.WithStack(err)
@@ -771,6 +1182,7 @@ type batchCommandsClient struct {
 	tikvClientCfg config.TiKVClient
 	tikvLoad      *uint64
 	dialTimeout   time.Duration
+	fallback      *batchFallback
 
 	// Increased in each reconnection.
 	// It's used to prevent the connection from reconnecting multiple times
@@ -791,6 +1203,13 @@ type batchCommandsClient struct {
 	eventListener *atomic.Pointer[ClientEventListener]
 
 	metrics *batchConnMetrics
+	// recvLats observes the latency of the responses for the adaptiveBatchSize of the batchConn, nil if the max batch
+	// size is fixed.
+	recvLats *recvLatencies
+
+	credentials *credentialCache
+	// streamsRecycled is set when the idle streams are recycled, it's protected by tryLock.
+	streamsRecycled bool
 }
 
 func (c *batchCommandsClient) isStopped() bool {
@@ -827,6 +1246,11 @@ func (c *batchCommandsClient) send(forwardedHost string, req *tikvpb.BatchComman
 	if forwardedHost != "" {
 		client = c.forwardedClients[forwardedHost]
 	}
+	if needRefresh(client.credentialExpiry, time.Now()) && client.rotating.CompareAndSwap(false, true) {
+		// The credential is still valid for a while, so keep sending on the stream until it's replaced.
+		go c.rotateStream(client)
+	}
+	client.onSent(req.RequestIds)
 	if err := client.Send(req); err != nil {
 		logutil.BgLogger().Info(
 			"sending batch commands meets error",
@@ -835,10 +1259,142 @@ func (c *batchCommandsClient) send(forwardedHost string, req *tikvpb.BatchComman
 			zap.Uint64s("requestIDs", req.RequestIds),
 			zap.Error(err),
 		)
-		c.failRequestsByIDs(err, req.RequestIds) // fast fail requests.
+		if c.tikvClientCfg.DeliverLateBatchResponses {
+			c.waitLateResponses(err, req.RequestIds)
+		} else {
+			c.failRequestsByIDs(err, req.RequestIds) // fast fail requests.
+		}
 	}
 }
 
+// waitLateResponses keeps the async requests failing to be sent by err waiting for their responses until their
+// deadlines, as the error may be ambiguous that the requests have been received by the store. The other requests are
+// failed at once. The waiting requests are still failed when the stream is broken.
+func (c *batchCommandsClient) waitLateResponses(err error, requestIDs []uint64) {
+	now := time.Now()
+	for _, requestID := range requestIDs {
+		value, ok := c.batched.Load(requestID)
+		if !ok {
+			continue
+		}
+		entry := value.(*batchCommandsEntry)
+		if !entry.async() || entry.deadline.IsZero() || !entry.deadline.After(now) {
+			c.failRequest(err, requestID, entry)
+			continue
+		}
+		entry.waitingLate.Store(true)
+		entry.lateTimer.Store(time.AfterFunc(entry.deadline.Sub(now), func() {
+			if c.failRequest(err, requestID, entry) {
+				metrics.TiKVBatchLateResponseCounter.WithLabelValues("expired").Inc()
+			}
+		}))
+		if value, ok := c.batched.Load(requestID); !ok || value != entry {
+			// The request is finished before the timer is set.
+			entry.stopLateTimer()
+		}
+	}
+}
+
+// stopLateTimer stops the timer set by waitLateResponses, if any.
+func (e *batchCommandsEntry) stopLateTimer() {
+	if timer := e.lateTimer.Load(); timer != nil {
+		timer.Stop()
+	}
+}
+
+// rotateStream replaces the stream whose credential is about to expire with a new one created out of the send loop.
+// The old stream is closed after the responses of the requests sent on it are received. The old stream is kept if it
+// fails to create the new one, and the replacement is retried by the next send.
+func (c *batchCommandsClient) rotateStream(old *batchCommandsStream) {
+	defer old.rotating.Store(false)
+	stream, err := c.newBatchStream(old.forwardedHost)
+	if err != nil {
+		logutil.BgLogger().Warn(
+			"replace streaming with new credential fail",
+			zap.String("target", c.target),
+			zap.String("forwardedHost", old.forwardedHost),
+			zap.Error(err),
+		)
+		return
+	}
+	// The new stream is closed if the old one is being re-created, which renews its credential, or no longer in use.
+	replaced := false
+	if c.tryLockForSend() {
+		current := c.client
+		if old.forwardedHost != "" {
+			current = c.forwardedClients[old.forwardedHost]
+		}
+		if current == old && !c.isStopped() {
+			if old.forwardedHost == "" {
+				c.client = stream
+			} else {
+				c.forwardedClients[old.forwardedHost] = stream
+			}
+			c.retireStream(old)
+			replaced = true
+		}
+		c.unlockForSend()
+	}
+	if !replaced {
+		c.retireStream(stream)
+	}
+	go c.batchRecvLoop(c.tikvClientCfg, c.tikvLoad, c.metrics, stream)
+}
+
+// retireStream closes the sending side of the stream, whose batchRecvLoop exits after the responses of the requests
+// sent on it are received.
+func (c *batchCommandsClient) retireStream(stream *batchCommandsStream) {
+	stream.retired.Store(true)
+	if err := stream.CloseSend(); err != nil {
+		logutil.BgLogger().Info("close the retired streaming fail", zap.String("target", c.target), zap.Error(err))
+	}
+}
+
+// failRetiredRequests fails the requests sent on the retired stream that are not responded when it's closed.
+func (c *batchCommandsClient) failRetiredRequests(err error, stream *batchCommandsStream) {
+	if !stream.sent {
+		return
+	}
+	if err == nil || err == io.EOF {
+		err = errors.New("retired batch commands stream is closed")
+	}
+	c.batched.Range(func(key, value interface{}) bool {
+		id, _ := key.(uint64)
+		entry, _ := value.(*batchCommandsEntry)
+		if entry.forwardedHost == stream.forwardedHost && id >= stream.firstRequestID && id <= stream.lastRequestID {
+			entry.streamBroken = true
+			c.failRequest(err, id, entry)
+		}
+		return true
+	})
+}
+
+// recycleStreams closes the streams of the client if it's not re-creating them. It returns whether any stream is
+// closed.
+func (c *batchCommandsClient) recycleStreams() bool {
+	if !c.tryLockForSend() {
+		return false
+	}
+	defer c.unlockForSend()
+	streams := make([]*batchCommandsStream, 0, len(c.forwardedClients)+1)
+	if c.client != nil {
+		streams = append(streams, c.client)
+	}
+	for _, stream := range c.forwardedClients {
+		streams = append(streams, stream)
+	}
+	if len(streams) == 0 {
+		return false
+	}
+	for _, stream := range streams {
+		c.retireStream(stream)
+	}
+	c.client = nil
+	c.forwardedClients = make(map[string]*batchCommandsStream)
+	c.streamsRecycled = true
+	return true
+}
+
 // `failPendingRequests` must be called in locked contexts in order to avoid double closing channels.
 // when enable-forwarding is true, the `forwardedHost` maybe not empty.
 // failPendingRequests fails all pending requests which req.forwardedHost equals to forwardedHost parameter.
@@ -850,12 +1406,16 @@ func (c *batchCommandsClient) send(forwardedHost string, req *tikvpb.BatchComman
 //     2. panic which cause by `send on closed channel`, since failPendingRequests will close the entry.res channel,
 //     but in another batchRecvLoop goroutine,  it may receive the response from forwardedHost store2 and try to send the response to entry.res channel,
 //     then panic by send on closed channel.
-func (c *batchCommandsClient) failPendingRequests(err error, forwardedHost string) {
+//
+// streamBroken tells whether the requests are failed because the stream is broken, in which case the read requests may
+// be resent, see sendBatchRequest.
+func (c *batchCommandsClient) failPendingRequests(err error, forwardedHost string, streamBroken bool) {
 	util.EvalFailpoint("panicInFailPendingRequests")
 	c.batched.Range(func(key, value interface{}) bool {
 		id, _ := key.(uint64)
 		entry, _ := value.(*batchCommandsEntry)
 		if entry.forwardedHost == forwardedHost {
+			entry.streamBroken = streamBroken
 			c.failRequest(err, id, entry)
 		}
 		return true
@@ -886,10 +1446,16 @@ func (c *batchCommandsClient) failRequestsByIDs(err error, requestIDs []uint64)
 	}
 }
 
-func (c *batchCommandsClient) failRequest(err error, requestID uint64, entry *batchCommandsEntry) {
-	c.batched.Delete(requestID)
+// failRequest fails the request and returns true, or returns false if the request is responded or failed concurrently,
+// e.g. when it's waiting for a late response.
+func (c *batchCommandsClient) failRequest(err error, requestID uint64, entry *batchCommandsEntry) bool {
+	if value, ok := c.batched.LoadAndDelete(requestID); !ok || value != entry {
+		return false
+	}
+	entry.stopLateTimer()
 	c.sent.Add(-1)
 	entry.error(err)
+	return true
 }
 
 func (c *batchCommandsClient) waitConnReady() (err error) {
@@ -954,7 +1520,7 @@ func (c *batchCommandsClient) batchRecvLoop(cfg config.TiKVClient, tikvTransport
 				zap.Stack("stack"))
 			logutil.BgLogger().Info("restart batchRecvLoop")
 			go c.batchRecvLoop(cfg, tikvTransportLayerLoad, connMetrics, streamClient)
-		} else {
+		} else if !streamClient.retired.Load() {
 			c.failAsyncRequestsOnClose()
 		}
 	}()
@@ -973,6 +1539,10 @@ func (c *batchCommandsClient) batchRecvLoop(cfg config.TiKVClient, tikvTransport
 			if c.isStopped() {
 				return
 			}
+			if streamClient.retired.Load() {
+				c.failRetiredRequests(err, streamClient)
+				return
+			}
 			logutil.BgLogger().Debug(
 				"batchRecvLoop fails when receiving, needs to reconnect",
 				zap.String("target", c.target),
@@ -980,6 +1550,12 @@ func (c *batchCommandsClient) batchRecvLoop(cfg config.TiKVClient, tikvTransport
 				zap.Error(err),
 			)
 
+			if isBatchCommandsUnsupported(err) {
+				// Fail the pending requests to let them fall back to unary RPCs, and probe it again later.
+				c.fallback.onUnsupported(c.target, err)
+				c.failPendingRequests(err, streamClient.forwardedHost, false)
+				c.fallback.waitProbe(c.isStopped)
+			}
 			now := time.Now()
 			if stopped := c.recreateStreamingClient(err, streamClient, &epoch); stopped {
 				return
@@ -999,36 +1575,70 @@ func (c *batchCommandsClient) batchRecvLoop(cfg config.TiKVClient, tikvTransport
 			c.onHealthFeedback(resp.GetHealthFeedback())
 		}
 
-		responses := resp.GetResponses()
-		for i, requestID := range resp.GetRequestIds() {
-			value, ok := c.batched.Load(requestID)
-			if !ok {
-				// this maybe caused by batchCommandsClient#send meets ambiguous error that request has be sent to TiKV but still report a error.
-				// then TiKV will send response back though stream and reach here.
-				logutil.BgLogger().Warn("batchRecvLoop receives outdated response", zap.Uint64("requestID", requestID), zap.String("forwardedHost", streamClient.forwardedHost))
-				continue
-			}
-			entry := value.(*batchCommandsEntry)
+		c.handleBatchResponse(streamClient, resp, respRecvTime, cfg, tikvTransportLayerLoad)
+		connMetrics.recvLoopProcessDur.Observe(time.Since(recvLoopStartTime).Seconds())
+	}
+}
 
-			atomic.StoreInt64(&entry.recvLat, int64(respRecvTime.Sub(entry.start)))
-			if trace.IsEnabled() {
-				trace.Log(entry.ctx, "rpc", "received")
-			}
-			logutil.Eventf(entry.ctx, "receive %T response with other %d batched requests from %s", responses[i].GetCmd(), len(responses), c.target)
-			if atomic.LoadInt32(&entry.canceled) == 0 {
-				// Put the response only if the request is not canceled.
-				entry.response(responses[i])
-			}
-			c.batched.Delete(requestID)
-			c.sent.Add(-1)
+// handleBatchResponse delivers the responses to the waiting requests.
+func (c *batchCommandsClient) handleBatchResponse(streamClient *batchCommandsStream, resp *tikvpb.BatchCommandsResponse, respRecvTime time.Time, cfg config.TiKVClient, tikvTransportLayerLoad *uint64) {
+	responses := resp.GetResponses()
+	var maxRecvLat time.Duration
+	for i, requestID := range resp.GetRequestIds() {
+		// The request is removed at once, so it's either responded here or failed by others.
+		value, ok := c.batched.LoadAndDelete(requestID)
+		if !ok {
+			// this maybe caused by batchCommandsClient#send meets ambiguous error that request has be sent to TiKV but still report a error.
+			// then TiKV will send response back though stream and reach here.
+			c.onOutdatedResponse(streamClient, requestID, responses[i], cfg)
+			continue
+		}
+		entry := value.(*batchCommandsEntry)
+		if entry.waitingLate.Load() {
+			entry.stopLateTimer()
+			metrics.TiKVBatchLateResponseCounter.WithLabelValues("delivered").Inc()
 		}
 
-		transportLayerLoad := resp.GetTransportLayerLoad()
-		if transportLayerLoad > 0 && cfg.MaxBatchWaitTime > 0 {
-			// We need to consider TiKV load only if batch-wait strategy is enabled.
-			atomic.StoreUint64(tikvTransportLayerLoad, transportLayerLoad)
+		recvLat := respRecvTime.Sub(entry.start)
+		atomic.StoreInt64(&entry.recvLat, int64(recvLat))
+		maxRecvLat = max(maxRecvLat, recvLat)
+		if trace.IsEnabled() {
+			trace.Log(entry.ctx, "rpc", "received")
+		}
+		logutil.Eventf(entry.ctx, "receive %T response with other %d batched requests from %s", responses[i].GetCmd(), len(responses), c.target)
+		if atomic.LoadInt32(&entry.canceled) == 0 {
+			// Put the response only if the request is not canceled.
+			entry.response(responses[i])
+		}
+		c.sent.Add(-1)
+	}
+	if c.recvLats != nil && maxRecvLat > 0 {
+		c.recvLats.observe(maxRecvLat)
+	}
+
+	transportLayerLoad := resp.GetTransportLayerLoad()
+	if transportLayerLoad > 0 && cfg.MaxBatchWaitTime > 0 {
+		// We need to consider TiKV load only if batch-wait strategy is enabled.
+		atomic.StoreUint64(tikvTransportLayerLoad, transportLayerLoad)
+	}
+}
+
+// onOutdatedResponse records the response whose request is already finished and notifies the listener, if any.
+func (c *batchCommandsClient) onOutdatedResponse(streamClient *batchCommandsStream, requestID uint64, resp *tikvpb.BatchCommandsResponse_Response, cfg config.TiKVClient) {
+	metrics.TiKVBatchOutdatedResponseCounter.WithLabelValues(c.target).Inc()
+	cmd := strings.TrimPrefix(fmt.Sprintf("%T", resp.GetCmd()), "*tikvpb.BatchCommandsResponse_Response_")
+	fields := []zap.Field{zap.Uint64("requestID", requestID), zap.String("forwardedHost", streamClient.forwardedHost)}
+	if cfg.LogOutdatedBatchResponseCmd {
+		fields = append(fields, zap.String("cmd", cmd))
+	}
+	logutil.BgLogger().Warn("batchRecvLoop receives outdated response", fields...)
+	if c.eventListener == nil {
+		return
+	}
+	if h := c.eventListener.Load(); h != nil {
+		if l, ok := (*h).(OutdatedResponseListener); ok {
+			l.OnOutdatedResponse(c.target, requestID, cmd)
 		}
-		connMetrics.recvLoopProcessDur.Observe(time.Since(recvLoopStartTime).Seconds())
 	}
 }
 
@@ -1043,6 +1653,10 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 	// blocks other streams trying to recreate.
 	c.lockForRecreate()
 	defer c.unlockForRecreate()
+	if streamClient.retired.Load() {
+		// The stream has been replaced or recycled, stop its batchRecvLoop.
+		return true
+	}
 
 	// Each batchCommandsStream has a batchRecvLoop. There is only one stream waiting for
 	// the connection ready in every epoch to prevent the connection from reconnecting
@@ -1075,7 +1689,7 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 	}
 	*epoch++
 
-	c.failPendingRequests(err, streamClient.forwardedHost) // fail all pending requests.
+	c.failPendingRequests(err, streamClient.forwardedHost, true) // fail all pending requests.
 	b := retry.NewBackofferWithVars(context.Background(), math.MaxInt32, nil)
 	for { // try to re-create the streaming in the loop.
 		if c.isStopped() {
@@ -1095,7 +1709,7 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 }
 
 func (c *batchCommandsClient) newBatchStream(forwardedHost string) (*batchCommandsStream, error) {
-	batchStream := &batchCommandsStream{forwardedHost: forwardedHost}
+	batchStream := &batchCommandsStream{forwardedHost: forwardedHost, credentials: c.credentials}
 	if err := batchStream.recreate(c.conn); err != nil {
 		return nil, err
 	}
@@ -1123,6 +1737,10 @@ func (c *batchCommandsClient) initBatchClient(forwardedHost string) error {
 	} else {
 		c.forwardedClients[forwardedHost] = streamClient
 	}
+	if c.streamsRecycled {
+		c.streamsRecycled = false
+		notifyConnEvent(c.eventListener, c.target, config.BatchConnIdleRecycleStream, connReestablished)
+	}
 	go c.batchRecvLoop(c.tikvClientCfg, c.tikvLoad, c.metrics, streamClient)
 	return nil
 }
@@ -1137,6 +1755,23 @@ func (a *batchConn) Close() {
 	// calling SendRequest and writing batchCommandsCh, if we close it here the
 	// writing goroutine will panic.
 	close(a.closed)
+	batchIdleDetector.unregister(a)
+}
+
+// isResendableRead returns whether the request is a read that can be resent safely after the stream is broken.
+func isResendableRead(req *tikvpb.BatchCommandsRequest_Request) bool {
+	switch req.GetCmd().(type) {
+	case *tikvpb.BatchCommandsRequest_Request_Get,
+		*tikvpb.BatchCommandsRequest_Request_Scan,
+		*tikvpb.BatchCommandsRequest_Request_BatchGet,
+		*tikvpb.BatchCommandsRequest_Request_BufferBatchGet,
+		*tikvpb.BatchCommandsRequest_Request_RawGet,
+		*tikvpb.BatchCommandsRequest_Request_RawBatchGet,
+		*tikvpb.BatchCommandsRequest_Request_RawScan,
+		*tikvpb.BatchCommandsRequest_Request_Coprocessor:
+		return true
+	}
+	return false
 }
 
 func sendBatchRequest(
@@ -1148,19 +1783,31 @@ func sendBatchRequest(
 	timeout time.Duration,
 	priority uint64,
 ) (*tikvrpc.Response, error) {
-	entry := &batchCommandsEntry{
-		ctx:           ctx,
-		req:           req,
-		res:           make(chan *tikvpb.BatchCommandsResponse_Response, 1),
-		forwardedHost: forwardedHost,
-		canceled:      0,
-		err:           nil,
-		pri:           priority,
-		start:         time.Now(),
+	newEntry := func() *batchCommandsEntry {
+		start := time.Now()
+		deadline := entryDeadline(ctx, start, timeout)
+		return &batchCommandsEntry{
+			ctx:           ctx,
+			req:           propagateDeadline(req, deadline, start),
+			res:           make(chan *tikvpb.BatchCommandsResponse_Response, 1),
+			forwardedHost: forwardedHost,
+			canceled:      0,
+			err:           nil,
+			pri:           priority,
+			start:         start,
+			deadline:      deadline,
+		}
+	}
+	entry := newEntry()
+	var reqSize int64
+	if memctl.Enabled() {
+		reqSize = int64(req.Size())
 	}
+	memctl.Consume(memctl.KindBatchQueue, reqSize)
 	timer := time.NewTimer(timeout)
 	defer func() {
 		timer.Stop()
+		memctl.Consume(memctl.KindBatchQueue, -reqSize)
 		if sendLat := atomic.LoadInt64(&entry.sendLat); sendLat > 0 {
 			metrics.BatchRequestDurationSend.Observe(time.Duration(sendLat).Seconds())
 		}
@@ -1168,45 +1815,127 @@ func sendBatchRequest(
 			metrics.BatchRequestDurationRecv.Observe(time.Duration(recvLat).Seconds())
 		}
 		metrics.BatchRequestDurationDone.Observe(time.Since(entry.start).Seconds())
+		if rpcTimelineEnabled.Load() {
+			if timeline, ok := ctx.Value(rpcTimelineKey{}).(*RPCTimeline); ok {
+				timeline.record(entry)
+			}
+		}
 	}()
 
-	select {
-	case batchConn.batchCommandsCh <- entry:
-	case <-ctx.Done():
-		logutil.Logger(ctx).Debug("send request is cancelled",
-			zap.String("to", addr), zap.String("cause", ctx.Err().Error()))
-		return nil, errors.WithStack(ctx.Err())
-	case <-batchConn.closed:
-		logutil.Logger(ctx).Debug("send request is cancelled (batchConn closed)", zap.String("to", addr))
-		return nil, errors.New("batchConn closed")
-	case <-timer.C:
-		return nil, errors.WithMessage(context.DeadlineExceeded, "wait sendLoop")
-	}
-
-	select {
-	case res, ok := <-entry.res:
-		if !ok {
-			return nil, errors.WithStack(entry.err)
+	for {
+		select {
+		case batchConn.batchCommandsCh <- entry:
+		case <-ctx.Done():
+			logutil.Logger(ctx).Debug("send request is cancelled",
+				zap.String("to", addr), zap.String("cause", ctx.Err().Error()))
+			return nil, errors.WithStack(ctx.Err())
+		case <-batchConn.closed:
+			logutil.Logger(ctx).Debug("send request is cancelled (batchConn closed)", zap.String("to", addr))
+			return nil, errors.New("batchConn closed")
+		case <-timer.C:
+			return nil, errors.WithMessage(context.DeadlineExceeded, "wait sendLoop")
 		}
-		return tikvrpc.FromBatchCommandsResponse(res)
-	case <-ctx.Done():
-		atomic.StoreInt32(&entry.canceled, 1)
-		logutil.Logger(ctx).Debug("wait response is cancelled",
-			zap.String("to", addr), zap.String("cause", ctx.Err().Error()))
-		return nil, errors.WithStack(ctx.Err())
-	case <-batchConn.closed:
-		atomic.StoreInt32(&entry.canceled, 1)
-		logutil.Logger(ctx).Debug("wait response is cancelled (batchConn closed)", zap.String("to", addr))
-		return nil, errors.New("batchConn closed")
-	case <-timer.C:
-		atomic.StoreInt32(&entry.canceled, 1)
-		reason := fmt.Sprintf("wait recvLoop timeout, timeout:%s", timeout)
-		if sendLat := atomic.LoadInt64(&entry.sendLat); sendLat > 0 {
-			reason += fmt.Sprintf(", send:%s", util.FormatDuration(time.Duration(sendLat)))
-			if recvLat := atomic.LoadInt64(&entry.recvLat); recvLat > 0 {
-				reason += fmt.Sprintf(", recv:%s", util.FormatDuration(time.Duration(recvLat-sendLat)))
+
+		select {
+		case res, ok := <-entry.res:
+			if !ok {
+				// The read requests failed by a broken stream are resent over the recreated one until the timeout.
+				if entry.streamBroken && isResendableRead(req) && config.GetGlobalConfig().TiKVClient.ResendReadsOnStreamBroken {
+					logutil.Logger(ctx).Debug("resend request after the stream is broken",
+						zap.String("to", addr), zap.Error(entry.err))
+					metrics.TiKVBatchClientResendCounter.Inc()
+					entry = newEntry()
+					continue
+				}
+				return nil, errors.WithStack(entry.err)
+			}
+			return tikvrpc.FromBatchCommandsResponse(res)
+		case <-ctx.Done():
+			atomic.StoreInt32(&entry.canceled, 1)
+			logutil.Logger(ctx).Debug("wait response is cancelled",
+				zap.String("to", addr), zap.String("cause", ctx.Err().Error()))
+			return nil, errors.WithStack(ctx.Err())
+		case <-batchConn.closed:
+			atomic.StoreInt32(&entry.canceled, 1)
+			logutil.Logger(ctx).Debug("wait response is cancelled (batchConn closed)", zap.String("to", addr))
+			return nil, errors.New("batchConn closed")
+		case <-timer.C:
+			atomic.StoreInt32(&entry.canceled, 1)
+			reason := fmt.Sprintf("wait recvLoop timeout, timeout:%s", timeout)
+			if sendLat := atomic.LoadInt64(&entry.sendLat); sendLat > 0 {
+				reason += fmt.Sprintf(", send:%s", util.FormatDuration(time.Duration(sendLat)))
+				if recvLat := atomic.LoadInt64(&entry.recvLat); recvLat > 0 {
+					reason += fmt.Sprintf(", recv:%s", util.FormatDuration(time.Duration(recvLat-sendLat)))
+				}
 			}
+			return nil, errors.WithMessage(context.DeadlineExceeded, reason)
 		}
-		return nil, errors.WithMessage(context.DeadlineExceeded, reason)
+	}
+}
+
+type rpcTimelineKey struct{}
+
+// rpcTimelineEnabled tells whether any request may record its timeline, so the others don't look up the context.
+var rpcTimelineEnabled atomic.Bool
+
+// EnableRPCTimeline sets whether the timelines of the requests sent with WithRPCTimeline are recorded.
+func EnableRPCTimeline(enabled bool) {
+	rpcTimelineEnabled.Store(enabled)
+}
+
+// RPCTimeline is how long a batch commands request spends in each stage on the client side.
+type RPCTimeline struct {
+	// Queue is the time waiting in the batch queue before being sent.
+	Queue time.Duration
+	// Wire is the time from being sent to its response being received, including the time on the server.
+	Wire time.Duration
+	// Recv is the time from its response being received to being returned.
+	Recv time.Duration
+}
+
+// WithRPCTimeline returns a copy of ctx that lets the batch commands request sent with it record its timeline to
+// timeline. It's not recorded if the request isn't sent by batch commands.
+func WithRPCTimeline(ctx context.Context, timeline *RPCTimeline) context.Context {
+	return context.WithValue(ctx, rpcTimelineKey{}, timeline)
+}
+
+// The results of the batch requests recorded by observeIntendedLatency.
+const (
+	intendedResultOK       = "ok"
+	intendedResultCanceled = "canceled"
+	intendedResultTimeout  = "timeout"
+	intendedResultError    = "error"
+)
+
+// observeIntendedLatency records the latency of a batch request from intendedStart, when the caller sends it, to when
+// it's finished in whatever way, if config.TiKVClient.EnableIntendedLatencyMetrics is set.
+func observeIntendedLatency(cmd tikvrpc.CmdType, intendedStart time.Time, err error) {
+	if !config.GetGlobalConfig().TiKVClient.EnableIntendedLatencyMetrics {
+		return
+	}
+	result := intendedResultOK
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		result = intendedResultCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		result = intendedResultTimeout
+	default:
+		result = intendedResultError
+	}
+	metrics.TiKVBatchRequestIntendedHistogram.WithLabelValues(cmd.String(), result).Observe(time.Since(intendedStart).Seconds())
+}
+
+func (t *RPCTimeline) record(entry *batchCommandsEntry) {
+	total := time.Since(entry.start)
+	sendLat := time.Duration(atomic.LoadInt64(&entry.sendLat))
+	recvLat := time.Duration(atomic.LoadInt64(&entry.recvLat))
+	switch {
+	case sendLat == 0:
+		t.Queue = total
+	case recvLat == 0:
+		t.Queue, t.Wire = sendLat, total-sendLat
+	default:
+		t.Queue, t.Wire, t.Recv = sendLat, recvLat-sendLat, total-recvLat
 	}
 }
@@ -59,6 +59,12 @@ type reqCollapse struct {
 func NewReqCollapse(client Client) Client {
 	return &reqCollapse{client}
 }
+
+// unwrap implements wrappedClient.
+func (r reqCollapse) unwrap() Client {
+	return r.Client
+}
+
 func (r reqCollapse) Close() error {
 	if r.Client == nil {
 		panic("client should not be nil")
@@ -86,7 +92,7 @@ func (r reqCollapse) SendRequestAsync(ctx context.Context, addr string, req *tik
 		copyReq := *req
 		rsC := resolveRegionSf.DoChan(key, func() (interface{}, error) {
 			// resolveRegionSf will call this function in a goroutine, thus use SendRequest directly.
-			return r.Client.SendRequest(context.Background(), addr, &copyReq, ReadTimeoutShort)
+			return r.Client.SendRequest(context.Background(), addr, &copyReq, DefaultTimeout(copyReq.Type))
 		})
 		// waiting the response in another goroutine.
 		cb.Executor().Go(func() {
@@ -136,7 +142,7 @@ func (r reqCollapse) collapse(ctx context.Context, key string, sf *singleflight.
 		copyReq.Req = proto.Clone(req.ResolveLock())
 	}
 	rsC := sf.DoChan(key, func() (interface{}, error) {
-		return r.Client.SendRequest(context.Background(), addr, &copyReq, ReadTimeoutShort) // use resolveLock timeout.
+		return r.Client.SendRequest(context.Background(), addr, &copyReq, DefaultTimeout(copyReq.Type)) // use the default timeout of the request type.
 	})
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
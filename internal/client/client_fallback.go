@@ -0,0 +1,66 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// batchCommandsProbeInterval is the interval to probe BatchCommands again after the target is found not to support it.
+var batchCommandsProbeInterval = time.Minute
+
+// batchFallback records that the requests to a target fall back to unary RPCs, because the target, e.g. a proxy or
+// a server of an incompatible version, doesn't support BatchCommands.
+type batchFallback struct {
+	// until is the unix nano time until which the requests fall back. BatchCommands is probed again after it.
+	until atomic.Int64
+}
+
+// active returns whether the requests should be sent by unary RPCs.
+func (f *batchFallback) active() bool {
+	return time.Now().UnixNano() < f.until.Load()
+}
+
+func (f *batchFallback) onUnsupported(target string, err error) {
+	if !f.active() {
+		logutil.BgLogger().Warn("target doesn't support BatchCommands, fall back to unary RPCs",
+			zap.String("target", target), zap.Duration("probeInterval", batchCommandsProbeInterval), zap.Error(err))
+	}
+	f.until.Store(time.Now().Add(batchCommandsProbeInterval).UnixNano())
+}
+
+// waitProbe waits until it's time to probe BatchCommands again, or stopped returns true.
+func (f *batchFallback) waitProbe(stopped func() bool) {
+	for !stopped() {
+		d := time.Until(time.Unix(0, f.until.Load()))
+		if d <= 0 {
+			return
+		}
+		time.Sleep(min(d, time.Second))
+	}
+}
+
+// isBatchCommandsUnsupported returns whether the error means the target doesn't support BatchCommands. The requests
+// failed by it are never sent to the target, so they can be retried by unary RPCs safely.
+func isBatchCommandsUnsupported(err error) bool {
+	return err != nil && status.Code(errors.Cause(err)) == codes.Unimplemented
+}
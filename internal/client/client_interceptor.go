@@ -19,6 +19,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"github.com/tikv/client-go/v2/internal/resourcecontrol"
 	"github.com/tikv/client-go/v2/tikvrpc"
@@ -43,9 +44,31 @@ func NewInterceptedClient(client Client) Client {
 	return interceptedClient{client}
 }
 
+// overrideResourceGroup sets the resource group of the request to the one of ctx set by util.WithResourceGroupName,
+// which overrides the resource group of the snapshot or the transaction sending the request.
+func overrideResourceGroup(ctx context.Context, req *tikvrpc.Request) {
+	name := util.ResourceGroupNameFromCtx(ctx)
+	if len(name) == 0 || req.GetResourceControlContext().GetResourceGroupName() == name {
+		return
+	}
+	// The context may be shared by the requests of a snapshot or a transaction, so it's copied instead of modified.
+	rc := &kvrpcpb.ResourceControlContext{ResourceGroupName: name}
+	if old := req.GetResourceControlContext(); old != nil {
+		rc.OverridePriority = old.OverridePriority
+		rc.Penalty = old.Penalty
+	}
+	req.ResourceControlContext = rc
+}
+
+func (r interceptedClient) unwrap() Client {
+	return r.Client
+}
+
 func (r interceptedClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (resp *tikvrpc.Response, err error) {
 	var ruDetails *util.RUDetails
 
+	overrideResourceGroup(ctx, req)
+
 	resourceGroupName, resourceControlInterceptor, reqInfo := getResourceControlInfo(ctx, req)
 	if resourceControlInterceptor != nil {
 		consumption, penalty, waitDuration, priority, err := resourceControlInterceptor.OnRequestWait(ctx, resourceGroupName, reqInfo)
@@ -91,6 +114,7 @@ func (r interceptedClient) SendRequest(ctx context.Context, addr string, req *ti
 func (r interceptedClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
 	// since all async requests processed by one runloop share the same resource group, if the quota is exceeded, all
 	// requests/responses shall wait for the tokens, thus it's ok to call OnRequestWait/OnResponseWait synchronously.
+	overrideResourceGroup(ctx, req)
 	resourceGroupName, resourceControlInterceptor, reqInfo := getResourceControlInfo(ctx, req)
 	if resourceControlInterceptor != nil {
 		consumption, penalty, waitDuration, priority, err := resourceControlInterceptor.OnRequestWait(ctx, resourceGroupName, reqInfo)
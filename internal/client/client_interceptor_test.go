@@ -20,9 +20,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/stretchr/testify/assert"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/util"
 	"github.com/tikv/client-go/v2/util/async"
 )
 
@@ -101,3 +103,56 @@ func TestAppendChainedInterceptor(t *testing.T) {
 	chain = interceptor.ChainRPCInterceptors(chain, mkInterceptorFn(1))
 	checkChained(chain, 5, []int{0, 2, 3, 4, 1})
 }
+
+func TestResourceGroupOverride(t *testing.T) {
+	var names []string
+	client := NewInterceptedClient(emptyClient{})
+	ctx := interceptor.WithRPCInterceptor(context.Background(), interceptor.NewRPCInterceptor("test", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			names = append(names, req.GetResourceControlContext().GetResourceGroupName())
+			return next(target, req)
+		}
+	}))
+
+	shared := &kvrpcpb.ResourceControlContext{ResourceGroupName: "rg1", OverridePriority: 8}
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}, kvrpcpb.Context{ResourceControlContext: shared})
+	_, _ = client.SendRequest(ctx, "", req, 0)
+	_, _ = client.SendRequest(util.WithResourceGroupName(ctx, "rg2"), "", req, 0)
+	assert.Equal(t, []string{"rg1", "rg2"}, names)
+	assert.Equal(t, uint64(8), req.GetResourceControlContext().GetOverridePriority())
+	// The context shared by other requests isn't modified.
+	assert.Equal(t, "rg1", shared.ResourceGroupName)
+
+	// The requests without a resource group are overridden too.
+	req = tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{})
+	_, _ = client.SendRequest(util.WithResourceGroupName(ctx, "rg3"), "", req, 0)
+	assert.Equal(t, "rg3", req.GetResourceControlContext().GetResourceGroupName())
+}
+
+func TestMetadataClient(t *testing.T) {
+	var sources, aliases []string
+	client := NewMetadataClient(NewInterceptedClient(emptyClient{}), ClientMetadata{AppName: "app", InstanceID: "pod-1", Version: "v1"})
+	ctx := interceptor.WithRPCInterceptor(context.Background(), interceptor.NewRPCInterceptor("test", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			sources = append(sources, req.RequestSource)
+			aliases = append(aliases, req.SourceStmt.GetSessionAlias())
+			return next(target, req)
+		}
+	}))
+
+	shared := &kvrpcpb.SourceStmt{ConnectionId: 1}
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}, kvrpcpb.Context{RequestSource: "leader_internal_gc", SourceStmt: shared})
+	_, _ = client.SendRequest(ctx, "", req, 0)
+	// The retried request isn't prefixed again.
+	_, _ = client.SendRequest(ctx, "", req, 0)
+	assert.Equal(t, []string{"app_leader_internal_gc", "app_leader_internal_gc"}, sources)
+	assert.Equal(t, []string{"app/pod-1/v1", "app/pod-1/v1"}, aliases)
+	assert.Equal(t, uint64(1), req.SourceStmt.ConnectionId)
+	// The source statement shared by other requests isn't modified.
+	assert.Empty(t, shared.SessionAlias)
+
+	// The session alias set by the user is kept.
+	req = tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}, kvrpcpb.Context{SourceStmt: &kvrpcpb.SourceStmt{SessionAlias: "s1"}})
+	_, _ = client.SendRequest(ctx, "", req, 0)
+	assert.Equal(t, "s1", aliases[2])
+}
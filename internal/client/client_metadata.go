@@ -0,0 +1,93 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/async"
+)
+
+// ClientMetadata identifies the application instance sending the requests to TiKV.
+type ClientMetadata struct {
+	// AppName is the name of the application, which prefixes the request source of the requests.
+	AppName string
+	// InstanceID identifies the instance of the application, e.g. the host name or the pod name.
+	InstanceID string
+	// Version is the version of the application.
+	Version string
+}
+
+// sessionAlias returns the identity of the instance set as the session alias of the requests, e.g. "app/pod-1/v1.2".
+func (md ClientMetadata) sessionAlias() string {
+	parts := make([]string, 0, 3)
+	for _, s := range []string{md.AppName, md.InstanceID, md.Version} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+type metadataClient struct {
+	Client
+	sourcePrefix string
+	alias        string
+}
+
+// NewMetadataClient creates a Client injecting the metadata into the context of every request. The request source is
+// prefixed by the app name, e.g. "myapp_leader_internal_gc", which keeps the last part used by TiKV for limit control,
+// and the identity of the instance is set as the session alias of the source statement if the request has none, so
+// the slow logs and the dashboards of TiKV can attribute the requests to the application instance.
+func NewMetadataClient(c Client, md ClientMetadata) Client {
+	mc := &metadataClient{Client: c, alias: md.sessionAlias()}
+	if md.AppName != "" {
+		mc.sourcePrefix = md.AppName + "_"
+	}
+	return mc
+}
+
+func (c *metadataClient) unwrap() Client {
+	return c.Client
+}
+
+func (c *metadataClient) inject(req *tikvrpc.Request) {
+	// A retried request may be sent again without patching the request source, so it's only prefixed once.
+	if c.sourcePrefix != "" && !strings.HasPrefix(req.RequestSource, c.sourcePrefix) {
+		req.RequestSource = c.sourcePrefix + req.RequestSource
+	}
+	if c.alias != "" && req.SourceStmt.GetSessionAlias() == "" {
+		// The source statement may be shared by other requests, so it's copied instead of modified.
+		stmt := &kvrpcpb.SourceStmt{SessionAlias: c.alias}
+		if old := req.SourceStmt; old != nil {
+			stmt.StartTs, stmt.ConnectionId, stmt.StmtId = old.StartTs, old.ConnectionId, old.StmtId
+		}
+		req.SourceStmt = stmt
+	}
+}
+
+func (c *metadataClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	c.inject(req)
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func (c *metadataClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
+	c.inject(req)
+	c.Client.SendRequestAsync(ctx, addr, req, cb)
+}
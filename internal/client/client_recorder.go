@@ -0,0 +1,336 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto" //nolint:staticcheck
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/async"
+	"github.com/tikv/client-go/v2/util/redact"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RPCRecord is a request sent by the client and its response, recorded by Recorder. The messages are encoded in
+// protobuf.
+type RPCRecord struct {
+	Time     time.Time `json:"time"`
+	Addr     string    `json:"addr"`
+	Type     string    `json:"type"`
+	ReqType  string    `json:"req_type"`
+	Req      []byte    `json:"req"`
+	RespType string    `json:"resp_type,omitempty"`
+	Resp     []byte    `json:"resp,omitempty"`
+	Err      string    `json:"err,omitempty"`
+	// Code is the gRPC status code of Err if it's a gRPC status, whose message is Err then.
+	Code     codes.Code    `json:"code,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// keyFields are the names of the bytes fields that carry the keys, which are redacted if redact log is enabled. The
+// other bytes fields, e.g. the values, previous values of CAS, short values of locks and coprocessor data, are removed
+// from the records unless the recorder keeps the values.
+var keyFields = map[string]struct{}{
+	"Key":               {},
+	"Keys":              {},
+	"StartKey":          {},
+	"EndKey":            {},
+	"Start":             {},
+	"End":               {},
+	"Primary":           {},
+	"PrimaryKey":        {},
+	"PrimaryLock":       {},
+	"Secondaries":       {},
+	"LockKey":           {},
+	"DeadlockKey":       {},
+	"SplitKey":          {},
+	"SplitKeys":         {},
+	"CompactedStartKey": {},
+	"CompactedEndKey":   {},
+}
+
+// gogoMessage is the protobuf message generated by gogo, i.e. the messages of kvproto.
+type gogoMessage interface {
+	proto.Message
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// encodeMessage encodes the message, removing the values unless keepValues is set, and redacting the keys if redact
+// log is enabled.
+func encodeMessage(msg any, keepValues bool) (string, []byte, error) {
+	m, ok := msg.(gogoMessage)
+	if !ok {
+		// Stream responses aren't recorded.
+		return "", nil, nil
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	name := proto.MessageName(m)
+	redactKeys := redact.NeedRedact()
+	if keepValues && !redactKeys {
+		return name, data, nil
+	}
+	sanitized := reflect.New(reflect.TypeOf(m).Elem()).Interface().(gogoMessage)
+	if err = sanitized.Unmarshal(data); err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	sanitize(reflect.ValueOf(sanitized), keepValues, redactKeys)
+	data, err = sanitized.Marshal()
+	return name, data, errors.WithStack(err)
+}
+
+// sanitize removes the values and redacts the keys in the message and its nested messages in place.
+func sanitize(v reflect.Value, keepValues, redactKeys bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			sanitize(v.Elem(), keepValues, redactKeys)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				sanitize(v.Index(i), keepValues, redactKeys)
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || strings.HasPrefix(field.Name, "XXX_") {
+				continue
+			}
+			f := v.Field(i)
+			_, isKey := keyFields[field.Name]
+			isValue := !isKey
+			switch {
+			case field.Type == reflect.TypeOf([]byte(nil)):
+				if isValue && !keepValues {
+					f.SetBytes(nil)
+				} else if redactKeys && f.Len() > 0 {
+					f.SetBytes(redact.KeyBytes(nil))
+				}
+			case field.Type == reflect.TypeOf([][]byte(nil)):
+				for j := 0; j < f.Len(); j++ {
+					if isValue && !keepValues {
+						f.Index(j).SetBytes(nil)
+					} else if redactKeys && f.Index(j).Len() > 0 {
+						f.Index(j).SetBytes(redact.KeyBytes(nil))
+					}
+				}
+			default:
+				sanitize(f, keepValues, redactKeys)
+			}
+		}
+	}
+}
+
+// Recorder records the requests sent by the clients wrapped by it and their responses as JSON lines. The values are
+// removed from the records unless keepValues is set, and the keys are redacted if redact log is enabled.
+type Recorder struct {
+	keepValues bool
+	mu         struct {
+		sync.Mutex
+		w      *bufio.Writer
+		enc    *json.Encoder
+		closed bool
+		err    error
+	}
+}
+
+// NewRecorder creates a Recorder writing to w.
+func NewRecorder(w io.Writer, keepValues bool) *Recorder {
+	r := &Recorder{keepValues: keepValues}
+	r.mu.w = bufio.NewWriter(w)
+	r.mu.enc = json.NewEncoder(r.mu.w)
+	return r
+}
+
+func (r *Recorder) record(start time.Time, addr string, req *tikvrpc.Request, resp *tikvrpc.Response, err error) {
+	record := RPCRecord{Time: start, Addr: addr, Type: req.Type.String(), Duration: time.Since(start)}
+	var encodeErr error
+	if record.ReqType, record.Req, encodeErr = encodeMessage(req.Req, r.keepValues); encodeErr != nil {
+		return
+	}
+	if resp != nil {
+		if record.RespType, record.Resp, encodeErr = encodeMessage(resp.Resp, r.keepValues); encodeErr != nil {
+			return
+		}
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		st := grpcErr.GRPCStatus()
+		record.Err, record.Code = st.Message(), st.Code()
+	} else if err != nil {
+		record.Err = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mu.closed || r.mu.err != nil {
+		return
+	}
+	r.mu.err = r.mu.enc.Encode(&record)
+}
+
+// Close stops recording and flushes the records. It returns the first error of writing the records.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mu.closed {
+		return r.mu.err
+	}
+	r.mu.closed = true
+	if r.mu.err == nil {
+		r.mu.err = r.mu.w.Flush()
+	}
+	return errors.WithStack(r.mu.err)
+}
+
+// WrapClient returns a Client that records the requests sent by c.
+func (r *Recorder) WrapClient(c Client) Client {
+	return &recordingClient{Client: c, recorder: r}
+}
+
+// Unwrap returns the client wrapped by WrapClient of the recorder, or c itself if it's not.
+func (r *Recorder) Unwrap(c Client) Client {
+	if rc, ok := c.(*recordingClient); ok && rc.recorder == r {
+		return rc.Client
+	}
+	return c
+}
+
+type recordingClient struct {
+	Client
+	recorder *Recorder
+}
+
+func (c *recordingClient) unwrap() Client {
+	return c.Client
+}
+
+func (c *recordingClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	start := time.Now()
+	resp, err := c.Client.SendRequest(ctx, addr, req, timeout)
+	c.recorder.record(start, addr, req, resp, err)
+	return resp, err
+}
+
+func (c *recordingClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
+	start := time.Now()
+	cb.Inject(func(resp *tikvrpc.Response, err error) (*tikvrpc.Response, error) {
+		c.recorder.record(start, addr, req, resp, err)
+		return resp, err
+	})
+	c.Client.SendRequestAsync(ctx, addr, req, cb)
+}
+
+// ReplayClient is a Client that responds to the requests with the responses recorded by Recorder. A request is
+// responded by the earliest unused record of the same type, so the anomalies, e.g. the region errors, key errors and
+// RPC errors, happen in the same order as they were recorded. The addresses and the contents of the requests aren't
+// matched, so the records can be replayed against a mock cluster.
+type ReplayClient struct {
+	mu      sync.Mutex
+	records map[string][]*RPCRecord
+}
+
+// NewReplayClient creates a ReplayClient from the records read from r.
+func NewReplayClient(r io.Reader) (*ReplayClient, error) {
+	c := &ReplayClient{records: make(map[string][]*RPCRecord)}
+	dec := json.NewDecoder(r)
+	for {
+		record := &RPCRecord{}
+		if err := dec.Decode(record); err == io.EOF {
+			return c, nil
+		} else if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c.records[record.Type] = append(c.records[record.Type], record)
+	}
+}
+
+// Remaining returns the number of the records that haven't been replayed.
+func (c *ReplayClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, records := range c.records {
+		n += len(records)
+	}
+	return n
+}
+
+// SendRequest implements Client.
+func (c *ReplayClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	typ := req.Type.String()
+	c.mu.Lock()
+	records := c.records[typ]
+	if len(records) == 0 {
+		c.mu.Unlock()
+		return nil, errors.Errorf("no recorded response for %s request to %s", typ, addr)
+	}
+	record := records[0]
+	c.records[typ] = records[1:]
+	c.mu.Unlock()
+
+	if record.Code != codes.OK {
+		return nil, errors.WithStack(status.Error(record.Code, record.Err))
+	}
+	if record.Err != "" {
+		return nil, errors.New(record.Err)
+	}
+	if record.RespType == "" {
+		return nil, errors.Errorf("response of %s request isn't recorded", typ)
+	}
+	t := proto.MessageType(record.RespType)
+	if t == nil {
+		return nil, errors.Errorf("unknown response type %s", record.RespType)
+	}
+	msg, ok := reflect.New(t.Elem()).Interface().(gogoMessage)
+	if !ok {
+		return nil, errors.Errorf("unsupported response type %s", record.RespType)
+	}
+	if err := msg.Unmarshal(record.Resp); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &tikvrpc.Response{Resp: msg}, nil
+}
+
+// SendRequestAsync implements Client.
+func (c *ReplayClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
+	go func() {
+		cb.Schedule(c.SendRequest(ctx, addr, req, 0))
+	}()
+}
+
+// Close implements Client.
+func (c *ReplayClient) Close() error { return nil }
+
+// CloseAddr implements Client.
+func (c *ReplayClient) CloseAddr(string) error { return nil }
+
+// SetEventListener implements Client.
+func (c *ReplayClient) SetEventListener(ClientEventListener) {}
@@ -37,9 +37,12 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
+	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -53,16 +56,23 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/client/mockserver"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util/async"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
 )
 
 func TestConn(t *testing.T) {
@@ -126,6 +136,114 @@ func TestCancelTimeoutRetErr(t *testing.T) {
 	assert.Equal(t, errors.Cause(err), context.DeadlineExceeded)
 }
 
+func TestResendReadsOnStreamBroken(t *testing.T) {
+	a := newBatchConn(1, 1, nil)
+	done := make(chan struct{})
+	defer close(done)
+	// The first attempt of every request is failed by a broken stream, and the others succeed.
+	go func() {
+		for i := 0; ; i++ {
+			var entry *batchCommandsEntry
+			select {
+			case entry = <-a.batchCommandsCh:
+			case <-done:
+				return
+			}
+			if i%2 == 0 {
+				entry.streamBroken = true
+				entry.error(errors.New("stream broken"))
+			} else {
+				entry.response(&tikvpb.BatchCommandsResponse_Response{Cmd: &tikvpb.BatchCommandsResponse_Response_Get{Get: &kvrpcpb.GetResponse{}}})
+			}
+		}
+	}()
+	get := &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Get{Get: &kvrpcpb.GetRequest{}}}
+	prewrite := &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Prewrite{Prewrite: &kvrpcpb.PrewriteRequest{}}}
+
+	_, err := sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.Error(t, err)
+	_, err = sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.ResendReadsOnStreamBroken = true
+	})()
+	_, err = sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+	// The writes are never resent.
+	_, err = sendBatchRequest(context.Background(), "", "", a, prewrite, time.Second, 0)
+	require.Error(t, err)
+	_, err = sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+}
+
+func TestPropagateBatchDeadline(t *testing.T) {
+	a := newBatchConn(1, 1, nil)
+	done := make(chan struct{})
+	defer close(done)
+	// sent receives the max execution duration of the requests sent in the batch.
+	sent := make(chan uint64, 1)
+	go func() {
+		for {
+			select {
+			case entry := <-a.batchCommandsCh:
+				sent <- batchRequestContext(entry.req).GetMaxExecutionDurationMs()
+				entry.response(&tikvpb.BatchCommandsResponse_Response{Cmd: &tikvpb.BatchCommandsResponse_Response_Get{Get: &kvrpcpb.GetResponse{}}})
+			case <-done:
+				return
+			}
+		}
+	}()
+	newGet := func(maxExecutionDurationMs uint64) (*tikvpb.BatchCommandsRequest_Request, *kvrpcpb.Context) {
+		rpcCtx := &kvrpcpb.Context{MaxExecutionDurationMs: maxExecutionDurationMs}
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Get{Get: &kvrpcpb.GetRequest{Context: rpcCtx}}}, rpcCtx
+	}
+
+	get, rpcCtx := newGet(0)
+	_, err := sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+	require.Zero(t, <-sent)
+	require.Zero(t, rpcCtx.MaxExecutionDurationMs)
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.PropagateBatchDeadline = true
+	})()
+	// The limit is only set on the request sent in the batch, the request of the caller is reused by the retries.
+	get, rpcCtx = newGet(0)
+	_, err = sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+	limit := <-sent
+	require.LessOrEqual(t, limit, uint64(1000))
+	require.Greater(t, limit, uint64(900))
+	require.Zero(t, rpcCtx.MaxExecutionDurationMs)
+	require.Same(t, rpcCtx, get.GetGet().Context)
+
+	// The deadline of the context is earlier than the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	get, rpcCtx = newGet(0)
+	_, err = sendBatchRequest(ctx, "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+	limit = <-sent
+	require.LessOrEqual(t, limit, uint64(200))
+	require.Positive(t, limit)
+	require.Zero(t, rpcCtx.MaxExecutionDurationMs)
+
+	// The shorter max execution duration is kept.
+	get, rpcCtx = newGet(10)
+	_, err = sendBatchRequest(context.Background(), "", "", a, get, time.Second, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), <-sent)
+	require.Equal(t, uint64(10), rpcCtx.MaxExecutionDurationMs)
+
+	// The requests without a context are sent as is.
+	empty := &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Empty{Empty: &tikvpb.BatchCommandsEmptyRequest{}}}
+	require.Nil(t, batchRequestContext(empty))
+	_, err = sendBatchRequest(context.Background(), "", "", a, empty, time.Second, 0)
+	require.NoError(t, err)
+	require.Zero(t, <-sent)
+}
+
 func TestSendWhenReconnect(t *testing.T) {
 	server, port := mockserver.StartMockTikvService()
 	require.True(t, port > 0)
@@ -399,6 +517,349 @@ func TestForwardMetadataByBatchCommands(t *testing.T) {
 	assert.Equal(t, atomic.LoadUint64(&checkCnt), uint64(2))
 }
 
+type admissionFunc func(ctx context.Context, info AdmissionInfo) (uint64, error)
+
+func (f admissionFunc) Admit(ctx context.Context, info AdmissionInfo) (uint64, error) {
+	return f(ctx, info)
+}
+
+func TestAdmissionController(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+	})()
+	var infos []AdmissionInfo
+	rejected := errors.New("too busy")
+	rpcClient := NewRPCClient(WithAdmissionController(admissionFunc(func(ctx context.Context, info AdmissionInfo) (uint64, error) {
+		infos = append(infos, info)
+		if info.Type == tikvrpc.CmdPrewrite {
+			return 0, rejected
+		}
+		return info.Priority + 1, nil
+	})))
+	defer rpcClient.Close()
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{}, kvrpcpb.Context{Peer: &metapb.Peer{StoreId: 1}})
+	_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, AdmissionInfo{Type: tikvrpc.CmdEmpty, Addr: addr, StoreID: 1}, infos[0])
+
+	req = tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{})
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	var errRejected *tikverr.ErrRequestRejected
+	require.ErrorAs(t, err, &errRejected)
+	require.ErrorIs(t, err, rejected)
+	require.Len(t, infos, 2)
+
+	// The requests not sent by batch commands aren't admitted.
+	req = tikvrpc.NewRequest(tikvrpc.CmdCopStream, &coprocessor.Request{})
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Len(t, infos, 2)
+}
+
+func TestSLOShaper(t *testing.T) {
+	_, err := NewSLOShaper(SLOShaperConfig{Classes: []SLOClass{{Name: "oltp"}}})
+	require.Error(t, err)
+	_, err = NewSLOShaper(SLOShaperConfig{Classes: []SLOClass{{Name: "oltp", TargetLatency: time.Millisecond}}, DefaultClass: "olap"})
+	require.Error(t, err)
+
+	shaper, err := NewSLOShaper(SLOShaperConfig{
+		Classes: []SLOClass{
+			{Name: "oltp", TargetLatency: 10 * time.Millisecond, Priority: 2},
+			{Name: "olap", TargetLatency: time.Second},
+		},
+		DefaultClass:         "olap",
+		ThrottledConcurrency: 1,
+		ProtectWindow:        time.Minute,
+	})
+	require.Nil(t, err)
+	oltp, olap := WithSLOClass(context.Background(), "oltp"), context.Background()
+	info := AdmissionInfo{Addr: "store1"}
+
+	pri, err := shaper.Admit(oltp, info)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), pri)
+	pri, err = shaper.Admit(olap, info)
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), pri)
+
+	// The oltp class misses its target on store1, so the olap class is throttled there but not on store2.
+	shaper.Done(oltp, info, 50*time.Millisecond, nil)
+	_, err = shaper.Admit(olap, AdmissionInfo{Addr: "store2"})
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(olap, 50*time.Millisecond)
+	_, err = shaper.Admit(ctx, info)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The throttled request is admitted once a running request of its class finishes.
+	admitted := make(chan struct{})
+	go func() {
+		_, err := shaper.Admit(olap, info)
+		assert.Nil(t, err)
+		close(admitted)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	shaper.Done(olap, info, time.Millisecond, nil)
+	select {
+	case <-admitted:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "throttled request isn't admitted")
+	}
+}
+
+func TestSLOShaperFeedback(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+	})()
+	shaper, err := NewSLOShaper(SLOShaperConfig{Classes: []SLOClass{{Name: "oltp", TargetLatency: time.Minute}}})
+	require.Nil(t, err)
+	rpcClient := NewRPCClient(WithAdmissionController(shaper))
+	defer rpcClient.Close()
+
+	readMet := func() float64 {
+		var m dto.Metric
+		require.Nil(t, metrics.TiKVSLORequestCounter.WithLabelValues("oltp", "met").Write(&m))
+		return m.GetCounter().GetValue()
+	}
+	before := readMet()
+	ctx := WithSLOClass(context.Background(), "oltp")
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err = rpcClient.SendRequest(ctx, addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, before+1, readMet())
+	shaper.mu.Lock()
+	require.Equal(t, 0, shaper.stores[addr].running["oltp"])
+	shaper.mu.Unlock()
+
+	// The requests without a class aren't shaped if there is no default class.
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, before+1, readMet())
+}
+
+func TestCustomInterceptors(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	// Disable batch.
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 0
+		conf.TiKVClient.GrpcConnectionCount = 1
+	})()
+	var unaryCalls, streamCalls []string
+	rpcClient := NewRPCClient(
+		WithUnaryInterceptors(
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				unaryCalls = append(unaryCalls, "auth")
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "token")
+				return invoker(ctx, method, req, reply, cc, opts...)
+			},
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				unaryCalls = append(unaryCalls, method)
+				return invoker(ctx, method, req, reply, cc, opts...)
+			},
+		),
+		WithStreamInterceptors(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			streamCalls = append(streamCalls, method)
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+	)
+	defer rpcClient.Close()
+
+	var checkCnt atomic.Int64
+	server.SetMetaChecker(func(ctx context.Context) error {
+		checkCnt.Add(1)
+		md, ok := metadata.FromIncomingContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"token"}, md.Get("authorization"))
+		return nil
+	})
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{})
+	_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"auth", "/tikvpb.Tikv/KvPrewrite"}, unaryCalls)
+	require.Equal(t, int64(1), checkCnt.Load())
+
+	server.SetMetaChecker(nil)
+	req = tikvrpc.NewRequest(tikvrpc.CmdCopStream, &coprocessor.Request{})
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"/tikvpb.Tikv/CoprocessorStream"}, streamCalls)
+	require.Len(t, unaryCalls, 2)
+}
+
+type credentialFunc func(ctx context.Context) (Credential, error)
+
+func (f credentialFunc) GetCredential(ctx context.Context) (Credential, error) {
+	return f(ctx)
+}
+
+func TestCredentialProvider(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+		conf.TiKVClient.GrpcConnectionCount = 1
+	})()
+	var refreshed atomic.Int64
+	provider := credentialFunc(func(ctx context.Context) (Credential, error) {
+		n := refreshed.Add(1)
+		// The credential needs to be refreshed 100ms later.
+		return Credential{Token: fmt.Sprintf("token-%d", n), Expiry: time.Now().Add(credentialRefreshAhead + 100*time.Millisecond)}, nil
+	})
+
+	// The credentials aren't sent over the insecure connections unless it's allowed explicitly.
+	secureClient := NewRPCClient(WithCredentialProvider(provider))
+	_, err := secureClient.SendRequest(context.Background(), addr, tikvrpc.NewRequest(tikvrpc.CmdCopStream, &coprocessor.Request{}), time.Second)
+	require.Error(t, err)
+	secureClient.Close()
+
+	rpcClient := NewRPCClient(WithInsecureCredentialProvider(provider))
+	defer rpcClient.Close()
+
+	var (
+		mu     sync.Mutex
+		tokens []string
+	)
+	server.SetMetaChecker(func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mu.Lock()
+		tokens = append(tokens, md.Get(credentialMetadataKey)...)
+		mu.Unlock()
+		return nil
+	})
+	getTokens := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), tokens...)
+	}
+
+	// The BatchCommands stream is created with the credential.
+	refreshed.Store(0)
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"token-1"}, getTokens())
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"token-1"}, getTokens())
+
+	// The stream is replaced with the new credential out of the send loop before the old one expires.
+	time.Sleep(150 * time.Millisecond)
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		return slices.Equal([]string{"token-1", "token-2"}, getTokens())
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"token-1", "token-2"}, getTokens())
+
+	// The unary calls carry the credential too.
+	req = tikvrpc.NewRequest(tikvrpc.CmdCopStream, &coprocessor.Request{})
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{"token-1", "token-2", "token-2"}, getTokens())
+	require.Equal(t, int64(2), refreshed.Load())
+}
+
+type statusErrClient struct {
+	Client
+	err error
+}
+
+func (c *statusErrClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &tikvrpc.Response{Resp: &kvrpcpb.ScanResponse{Pairs: []*kvrpcpb.KvPair{{Key: []byte("k"), Value: []byte("v")}}}}, nil
+}
+
+func TestRecorder(t *testing.T) {
+	// The values are removed from the nested messages too, and the keys are kept.
+	for _, msg := range []gogoMessage{
+		&kvrpcpb.RawCASRequest{Key: []byte("k"), Value: []byte("v"), PreviousValue: []byte("pv")},
+		&kvrpcpb.ScanResponse{Pairs: []*kvrpcpb.KvPair{{Key: []byte("k"), Value: []byte("v")}}},
+		&kvrpcpb.MvccGetByKeyResponse{Info: &kvrpcpb.MvccInfo{Lock: &kvrpcpb.MvccLock{Primary: []byte("k"), ShortValue: []byte("v")}}},
+		&kvrpcpb.PrewriteRequest{Mutations: []*kvrpcpb.Mutation{{Key: []byte("k"), Value: []byte("v")}}, PrimaryLock: []byte("k")},
+	} {
+		_, data, err := encodeMessage(msg, false)
+		require.Nil(t, err)
+		decoded := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(gogoMessage)
+		require.Nil(t, decoded.Unmarshal(data))
+		text := decoded.String()
+		require.Contains(t, text, `"k"`)
+		require.NotContains(t, text, `"v"`)
+		require.NotContains(t, text, `"pv"`)
+	}
+
+	// The gRPC status codes of the errors are replayed.
+	var buf strings.Builder
+	recorder := NewRecorder(&buf, false)
+	client := recorder.WrapClient(&statusErrClient{err: errors.WithStack(status.Error(codes.Unavailable, "store is down"))})
+	req := tikvrpc.NewRequest(tikvrpc.CmdScan, &kvrpcpb.ScanRequest{})
+	_, err := client.SendRequest(context.Background(), "", req, time.Second)
+	require.Error(t, err)
+	client.(*recordingClient).Client = &statusErrClient{}
+	_, err = client.SendRequest(context.Background(), "", req, time.Second)
+	require.Nil(t, err)
+	require.Nil(t, recorder.Close())
+
+	replay, err := NewReplayClient(strings.NewReader(buf.String()))
+	require.Nil(t, err)
+	_, err = replay.SendRequest(context.Background(), "", req, time.Second)
+	require.Equal(t, codes.Unavailable, status.Code(errors.Cause(err)))
+	require.Equal(t, "rpc error: code = Unavailable desc = store is down", err.Error())
+	resp, err := replay.SendRequest(context.Background(), "", req, time.Second)
+	require.Nil(t, err)
+	pairs := resp.Resp.(*kvrpcpb.ScanResponse).Pairs
+	require.Len(t, pairs, 1)
+	require.Equal(t, []byte("k"), pairs[0].Key)
+	require.Empty(t, pairs[0].Value)
+}
+
+func TestFailRetiredStreamRequests(t *testing.T) {
+	c := &batchCommandsClient{}
+	entries := make([]*batchCommandsEntry, 5)
+	for i := range entries {
+		entries[i] = &batchCommandsEntry{res: make(chan *tikvpb.BatchCommandsResponse_Response, 1)}
+		c.batched.Store(uint64(i), entries[i])
+	}
+	entries[2].forwardedHost = "forwarded"
+	stream := &batchCommandsStream{}
+	stream.onSent([]uint64{1, 2})
+	stream.onSent([]uint64{3})
+
+	// The requests sent on the stream but not responded are failed when the retired stream is closed.
+	c.failRetiredRequests(io.EOF, stream)
+	for i, entry := range entries {
+		_, pending := c.batched.Load(uint64(i))
+		require.Equal(t, i == 0 || i == 2 || i == 4, pending, i)
+		if !pending {
+			require.Error(t, entry.err)
+			require.True(t, entry.streamBroken)
+		}
+	}
+}
+
 func TestBatchCommandsBuilder(t *testing.T) {
 	builder := newBatchCommandsBuilder(128)
 
@@ -845,6 +1306,28 @@ func (l *testClientEventListener) OnHealthFeedback(feedback *kvrpcpb.HealthFeedb
 	l.healthFeedbackCh <- feedback
 }
 
+func TestHealthFeedbackSimulator(t *testing.T) {
+	sim := NewHealthFeedbackSimulator(nil)
+	require.False(t, sim.Feed(1, 100))
+
+	listener := newTestClientEventListener()
+	sim.SetEventListener(listener)
+	require.True(t, sim.Feed(1, 100))
+	require.True(t, sim.Feed(2, 1))
+	for i, expected := range []*kvrpcpb.HealthFeedback{
+		{StoreId: 1, FeedbackSeqNo: 1, SlowScore: 100},
+		{StoreId: 2, FeedbackSeqNo: 2, SlowScore: 1},
+	} {
+		select {
+		case feedback := <-listener.healthFeedbackCh:
+			require.Equal(t, expected, feedback, "feedback %d", i)
+		default:
+			require.FailNow(t, "the feedback isn't delivered synchronously")
+		}
+	}
+	require.Nil(t, sim.Close())
+}
+
 func TestBatchClientReceiveHealthFeedback(t *testing.T) {
 	server, port := mockserver.StartMockTikvService()
 	require.True(t, port > 0)
@@ -1081,39 +1564,39 @@ func TestBatchPolicy(t *testing.T) {
 	t.Run(config.BatchPolicyBasic, func(t *testing.T) {
 		trigger, ok := newTurboBatchTriggerFromPolicy(config.BatchPolicyBasic)
 		require.True(t, ok)
-		require.False(t, trigger.turboWaitTime() > 0)
+		require.False(t, trigger.TurboWaitTime() > 0)
 	})
 	t.Run(config.BatchPolicyPositive, func(t *testing.T) {
 		trigger, ok := newTurboBatchTriggerFromPolicy(config.BatchPolicyPositive)
 		require.True(t, ok)
-		require.Equal(t, trigger.turboWaitTime(), 100*time.Microsecond)
-		require.True(t, trigger.needFetchMore(time.Hour))
-		require.True(t, trigger.needFetchMore(time.Millisecond))
-		require.Equal(t, 8, trigger.preferredBatchWaitSize(1, 8))
-		require.Equal(t, 8, trigger.preferredBatchWaitSize(1.2, 8))
-		require.Equal(t, 8, trigger.preferredBatchWaitSize(1.8, 8))
+		require.Equal(t, trigger.TurboWaitTime(), 100*time.Microsecond)
+		require.True(t, trigger.NeedFetchMore(time.Hour))
+		require.True(t, trigger.NeedFetchMore(time.Millisecond))
+		require.Equal(t, 8, trigger.PreferredBatchWaitSize(1, 8))
+		require.Equal(t, 8, trigger.PreferredBatchWaitSize(1.2, 8))
+		require.Equal(t, 8, trigger.PreferredBatchWaitSize(1.8, 8))
 	})
 	t.Run(config.BatchPolicyStandard, func(t *testing.T) {
 		trigger, ok := newTurboBatchTriggerFromPolicy(config.BatchPolicyStandard)
 		require.True(t, ok)
-		require.Equal(t, 1, trigger.preferredBatchWaitSize(1, 8))
-		require.Equal(t, 1, trigger.preferredBatchWaitSize(1.2, 8))
-		require.Equal(t, 2, trigger.preferredBatchWaitSize(1.8, 8))
-		require.Equal(t, trigger.turboWaitTime(), 100*time.Microsecond)
-		require.False(t, trigger.needFetchMore(100*time.Microsecond))
-		require.False(t, trigger.needFetchMore(80*time.Microsecond))
-		require.True(t, trigger.needFetchMore(10*time.Microsecond))
-		require.True(t, trigger.needFetchMore(80*time.Microsecond))
-		require.False(t, trigger.needFetchMore(90*time.Microsecond))
+		require.Equal(t, 1, trigger.PreferredBatchWaitSize(1, 8))
+		require.Equal(t, 1, trigger.PreferredBatchWaitSize(1.2, 8))
+		require.Equal(t, 2, trigger.PreferredBatchWaitSize(1.8, 8))
+		require.Equal(t, trigger.TurboWaitTime(), 100*time.Microsecond)
+		require.False(t, trigger.NeedFetchMore(100*time.Microsecond))
+		require.False(t, trigger.NeedFetchMore(80*time.Microsecond))
+		require.True(t, trigger.NeedFetchMore(10*time.Microsecond))
+		require.True(t, trigger.NeedFetchMore(80*time.Microsecond))
+		require.False(t, trigger.NeedFetchMore(90*time.Microsecond))
 
 		for i := 0; i < 50; i++ {
-			trigger.needFetchMore(time.Hour)
+			trigger.NeedFetchMore(time.Hour)
 		}
 		require.Less(t, trigger.estArrivalInterval, trigger.maxArrivalInterval)
 		for i := 0; i < 8; i++ {
-			require.False(t, trigger.needFetchMore(10*time.Microsecond))
+			require.False(t, trigger.NeedFetchMore(10*time.Microsecond))
 		}
-		require.True(t, trigger.needFetchMore(10*time.Microsecond))
+		require.True(t, trigger.NeedFetchMore(10*time.Microsecond))
 	})
 	t.Run(config.BatchPolicyCustom, func(t *testing.T) {
 		trigger, ok := newTurboBatchTriggerFromPolicy(config.BatchPolicyCustom + " {} ")
@@ -1130,14 +1613,14 @@ func TestBatchPolicy(t *testing.T) {
 
 		trigger, ok = newTurboBatchTriggerFromPolicy(`{"v":2,"t":0.001,"w":0.2,"p":0.5}`)
 		require.True(t, ok)
-		require.Equal(t, 2, trigger.preferredBatchWaitSize(1, 8))
-		require.Equal(t, 2, trigger.preferredBatchWaitSize(1.2, 8))
-		require.Equal(t, trigger.turboWaitTime(), time.Millisecond)
-		require.False(t, trigger.needFetchMore(time.Millisecond-time.Microsecond))
-		require.False(t, trigger.needFetchMore(time.Millisecond-time.Microsecond))
-		require.False(t, trigger.needFetchMore(time.Millisecond-time.Microsecond))
-		require.True(t, trigger.needFetchMore(time.Millisecond-time.Microsecond))
-		require.False(t, trigger.needFetchMore(time.Millisecond))
+		require.Equal(t, 2, trigger.PreferredBatchWaitSize(1, 8))
+		require.Equal(t, 2, trigger.PreferredBatchWaitSize(1.2, 8))
+		require.Equal(t, trigger.TurboWaitTime(), time.Millisecond)
+		require.False(t, trigger.NeedFetchMore(time.Millisecond-time.Microsecond))
+		require.False(t, trigger.NeedFetchMore(time.Millisecond-time.Microsecond))
+		require.False(t, trigger.NeedFetchMore(time.Millisecond-time.Microsecond))
+		require.True(t, trigger.NeedFetchMore(time.Millisecond-time.Microsecond))
+		require.False(t, trigger.NeedFetchMore(time.Millisecond))
 	})
 	t.Run("invalid", func(t *testing.T) {
 		for _, val := range []string{
@@ -1149,3 +1632,716 @@ func TestBatchPolicy(t *testing.T) {
 		}
 	})
 }
+
+func TestSetBatchPolicy(t *testing.T) {
+	for _, policy := range []string{
+		config.BatchPolicyBasic, config.BatchPolicyStandard, config.BatchPolicyPositive,
+		config.BatchPolicyCustom + ` {"v":2,"t":0.001,"w":0.2,"p":0.5}`, `{"t":0.0001}`,
+	} {
+		require.NoError(t, ValidateBatchPolicy(policy), policy)
+	}
+	for _, policy := range []string{
+		"", "invalid", "custom", "custom {x:1}", `custom {"x":1}`, `custom {"v":3}`, `custom {"t":-1}`,
+		`custom {"v":1,"p":1.5}`, `custom {} {}`,
+	} {
+		require.Error(t, ValidateBatchPolicy(policy), policy)
+	}
+
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+	client := NewRPCClient()
+	defer client.Close()
+	require.Equal(t, config.GetGlobalConfig().TiKVClient.BatchPolicy, client.GetBatchPolicy())
+
+	conn, err := client.getConnArray(addr, true)
+	require.NoError(t, err)
+	require.Equal(t, config.GetGlobalConfig().TiKVClient.BatchPolicy, *conn.batchConn.policy.Load())
+	require.Error(t, SetBatchPolicy(client, "invalid"))
+	require.Equal(t, config.GetGlobalConfig().TiKVClient.BatchPolicy, client.GetBatchPolicy())
+	// The wrappers of the client are unwrapped.
+	wrapped := NewMetadataClient(NewInterceptedClient(client), ClientMetadata{AppName: "app"})
+	require.NotEmpty(t, GetConnStats(wrapped))
+	require.NotEmpty(t, GetBatchQueueStats(wrapped))
+	require.NoError(t, SetBatchPolicy(wrapped, config.BatchPolicyPositive))
+	require.Equal(t, config.BatchPolicyPositive, client.GetBatchPolicy())
+	require.Equal(t, config.BatchPolicyPositive, *conn.batchConn.policy.Load())
+	// The send loop works with the updated policy.
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err = client.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.NoError(t, err)
+
+	// The connections established later use the updated policy.
+	require.NoError(t, client.CloseAddr(addr))
+	conn, err = client.getConnArray(addr, true)
+	require.NoError(t, err)
+	require.Equal(t, config.BatchPolicyPositive, *conn.batchConn.policy.Load())
+
+	require.Error(t, SetBatchPolicy(NewHealthFeedbackSimulator(nil), config.BatchPolicyBasic))
+}
+
+type countingBatchPolicy struct {
+	fetchMore *atomic.Int64
+}
+
+func (p countingBatchPolicy) TurboWaitTime() time.Duration { return time.Millisecond }
+
+func (p countingBatchPolicy) NeedFetchMore(time.Duration) bool {
+	p.fetchMore.Add(1)
+	return false
+}
+
+func (p countingBatchPolicy) PreferredBatchWaitSize(float64, int) int { return 1 }
+
+func TestRegisterBatchSchedulerPolicy(t *testing.T) {
+	const name = "test-arrival-predictor"
+	var created, fetchMore atomic.Int64
+	newPolicy := func() BatchSchedulerPolicy {
+		created.Add(1)
+		return countingBatchPolicy{fetchMore: &fetchMore}
+	}
+	require.Error(t, ValidateBatchPolicy(name))
+	require.NoError(t, RegisterBatchSchedulerPolicy(name, newPolicy))
+	defer func() {
+		customBatchPolicies.Lock()
+		delete(customBatchPolicies.m, name)
+		customBatchPolicies.Unlock()
+	}()
+	require.NoError(t, ValidateBatchPolicy(name))
+	require.Error(t, RegisterBatchSchedulerPolicy(name, newPolicy))
+	for _, invalid := range []string{"", " ", config.BatchPolicyStandard, config.BatchPolicyCustom + "-x", `{"v":1}`} {
+		require.Error(t, RegisterBatchSchedulerPolicy(invalid, newPolicy), invalid)
+	}
+	require.Error(t, RegisterBatchSchedulerPolicy("nil-policy", nil))
+
+	// The policies failing to be created fall back to the default one.
+	badPolicies := map[string]func() BatchSchedulerPolicy{
+		"test-returning-nil": func() BatchSchedulerPolicy { return nil },
+		"test-panicking":     func() BatchSchedulerPolicy { panic("boom") },
+	}
+	for badName, newBadPolicy := range badPolicies {
+		require.NoError(t, RegisterBatchSchedulerPolicy(badName, newBadPolicy))
+		p, ok := newBatchSchedulerPolicy(badName)
+		require.False(t, ok, badName)
+		require.Equal(t, presetBatchPolicies[config.DefBatchPolicy], p.(*turboBatchTrigger).opts, badName)
+		customBatchPolicies.Lock()
+		delete(customBatchPolicies.m, badName)
+		customBatchPolicies.Unlock()
+	}
+
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+	client := NewRPCClient()
+	defer client.Close()
+	require.NoError(t, client.SetBatchPolicy(name))
+
+	// The requests are sent one by one, so the arrival interval of each batch is positive after the first one.
+	for i := 0; i < 3; i++ {
+		req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+		_, err := client.SendRequest(context.Background(), addr, req, 10*time.Second)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), created.Load())
+	require.Positive(t, fetchMore.Load())
+}
+
+func TestAdaptiveBatchSize(t *testing.T) {
+	gauge := metrics.TiKVBatchAdaptiveMaxSize.WithLabelValues("test-adaptive")
+	threshold := 20 * time.Millisecond
+	require.Nil(t, newAdaptiveBatchSize(0, 128, threshold, gauge))
+	require.Nil(t, newAdaptiveBatchSize(128, 128, threshold, gauge))
+	s := newAdaptiveBatchSize(8, 128, threshold, gauge)
+	require.Equal(t, 128, s.get())
+	recv1, recv2 := s.newRecvLatencies(), s.newRecvLatencies()
+
+	readGauge := func() float64 {
+		var m dto.Metric
+		require.NoError(t, gauge.Write(&m))
+		return m.GetGauge().GetValue()
+	}
+	now := time.Now()
+	// The size is halved to the floor while the responses of any client are slow.
+	for _, expected := range []int{64, 32, 16, 8, 8} {
+		for i := 0; i < 100; i++ {
+			recv1.observe(time.Millisecond)
+			recv2.observe(time.Millisecond)
+		}
+		for i := 0; i < 5; i++ {
+			recv2.observe(time.Second)
+		}
+		s.observeSend(time.Millisecond)
+		now = now.Add(adaptiveBatchSizeInterval)
+		require.Equal(t, expected, s.adjust(now))
+		require.Equal(t, float64(expected), readGauge())
+	}
+	// It isn't adjusted within the interval.
+	s.observeSend(time.Second)
+	require.Equal(t, 8, s.adjust(now.Add(adaptiveBatchSizeInterval/2)))
+	// The slow batches waiting to be sent halve it as well.
+	now = now.Add(adaptiveBatchSizeInterval)
+	s.observeSend(time.Second)
+	require.Equal(t, 8, s.adjust(now))
+	// It grows back gradually when the latency is low.
+	for _, expected := range []int{16, 24, 32} {
+		s.observeSend(time.Millisecond)
+		recv1.observe(time.Millisecond)
+		now = now.Add(adaptiveBatchSizeInterval)
+		require.Equal(t, expected, s.adjust(now))
+	}
+	for i := 0; i < 20; i++ {
+		now = now.Add(adaptiveBatchSizeInterval)
+		s.adjust(now)
+	}
+	require.Equal(t, 128, s.get())
+	require.Equal(t, float64(128), readGauge())
+
+	// The latency is compared with the configured threshold, e.g. for the stores across regions.
+	s = newAdaptiveBatchSize(8, 128, 100*time.Millisecond, gauge)
+	s.observeSend(50 * time.Millisecond)
+	s.newRecvLatencies().observe(50 * time.Millisecond)
+	require.Equal(t, 128, s.adjust(now))
+
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.AdaptiveBatchSizeFloor = 16
+	})()
+	client := NewRPCClient()
+	defer client.Close()
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err := client.SendRequest(context.Background(), server.Addr(), req, 10*time.Second)
+	require.NoError(t, err)
+	stats := GetBatchQueueStats(client)
+	require.Len(t, stats, 1)
+	require.Equal(t, int(config.GetGlobalConfig().TiKVClient.MaxBatchSize), stats[0].MaxBatchSize)
+}
+
+func TestTrafficClassMetrics(t *testing.T) {
+	client := NewRPCClient()
+	defer client.Close()
+	connArray, err := client.getConnArray("127.0.0.1:6379", false)
+	require.Nil(t, err)
+
+	readTraffic := func(class tikvrpc.TrafficClass) float64 {
+		var m dto.Metric
+		require.Nil(t, metrics.TiKVTrafficRequestCounter.WithLabelValues(class.Name(), tikvrpc.CmdResolveLock.String()).Write(&m))
+		return m.GetCounter().GetValue()
+	}
+	userBefore, internalBefore := readTraffic(tikvrpc.TrafficClassUser), readTraffic(tikvrpc.TrafficClassLockResolve)
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdResolveLock, &kvrpcpb.ResolveLockRequest{})
+	connArray.updateRPCMetrics(req, &tikvrpc.Response{}, time.Millisecond)
+	req.TrafficClass = tikvrpc.TrafficClassLockResolve
+	connArray.updateRPCMetrics(req, &tikvrpc.Response{}, time.Millisecond)
+	connArray.updateRPCMetrics(req, &tikvrpc.Response{}, time.Millisecond)
+
+	require.Equal(t, userBefore+1, readTraffic(tikvrpc.TrafficClassUser))
+	require.Equal(t, internalBefore+2, readTraffic(tikvrpc.TrafficClassLockResolve))
+	require.True(t, req.TrafficClass.IsInternal())
+}
+
+func TestBatchConnIdleDetect(t *testing.T) {
+	var idleNotify uint32
+	a := newBatchConn(1, 8, &idleNotify)
+	a.setIdlePolicy(time.Minute, config.BatchConnIdleRecycleConnArray)
+
+	// A fetched request makes the connection active.
+	now := time.Now()
+	a.batchCommandsCh <- &batchCommandsEntry{start: now}
+	a.fetchAllPendingRequests(8)
+	require.GreaterOrEqual(t, a.lastActive.Load(), now.UnixNano())
+
+	// There are requests within idleTimeout, so it's checked again when it may become idle.
+	a.lastActive.Store(now.Add(-time.Second).UnixNano())
+	require.Equal(t, 59*time.Second, a.checkIdle(now))
+	require.False(t, a.isIdle())
+	require.Equal(t, uint32(0), atomic.LoadUint32(&idleNotify))
+
+	// No request for idleTimeout.
+	a.lastActive.Store(now.Add(-time.Minute).UnixNano())
+	require.Equal(t, time.Duration(0), a.checkIdle(now))
+	require.True(t, a.isIdle())
+	require.Equal(t, uint32(1), atomic.LoadUint32(&idleNotify))
+}
+
+func TestBatchIdlePollerWakeups(t *testing.T) {
+	const conns, idleTimeout = 100, 100 * time.Millisecond
+	p := newBatchIdlePoller()
+	var (
+		idleNotify uint32
+		wakeups    atomic.Int64
+		wg         sync.WaitGroup
+		batchConns []*batchConn
+	)
+	for i := 0; i < conns; i++ {
+		a := newBatchConn(1, 8, &idleNotify)
+		a.setIdlePolicy(idleTimeout, config.BatchConnIdleRecycleConnArray)
+		p.register(a)
+		batchConns = append(batchConns, a)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The send loop parks until the connection is closed.
+			a.fetchAllPendingRequests(8)
+			wakeups.Add(1)
+		}()
+	}
+
+	time.Sleep(5 * idleTimeout)
+	for _, a := range batchConns {
+		require.True(t, a.isIdle())
+	}
+	require.Equal(t, int64(0), wakeups.Load())
+	// A timer per connection would fire at least once for each of them.
+	require.Less(t, p.wakeups.Load(), uint64(10))
+
+	for _, a := range batchConns {
+		a.Close()
+		p.unregister(a)
+	}
+	wg.Wait()
+	require.Equal(t, int64(conns), wakeups.Load())
+	// The poller stops without connections to watch.
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return !p.running
+	}, time.Second, 10*time.Millisecond)
+}
+
+type connEventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *connEventRecorder) OnHealthFeedback(*kvrpcpb.HealthFeedback) {}
+
+func (r *connEventRecorder) OnConnRecycled(_ string, recycle string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, recycle+":"+connRecycled)
+}
+
+func (r *connEventRecorder) OnConnReestablished(_ string, recycle string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, recycle+":"+connReestablished)
+}
+
+func (r *connEventRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestBatchConnIdleRecycleStream(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+		conf.TiKVClient.GrpcConnectionCount = 1
+		conf.TiKVClient.BatchConnIdleTimeout = 100 * time.Millisecond
+		conf.TiKVClient.BatchConnIdleRecycle = config.BatchConnIdleRecycleStream
+	})()
+	rpcClient := NewRPCClient()
+	defer rpcClient.Close()
+	recorder := &connEventRecorder{}
+	rpcClient.SetEventListener(recorder)
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+
+	// The stream is closed after being idle, but the connection is kept.
+	stream := config.BatchConnIdleRecycleStream
+	require.Eventually(t, func() bool {
+		return len(recorder.get()) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{stream + ":" + connRecycled}, recorder.get())
+	conn, err := rpcClient.getConnArray(addr, true)
+	require.Nil(t, err)
+	require.False(t, conn.batchConn.isIdle())
+
+	// The stream is re-established by the next request.
+	_, err = rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+	require.Equal(t, []string{stream + ":" + connRecycled, stream + ":" + connReestablished}, recorder.get()[:2])
+}
+
+func TestBatchConnIdleRecycleDisabled(t *testing.T) {
+	var idleNotify uint32
+	a := newBatchConn(1, 8, &idleNotify)
+	a.setIdlePolicy(0, config.BatchConnIdleRecycleConnArray)
+
+	// The connection is never watched, so it's never marked idle.
+	p := newBatchIdlePoller()
+	p.register(a)
+	require.Empty(t, p.conns)
+	require.False(t, p.running)
+	require.False(t, a.isIdle())
+	require.Equal(t, uint32(0), atomic.LoadUint32(&idleNotify))
+}
+
+func TestBatchDeadlineFastPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	require.Equal(t, start.Add(time.Millisecond), entryDeadline(ctx, start, time.Millisecond))
+	deadline, _ := ctx.Deadline()
+	require.Equal(t, deadline, entryDeadline(ctx, start, time.Minute))
+	require.Equal(t, deadline, entryDeadline(ctx, start, 0))
+	require.True(t, entryDeadline(context.Background(), start, 0).IsZero())
+
+	a := newBatchConn(1, 8, nil)
+	a.reqBuilder.deadlineThreshold = 10 * time.Millisecond
+	a.reqBuilder.push(&batchCommandsEntry{start: time.Now()})
+	a.reqBuilder.push(&batchCommandsEntry{start: time.Now(), deadline: time.Now().Add(time.Second)})
+	require.Equal(t, 0, a.reqBuilder.urgent)
+
+	// An urgent request ends the wait for more requests.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		a.batchCommandsCh <- &batchCommandsEntry{start: time.Now(), deadline: time.Now().Add(time.Millisecond)}
+	}()
+	waitStart := time.Now()
+	a.fetchMorePendingRequests(128, 8, 10*time.Second)
+	require.Less(t, time.Since(waitStart), 5*time.Second)
+	require.Equal(t, 3, a.reqBuilder.len())
+	require.Equal(t, 1, a.reqBuilder.urgent)
+
+	a.reqBuilder.reset()
+	require.Equal(t, 0, a.reqBuilder.urgent)
+}
+
+type outdatedResponseRecorder struct {
+	mu        sync.Mutex
+	responses []string
+}
+
+func (r *outdatedResponseRecorder) OnHealthFeedback(*kvrpcpb.HealthFeedback) {}
+
+func (r *outdatedResponseRecorder) OnOutdatedResponse(addr string, requestID uint64, cmd string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, fmt.Sprintf("%s/%d/%s", addr, requestID, cmd))
+}
+
+func TestLateBatchResponses(t *testing.T) {
+	recorder := &outdatedResponseRecorder{}
+	var listener ClientEventListener = recorder
+	cli := &batchCommandsClient{target: "store1", eventListener: new(atomic.Pointer[ClientEventListener])}
+	cli.eventListener.Store(&listener)
+
+	ctx := context.Background()
+	rl := async.NewRunLoop()
+	errs := make(map[uint64]error)
+	addEntry := func(id uint64, deadline time.Time, isAsync bool) *batchCommandsEntry {
+		entry := &batchCommandsEntry{ctx: ctx, start: time.Now(), deadline: deadline}
+		if isAsync {
+			entry.cb = async.NewCallback(rl, func(resp *tikvrpc.Response, err error) { errs[id] = err })
+		} else {
+			entry.res = make(chan *tikvpb.BatchCommandsResponse_Response, 1)
+		}
+		cli.batched.Store(id, entry)
+		cli.sent.Add(1)
+		return entry
+	}
+	lateEntry := addEntry(1, time.Now().Add(time.Minute), true)
+	addEntry(2, time.Now().Add(10*time.Millisecond), true)
+	syncEntry := addEntry(3, time.Now().Add(time.Minute), false)
+	sendErr := errors.New("ambiguous send error")
+	cli.waitLateResponses(sendErr, []uint64{1, 2, 3})
+
+	// The sync request is failed at once.
+	_, ok := <-syncEntry.res
+	require.False(t, ok)
+	require.Equal(t, int64(2), cli.sent.Load())
+
+	// The late response is delivered.
+	resp := &tikvpb.BatchCommandsResponse{
+		RequestIds: []uint64{1},
+		Responses:  []*tikvpb.BatchCommandsResponse_Response{{Cmd: &tikvpb.BatchCommandsResponse_Response_Get{Get: &kvrpcpb.GetResponse{}}}},
+	}
+	cli.handleBatchResponse(&batchCommandsStream{}, resp, time.Now(), config.TiKVClient{}, new(uint64))
+	rl.Exec(ctx)
+	err, ok := errs[1]
+	require.True(t, ok)
+	require.NoError(t, err)
+	// The timer is stopped once the response is delivered.
+	require.False(t, lateEntry.lateTimer.Load().Stop())
+
+	// The request without a response is failed at its deadline.
+	rl.Exec(ctx)
+	require.ErrorIs(t, errs[2], sendErr)
+	require.Equal(t, int64(0), cli.sent.Load())
+
+	// The response after the deadline is outdated.
+	resp.RequestIds = []uint64{2}
+	cli.handleBatchResponse(&batchCommandsStream{}, resp, time.Now(), config.TiKVClient{LogOutdatedBatchResponseCmd: true}, new(uint64))
+	require.Equal(t, []string{"store1/2/Get"}, recorder.responses)
+
+	// The timers are stopped when the client is closed.
+	closedEntry := addEntry(4, time.Now().Add(time.Minute), true)
+	cli.waitLateResponses(sendErr, []uint64{4})
+	cli.failAsyncRequestsOnClose()
+	require.False(t, closedEntry.lateTimer.Load().Stop())
+	rl.Exec(ctx)
+	require.Error(t, errs[4])
+	require.Equal(t, int64(0), cli.sent.Load())
+}
+
+func BenchmarkFetchAllPendingRequests(b *testing.B) {
+	a := newBatchConn(1, 128, nil)
+	entry := &batchCommandsEntry{start: time.Now()}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.batchCommandsCh <- entry
+		a.fetchAllPendingRequests(128)
+		a.reqBuilder.entries.reset()
+	}
+}
+
+func BenchmarkConnMonitor(b *testing.B) {
+	client := NewRPCClient()
+	defer client.Close()
+	for i := 0; i < 64; i++ {
+		_, err := client.getConnArray(fmt.Sprintf("127.0.0.1:%d", 20000+i), false)
+		require.Nil(b, err)
+	}
+	m := client.connMonitor
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.m.Range(func(_, value interface{}) bool {
+			value.(*monitoredConn).updateStateMetrics()
+			return true
+		})
+	}
+}
+
+func TestConnStats(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	addr := server.Addr()
+
+	rpcClient := NewRPCClient()
+	defer rpcClient.Close()
+	req := tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{})
+	_, err := rpcClient.SendRequest(context.Background(), addr, req, 10*time.Second)
+	require.Nil(t, err)
+
+	connected := func() (connects, disconnects uint64) {
+		for _, stat := range GetConnStats(rpcClient) {
+			require.Equal(t, addr, stat.Target)
+			connects += stat.Connects
+			disconnects += stat.Disconnects
+		}
+		return
+	}
+	require.Len(t, GetConnStats(rpcClient), int(config.GetGlobalConfig().TiKVClient.GrpcConnectionCount))
+	connects, disconnects := connected()
+	require.Greater(t, connects, uint64(0))
+	require.Zero(t, disconnects)
+
+	server.Stop()
+	require.Eventually(t, func() bool {
+		_, disconnects := connected()
+		return disconnects == connects
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A GOAWAY is counted once per transport.
+	handler := newConnStatsHandler(addr)
+	drained, reconnected := 0, 0
+	handler.onDrain = func() { drained++ }
+	handler.onReconnect = func() { reconnected++ }
+	handler.HandleConn(context.Background(), &stats.ConnBegin{})
+	for i := 0; i < 3; i++ {
+		handler.HandleRPC(context.Background(), &stats.End{Error: status.Error(codes.Unavailable, "the connection is draining"), EndTime: time.Now()})
+	}
+	handler.HandleConn(context.Background(), &stats.ConnEnd{})
+	var stat ConnStat
+	handler.fill(&stat)
+	require.Equal(t, uint64(1), stat.GoAways)
+	require.Equal(t, "the connection is draining", stat.LastError)
+
+	// Only the GOAWAYs of shutting down drain the target, until it's connected again.
+	for _, msg := range []string{
+		`closing transport due to: EOF, received prior goaway: code: ENHANCE_YOUR_CALM, debug data: "too_many_pings"`,
+		`closing transport due to: EOF, received prior goaway: code: NO_ERROR, debug data: "max_age"`,
+	} {
+		handler.HandleConn(context.Background(), &stats.ConnBegin{})
+		handler.HandleRPC(context.Background(), &stats.End{Error: status.Error(codes.Unavailable, msg), EndTime: time.Now()})
+	}
+	require.Zero(t, drained)
+	for i := 0; i < 2; i++ {
+		handler.HandleRPC(context.Background(), &stats.End{Error: status.Error(codes.Unavailable, "closing transport due to: EOF, received prior goaway: code: NO_ERROR"), EndTime: time.Now()})
+	}
+	require.Equal(t, 1, drained)
+	handler.HandleConn(context.Background(), &stats.ConnBegin{})
+	handler.HandleConn(context.Background(), &stats.ConnBegin{})
+	require.Equal(t, 1, reconnected)
+}
+
+func TestBatchConnStoreOverride(t *testing.T) {
+	a := newBatchConn(2, 8, nil)
+	a.target, a.concurrencyLimit = "store1:20160", 1024
+	for i := 0; i < 2; i++ {
+		cli := &batchCommandsClient{}
+		cli.maxConcurrencyRequestLimit.Store(a.concurrencyLimit)
+		a.batchCommandsClients = append(a.batchCommandsClients, cli)
+	}
+	checkLimit := func(limit int64) {
+		a.applyStoreOverride()
+		for _, cli := range a.batchCommandsClients {
+			require.Equal(t, limit, cli.maxConcurrencyRequestLimit.Load())
+		}
+	}
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.PerStoreOverrides = []config.StoreOverride{{StoreIDs: []uint64{1}, MaxConcurrencyRequestLimit: 16}}
+	})()
+	// The store ID is unknown yet.
+	checkLimit(1024)
+	a.observeStoreID(1)
+	checkLimit(16)
+
+	// Overrides are hot-reloaded.
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.PerStoreOverrides = []config.StoreOverride{{Addrs: []string{"store1:20160"}, MaxConcurrencyRequestLimit: 32}}
+	})
+	checkLimit(32)
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.PerStoreOverrides = []config.StoreOverride{{StoreIDs: []uint64{2}, MaxConcurrencyRequestLimit: 16}}
+	})
+	checkLimit(1024)
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdGet))
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdPrewrite))
+	require.Equal(t, ReadTimeoutMedium, DefaultTimeout(tikvrpc.CmdScan))
+	require.Equal(t, ReadTimeoutMedium, DefaultTimeout(tikvrpc.CmdCop))
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdRawScan))
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdRawDeleteRange))
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdRawChecksum))
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.RequestTimeouts = map[string]time.Duration{"Get": time.Second, "Cop": 0}
+	})()
+	require.Equal(t, time.Second, DefaultTimeout(tikvrpc.CmdGet))
+	require.Equal(t, ReadTimeoutShort, DefaultTimeout(tikvrpc.CmdPrewrite))
+	require.Equal(t, ReadTimeoutMedium, DefaultTimeout(tikvrpc.CmdCop))
+}
+
+func TestBatchCommandsFallback(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+	server.BatchCommandsUnsupported.Store(true)
+	probeInterval := batchCommandsProbeInterval
+	batchCommandsProbeInterval = 500 * time.Millisecond
+	defer func() { batchCommandsProbeInterval = probeInterval }()
+
+	client := NewRPCClient()
+	defer client.Close()
+	ctx := context.Background()
+	// The requests racing with the detection may fail, the following ones fall back to unary RPCs.
+	require.Eventually(t, func() bool {
+		_, err := client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	conn, err := client.getConnArray(addr, true)
+	require.NoError(t, err)
+	require.True(t, conn.batchConn.fallback.active())
+	for i := 0; i < 10; i++ {
+		_, err = client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+		require.NoError(t, err)
+	}
+	rl := async.NewRunLoop()
+	called := false
+	client.SendRequestAsync(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), async.NewCallback(rl, func(resp *tikvrpc.Response, err error) {
+		require.NoError(t, err)
+		called = true
+	}))
+	_, err = rl.Exec(ctx)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	// BatchCommands is used again once it's supported.
+	server.BatchCommandsUnsupported.Store(false)
+	require.Eventually(t, func() bool {
+		return !conn.batchConn.fallback.active()
+	}, 5*time.Second, 50*time.Millisecond)
+	resp, err := client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdEmpty, &tikvpb.BatchCommandsEmptyRequest{}), time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, resp.Resp)
+}
+
+func TestBatchCommandsFallbackAdmission(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	addr := server.Addr()
+	server.BatchCommandsUnsupported.Store(true)
+	probeInterval := batchCommandsProbeInterval
+	batchCommandsProbeInterval = 500 * time.Millisecond
+	defer func() { batchCommandsProbeInterval = probeInterval }()
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MaxBatchSize = 128
+	})()
+	var admitted atomic.Int64
+	var reject atomic.Bool
+	rejected := errors.New("too busy")
+	client := NewRPCClient(WithAdmissionController(admissionFunc(func(ctx context.Context, info AdmissionInfo) (uint64, error) {
+		admitted.Add(1)
+		if reject.Load() {
+			return 0, rejected
+		}
+		return info.Priority, nil
+	})))
+	defer client.Close()
+	ctx := context.Background()
+	require.Eventually(t, func() bool {
+		_, err := client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	conn, err := client.getConnArray(addr, true)
+	require.NoError(t, err)
+	require.True(t, conn.batchConn.fallback.active())
+
+	// The requests falling back to unary RPCs are admitted as well.
+	n := admitted.Load()
+	_, err = client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+	require.NoError(t, err)
+	require.Equal(t, n+1, admitted.Load())
+
+	reject.Store(true)
+	var errRejected *tikverr.ErrRequestRejected
+	_, err = client.SendRequest(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+	require.ErrorAs(t, err, &errRejected)
+	require.ErrorIs(t, err, rejected)
+
+	rl := async.NewRunLoop()
+	client.SendRequestAsync(ctx, addr, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), async.NewCallback(rl, func(resp *tikvrpc.Response, e error) {
+		err = e
+	}))
+	_, execErr := rl.Exec(ctx)
+	require.NoError(t, execErr)
+	require.ErrorAs(t, err, &errRejected)
+	require.Equal(t, n+3, admitted.Load())
+
+	server.BatchCommandsUnsupported.Store(false)
+	require.Eventually(t, func() bool {
+		return !conn.batchConn.fallback.active()
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/client-go/v2/metrics"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// ConnStat is the stat of a gRPC connection to a target.
+type ConnStat struct {
+	Target string
+	// Name identifies the connection among the connections to the target.
+	Name  string
+	State string
+	// Connects and Disconnects are the numbers of the transports established and closed beneath the connection.
+	Connects    uint64
+	Disconnects uint64
+	// StateChanges is the number of the state changes observed by the connection monitor, which checks the states
+	// every few seconds, so quick flaps may be missed.
+	StateChanges uint64
+	// GoAways is the number of the transports closed by a GOAWAY from the target.
+	GoAways uint64
+	// LastError is the latest error of the RPCs on the connection.
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// connStatsHandler is the grpc stats.Handler of a connection, collecting the events of its transports.
+type connStatsHandler struct {
+	mu struct {
+		sync.Mutex
+		connects      uint64
+		disconnects   uint64
+		stateChanges  uint64
+		goAways       uint64
+		goAwayCounted bool
+		// draining is set when the target is shutting down, until a new transport is established.
+		draining    bool
+		lastErr     string
+		lastErrTime time.Time
+	}
+	metrics struct {
+		connect     prometheus.Counter
+		disconnect  prometheus.Counter
+		stateChange prometheus.Counter
+		goAway      prometheus.Counter
+	}
+	// onDrain is called when a transport receives a GOAWAY of the target shutting down, if it's not nil.
+	onDrain func()
+	// onReconnect is called when a new transport is established after onDrain, if it's not nil.
+	onReconnect func()
+}
+
+func newConnStatsHandler(target string) *connStatsHandler {
+	h := &connStatsHandler{}
+	h.metrics.connect = metrics.TiKVGrpcConnectionEventCounter.WithLabelValues(target, "connect")
+	h.metrics.disconnect = metrics.TiKVGrpcConnectionEventCounter.WithLabelValues(target, "disconnect")
+	h.metrics.stateChange = metrics.TiKVGrpcConnectionEventCounter.WithLabelValues(target, "state_change")
+	h.metrics.goAway = metrics.TiKVGrpcConnectionEventCounter.WithLabelValues(target, "goaway")
+	return h
+}
+
+// TagRPC implements stats.Handler.
+func (h *connStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (h *connStatsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok || end.Error == nil {
+		return
+	}
+	msg := end.Error.Error()
+	if st, ok := status.FromError(end.Error); ok {
+		msg = st.Message()
+	}
+	h.mu.Lock()
+	h.mu.lastErr, h.mu.lastErrTime = msg, end.EndTime
+	// All streams on a transport fail after it receives a GOAWAY, count it only once per transport.
+	if !h.mu.goAwayCounted && isGoAwayMessage(msg) {
+		h.mu.goAwayCounted = true
+		h.mu.goAways++
+		h.metrics.goAway.Inc()
+	}
+	drain := !h.mu.draining && isShutdownGoAwayMessage(msg)
+	if drain {
+		h.mu.draining = true
+	}
+	h.mu.Unlock()
+	if drain && h.onDrain != nil {
+		h.onDrain()
+	}
+}
+
+func isGoAwayMessage(msg string) bool {
+	return strings.Contains(msg, "goaway") || strings.Contains(msg, "connection is draining")
+}
+
+// isShutdownGoAwayMessage tells whether the error is caused by a GOAWAY of the target shutting down gracefully, which
+// has the NO_ERROR code, unlike the GOAWAYs of too many pings or the max connection age. gRPC only reports the code of
+// the GOAWAY in the errors of the streams closed with the transport.
+func isShutdownGoAwayMessage(msg string) bool {
+	return strings.Contains(msg, "received prior goaway: code: NO_ERROR") && !strings.Contains(msg, "max_age")
+}
+
+// TagConn implements stats.Handler.
+func (h *connStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.
+func (h *connStatsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	var reconnect bool
+	h.mu.Lock()
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.mu.connects++
+		h.mu.goAwayCounted = false
+		reconnect, h.mu.draining = h.mu.draining, false
+		h.metrics.connect.Inc()
+	case *stats.ConnEnd:
+		h.mu.disconnects++
+		h.metrics.disconnect.Inc()
+	}
+	h.mu.Unlock()
+	if reconnect && h.onReconnect != nil {
+		h.onReconnect()
+	}
+}
+
+func (h *connStatsHandler) onStateChange() {
+	h.mu.Lock()
+	h.mu.stateChanges++
+	h.mu.Unlock()
+	h.metrics.stateChange.Inc()
+}
+
+func (h *connStatsHandler) fill(stat *ConnStat) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stat.Connects = h.mu.connects
+	stat.Disconnects = h.mu.disconnects
+	stat.StateChanges = h.mu.stateChanges
+	stat.GoAways = h.mu.goAways
+	stat.LastError = h.mu.lastErr
+	stat.LastErrorTime = h.mu.lastErrTime
+}
+
+// connStats returns the stats of the gRPC connections, sorted by the target and the name.
+func (c *RPCClient) connStats() []ConnStat {
+	c.RLock()
+	defer c.RUnlock()
+	var res []ConnStat
+	for target, array := range c.conns {
+		for _, conn := range array.v {
+			if conn == nil || conn.ClientConn == nil {
+				continue
+			}
+			stat := ConnStat{Target: target, Name: conn.Name, State: conn.GetState().String()}
+			conn.stats.fill(&stat)
+			res = append(res, stat)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Target != res[j].Target {
+			return res[i].Target < res[j].Target
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// GetConnStats returns the stats of the gRPC connections of the client, or nil if the client isn't (a wrapper of)
+// RPCClient.
+func GetConnStats(c Client) []ConnStat {
+	if rpcClient := unwrapRPCClient(c); rpcClient != nil {
+		return rpcClient.connStats()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// credentialMetadataKey is the gRPC metadata key of the credential token.
+const credentialMetadataKey = "authorization"
+
+// credentialRefreshAhead is how long before the expiry a credential is refreshed, so the requests in flight are not
+// rejected because of the expiry.
+const credentialRefreshAhead = 30 * time.Second
+
+// Credential is a token attached to the requests to TiKV.
+type Credential struct {
+	// Token is sent as the "authorization" gRPC metadata, e.g. "Bearer xxx".
+	Token string
+	// Expiry is when the token expires. A zero Expiry means the token never expires.
+	Expiry time.Time
+}
+
+// CredentialProvider provides the credentials attached to the requests to TiKV, e.g. for the TiKV deployments behind
+// authentication proxies.
+type CredentialProvider interface {
+	// GetCredential returns a new credential. It's called when the client is going to send a request and the previous
+	// credential is about to expire.
+	GetCredential(ctx context.Context) (Credential, error)
+}
+
+// WithCredentialProvider is used to attach the credentials of the provider to every request, including the
+// BatchCommands streams, which are recreated with new credentials before the old ones expire. The credentials are only
+// sent over TLS connections, the requests over the insecure connections fail.
+func WithCredentialProvider(provider CredentialProvider) Opt {
+	return func(c *option) {
+		c.credentials = &credentialCache{provider: provider}
+	}
+}
+
+// WithInsecureCredentialProvider is like WithCredentialProvider, but also sends the credentials over the insecure
+// connections, e.g. when the TLS is terminated by the authentication proxies in front of TiKV.
+func WithInsecureCredentialProvider(provider CredentialProvider) Opt {
+	return func(c *option) {
+		c.credentials = &credentialCache{provider: provider, allowInsecure: true}
+	}
+}
+
+// credentialCache caches the credential of the provider until it's about to expire. It implements
+// credentials.PerRPCCredentials to attach the credential to the gRPC calls.
+type credentialCache struct {
+	provider      CredentialProvider
+	allowInsecure bool
+
+	mu      sync.Mutex
+	current Credential
+	valid   bool
+}
+
+func needRefresh(expiry time.Time, now time.Time) bool {
+	return !expiry.IsZero() && !now.Before(expiry.Add(-credentialRefreshAhead))
+}
+
+func (c *credentialCache) get(ctx context.Context) (Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.valid && !needRefresh(c.current.Expiry, time.Now()) {
+		return c.current, nil
+	}
+	cred, err := c.provider.GetCredential(ctx)
+	if err != nil {
+		return Credential{}, errors.WithMessage(err, "get credential")
+	}
+	c.current, c.valid = cred, true
+	return cred, nil
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (c *credentialCache) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	cred, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{credentialMetadataKey: cred.Token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (c *credentialCache) RequireTransportSecurity() bool {
+	return !c.allowInsecure
+}
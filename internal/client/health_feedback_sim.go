@@ -0,0 +1,80 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+)
+
+// SimulatedHealthFeedbackListener can be implemented by a ClientEventListener to handle the feedback fed by a
+// HealthFeedbackSimulator differently, e.g. the region cache applies every simulated slow score immediately, while
+// the feedback pushed by TiKV too frequently is ignored.
+type SimulatedHealthFeedbackListener interface {
+	// OnSimulatedHealthFeedback is called instead of OnHealthFeedback with the simulated feedback.
+	OnSimulatedHealthFeedback(feedback *kvrpcpb.HealthFeedback)
+}
+
+// HealthFeedbackSimulator is a Client feeding synthetic HealthFeedback of the stores to its event listener, so the
+// listeners and the logic driven by the slow scores of the stores can be tested deterministically without TiKV
+// pushing the feedback. The requests are sent by the wrapped Client, which may be nil if no request is sent.
+type HealthFeedbackSimulator struct {
+	Client
+	listener atomic.Pointer[ClientEventListener]
+	seqNo    atomic.Uint64
+}
+
+// NewHealthFeedbackSimulator creates a HealthFeedbackSimulator wrapping c.
+func NewHealthFeedbackSimulator(c Client) *HealthFeedbackSimulator {
+	return &HealthFeedbackSimulator{Client: c}
+}
+
+// SetEventListener implements Client.
+func (s *HealthFeedbackSimulator) SetEventListener(listener ClientEventListener) {
+	s.listener.Store(&listener)
+	if s.Client != nil {
+		s.Client.SetEventListener(listener)
+	}
+}
+
+// unwrap implements wrappedClient.
+func (s *HealthFeedbackSimulator) unwrap() Client {
+	return s.Client
+}
+
+// Close implements Client.
+func (s *HealthFeedbackSimulator) Close() error {
+	if s.Client != nil {
+		return s.Client.Close()
+	}
+	return nil
+}
+
+// Feed feeds a HealthFeedback of the store with the slow score to the listener, and returns after the listener
+// handles it. The feedbacks are numbered in the order they're fed. It returns false if no listener is set.
+func (s *HealthFeedbackSimulator) Feed(storeID uint64, slowScore int32) bool {
+	l := s.listener.Load()
+	if l == nil || *l == nil {
+		return false
+	}
+	feedback := &kvrpcpb.HealthFeedback{StoreId: storeID, FeedbackSeqNo: s.seqNo.Add(1), SlowScore: slowScore}
+	if simListener, ok := (*l).(SimulatedHealthFeedbackListener); ok {
+		simListener.OnSimulatedHealthFeedback(feedback)
+	} else {
+		(*l).OnHealthFeedback(feedback)
+	}
+	return true
+}
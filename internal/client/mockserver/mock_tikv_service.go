@@ -34,6 +34,8 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // MockServer is a mock tikv server for testing purpose.
@@ -50,6 +52,8 @@ type MockServer struct {
 	}
 
 	OnBatchCommandsRequest atomic.Pointer[func(*tikvpb.BatchCommandsRequest) (*tikvpb.BatchCommandsResponse, error)]
+	// BatchCommandsUnsupported makes BatchCommands fail with Unimplemented, like a proxy that doesn't support it.
+	BatchCommandsUnsupported atomic.Bool
 }
 
 // KvGet implements the TikvServer interface.
@@ -81,6 +85,9 @@ func (s *MockServer) BatchCommands(ss tikvpb.Tikv_BatchCommandsServer) error {
 	if err := s.checkMetadata(ss.Context()); err != nil {
 		return err
 	}
+	if s.BatchCommandsUnsupported.Load() {
+		return status.Error(codes.Unimplemented, "unknown method BatchCommands")
+	}
 	var feedbackSeq uint64 = 1
 	for {
 		req, err := ss.Recv()
@@ -0,0 +1,209 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/metrics"
+)
+
+// SLOClass is a class of requests sharing a target latency.
+type SLOClass struct {
+	// Name is the name of the class, which is set to the context of the requests by WithSLOClass.
+	Name string
+	// TargetLatency is the latency the requests of the class are expected to finish within.
+	TargetLatency time.Duration
+	// Priority is the priority of the requests of the class in the batch queue, higher values are sent first. The
+	// classes of lower priorities are throttled to protect the ones of higher priorities missing their targets.
+	Priority uint64
+}
+
+// The defaults of SLOShaperConfig.
+const (
+	DefSLOThrottledConcurrency = 4
+	DefSLOProtectWindow        = time.Second
+)
+
+// SLOShaperConfig is the config of an SLOShaper.
+type SLOShaperConfig struct {
+	Classes []SLOClass
+	// DefaultClass is the class of the requests whose contexts have no known class. The requests aren't shaped if
+	// it's empty.
+	DefaultClass string
+	// ThrottledConcurrency is the max number of the running requests of a throttled class to a store,
+	// DefSLOThrottledConcurrency by default.
+	ThrottledConcurrency int
+	// ProtectWindow is how long the classes of lower priorities are throttled after a class misses its target on a
+	// store, DefSLOProtectWindow by default.
+	ProtectWindow time.Duration
+}
+
+type sloClassCtxKey struct{}
+
+// WithSLOClass returns a context whose requests are shaped as the class by an SLOShaper.
+func WithSLOClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, sloClassCtxKey{}, class)
+}
+
+// sloEWMAWeight is the weight of a new latency in the moving average of the latencies of a class.
+const sloEWMAWeight = 0.2
+
+// SLOShaper is an AdmissionController shaping the requests by their SLOClasses. It tracks the average latency of each
+// class to each store, and when a class misses its target on a store, e.g. because the store slows down, the running
+// requests of the classes of lower priorities to the store are limited by ThrottledConcurrency, so the store serves the
+// class of a higher priority first. The requests of a class are also queued by the priority of the class. The
+// attainment of each class is reported by the TiKVSLORequestCounter metrics.
+type SLOShaper struct {
+	cfg     SLOShaperConfig
+	classes map[string]*SLOClass
+
+	mu     sync.Mutex
+	stores map[string]*sloStoreState
+}
+
+// sloStoreState is the state of the classes of the requests to a store.
+type sloStoreState struct {
+	running map[string]int
+	avg     map[string]time.Duration
+	// missedAt is when each class missed its target on the store last time.
+	missedAt map[string]time.Time
+	// released is closed when a request to the store finishes, to wake up the throttled requests.
+	released chan struct{}
+}
+
+// NewSLOShaper creates an SLOShaper of the classes.
+func NewSLOShaper(cfg SLOShaperConfig) (*SLOShaper, error) {
+	if cfg.ThrottledConcurrency <= 0 {
+		cfg.ThrottledConcurrency = DefSLOThrottledConcurrency
+	}
+	if cfg.ProtectWindow <= 0 {
+		cfg.ProtectWindow = DefSLOProtectWindow
+	}
+	s := &SLOShaper{cfg: cfg, classes: make(map[string]*SLOClass, len(cfg.Classes)), stores: make(map[string]*sloStoreState)}
+	for i := range cfg.Classes {
+		class := &cfg.Classes[i]
+		if class.Name == "" || class.TargetLatency <= 0 {
+			return nil, errors.Errorf("invalid SLO class %q with target latency %v", class.Name, class.TargetLatency)
+		}
+		if _, ok := s.classes[class.Name]; ok {
+			return nil, errors.Errorf("duplicated SLO class %q", class.Name)
+		}
+		s.classes[class.Name] = class
+	}
+	if _, ok := s.classes[cfg.DefaultClass]; cfg.DefaultClass != "" && !ok {
+		return nil, errors.Errorf("unknown default SLO class %q", cfg.DefaultClass)
+	}
+	return s, nil
+}
+
+func (s *SLOShaper) classOf(ctx context.Context) *SLOClass {
+	if name, ok := ctx.Value(sloClassCtxKey{}).(string); ok {
+		if class, ok := s.classes[name]; ok {
+			return class
+		}
+	}
+	return s.classes[s.cfg.DefaultClass]
+}
+
+func (s *SLOShaper) store(addr string) *sloStoreState {
+	st, ok := s.stores[addr]
+	if !ok {
+		st = &sloStoreState{
+			running:  make(map[string]int),
+			avg:      make(map[string]time.Duration),
+			missedAt: make(map[string]time.Time),
+			released: make(chan struct{}),
+		}
+		s.stores[addr] = st
+	}
+	return st
+}
+
+// throttled tells whether the class is throttled on the store because a class of a higher priority missed its target
+// recently.
+func (s *SLOShaper) throttled(st *sloStoreState, class *SLOClass, now time.Time) bool {
+	for name, missedAt := range st.missedAt {
+		if s.classes[name].Priority > class.Priority && now.Sub(missedAt) < s.cfg.ProtectWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// Admit implements AdmissionController. It blocks the request of a throttled class until the running requests of the
+// class to the store are less than ThrottledConcurrency.
+func (s *SLOShaper) Admit(ctx context.Context, info AdmissionInfo) (uint64, error) {
+	class := s.classOf(ctx)
+	if class == nil {
+		return info.Priority, nil
+	}
+	waited := false
+	for {
+		s.mu.Lock()
+		st := s.store(info.Addr)
+		if st.running[class.Name] < s.cfg.ThrottledConcurrency || !s.throttled(st, class, time.Now()) {
+			st.running[class.Name]++
+			s.mu.Unlock()
+			return max(info.Priority, class.Priority), nil
+		}
+		released := st.released
+		s.mu.Unlock()
+		if !waited {
+			waited = true
+			metrics.TiKVSLOThrottledCounter.WithLabelValues(class.Name).Inc()
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return 0, errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// Done implements AdmissionFeedback.
+func (s *SLOShaper) Done(ctx context.Context, info AdmissionInfo, latency time.Duration, err error) {
+	class := s.classOf(ctx)
+	if class == nil {
+		return
+	}
+	result := "met"
+	if err != nil || latency > class.TargetLatency {
+		result = "missed"
+	}
+	metrics.TiKVSLORequestCounter.WithLabelValues(class.Name, result).Inc()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.store(info.Addr)
+	st.running[class.Name]--
+	if err == nil {
+		avg := st.avg[class.Name]
+		if avg == 0 {
+			avg = latency
+		} else {
+			avg = time.Duration(sloEWMAWeight*float64(latency) + (1-sloEWMAWeight)*float64(avg))
+		}
+		st.avg[class.Name] = avg
+		if avg > class.TargetLatency {
+			st.missedAt[class.Name] = time.Now()
+		}
+	}
+	close(st.released)
+	st.released = make(chan struct{})
+}
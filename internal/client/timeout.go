@@ -0,0 +1,54 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"time"
+
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// defaultTimeouts is the default timeouts of the requests that may read or write many key-values, e.g. scans and
+// coprocessor requests. The other requests, e.g. point gets, the requests of the transaction protocol and the raw
+// requests, use ReadTimeoutShort.
+var defaultTimeouts = map[tikvrpc.CmdType]time.Duration{
+	tikvrpc.CmdScan:                      ReadTimeoutMedium,
+	tikvrpc.CmdBatchGet:                  ReadTimeoutMedium,
+	tikvrpc.CmdBufferBatchGet:            ReadTimeoutMedium,
+	tikvrpc.CmdScanLock:                  ReadTimeoutMedium,
+	tikvrpc.CmdDeleteRange:               ReadTimeoutMedium,
+	tikvrpc.CmdFlashbackToVersion:        ReadTimeoutMedium,
+	tikvrpc.CmdPrepareFlashbackToVersion: ReadTimeoutMedium,
+	tikvrpc.CmdUnsafeDestroyRange:        ReadTimeoutMedium,
+	tikvrpc.CmdPhysicalScanLock:          ReadTimeoutMedium,
+	tikvrpc.CmdCop:                       ReadTimeoutMedium,
+	tikvrpc.CmdCopStream:                 ReadTimeoutMedium,
+	tikvrpc.CmdBatchCop:                  ReadTimeoutMedium,
+}
+
+// DefaultTimeout returns the timeout of the requests of the type when the caller doesn't specify one. It can be
+// overridden by config.TiKVClient.RequestTimeouts with the name of the type, e.g. "Get" or "Prewrite".
+func DefaultTimeout(cmd tikvrpc.CmdType) time.Duration {
+	if overrides := config.GetGlobalConfig().TiKVClient.RequestTimeouts; len(overrides) > 0 {
+		if timeout := overrides[cmd.String()]; timeout > 0 {
+			return timeout
+		}
+	}
+	if timeout, ok := defaultTimeouts[cmd]; ok {
+		return timeout
+	}
+	return ReadTimeoutShort
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// busyRedirectMinSamples is the number of the reads redirected to a store by which the redirection is evaluated.
+	busyRedirectMinSamples = 20
+	// busyRedirectDisableDuration is how long the reads aren't redirected to a store where the redirection never
+	// helps. The redirection is evaluated again after it.
+	busyRedirectDisableDuration = time.Minute
+
+	busyRedirectWon  = "won"
+	busyRedirectLost = "lost"
+)
+
+// busyRedirectStats tracks the effectiveness of the reads redirected to a follower store because the leader is busy,
+// i.e. the load-based replica reads, which win if they're served by the follower, and lose if they have to be retried
+// elsewhere. If none of busyRedirectMinSamples redirected reads wins, the reads aren't redirected to the store for
+// busyRedirectDisableDuration.
+type busyRedirectStats struct {
+	mu        sync.Mutex
+	won, lost int
+	// disabledUntil is the unix nano time until which the reads aren't redirected to the store.
+	disabledUntil atomic.Int64
+}
+
+// busyRedirectAllowed returns whether the reads of the busy leaders can be redirected to the store.
+func (s *Store) busyRedirectAllowed() bool {
+	until := s.busyRedirect.disabledUntil.Load()
+	if until == 0 {
+		return true
+	}
+	if time.Now().UnixNano() < until {
+		return false
+	}
+	if s.busyRedirect.disabledUntil.CompareAndSwap(until, 0) {
+		metrics.TiKVBusyRedirectDisabledGauge.WithLabelValues(strconv.FormatUint(s.storeID, 10)).Set(0)
+		logutil.BgLogger().Info("re-enable redirecting the reads of busy leaders to store",
+			zap.Uint64("store", s.storeID),
+			zap.String("addr", s.addr))
+	}
+	return true
+}
+
+// onBusyRedirect records whether a read redirected to the store wins, and disables the redirection if it never helps.
+func (s *Store) onBusyRedirect(won bool, now time.Time) {
+	storeLabel := strconv.FormatUint(s.storeID, 10)
+	result := busyRedirectLost
+	if won {
+		result = busyRedirectWon
+	}
+	metrics.TiKVBusyRedirectCounter.WithLabelValues(storeLabel, result).Inc()
+
+	stats := &s.busyRedirect
+	stats.mu.Lock()
+	if won {
+		stats.won++
+	} else {
+		stats.lost++
+	}
+	if stats.won+stats.lost < busyRedirectMinSamples {
+		stats.mu.Unlock()
+		return
+	}
+	disable := stats.won == 0
+	lost := stats.lost
+	stats.won, stats.lost = 0, 0
+	stats.mu.Unlock()
+	if !disable {
+		return
+	}
+	stats.disabledUntil.Store(now.Add(busyRedirectDisableDuration).UnixNano())
+	metrics.TiKVBusyRedirectDisabledGauge.WithLabelValues(storeLabel).Set(1)
+	logutil.BgLogger().Info("the reads of busy leaders redirected to store never win, disable the redirection",
+		zap.Uint64("store", s.storeID),
+		zap.String("addr", s.addr),
+		zap.Int("lost", lost),
+		zap.Duration("duration", busyRedirectDisableDuration))
+}
+
+// recordBusyRedirect records the outcome of the read redirected to an idle follower by the selector, if any.
+func (s *replicaSelector) recordBusyRedirect(won bool) {
+	if !s.redirected {
+		return
+	}
+	s.redirected = false
+	s.target.store.onBusyRedirect(won, time.Now())
+}
+
+// onFinish is called when the request using the selector is done. The redirected read that is neither served nor
+// retried, e.g. because the request fails or is canceled, loses.
+func (s *replicaSelector) onFinish() {
+	s.recordBusyRedirect(false)
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// Feature is a capability of TiKV that some request fields or commands depend on.
+type Feature int
+
+const (
+	// FeatureBucketsVersionHint means TiKV checks the buckets version carried by requests.
+	FeatureBucketsVersionHint Feature = iota
+	// FeatureHealthFeedback means TiKV feeds back its health status to the client.
+	FeatureHealthFeedback
+	// FeatureFlashback means TiKV supports flashing back regions to a version.
+	FeatureFlashback
+
+	numFeatures
+)
+
+// featureMinVersions are the min TiKV versions supporting the features.
+var featureMinVersions = [numFeatures]*semver.Version{
+	FeatureBucketsVersionHint: semver.New("6.6.0"),
+	FeatureHealthFeedback:     semver.New("8.2.0"),
+	FeatureFlashback:          semver.New("6.4.0"),
+}
+
+func (f Feature) String() string {
+	switch f {
+	case FeatureBucketsVersionHint:
+		return "BucketsVersionHint"
+	case FeatureHealthFeedback:
+		return "HealthFeedback"
+	case FeatureFlashback:
+		return "Flashback"
+	default:
+		return "Unknown"
+	}
+}
+
+// parseStoreVersion parses the version a store reports to PD, returns nil if it's invalid.
+func parseStoreVersion(version string) *semver.Version {
+	v, err := semver.NewVersion(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// supportedBy returns whether a store of the given version supports the feature. A store of
+// unknown version is considered not supporting any feature.
+func (f Feature) supportedBy(v *semver.Version) bool {
+	if f < 0 || f >= numFeatures || v == nil {
+		return false
+	}
+	// Ignore the pre-release and metadata so that nightly builds are treated as the released version.
+	return !semver.New(fmtVersion(v)).LessThan(*featureMinVersions[f])
+}
+
+func fmtVersion(v *semver.Version) string {
+	return semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}.String()
+}
+
+// featureGateRefreshInterval is how long the cluster-wide features are cached before they're
+// loaded again, so that the newly supported features are picked up after upgrading.
+const featureGateRefreshInterval = time.Minute
+
+// featureGate caches the features supported by all TiKV stores of the cluster.
+type featureGate struct {
+	registry storeRegistry
+	// loading merges the concurrent loads of the stores, which are done without holding mu.
+	loading singleflight.Group
+
+	mu struct {
+		sync.Mutex
+		loadedAt time.Time
+		// minVersion is the min version of TiKV stores, nil if any of them is unknown.
+		minVersion *semver.Version
+	}
+}
+
+func newFeatureGate(registry storeRegistry) *featureGate {
+	return &featureGate{registry: registry}
+}
+
+// supports returns whether all TiKV stores of the cluster support the feature. The stores are
+// loaded from PD at most once per featureGateRefreshInterval.
+func (g *featureGate) supports(ctx context.Context, f Feature) bool {
+	g.mu.Lock()
+	stale := g.mu.loadedAt.IsZero() || time.Since(g.mu.loadedAt) >= featureGateRefreshInterval
+	minVersion := g.mu.minVersion
+	g.mu.Unlock()
+	if !stale {
+		return f.supportedBy(minVersion)
+	}
+	v, err, _ := g.loading.Do("", func() (interface{}, error) {
+		stores, err := g.registry.fetchAllStores(ctx)
+		if err != nil {
+			return nil, err
+		}
+		minVersion := minTiKVVersion(stores)
+		g.mu.Lock()
+		g.mu.minVersion = minVersion
+		g.mu.loadedAt = time.Now()
+		g.mu.Unlock()
+		return minVersion, nil
+	})
+	if err != nil {
+		// Keep using the cached versions, which is considered not supporting anything if it's
+		// never loaded. Retry on the next call.
+		logutil.Logger(ctx).Info("load stores for feature gate failed", zap.Error(err))
+		return f.supportedBy(minVersion)
+	}
+	return f.supportedBy(v.(*semver.Version))
+}
+
+// minTiKVVersion returns the min version of the alive TiKV stores, nil if any of them is unknown.
+func minTiKVVersion(stores []*metapb.Store) *semver.Version {
+	var minVersion *semver.Version
+	for _, store := range stores {
+		if store.GetState() == metapb.StoreState_Tombstone || tikvrpc.GetStoreTypeByMeta(store) != tikvrpc.TiKV {
+			continue
+		}
+		v := parseStoreVersion(store.GetVersion())
+		if v == nil {
+			return nil
+		}
+		if minVersion == nil || v.LessThan(*minVersion) {
+			minVersion = v
+		}
+	}
+	return minVersion
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+)
+
+type mockStoreRegistry struct {
+	stores []*metapb.Store
+	err    error
+	loads  int
+	// fetching is notified and block blocks fetchAllStores until it's closed if they're not nil.
+	fetching chan struct{}
+	block    chan struct{}
+}
+
+func (r *mockStoreRegistry) fetchStore(ctx context.Context, id uint64) (*metapb.Store, error) {
+	for _, store := range r.stores {
+		if store.GetId() == id {
+			return store, nil
+		}
+	}
+	return nil, errors.New("store not found")
+}
+
+func (r *mockStoreRegistry) fetchAllStores(ctx context.Context) ([]*metapb.Store, error) {
+	r.loads++
+	if r.block != nil {
+		r.fetching <- struct{}{}
+		<-r.block
+	}
+	return r.stores, r.err
+}
+
+func TestFeatureSupportedBy(t *testing.T) {
+	require.False(t, FeatureHealthFeedback.supportedBy(nil))
+	require.False(t, FeatureHealthFeedback.supportedBy(parseStoreVersion("v8.1.2")))
+	require.True(t, FeatureHealthFeedback.supportedBy(parseStoreVersion("v8.2.0")))
+	require.True(t, FeatureHealthFeedback.supportedBy(parseStoreVersion("8.2.0-alpha-123-g0123456")))
+	require.True(t, FeatureFlashback.supportedBy(parseStoreVersion("v6.4.0")))
+	require.False(t, Feature(-1).supportedBy(parseStoreVersion("v8.2.0")))
+	require.False(t, numFeatures.supportedBy(parseStoreVersion("v8.2.0")))
+	require.Nil(t, parseStoreVersion("invalid"))
+}
+
+func TestFeatureGate(t *testing.T) {
+	ctx := context.Background()
+	registry := &mockStoreRegistry{err: errors.New("pd unavailable")}
+	gate := newFeatureGate(registry)
+	require.False(t, gate.supports(ctx, FeatureFlashback))
+	require.Equal(t, 1, registry.loads)
+
+	registry.err = nil
+	registry.stores = []*metapb.Store{
+		{Id: 1, Version: "v8.5.0"},
+		{Id: 2, Version: "v7.5.0"},
+		{Id: 3, Version: "v5.0.0", State: metapb.StoreState_Tombstone},
+		{Id: 4, Version: "v5.0.0", Labels: []*metapb.StoreLabel{{Key: "engine", Value: "tiflash"}}},
+	}
+	require.True(t, gate.supports(ctx, FeatureFlashback))
+	require.False(t, gate.supports(ctx, FeatureHealthFeedback))
+	// The stores are cached until the refresh interval elapses.
+	require.Equal(t, 2, registry.loads)
+
+	registry.stores = append(registry.stores, &metapb.Store{Id: 5, Version: "unknown"})
+	gate.mu.loadedAt = gate.mu.loadedAt.Add(-featureGateRefreshInterval)
+	require.False(t, gate.supports(ctx, FeatureFlashback))
+	require.Equal(t, 3, registry.loads)
+
+	// The lock isn't held while loading the stores.
+	registry.block = make(chan struct{})
+	gate.mu.loadedAt = gate.mu.loadedAt.Add(-featureGateRefreshInterval)
+	registry.fetching = make(chan struct{})
+	done := make(chan bool)
+	go func() { done <- gate.supports(ctx, FeatureFlashback) }()
+	<-registry.fetching
+	require.True(t, gate.mu.TryLock())
+	gate.mu.Unlock()
+	close(registry.block)
+	require.False(t, <-done)
+	require.Equal(t, 4, registry.loads)
+}
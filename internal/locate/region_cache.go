@@ -652,10 +652,17 @@ type RegionCache struct {
 
 	requestHealthFeedbackCallback func(ctx context.Context, addr string) error
 
+	// storeFallbackAddrs returns the fallback addresses of a store, see SetStoreFallbackAddrs.
+	storeFallbackAddrs atomic.Pointer[func(storeID uint64, addr string) []string]
+	// writeStalls detects the stores stalling the writes, see SetWriteStallDetector.
+	writeStalls atomic.Pointer[WriteStallDetector]
+
 	mu regionIndexMu
 
 	stores storeCache
 
+	features *featureGate
+
 	// runner for background jobs
 	bg *bgRunner
 
@@ -697,6 +704,7 @@ func NewRegionCache(pdClient pd.Client, opt ...RegionCacheOpt) *RegionCache {
 	}
 
 	c.stores = newStoreCache(pdClient)
+	c.features = newFeatureGate(c.stores)
 	c.bg = newBackgroundRunner(context.Background())
 	c.enableForwarding = config.GetGlobalConfig().EnableForwarding
 	if c.pdClient != nil {
@@ -785,10 +793,12 @@ func refreshFullStoreList(ctx context.Context, stores storeCache) {
 		s := stores.getOrInsertDefault(store.GetId())
 		// TODO: maybe refactor this, together with other places initializing Store
 		s.addr = addr
+		s.metaAddr = addr
 		s.peerAddr = store.GetPeerAddress()
 		s.saddr = store.GetStatusAddress()
 		s.storeType = tikvrpc.GetStoreTypeByMeta(store)
 		s.labels = store.GetLabels()
+		s.setOffline(store.GetState() == metapb.StoreState_Offline)
 		s.changeResolveStateTo(unresolved, resolved)
 	}
 }
@@ -842,6 +852,17 @@ func (c *RegionCache) SetRegionCacheStore(id uint64, addr string, peerAddr strin
 	c.stores.put(newStore(id, addr, peerAddr, "", storeType, resolveState(state), labels))
 }
 
+// SetMockStoreLiveness makes the health check of the stores ask live instead of requesting the stores, for testing
+// only. It lets the stores of a mock cluster become reachable again after they're restarted.
+func (c *RegionCache) SetMockStoreLiveness(live func(storeID uint64) bool) {
+	c.stores.setMockRequestLiveness(func(ctx context.Context, s *Store) livenessState {
+		if live(s.storeID) {
+			return reachable
+		}
+		return unreachable
+	})
+}
+
 // SetPDClient replaces pd client,for testing only
 func (c *RegionCache) SetPDClient(client pd.Client) {
 	c.pdClient = client
@@ -882,6 +903,8 @@ type storeSelectorOp struct {
 	preferLeader bool
 	labels       []*metapb.StoreLabel
 	stores       []uint64
+	// learnerFallback is used by ReplicaReadLearner requests when no learner is available.
+	learnerFallback kv.LearnerReadFallback
 }
 
 // StoreSelectorOption configures storeSelectorOp.
@@ -915,6 +938,13 @@ func WithMatchStores(stores []uint64) StoreSelectorOption {
 	}
 }
 
+// WithLearnerReadFallback sets where ReplicaReadLearner requests go when no learner replica is available.
+func WithLearnerReadFallback(fallback kv.LearnerReadFallback) StoreSelectorOption {
+	return func(op *storeSelectorOp) {
+		op.learnerFallback = fallback
+	}
+}
+
 // GetTiKVRPCContext returns RPCContext for a region. If it returns nil, the region
 // must be out of date and already dropped from cache.
 func (c *RegionCache) GetTiKVRPCContext(bo *retry.Backoffer, id RegionVerID, replicaRead kv.ReplicaReadType, followerStoreSeed uint32, opts ...StoreSelectorOption) (*RPCContext, error) {
@@ -1268,6 +1298,19 @@ func (c *RegionCache) LocateKeyRange(bo *retry.Backoffer, startKey, endKey []byt
 	return res, nil
 }
 
+// NextKeyLocation returns the locate function of util.SplitRangeByRegions that returns the located regions in order,
+// e.g. the ones returned by LocateKeyRange for the same range.
+func NextKeyLocation(locs []*KeyLocation) func(key []byte) (*KeyLocation, []byte, error) {
+	return func(key []byte) (*KeyLocation, []byte, error) {
+		if len(locs) == 0 {
+			return nil, nil, errors.Errorf("no region is located for key %q", key)
+		}
+		loc := locs[0]
+		locs = locs[1:]
+		return loc, loc.EndKey, nil
+	}
+}
+
 type batchLocateKeyRangesOption struct {
 	// whether load leader only, if it's set to true, regions without leader will be skipped.
 	// Note there is leader even the leader is invalid or outdated.
@@ -1872,6 +1915,34 @@ func (c *RegionCache) InvalidateCachedRegionWithReason(id RegionVerID, reason In
 	cachedRegion.invalidate(reason)
 }
 
+// InvalidateKey removes the cached Region that contains the key. If refetch is
+// true, the Region is loaded from PD again before returning and its location is
+// returned, otherwise the returned location is nil.
+// It's useful for tools that changed the region (e.g. split it or transferred its
+// leader) and want the cache to reflect the change deterministically.
+func (c *RegionCache) InvalidateKey(bo *retry.Backoffer, key []byte, refetch bool) (*KeyLocation, error) {
+	if r, _ := c.searchCachedRegionByKey(key, false); r != nil {
+		r.invalidate(Other)
+	}
+	if !refetch {
+		return nil, nil
+	}
+	return c.LocateKey(bo, key)
+}
+
+// InvalidateRegionID removes the cached Region with the given ID. If refetch is
+// true, the Region is loaded from PD again before returning and its location is
+// returned, otherwise the returned location is nil.
+func (c *RegionCache) InvalidateRegionID(bo *retry.Backoffer, regionID uint64, refetch bool) (*KeyLocation, error) {
+	if r, _ := c.searchCachedRegionByID(regionID); r != nil {
+		r.invalidate(Other)
+	}
+	if !refetch {
+		return nil, nil
+	}
+	return c.LocateRegionByID(bo, regionID)
+}
+
 // UpdateLeader update some region cache with newer leader info.
 func (c *RegionCache) UpdateLeader(regionID RegionVerID, leader *metapb.Peer, currentPeerIdx AccessIndex) {
 	r := c.GetCachedRegionWithRLock(regionID)
@@ -1904,6 +1975,24 @@ func (c *RegionCache) UpdateLeader(regionID RegionVerID, leader *metapb.Peer, cu
 	}
 }
 
+// HintLeader switches the cached leader of the region to its voter on the store, e.g. when the leader is known to be
+// transferred by an orchestrator, so the requests are sent to the new leader without meeting NotLeader first. It
+// returns false if the region isn't cached or has no voter on the store.
+func (c *RegionCache) HintLeader(regionID, storeID uint64) bool {
+	r, _ := c.searchCachedRegionByID(regionID)
+	if r == nil {
+		return false
+	}
+	peer := r.getPeerOnStore(storeID)
+	if peer == nil || peer.GetRole() == metapb.PeerRole_Learner || !r.switchWorkLeaderToPeer(peer) {
+		return false
+	}
+	logutil.BgLogger().Info("switch region leader to specific leader due to leader hint",
+		zap.Uint64("regionID", regionID),
+		zap.Uint64("leaderStoreID", storeID))
+	return true
+}
+
 // removeVersionFromCache removes a RegionVerID from cache, tries to cleanup
 // both c.mu.regions and c.mu.versions. Note this function is not thread-safe.
 func (mu *regionIndexMu) removeVersionFromCache(oldVer RegionVerID, regionID uint64) {
@@ -2023,6 +2112,50 @@ func (c *RegionCache) GetAllStores() []*Store {
 	})
 }
 
+// SupportsFeature returns whether all TiKV stores of the cluster support the feature. New request
+// fields or commands should only be used when it returns true.
+func (c *RegionCache) SupportsFeature(ctx context.Context, f Feature) bool {
+	return c.features.supports(ctx, f)
+}
+
+// StoreSummary is the summary of a cached store.
+type StoreSummary struct {
+	ID           uint64
+	Addr         string
+	Type         string
+	ResolveState string
+	Liveness     string
+}
+
+// RegionCacheSummary is the summary of the region cache for diagnosis.
+type RegionCacheSummary struct {
+	// Regions is the number of cached regions, including the expired ones.
+	Regions int
+	// ValidRegions is the number of cached regions that aren't expired.
+	ValidRegions int
+	Stores       []StoreSummary
+}
+
+// Summary returns the summary of the region cache.
+func (c *RegionCache) Summary() RegionCacheSummary {
+	var summary RegionCacheSummary
+	c.mu.RLock()
+	summary.Regions = len(c.mu.regions)
+	summary.ValidRegions = c.mu.sorted.ValidRegionsInBtree(time.Now().Unix())
+	c.mu.RUnlock()
+	c.stores.forEach(func(s *Store) {
+		summary.Stores = append(summary.Stores, StoreSummary{
+			ID:           s.storeID,
+			Addr:         s.GetAddr(),
+			Type:         s.storeType.Name(),
+			ResolveState: s.getResolveState().String(),
+			Liveness:     s.getLivenessState().String(),
+		})
+	})
+	sort.Slice(summary.Stores, func(i, j int) bool { return summary.Stores[i].ID < summary.Stores[j].ID })
+	return summary
+}
+
 var loadRegionCounters sync.Map
 
 const (
@@ -3131,6 +3264,16 @@ func (c *RegionCache) onHealthFeedback(feedback *kvrpcpb.HealthFeedback) {
 	store.recordHealthFeedback(feedback)
 }
 
+func (c *RegionCache) onSimulatedHealthFeedback(feedback *kvrpcpb.HealthFeedback) {
+	store, ok := c.stores.get(feedback.GetStoreId())
+	if !ok {
+		return
+	}
+	// Every simulated slow score is applied, regardless of the update interval of the feedback pushed by TiKV.
+	store.healthStatus.setTiKVSlowScoreLastUpdateTimeForTest(time.Now().Add(-tikvSlowScoreUpdateInterval))
+	store.recordHealthFeedback(feedback)
+}
+
 // GetClientEventListener returns the listener to observe the RPC client's events and let the region cache respond to
 // them. When creating the `KVStore` using `tikv.NewKVStore` function, the listener will be setup immediately.
 func (c *RegionCache) GetClientEventListener() client.ClientEventListener {
@@ -3146,3 +3289,18 @@ type regionCacheClientEventListener struct {
 func (l *regionCacheClientEventListener) OnHealthFeedback(feedback *kvrpcpb.HealthFeedback) {
 	l.c.onHealthFeedback(feedback)
 }
+
+// OnSimulatedHealthFeedback implements the `client.SimulatedHealthFeedbackListener` interface.
+func (l *regionCacheClientEventListener) OnSimulatedHealthFeedback(feedback *kvrpcpb.HealthFeedback) {
+	l.c.onSimulatedHealthFeedback(feedback)
+}
+
+// OnStoreDraining implements the `client.DrainEventListener` interface.
+func (l *regionCacheClientEventListener) OnStoreDraining(addr string) {
+	l.c.onStoreDraining(addr)
+}
+
+// OnStoreReconnected implements the `client.DrainEventListener` interface.
+func (l *regionCacheClientEventListener) OnStoreReconnected(addr string) {
+	l.c.onStoreReconnected(addr)
+}
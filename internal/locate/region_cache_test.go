@@ -65,6 +65,8 @@ import (
 	"github.com/tikv/pd/client/clients/router"
 	"github.com/tikv/pd/client/opt"
 	uatomic "go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type inspectedPDClient struct {
@@ -332,6 +334,49 @@ func (s *testRegionCacheSuite) getAddr(key []byte, replicaRead kv.ReplicaReadTyp
 	return ctx.Addr
 }
 
+func (s *testRegionCacheSuite) TestRotateStoreAddr() {
+	addr := s.storeAddr(s.store1)
+	s.Equal(addr, s.getAddr([]byte("a"), kv.ReplicaReadLeader, 0))
+	store, _ := s.cache.stores.get(s.store1)
+	// The store has no other address.
+	s.False(s.cache.rotateStoreAddr(store, addr))
+
+	s.cache.SetStoreFallbackAddrs(func(storeID uint64, addr string) []string {
+		return []string{addr, "alt-" + addr}
+	})
+	store.offline.Store(true)
+	store.busyRedirect.disabledUntil.Store(time.Now().Add(time.Minute).UnixNano())
+	s.True(s.cache.rotateStoreAddr(store, addr))
+	s.Equal(deleted, store.getResolveState())
+	// The store is already switched by another request.
+	s.False(s.cache.rotateStoreAddr(store, addr))
+	s.Equal("alt-"+addr, s.getAddr([]byte("a"), kv.ReplicaReadLeader, 0))
+	// The state of the store is kept.
+	newStore, _ := s.cache.stores.get(s.store1)
+	s.True(newStore.offline.Load())
+	s.False(newStore.busyRedirectAllowed())
+
+	// Re-resolving the store doesn't switch it back as its advertised address isn't changed.
+	ok, err := newStore.reResolve(s.cache.stores, s.cache.bg)
+	s.True(ok)
+	s.Nil(err)
+	s.Equal(resolved, newStore.getResolveState())
+
+	// The addresses are rotated.
+	s.True(s.cache.rotateStoreAddr(newStore, "alt-"+addr))
+	s.Equal(addr, s.getAddr([]byte("a"), kv.ReplicaReadLeader, 0))
+
+	s.True(isDialError(fmt.Errorf("send request: %w", status.Error(codes.Unavailable,
+		`connection error: desc = "transport: Error while dialing: dial tcp 127.0.0.1:20160: connect: connection refused"`))))
+	s.True(isDialError(status.Error(codes.Unavailable,
+		`connection error: desc = "transport: authentication handshake failed: tls: bad certificate"`)))
+	// The errors of an established connection are not dial errors.
+	s.False(isDialError(status.Error(codes.Unavailable, "transport is closing")))
+	s.False(isDialError(status.Error(codes.Unavailable, "error reading from server: EOF")))
+	s.False(isDialError(status.Error(codes.DeadlineExceeded, "deadline exceeded")))
+	s.False(isDialError(errors.New("connection refused")))
+}
+
 func (s *testRegionCacheSuite) TestStoreLabels() {
 	testcases := []struct {
 		storeID uint64
@@ -1274,6 +1319,37 @@ func (s *testRegionCacheSuite) TestMerge() {
 	s.checkCache(1)
 }
 
+func (s *testRegionCacheSuite) TestInvalidateKeyAndRegionID() {
+	r := s.getRegion([]byte("x"))
+	s.Equal(r.GetID(), s.region1)
+
+	// split to ['' - 'm' - 'z'] without notifying the cache.
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, region2, []byte("m"), newPeers, newPeers[0])
+
+	loc, err := s.cache.InvalidateKey(s.bo, []byte("x"), false)
+	s.Nil(err)
+	s.Nil(loc)
+	s.checkCache(0)
+
+	loc, err = s.cache.InvalidateKey(s.bo, []byte("x"), true)
+	s.Nil(err)
+	s.Equal(loc.Region.id, region2)
+	s.checkCache(1)
+
+	loc, err = s.cache.InvalidateRegionID(s.bo, region2, true)
+	s.Nil(err)
+	s.Equal(loc.Region.id, region2)
+	s.Equal(loc.StartKey, []byte("m"))
+	s.checkCache(1)
+
+	loc, err = s.cache.InvalidateRegionID(s.bo, region2, false)
+	s.Nil(err)
+	s.Nil(loc)
+	s.checkCache(0)
+}
+
 func (s *testRegionCacheSuite) TestReconnect() {
 	seed := rand.Uint32()
 	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
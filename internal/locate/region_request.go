@@ -118,6 +118,7 @@ type RegionRequestSender struct {
 	failProxyStoreIDs map[uint64]struct{}
 	Stats             *RegionRequestRuntimeStats
 	AccessStats       *ReplicaAccessStats
+	transitionHook    SendReqTransitionHook
 }
 
 func (s *RegionRequestSender) String() string {
@@ -412,6 +413,12 @@ func NewRegionRequestSender(regionCache *RegionCache, client client.Client, read
 	}
 }
 
+// SetTransitionHook sets a hook called on every transition of the retry state machine, which
+// is useful to observe how the sender reacts to errors in tests.
+func (s *RegionRequestSender) SetTransitionHook(hook SendReqTransitionHook) {
+	s.transitionHook = hook
+}
+
 // GetRegionCache returns the region cache.
 func (s *RegionRequestSender) GetRegionCache() *RegionCache {
 	return s.regionCache
@@ -500,6 +507,9 @@ func (s *RegionRequestSender) SendReqAsync(
 	}
 
 	cb.Inject(func(resp *tikvrpc.ResponseExt, err error) (*tikvrpc.ResponseExt, error) {
+		if s.replicaSelector != nil {
+			s.replicaSelector.onFinish()
+		}
 		retryTimes := 0
 		if state.vars.sendTimes > 1 {
 			retryTimes = state.vars.sendTimes - 1
@@ -516,6 +526,8 @@ func (s *RegionRequestSender) SendReqAsync(
 			}
 		}
 
+		state.recordServed(bo.GetCtx(), time.Since(startTime))
+
 		// metrics
 		if retryTimes > 0 {
 			metrics.TiKVRequestRetryTimesHistogram.Observe(float64(retryTimes))
@@ -725,13 +737,14 @@ func (s *baseReplicaSelector) buildRPCContext(bo *retry.Backoffer, targetReplica
 	}
 
 	rpcCtx := &RPCContext{
-		ClusterID:  s.regionCache.clusterID,
-		Region:     s.region.VerID(),
-		Meta:       s.region.meta,
-		Peer:       targetReplica.peer,
-		Store:      targetReplica.store,
-		AccessMode: tiKVOnly,
-		TiKVNum:    len(s.replicas),
+		ClusterID:     s.regionCache.clusterID,
+		Region:        s.region.VerID(),
+		Meta:          s.region.meta,
+		Peer:          targetReplica.peer,
+		Store:         targetReplica.store,
+		AccessMode:    tiKVOnly,
+		TiKVNum:       len(s.replicas),
+		BucketVersion: s.region.getStore().buckets.GetVersion(),
 	}
 
 	// Set leader addr
@@ -860,6 +873,57 @@ func (s *RegionRequestSender) reset() {
 
 const slowLogSendReqTime = 100 * time.Millisecond
 
+// SendReqPhase is a phase of the retry state machine of RegionRequestSender.
+type SendReqPhase int
+
+const (
+	// SendReqPhaseHandleError handles the send error or region error of the last attempt.
+	SendReqPhaseHandleError SendReqPhase = iota
+	// SendReqPhaseSelectReplica selects the replica to send the request to.
+	SendReqPhaseSelectReplica
+	// SendReqPhaseSend sends the request to the selected replica.
+	SendReqPhaseSend
+	// SendReqPhaseRetry means the attempt failed with an error that should be handled and retried.
+	SendReqPhaseRetry
+	// SendReqPhaseDone means the request is finished, either succeeded or failed.
+	SendReqPhaseDone
+)
+
+func (p SendReqPhase) String() string {
+	switch p {
+	case SendReqPhaseHandleError:
+		return "HandleError"
+	case SendReqPhaseSelectReplica:
+		return "SelectReplica"
+	case SendReqPhaseSend:
+		return "Send"
+	case SendReqPhaseRetry:
+		return "Retry"
+	case SendReqPhaseDone:
+		return "Done"
+	default:
+		return "Unknown"
+	}
+}
+
+// SendReqTransition describes a transition of the retry state machine of RegionRequestSender.
+type SendReqTransition struct {
+	From SendReqPhase
+	To   SendReqPhase
+	// SendTimes is the number of times the request has been sent.
+	SendTimes int
+	// Addr and StoreID are the target of the request if a replica has been selected.
+	Addr    string
+	StoreID uint64
+	// RegionErr and Err are the errors pending to be handled or returned.
+	RegionErr *errorpb.Error
+	Err       error
+}
+
+// SendReqTransitionHook is called on every transition of the retry state machine of
+// RegionRequestSender. It's called synchronously and must not modify the request.
+type SendReqTransitionHook func(t SendReqTransition)
+
 // sendReqArgs defines the input arguments of the send request.
 type sendReqArgs struct {
 	bo       *retry.Backoffer
@@ -889,6 +953,9 @@ type sendReqState struct {
 	}
 
 	invariants reqInvariants
+
+	// trace collects the timeline of the request if the slow rpc tracer is set.
+	trace *rpcTraceBuilder
 }
 
 // reqInvariants holds the input state of the request.
@@ -902,14 +969,58 @@ type reqInvariants struct {
 // (s.vars.regionErr) if one of them exists. When the error is retriable, `next` then constructs a new RPCContext and
 // sends the request again. `next` returns true if the retry loop should stop, either because the request is done or
 // exhausted (cannot complete by retrying).
+//
+// An iteration walks through the phases SendReqPhaseHandleError -> SendReqPhaseSelectReplica -> SendReqPhaseSend and
+// ends in SendReqPhaseRetry or SendReqPhaseDone. Each transition is reported to the transition hook of the sender.
 func (s *sendReqState) next() (done bool) {
+	phase := SendReqPhaseHandleError
+	for phase != SendReqPhaseRetry && phase != SendReqPhaseDone {
+		var to SendReqPhase
+		switch phase {
+		case SendReqPhaseHandleError:
+			to = s.handleError()
+		case SendReqPhaseSelectReplica:
+			to = s.selectReplica()
+		case SendReqPhaseSend:
+			to = s.sendToReplica()
+		default:
+			panic(fmt.Sprintf("unexpected send request phase %v", phase))
+		}
+		s.onTransition(phase, to)
+		phase = to
+	}
+	return phase == SendReqPhaseDone
+}
+
+func (s *sendReqState) onTransition(from, to SendReqPhase) {
+	if s.transitionHook == nil {
+		return
+	}
+	t := SendReqTransition{
+		From:      from,
+		To:        to,
+		SendTimes: s.vars.sendTimes,
+		RegionErr: s.vars.regionErr,
+		Err:       s.vars.err,
+	}
+	if s.vars.rpcCtx != nil {
+		t.Addr = s.vars.rpcCtx.Addr
+		if s.vars.rpcCtx.Store != nil {
+			t.StoreID = s.vars.rpcCtx.Store.storeID
+		}
+	}
+	s.transitionHook(t)
+}
+
+// handleError handles the send error or region error of the last attempt and decides whether to retry.
+func (s *sendReqState) handleError() SendReqPhase {
 	bo, req := s.args.bo, s.args.req
 
 	// check whether the session/query is killed during the Next()
 	if req.IsInterruptible() {
 		if err := bo.CheckKilled(); err != nil {
 			s.vars.resp, s.vars.err = nil, err
-			return true
+			return SendReqPhaseDone
 		}
 	}
 
@@ -918,7 +1029,7 @@ func (s *sendReqState) next() (done bool) {
 		if e := s.onSendFail(bo, s.vars.rpcCtx, req, s.vars.err); e != nil {
 			s.vars.rpcCtx, s.vars.resp = nil, nil
 			s.vars.msg = fmt.Sprintf("failed to handle send error: %v", s.vars.err)
-			return true
+			return SendReqPhaseDone
 		}
 		s.vars.err = nil
 	}
@@ -930,14 +1041,20 @@ func (s *sendReqState) next() (done bool) {
 			s.vars.rpcCtx, s.vars.resp = nil, nil
 			s.vars.err = err
 			s.vars.msg = fmt.Sprintf("failed to handle region error: %v", err)
-			return true
+			return SendReqPhaseDone
 		}
 		if !retry {
 			s.vars.msg = fmt.Sprintf("met unretriable region error: %T", s.vars.regionErr)
-			return true
+			return SendReqPhaseDone
 		}
 		s.vars.regionErr = nil
 	}
+	return SendReqPhaseSelectReplica
+}
+
+// selectReplica picks the replica to send the request to and prepares the request for it.
+func (s *sendReqState) selectReplica() SendReqPhase {
+	bo, req := s.args.bo, s.args.req
 
 	s.vars.rpcCtx, s.vars.resp = nil, nil
 	if !req.IsRetryRequest && s.vars.sendTimes > 0 {
@@ -946,7 +1063,7 @@ func (s *sendReqState) next() (done bool) {
 
 	s.vars.rpcCtx, s.vars.err = s.getRPCContext(bo, req, s.args.regionID, s.args.et, s.args.opts...)
 	if s.vars.err != nil {
-		return true
+		return SendReqPhaseDone
 	}
 
 	if _, err := util.EvalFailpoint("invalidCacheAndRetry"); err == nil {
@@ -954,7 +1071,7 @@ func (s *sendReqState) next() (done bool) {
 		if c := bo.GetCtx().Value("injectedBackoff"); c != nil {
 			s.vars.regionErr = &errorpb.Error{EpochNotMatch: &errorpb.EpochNotMatch{}}
 			s.vars.resp, s.vars.err = tikvrpc.GenRegionErrorResp(req, s.vars.regionErr)
-			return true
+			return SendReqPhaseDone
 		}
 	}
 
@@ -968,13 +1085,13 @@ func (s *sendReqState) next() (done bool) {
 		// and handle this error like EpochNotMatch, which means to re-split the request and retry.
 		if s.replicaSelector != nil {
 			if s.vars.err = s.replicaSelector.backoffOnNoCandidate(bo); s.vars.err != nil {
-				return true
+				return SendReqPhaseDone
 			}
 		}
 		s.vars.regionErr = &errorpb.Error{EpochNotMatch: &errorpb.EpochNotMatch{}}
 		s.vars.resp, s.vars.err = tikvrpc.GenRegionErrorResp(req, s.vars.regionErr)
 		s.vars.msg = "throwing pseudo region error due to no replica available"
-		return true
+		return SendReqPhaseDone
 	}
 
 	// should reset the access location after shifting to the next store.
@@ -989,13 +1106,20 @@ func (s *sendReqState) next() (done bool) {
 	}
 	// RPCClient.SendRequest will attach `req.Context` thus skip attaching here to reduce overhead.
 	if s.vars.err = tikvrpc.SetContextNoAttach(req, s.vars.rpcCtx.Meta, s.vars.rpcCtx.Peer); s.vars.err != nil {
-		return true
+		return SendReqPhaseDone
 	}
+	setBucketsVersionHint(req, s.vars.rpcCtx)
 	if s.replicaSelector != nil {
 		if s.vars.err = s.replicaSelector.backoffOnRetry(s.vars.rpcCtx.Store, bo); s.vars.err != nil {
-			return true
+			return SendReqPhaseDone
 		}
 	}
+	return SendReqPhaseSend
+}
+
+// sendToReplica sends the request to the selected replica and classifies the result.
+func (s *sendReqState) sendToReplica() SendReqPhase {
+	bo, req := s.args.bo, s.args.req
 
 	if _, err := util.EvalFailpoint("beforeSendReqToRegion"); err == nil {
 		if hook := bo.GetCtx().Value("sendReqToRegionHook"); hook != nil {
@@ -1007,7 +1131,7 @@ func (s *sendReqState) next() (done bool) {
 	// judge the store limit switch.
 	if limit := kv.StoreLimit.Load(); limit > 0 {
 		if s.vars.err = s.getStoreToken(s.vars.rpcCtx.Store, limit); s.vars.err != nil {
-			return true
+			return SendReqPhaseDone
 		}
 		defer s.releaseStoreToken(s.vars.rpcCtx.Store)
 	}
@@ -1020,34 +1144,34 @@ func (s *sendReqState) next() (done bool) {
 		// we need to retry the request. But for context cancel active, for example, limitExec gets the required rows,
 		// we shouldn't retry the request, it will go to backoff and hang in retry logic.
 		if canceled {
-			return true
+			return SendReqPhaseDone
 		}
 		if val, e := util.EvalFailpoint("noRetryOnRpcError"); e == nil && val.(bool) {
-			return true
+			return SendReqPhaseDone
 		}
 		// need to handle send error
-		return false
+		return SendReqPhaseRetry
 	}
 
 	if val, err := util.EvalFailpoint("mockRetrySendReqToRegion"); err == nil && val.(bool) {
 		// force retry
-		return false
+		return SendReqPhaseRetry
 	}
 
 	s.vars.regionErr, s.vars.err = s.vars.resp.GetRegionError()
 	if s.vars.err != nil {
 		s.vars.rpcCtx, s.vars.resp = nil, nil
-		return true
+		return SendReqPhaseDone
 	} else if s.vars.regionErr != nil {
 		// need to handle region error
-		return false
+		return SendReqPhaseRetry
 	}
 
 	if s.replicaSelector != nil {
 		s.replicaSelector.onSendSuccess(req)
 	}
 
-	return true
+	return SendReqPhaseDone
 }
 
 func (s *sendReqState) send() (canceled bool) {
@@ -1112,9 +1236,21 @@ func (s *sendReqState) send() (canceled bool) {
 	}
 
 	if !injectFailOnSend {
+		var timeline client.RPCTimeline
+		sendCtx := ctx
+		if s.trace != nil {
+			sendCtx = client.WithRPCTimeline(ctx, &timeline)
+		}
 		start := time.Now()
-		s.vars.resp, s.vars.err = s.client.SendRequest(ctx, sendToAddr, req, s.args.timeout)
+		s.vars.resp, s.vars.err = s.client.SendRequest(sendCtx, sendToAddr, req, s.args.timeout)
 		rpcDuration := time.Since(start)
+		if s.trace != nil {
+			s.trace.onAttempt(sendToAddr, rpcDuration, &timeline, s.vars.resp, s.vars.err)
+		}
+		if d := s.regionCache.writeStalls.Load(); d != nil && s.vars.err == nil && s.vars.rpcCtx.Store != nil &&
+			(req.IsTxnWriteRequest() || req.IsRawWriteRequest()) {
+			d.observe(s.vars.rpcCtx.Store, s.vars.resp, time.Now())
+		}
 		if s.replicaSelector != nil {
 			recordAttemptedTime(s.replicaSelector, rpcDuration)
 		}
@@ -1130,6 +1266,9 @@ func (s *sendReqState) send() (canceled bool) {
 		collector.onReq(req, execDetails)
 		collector.onResp(req, s.vars.resp, execDetails)
 
+		if rpcCtx.Store != nil {
+			rpcCtx.Store.healthStatus.recordRPCStat(rpcDuration, s.vars.err)
+		}
 		// Record timecost of external requests on related Store when `ReplicaReadMode == "PreferLeader"`.
 		if rpcCtx.Store != nil && req.ReplicaReadType == kv.ReplicaReadPreferLeader && !util.IsInternalRequest(req.RequestSource) {
 			rpcCtx.Store.healthStatus.recordClientSideSlowScoreStat(rpcDuration)
@@ -1241,6 +1380,7 @@ func (s *sendReqState) initForAsyncRequest() (ok bool) {
 	if s.vars.err = tikvrpc.SetContextNoAttach(req, s.vars.rpcCtx.Meta, s.vars.rpcCtx.Peer); s.vars.err != nil {
 		return false
 	}
+	setBucketsVersionHint(req, s.vars.rpcCtx)
 
 	// Count the replica number as the RU cost factor.
 	req.ReplicaNumber = 1
@@ -1257,6 +1397,16 @@ func (s *sendReqState) initForAsyncRequest() (ok bool) {
 	return true
 }
 
+// setBucketsVersionHint attaches the buckets version of the region to the request if the target store
+// uses partitioned-raft-kv, which lets TiKV reject requests built with stale buckets by
+// BucketVersionNotMatch instead of serving them across the changed buckets. The buckets version set by
+// the caller is left as is for the other stores.
+func setBucketsVersionHint(req *tikvrpc.Request, rpcCtx *RPCContext) {
+	if rpcCtx.Store != nil && rpcCtx.Store.IsPartitionedRaftKV() {
+		req.Context.BucketsVersion = rpcCtx.BucketVersion
+	}
+}
+
 // setReqAccessLocation set the AccessLocation value of kv request based on
 // target store "zone" label.
 func (s *sendReqState) setReqAccessLocation(req *tikvrpc.Request) {
@@ -1302,6 +1452,9 @@ func (s *sendReqState) handleAsyncResponse(start time.Time, canceled bool, resp
 	collector.onReq(req, execDetails)
 	collector.onResp(req, resp, execDetails)
 
+	if s.vars.rpcCtx.Store != nil {
+		s.vars.rpcCtx.Store.healthStatus.recordRPCStat(rpcDuration, s.vars.err)
+	}
 	if s.vars.rpcCtx.Store != nil && req.ReplicaReadType == kv.ReplicaReadPreferLeader && !util.IsInternalRequest(req.RequestSource) {
 		s.vars.rpcCtx.Store.healthStatus.recordClientSideSlowScoreStat(rpcDuration)
 	}
@@ -1352,6 +1505,36 @@ func (s *sendReqState) handleAsyncResponse(start time.Time, canceled bool, resp
 	return true
 }
 
+// recordServed records where the request is finally served to the exec details in the context if it's collected.
+func (s *sendReqState) recordServed(ctx context.Context, cost time.Duration) {
+	rpcCtx := s.vars.rpcCtx
+	if s.vars.err != nil || rpcCtx == nil {
+		return
+	}
+	execDetails, ok := ctx.Value(util.ExecDetailsKey).(*util.ExecDetails)
+	if !ok || execDetails.ReqServed == nil {
+		return
+	}
+	attempted := 1
+	if s.replicaSelector != nil {
+		attempted = 0
+		for _, replica := range s.replicaSelector.replicas {
+			if replica.attempts > 0 {
+				attempted++
+			}
+		}
+	}
+	execDetails.ReqServed.Record(util.ReqServedInfo{
+		ReqType:           s.args.req.Type.String(),
+		RegionID:          rpcCtx.Region.GetID(),
+		PeerID:            rpcCtx.Peer.GetId(),
+		StoreID:           rpcCtx.Peer.GetStoreId(),
+		StoreAddr:         rpcCtx.Addr,
+		AttemptedReplicas: max(attempted, 1),
+		Duration:          cost,
+	})
+}
+
 // toResponseExt converts the state to a ResponseExt .
 func (s *sendReqState) toResponseExt() (*tikvrpc.ResponseExt, error) {
 	if s.vars.err != nil {
@@ -1436,9 +1619,13 @@ func (s *RegionRequestSender) SendReqCtx(
 		invariants: reqInvariants{
 			staleRead: req.StaleRead,
 		},
+		trace: newRPCTraceBuilder(bo),
 	}
 
 	defer func() {
+		if s.replicaSelector != nil {
+			s.replicaSelector.onFinish()
+		}
 		if retryTimes := state.vars.sendTimes - 1; retryTimes > 0 {
 			metrics.TiKVRequestRetryTimesHistogram.Observe(float64(retryTimes))
 		}
@@ -1479,6 +1666,10 @@ func (s *RegionRequestSender) SendReqCtx(
 			s.logSendReqError(bo, msg, regionID, retryTimes, req, cost, bo.GetTotalSleep()-startBackOff, timeout)
 		}
 	}
+	if state.trace != nil {
+		state.trace.finish(bo, req, regionID, startTime, err)
+	}
+	state.recordServed(bo.GetCtx(), time.Since(startTime))
 
 	return
 }
@@ -1685,6 +1876,25 @@ func (s *RegionRequestSender) onSendFail(bo *retry.Backoffer, ctx *RPCContext, r
 	if errors.As(err, &errGetResourceGroup) {
 		return err
 	}
+	// don't need to retry for the request rejected by the admission controller of the client
+	var errRejected *tikverr.ErrRequestRejected
+	if errors.As(err, &errRejected) {
+		return err
+	}
+	// don't need to retry for the request failing the validation of the client
+	var errInvalid *tikverr.ErrInvalidRequest
+	if errors.As(err, &errInvalid) {
+		return err
+	}
+
+	// Switch the store failed to dial to its next address, which is used by the retry.
+	if isDialError(err) {
+		if ctx.ProxyStore != nil {
+			s.regionCache.rotateStoreAddr(ctx.ProxyStore, ctx.ProxyAddr)
+		} else if ctx.Store != nil {
+			s.regionCache.rotateStoreAddr(ctx.Store, ctx.Addr)
+		}
+	}
 
 	if ctx.Store != nil && ctx.Store.storeType == tikvrpc.TiFlashCompute {
 		s.regionCache.InvalidateTiFlashComputeStoresIfGRPCError(err)
@@ -1707,13 +1917,25 @@ func (s *RegionRequestSender) onSendFail(bo *retry.Backoffer, ctx *RPCContext, r
 		)
 	} else {
 		err = bo.Backoff(
-			retry.BoTiKVRPC,
+			storeBackoffConfig(retry.BoTiKVRPC, ctx.Store),
 			errors.Errorf("send tikv request error: %v, ctx: %v, try next peer later", err, ctx),
 		)
 	}
 	return err
 }
 
+// storeBackoffConfig returns cfg scaled by the backoff multiplier of the override of the store, if any.
+func storeBackoffConfig(cfg *retry.Config, store *Store) *retry.Config {
+	if store == nil || len(config.GetGlobalConfig().TiKVClient.PerStoreOverrides) == 0 {
+		return cfg
+	}
+	o := config.GetGlobalConfig().TiKVClient.GetStoreOverride(store.storeID, store.GetAddr())
+	if o == nil || o.BackoffMultiplier <= 1 {
+		return cfg
+	}
+	return cfg.Scaled(o.BackoffMultiplier)
+}
+
 func isCauseByDeadlineExceeded(err error) bool {
 	causeErr := errors.Cause(err)
 	return causeErr == context.DeadlineExceeded || // batch-client will return this error.
@@ -1810,6 +2032,8 @@ func regionErrorToLabel(e *errorpb.Error) string {
 		return "bucket_version_not_match"
 	} else if isInvalidMaxTsUpdate(e) {
 		return "invalid_max_ts_update"
+	} else if isClusterIDMismatch(e) {
+		return "cluster_id_mismatch"
 	} else if e.GetUndeterminedResult() != nil {
 		return "undetermined_result"
 	}
@@ -1824,6 +2048,12 @@ func isInvalidMaxTsUpdate(e *errorpb.Error) bool {
 	return strings.Contains(e.GetMessage(), "invalid max_ts update")
 }
 
+// isClusterIDMismatch tells whether the store rejects the request because the cluster ID in its context isn't the one
+// of the store.
+func isClusterIDMismatch(e *errorpb.Error) bool {
+	return strings.Contains(strings.ToLower(e.GetMessage()), "cluster id mismatch")
+}
+
 func (s *RegionRequestSender) onRegionError(
 	bo *retry.Backoffer, ctx *RPCContext, req *tikvrpc.Request, regionErr *errorpb.Error,
 ) (shouldRetry bool, err error) {
@@ -1840,6 +2070,15 @@ func (s *RegionRequestSender) onRegionError(
 		s.recordRPCAccessInfo(req, ctx, regionErrorToLogging(regionErr, regionErrLabel))
 	}
 
+	if isClusterIDMismatch(regionErr) {
+		// Never retry the request sent to a store of another cluster, which may write the data to the wrong cluster.
+		metrics.TiKVClusterIDMismatchCounter.WithLabelValues("tikv").Inc()
+		err = &tikverr.ErrClusterIDMismatch{Source: "tikv", Addr: ctx.Addr, Expected: req.Context.ClusterId}
+		logutil.Logger(bo.GetCtx()).Error("the store belongs to another cluster",
+			zap.Error(err), zap.String("message", regionErr.GetMessage()))
+		return false, err
+	}
+
 	if regionErr.GetUndeterminedResult() != nil {
 		// should not retry for `UndeterminedResult` because this error should be processed by the caller.
 		return false, nil
@@ -1994,7 +2233,11 @@ func (s *RegionRequestSender) onRegionError(
 		if ctx != nil && ctx.Store != nil && ctx.Store.storeType.IsTiFlashRelatedType() {
 			err = bo.Backoff(retry.BoTiFlashServerBusy, errors.Errorf("server is busy, ctx: %v", ctx))
 		} else {
-			err = bo.Backoff(retry.BoTiKVServerBusy, errors.Errorf("server is busy, ctx: %v", ctx))
+			var store *Store
+			if ctx != nil {
+				store = ctx.Store
+			}
+			err = bo.Backoff(storeBackoffConfig(retry.BoTiKVServerBusy, store), errors.Errorf("server is busy, ctx: %v", ctx))
 		}
 		if err != nil {
 			return false, err
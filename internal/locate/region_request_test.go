@@ -69,6 +69,7 @@ import (
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/oracle/oracles"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
 	"github.com/tikv/client-go/v2/util/async"
 	pd "github.com/tikv/pd/client"
 	pderr "github.com/tikv/pd/client/errs"
@@ -182,6 +183,148 @@ func (s *testRegionRequestToSingleStoreSuite) TestOnRegionError() {
 	s.Run("AsyncAPI", test)
 }
 
+func (s *testRegionRequestToSingleStoreSuite) TestTransitionHook() {
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	})
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	s.NotNil(region)
+
+	oc := s.regionRequestSender.client
+	defer func() {
+		s.regionRequestSender.client = oc
+		s.regionRequestSender.SetTransitionHook(nil)
+	}()
+	sendTimes := 0
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		sendTimes++
+		if sendTimes == 1 {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{
+				RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}},
+			}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	var transitions []SendReqTransition
+	s.regionRequestSender.SetTransitionHook(func(t SendReqTransition) {
+		transitions = append(transitions, t)
+	})
+	bo := retry.NewBackofferWithVars(context.Background(), 1000, nil)
+	resp, _, err := s.regionRequestSender.SendReq(bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.NotNil(resp)
+
+	expected := [][2]SendReqPhase{
+		{SendReqPhaseHandleError, SendReqPhaseSelectReplica},
+		{SendReqPhaseSelectReplica, SendReqPhaseSend},
+		{SendReqPhaseSend, SendReqPhaseRetry},
+		{SendReqPhaseHandleError, SendReqPhaseSelectReplica},
+		{SendReqPhaseSelectReplica, SendReqPhaseSend},
+		{SendReqPhaseSend, SendReqPhaseDone},
+	}
+	s.Len(transitions, len(expected))
+	for i, t := range transitions {
+		s.Equal(expected[i], [2]SendReqPhase{t.From, t.To}, "transition %d", i)
+	}
+	s.NotNil(transitions[2].RegionErr.GetServerIsBusy())
+	s.Equal(1, transitions[2].SendTimes)
+	s.Equal(2, transitions[5].SendTimes)
+}
+
+func (s *testRegionRequestToSingleStoreSuite) TestReqServedDetails() {
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	s.NotNil(region)
+
+	oc := s.regionRequestSender.client
+	defer func() {
+		s.regionRequestSender.client = oc
+	}()
+	sendTimes := 0
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		sendTimes++
+		if sendTimes == 1 {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{
+				RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}},
+			}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	send := func(ctx context.Context) {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("key"), Value: []byte("value")})
+		_, _, err := s.regionRequestSender.SendReq(retry.NewBackofferWithVars(ctx, 1000, nil), req, region.Region, time.Second)
+		s.Nil(err)
+	}
+
+	// Nothing is collected unless it's set.
+	execDetails := &util.ExecDetails{}
+	send(context.WithValue(context.Background(), util.ExecDetailsKey, execDetails))
+	s.Nil(execDetails.ReqServed)
+
+	execDetails.ReqServed = &util.ReqServedDetails{}
+	sendTimes = 0
+	send(context.WithValue(context.Background(), util.ExecDetailsKey, execDetails))
+	send(context.WithValue(context.Background(), util.ExecDetailsKey, execDetails))
+	s.Equal(2, execDetails.ReqServed.Count())
+	slowest := execDetails.ReqServed.Slowest()
+	s.Equal("RawPut", slowest.ReqType)
+	s.Equal(s.region, slowest.RegionID)
+	s.Equal(s.peer, slowest.PeerID)
+	s.Equal(s.store, slowest.StoreID)
+	s.Equal(1, slowest.AttemptedReplicas)
+	s.Equal(map[uint64]int{s.store: 2}, execDetails.ReqServed.StoreReqCounts())
+	s.Contains(execDetails.ReqServed.String(), "req_served: {num: 2")
+}
+
+func (s *testRegionRequestToSingleStoreSuite) TestPartitionedRaftKVBucketsHint() {
+	s.cluster.SplitRegionBuckets(s.region, [][]byte{{}, []byte("b"), {}}, 7)
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	s.NotNil(region)
+
+	oc := s.regionRequestSender.client
+	defer func() {
+		s.regionRequestSender.client = oc
+	}()
+	var bucketsVersion uint64
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		bucketsVersion = req.Context.GetBucketsVersion()
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	sendWithVersion := func(version uint64) {
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("key"), Value: []byte("value")})
+		req.Context.BucketsVersion = version
+		_, _, err := s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+		s.Nil(err)
+	}
+	send := func() { sendWithVersion(0) }
+
+	// The hint is not attached unless the cluster is declared as partitioned-raft-kv.
+	send()
+	s.Zero(bucketsVersion)
+	// The buckets version set by the caller is kept.
+	sendWithVersion(3)
+	s.Equal(uint64(3), bucketsVersion)
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.PartitionedRaftKV = true
+	})()
+	// The version of the store is unknown.
+	send()
+	s.Zero(bucketsVersion)
+
+	store, ok := s.cache.stores.get(s.store)
+	s.True(ok)
+	store.setVersion("6.5.0")
+	send()
+	s.Zero(bucketsVersion)
+	store.setVersion("v7.5.0")
+	send()
+	s.Equal(uint64(7), bucketsVersion)
+}
+
 func (s *testRegionRequestToSingleStoreSuite) TestOnSendFailByResourceGroupThrottled() {
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
 		Key:   []byte("key"),
@@ -1049,6 +1192,26 @@ func (s *testRegionRequestToSingleStoreSuite) TestClusterIDInReq() {
 	s.Nil(regionErr)
 }
 
+func (s *testRegionRequestToSingleStoreSuite) TestClusterIDMismatch() {
+	count := 0
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		count++
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{
+			RegionError: &errorpb.Error{Message: "Cluster ID mismatch, local 2 != request 1"},
+		}}, nil
+	}}
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Nil(err)
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a"), Version: 1})
+	_, _, err = s.regionRequestSender.SendReq(retry.NewBackofferWithVars(context.Background(), 2000, nil), req, region.Region, time.Second)
+	var errMismatch *tikverr.ErrClusterIDMismatch
+	s.ErrorAs(err, &errMismatch)
+	s.Equal("tikv", errMismatch.Source)
+	s.Equal(req.ClusterId, errMismatch.Expected)
+	// The request isn't retried.
+	s.Equal(1, count)
+}
+
 type emptyClient struct {
 	client.Client
 }
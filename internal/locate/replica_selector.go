@@ -38,6 +38,8 @@ type replicaSelector struct {
 	target          *replica
 	proxy           *replica
 	attempts        int
+	// redirected tells whether the target is an idle follower the read of the busy leader is redirected to.
+	redirected bool
 }
 
 func newReplicaSelector(
@@ -104,6 +106,8 @@ func (s *replicaSelector) next(bo *retry.Backoffer, req *tikvrpc.Request) (rpcCt
 	}
 
 	s.attempts++
+	// The redirected read has to be retried, so the redirection doesn't help.
+	s.recordBusyRedirect(false)
 	s.target = nil
 	s.proxy = nil
 	switch s.replicaReadType {
@@ -132,13 +136,16 @@ func (s *replicaSelector) nextForReplicaReadLeader(req *tikvrpc.Request) {
 	leaderIdx := s.region.getStore().workTiKVIdx
 	strategy := ReplicaSelectLeaderStrategy{leaderIdx: leaderIdx}
 	s.target = strategy.next(s.replicas)
-	if s.target != nil && s.busyThreshold > 0 && s.isReadOnlyReq && (s.target.store.EstimatedWaitTime() > s.busyThreshold || s.target.hasFlag(serverIsBusyFlag)) {
+	if s.target != nil && s.target.store.IsDraining() {
+		s.avoidDrainingLeader(req, leaderIdx)
+	} else if s.target != nil && s.busyThreshold > 0 && s.isReadOnlyReq && (s.target.store.EstimatedWaitTime() > s.busyThreshold || s.target.hasFlag(serverIsBusyFlag)) {
 		// If the leader is busy in our estimation, try other idle replicas.
 		// If other replicas are all busy, tryIdleReplica will try the leader again without busy threshold.
 		mixedStrategy := ReplicaSelectMixedStrategy{leaderIdx: leaderIdx, busyThreshold: s.busyThreshold}
 		idleTarget := mixedStrategy.next(s)
 		if idleTarget != nil {
 			s.target = idleTarget
+			s.redirected = true
 			req.ReplicaRead = true
 		} else {
 			// No threshold if all peers are too busy, remove busy threshold and still use leader.
@@ -158,6 +165,23 @@ func (s *replicaSelector) nextForReplicaReadLeader(req *tikvrpc.Request) {
 	}
 }
 
+// avoidDrainingLeader is called when the store of the leader is draining. The region is reloaded soon to find the new
+// leader, which is usually transferred away before the store stops, and a read is sent to a follower by replica read
+// if there is one available.
+func (s *replicaSelector) avoidDrainingLeader(req *tikvrpc.Request, leaderIdx AccessIndex) {
+	s.region.setSyncFlags(needDelayedReloadPending)
+	if !s.isReadOnlyReq || s.option.leaderOnly {
+		return
+	}
+	strategy := ReplicaSelectMixedStrategy{leaderIdx: leaderIdx, avoidLeader: true}
+	if follower := strategy.next(s); follower != nil {
+		s.target = follower
+		req.ReplicaRead = true
+	} else {
+		req.ReplicaRead = false
+	}
+}
+
 func (s *replicaSelector) nextForReplicaReadMixed(req *tikvrpc.Request) {
 	leaderIdx := s.region.getStore().workTiKVIdx
 	if s.isStaleRead && s.attempts == 2 {
@@ -179,6 +203,8 @@ func (s *replicaSelector) nextForReplicaReadMixed(req *tikvrpc.Request) {
 		learnerOnly:  req.ReplicaReadType == kv.ReplicaReadLearner,
 		labels:       s.option.labels,
 		stores:       s.option.stores,
+
+		learnerFallback: s.option.learnerFallback,
 	}
 	s.target = strategy.next(s)
 	if s.target != nil {
@@ -255,6 +281,10 @@ type ReplicaSelectMixedStrategy struct {
 	labels        []*metapb.StoreLabel
 	stores        []uint64
 	busyThreshold time.Duration
+	// avoidLeader excludes the leader, e.g. when its store is draining.
+	avoidLeader bool
+	// learnerFallback limits the non-learner candidates when learnerOnly is set.
+	learnerFallback kv.LearnerReadFallback
 }
 
 func (s *ReplicaSelectMixedStrategy) next(selector *replicaSelector) *replica {
@@ -292,8 +322,8 @@ func (s *ReplicaSelectMixedStrategy) next(selector *replicaSelector) *replica {
 		idx := maxScoreIdxes[randIntn(len(maxScoreIdxes))]
 		return replicas[idx]
 	}
-	if s.busyThreshold > 0 {
-		// when can't find an idle replica, no need to invalidate region.
+	if s.busyThreshold > 0 || s.avoidLeader {
+		// when can't find an idle replica or a follower, no need to invalidate region.
 		return nil
 	}
 	// when meet deadline exceeded error, do fast retry without invalidate region cache.
@@ -343,7 +373,20 @@ func (s *ReplicaSelectMixedStrategy) isCandidate(r *replica, isLeader bool, epoc
 	if s.leaderOnly && !isLeader {
 		return false
 	}
-	if s.busyThreshold > 0 && (r.store.EstimatedWaitTime() > s.busyThreshold || r.hasFlag(serverIsBusyFlag) || isLeader) {
+	if s.avoidLeader && isLeader {
+		return false
+	}
+	if s.learnerOnly && r.peer.Role != metapb.PeerRole_Learner {
+		switch s.learnerFallback {
+		case kv.LearnerReadFallbackLeader:
+			if !isLeader {
+				return false
+			}
+		case kv.LearnerReadFallbackNone:
+			return false
+		}
+	}
+	if s.busyThreshold > 0 && (r.store.EstimatedWaitTime() > s.busyThreshold || r.hasFlag(serverIsBusyFlag) || isLeader || !r.store.busyRedirectAllowed()) {
 		return false
 	}
 	if s.preferLeader && r.store.healthStatus.IsSlow() && !isLeader {
@@ -359,12 +402,14 @@ type storeSelectionScore int64
 const (
 	// The definition of the score is:
 	// MSB                                                                                                         LSB
-	// [unused bits][1 bit: NotSlow][1 bit: LabelMatches][1 bit: PreferLeader][1 bit: NormalPeer][1 bit: NotAttempted]
+	// [unused bits][1 bit: Learner][1 bit: NotSlow][1 bit: LabelMatches][1 bit: PreferLeader][1 bit: NormalPeer][1 bit: NotAttempted]
 	flagNotAttempted storeSelectionScore = 1 << iota
 	flagNormalPeer
 	flagPreferLeader
 	flagLabelMatches
 	flagNotSlow
+	// flagLearner is only set for learner-only reads, so that learners are always selected before other replicas.
+	flagLearner
 )
 
 func (s storeSelectionScore) String() string {
@@ -378,6 +423,9 @@ func (s storeSelectionScore) String() string {
 		}
 		res += name
 	}
+	if (s & flagLearner) != 0 {
+		appendFactor("Learner")
+	}
 	if (s & flagNotSlow) != 0 {
 		appendFactor("NotSlow")
 	}
@@ -423,13 +471,14 @@ func (s *ReplicaSelectMixedStrategy) calculateScore(r *replica, isLeader bool) s
 	} else {
 		if s.learnerOnly {
 			if r.peer.Role == metapb.PeerRole_Learner {
-				score |= flagNormalPeer
+				score |= flagLearner | flagNormalPeer
 			}
 		} else {
 			score |= flagNormalPeer
 		}
 	}
-	if !r.store.healthStatus.IsSlow() {
+	// A draining store is about to stop, it's avoided as a slow one.
+	if !r.store.healthStatus.IsSlow() && !r.store.IsDraining() {
 		score |= flagNotSlow
 	}
 	if r.attempts == 0 {
@@ -541,14 +590,15 @@ func (s *replicaSelector) onServerIsBusy(
 		}
 	}
 	backoffErr := errors.Errorf("server is busy, ctx: %v", ctx)
+	backoffCfg := storeBackoffConfig(retry.BoTiKVServerBusy, store)
 	if s.canFastRetry() {
-		s.addPendingBackoff(store, retry.BoTiKVServerBusy, backoffErr)
+		s.addPendingBackoff(store, backoffCfg, backoffErr)
 		if s.target != nil {
 			s.target.addFlag(serverIsBusyFlag)
 		}
 		return true, nil
 	}
-	err = bo.Backoff(retry.BoTiKVServerBusy, backoffErr)
+	err = bo.Backoff(backoffCfg, backoffErr)
 	if err != nil {
 		return false, err
 	}
@@ -614,6 +664,7 @@ func (s *replicaSelector) onSendFailure(bo *retry.Backoffer, err error) {
 }
 
 func (s *replicaSelector) onSendSuccess(req *tikvrpc.Request) {
+	s.recordBusyRedirect(true)
 	if s.proxy != nil && s.target != nil {
 		for idx, r := range s.replicas {
 			if r.peer.Id == s.proxy.peer.Id {
@@ -227,6 +227,174 @@ func TestReplicaSelectorCalculateScore(t *testing.T) {
 	}
 }
 
+func TestReplicaSelectorDrainingLeader(t *testing.T) {
+	s := new(testReplicaSelectorSuite)
+	s.SetupTest(t)
+	defer s.TearDownTest()
+
+	rc := s.getRegion()
+	leader := rc.getStore().stores[rc.getStore().workTiKVIdx]
+	s.cache.GetClientEventListener().(client.DrainEventListener).OnStoreDraining(leader.addr)
+	s.True(leader.IsDraining())
+	for _, store := range rc.getStore().stores {
+		s.Equal(store == leader, store.IsDraining())
+	}
+
+	// A read of the leader is sent to a follower by replica read.
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")})
+	selector, err := newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	rpcCtx, err := selector.next(s.bo, req)
+	s.Nil(err)
+	s.NotEqual(leader.storeID, rpcCtx.Store.storeID)
+	s.True(req.ReplicaRead)
+	s.True(rc.checkSyncFlags(needDelayedReloadPending))
+
+	// A write is still sent to the leader.
+	req = tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{})
+	selector, err = newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	rpcCtx, err = selector.next(s.bo, req)
+	s.Nil(err)
+	s.Equal(leader.storeID, rpcCtx.Store.storeID)
+
+	// The leader is read if no follower is available.
+	req = tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")})
+	selector, err = newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	for _, r := range selector.replicas {
+		if r.store != leader {
+			r.attempts = 1
+		}
+	}
+	rpcCtx, err = selector.next(s.bo, req)
+	s.Nil(err)
+	s.Equal(leader.storeID, rpcCtx.Store.storeID)
+	s.False(req.ReplicaRead)
+
+	// The store is no longer draining once it's connected again.
+	s.cache.GetClientEventListener().(client.DrainEventListener).OnStoreReconnected(leader.addr)
+	s.False(leader.IsDraining())
+	leader.setOffline(true)
+	s.True(leader.IsDraining())
+	leader.setOffline(false)
+	s.False(leader.IsDraining())
+}
+
+func TestReplicaSelectorBusyRedirect(t *testing.T) {
+	s := new(testReplicaSelectorSuite)
+	s.SetupTest(t)
+	defer s.TearDownTest()
+
+	rc := s.getRegion()
+	leader := rc.getStore().stores[rc.getStore().workTiKVIdx]
+	leader.updateServerLoadStats(60000)
+	newReq := func() *tikvrpc.Request {
+		req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")})
+		req.BusyThresholdMs = 10
+		return req
+	}
+
+	// A redirected read served by the follower wins.
+	req := newReq()
+	selector, err := newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	rpcCtx, err := selector.next(s.bo, req)
+	s.Nil(err)
+	s.NotEqual(leader.storeID, rpcCtx.Store.storeID)
+	s.True(req.ReplicaRead)
+	selector.onSendSuccess(req)
+	s.False(selector.redirected)
+	s.Equal(1, rpcCtx.Store.busyRedirect.won)
+	selector.onFinish()
+	s.Equal(0, rpcCtx.Store.busyRedirect.lost)
+
+	// A redirected read that fails without being retried loses.
+	req = newReq()
+	selector, err = newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	rpcCtx, err = selector.next(s.bo, req)
+	s.Nil(err)
+	s.True(selector.redirected)
+	selector.onFinish()
+	s.False(selector.redirected)
+	s.Equal(1, rpcCtx.Store.busyRedirect.lost)
+	rpcCtx.Store.busyRedirect.lost = 0
+
+	// The reads aren't redirected to the followers once the redirected reads never win.
+	for i := 0; i < 4*busyRedirectMinSamples; i++ {
+		req = newReq()
+		selector, err = newReplicaSelector(s.cache, rc.VerID(), req)
+		s.Nil(err)
+		rpcCtx, err = selector.next(s.bo, req)
+		s.Nil(err)
+		if rpcCtx.Store == leader {
+			break
+		}
+		_, err = selector.next(s.bo, req)
+		s.Nil(err)
+	}
+	s.Equal(leader.storeID, rpcCtx.Store.storeID)
+	s.False(req.ReplicaRead)
+	for _, store := range rc.getStore().stores {
+		if store != leader {
+			s.False(store.busyRedirectAllowed())
+		}
+	}
+
+	// The redirection is allowed again after the disabled duration.
+	for _, store := range rc.getStore().stores {
+		if store != leader {
+			store.busyRedirect.disabledUntil.Store(time.Now().Add(-time.Second).UnixNano())
+			s.True(store.busyRedirectAllowed())
+			s.Zero(store.busyRedirect.disabledUntil.Load())
+		}
+	}
+	req = newReq()
+	selector, err = newReplicaSelector(s.cache, rc.VerID(), req)
+	s.Nil(err)
+	rpcCtx, err = selector.next(s.bo, req)
+	s.Nil(err)
+	s.NotEqual(leader.storeID, rpcCtx.Store.storeID)
+}
+
+func TestReplicaSelectorLearnerReadFallback(t *testing.T) {
+	s := new(testReplicaSelectorSuite)
+	s.SetupTest(t)
+	defer s.TearDownTest()
+
+	req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, kv.ReplicaReadLearner, nil, kvrpcpb.Context{})
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	selector, err := newReplicaSelector(s.cache, loc.Region, req)
+	s.Nil(err)
+	// Make the replica in store3 a learner.
+	learner := selector.replicas[2]
+	s.Equal(uint64(3), learner.store.storeID)
+	learner.peer = &metapb.Peer{Id: learner.peer.Id, StoreId: learner.peer.StoreId, Role: metapb.PeerRole_Learner}
+
+	leaderIdx := selector.region.getStore().workTiKVIdx
+	labels := []*metapb.StoreLabel{{Key: "id", Value: "2"}}
+	newStrategy := func(fallback kv.LearnerReadFallback) ReplicaSelectMixedStrategy {
+		return ReplicaSelectMixedStrategy{leaderIdx: leaderIdx, learnerOnly: true, labels: labels, learnerFallback: fallback}
+	}
+
+	// The learner is selected even if the labels of another replica match.
+	for _, fallback := range []kv.LearnerReadFallback{kv.LearnerReadFallbackAny, kv.LearnerReadFallbackLeader, kv.LearnerReadFallbackNone} {
+		strategy := newStrategy(fallback)
+		s.Equal(learner, strategy.next(selector), fallback.String())
+	}
+
+	// Fall back according to the policy once the learner is exhausted.
+	learner.attempts = 1
+	strategy := newStrategy(kv.LearnerReadFallbackAny)
+	s.Equal(uint64(2), strategy.next(selector).store.storeID)
+	strategy = newStrategy(kv.LearnerReadFallbackLeader)
+	s.Equal(uint64(1), strategy.next(selector).store.storeID)
+	strategy = newStrategy(kv.LearnerReadFallbackNone)
+	s.Nil(strategy.next(selector))
+}
+
 func TestCanFastRetry(t *testing.T) {
 	s := new(testReplicaSelectorSuite)
 	s.SetupTest(t)
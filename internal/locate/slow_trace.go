@@ -0,0 +1,388 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSlowRPCTraceMaxSizeMB is the size of the trace file that triggers a rotation if MaxSizeMB is 0.
+	defaultSlowRPCTraceMaxSizeMB = 100
+	slowRPCTraceBackupTimeFormat = "2006-01-02T15-04-05.000"
+	// slowRPCTraceQueueSize is the number of traces waiting to be written, more traces are dropped.
+	slowRPCTraceQueueSize = 1024
+)
+
+// SlowRPCTraceConfig is the config of a SlowRPCTracer.
+type SlowRPCTraceConfig struct {
+	// Threshold is the latency, including retries and backoffs, above which a request may be traced.
+	Threshold time.Duration
+	// SampleRate is the fraction in [0, 1] of the slow requests that are traced.
+	SampleRate float64
+	// Filename is the file the traces are written to, one JSON object per line.
+	Filename string
+	// MaxSizeMB is the size of the file that triggers a rotation, 0 means 100MB.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files to keep, 0 means keeping all of them.
+	MaxBackups int
+}
+
+// SlowRPCTracer writes the timelines of sampled slow requests sent by RegionRequestSender to a rotating file for
+// offline analysis. The traces are written by a background goroutine, so a slow disk doesn't stall the requests, and
+// the traces are dropped if too many of them are waiting to be written.
+type SlowRPCTracer struct {
+	cfg SlowRPCTraceConfig
+
+	traces    chan *RPCTrace
+	dropped   atomic.Uint64
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	// w is only accessed by the background goroutine, and closeErr is set by it before done is closed.
+	w        *rotatingFile
+	closeErr error
+}
+
+// NewSlowRPCTracer creates a SlowRPCTracer.
+func NewSlowRPCTracer(cfg SlowRPCTraceConfig) (*SlowRPCTracer, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("filename of slow rpc traces is empty")
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, errors.Errorf("invalid sample rate %v of slow rpc traces", cfg.SampleRate)
+	}
+	t := &SlowRPCTracer{
+		cfg:     cfg,
+		traces:  make(chan *RPCTrace, slowRPCTraceQueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		w:       newRotatingFile(cfg.Filename, cfg.MaxSizeMB, cfg.MaxBackups),
+	}
+	go t.run()
+	return t, nil
+}
+
+// Close writes the traces waiting in the queue and closes the file of the tracer.
+func (t *SlowRPCTracer) Close() error {
+	t.closeOnce.Do(func() { close(t.closing) })
+	<-t.done
+	return t.closeErr
+}
+
+func (t *SlowRPCTracer) sample(cost time.Duration) bool {
+	return cost >= t.cfg.Threshold && t.cfg.SampleRate > 0 && rand.Float64() < t.cfg.SampleRate
+}
+
+// write queues the trace without blocking, the trace is dropped if the queue is full or the tracer is closed.
+func (t *SlowRPCTracer) write(trace *RPCTrace) {
+	select {
+	case <-t.closing:
+		return
+	default:
+	}
+	select {
+	case t.traces <- trace:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *SlowRPCTracer) run() {
+	defer close(t.done)
+	for {
+		select {
+		case trace := <-t.traces:
+			t.writeTrace(trace)
+		case <-t.closing:
+			for {
+				select {
+				case trace := <-t.traces:
+					t.writeTrace(trace)
+				default:
+					t.closeErr = t.w.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *SlowRPCTracer) writeTrace(trace *RPCTrace) {
+	if dropped := t.dropped.Swap(0); dropped > 0 {
+		logutil.BgLogger().Warn("slow rpc traces are dropped because the queue is full", zap.Uint64("count", dropped))
+	}
+	data, err := json.Marshal(trace)
+	if err != nil {
+		logutil.BgLogger().Warn("failed to encode slow rpc trace", zap.Error(err))
+		return
+	}
+	data = append(data, '\n')
+	if _, err = t.w.Write(data); err != nil {
+		logutil.BgLogger().Warn("failed to write slow rpc trace", zap.Error(err))
+	}
+}
+
+// rotatingFile is a file that is renamed with a timestamp suffix and replaced by a new one once it reaches the max
+// size. Unlike lumberjack, it removes the old files synchronously, so it doesn't leave a goroutine behind after closed.
+type rotatingFile struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+}
+
+func newRotatingFile(filename string, maxSizeMB, maxBackups int) *rotatingFile {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultSlowRPCTraceMaxSizeMB
+	}
+	return &rotatingFile{filename: filename, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, errors.WithStack(err)
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.filename), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(r.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.filename)
+	prefix := strings.TrimSuffix(r.filename, ext) + "-"
+	backup := fmt.Sprintf("%s%s%s", prefix, time.Now().UTC().Format(slowRPCTraceBackupTimeFormat), ext)
+	if err := os.Rename(r.filename, backup); err != nil {
+		return errors.WithStack(err)
+	}
+	if r.maxBackups > 0 {
+		backups, err := r.backups(prefix, ext)
+		if err != nil {
+			return err
+		}
+		// The timestamps sort the backups from the oldest.
+		slices.Sort(backups)
+		for len(backups) > r.maxBackups {
+			if err := os.Remove(backups[0]); err != nil {
+				logutil.BgLogger().Warn("failed to remove old slow rpc trace file", zap.Error(err))
+			}
+			backups = backups[1:]
+		}
+	}
+	return r.open()
+}
+
+// backups returns the rotated files, other files sharing the prefix are not touched.
+func (r *rotatingFile) backups(prefix, ext string) ([]string, error) {
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	backups := matches[:0]
+	for _, name := range matches {
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(slowRPCTraceBackupTimeFormat, ts); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	return backups, nil
+}
+
+// Close closes the current file, and the next write opens it again.
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return errors.WithStack(err)
+}
+
+var slowRPCTracer atomic.Pointer[SlowRPCTracer]
+
+// SetSlowRPCTracer sets the tracer of the slow requests of the process, nil disables tracing. It returns the
+// previous tracer, which should be closed by the caller.
+func SetSlowRPCTracer(t *SlowRPCTracer) *SlowRPCTracer {
+	client.EnableRPCTimeline(t != nil)
+	return slowRPCTracer.Swap(t)
+}
+
+// RPCTrace is the timeline of a request sent by RegionRequestSender, including every attempt and the backoffs
+// between them.
+type RPCTrace struct {
+	Time          time.Time          `json:"time"`
+	Type          string             `json:"type"`
+	Region        string             `json:"region"`
+	StartTS       uint64             `json:"start_ts,omitempty"`
+	RequestSource string             `json:"request_source,omitempty"`
+	Total         time.Duration      `json:"total_ns"`
+	Attempts      []RPCAttemptTrace  `json:"attempts"`
+	Backoffs      map[string]Backoff `json:"backoffs,omitempty"`
+	Err           string             `json:"error,omitempty"`
+}
+
+// Backoff is how many times and how long a request backs off with a backoff type.
+type Backoff struct {
+	Times   int `json:"times"`
+	SleepMS int `json:"sleep_ms"`
+}
+
+// RPCAttemptTrace is the timeline of sending a request to a replica once.
+type RPCAttemptTrace struct {
+	Addr  string        `json:"addr"`
+	Total time.Duration `json:"total_ns"`
+	// Queue, Wire and Recv are only recorded for batch commands requests.
+	Queue       time.Duration `json:"queue_ns,omitempty"`
+	Wire        time.Duration `json:"wire_ns,omitempty"`
+	Recv        time.Duration `json:"recv_ns,omitempty"`
+	Server      *ServerTrace  `json:"server,omitempty"`
+	RegionError string        `json:"region_error,omitempty"`
+	Err         string        `json:"error,omitempty"`
+}
+
+// ServerTrace is the time details reported by TiKV.
+type ServerTrace struct {
+	TotalRPC time.Duration `json:"total_rpc_ns"`
+	Wait     time.Duration `json:"wait_ns"`
+	Process  time.Duration `json:"process_ns"`
+	KvRead   time.Duration `json:"kv_read_ns"`
+}
+
+// rpcTraceBuilder collects the timeline of a request for the slow rpc tracer.
+type rpcTraceBuilder struct {
+	tracer        *SlowRPCTracer
+	attempts      []RPCAttemptTrace
+	backoffTimes  map[string]int
+	backoffSleeps map[string]int
+}
+
+func newRPCTraceBuilder(bo *retry.Backoffer) *rpcTraceBuilder {
+	tracer := slowRPCTracer.Load()
+	if tracer == nil {
+		return nil
+	}
+	b := &rpcTraceBuilder{
+		tracer:        tracer,
+		backoffTimes:  make(map[string]int),
+		backoffSleeps: make(map[string]int),
+	}
+	for name, times := range bo.GetBackoffTimes() {
+		b.backoffTimes[name] = times
+	}
+	for name, sleep := range bo.GetBackoffSleepMS() {
+		b.backoffSleeps[name] = sleep
+	}
+	return b
+}
+
+func (b *rpcTraceBuilder) onAttempt(addr string, cost time.Duration, timeline *client.RPCTimeline, resp *tikvrpc.Response, err error) {
+	attempt := RPCAttemptTrace{
+		Addr:  addr,
+		Total: cost,
+		Queue: timeline.Queue,
+		Wire:  timeline.Wire,
+		Recv:  timeline.Recv,
+	}
+	if err != nil {
+		attempt.Err = err.Error()
+	} else if resp != nil {
+		if regionErr, _ := resp.GetRegionError(); regionErr != nil {
+			attempt.RegionError = regionErr.String()
+		}
+		if td := resp.GetExecDetailsV2().GetTimeDetailV2(); td != nil {
+			attempt.Server = &ServerTrace{
+				TotalRPC: time.Duration(td.TotalRpcWallTimeNs),
+				Wait:     time.Duration(td.WaitWallTimeNs),
+				Process:  time.Duration(td.ProcessWallTimeNs),
+				KvRead:   time.Duration(td.KvReadWallTimeNs),
+			}
+		}
+	}
+	b.attempts = append(b.attempts, attempt)
+}
+
+func (b *rpcTraceBuilder) finish(bo *retry.Backoffer, req *tikvrpc.Request, regionID RegionVerID, start time.Time, err error) {
+	cost := time.Since(start)
+	if !b.tracer.sample(cost) {
+		return
+	}
+	trace := &RPCTrace{
+		Time:          start,
+		Type:          req.Type.String(),
+		Region:        regionID.String(),
+		StartTS:       req.GetStartTS(),
+		RequestSource: req.GetRequestSource(),
+		Total:         cost,
+		Attempts:      b.attempts,
+	}
+	sleeps := bo.GetBackoffSleepMS()
+	for name, times := range bo.GetBackoffTimes() {
+		if times -= b.backoffTimes[name]; times > 0 {
+			if trace.Backoffs == nil {
+				trace.Backoffs = make(map[string]Backoff)
+			}
+			trace.Backoffs[name] = Backoff{Times: times, SleepMS: sleeps[name] - b.backoffSleeps[name]}
+		}
+	}
+	if err != nil {
+		trace.Err = err.Error()
+	}
+	b.tracer.write(trace)
+}
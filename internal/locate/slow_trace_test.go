@@ -0,0 +1,127 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func (s *testRegionRequestToSingleStoreSuite) TestSlowRPCTrace() {
+	_, err := NewSlowRPCTracer(SlowRPCTraceConfig{Filename: "trace.log", SampleRate: 2})
+	s.Error(err)
+
+	filename := filepath.Join(s.T().TempDir(), "trace.log")
+	tracer, err := NewSlowRPCTracer(SlowRPCTraceConfig{Threshold: time.Millisecond, SampleRate: 1, Filename: filename})
+	s.Require().Nil(err)
+	s.Nil(SetSlowRPCTracer(tracer))
+
+	sends := 0
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		sends++
+		if sends == 1 {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{
+				RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}},
+			}}, nil
+		}
+		time.Sleep(2 * time.Millisecond)
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{Key: []byte("key"), Value: []byte("value")})
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Require().Nil(err)
+	bo := retry.NewBackofferWithVars(context.Background(), 10000, nil)
+	_, _, err = s.regionRequestSender.SendReq(bo, req, region.Region, time.Second)
+	s.Nil(err)
+
+	// Fast requests aren't traced.
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+	}}
+	_, _, err = s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+	s.Nil(err)
+
+	s.Equal(tracer, SetSlowRPCTracer(nil))
+	s.Nil(tracer.Close())
+	// The traces of the requests that loaded the tracer before it's closed are dropped.
+	tracer.write(&RPCTrace{})
+	s.Nil(tracer.Close())
+
+	f, err := os.Open(filename)
+	s.Require().Nil(err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var traces []RPCTrace
+	for scanner.Scan() {
+		var trace RPCTrace
+		s.Require().Nil(json.Unmarshal(scanner.Bytes(), &trace))
+		traces = append(traces, trace)
+	}
+	s.Require().Len(traces, 1)
+	trace := traces[0]
+	s.Equal(tikvrpc.CmdRawPut.String(), trace.Type)
+	s.Equal(region.Region.String(), trace.Region)
+	s.Require().Len(trace.Attempts, 2)
+	s.NotEmpty(trace.Attempts[0].RegionError)
+	s.Empty(trace.Attempts[1].RegionError)
+	s.GreaterOrEqual(trace.Attempts[1].Total, 2*time.Millisecond)
+	s.Equal(1, trace.Backoffs[retry.BoTiKVServerBusy.String()].Times)
+	s.Empty(trace.Err)
+}
+
+func TestSlowRPCTracerDropsWhenFull(t *testing.T) {
+	tracer := &SlowRPCTracer{traces: make(chan *RPCTrace, 1), closing: make(chan struct{})}
+	tracer.write(&RPCTrace{})
+	tracer.write(&RPCTrace{})
+	require.Len(t, tracer.traces, 1)
+	require.Equal(t, uint64(1), tracer.dropped.Load())
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	// Files sharing the prefix but not rotated by the tracer are kept.
+	other := filepath.Join(dir, "trace-foo.log")
+	require.Nil(t, os.WriteFile(other, nil, 0o644))
+	f := newRotatingFile(filepath.Join(dir, "trace.log"), 1, 2)
+	line := make([]byte, 400<<10)
+	for i := 0; i < 10; i++ {
+		_, err := f.Write(line)
+		require.Nil(t, err)
+		// Make the timestamps of the backups distinct.
+		time.Sleep(2 * time.Millisecond)
+	}
+	require.Nil(t, f.Close())
+	files, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	// Each file holds 2 lines, and only the current file and 2 backups are kept.
+	require.Len(t, files, 4)
+	require.FileExists(t, other)
+	for _, file := range files {
+		info, err := file.Info()
+		require.Nil(t, err)
+		require.LessOrEqual(t, info.Size(), int64(1<<20))
+	}
+}
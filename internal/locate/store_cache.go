@@ -24,6 +24,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coreos/go-semver/semver"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pkg/errors"
@@ -206,19 +207,28 @@ func (c *storeCacheImpl) getCheckStoreEvents() <-chan struct{} {
 
 // Store contains a kv process's address.
 type Store struct {
-	addr         string               // loaded store address
-	peerAddr     string               // TiFlash Proxy use peerAddr
-	saddr        string               // loaded store status address
-	storeID      uint64               // store's id
-	state        uint64               // unsafe store storeState
-	labels       []*metapb.StoreLabel // stored store labels
-	resolveMutex sync.Mutex           // protect pd from concurrent init requests
-	epoch        uint32               // store fail epoch, see RegionStore.storeEpochs
-	storeType    tikvrpc.EndpointType // type of the store
-	tokenCount   atomic.Int64         // used store token count
+	addr         string                         // loaded store address
+	metaAddr     string                         // store address advertised in PD, addr may be a fallback of it
+	peerAddr     string                         // TiFlash Proxy use peerAddr
+	saddr        string                         // loaded store status address
+	storeID      uint64                         // store's id
+	state        uint64                         // unsafe store storeState
+	labels       []*metapb.StoreLabel           // stored store labels
+	resolveMutex sync.Mutex                     // protect pd from concurrent init requests
+	epoch        uint32                         // store fail epoch, see RegionStore.storeEpochs
+	storeType    tikvrpc.EndpointType           // type of the store
+	tokenCount   atomic.Int64                   // used store token count
+	version      atomic.Pointer[semver.Version] // store version reported to PD, nil if unknown
 
 	loadStats atomic.Pointer[storeLoadStats]
 
+	// drainDeadline is the unix nano time until which the store is draining after a GOAWAY, and offline tells whether
+	// the store is offline in PD, see isDraining.
+	drainDeadline atomic.Int64
+	offline       atomic.Bool
+
+	busyRedirect busyRedirectStats
+
 	// whether the store is unreachable due to some reason, therefore requests to the store needs to be
 	// forwarded by other stores. this is also the flag that a health check loop is running for this store.
 	// this mechanism is currently only applicable for TiKV stores.
@@ -245,6 +255,7 @@ func newStore(
 		state:     uint64(state),
 		labels:    labels,
 		addr:      addr,
+		metaAddr:  addr,
 		peerAddr:  peerAddr,
 		saddr:     statusAddr,
 		// Make sure healthStatus field is never null.
@@ -309,6 +320,16 @@ func (s *Store) GetLabelValue(key string) (string, bool) {
 	return "", false
 }
 
+// GetLabels returns the labels of the store, which must not be modified.
+func (s *Store) GetLabels() []*metapb.StoreLabel {
+	return s.labels
+}
+
+// IsReachable returns whether the store is reachable according to its last health check.
+func (s *Store) IsReachable() bool {
+	return s.getLivenessState() == reachable
+}
+
 // IsSameLabels returns whether the store have the same labels with target labels
 func (s *Store) IsSameLabels(labels []*metapb.StoreLabel) bool {
 	if len(s.labels) != len(labels) {
@@ -424,10 +445,13 @@ func (s *Store) initResolve(bo *retry.Backoffer, c storeCache) (addr string, err
 			return "", errors.Errorf("empty store(%d) address", s.storeID)
 		}
 		s.addr = addr
+		s.metaAddr = addr
 		s.peerAddr = store.GetPeerAddress()
 		s.saddr = store.GetStatusAddress()
 		s.storeType = tikvrpc.GetStoreTypeByMeta(store)
 		s.labels = store.GetLabels()
+		s.setVersion(store.GetVersion())
+		s.setOffline(store.GetState() == metapb.StoreState_Offline)
 		// Shouldn't have other one changing its state concurrently, but we still use changeResolveStateTo for safety.
 		s.changeResolveStateTo(unresolved, resolved)
 		return s.addr, nil
@@ -464,7 +488,9 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 
 	storeType := tikvrpc.GetStoreTypeByMeta(store)
 	addr = store.GetAddress()
-	if s.addr != addr || !s.IsSameLabels(store.GetLabels()) {
+	// The store may be accessed by a fallback address after failing to dial the advertised one, which isn't a change
+	// of the address.
+	if s.metaAddr != addr || !s.IsSameLabels(store.GetLabels()) {
 		newStore := newStore(
 			s.storeID,
 			addr,
@@ -474,12 +500,10 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 			resolved,
 			store.GetLabels(),
 		)
-		newStore.livenessState = atomic.LoadUint32(&s.livenessState)
-		if newStore.getLivenessState() != reachable {
-			newStore.unreachableSince = s.unreachableSince
-			startHealthCheckLoop(scheduler, c, newStore, newStore.getLivenessState(), storeReResolveInterval)
-		}
-		if s.addr == addr {
+		newStore.setVersion(store.GetVersion())
+		s.inheritStateTo(newStore, c, scheduler)
+		newStore.setOffline(store.GetState() == metapb.StoreState_Offline)
+		if s.metaAddr == addr {
 			newStore.healthStatus = s.healthStatus
 		}
 		c.put(newStore)
@@ -494,10 +518,47 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 			zap.String("new-liveness", newStore.getLivenessState().String()))
 		return false, nil
 	}
+	s.setVersion(store.GetVersion())
+	s.setOffline(store.GetState() == metapb.StoreState_Offline)
 	s.changeResolveStateTo(needCheck, resolved)
 	return true, nil
 }
 
+// inheritStateTo copies the state of the store to newStore replacing it in the cache, and starts the health check loop
+// of newStore if the store isn't reachable.
+func (s *Store) inheritStateTo(newStore *Store, c storeCache, scheduler *bgRunner) {
+	newStore.drainDeadline.Store(s.drainDeadline.Load())
+	newStore.offline.Store(s.offline.Load())
+	newStore.busyRedirect.disabledUntil.Store(s.busyRedirect.disabledUntil.Load())
+	newStore.livenessState = atomic.LoadUint32(&s.livenessState)
+	if newStore.getLivenessState() != reachable {
+		newStore.unreachableSince = s.unreachableSince
+		startHealthCheckLoop(scheduler, c, newStore, newStore.getLivenessState(), storeReResolveInterval)
+	}
+}
+
+// setVersion records the version the store reports to PD. Versions that can't be parsed are ignored.
+func (s *Store) setVersion(version string) {
+	if v := parseStoreVersion(version); v != nil {
+		s.version.Store(v)
+	}
+}
+
+// supports returns whether the store is known to support the feature by its version.
+func (s *Store) supports(f Feature) bool {
+	return f.supportedBy(s.version.Load())
+}
+
+// IsPartitionedRaftKV returns whether the store is a TiKV using partitioned-raft-kv. As the engine
+// isn't reported to PD, it requires the cluster to be declared as partitioned-raft-kv in the config
+// and the store version to be known to support the buckets version hint.
+func (s *Store) IsPartitionedRaftKV() bool {
+	if s.storeType != tikvrpc.TiKV || !config.GetGlobalConfig().TiKVClient.PartitionedRaftKV {
+		return false
+	}
+	return s.supports(FeatureBucketsVersionHint)
+}
+
 // A quick and dirty solution to find out whether an err is caused by StoreNotFound.
 // todo: A better solution, maybe some err-code based error handling?
 func isStoreNotFoundError(err error) bool {
@@ -848,6 +909,14 @@ type StoreHealthStatus struct {
 	// A statistic for counting the request latency to this store
 	clientSideSlowScore SlowScoreStat
 
+	// rpcStats is the statistics of the RPCs sent to this store since they were taken last time.
+	rpcStats struct {
+		requests     atomic.Int64
+		errors       atomic.Int64
+		totalLatency atomic.Int64
+		maxLatency   atomic.Int64
+	}
+
 	tikvSideSlowScore struct {
 		sync.Mutex
 
@@ -901,6 +970,52 @@ func (s *StoreHealthStatus) tick(ctx context.Context, now time.Time, store *Stor
 	s.updateSlowFlag()
 }
 
+// StoreRPCStats is the statistics of the RPCs sent to a store observed by the client in a period.
+type StoreRPCStats struct {
+	// Requests is the number of the RPCs sent.
+	Requests int64
+	// Errors is the number of the RPCs failed without a response, e.g. the timeouts and the connection errors.
+	Errors int64
+	// TotalLatency is the sum of the latency of the RPCs.
+	TotalLatency time.Duration
+	// MaxLatency is the max latency of the RPCs.
+	MaxLatency time.Duration
+}
+
+// AvgLatency returns the average latency of the RPCs, or 0 if there is no RPC.
+func (s StoreRPCStats) AvgLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Requests)
+}
+
+// recordRPCStat records the latency and the result of each RPC sent to the store.
+func (s *StoreHealthStatus) recordRPCStat(latency time.Duration, err error) {
+	s.rpcStats.requests.Add(1)
+	if err != nil {
+		s.rpcStats.errors.Add(1)
+	}
+	s.rpcStats.totalLatency.Add(int64(latency))
+	for {
+		maxLatency := s.rpcStats.maxLatency.Load()
+		if int64(latency) <= maxLatency || s.rpcStats.maxLatency.CompareAndSwap(maxLatency, int64(latency)) {
+			break
+		}
+	}
+}
+
+// TakeRPCStats returns the statistics of the RPCs sent to the store since the last call and resets them. The RPCs
+// recorded concurrently may be split between the two periods.
+func (s *StoreHealthStatus) TakeRPCStats() StoreRPCStats {
+	return StoreRPCStats{
+		Requests:     s.rpcStats.requests.Swap(0),
+		Errors:       s.rpcStats.errors.Swap(0),
+		TotalLatency: time.Duration(s.rpcStats.totalLatency.Swap(0)),
+		MaxLatency:   time.Duration(s.rpcStats.maxLatency.Swap(0)),
+	}
+}
+
 // recordClientSideSlowScoreStat records timecost of each request to update the client side slow score.
 func (s *StoreHealthStatus) recordClientSideSlowScoreStat(timecost time.Duration) {
 	s.clientSideSlowScore.recordSlowScoreStat(timecost)
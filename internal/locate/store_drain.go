@@ -0,0 +1,93 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"go.uber.org/zap"
+)
+
+// storeDrainTimeout is how long a store is considered draining at most after a connection to it receives a GOAWAY of
+// shutting down. A store being restarted gracefully sends GOAWAYs before it stops, and it's no longer draining once
+// it's connected again, which is usually much sooner.
+const storeDrainTimeout = 30 * time.Second
+
+const (
+	drainReasonGoAway  = "goaway"
+	drainReasonOffline = "offline"
+)
+
+// IsDraining returns whether the store is draining, i.e. it sent a GOAWAY of shutting down and isn't connected again,
+// e.g. being restarted gracefully, or it's offline in PD. The replica selector sends the new reads allowing replica
+// read to the followers of the leaders on a draining store, and marks the regions to be reloaded soon to find their
+// new leaders, which are usually transferred away before the store stops. The writes are still sent to the leaders
+// until then, and the in-flight requests complete as usual.
+func (s *Store) IsDraining() bool {
+	if s.offline.Load() {
+		return true
+	}
+	deadline := s.drainDeadline.Load()
+	return deadline > 0 && time.Now().UnixNano() < deadline
+}
+
+// markDraining makes the store draining for storeDrainTimeout from now, and returns whether it wasn't draining.
+func (s *Store) markDraining(now time.Time) bool {
+	started := !s.IsDraining()
+	s.drainDeadline.Store(now.Add(storeDrainTimeout).UnixNano())
+	return started
+}
+
+// setOffline records whether the store is offline in PD.
+func (s *Store) setOffline(offline bool) {
+	if s.offline.Swap(offline) == offline || !offline {
+		return
+	}
+	metrics.TiKVStoreDrainCounter.WithLabelValues(drainReasonOffline).Inc()
+	logutil.BgLogger().Info("store is offline, drain it",
+		zap.Uint64("store", s.storeID),
+		zap.String("addr", s.addr))
+}
+
+// onStoreDraining marks the stores at addr draining after a connection to them receives a GOAWAY of shutting down.
+func (c *RegionCache) onStoreDraining(addr string) {
+	now := time.Now()
+	c.stores.forEach(func(s *Store) {
+		if state := s.getResolveState(); s.addr != addr || (state != resolved && state != needCheck) {
+			return
+		}
+		if s.markDraining(now) {
+			metrics.TiKVStoreDrainCounter.WithLabelValues(drainReasonGoAway).Inc()
+			logutil.BgLogger().Info("store sent GOAWAY, drain it",
+				zap.Uint64("store", s.storeID),
+				zap.String("addr", addr),
+				zap.Duration("timeout", storeDrainTimeout))
+		}
+	})
+}
+
+// onStoreReconnected stops draining the stores at addr after they're connected again.
+func (c *RegionCache) onStoreReconnected(addr string) {
+	c.stores.forEach(func(s *Store) {
+		if s.addr != addr || s.drainDeadline.Swap(0) == 0 {
+			return
+		}
+		logutil.BgLogger().Info("store is connected again, stop draining it",
+			zap.Uint64("store", s.storeID),
+			zap.String("addr", addr))
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetStoreFallbackAddrs sets the function returning the fallback addresses of a store by its ID and the address
+// advertised in PD, e.g. the addresses of the store in other networks. When the client fails to dial or handshake with
+// the address of a store, the store is accessed by the next one of its advertised address, its peer address if it's a
+// TiKV store, and the fallback addresses, until the store is re-resolved with a new address from PD. The stores are
+// only accessed by their advertised addresses if it's never set.
+func (c *RegionCache) SetStoreFallbackAddrs(f func(storeID uint64, addr string) []string) {
+	c.storeFallbackAddrs.Store(&f)
+}
+
+// storeAddrs returns the addresses the store can be accessed by, the first of which is the advertised one.
+func (c *RegionCache) storeAddrs(store *Store) []string {
+	addrs := []string{store.metaAddr}
+	f := c.storeFallbackAddrs.Load()
+	if f == nil {
+		return addrs
+	}
+	if store.storeType == tikvrpc.TiKV && store.peerAddr != "" {
+		addrs = append(addrs, store.peerAddr)
+	}
+	addrs = append(addrs, (*f)(store.storeID, store.metaAddr)...)
+	res := addrs[:0]
+	for _, addr := range addrs {
+		if addr != "" && !slices.Contains(res, addr) {
+			res = append(res, addr)
+		}
+	}
+	return res
+}
+
+// The descriptions of the connection errors gRPC reports when it fails to establish a connection, which are carried by
+// the errors of the RPCs on a connection that never becomes READY.
+var dialErrorDescs = []string{"Error while dialing", "authentication handshake failed"}
+
+// isDialError tells whether the error is caused by failing to dial or handshake with the target. gRPC reports them
+// with the Unavailable code, but so are the errors of an established connection, e.g. "transport is closing" when the
+// connection is reset, which are told apart by the description of the error.
+func isDialError(err error) bool {
+	var s interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &s) || s.GRPCStatus().Code() != codes.Unavailable {
+		return false
+	}
+	msg := s.GRPCStatus().Message()
+	return slices.ContainsFunc(dialErrorDescs, func(desc string) bool {
+		return strings.Contains(msg, desc)
+	})
+}
+
+// rotateStoreAddr switches the store to its next address after failing to dial addr. Like reResolve, a new store of
+// the address replaces the store in the cache, and the old one is marked deleted, so the regions switch to the new one
+// by changeToActiveStore. It returns false if the store has no other address or is already switched.
+func (c *RegionCache) rotateStoreAddr(store *Store, addr string) bool {
+	store.resolveMutex.Lock()
+	defer store.resolveMutex.Unlock()
+	if state := store.getResolveState(); (state != resolved && state != needCheck) || store.addr != addr {
+		return false
+	}
+	if store.storeType == tikvrpc.TiFlashCompute {
+		return false
+	}
+	addrs := c.storeAddrs(store)
+	if len(addrs) <= 1 {
+		return false
+	}
+	next := addrs[0]
+	if i := slices.Index(addrs, addr); i >= 0 && i+1 < len(addrs) {
+		next = addrs[i+1]
+	}
+	newStore := newStore(store.storeID, next, store.peerAddr, store.saddr, store.storeType, resolved, store.labels)
+	newStore.metaAddr = store.metaAddr
+	newStore.version.Store(store.version.Load())
+	newStore.healthStatus = store.healthStatus
+	store.inheritStateTo(newStore, c.stores, c.bg)
+	c.stores.put(newStore)
+	store.setResolveState(deleted)
+	metrics.RegionCacheCounterWithRotateStoreAddrOK.Inc()
+	logutil.BgLogger().Info("failed to dial store, switch to the next address",
+		zap.Uint64("store", store.storeID),
+		zap.String("old-addr", addr),
+		zap.String("new-addr", next))
+	return true
+}
@@ -0,0 +1,205 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// The defaults of WriteStallConfig.
+const (
+	DefWriteStallMinSignals     = 3
+	DefWriteStallDelayThreshold = 100 * time.Millisecond
+	DefWriteStallWindow         = 10 * time.Second
+)
+
+// WriteStallConfig is the config of a WriteStallDetector.
+type WriteStallConfig struct {
+	// MinSignals is the number of the stall signals of the writes to a store within Window to consider the store
+	// stalling, DefWriteStallMinSignals by default. A stall signal is a ServerIsBusy error of a write, or a write
+	// delayed by DelayThreshold in the store.
+	MinSignals int
+	// DelayThreshold is the delay of a write in the store, i.e. the time it's throttled by the flow control and waits
+	// for the raftstore batch reported by its exec details, to consider it a stall signal,
+	// DefWriteStallDelayThreshold by default.
+	DelayThreshold time.Duration
+	// Window is the window of the stall signals, and a stalling store recovers after it sees no stall signal for
+	// Window, DefWriteStallWindow by default.
+	Window time.Duration
+	// OnStall is called when a store starts stalling, and called again with Recovered set when a write to it sees no
+	// stall signal for Window.
+	OnStall func(WriteStall)
+}
+
+// WriteStall is a store stalling the writes, reported by a WriteStallDetector.
+type WriteStall struct {
+	StoreID uint64
+	Addr    string
+	// Reason is the reason of the last stall signal, e.g. the reason of ServerIsBusy.
+	Reason string
+	// Since is when the store starts stalling.
+	Since time.Time
+	// SuggestedBackoff is how long the writers are suggested to back off before writing to the store again, which is
+	// the larger one of the backoff suggested by the store and the delay of the writes. It's halved by each write to
+	// the store without a stall signal.
+	SuggestedBackoff time.Duration
+	Recovered        bool
+}
+
+// WriteStallDetector detects the stores stalling the writes, e.g. because of the write stall of RocksDB or the flow
+// control, from the errors and the exec details of the writes, so the bulk writers can throttle themselves before
+// the writes fail and retry massively.
+type WriteStallDetector struct {
+	cfg WriteStallConfig
+
+	mu     sync.Mutex
+	stores map[uint64]*writeStallState
+}
+
+type writeStallState struct {
+	addr    string
+	signals []time.Time
+	last    time.Time
+	reason  string
+	backoff time.Duration
+	since   time.Time
+}
+
+// NewWriteStallDetector creates a WriteStallDetector.
+func NewWriteStallDetector(cfg WriteStallConfig) *WriteStallDetector {
+	if cfg.MinSignals <= 0 {
+		cfg.MinSignals = DefWriteStallMinSignals
+	}
+	if cfg.DelayThreshold <= 0 {
+		cfg.DelayThreshold = DefWriteStallDelayThreshold
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefWriteStallWindow
+	}
+	return &WriteStallDetector{cfg: cfg, stores: make(map[uint64]*writeStallState)}
+}
+
+// SetWriteStallDetector sets the detector of the stores stalling the writes sent by the region cache, nil disables
+// the detection.
+func (c *RegionCache) SetWriteStallDetector(d *WriteStallDetector) {
+	c.writeStalls.Store(d)
+}
+
+// GetWriteStallDetector returns the detector set by SetWriteStallDetector.
+func (c *RegionCache) GetWriteStallDetector() *WriteStallDetector {
+	return c.writeStalls.Load()
+}
+
+// observe checks the response of a write sent to the store for a stall signal.
+func (d *WriteStallDetector) observe(store *Store, resp *tikvrpc.Response, now time.Time) {
+	var (
+		reason  string
+		backoff time.Duration
+	)
+	if regionErr, _ := resp.GetRegionError(); regionErr != nil {
+		busy := regionErr.GetServerIsBusy()
+		if busy == nil {
+			return
+		}
+		reason = busy.GetReason()
+		if reason == "" {
+			reason = "server is busy"
+		}
+		backoff = max(time.Duration(busy.GetBackoffMs())*time.Millisecond, time.Duration(busy.GetEstimatedWaitMs())*time.Millisecond)
+	} else if wd := resp.GetExecDetailsV2().GetWriteDetail(); wd != nil {
+		delay := time.Duration(wd.GetThrottleNanos() + wd.GetStoreBatchWaitNanos())
+		if delay < d.cfg.DelayThreshold {
+			d.observeHealthy(store.storeID, now)
+			return
+		}
+		reason, backoff = "write delayed", delay
+	} else {
+		d.observeHealthy(store.storeID, now)
+		return
+	}
+
+	d.mu.Lock()
+	st, ok := d.stores[store.storeID]
+	if !ok {
+		st = &writeStallState{}
+		d.stores[store.storeID] = st
+	}
+	st.addr, st.last, st.reason = store.addr, now, reason
+	st.backoff = max(backoff, st.backoff)
+	st.signals = append(st.signals, now)
+	for len(st.signals) > 0 && now.Sub(st.signals[0]) > d.cfg.Window {
+		st.signals = st.signals[1:]
+	}
+	var stall *WriteStall
+	if st.since.IsZero() && len(st.signals) >= d.cfg.MinSignals {
+		st.since = now
+		metrics.TiKVWriteStallCounter.WithLabelValues(reason).Inc()
+		stall = st.stall(store.storeID)
+	}
+	d.mu.Unlock()
+	if stall != nil && d.cfg.OnStall != nil {
+		d.cfg.OnStall(*stall)
+	}
+}
+
+// observeHealthy decays the suggested backoff of the store, and recovers the store if it has seen no stall signal
+// for Window.
+func (d *WriteStallDetector) observeHealthy(storeID uint64, now time.Time) {
+	d.mu.Lock()
+	st, ok := d.stores[storeID]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	if now.Sub(st.last) <= d.cfg.Window {
+		st.backoff /= 2
+		d.mu.Unlock()
+		return
+	}
+	delete(d.stores, storeID)
+	var stall *WriteStall
+	if !st.since.IsZero() {
+		stall = st.stall(storeID)
+		stall.Recovered = true
+	}
+	d.mu.Unlock()
+	if stall != nil && d.cfg.OnStall != nil {
+		d.cfg.OnStall(*stall)
+	}
+}
+
+func (st *writeStallState) stall(storeID uint64) *WriteStall {
+	return &WriteStall{StoreID: storeID, Addr: st.addr, Reason: st.reason, Since: st.since, SuggestedBackoff: st.backoff}
+}
+
+// StallingStores returns the stores stalling the writes, sorted by the store ID.
+func (d *WriteStallDetector) StallingStores() []WriteStall {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var stalls []WriteStall
+	for storeID, st := range d.stores {
+		if !st.since.IsZero() && now.Sub(st.last) <= d.cfg.Window {
+			stalls = append(stalls, *st.stall(storeID))
+		}
+	}
+	sort.Slice(stalls, func(i, j int) bool { return stalls[i].StoreID < stalls[j].StoreID })
+	return stalls
+}
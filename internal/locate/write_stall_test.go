@@ -0,0 +1,94 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func (s *testRegionRequestToSingleStoreSuite) TestWriteStallDetector() {
+	var stalls []WriteStall
+	d := NewWriteStallDetector(WriteStallConfig{MinSignals: 2, Window: time.Minute, OnStall: func(stall WriteStall) {
+		stalls = append(stalls, stall)
+	}})
+	store := newStore(1, "store1", "", "", tikvrpc.TiKV, resolved, nil)
+	busy := &tikvrpc.Response{Resp: &kvrpcpb.PrewriteResponse{RegionError: &errorpb.Error{
+		ServerIsBusy: &errorpb.ServerIsBusy{Reason: "write stall", BackoffMs: 200, EstimatedWaitMs: 500},
+	}}}
+	healthy := &tikvrpc.Response{Resp: &kvrpcpb.PrewriteResponse{}}
+
+	now := time.Now()
+	d.observe(store, busy, now)
+	s.Empty(stalls)
+	s.Empty(d.StallingStores())
+	// A healthy write within the window doesn't reset the signals.
+	d.observe(store, healthy, now.Add(time.Second))
+	d.observe(store, busy, now.Add(2*time.Second))
+	s.Require().Len(stalls, 1)
+	s.Equal(WriteStall{StoreID: 1, Addr: "store1", Reason: "write stall", Since: now.Add(2 * time.Second), SuggestedBackoff: 500 * time.Millisecond}, stalls[0])
+	s.Equal(stalls, d.StallingStores())
+	// The store is reported once while it's stalling.
+	d.observe(store, busy, now.Add(3*time.Second))
+	s.Len(stalls, 1)
+	// The suggested backoff decays by the healthy writes.
+	d.observe(store, healthy, now.Add(4*time.Second))
+	s.Require().Len(d.StallingStores(), 1)
+	s.Equal(250*time.Millisecond, d.StallingStores()[0].SuggestedBackoff)
+
+	d.observe(store, healthy, now.Add(3*time.Second+time.Minute+time.Millisecond))
+	s.Require().Len(stalls, 2)
+	s.True(stalls[1].Recovered)
+	s.Equal(uint64(1), stalls[1].StoreID)
+	s.Empty(d.StallingStores())
+
+	// Other region errors aren't stall signals.
+	notLeader := &tikvrpc.Response{Resp: &kvrpcpb.PrewriteResponse{RegionError: &errorpb.Error{NotLeader: &errorpb.NotLeader{}}}}
+	for i := 0; i < 3; i++ {
+		d.observe(store, notLeader, now)
+	}
+	s.Len(stalls, 2)
+}
+
+func (s *testRegionRequestToSingleStoreSuite) TestWriteStallDetectedBySender() {
+	s.cache.SetWriteStallDetector(NewWriteStallDetector(WriteStallConfig{MinSignals: 1}))
+	defer s.cache.SetWriteStallDetector(nil)
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		return &tikvrpc.Response{Resp: &kvrpcpb.PrewriteResponse{ExecDetailsV2: &kvrpcpb.ExecDetailsV2{
+			WriteDetail: &kvrpcpb.WriteDetail{ThrottleNanos: uint64(150 * time.Millisecond), StoreBatchWaitNanos: uint64(50 * time.Millisecond)},
+		}}}, nil
+	}}
+	region, err := s.cache.LocateRegionByID(s.bo, s.region)
+	s.Require().Nil(err)
+
+	// Reads aren't observed.
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("key")})
+	_, _, err = s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.Empty(s.cache.GetWriteStallDetector().StallingStores())
+
+	req = tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{})
+	_, _, err = s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+	s.Nil(err)
+	stalls := s.cache.GetWriteStallDetector().StallingStores()
+	s.Require().Len(stalls, 1)
+	s.Equal(s.store, stalls[0].StoreID)
+	s.Equal("write delayed", stalls[0].Reason)
+	s.Equal(200*time.Millisecond, stalls[0].SuggestedBackoff)
+}
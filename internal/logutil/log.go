@@ -40,6 +40,7 @@ import (
 
 	"github.com/pingcap/log"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // BgLogger returns the default global logger.
@@ -69,3 +70,75 @@ func AssertWarn(logger *zap.Logger, msg string, fields ...zap.Field) {
 	}
 	logger.Warn(msg, fields...)
 }
+
+// Sink is the destination of logs, which can be used instead of the global logger.
+type Sink interface {
+	// Enabled returns whether logs of the level should be written.
+	Enabled(level zapcore.Level) bool
+	// Log writes a log.
+	Log(level zapcore.Level, msg string, fields []zap.Field)
+}
+
+// NewSinkLogger creates a logger writing to the sink.
+func NewSinkLogger(sink Sink) *zap.Logger {
+	return zap.New(&sinkCore{sink: sink})
+}
+
+// sinkCore adapts a Sink to zapcore.Core.
+type sinkCore struct {
+	sink   Sink
+	fields []zap.Field
+}
+
+func (c *sinkCore) Enabled(level zapcore.Level) bool {
+	return c.sink.Enabled(level)
+}
+
+func (c *sinkCore) With(fields []zap.Field) zapcore.Core {
+	return &sinkCore{sink: c.sink, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zap.Field) error {
+	c.sink.Log(ent.Level, ent.Message, append(c.fields[:len(c.fields):len(c.fields)], fields...))
+	return nil
+}
+
+func (c *sinkCore) Sync() error {
+	return nil
+}
+
+// WithLevel returns a logger that only writes the logs of the logger enabled by the level. It can't make the logger
+// more verbose than it is.
+func WithLevel(logger *zap.Logger, level zapcore.LevelEnabler) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+}
+
+// levelCore filters the logs of a core by a level.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zap.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memctl accounts the major allocations of the client against the memory budget of
+// config.TiKVClient.MemoryControl, which is shared by all clients in the process.
+package memctl
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/metrics"
+)
+
+// Kind is the kind of the accounted allocations.
+type Kind int
+
+const (
+	// KindTxnBuffer is the memory buffers of the transactions.
+	KindTxnBuffer Kind = iota
+	// KindScanBuffer is the buffered results of the scanners.
+	KindScanBuffer
+	// KindBatchQueue is the requests waiting in the batch queues or waiting for the responses.
+	KindBatchQueue
+
+	kindCount
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindTxnBuffer:
+		return "txn_buffer"
+	case KindScanBuffer:
+		return "scan_buffer"
+	case KindBatchQueue:
+		return "batch_queue"
+	default:
+		return "unknown"
+	}
+}
+
+// Pressure actions, used as the label of the metrics.
+const (
+	ActionRejectScan = "reject_scan"
+	ActionFlushTxn   = "flush_txn"
+)
+
+var (
+	usage  [kindCount]atomic.Int64
+	gauges [kindCount]prometheus.Gauge
+)
+
+func init() {
+	for k := Kind(0); k < kindCount; k++ {
+		gauges[k] = metrics.TiKVMemoryUsageGauge.WithLabelValues(k.String())
+	}
+}
+
+// Enabled returns whether the memory budget is set. The allocations needn't be accounted if it isn't.
+func Enabled() bool {
+	return config.GetGlobalConfig().TiKVClient.MemoryControl.Limit > 0
+}
+
+// Consume adds delta to the memory usage of the kind, for the allocations not tracked by a Tracker.
+func Consume(kind Kind, delta int64) {
+	if delta == 0 {
+		return
+	}
+	usage[kind].Add(delta)
+	gauges[kind].Add(float64(delta))
+}
+
+// Usage is the memory usage accounted by the controller.
+type Usage struct {
+	TxnBuffer  uint64
+	ScanBuffer uint64
+	BatchQueue uint64
+	// Limit is the memory budget, 0 means no limit.
+	Limit uint64
+}
+
+// Total returns the total memory usage.
+func (u Usage) Total() uint64 {
+	return u.TxnBuffer + u.ScanBuffer + u.BatchQueue
+}
+
+func load(kind Kind) uint64 {
+	if v := usage[kind].Load(); v > 0 {
+		return uint64(v)
+	}
+	return 0
+}
+
+// GetUsage returns the current memory usage.
+func GetUsage() Usage {
+	return Usage{
+		TxnBuffer:  load(KindTxnBuffer),
+		ScanBuffer: load(KindScanBuffer),
+		BatchQueue: load(KindBatchQueue),
+		Limit:      config.GetGlobalConfig().TiKVClient.MemoryControl.Limit,
+	}
+}
+
+// checkPressure returns ErrMemoryPressure if the memory usage exceeds the limit.
+func checkPressure(cfg *config.MemoryControl) error {
+	if cfg.Limit == 0 {
+		return nil
+	}
+	u := GetUsage()
+	if total := u.Total(); total >= cfg.Limit {
+		return &tikverr.ErrMemoryPressure{Usage: total, Limit: cfg.Limit}
+	}
+	return nil
+}
+
+// CheckScan returns ErrMemoryPressure if a new scan with the batch size should be rejected, i.e. the batch size is
+// larger than defaultBatchSize and the memory usage exceeds the limit.
+func CheckScan(batchSize, defaultBatchSize int) error {
+	cfg := &config.GetGlobalConfig().TiKVClient.MemoryControl
+	if !cfg.RejectLargeScan || batchSize <= defaultBatchSize {
+		return nil
+	}
+	err := checkPressure(cfg)
+	if err != nil {
+		metrics.TiKVMemoryPressureActionCounter.WithLabelValues(ActionRejectScan).Inc()
+	}
+	return err
+}
+
+// ShouldFlushTxn returns whether the pipelined transactions should flush their buffers because of memory pressure.
+// The callers should only ask it when they wouldn't flush otherwise, as it counts a flush action if it returns true.
+func ShouldFlushTxn() bool {
+	cfg := &config.GetGlobalConfig().TiKVClient.MemoryControl
+	if !cfg.ForceFlushPipelinedTxn || checkPressure(cfg) == nil {
+		return false
+	}
+	metrics.TiKVMemoryPressureActionCounter.WithLabelValues(ActionFlushTxn).Inc()
+	return true
+}
+
+// Tracker tracks the memory usage of an object, e.g. a transaction buffer. It's safe for concurrent use.
+type Tracker struct {
+	kind     Kind
+	consumed atomic.Int64
+}
+
+// NewTracker creates a Tracker of the kind. The memory usage is released when the Tracker is garbage collected, so
+// that the objects abandoned without being closed don't leak the budget.
+func NewTracker(kind Kind) *Tracker {
+	t := &Tracker{kind: kind}
+	runtime.SetFinalizer(t, (*Tracker).Release)
+	return t
+}
+
+// Set sets the memory usage of the object. It's a no-op if the memory budget isn't set and nothing is tracked.
+func (t *Tracker) Set(bytes int64) {
+	if t.consumed.Load() == 0 && !Enabled() {
+		return
+	}
+	Consume(t.kind, bytes-t.consumed.Swap(bytes))
+}
+
+// Release releases all memory usage of the object.
+func (t *Tracker) Release() {
+	t.Set(0)
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memctl
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+)
+
+func TestMemoryControl(t *testing.T) {
+	// Nothing is tracked without a memory budget.
+	scan := NewTracker(KindScanBuffer)
+	scan.Set(100)
+	require.Equal(t, uint64(0), GetUsage().Total())
+	require.Nil(t, CheckScan(1024, 256))
+	require.False(t, ShouldFlushTxn())
+
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 1000
+	})()
+	scan.Set(100)
+	scan.Set(60)
+	Consume(KindBatchQueue, 30)
+	require.Equal(t, Usage{ScanBuffer: 60, BatchQueue: 30, Limit: 1000}, GetUsage())
+	require.Nil(t, CheckScan(1024, 256))
+	require.False(t, ShouldFlushTxn())
+
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 90
+	})
+	require.Nil(t, CheckScan(256, 256))
+	require.NotNil(t, CheckScan(1024, 256))
+	require.True(t, ShouldFlushTxn())
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.RejectLargeScan = false
+		conf.TiKVClient.MemoryControl.ForceFlushPipelinedTxn = false
+	})
+	require.Nil(t, CheckScan(1024, 256))
+	require.False(t, ShouldFlushTxn())
+
+	// The tracked usage is still released after the budget is unset.
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 0
+	})
+	scan.Release()
+	Consume(KindBatchQueue, -30)
+	require.Equal(t, uint64(0), GetUsage().Total())
+
+	// The usage of an abandoned tracker is released when it's garbage collected.
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.MemoryControl.Limit = 1000
+	})
+	NewTracker(KindTxnBuffer).Set(50)
+	require.Equal(t, uint64(50), GetUsage().TxnBuffer)
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		return GetUsage().TxnBuffer == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -101,6 +101,11 @@ type MemdbArena struct {
 	capacity uint64
 	// when it enlarges or shrinks, call this function with the current memory footprint (in bytes)
 	memChangeHook atomic.Pointer[func()]
+	// offHeap tracks the blocks allocated off the Go heap, it's nil if the arena allocates the blocks on the Go heap.
+	offHeap *offHeapBlocks
+	// retired are the off-heap blocks dropped by Reset or Truncate. They may still be
+	// referenced by the slices returned before, so they're only freed by FreeRetired.
+	retired [][]byte
 }
 
 func (a *MemdbArena) Alloc(size int, align bool) (MemdbArenaAddr, []byte) {
@@ -130,14 +135,51 @@ func (a *MemdbArena) enlarge(allocSize, blockSize int) {
 	if a.blockSize > maxBlockSize {
 		a.blockSize = maxBlockSize
 	}
-	a.blocks = append(a.blocks, memdbArenaBlock{
-		buf: make([]byte, a.blockSize),
-	})
+	var block memdbArenaBlock
+	if a.offHeap != nil {
+		block.buf = a.offHeap.alloc(a.blockSize)
+		block.offHeap = block.buf != nil
+	}
+	if block.buf == nil {
+		block.buf = make([]byte, a.blockSize)
+	}
+	a.blocks = append(a.blocks, block)
 	a.capacity += uint64(a.blockSize)
 	// We shall not call a.OnMemChange() here, since it will make the latest block empty, which breaks a precondition
 	// for some operations (e.g. RevertToCheckpoint)
 }
 
+// SetOffHeap makes the arena allocate the blocks off the Go heap if it's supported by
+// the platform, so the blocks aren't managed by the GC and don't grow the heap. The
+// slices returned by the arena become invalid once the blocks are freed, so it must
+// only be used for the data never returned to the callers of memdb. FreeRetired
+// should be called after Reset when the arena is no longer used, the blocks not freed
+// by it are freed when the arena is garbage collected.
+func (a *MemdbArena) SetOffHeap() {
+	if a.offHeap == nil {
+		a.offHeap = newOffHeapBlocks()
+	}
+}
+
+// IsOffHeap returns whether the arena allocates the blocks off the Go heap.
+func (a *MemdbArena) IsOffHeap() bool {
+	return a.offHeap != nil
+}
+
+// FreeRetired frees the off-heap blocks dropped by Reset or Truncate.
+func (a *MemdbArena) FreeRetired() {
+	for _, buf := range a.retired {
+		a.offHeap.free(buf)
+	}
+	a.retired = nil
+}
+
+func (a *MemdbArena) retire(block *memdbArenaBlock) {
+	if block.offHeap && block.buf != nil {
+		a.retired = append(a.retired, block.buf)
+	}
+}
+
 func (a *MemdbArena) Blocks() int {
 	return len(a.blocks)
 }
@@ -181,6 +223,7 @@ func (a *MemdbArena) GetData(addr MemdbArenaAddr) []byte {
 
 func (a *MemdbArena) Reset() {
 	for i := range a.blocks {
+		a.retire(&a.blocks[i])
 		a.blocks[i].reset()
 	}
 	a.blocks = a.blocks[:0]
@@ -190,8 +233,9 @@ func (a *MemdbArena) Reset() {
 }
 
 type memdbArenaBlock struct {
-	buf    []byte
-	length int
+	buf     []byte
+	length  int
+	offHeap bool
 }
 
 func (a *memdbArenaBlock) alloc(size int, align bool) (uint32, []byte) {
@@ -212,6 +256,7 @@ func (a *memdbArenaBlock) alloc(size int, align bool) (uint32, []byte) {
 func (a *memdbArenaBlock) reset() {
 	a.buf = nil
 	a.length = 0
+	a.offHeap = false
 }
 
 // MemDBCheckpoint is the Checkpoint of memory DB.
@@ -252,6 +297,7 @@ func (a *MemdbArena) Checkpoint() MemDBCheckpoint {
 
 func (a *MemdbArena) Truncate(snap *MemDBCheckpoint) {
 	for i := snap.blocks; i < len(a.blocks); i++ {
+		a.retire(&a.blocks[i])
 		a.blocks[i] = memdbArenaBlock{}
 	}
 	a.blocks = a.blocks[:snap.blocks]
@@ -77,3 +77,40 @@ func TestValueLargeThanBlock(t *testing.T) {
 	val := vlog.GetValue(vAddr)
 	assert.Equal(len(val), 3000)
 }
+
+func TestOffHeap(t *testing.T) {
+	assert := assert.New(t)
+	var a MemdbArena
+	a.SetOffHeap()
+	assert.True(a.IsOffHeap())
+	addr, data := a.Alloc(5, true)
+	copy(data, "value")
+	cp := a.Checkpoint()
+	a.Alloc(8192, true)
+	assert.Equal(len(a.blocks), 2)
+	assert.Len(a.offHeap.bufs, 2)
+	a.Truncate(&cp)
+	assert.Equal([]byte("value"), a.GetData(addr)[:5])
+	assert.Equal(len(a.retired), 1)
+
+	a.Reset()
+	// The blocks are kept until they're freed explicitly, the mode survives the reset.
+	assert.True(a.IsOffHeap())
+	assert.Empty(a.blocks)
+	assert.Equal(len(a.retired), 2)
+	a.FreeRetired()
+	assert.Empty(a.retired)
+	assert.Empty(a.offHeap.bufs)
+}
+
+func TestOffHeapLeaked(t *testing.T) {
+	assert := assert.New(t)
+	var a MemdbArena
+	a.SetOffHeap()
+	a.Alloc(16, true)
+	a.Alloc(8192, true)
+	assert.Len(a.offHeap.bufs, 2)
+	// The finalizer frees the blocks which are not freed by FreeRetired.
+	a.offHeap.freeLeaked()
+	assert.Empty(a.offHeap.bufs)
+}
@@ -0,0 +1,61 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arena
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+// offHeapBlocks tracks the off-heap blocks of an arena which are not freed yet. It's kept apart from the arena and
+// references nothing on the Go heap, so its finalizer runs once the arena is unreachable and frees the blocks leaked
+// by a missing FreeRetired.
+type offHeapBlocks struct {
+	bufs map[*byte][]byte
+}
+
+func newOffHeapBlocks() *offHeapBlocks {
+	b := &offHeapBlocks{bufs: make(map[*byte][]byte)}
+	runtime.SetFinalizer(b, (*offHeapBlocks).freeLeaked)
+	return b
+}
+
+// alloc allocates an off-heap block, it returns nil if the block can't be allocated off the Go heap.
+func (b *offHeapBlocks) alloc(size int) []byte {
+	buf := allocOffHeap(size)
+	if buf != nil {
+		b.bufs[unsafe.SliceData(buf)] = buf
+	}
+	return buf
+}
+
+func (b *offHeapBlocks) free(buf []byte) {
+	delete(b.bufs, unsafe.SliceData(buf))
+	freeOffHeap(buf)
+}
+
+func (b *offHeapBlocks) freeLeaked() {
+	if len(b.bufs) == 0 {
+		return
+	}
+	logutil.BgLogger().Warn("off-heap memdb blocks are leaked, free them in the finalizer", zap.Int("blocks", len(b.bufs)))
+	for _, buf := range b.bufs {
+		freeOffHeap(buf)
+	}
+	b.bufs = nil
+}
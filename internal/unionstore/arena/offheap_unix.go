@@ -0,0 +1,42 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package arena
+
+import (
+	"syscall"
+
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+// allocOffHeap allocates a zeroed block with an anonymous mmap. It returns nil if
+// the mmap fails, then the caller should allocate the block on the Go heap.
+func allocOffHeap(size int) []byte {
+	buf, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		logutil.BgLogger().Warn("allocate off-heap memdb block failed, fallback to heap",
+			zap.Int("size", size), zap.Error(err))
+		return nil
+	}
+	return buf
+}
+
+func freeOffHeap(buf []byte) {
+	if err := syscall.Munmap(buf); err != nil {
+		logutil.BgLogger().Warn("free off-heap memdb block failed", zap.Int("size", len(buf)), zap.Error(err))
+	}
+}
@@ -115,6 +115,7 @@ func (t *ART) Set(key artKey, value []byte, ops ...kv.FlagsOp) error {
 	if len(key) > MaxKeyLen {
 		return &tikverr.ErrKeyTooLarge{
 			KeySize: len(key),
+			Limit:   MaxKeyLen,
 		}
 	}
 
@@ -435,7 +436,7 @@ func (t *ART) Dirty() bool {
 
 // Mem returns the memory usage of MemBuffer.
 func (t *ART) Mem() uint64 {
-	return t.allocator.nodeAllocator.Capacity() + t.allocator.vlogAllocator.Capacity()
+	return t.allocator.nodeAllocator.Capacity() + t.allocator.leafAllocator.Capacity() + t.allocator.vlogAllocator.Capacity()
 }
 
 // Len returns the count of entries in the MemBuffer.
@@ -567,6 +568,7 @@ func (t *ART) Reset() {
 	t.size = 0
 	t.len = 0
 	t.allocator.nodeAllocator.Reset()
+	t.allocator.leafAllocator.Reset()
 	t.allocator.vlogAllocator.Reset()
 	t.lastTraversedNode.Store(arena.NullU64Addr)
 	t.SnapshotSeqNo++
@@ -609,9 +611,10 @@ func (t *ART) SelectValueHistory(key []byte, predicate func(value []byte) bool)
 
 func (t *ART) SetMemoryFootprintChangeHook(hook func(uint64)) {
 	innerHook := func() {
-		hook(t.allocator.nodeAllocator.Capacity() + t.allocator.vlogAllocator.Capacity())
+		hook(t.allocator.nodeAllocator.Capacity() + t.allocator.leafAllocator.Capacity() + t.allocator.vlogAllocator.Capacity())
 	}
 	t.allocator.nodeAllocator.SetMemChangeHook(innerHook)
+	t.allocator.leafAllocator.SetMemChangeHook(innerHook)
 	t.allocator.vlogAllocator.SetMemChangeHook(innerHook)
 }
 
@@ -620,6 +623,24 @@ func (t *ART) MemHookSet() bool {
 	return t.allocator.nodeAllocator.MemHookSet()
 }
 
+// SetOffHeap makes the ART allocate its inner nodes off the Go heap. The leaves and
+// values stay on the Go heap, so the keys and values returned by the ART remain valid
+// after FreeOffHeap. It must be called before any write.
+func (t *ART) SetOffHeap() {
+	t.allocator.nodeAllocator.SetOffHeap()
+}
+
+// FreeOffHeap resets the ART and frees the inner nodes allocated off the Go heap. The
+// inner nodes are left to the finalizer of the arena if there are ongoing snapshot
+// iterators, which may still read them.
+func (t *ART) FreeOffHeap() {
+	t.Reset()
+	if t.allocator.nodeAllocator.blockedSnapshotCnt.Load() > 0 {
+		return
+	}
+	t.allocator.nodeAllocator.FreeRetired()
+}
+
 // GetKeyByHandle returns key by handle.
 func (t *ART) GetKeyByHandle(handle arena.MemKeyHandle) []byte {
 	lf := t.allocator.getLeaf(handle.ToAddr())
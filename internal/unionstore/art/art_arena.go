@@ -48,6 +48,9 @@ type nodeArena struct {
 type artAllocator struct {
 	vlogAllocator arena.MemdbVlog[*artLeaf, *ART]
 	nodeAllocator nodeArena
+	// The leaves are allocated apart from the inner nodes, because the keys stored in them are returned to the
+	// callers, they must stay on the Go heap even if the inner nodes are allocated off the heap.
+	leafAllocator arena.MemdbArena
 }
 
 // init the allocator.
@@ -165,7 +168,7 @@ func (f *artAllocator) getNode256(addr arena.MemdbArenaAddr) *node256 {
 
 func (f *artAllocator) allocLeaf(key []byte) (arena.MemdbArenaAddr, *artLeaf) {
 	size := leafSize + len(key)
-	addr, data := f.nodeAllocator.Alloc(size, true)
+	addr, data := f.leafAllocator.Alloc(size, true)
 	lf := (*artLeaf)(unsafe.Pointer(&data[0]))
 	lf.keyLen = uint16(len(key))
 	lf.flags = 0
@@ -178,7 +181,7 @@ func (f *artAllocator) getLeaf(addr arena.MemdbArenaAddr) *artLeaf {
 	if addr.IsNull() {
 		return nil
 	}
-	data := f.nodeAllocator.GetData(addr)
+	data := f.leafAllocator.GetData(addr)
 	return (*artLeaf)(unsafe.Pointer(&data[0]))
 }
 
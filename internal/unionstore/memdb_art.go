@@ -80,6 +80,14 @@ func (db *artDBWithContext) DeleteWithFlags(key []byte, ops ...kv.FlagsOp) error
 	return db.set(key, arena.Tombstone, ops)
 }
 
+func (db *artDBWithContext) FreeOffHeap() {
+	if !db.skipMutex {
+		db.Lock()
+		defer db.Unlock()
+	}
+	db.ART.FreeOffHeap()
+}
+
 func (db *artDBWithContext) Staging() int {
 	if !db.skipMutex {
 		db.Lock()
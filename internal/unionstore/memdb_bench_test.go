@@ -38,6 +38,7 @@ import (
 	"context"
 	"encoding/binary"
 	"math/rand"
+	"runtime"
 	"slices"
 	"testing"
 )
@@ -80,6 +81,35 @@ func BenchmarkPut(b *testing.B) {
 	b.Run("ART", func(b *testing.B) { fn(b, newArtDBWithContext()) })
 }
 
+func BenchmarkPutOffHeap(b *testing.B) {
+	fn := func(b *testing.B, p OffHeapMemBuffer, offHeap bool) {
+		if offHeap {
+			p.SetOffHeap()
+		}
+		buf := make([][valueSize]byte, b.N)
+		for i := range buf {
+			binary.BigEndian.PutUint32(buf[i][:], uint32(i))
+		}
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.ResetTimer()
+		for i := range buf {
+			p.Set(buf[i][:keySize], buf[i][:])
+		}
+		runtime.GC()
+		b.StopTimer()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.NumGC-before.NumGC), "gc")
+		b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs), "gc-pause-ns")
+		b.ReportMetric(float64(after.HeapAlloc), "heap-bytes")
+		p.FreeOffHeap()
+	}
+
+	b.Run("ART", func(b *testing.B) { fn(b, newArtDBWithContext(), false) })
+	b.Run("ART-OffHeap", func(b *testing.B) { fn(b, newArtDBWithContext(), true) })
+}
+
 func BenchmarkPutRandom(b *testing.B) {
 	fn := func(b *testing.B, p MemBuffer) {
 		buf := make([][valueSize]byte, b.N)
@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/errors"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/internal/unionstore/arena"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
@@ -356,13 +357,14 @@ func (p *PipelinedMemDB) needFlush() bool {
 	               +-----------------------------------------> Size
 	               0   MinSize(16MB)   Force(128MB)
 	*/
-	if size < p.flushOption.MinFlushMemSize ||
-		(uint64(p.memDB.Len()) < p.flushOption.MinFlushKeys &&
-			size < p.flushOption.ForceFlushMemSizeThreshold) {
+	if size < p.flushOption.MinFlushMemSize {
 		return false
 	}
-	if p.onFlushing.Load() && size < p.flushOption.ForceFlushMemSizeThreshold {
-		return false
+	if size < p.flushOption.ForceFlushMemSizeThreshold &&
+		(uint64(p.memDB.Len()) < p.flushOption.MinFlushKeys || p.onFlushing.Load()) {
+		// Under memory pressure of the client, flush regardless of the number of keys, and wait for the ongoing
+		// flush if there is one.
+		return memctl.ShouldFlushTxn()
 	}
 	return true
 }
@@ -329,6 +329,7 @@ func (db *RBT) Set(key []byte, value []byte, ops ...kv.FlagsOp) error {
 	if len(key) > MaxKeyLen {
 		return &tikverr.ErrKeyTooLarge{
 			KeySize: len(key),
+			Limit:   MaxKeyLen,
 		}
 	}
 
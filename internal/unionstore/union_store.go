@@ -287,6 +287,19 @@ type MemBuffer interface {
 	GetSnapshot() MemBufferSnapshot
 }
 
+// OffHeapMemBuffer is a MemBuffer that can allocate its index off the Go heap. The keys
+// and values are always kept on the Go heap, so the slices returned by the MemBuffer
+// stay valid after FreeOffHeap.
+type OffHeapMemBuffer interface {
+	MemBuffer
+	// SetOffHeap makes the MemBuffer allocate its index off the Go heap. It must be
+	// called before anything is written.
+	SetOffHeap()
+	// FreeOffHeap resets the MemBuffer and frees the off-heap memory. The memory not
+	// freed by it is freed when the MemBuffer is garbage collected.
+	FreeOffHeap()
+}
+
 type Metrics struct {
 	WaitDuration   time.Duration
 	TotalDuration  time.Duration
@@ -298,6 +311,8 @@ var (
 	_ MemBuffer = &PipelinedMemDB{}
 	_ MemBuffer = &rbtDBWithContext{}
 	_ MemBuffer = &artDBWithContext{}
+
+	_ OffHeapMemBuffer = &artDBWithContext{}
 )
 
 type memdbSnapshot interface {
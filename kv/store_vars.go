@@ -50,6 +50,11 @@ const DefTxnCommitBatchSize uint64 = 16 * 1024
 // TiKV recommends each RPC packet should be less than ~1MB.
 var TxnCommitBatchSize atomic.Uint64
 
+// TxnInsertOnlyCommitBatchSize controls the batch size of the prewrite requests of the insert-only transactions,
+// whose mutations are all inserts, e.g. the ones of bulk loading. Setting it larger than TxnCommitBatchSize, e.g. to
+// 64KB, reduces the number of the RPCs of such transactions. 0, the default, means following TxnCommitBatchSize.
+var TxnInsertOnlyCommitBatchSize atomic.Uint64
+
 func init() {
 	TxnCommitBatchSize.Store(DefTxnCommitBatchSize)
 }
@@ -93,6 +98,32 @@ func (r ReplicaReadType) String() string {
 	}
 }
 
+// LearnerReadFallback is the policy of ReplicaReadLearner requests when no learner replica is available.
+type LearnerReadFallback byte
+
+const (
+	// LearnerReadFallbackAny stands for 'fall back to any other replica'.
+	LearnerReadFallbackAny LearnerReadFallback = iota
+	// LearnerReadFallbackLeader stands for 'fall back to the leader only'.
+	LearnerReadFallbackLeader
+	// LearnerReadFallbackNone stands for 'never read from a non-learner replica'.
+	LearnerReadFallbackNone
+)
+
+// String implements fmt.Stringer interface.
+func (f LearnerReadFallback) String() string {
+	switch f {
+	case LearnerReadFallbackAny:
+		return "any"
+	case LearnerReadFallbackLeader:
+		return "leader"
+	case LearnerReadFallbackNone:
+		return "none"
+	default:
+		return fmt.Sprintf("unknown-%v", byte(f))
+	}
+}
+
 type AccessLocationType byte
 
 const (
@@ -0,0 +1,107 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// ValueTransformer transforms the values before they're written to TiKV and restores them after they're read, e.g.
+// to encrypt or compress them on the client side.
+type ValueTransformer interface {
+	// ID identifies the transformer in the tags of the values it transforms. It must be unique among the transformers
+	// of a client and stable across the restarts. 0 is reserved.
+	ID() byte
+	// Transform transforms the value of the key to be written.
+	Transform(key, value []byte) ([]byte, error)
+	// Restore restores the value of the key transformed by Transform.
+	Restore(key, value []byte) ([]byte, error)
+}
+
+// valueTagMagic prefixes the tag of a transformed value, which is followed by the ID of the transformer.
+var valueTagMagic = []byte("\xfe\xedvt")
+
+// ValueTransformers transforms the values written by a client with one transformer, and restores the values read by
+// the client with the transformer tagged in each of them, so the values written by a retired transformer, e.g. with
+// a rotated encryption key, are still readable. The untagged values, e.g. the ones written before the transformers
+// are enabled, are read as is. A nil ValueTransformers transforms nothing.
+type ValueTransformers struct {
+	writer ValueTransformer
+	byID   map[byte]ValueTransformer
+}
+
+// NewValueTransformers creates ValueTransformers transforming the values with writer, and restoring the values with
+// writer or the readers by the IDs tagged in them.
+func NewValueTransformers(writer ValueTransformer, readers ...ValueTransformer) (*ValueTransformers, error) {
+	t := &ValueTransformers{writer: writer, byID: make(map[byte]ValueTransformer, len(readers)+1)}
+	for _, transformer := range append([]ValueTransformer{writer}, readers...) {
+		id := transformer.ID()
+		if id == 0 {
+			return nil, errors.New("value transformer ID 0 is reserved")
+		}
+		if _, ok := t.byID[id]; ok {
+			return nil, errors.Errorf("duplicate value transformer ID %d", id)
+		}
+		t.byID[id] = transformer
+	}
+	return t, nil
+}
+
+// Transform transforms the value of the key and tags it with the ID of the writer. The nil value of a delete is
+// returned as is.
+func (t *ValueTransformers) Transform(key, value []byte) ([]byte, error) {
+	if t == nil || value == nil {
+		return value, nil
+	}
+	transformed, err := t.writer.Transform(key, value)
+	if err != nil {
+		return nil, err
+	}
+	v := make([]byte, 0, len(valueTagMagic)+1+len(transformed))
+	v = append(v, valueTagMagic...)
+	v = append(v, t.writer.ID())
+	return append(v, transformed...), nil
+}
+
+// Restore restores the value of the key with the transformer tagged in it. An untagged value is returned as is.
+func (t *ValueTransformers) Restore(key, value []byte) ([]byte, error) {
+	if t == nil || len(value) <= len(valueTagMagic) || !bytes.HasPrefix(value, valueTagMagic) {
+		return value, nil
+	}
+	id := value[len(valueTagMagic)]
+	transformer, ok := t.byID[id]
+	if !ok {
+		return nil, &tikverr.ErrUnknownValueTransformer{Key: key, ID: id}
+	}
+	return transformer.Restore(key, value[len(valueTagMagic)+1:])
+}
+
+// RestoreMap restores the values of the map in place.
+func (t *ValueTransformers) RestoreMap(values map[string][]byte) error {
+	if t == nil {
+		return nil
+	}
+	for k, v := range values {
+		restored, err := t.Restore([]byte(k), v)
+		if err != nil {
+			return err
+		}
+		values[k] = restored
+	}
+	return nil
+}
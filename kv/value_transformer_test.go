@@ -0,0 +1,79 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+type xorTransformer byte
+
+func (t xorTransformer) ID() byte { return byte(t) }
+
+func (t xorTransformer) Transform(_, value []byte) ([]byte, error) {
+	res := make([]byte, len(value))
+	for i, b := range value {
+		res[i] = b ^ byte(t)
+	}
+	return res, nil
+}
+
+func (t xorTransformer) Restore(key, value []byte) ([]byte, error) {
+	return t.Transform(key, value)
+}
+
+func TestValueTransformers(t *testing.T) {
+	_, err := NewValueTransformers(xorTransformer(0))
+	assert.NotNil(t, err)
+	_, err = NewValueTransformers(xorTransformer(1), xorTransformer(1))
+	assert.NotNil(t, err)
+
+	old, err := NewValueTransformers(xorTransformer(1))
+	assert.Nil(t, err)
+	oldValue, err := old.Transform([]byte("k"), []byte("value"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, []byte("value"), oldValue)
+
+	// The values transformed by the retired transformer are still readable.
+	current, err := NewValueTransformers(xorTransformer(2), xorTransformer(1))
+	assert.Nil(t, err)
+	newValue, err := current.Transform([]byte("k"), []byte("value"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, oldValue, newValue)
+	for _, v := range [][]byte{oldValue, newValue, []byte("value")} {
+		restored, err := current.Restore([]byte("k"), v)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), restored)
+	}
+	_, err = old.Restore([]byte("k"), newValue)
+	var unknownErr *tikverr.ErrUnknownValueTransformer
+	assert.ErrorAs(t, err, &unknownErr)
+	assert.Equal(t, byte(2), unknownErr.ID)
+
+	// Deletes and nil transformers are passed through.
+	v, err := current.Transform([]byte("k"), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+	var none *ValueTransformers
+	v, err = none.Transform([]byte("k"), []byte("value"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), v)
+	m := map[string][]byte{"k": oldValue}
+	assert.Nil(t, current.RestoreMap(m))
+	assert.Equal(t, map[string][]byte{"k": []byte("value")}, m)
+}
@@ -0,0 +1,48 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import "sync/atomic"
+
+// DefaultKeyRangeBucket is the bucket label of all keys if no bucketer is set.
+const DefaultKeyRangeBucket = "all"
+
+var keyRangeBucketer atomic.Pointer[func(key []byte) string]
+
+// SetKeyRangeBucketer sets the function mapping a key to the bucket label of
+// the contention metrics labeled by key range, e.g. the table ID or a prefix
+// of the key. It must return a small set of distinct values. Passing nil
+// resets it and all keys fall into DefaultKeyRangeBucket. It's used by the lock
+// contention, lock wait and write conflict metrics, and isn't called with empty
+// keys.
+func SetKeyRangeBucketer(bucketer func(key []byte) string) {
+	if bucketer == nil {
+		keyRangeBucketer.Store(nil)
+		return
+	}
+	keyRangeBucketer.Store(&bucketer)
+}
+
+// KeyRangeBucket returns the bucket label of the key. Empty keys, e.g. the
+// unknown keys of write conflicts, fall into DefaultKeyRangeBucket.
+func KeyRangeBucket(key []byte) string {
+	if len(key) == 0 {
+		return DefaultKeyRangeBucket
+	}
+	if bucketer := keyRangeBucketer.Load(); bucketer != nil {
+		return (*bucketer)(key)
+	}
+	return DefaultKeyRangeBucket
+}
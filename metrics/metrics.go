@@ -68,14 +68,19 @@ var (
 	TiKVBatchRecvLoopDuration                      *prometheus.SummaryVec
 	TiKVBatchHeadArrivalInterval                   *prometheus.SummaryVec
 	TiKVBatchBestSize                              *prometheus.SummaryVec
+	TiKVBatchAdaptiveMaxSize                       *prometheus.GaugeVec
 	TiKVBatchMoreRequests                          *prometheus.SummaryVec
 	TiKVBatchWaitOverLoad                          prometheus.Counter
+	TiKVBatchDeadlineFastPathCounter               prometheus.Counter
+	TiKVSLORequestCounter                          *prometheus.CounterVec
+	TiKVSLOThrottledCounter                        *prometheus.CounterVec
 	TiKVBatchPendingRequests                       *prometheus.HistogramVec
 	TiKVBatchRequests                              *prometheus.HistogramVec
 	TiKVBatchRequestDuration                       *prometheus.SummaryVec
 	TiKVBatchClientUnavailable                     prometheus.Histogram
 	TiKVBatchClientWaitEstablish                   prometheus.Histogram
 	TiKVBatchClientRecycle                         prometheus.Histogram
+	TiKVBatchClientResendCounter                   prometheus.Counter
 	TiKVRangeTaskStats                             *prometheus.GaugeVec
 	TiKVRangeTaskPushDuration                      *prometheus.HistogramVec
 	TiKVTokenWaitDuration                          prometheus.Histogram
@@ -103,6 +108,7 @@ var (
 	TiKVUnsafeDestroyRangeFailuresCounterVec       *prometheus.CounterVec
 	TiKVPrewriteAssertionUsageCounter              *prometheus.CounterVec
 	TiKVGrpcConnectionState                        *prometheus.GaugeVec
+	TiKVGrpcConnectionEventCounter                 *prometheus.CounterVec
 	TiKVAggressiveLockedKeysCounter                *prometheus.CounterVec
 	TiKVStoreSlowScoreGauge                        *prometheus.GaugeVec
 	TiKVFeedbackSlowScoreGauge                     *prometheus.GaugeVec
@@ -121,6 +127,28 @@ var (
 	TiKVTxnWriteConflictCounter                    prometheus.Counter
 	TiKVAsyncSendReqCounter                        *prometheus.CounterVec
 	TiKVAsyncBatchGetCounter                       *prometheus.CounterVec
+	TiKVTxnHelperCounter                           *prometheus.CounterVec
+	TiKVLockContentionCounter                      *prometheus.CounterVec
+	TiKVPessimisticLockRetryDelayHistogram         *prometheus.HistogramVec
+	TiKVLockWaitHistogram                          *prometheus.HistogramVec
+	TiKVWriteConflictByRangeCounter                *prometheus.CounterVec
+	TiKVTrafficRequestCounter                      *prometheus.CounterVec
+	TiKVSecondaryCommitLagHistogram                *prometheus.HistogramVec
+	TiKVPendingSecondaryCommitGauge                prometheus.Gauge
+	TiKVLockCleanupPendingGauge                    prometheus.Gauge
+	TiKVLockCleanupTaskCounter                     *prometheus.CounterVec
+	TiKVMemoryUsageGauge                           *prometheus.GaugeVec
+	TiKVMemoryPressureActionCounter                *prometheus.CounterVec
+	TiKVBatchConnRecycleCounter                    *prometheus.CounterVec
+	TiKVRunawayOperationCounter                    *prometheus.CounterVec
+	TiKVClusterIDMismatchCounter                   *prometheus.CounterVec
+	TiKVBatchOutdatedResponseCounter               *prometheus.CounterVec
+	TiKVBatchLateResponseCounter                   *prometheus.CounterVec
+	TiKVWriteStallCounter                          *prometheus.CounterVec
+	TiKVStoreDrainCounter                          *prometheus.CounterVec
+	TiKVBatchRequestIntendedHistogram              *prometheus.HistogramVec
+	TiKVBusyRedirectCounter                        *prometheus.CounterVec
+	TiKVBusyRedirectDisabledGauge                  *prometheus.GaugeVec
 )
 
 // Label constants.
@@ -146,6 +174,9 @@ const (
 	LblGeneral         = "general"
 	LblDirection       = "direction"
 	LblReason          = "reason"
+	LblBucket          = "bucket"
+	LblTrafficClass    = "traffic_class"
+	LblSLOClass        = "slo_class"
 )
 
 func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
@@ -405,6 +436,15 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{LblStore})
 
+	TiKVBatchAdaptiveMaxSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_adaptive_max_size",
+			Help:        "max batch size adapted to the latency of the store",
+			ConstLabels: constLabels,
+		}, []string{LblStore})
+
 	TiKVBatchMoreRequests = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
 			Namespace:   namespace,
@@ -423,6 +463,33 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		})
 
+	TiKVBatchDeadlineFastPathCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_deadline_fast_path_total",
+			Help:        "Counter of batch requests sent without waiting for more requests because their deadlines are near.",
+			ConstLabels: constLabels,
+		})
+
+	TiKVSLORequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "slo_requests_total",
+			Help:        "Counter of requests shaped by SLO classes, by whether they met the target latencies.",
+			ConstLabels: constLabels,
+		}, []string{LblSLOClass, LblResult})
+
+	TiKVSLOThrottledCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "slo_throttled_total",
+			Help:        "Counter of requests throttled to protect SLO classes of higher priorities.",
+			ConstLabels: constLabels,
+		}, []string{LblSLOClass})
+
 	TiKVBatchPendingRequests = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace:   namespace,
@@ -482,6 +549,15 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		})
 
+	TiKVBatchClientResendCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_client_resend_total",
+			Help:        "Counter of read requests resent after the batch commands stream is broken.",
+			ConstLabels: constLabels,
+		})
+
 	TiKVRangeTaskStats = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -725,6 +801,15 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{"connection_id", "store_ip", "grpc_state"})
 
+	TiKVGrpcConnectionEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "grpc_connection_event_total",
+			Help:        "Counter of the events of gRPC connections, i.e. connect, disconnect, state change and goaway.",
+			ConstLabels: constLabels,
+		}, []string{LblStore, LblType})
+
 	TiKVAggressiveLockedKeysCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace:   namespace,
@@ -889,6 +974,208 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{LblResult})
 
+	TiKVTxnHelperCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "txn_helper_total",
+			Help:        "Counter of transactions run by the client-side txn helpers.",
+			ConstLabels: constLabels,
+		}, []string{LblType, LblResult})
+
+	TiKVLockContentionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "lock_contention_total",
+			Help:        "Counter of pessimistic lock requests still blocked by other transactions after lock waiting.",
+			ConstLabels: constLabels,
+		}, []string{LblBucket})
+
+	TiKVPessimisticLockRetryDelayHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "pessimistic_lock_retry_delay_seconds",
+			Help:        "Delay before resending blocked pessimistic lock requests.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 20), // 0.5ms ~ 262s
+		}, []string{LblType, LblBucket})
+
+	TiKVLockWaitHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "lock_wait_seconds",
+			Help:        "Time pessimistic lock requests blocked by other transactions waited for the locks, by key range.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 20), // 0.5ms ~ 262s
+		}, []string{LblBucket})
+
+	TiKVWriteConflictByRangeCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "txn_write_conflict_by_range_total",
+			Help:        "Counter of txn write conflicts by key range.",
+			ConstLabels: constLabels,
+		}, []string{LblBucket})
+
+	TiKVTrafficRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "traffic_request_total",
+			Help:        "Counter of requests sent to TiKV by traffic class.",
+			ConstLabels: constLabels,
+		}, []string{LblTrafficClass, LblType})
+
+	TiKVSecondaryCommitLagHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "secondary_commit_lag_seconds",
+			Help:        "Bucketed histogram of the time secondary keys are committed in background after the primary key.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 22), // 0.5ms ~ 1048s
+		}, []string{LblResult})
+
+	TiKVPendingSecondaryCommitGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "pending_secondary_commit",
+			Help:        "Number of transactions whose secondary keys are being committed in background.",
+			ConstLabels: constLabels,
+		})
+
+	TiKVLockCleanupPendingGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "lock_cleanup_pending",
+			Help:        "Number of lock cleanup tasks queued or running in the lock cleanup scheduler.",
+			ConstLabels: constLabels,
+		})
+
+	TiKVLockCleanupTaskCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "lock_cleanup_scheduler_task_total",
+			Help:        "Counter of lock cleanup tasks handled by the lock cleanup scheduler.",
+			ConstLabels: constLabels,
+		}, []string{LblResult})
+
+	TiKVMemoryUsageGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "memory_usage_bytes",
+			Help:        "Memory usage of the major allocations of the client, i.e. txn buffers, scan buffers and batch queues.",
+			ConstLabels: constLabels,
+		}, []string{LblType})
+
+	TiKVMemoryPressureActionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "memory_pressure_action_total",
+			Help:        "Counter of the actions taken under memory pressure.",
+			ConstLabels: constLabels,
+		}, []string{LblType})
+
+	TiKVBatchConnRecycleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_conn_recycle_total",
+			Help:        "Counter of the idle batch connections or streams recycled and re-established.",
+			ConstLabels: constLabels,
+		}, []string{LblType, LblResult})
+
+	TiKVRunawayOperationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "runaway_operation_total",
+			Help:        "Counter of the operations flagged by the runaway watchdog, by the exceeded threshold and the action.",
+			ConstLabels: constLabels,
+		}, []string{LblType, LblResult})
+
+	TiKVClusterIDMismatchCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "cluster_id_mismatch_total",
+			Help:        "Counter of the cluster ID mismatches between the client and PD or the stores.",
+			ConstLabels: constLabels,
+		}, []string{LblSource})
+
+	TiKVBatchOutdatedResponseCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_outdated_response_total",
+			Help:        "Counter of the batch responses received after their requests are finished.",
+			ConstLabels: constLabels,
+		}, []string{LblAddress})
+
+	TiKVBatchLateResponseCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_late_response_total",
+			Help:        "Counter of the async requests failing to be sent but waiting for their responses, by whether the responses are delivered before the deadlines.",
+			ConstLabels: constLabels,
+		}, []string{LblResult})
+
+	TiKVWriteStallCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "write_stall_total",
+			Help:        "Counter of the stores detected stalling the writes, by the reason.",
+			ConstLabels: constLabels,
+		}, []string{LblReason})
+
+	TiKVStoreDrainCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "store_drain_total",
+			Help:        "Counter of the stores starting to drain, by the reason, i.e. goaway or offline.",
+			ConstLabels: constLabels,
+		}, []string{LblReason})
+
+	TiKVBatchRequestIntendedHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "batch_request_intended_seconds",
+			Help:        "Bucketed histogram of the batch request duration from when the requests are intended to be sent, including the time waiting for the admission and in the batch queue, by the result.",
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 24), // 0.5ms ~ 1.2h
+			ConstLabels: constLabels,
+		}, []string{LblType, LblResult})
+
+	TiKVBusyRedirectCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "busy_redirect_total",
+			Help:        "Counter of the reads of busy leaders redirected to the store, by whether they're served by it.",
+			ConstLabels: constLabels,
+		}, []string{LblStore, LblResult})
+
+	TiKVBusyRedirectDisabledGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "busy_redirect_disabled",
+			Help:        "Whether redirecting the reads of busy leaders to the store is disabled because it never helps.",
+			ConstLabels: constLabels,
+		}, []string{LblStore})
+
 	initShortcuts()
 }
 
@@ -938,12 +1225,16 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TiKVBatchBestSize)
 	prometheus.MustRegister(TiKVBatchMoreRequests)
 	prometheus.MustRegister(TiKVBatchWaitOverLoad)
+	prometheus.MustRegister(TiKVBatchDeadlineFastPathCounter)
+	prometheus.MustRegister(TiKVSLORequestCounter)
+	prometheus.MustRegister(TiKVSLOThrottledCounter)
 	prometheus.MustRegister(TiKVBatchPendingRequests)
 	prometheus.MustRegister(TiKVBatchRequests)
 	prometheus.MustRegister(TiKVBatchRequestDuration)
 	prometheus.MustRegister(TiKVBatchClientUnavailable)
 	prometheus.MustRegister(TiKVBatchClientWaitEstablish)
 	prometheus.MustRegister(TiKVBatchClientRecycle)
+	prometheus.MustRegister(TiKVBatchClientResendCounter)
 	prometheus.MustRegister(TiKVRangeTaskStats)
 	prometheus.MustRegister(TiKVRangeTaskPushDuration)
 	prometheus.MustRegister(TiKVTokenWaitDuration)
@@ -970,6 +1261,7 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TiKVUnsafeDestroyRangeFailuresCounterVec)
 	prometheus.MustRegister(TiKVPrewriteAssertionUsageCounter)
 	prometheus.MustRegister(TiKVGrpcConnectionState)
+	prometheus.MustRegister(TiKVGrpcConnectionEventCounter)
 	prometheus.MustRegister(TiKVAggressiveLockedKeysCounter)
 	prometheus.MustRegister(TiKVStoreSlowScoreGauge)
 	prometheus.MustRegister(TiKVFeedbackSlowScoreGauge)
@@ -988,6 +1280,29 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TiKVTxnWriteConflictCounter)
 	prometheus.MustRegister(TiKVAsyncSendReqCounter)
 	prometheus.MustRegister(TiKVAsyncBatchGetCounter)
+	prometheus.MustRegister(TiKVTxnHelperCounter)
+	prometheus.MustRegister(TiKVLockContentionCounter)
+	prometheus.MustRegister(TiKVPessimisticLockRetryDelayHistogram)
+	prometheus.MustRegister(TiKVLockWaitHistogram)
+	prometheus.MustRegister(TiKVWriteConflictByRangeCounter)
+	prometheus.MustRegister(TiKVTrafficRequestCounter)
+	prometheus.MustRegister(TiKVSecondaryCommitLagHistogram)
+	prometheus.MustRegister(TiKVPendingSecondaryCommitGauge)
+	prometheus.MustRegister(TiKVLockCleanupPendingGauge)
+	prometheus.MustRegister(TiKVLockCleanupTaskCounter)
+	prometheus.MustRegister(TiKVMemoryUsageGauge)
+	prometheus.MustRegister(TiKVMemoryPressureActionCounter)
+	prometheus.MustRegister(TiKVBatchConnRecycleCounter)
+	prometheus.MustRegister(TiKVRunawayOperationCounter)
+	prometheus.MustRegister(TiKVClusterIDMismatchCounter)
+	prometheus.MustRegister(TiKVBatchOutdatedResponseCounter)
+	prometheus.MustRegister(TiKVBatchLateResponseCounter)
+	prometheus.MustRegister(TiKVWriteStallCounter)
+	prometheus.MustRegister(TiKVStoreDrainCounter)
+	prometheus.MustRegister(TiKVBatchRequestIntendedHistogram)
+	prometheus.MustRegister(TiKVBatchAdaptiveMaxSize)
+	prometheus.MustRegister(TiKVBusyRedirectCounter)
+	prometheus.MustRegister(TiKVBusyRedirectDisabledGauge)
 }
 
 // readCounter reads the value of a prometheus.Counter.
@@ -72,6 +72,7 @@ var (
 	BackoffHistogramStaleCmd                 prometheus.Observer
 	BackoffHistogramDataNotReady             prometheus.Observer
 	BackoffHistogramIsWitness                prometheus.Observer
+	BackoffHistogramWriteConflict            prometheus.Observer
 	BackoffHistogramEmpty                    prometheus.Observer
 
 	TxnRegionsNumHistogramWithSnapshotInternal         prometheus.Observer
@@ -109,6 +110,8 @@ var (
 	LockResolverCountWithQueryCheckSecondaryLocks prometheus.Counter
 	LockResolverCountWithResolveLocks             prometheus.Counter
 	LockResolverCountWithResolveLockLite          prometheus.Counter
+	LockResolverCountWithResolvedCacheHit         prometheus.Counter
+	LockResolverCountWithResolvedCacheMiss        prometheus.Counter
 
 	RegionCacheCounterWithInvalidateRegionFromCacheOK prometheus.Counter
 	RegionCacheCounterWithSendFail                    prometheus.Counter
@@ -123,6 +126,7 @@ var (
 	RegionCacheCounterWithGetStoreOK                  prometheus.Counter
 	RegionCacheCounterWithGetStoreError               prometheus.Counter
 	RegionCacheCounterWithInvalidateStoreRegionsOK    prometheus.Counter
+	RegionCacheCounterWithRotateStoreAddrOK           prometheus.Counter
 
 	LoadRegionCacheHistogramWhenCacheMiss        prometheus.Observer
 	LoadRegionCacheHistogramWithRegions          prometheus.Observer
@@ -187,6 +191,22 @@ var (
 	AsyncBatchGetCounterWithRegionError prometheus.Counter
 	AsyncBatchGetCounterWithLockError   prometheus.Counter
 	AsyncBatchGetCounterWithOtherError  prometheus.Counter
+
+	TxnHelperCounterUpdateOK       prometheus.Counter
+	TxnHelperCounterUpdateConflict prometheus.Counter
+	TxnHelperCounterUpdateError    prometheus.Counter
+	TxnHelperCounterRunTxnOK       prometheus.Counter
+	TxnHelperCounterRunTxnConflict prometheus.Counter
+	TxnHelperCounterRunTxnError    prometheus.Counter
+
+	SecondaryCommitLagOK    prometheus.Observer
+	SecondaryCommitLagError prometheus.Observer
+
+	LockCleanupTaskCounterOK       prometheus.Counter
+	LockCleanupTaskCounterRetry    prometheus.Counter
+	LockCleanupTaskCounterFailed   prometheus.Counter
+	LockCleanupTaskCounterRejected prometheus.Counter
+	LockCleanupTaskCounterDropped  prometheus.Counter
 )
 
 func initShortcuts() {
@@ -224,6 +244,7 @@ func initShortcuts() {
 	BackoffHistogramStaleCmd = TiKVBackoffHistogram.WithLabelValues("staleCommand")
 	BackoffHistogramDataNotReady = TiKVBackoffHistogram.WithLabelValues("dataNotReady")
 	BackoffHistogramIsWitness = TiKVBackoffHistogram.WithLabelValues("isWitness")
+	BackoffHistogramWriteConflict = TiKVBackoffHistogram.WithLabelValues("writeConflict")
 	BackoffHistogramEmpty = TiKVBackoffHistogram.WithLabelValues("")
 
 	TxnRegionsNumHistogramWithSnapshotInternal = TiKVTxnRegionsNumHistogram.WithLabelValues("snapshot", LblInternal)
@@ -260,6 +281,8 @@ func initShortcuts() {
 	LockResolverCountWithQueryCheckSecondaryLocks = TiKVLockResolverCounter.WithLabelValues("query_check_secondary_locks")
 	LockResolverCountWithResolveLocks = TiKVLockResolverCounter.WithLabelValues("query_resolve_locks")
 	LockResolverCountWithResolveLockLite = TiKVLockResolverCounter.WithLabelValues("query_resolve_lock_lite")
+	LockResolverCountWithResolvedCacheHit = TiKVLockResolverCounter.WithLabelValues("resolved_cache_hit")
+	LockResolverCountWithResolvedCacheMiss = TiKVLockResolverCounter.WithLabelValues("resolved_cache_miss")
 
 	RegionCacheCounterWithInvalidateRegionFromCacheOK = TiKVRegionCacheCounter.WithLabelValues("invalidate_region_from_cache", "ok")
 	RegionCacheCounterWithSendFail = TiKVRegionCacheCounter.WithLabelValues("send_fail", "ok")
@@ -274,6 +297,7 @@ func initShortcuts() {
 	RegionCacheCounterWithGetStoreOK = TiKVRegionCacheCounter.WithLabelValues("get_store", "ok")
 	RegionCacheCounterWithGetStoreError = TiKVRegionCacheCounter.WithLabelValues("get_store", "err")
 	RegionCacheCounterWithInvalidateStoreRegionsOK = TiKVRegionCacheCounter.WithLabelValues("invalidate_store_regions", "ok")
+	RegionCacheCounterWithRotateStoreAddrOK = TiKVRegionCacheCounter.WithLabelValues("rotate_store_addr", "ok")
 
 	LoadRegionCacheHistogramWhenCacheMiss = TiKVLoadRegionCacheHistogram.WithLabelValues("get_region_when_miss")
 	LoadRegionCacheHistogramWithRegionByID = TiKVLoadRegionCacheHistogram.WithLabelValues("get_region_by_id")
@@ -343,4 +367,20 @@ func initShortcuts() {
 	AsyncBatchGetCounterWithRegionError = TiKVAsyncBatchGetCounter.WithLabelValues("region_error")
 	AsyncBatchGetCounterWithLockError = TiKVAsyncBatchGetCounter.WithLabelValues("lock_error")
 	AsyncBatchGetCounterWithOtherError = TiKVAsyncBatchGetCounter.WithLabelValues("other_error")
+
+	TxnHelperCounterUpdateOK = TiKVTxnHelperCounter.WithLabelValues("update", "ok")
+	TxnHelperCounterUpdateConflict = TiKVTxnHelperCounter.WithLabelValues("update", "conflict")
+	TxnHelperCounterUpdateError = TiKVTxnHelperCounter.WithLabelValues("update", "error")
+	TxnHelperCounterRunTxnOK = TiKVTxnHelperCounter.WithLabelValues("run_txn", "ok")
+	TxnHelperCounterRunTxnConflict = TiKVTxnHelperCounter.WithLabelValues("run_txn", "conflict")
+	TxnHelperCounterRunTxnError = TiKVTxnHelperCounter.WithLabelValues("run_txn", "error")
+
+	SecondaryCommitLagOK = TiKVSecondaryCommitLagHistogram.WithLabelValues("ok")
+	SecondaryCommitLagError = TiKVSecondaryCommitLagHistogram.WithLabelValues("error")
+
+	LockCleanupTaskCounterOK = TiKVLockCleanupTaskCounter.WithLabelValues("ok")
+	LockCleanupTaskCounterRetry = TiKVLockCleanupTaskCounter.WithLabelValues("retry")
+	LockCleanupTaskCounterFailed = TiKVLockCleanupTaskCounter.WithLabelValues("failed")
+	LockCleanupTaskCounterRejected = TiKVLockCleanupTaskCounter.WithLabelValues("rejected")
+	LockCleanupTaskCounterDropped = TiKVLockCleanupTaskCounter.WithLabelValues("dropped")
 }
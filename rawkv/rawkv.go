@@ -48,10 +48,12 @@ import (
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/kvrpc"
 	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
 	pd "github.com/tikv/pd/client"
 	"github.com/tikv/pd/client/opt"
 	"github.com/tikv/pd/client/pkg/caller"
@@ -133,14 +135,27 @@ type Client struct {
 	rpcClient   client.Client
 	cf          string
 	atomic      bool
+	// valueTransformers transforms the values written and restores the values read.
+	valueTransformers *kv.ValueTransformers
+	// coalescer coalesces the concurrent puts and deletes if it's not nil.
+	coalescer *writeCoalescer
 }
 
 type option struct {
-	apiVersion      kvrpcpb.APIVersion
-	security        config.Security
-	gRPCDialOptions []grpc.DialOption
-	pdOptions       []opt.ClientOption
-	keyspace        string
+	apiVersion       kvrpcpb.APIVersion
+	apiVersionSet    bool
+	detectAPIVersion bool
+	security         config.Security
+	gRPCDialOptions  []grpc.DialOption
+	pdOptions        []opt.ClientOption
+	keyspace         string
+
+	unaryInterceptors   []grpc.UnaryClientInterceptor
+	streamInterceptors  []grpc.StreamClientInterceptor
+	credentials         tikv.CredentialProvider
+	insecureCredentials bool
+	valueTransformers   *kv.ValueTransformers
+	coalescingWindow    time.Duration
 }
 
 // ClientOpt is factory to set the client options.
@@ -167,10 +182,49 @@ func WithGRPCDialOptions(opts ...grpc.DialOption) ClientOpt {
 	}
 }
 
+// WithUnaryInterceptors appends the unary interceptors to the connections to TiKV.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOpt {
+	return func(o *option) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends the stream interceptors to the connections to TiKV.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) ClientOpt {
+	return func(o *option) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithCredentialProvider is used to attach the credentials of the provider to every request to TiKV over TLS.
+func WithCredentialProvider(provider tikv.CredentialProvider) ClientOpt {
+	return func(o *option) {
+		o.credentials, o.insecureCredentials = provider, false
+	}
+}
+
+// WithInsecureCredentialProvider is like WithCredentialProvider, but also sends the credentials over the insecure
+// connections.
+func WithInsecureCredentialProvider(provider tikv.CredentialProvider) ClientOpt {
+	return func(o *option) {
+		o.credentials, o.insecureCredentials = provider, true
+	}
+}
+
 // WithAPIVersion is used to set the api version.
 func WithAPIVersion(apiVersion kvrpcpb.APIVersion) ClientOpt {
 	return func(o *option) {
 		o.apiVersion = apiVersion
+		o.apiVersionSet = true
+	}
+}
+
+// WithAPIVersionDetection is used to detect the api version from the storage config of the TiKV cluster when creating
+// the client. If the api version is also set by WithAPIVersion, the client fails to create with ErrAPIVersionMismatch
+// if it doesn't match the cluster. Otherwise, the detected api version is used.
+func WithAPIVersionDetection() ClientOpt {
+	return func(o *option) {
+		o.detectAPIVersion = true
 	}
 }
 
@@ -181,6 +235,26 @@ func WithKeyspace(name string) ClientOpt {
 	}
 }
 
+// WithValueTransformers makes the client transform the values written by the puts and restore the values read by the
+// gets and scans with the transformers, e.g. to encrypt the values on the client side, see kv.ValueTransformers.
+// CompareAndSwap isn't supported with the transformers, as TiKV compares the previous value with the transformed one.
+func WithValueTransformers(t *kv.ValueTransformers) ClientOpt {
+	return func(o *option) {
+		o.valueTransformers = t
+	}
+}
+
+// WithWriteCoalescing makes the client coalesce the concurrent Put and Delete calls submitted within the window into
+// batch requests, improving the throughput of many small writes at the cost of the latency of the window. The writes
+// of the same key still take effect in the order they're submitted, and each call returns after its write is done.
+// The writes of a window are sent with the context values, e.g. the request source, of the first one, and the latest
+// deadline of them. The puts with TTL and the writes in the atomic mode for CompareAndSwap aren't coalesced.
+func WithWriteCoalescing(window time.Duration) ClientOpt {
+	return func(o *option) {
+		o.coalescingWindow = window
+	}
+}
+
 // SetAtomicForCAS sets atomic mode for CompareAndSwap
 func (c *Client) SetAtomicForCAS(b bool) *Client {
 	c.atomic = b
@@ -215,6 +289,18 @@ func NewClientWithOpts(ctx context.Context, pdAddrs []string, opts ...ClientOpt)
 		return nil, errors.WithStack(err)
 	}
 
+	if opt.detectAPIVersion {
+		if opt.apiVersionSet {
+			err = tikv.CheckAPIVersion(ctx, pdCli, opt.security, tikv.ModeRaw, opt.apiVersion)
+		} else {
+			opt.apiVersion, err = tikv.DetectAPIVersion(ctx, pdCli, opt.security, tikv.ModeRaw)
+		}
+		if err != nil {
+			pdCli.Close()
+			return nil, err
+		}
+	}
+
 	// Build a CodecPDClient
 	var codecCli *tikv.CodecPDClient
 
@@ -232,23 +318,46 @@ func NewClientWithOpts(ctx context.Context, pdAddrs []string, opts ...ClientOpt)
 
 	pdCli = codecCli
 
-	rpcCli := client.NewRPCClient(
+	rpcOpts := []client.Opt{
 		client.WithSecurity(opt.security),
 		client.WithGRPCDialOptions(opt.gRPCDialOptions...),
 		client.WithCodec(codecCli.GetCodec()),
-	)
+		client.WithUnaryInterceptors(opt.unaryInterceptors...),
+		client.WithStreamInterceptors(opt.streamInterceptors...),
+	}
+	if opt.credentials != nil && opt.insecureCredentials {
+		rpcOpts = append(rpcOpts, client.WithInsecureCredentialProvider(opt.credentials))
+	} else if opt.credentials != nil {
+		rpcOpts = append(rpcOpts, client.WithCredentialProvider(opt.credentials))
+	}
+	rpcCli := client.NewRPCClient(rpcOpts...)
 
-	return &Client{
+	c := &Client{
 		apiVersion:  opt.apiVersion,
 		clusterID:   pdCli.GetClusterID(ctx),
 		regionCache: locate.NewRegionCache(pdCli),
 		pdClient:    pdCli.WithCallerComponent(componentName),
 		rpcClient:   rpcCli,
-	}, nil
+
+		valueTransformers: opt.valueTransformers,
+	}
+	if opt.coalescingWindow > 0 {
+		c.enableWriteCoalescing(opt.coalescingWindow)
+	}
+	return c, nil
+}
+
+// NewClientForKeyspace creates a client in API v2 bound to the keyspace with the given name.
+func NewClientForKeyspace(ctx context.Context, pdAddrs []string, keyspaceName string, opts ...ClientOpt) (*Client, error) {
+	opts = append(opts, WithAPIVersion(kvrpcpb.APIVersion_V2), WithKeyspace(keyspaceName))
+	return NewClientWithOpts(ctx, pdAddrs, opts...)
 }
 
 // Close closes the client.
 func (c *Client) Close() error {
+	if c.coalescer != nil {
+		c.coalescer.close()
+	}
 	if c.pdClient != nil {
 		c.pdClient.Close()
 	}
@@ -292,7 +401,7 @@ func (c *Client) Get(ctx context.Context, key []byte, options ...RawOption) ([]b
 	if cmdResp.NotFound {
 		return nil, nil
 	}
-	return convertNilToEmptySlice(cmdResp.Value), nil
+	return c.valueTransformers.Restore(key, convertNilToEmptySlice(cmdResp.Value))
 }
 
 const rawkvMaxBackoff = 20000
@@ -325,7 +434,9 @@ func (c *Client) BatchGet(ctx context.Context, keys [][]byte, options ...RawOpti
 	for i, key := range keys {
 		v, ok := keyToValue[string(key)]
 		if ok {
-			v = convertNilToEmptySlice(v)
+			if v, err = c.valueTransformers.Restore(key, convertNilToEmptySlice(v)); err != nil {
+				return nil, err
+			}
 		}
 		values[i] = v
 	}
@@ -339,7 +450,15 @@ func (c *Client) PutWithTTL(ctx context.Context, key, value []byte, ttl uint64,
 	metrics.RawkvSizeHistogramWithKey.Observe(float64(len(key)))
 	metrics.RawkvSizeHistogramWithValue.Observe(float64(len(value)))
 
+	value, err := c.valueTransformers.Transform(key, value)
+	if err != nil {
+		return err
+	}
 	opts := c.getRawKVOptions(options...)
+	if c.coalescer != nil && ttl == 0 && !c.atomic {
+		// The value of a coalesced put can't be nil, which means a delete.
+		return c.coalesceWrite(ctx, opts, key, convertNilToEmptySlice(value))
+	}
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
 		Key:    key,
 		Value:  value,
@@ -421,6 +540,16 @@ func (c *Client) BatchPutWithTTL(ctx context.Context, keys, values [][]byte, ttl
 	if len(ttls) > 0 && len(keys) != len(ttls) {
 		return errors.New("the len of ttls is not equal to the len of values")
 	}
+	if c.valueTransformers != nil {
+		transformed := make([][]byte, len(values))
+		for i, value := range values {
+			var err error
+			if transformed[i], err = c.valueTransformers.Transform(keys[i], value); err != nil {
+				return err
+			}
+		}
+		values = transformed
+	}
 	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
 	opts := c.getRawKVOptions(options...)
 	err := c.sendBatchPut(bo, keys, values, ttls, opts)
@@ -433,6 +562,9 @@ func (c *Client) Delete(ctx context.Context, key []byte, options ...RawOption) e
 	defer func() { metrics.RawkvCmdHistogramWithDelete.Observe(time.Since(start).Seconds()) }()
 
 	opts := c.getRawKVOptions(options...)
+	if c.coalescer != nil && !c.atomic {
+		return c.coalesceWrite(ctx, opts, key, nil)
+	}
 	req := tikvrpc.NewRequest(tikvrpc.CmdRawDelete, &kvrpcpb.RawDeleteRequest{
 		Key:    key,
 		Cf:     c.getColumnFamily(opts),
@@ -544,8 +676,14 @@ func (c *Client) Scan(ctx context.Context, startKey, endKey []byte, limit int, o
 		}
 		cmdResp := resp.Resp.(*kvrpcpb.RawScanResponse)
 		for _, pair := range cmdResp.Kvs {
+			value := convertNilToEmptySlice(pair.Value)
+			if !opts.KeyOnly {
+				if value, err = c.valueTransformers.Restore(pair.Key, value); err != nil {
+					return nil, nil, err
+				}
+			}
 			keys = append(keys, pair.Key)
-			values = append(values, convertNilToEmptySlice(pair.Value))
+			values = append(values, value)
 		}
 		startKey = loc.EndKey
 		if len(startKey) == 0 {
@@ -593,8 +731,14 @@ func (c *Client) ReverseScan(ctx context.Context, startKey, endKey []byte, limit
 		}
 		cmdResp := resp.Resp.(*kvrpcpb.RawScanResponse)
 		for _, pair := range cmdResp.Kvs {
+			value := convertNilToEmptySlice(pair.Value)
+			if !opts.KeyOnly {
+				if value, err = c.valueTransformers.Restore(pair.Key, value); err != nil {
+					return nil, nil, err
+				}
+			}
 			keys = append(keys, pair.Key)
-			values = append(values, convertNilToEmptySlice(pair.Value))
+			values = append(values, value)
 		}
 		startKey = loc.StartKey
 		if len(startKey) == 0 {
@@ -642,6 +786,64 @@ func (c *Client) Checksum(ctx context.Context, startKey, endKey []byte, options
 	return
 }
 
+// RegionInfo is a region covering a range of keys and where its leader is.
+type RegionInfo struct {
+	RegionID uint64
+	// StartKey and EndKey are the range of the region, which may exceed the queried range.
+	StartKey []byte
+	EndKey   []byte
+	// LeaderStoreID and LeaderAddr are the store and address of the region leader.
+	LeaderStoreID uint64
+	LeaderAddr    string
+}
+
+// Regions returns the regions covering the [startKey, endKey) range in key order, along with their leaders. An
+// empty endKey means the range is unbounded. The result is a snapshot of the region distribution, which may be
+// out of date when it's used, so callers partitioning work by it should still tolerate region errors.
+func (c *Client) Regions(ctx context.Context, startKey, endKey []byte) ([]RegionInfo, error) {
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	var regions []RegionInfo
+	err := util.SplitRangeByRegions(startKey, endKey, func(key []byte) (RegionInfo, []byte, error) {
+		for {
+			loc, err := c.regionCache.LocateKey(bo, key)
+			if err != nil {
+				return RegionInfo{}, nil, err
+			}
+			rpcCtx, err := c.regionCache.GetTiKVRPCContext(bo, loc.Region, kv.ReplicaReadLeader, 0)
+			if err != nil {
+				return RegionInfo{}, nil, err
+			}
+			if rpcCtx == nil {
+				// The region is out of date and dropped from the cache, locate the key again.
+				err = bo.Backoff(retry.BoRegionMiss, errors.Errorf("region %d is stale", loc.Region.GetID()))
+				if err != nil {
+					return RegionInfo{}, nil, err
+				}
+				continue
+			}
+			return RegionInfo{
+				RegionID:      loc.Region.GetID(),
+				StartKey:      loc.StartKey,
+				EndKey:        loc.EndKey,
+				LeaderStoreID: rpcCtx.Store.StoreID(),
+				LeaderAddr:    rpcCtx.Addr,
+			}, loc.EndKey, nil
+		}
+	}, func(region RegionInfo, _, _ []byte) error {
+		regions = append(regions, region)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// SplitRangeByRegions splits [startKey, endKey) into region-aligned sub-ranges, see tikv.SplitRangeByRegions.
+func (c *Client) SplitRangeByRegions(ctx context.Context, startKey, endKey []byte, maxRangesPerRegion int) ([]tikv.RegionAlignedRange, error) {
+	return tikv.SplitRangeByRegions(ctx, c.regionCache, startKey, endKey, maxRangesPerRegion)
+}
+
 // CompareAndSwap results in an atomic compare-and-set operation for the given key while SetAtomicForCAS(true)
 // If the value retrieved is equal to previousValue, newValue is written.
 // It returns the previous value and whether the value is successfully swapped.
@@ -656,6 +858,9 @@ func (c *Client) CompareAndSwap(ctx context.Context, key, previousValue, newValu
 	if !c.atomic {
 		return nil, false, errors.New("using CompareAndSwap without enable atomic mode")
 	}
+	if c.valueTransformers != nil {
+		return nil, false, errors.New("using CompareAndSwap with value transformers")
+	}
 
 	opts := c.getRawKVOptions(options...)
 	reqArgs := kvrpcpb.RawCASRequest{
@@ -704,7 +909,7 @@ func (c *Client) sendReq(ctx context.Context, key []byte, req *tikvrpc.Request,
 		if err != nil {
 			return nil, nil, err
 		}
-		resp, _, err := sender.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		resp, _, err := sender.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -794,7 +999,7 @@ func (c *Client) doBatchReq(bo *retry.Backoffer, batch kvrpc.Batch, options *raw
 
 	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.ReadTimeoutShort)
+	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.DefaultTimeout(req.Type))
 
 	batchResp := kvrpc.BatchResult{}
 	if err != nil {
@@ -861,7 +1066,7 @@ func (c *Client) sendDeleteRangeReq(ctx context.Context, startKey []byte, endKey
 		})
 
 		req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-		resp, _, err := sender.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		resp, _, err := sender.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -951,7 +1156,7 @@ func (c *Client) doBatchPut(bo *retry.Backoffer, batch kvrpc.Batch, opts *rawOpt
 	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
 	req.ApiVersion = c.apiVersion
-	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.ReadTimeoutShort)
+	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.DefaultTimeout(req.Type))
 	if err != nil {
 		return err
 	}
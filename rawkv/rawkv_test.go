@@ -39,15 +39,19 @@ import (
 	"context"
 	"fmt"
 	"hash/crc64"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pingcap/failpoint"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/locate"
 	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/tikvrpc"
 )
 
 func TestRawKV(t *testing.T) {
@@ -657,3 +661,254 @@ func (s *testRawkvSuite) TestRawChecksum() {
 	s.Equal(expectTotalKvs, check.TotalKvs)
 	s.Equal(expectTotalBytes, check.TotalBytes)
 }
+
+func (s *testRawkvSuite) TestWriteBatch() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, s.cluster.AllocID(), []byte("key5"), newPeers, newPeers[0])
+
+	ctx := context.Background()
+	wb := client.NewWriteBatch(WithWriteBatchMaxCount(4), WithWriteBatchConcurrency(2))
+	for i := 0; i < 10; i++ {
+		wb.Put(ctx, []byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	// The first 8 keys are flushed automatically.
+	s.Equal(2, wb.Len())
+	wb.Delete(ctx, []byte("key9"))
+	wb.Delete(ctx, []byte("key1"))
+	// The key and value are copied, so the buffers can be reused.
+	key, value := []byte("key1"), []byte("value1-new")
+	wb.Put(ctx, key, value)
+	copy(key, "keyX")
+	copy(value, "valueX")
+	s.Nil(wb.Flush(ctx))
+	s.Equal(0, wb.Len())
+
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		val, err := client.Get(ctx, key)
+		s.Nil(err)
+		switch i {
+		case 1:
+			s.Equal([]byte("value1-new"), val)
+		case 9:
+			s.Nil(val)
+		default:
+			s.Equal([]byte(fmt.Sprintf("value%d", i)), val)
+		}
+	}
+}
+
+// cmdCountingClient counts the requests sent by the wrapped client by the command types.
+type cmdCountingClient struct {
+	client.Client
+	mu   sync.Mutex
+	cmds map[tikvrpc.CmdType]int
+}
+
+func (c *cmdCountingClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	c.mu.Lock()
+	c.cmds[req.Type]++
+	c.mu.Unlock()
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func (c *cmdCountingClient) count(cmd tikvrpc.CmdType) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cmds[cmd]
+}
+
+func (s *testRawkvSuite) TestWriteCoalescing() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	rpcClient := &cmdCountingClient{
+		Client: mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+		cmds:   make(map[tikvrpc.CmdType]int),
+	}
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   rpcClient,
+	}
+	client.enableWriteCoalescing(50 * time.Millisecond)
+	defer client.Close()
+
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, s.cluster.AllocID(), []byte("key5"), newPeers, newPeers[0])
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Nil(client.Put(ctx, []byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))))
+		}()
+	}
+	wg.Wait()
+	s.Zero(rpcClient.count(tikvrpc.CmdRawPut))
+	// The puts are coalesced into a batch put of each region, or a few more if the goroutines are slow.
+	s.GreaterOrEqual(rpcClient.count(tikvrpc.CmdRawBatchPut), 2)
+	s.Less(rpcClient.count(tikvrpc.CmdRawBatchPut), 10)
+	for i := 0; i < 10; i++ {
+		val, err := client.Get(ctx, []byte(fmt.Sprintf("key%d", i)))
+		s.Nil(err)
+		s.Equal([]byte(fmt.Sprintf("value%d", i)), val)
+	}
+
+	// The writes of the same key take effect in the order they're returned.
+	s.Nil(client.Delete(ctx, []byte("key1")))
+	s.Nil(client.Put(ctx, []byte("key1"), []byte("value1-new")))
+	s.Nil(client.Delete(ctx, []byte("key2")))
+	s.Nil(client.Put(ctx, []byte("key3"), []byte{}))
+	val, err := client.Get(ctx, []byte("key1"))
+	s.Nil(err)
+	s.Equal([]byte("value1-new"), val)
+	val, err = client.Get(ctx, []byte("key2"))
+	s.Nil(err)
+	s.Nil(val)
+	val, err = client.Get(ctx, []byte("key3"))
+	s.Nil(err)
+	s.Equal([]byte{}, val)
+	s.Zero(rpcClient.count(tikvrpc.CmdRawDelete))
+
+	// The write of a canceled call is still flushed, with the key and value it was submitted with.
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	key, value := []byte("key6"), []byte("value6")
+	s.ErrorIs(client.Put(canceledCtx, key, value), context.Canceled)
+	copy(key, "keyX")
+	copy(value, "valueX")
+	s.Eventually(func() bool {
+		val, err := client.Get(ctx, []byte("key6"))
+		return err == nil && string(val) == "value6"
+	}, time.Second, 10*time.Millisecond)
+	val, err = client.Get(ctx, []byte("keyX"))
+	s.Nil(err)
+	s.Nil(val)
+
+	// The puts with TTL aren't coalesced.
+	s.Nil(client.PutWithTTL(ctx, []byte("key4"), []byte("value4"), 100))
+	s.Equal(1, rpcClient.count(tikvrpc.CmdRawPut))
+
+	s.Nil(client.Close())
+	s.Error(client.Put(ctx, []byte("key5"), []byte("value5")))
+}
+
+type xorTransformer byte
+
+func (t xorTransformer) ID() byte { return byte(t) }
+
+func (t xorTransformer) Transform(_, value []byte) ([]byte, error) {
+	res := make([]byte, len(value))
+	for i, b := range value {
+		res[i] = b ^ byte(t)
+	}
+	return res, nil
+}
+
+func (t xorTransformer) Restore(key, value []byte) ([]byte, error) {
+	return t.Transform(key, value)
+}
+
+func (s *testRawkvSuite) TestValueTransformers() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	transformers, err := kv.NewValueTransformers(xorTransformer(1))
+	s.Nil(err)
+	client := &Client{
+		clusterID:         0,
+		regionCache:       locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:         mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+		valueTransformers: transformers,
+	}
+	defer client.Close()
+	plain := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer plain.Close()
+
+	ctx := context.Background()
+	s.Nil(client.Put(ctx, []byte("k1"), []byte("v1")))
+	s.Nil(client.BatchPut(ctx, [][]byte{[]byte("k2"), []byte("k3")}, [][]byte{[]byte("v2"), []byte("v3")}))
+	wb := client.NewWriteBatch()
+	wb.Put(ctx, []byte("k4"), []byte("v4"))
+	s.Nil(wb.Flush(ctx))
+
+	val, err := client.Get(ctx, []byte("k1"))
+	s.Nil(err)
+	s.Equal([]byte("v1"), val)
+	vals, err := client.BatchGet(ctx, [][]byte{[]byte("k2"), []byte("k4")})
+	s.Nil(err)
+	s.Equal([][]byte{[]byte("v2"), []byte("v4")}, vals)
+	keys, vals, err := client.Scan(ctx, []byte("k"), nil, 10)
+	s.Nil(err)
+	s.Len(keys, 4)
+	for i := range keys {
+		s.Equal([]byte(fmt.Sprintf("v%d", i+1)), vals[i])
+	}
+
+	// The values are stored transformed.
+	val, err = plain.Get(ctx, []byte("k3"))
+	s.Nil(err)
+	s.NotEqual([]byte("v3"), val)
+	restored, err := transformers.Restore([]byte("k3"), val)
+	s.Nil(err)
+	s.Equal([]byte("v3"), restored)
+
+	// The values written without transformers are still readable.
+	s.Nil(plain.Put(ctx, []byte("k5"), []byte("v5")))
+	val, err = client.Get(ctx, []byte("k5"))
+	s.Nil(err)
+	s.Equal([]byte("v5"), val)
+
+	_, _, err = client.CompareAndSwap(ctx, []byte("k1"), []byte("v1"), []byte("v1-new"))
+	s.NotNil(err)
+}
+
+func (s *testRawkvSuite) TestRegions() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.SplitRaw(s.region1, region2, []byte("m"), newPeers, newPeers[1])
+
+	ctx := context.Background()
+	regions, err := client.Regions(ctx, []byte("a"), []byte("z"))
+	s.Nil(err)
+	s.Len(regions, 2)
+	s.Equal(s.region1, regions[0].RegionID)
+	s.Equal(s.store1, regions[0].LeaderStoreID)
+	s.Equal(s.storeAddr(s.store1), regions[0].LeaderAddr)
+	s.Equal([]byte("m"), regions[0].EndKey)
+	s.Equal(region2, regions[1].RegionID)
+	s.Equal(s.store2, regions[1].LeaderStoreID)
+	s.Equal(s.storeAddr(s.store2), regions[1].LeaderAddr)
+
+	regions, err = client.Regions(ctx, []byte("a"), []byte("m"))
+	s.Nil(err)
+	s.Len(regions, 1)
+	s.Equal(s.region1, regions[0].RegionID)
+}
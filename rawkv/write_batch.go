@@ -0,0 +1,270 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawkv
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/kvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+const (
+	defaultWriteBatchMaxSize     = 4 * 1024 * 1024
+	defaultWriteBatchMaxCount    = 16 * 1024
+	defaultWriteBatchConcurrency = 16
+)
+
+type writeBatchOptions struct {
+	maxSize     int
+	maxCount    int
+	concurrency int
+	rawOptions  []RawOption
+}
+
+// WriteBatchOpt is the option of a WriteBatch.
+type WriteBatchOpt func(*writeBatchOptions)
+
+// WithWriteBatchMaxSize sets the total size of keys and values that triggers a flush.
+func WithWriteBatchMaxSize(size int) WriteBatchOpt {
+	return func(opts *writeBatchOptions) {
+		opts.maxSize = size
+	}
+}
+
+// WithWriteBatchMaxCount sets the number of mutations that triggers a flush.
+func WithWriteBatchMaxCount(count int) WriteBatchOpt {
+	return func(opts *writeBatchOptions) {
+		opts.maxCount = count
+	}
+}
+
+// WithWriteBatchConcurrency sets how many requests to different regions are sent concurrently in a flush.
+func WithWriteBatchConcurrency(concurrency int) WriteBatchOpt {
+	return func(opts *writeBatchOptions) {
+		opts.concurrency = concurrency
+	}
+}
+
+// WithWriteBatchRawOptions sets the raw options, e.g. the column family, of all mutations in the batch.
+func WithWriteBatchRawOptions(options ...RawOption) WriteBatchOpt {
+	return func(opts *writeBatchOptions) {
+		opts.rawOptions = options
+	}
+}
+
+// WriteBatch accumulates puts and deletes and writes them to TiKV once the buffered mutations reach the size or
+// count limit. Mutations of the same key are applied in order. Errors of the automatic flushes don't fail Put or
+// Delete, they're collected and reported by Flush.
+//
+// A WriteBatch is not safe for concurrent use.
+type WriteBatch struct {
+	client *Client
+	opts   writeBatchOptions
+
+	// keys are the pending keys in the order they're first mutated.
+	keys [][]byte
+	// values are the pending values by key, nil means the key is deleted.
+	values map[string][]byte
+	size   int
+
+	written    int
+	failed     int
+	firstError error
+}
+
+// NewWriteBatch creates a WriteBatch writing to the client.
+func (c *Client) NewWriteBatch(opts ...WriteBatchOpt) *WriteBatch {
+	b := &WriteBatch{
+		client: c,
+		opts: writeBatchOptions{
+			maxSize:     defaultWriteBatchMaxSize,
+			maxCount:    defaultWriteBatchMaxCount,
+			concurrency: defaultWriteBatchConcurrency,
+		},
+		values: make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	if b.opts.concurrency < 1 {
+		b.opts.concurrency = 1
+	}
+	return b
+}
+
+// Put adds a put of the key-value pair to the batch. It may flush the batch. The key and value are copied, so they can
+// be reused after Put returns.
+func (b *WriteBatch) Put(ctx context.Context, key, value []byte) {
+	if value == nil {
+		// nil is reserved for deletes, an empty value is still a put.
+		value = []byte{}
+	}
+	value, err := b.client.valueTransformers.Transform(key, value)
+	if err != nil {
+		b.onFailed(1, err)
+		return
+	}
+	b.add(ctx, key, value)
+}
+
+// Delete adds a delete of the key to the batch. It may flush the batch. The key is copied, so it can be reused after
+// Delete returns.
+func (b *WriteBatch) Delete(ctx context.Context, key []byte) {
+	b.add(ctx, key, nil)
+}
+
+func (b *WriteBatch) add(ctx context.Context, key, value []byte) {
+	if old, ok := b.values[string(key)]; ok {
+		b.size -= len(old)
+	} else {
+		b.keys = append(b.keys, slices.Clone(key))
+		b.size += len(key)
+	}
+	b.values[string(key)] = slices.Clone(value)
+	b.size += len(value)
+	if b.size >= b.opts.maxSize || len(b.keys) >= b.opts.maxCount {
+		b.flushPending(ctx)
+	}
+}
+
+// Len returns the number of pending mutations.
+func (b *WriteBatch) Len() int {
+	return len(b.keys)
+}
+
+// Flush writes the pending mutations and returns a summary error if any mutation written by the batch so far has
+// failed. The batch can be reused after Flush.
+func (b *WriteBatch) Flush(ctx context.Context) error {
+	b.flushPending(ctx)
+	written, failed, firstError := b.written, b.failed, b.firstError
+	b.written, b.failed, b.firstError = 0, 0, nil
+	if firstError != nil {
+		return errors.Wrapf(firstError, "%d of %d mutations failed to write", failed, written+failed)
+	}
+	return nil
+}
+
+// writeBatchTask is a request of puts or deletes to one region.
+type writeBatchTask struct {
+	batch  kvrpc.Batch
+	delete bool
+}
+
+// flushPending writes the pending mutations. The mutations of a flush have distinct keys, so they're written to
+// regions concurrently, while flushes are sequential to keep the order of mutations of the same key.
+func (b *WriteBatch) flushPending(ctx context.Context) {
+	if len(b.keys) == 0 {
+		return
+	}
+	keys, values := b.keys, b.values
+	b.keys, b.values, b.size = nil, make(map[string][]byte), 0
+
+	opts := b.client.getRawKVOptions(b.opts.rawOptions...)
+	b.client.writeMutations(ctx, keys, values, opts, b.opts.concurrency, func(keys [][]byte, err error) {
+		if err != nil {
+			b.onFailed(len(keys), err)
+		} else {
+			b.written += len(keys)
+		}
+	})
+}
+
+// writeMutations writes the mutations of the distinct keys, whose values are nil for deletes, with at most
+// concurrency requests to the regions at the same time. onDone is called with the keys of every request and its
+// error, or with all the keys if they can't be grouped by regions. onDone is never called concurrently.
+func (c *Client) writeMutations(ctx context.Context, keys [][]byte, values map[string][]byte, opts *rawOptions,
+	concurrency int, onDone func(keys [][]byte, err error)) {
+	var putKeys, deleteKeys [][]byte
+	for _, key := range keys {
+		if values[string(key)] == nil {
+			deleteKeys = append(deleteKeys, key)
+		} else {
+			putKeys = append(putKeys, key)
+		}
+	}
+
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	var tasks []writeBatchTask
+	if len(putKeys) > 0 {
+		groups, _, err := c.regionCache.GroupKeysByRegion(bo, putKeys, nil)
+		if err != nil {
+			onDone(keys, err)
+			return
+		}
+		var batches []kvrpc.Batch
+		for regionID, groupKeys := range groups {
+			batches = kvrpc.AppendBatches(batches, regionID, groupKeys, values, nil, rawBatchPutSize)
+		}
+		for _, batch := range batches {
+			tasks = append(tasks, writeBatchTask{batch: batch})
+		}
+	}
+	if len(deleteKeys) > 0 {
+		groups, _, err := c.regionCache.GroupKeysByRegion(bo, deleteKeys, nil)
+		if err != nil {
+			onDone(keys, err)
+			return
+		}
+		var batches []kvrpc.Batch
+		for regionID, groupKeys := range groups {
+			batches = kvrpc.AppendKeyBatches(batches, regionID, groupKeys, rawBatchPairCount)
+		}
+		for _, batch := range batches {
+			tasks = append(tasks, writeBatchTask{batch: batch, delete: true})
+		}
+	}
+
+	forkedBo, cancel := bo.Fork()
+	defer cancel()
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, concurrency)
+	)
+	for _, task := range tasks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			taskBo, taskCancel := forkedBo.Fork()
+			defer taskCancel()
+			var err error
+			if task.delete {
+				err = c.doBatchReq(taskBo, task.batch, opts, tikvrpc.CmdRawBatchDelete).Error
+			} else {
+				err = c.doBatchPut(taskBo, task.batch, opts)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			onDone(task.batch.Keys, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *WriteBatch) onFailed(count int, err error) {
+	b.failed += count
+	if b.firstError == nil {
+		b.firstError = errors.WithStack(err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawkv
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errCoalescerClosed is returned by the writes submitted after the client is closed.
+var errCoalescerClosed = errors.New("rawkv client is closed")
+
+// writeCoalescer coalesces the concurrent puts and deletes submitted within a window into batch requests. The writes
+// of a window are flushed after the previous window is flushed, and a key written more than once in a window is only
+// written with the last submitted value, so the writes of the same key take effect in the order they're submitted.
+type writeCoalescer struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	pending  *coalescedWrites
+	flushing bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// coalescedWrites is the writes of a window by their options, whose callers wait for done.
+type coalescedWrites struct {
+	groups map[rawOptions]*coalescedGroup
+	done   chan struct{}
+	// ctx carries the values, e.g. the request source, of the first write. The writes are flushed with the latest
+	// deadline of them if every write has one.
+	ctx        context.Context
+	deadline   time.Time
+	noDeadline bool
+}
+
+type coalescedGroup struct {
+	// keys are the keys in the order they're first written.
+	keys [][]byte
+	// values are the values by key, nil means the key is deleted.
+	values map[string][]byte
+	// errs are the errors of the keys failed to write.
+	errs map[string]error
+}
+
+// enableWriteCoalescing makes Put and Delete coalesce the writes within the window.
+func (c *Client) enableWriteCoalescing(window time.Duration) {
+	c.coalescer = &writeCoalescer{window: window}
+}
+
+// coalesceWrite submits the write of the key, whose value is nil for a delete, and waits until it's flushed or ctx
+// is done. The write may still be flushed after ctx is done, so the key and value are copied.
+func (c *Client) coalesceWrite(ctx context.Context, opts *rawOptions, key, value []byte) error {
+	key, value = slices.Clone(key), slices.Clone(value)
+	w := c.coalescer
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return errors.WithStack(errCoalescerClosed)
+	}
+	if w.pending == nil {
+		w.pending = &coalescedWrites{
+			groups: make(map[rawOptions]*coalescedGroup),
+			done:   make(chan struct{}),
+			ctx:    context.WithoutCancel(ctx),
+		}
+		if !w.flushing {
+			w.flushing = true
+			w.wg.Add(1)
+			go c.runWriteCoalescer()
+		}
+	}
+	pending := w.pending
+	if deadline, ok := ctx.Deadline(); !ok {
+		pending.noDeadline = true
+	} else if deadline.After(pending.deadline) {
+		pending.deadline = deadline
+	}
+	group, ok := pending.groups[*opts]
+	if !ok {
+		group = &coalescedGroup{values: make(map[string][]byte), errs: make(map[string]error)}
+		pending.groups[*opts] = group
+	}
+	if _, ok := group.values[string(key)]; !ok {
+		group.keys = append(group.keys, key)
+	}
+	group.values[string(key)] = value
+	w.mu.Unlock()
+
+	select {
+	case <-pending.done:
+		return group.errs[string(key)]
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// runWriteCoalescer flushes the pending writes after the window, and keeps flushing the writes submitted during the
+// previous flush until there are none.
+func (c *Client) runWriteCoalescer() {
+	w := c.coalescer
+	defer w.wg.Done()
+	time.Sleep(w.window)
+	for {
+		w.mu.Lock()
+		pending := w.pending
+		w.pending = nil
+		if pending == nil {
+			w.flushing = false
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+
+		c.flushCoalescedWrites(pending)
+	}
+}
+
+func (c *Client) flushCoalescedWrites(pending *coalescedWrites) {
+	ctx := pending.ctx
+	if !pending.noDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, pending.deadline)
+		defer cancel()
+	}
+	for opts, group := range pending.groups {
+		c.writeMutations(ctx, group.keys, group.values, &opts, defaultWriteBatchConcurrency, func(keys [][]byte, err error) {
+			if err == nil {
+				return
+			}
+			for _, key := range keys {
+				group.errs[string(key)] = errors.WithStack(err)
+			}
+		})
+	}
+	close(pending.done)
+}
+
+// close rejects the writes submitted later and waits for the pending writes to be flushed.
+func (w *writeCoalescer) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.wg.Wait()
+}
@@ -0,0 +1,351 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+)
+
+// ChaosOp is a kind of the chaos applied to a mock cluster by ChaosScheduler.
+type ChaosOp int
+
+// The chaos ops.
+const (
+	// ChaosSplit splits a region at a random key.
+	ChaosSplit ChaosOp = iota
+	// ChaosMerge merges two adjacent regions.
+	ChaosMerge
+	// ChaosTransferLeader transfers the leader of a region to another peer.
+	ChaosTransferLeader
+	// ChaosRestartStore stops a store for ChaosConfig.RestartDuration, moving the leaders on it to the other stores.
+	ChaosRestartStore
+)
+
+func (op ChaosOp) String() string {
+	switch op {
+	case ChaosSplit:
+		return "split"
+	case ChaosMerge:
+		return "merge"
+	case ChaosTransferLeader:
+		return "transfer-leader"
+	case ChaosRestartStore:
+		return "restart-store"
+	}
+	return fmt.Sprintf("ChaosOp(%d)", int(op))
+}
+
+// The defaults of ChaosConfig.
+const (
+	DefaultChaosInterval        = 10 * time.Millisecond
+	DefaultChaosRestartDuration = 50 * time.Millisecond
+	DefaultChaosMaxRegions      = 64
+)
+
+// ChaosConfig is the config of a ChaosScheduler.
+type ChaosConfig struct {
+	// Seed seeds the choice of the ops, the same seed applies the same ops to the same cluster.
+	Seed int64
+	// Interval is the interval between the ops applied by Start, DefaultChaosInterval by default.
+	Interval time.Duration
+	// Ops are the ops applied, all of them by default.
+	Ops []ChaosOp
+	// SplitKey generates the keys the regions are split at. The keys are random 8 bytes by default.
+	SplitKey func(r *rand.Rand) []byte
+	// RestartDuration is how long a restarted store is down, DefaultChaosRestartDuration by default.
+	RestartDuration time.Duration
+	// MaxRegions is the max number of the regions split to, DefaultChaosMaxRegions by default.
+	MaxRegions int
+}
+
+// ChaosEvent is an op applied by ChaosScheduler.
+type ChaosEvent struct {
+	Op ChaosOp
+	// Applied is false if the op can't be applied to the cluster, e.g. merging the only region.
+	Applied bool
+	// RegionID is the region split or merged into, or whose leader is transferred.
+	RegionID uint64
+	// StoreID is the store restarted, or the leader is transferred to.
+	StoreID uint64
+	// Key is the key a region is split at.
+	Key []byte
+}
+
+// ChaosScheduler continuously applies random region splits, merges, leader transfers and store restarts to a mock
+// cluster, so the retry paths of the client can be tested against the changes of the cluster in soak tests. At most
+// one store is down at a time.
+type ChaosScheduler struct {
+	cluster *MockCluster
+	cfg     ChaosConfig
+
+	mu struct {
+		sync.Mutex
+		rng       *rand.Rand
+		events    []ChaosEvent
+		downStore uint64
+		restart   *time.Timer
+	}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewChaosScheduler creates a ChaosScheduler of the cluster.
+func NewChaosScheduler(cluster *MockCluster, cfg ChaosConfig) *ChaosScheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultChaosInterval
+	}
+	if len(cfg.Ops) == 0 {
+		cfg.Ops = []ChaosOp{ChaosSplit, ChaosMerge, ChaosTransferLeader, ChaosRestartStore}
+	}
+	if cfg.SplitKey == nil {
+		cfg.SplitKey = func(r *rand.Rand) []byte {
+			key := make([]byte, 8)
+			r.Read(key)
+			return key
+		}
+	}
+	if cfg.RestartDuration <= 0 {
+		cfg.RestartDuration = DefaultChaosRestartDuration
+	}
+	if cfg.MaxRegions <= 0 {
+		cfg.MaxRegions = DefaultChaosMaxRegions
+	}
+	s := &ChaosScheduler{cluster: cluster, cfg: cfg}
+	s.mu.rng = rand.New(rand.NewSource(cfg.Seed))
+	return s
+}
+
+// Start applies an op every interval until Stop is called.
+func (s *ChaosScheduler) Start() {
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.Step()
+			}
+		}
+	}()
+}
+
+// Stop stops applying the ops and starts the store being restarted.
+func (s *ChaosScheduler) Stop() {
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.wg.Wait()
+		s.stopCh = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.restart != nil && s.mu.restart.Stop() {
+		s.startStoreLocked()
+	}
+}
+
+// Events returns the ops applied so far.
+func (s *ChaosScheduler) Events() []ChaosEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.mu.events)
+}
+
+// Step applies a random op and returns it.
+func (s *ChaosScheduler) Step() ChaosEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := ChaosEvent{Op: s.cfg.Ops[s.mu.rng.Intn(len(s.cfg.Ops))]}
+	switch e.Op {
+	case ChaosSplit:
+		s.split(&e)
+	case ChaosMerge:
+		s.merge(&e)
+	case ChaosTransferLeader:
+		s.transferLeader(&e)
+	case ChaosRestartStore:
+		s.restartStore(&e)
+	}
+	s.mu.events = append(s.mu.events, e)
+	return e
+}
+
+// regions returns the regions sorted by their start keys.
+func (s *ChaosScheduler) regions() []*metapb.Region {
+	var metas []*metapb.Region
+	for _, r := range s.cluster.ScanRegions(nil, nil, 0) {
+		metas = append(metas, r.Meta)
+	}
+	return metas
+}
+
+func (s *ChaosScheduler) split(e *ChaosEvent) {
+	regions := s.regions()
+	key := s.cfg.SplitKey(s.mu.rng)
+	if len(regions) >= s.cfg.MaxRegions || len(key) == 0 {
+		return
+	}
+	encodedKey := mocktikv.NewMvccKey(key)
+	i := sort.Search(len(regions), func(i int) bool {
+		return bytes.Compare(regions[i].GetStartKey(), encodedKey) > 0
+	}) - 1
+	region := regions[i]
+	if bytes.Equal(region.GetStartKey(), encodedKey) {
+		return
+	}
+	_, leader, _, _ := s.cluster.GetRegionByID(region.GetId())
+	peerIDs := s.cluster.AllocIDs(len(region.GetPeers()))
+	leaderPeerID := peerIDs[0]
+	for j, peer := range region.GetPeers() {
+		if peer.GetId() == leader.GetId() {
+			leaderPeerID = peerIDs[j]
+		}
+	}
+	s.cluster.Split(region.GetId(), s.cluster.AllocID(), key, peerIDs, leaderPeerID)
+	e.Applied, e.RegionID, e.Key = true, region.GetId(), key
+}
+
+func (s *ChaosScheduler) merge(e *ChaosEvent) {
+	regions := s.regions()
+	if len(regions) < 2 {
+		return
+	}
+	i := s.mu.rng.Intn(len(regions) - 1)
+	s.cluster.Merge(regions[i].GetId(), regions[i+1].GetId())
+	e.Applied, e.RegionID = true, regions[i].GetId()
+}
+
+func (s *ChaosScheduler) transferLeader(e *ChaosEvent) {
+	regions := s.regions()
+	region := regions[s.mu.rng.Intn(len(regions))]
+	_, leader, _, _ := s.cluster.GetRegionByID(region.GetId())
+	var candidates []*metapb.Peer
+	for _, peer := range region.GetPeers() {
+		if peer.GetId() != leader.GetId() && peer.GetStoreId() != s.mu.downStore {
+			candidates = append(candidates, peer)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	peer := candidates[s.mu.rng.Intn(len(candidates))]
+	s.cluster.ChangeLeader(region.GetId(), peer.GetId())
+	e.Applied, e.RegionID, e.StoreID = true, region.GetId(), peer.GetStoreId()
+}
+
+func (s *ChaosScheduler) restartStore(e *ChaosEvent) {
+	if s.mu.downStore != 0 {
+		return
+	}
+	stores := s.cluster.GetAllStores()
+	slices.SortFunc(stores, func(a, b *metapb.Store) int { return cmp.Compare(a.GetId(), b.GetId()) })
+	store := stores[s.mu.rng.Intn(len(stores))]
+	// The leaders on the stopped store are elected on the other stores.
+	for _, region := range s.regions() {
+		_, leader, _, _ := s.cluster.GetRegionByID(region.GetId())
+		if leader.GetStoreId() != store.GetId() {
+			continue
+		}
+		for _, peer := range region.GetPeers() {
+			if peer.GetStoreId() != store.GetId() {
+				s.cluster.ChangeLeader(region.GetId(), peer.GetId())
+				break
+			}
+		}
+	}
+	s.cluster.StopStore(store.GetId())
+	s.mu.downStore = store.GetId()
+	s.mu.restart = time.AfterFunc(s.cfg.RestartDuration, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.startStoreLocked()
+	})
+	e.Applied, e.StoreID = true, store.GetId()
+}
+
+func (s *ChaosScheduler) startStoreLocked() {
+	if s.mu.downStore != 0 {
+		s.cluster.StartStore(s.mu.downStore)
+		s.mu.downStore, s.mu.restart = 0, nil
+	}
+}
+
+// MockStoreLiveness returns the liveness of the stores of the cluster, which is up unless the store is stopped. It's
+// passed to StoreProbe.SetMockStoreLiveness so the client finds the restarted stores reachable again.
+func MockStoreLiveness(cluster *MockCluster) func(storeID uint64) bool {
+	return func(storeID uint64) bool {
+		store := cluster.GetStore(storeID)
+		return store != nil && store.GetState() == metapb.StoreState_Up
+	}
+}
+
+// ChaosInvariants checks that the client reads the committed writes consistently under chaos. The writes committed
+// are recorded by RecordCommit, and a value read at a ts is checked by CheckRead to be the value of the latest commit
+// before the ts. All the commits of the keys checked must be recorded, so the transactions whose commit results are
+// undetermined shouldn't write the keys.
+type ChaosInvariants struct {
+	mu      sync.Mutex
+	commits map[string][]chaosCommit
+}
+
+type chaosCommit struct {
+	commitTS uint64
+	value    []byte
+}
+
+// NewChaosInvariants creates a ChaosInvariants.
+func NewChaosInvariants() *ChaosInvariants {
+	return &ChaosInvariants{commits: make(map[string][]chaosCommit)}
+}
+
+// RecordCommit records the value of key committed at commitTS. A nil value is a delete.
+func (c *ChaosInvariants) RecordCommit(key, value []byte, commitTS uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commits := c.commits[string(key)]
+	i := sort.Search(len(commits), func(i int) bool { return commits[i].commitTS > commitTS })
+	c.commits[string(key)] = slices.Insert(commits, i, chaosCommit{commitTS: commitTS, value: slices.Clone(value)})
+}
+
+// CheckRead checks the value of key read at readTS, a nil value means the key isn't found.
+func (c *ChaosInvariants) CheckRead(key, value []byte, readTS uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commits := c.commits[string(key)]
+	i := sort.Search(len(commits), func(i int) bool { return commits[i].commitTS > readTS })
+	var expected []byte
+	if i > 0 {
+		expected = commits[i-1].value
+	}
+	if !bytes.Equal(expected, value) {
+		return errors.Errorf("key %q read at ts %d is %q, expected %q", key, readTS, value, expected)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/async"
+)
+
+// SendReqPhase is a phase of the retry state machine of RegionRequestSender.
+type SendReqPhase = locate.SendReqPhase
+
+// SendReqTransition describes a transition of the retry state machine of RegionRequestSender.
+type SendReqTransition = locate.SendReqTransition
+
+// SimulatedReply is a scripted reply of a store in RegionRequestSimulator.
+type SimulatedReply struct {
+	// StoreID restricts the reply to the requests sent to the store. 0 matches any store.
+	StoreID uint64
+	// RegionErr is returned as the region error of the response.
+	RegionErr *errorpb.Error
+	// Err is returned as the RPC error if it's not nil.
+	Err error
+}
+
+// SimulationResult is the result of a request sent by RegionRequestSimulator.
+type SimulationResult struct {
+	Resp *tikvrpc.Response
+	Err  error
+	// Attempts are the IDs of the stores the request is sent to, in order.
+	Attempts []uint64
+	// Transitions are the transitions of the retry state machine, in order.
+	Transitions []SendReqTransition
+}
+
+// RegionRequestSimulator sends requests with a RegionRequestSender to a mock cluster with one
+// region replicated on every store. The stores reply the scripted results before handling the
+// requests normally, so the retry behavior under a sequence of region errors can be verified
+// without a TiKV cluster.
+type RegionRequestSimulator struct {
+	Cluster  *MockCluster
+	StoreIDs []uint64
+	PeerIDs  []uint64
+	RegionID uint64
+
+	client *simulatedClient
+	cache  *locate.RegionCache
+}
+
+// NewRegionRequestSimulator creates a RegionRequestSimulator with the given number of stores. The
+// leader of the region is on the first store.
+func NewRegionRequestSimulator(storeCount int) (*RegionRequestSimulator, error) {
+	rpcClient, cluster, pdClient, err := mocktikv.NewTiKVAndPDClient("", nil)
+	if err != nil {
+		return nil, err
+	}
+	storeIDs, peerIDs, regionID, _ := mocktikv.BootstrapWithMultiStores(cluster, storeCount)
+	return &RegionRequestSimulator{
+		Cluster:  cluster,
+		StoreIDs: storeIDs,
+		PeerIDs:  peerIDs,
+		RegionID: regionID,
+		client:   &simulatedClient{RPCClient: rpcClient, cluster: cluster},
+		cache:    locate.NewRegionCache(locate.NewCodecPDClient(apicodec.ModeTxn, pdClient)),
+	}, nil
+}
+
+// Script appends replies to the script. A request sent to a store consumes the first reply
+// matching the store, or is handled by the store normally if there isn't any.
+func (s *RegionRequestSimulator) Script(replies ...SimulatedReply) {
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.client.script = append(s.client.script, replies...)
+}
+
+// Send sends the request to the region with a new RegionRequestSender and records how it's retried.
+func (s *RegionRequestSimulator) Send(bo *retry.Backoffer, req *tikvrpc.Request, timeout time.Duration) SimulationResult {
+	var result SimulationResult
+	loc, err := s.cache.LocateRegionByID(bo, s.RegionID)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	s.client.mu.Lock()
+	s.client.attempts = nil
+	s.client.mu.Unlock()
+
+	sender := locate.NewRegionRequestSender(s.cache, s.client, oracle.NoopReadTSValidator{})
+	sender.SetTransitionHook(func(t SendReqTransition) {
+		result.Transitions = append(result.Transitions, t)
+	})
+	result.Resp, _, _, result.Err = sender.SendReqCtx(bo, req, loc.Region, timeout, tikvrpc.TiKV)
+
+	s.client.mu.Lock()
+	result.Attempts = s.client.attempts
+	s.client.mu.Unlock()
+	return result
+}
+
+// Close releases the resources of the simulator.
+func (s *RegionRequestSimulator) Close() {
+	s.cache.Close()
+	s.client.Close()
+}
+
+type simulatedClient struct {
+	*mocktikv.RPCClient
+	cluster *mocktikv.Cluster
+
+	mu       sync.Mutex
+	script   []SimulatedReply
+	attempts []uint64
+}
+
+func (c *simulatedClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	var storeID uint64
+	if store := c.cluster.GetStoreByAddr(addr); store != nil {
+		storeID = store.GetId()
+	}
+	c.mu.Lock()
+	c.attempts = append(c.attempts, storeID)
+	for i, reply := range c.script {
+		if reply.StoreID != 0 && reply.StoreID != storeID {
+			continue
+		}
+		c.script = append(c.script[:i], c.script[i+1:]...)
+		c.mu.Unlock()
+		if reply.Err != nil {
+			return nil, reply.Err
+		}
+		if reply.RegionErr != nil {
+			return tikvrpc.GenRegionErrorResp(req, reply.RegionErr)
+		}
+		return c.RPCClient.SendRequest(ctx, addr, req, timeout)
+	}
+	c.mu.Unlock()
+	return c.RPCClient.SendRequest(ctx, addr, req, timeout)
+}
+
+func (c *simulatedClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
+	go func() {
+		cb.Schedule(c.SendRequest(ctx, addr, req, 0))
+	}()
+}
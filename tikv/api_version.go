@@ -0,0 +1,153 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	pd "github.com/tikv/pd/client"
+	"github.com/tikv/pd/client/opt"
+)
+
+// storeStorageConfig is the part of the config of TiKV, returned by the /config status API, that decides the API
+// version.
+type storeStorageConfig struct {
+	Storage struct {
+		APIVersion int  `json:"api-version"`
+		EnableTTL  bool `json:"enable-ttl"`
+	} `json:"storage"`
+}
+
+func (c *storeStorageConfig) apiVersion() (kvrpcpb.APIVersion, error) {
+	switch c.Storage.APIVersion {
+	case 1:
+		if c.Storage.EnableTTL {
+			return kvrpcpb.APIVersion_V1TTL, nil
+		}
+		return kvrpcpb.APIVersion_V1, nil
+	case 2:
+		return kvrpcpb.APIVersion_V2, nil
+	default:
+		return 0, errors.Errorf("unknown api version: %d", c.Storage.APIVersion)
+	}
+}
+
+func modeName(mode Mode) string {
+	if mode == ModeRaw {
+		return "raw"
+	}
+	return "txn"
+}
+
+// DetectAPIVersion returns the API version served by the TiKV cluster, by querying the storage config of all up TiKV
+// stores through their status addresses. It returns ErrAPIVersionMismatch if the stores don't agree with each other,
+// or the API version doesn't support the mode, i.e. txn mode on a V1TTL cluster.
+func DetectAPIVersion(ctx context.Context, pdClient pd.Client, security config.Security, mode Mode) (kvrpcpb.APIVersion, error) {
+	stores, err := pdClient.GetAllStores(ctx, opt.WithExcludeTombstone())
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	scheme, httpClient := "http", http.DefaultClient
+	if len(security.ClusterSSLCA) != 0 {
+		tlsConfig, err := security.ToTLSConfig()
+		if err != nil {
+			return 0, err
+		}
+		scheme, httpClient = "https", &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+
+	var (
+		detected   kvrpcpb.APIVersion
+		detectedBy *metapb.Store
+	)
+	for _, store := range stores {
+		if store.GetState() != metapb.StoreState_Up || tikvrpc.GetStoreTypeByMeta(store) != tikvrpc.TiKV {
+			continue
+		}
+		apiVersion, err := getStoreAPIVersion(ctx, httpClient, scheme+"://"+store.GetStatusAddress()+"/config")
+		if err != nil {
+			return 0, errors.WithMessagef(err, "failed to get the config of store %d", store.GetId())
+		}
+		if detectedBy != nil && apiVersion != detected {
+			return 0, &tikverr.ErrAPIVersionMismatch{
+				StoreID:  store.GetId(),
+				Addr:     store.GetAddress(),
+				Mode:     modeName(mode),
+				Expected: detected,
+				Actual:   apiVersion,
+			}
+		}
+		if mode == ModeTxn && apiVersion == kvrpcpb.APIVersion_V1TTL {
+			return 0, &tikverr.ErrAPIVersionMismatch{
+				StoreID:  store.GetId(),
+				Addr:     store.GetAddress(),
+				Mode:     modeName(mode),
+				Expected: kvrpcpb.APIVersion_V1,
+				Actual:   apiVersion,
+			}
+		}
+		detected, detectedBy = apiVersion, store
+	}
+	if detectedBy == nil {
+		return 0, errors.New("no available TiKV store to detect the api version")
+	}
+	return detected, nil
+}
+
+// CheckAPIVersion checks that the TiKV cluster serves the given API version in the mode. It returns
+// ErrAPIVersionMismatch otherwise.
+func CheckAPIVersion(ctx context.Context, pdClient pd.Client, security config.Security, mode Mode, apiVersion kvrpcpb.APIVersion) error {
+	detected, err := DetectAPIVersion(ctx, pdClient, security, mode)
+	if err != nil {
+		return err
+	}
+	// A V1 client can access a V1TTL cluster in raw mode, without TTL support.
+	if detected == apiVersion || (mode == ModeRaw && apiVersion == kvrpcpb.APIVersion_V1 && detected == kvrpcpb.APIVersion_V1TTL) {
+		return nil
+	}
+	return &tikverr.ErrAPIVersionMismatch{
+		Mode:     modeName(mode),
+		Expected: apiVersion,
+		Actual:   detected,
+	}
+}
+
+func getStoreAPIVersion(ctx context.Context, httpClient *http.Client, url string) (kvrpcpb.APIVersion, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("unexpected status: %s", resp.Status)
+	}
+	var cfg storeStorageConfig
+	if err = json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return cfg.apiVersion()
+}
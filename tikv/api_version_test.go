@@ -0,0 +1,98 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+	pd "github.com/tikv/pd/client"
+	"github.com/tikv/pd/client/opt"
+)
+
+type storesPDClient struct {
+	pd.Client
+	stores []*metapb.Store
+}
+
+func (c *storesPDClient) GetAllStores(ctx context.Context, opts ...opt.GetStoreOption) ([]*metapb.Store, error) {
+	return c.stores, nil
+}
+
+func TestDetectAPIVersion(t *testing.T) {
+	newStore := func(id uint64, apiVersion int, enableTTL bool) *metapb.Store {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/config", r.URL.Path)
+			fmt.Fprintf(w, `{"storage": {"api-version": %d, "enable-ttl": %v}, "server": {}}`, apiVersion, enableTTL)
+		}))
+		t.Cleanup(server.Close)
+		return &metapb.Store{
+			Id:            id,
+			Address:       fmt.Sprintf("store%d", id),
+			StatusAddress: strings.TrimPrefix(server.URL, "http://"),
+			State:         metapb.StoreState_Up,
+		}
+	}
+	ctx := context.Background()
+	var mismatch *tikverr.ErrAPIVersionMismatch
+
+	// TiFlash and offline stores are ignored.
+	tiflash := newStore(3, 1, false)
+	tiflash.Labels = []*metapb.StoreLabel{{Key: "engine", Value: "tiflash"}}
+	offline := newStore(4, 1, false)
+	offline.State = metapb.StoreState_Offline
+	pdClient := &storesPDClient{stores: []*metapb.Store{newStore(1, 2, false), newStore(2, 2, false), tiflash, offline}}
+	apiVersion, err := DetectAPIVersion(ctx, pdClient, config.Security{}, ModeTxn)
+	require.Nil(t, err)
+	require.Equal(t, kvrpcpb.APIVersion_V2, apiVersion)
+	require.Nil(t, CheckAPIVersion(ctx, pdClient, config.Security{}, ModeRaw, kvrpcpb.APIVersion_V2))
+	err = CheckAPIVersion(ctx, pdClient, config.Security{}, ModeRaw, kvrpcpb.APIVersion_V1)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, kvrpcpb.APIVersion_V1, mismatch.Expected)
+	require.Equal(t, kvrpcpb.APIVersion_V2, mismatch.Actual)
+
+	// The stores don't agree with each other.
+	pdClient.stores = []*metapb.Store{newStore(1, 1, false), newStore(2, 2, false)}
+	_, err = DetectAPIVersion(ctx, pdClient, config.Security{}, ModeRaw)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, uint64(2), mismatch.StoreID)
+	require.Equal(t, kvrpcpb.APIVersion_V1, mismatch.Expected)
+	require.Equal(t, kvrpcpb.APIVersion_V2, mismatch.Actual)
+
+	// V1TTL only supports raw mode.
+	pdClient.stores = []*metapb.Store{newStore(1, 1, true)}
+	apiVersion, err = DetectAPIVersion(ctx, pdClient, config.Security{}, ModeRaw)
+	require.Nil(t, err)
+	require.Equal(t, kvrpcpb.APIVersion_V1TTL, apiVersion)
+	require.Nil(t, CheckAPIVersion(ctx, pdClient, config.Security{}, ModeRaw, kvrpcpb.APIVersion_V1))
+	_, err = DetectAPIVersion(ctx, pdClient, config.Security{}, ModeTxn)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "txn", mismatch.Mode)
+	require.Equal(t, kvrpcpb.APIVersion_V1TTL, mismatch.Actual)
+
+	pdClient.stores = nil
+	_, err = DetectAPIVersion(ctx, pdClient, config.Security{}, ModeRaw)
+	require.NotNil(t, err)
+}
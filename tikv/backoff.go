@@ -99,3 +99,18 @@ func NewGcResolveLockMaxBackoffer(ctx context.Context) *Backoffer {
 
 // NewNoopBackoff create a Backoffer do nothing just return error directly
 var NewNoopBackoff = retry.NewNoopBackoff
+
+// BackoffStat is the stat of a type of backoff.
+type BackoffStat = retry.BackoffStat
+
+// BackoffStatsCollector accumulates the backoffs of an operation by the backoff type.
+type BackoffStatsCollector = retry.BackoffStatsCollector
+
+// NewBackoffStatsCollector creates an empty BackoffStatsCollector.
+var NewBackoffStatsCollector = retry.NewBackoffStatsCollector
+
+// WithBackoffStatsCollector returns a context that makes the operations using it record their backoffs to c.
+var WithBackoffStatsCollector = retry.WithBackoffStatsCollector
+
+// BackoffStats returns the stats of all types of backoff happened in the process, keyed by the backoff type.
+var BackoffStats = retry.BackoffStats
@@ -0,0 +1,118 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+)
+
+// boundedStalenessMaxBackoff is the max backoff of locating the regions of the key ranges.
+const boundedStalenessMaxBackoff = 20000
+
+// BoundedStalenessTS is a read ts picked by GetBoundedStalenessTS.
+type BoundedStalenessTS struct {
+	TS uint64
+	// AllReplicasReady indicates whether TS is not after the safe ts of any TiKV replica of the key ranges, so any
+	// replica can serve the reads. Otherwise TS is the staleness bound, and some replicas have to read from the leader.
+	AllReplicasReady bool
+}
+
+// GetBoundedStalenessTS picks a single read ts for the key ranges, which may span many regions, with a staleness no
+// more than maxStaleness. It's the minimum safe ts of the stores of the TiKV replicas of the ranges, or the staleness
+// bound if the minimum safe ts is older than the bound.
+func (s *KVStore) GetBoundedStalenessTS(ctx context.Context, ranges []kv.KeyRange, maxStaleness time.Duration) (BoundedStalenessTS, error) {
+	bo := NewBackofferWithVars(ctx, boundedStalenessMaxBackoff, nil)
+	now, err := s.getTimestampWithRetry(bo, oracle.GlobalTxnScope)
+	if err != nil {
+		return BoundedStalenessTS{}, err
+	}
+	bound := oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-maxStaleness))
+
+	tikvStores := make(map[uint64]struct{})
+	for _, store := range s.regionCache.GetStoresByType(tikvrpc.TiKV) {
+		tikvStores[store.StoreID()] = struct{}{}
+	}
+	involved := make(map[uint64]struct{})
+	for _, r := range ranges {
+		regions, err := s.regionCache.LoadRegionsInKeyRange(bo, r.StartKey, r.EndKey)
+		if err != nil {
+			return BoundedStalenessTS{}, errors.WithStack(err)
+		}
+		for _, region := range regions {
+			for _, peer := range region.GetMeta().GetPeers() {
+				if _, ok := tikvStores[peer.GetStoreId()]; ok {
+					involved[peer.GetStoreId()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	minSafeTS := now
+	for storeID := range involved {
+		ok, safeTS := s.getSafeTS(storeID)
+		if !ok {
+			// The safe ts of each store isn't loaded if the cluster-level one is got from PD, which is not later than
+			// the one of any store.
+			safeTS = s.GetMinSafeTS(oracle.GlobalTxnScope)
+		}
+		minSafeTS = min(minSafeTS, safeTS)
+	}
+	if len(involved) == 0 || minSafeTS < bound {
+		return BoundedStalenessTS{TS: bound}, nil
+	}
+	return BoundedStalenessTS{TS: minSafeTS, AllReplicasReady: true}, nil
+}
+
+// ScanRangesWithBoundedStaleness scans the key ranges, which may span many regions, at a single ts picked by
+// GetBoundedStalenessTS, e.g. for dashboards that need a consistent view of many ranges but tolerate staleness. The
+// scans are stale reads served by any replica ready at the ts, which fall back to the leaders otherwise. fn is
+// called for each key-value pair in the order of the ranges, and the scan stops if it returns an error. It returns the
+// read ts.
+func (s *KVStore) ScanRangesWithBoundedStaleness(ctx context.Context, ranges []kv.KeyRange, maxStaleness time.Duration, fn func(key, value []byte) error) (uint64, error) {
+	ts, err := s.GetBoundedStalenessTS(ctx, ranges, maxStaleness)
+	if err != nil {
+		return 0, err
+	}
+	snapshot := s.GetSnapshot(ts.TS)
+	snapshot.SetIsStalenessReadOnly(true)
+	snapshot.SetReadReplicaScope(oracle.GlobalTxnScope)
+	for _, r := range ranges {
+		if err := ctx.Err(); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		iter, err := snapshot.IterWithOptions(r.StartKey, r.EndKey, txnsnapshot.WithStaleReadScan())
+		if err != nil {
+			return 0, err
+		}
+		for iter.Valid() {
+			if err = fn(iter.Key(), iter.Value()); err == nil {
+				err = iter.Next()
+			}
+			if err != nil {
+				iter.Close()
+				return 0, err
+			}
+		}
+		iter.Close()
+	}
+	return ts.TS, nil
+}
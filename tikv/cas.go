@@ -0,0 +1,117 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// CASMutation describes the check and the write applied to a single key by
+// KVStore.CompareAndSwap.
+type CASMutation struct {
+	Key []byte
+	// Expected is the value the key must hold for the swap to happen. A nil
+	// Expected means the key must not exist.
+	Expected []byte
+	// New is the value written to the key if all checks pass. A nil New means
+	// the key is deleted.
+	New []byte
+}
+
+// CompareAndSwap atomically checks that every key holds its expected value and,
+// only if all of them match, writes the new values. The keys may span multiple
+// regions.
+//
+// It runs a short optimistic transaction under the hood. 1PC and async commit
+// are enabled so that the write finishes in a single round trip when all keys
+// fall in one region, and the commit latency is reduced otherwise. Keys whose
+// new value equals the expected one are locked instead of rewritten, so that
+// concurrent writers are still detected.
+//
+// If any check fails, swapped is false and current holds the values read for
+// all keys (a missing key has no entry). A concurrent modification of any key is
+// reported as a write conflict error, which the caller may retry.
+func (s *KVStore) CompareAndSwap(ctx context.Context, mutations []CASMutation) (swapped bool, current map[string][]byte, err error) {
+	if len(mutations) == 0 {
+		return true, nil, nil
+	}
+	keys := make([][]byte, 0, len(mutations))
+	seen := make(map[string]struct{}, len(mutations))
+	for _, m := range mutations {
+		if len(m.Key) == 0 {
+			return false, nil, errors.New("compare and swap: empty key")
+		}
+		if _, ok := seen[string(m.Key)]; ok {
+			return false, nil, errors.Errorf("compare and swap: duplicated key %q", m.Key)
+		}
+		if m.New != nil && len(m.New) == 0 {
+			return false, nil, errors.Errorf("compare and swap: empty new value for key %q", m.Key)
+		}
+		seen[string(m.Key)] = struct{}{}
+		keys = append(keys, m.Key)
+	}
+
+	txn, err := s.Begin()
+	if err != nil {
+		return false, nil, err
+	}
+	defer func() {
+		if txn.Valid() {
+			_ = txn.Rollback()
+		}
+	}()
+	txn.SetEnable1PC(true)
+	txn.SetEnableAsyncCommit(true)
+
+	current, err = txn.BatchGet(ctx, keys)
+	if err != nil {
+		return false, nil, err
+	}
+	for _, m := range mutations {
+		old, exists := current[string(m.Key)]
+		if exists != (m.Expected != nil) || !bytes.Equal(old, m.Expected) {
+			return false, current, nil
+		}
+	}
+
+	var lockOnly [][]byte
+	for _, m := range mutations {
+		switch {
+		case m.New == nil && m.Expected == nil:
+			lockOnly = append(lockOnly, m.Key)
+		case m.New == nil:
+			err = txn.Delete(m.Key)
+		case bytes.Equal(m.New, m.Expected):
+			lockOnly = append(lockOnly, m.Key)
+		default:
+			err = txn.Set(m.Key, m.New)
+		}
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	if len(lockOnly) > 0 {
+		if err = txn.LockKeysWithWaitTime(ctx, 0, lockOnly...); err != nil {
+			return false, nil, err
+		}
+	}
+	if err = txn.Commit(ctx); err != nil {
+		return false, nil, err
+	}
+	return true, nil, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/testutils"
+)
+
+func TestTxnHelpers(t *testing.T) {
+	suite.Run(t, new(testTxnHelperSuite))
+}
+
+type testTxnHelperSuite struct {
+	suite.Suite
+	store *KVStore
+}
+
+func (s *testTxnHelperSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	mocktikv.BootstrapWithMultiRegions(cluster, []byte("m"))
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+}
+
+func (s *testTxnHelperSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testTxnHelperSuite) mustGet(key string) ([]byte, bool) {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	defer txn.Rollback()
+	val, err := txn.Get(context.Background(), []byte(key))
+	if tikverr.IsErrNotFound(err) {
+		return nil, false
+	}
+	s.Require().Nil(err)
+	return val, true
+}
+
+func (s *testTxnHelperSuite) TestCompareAndSwap() {
+	ctx := context.Background()
+
+	// Insert keys in both regions.
+	swapped, _, err := s.store.CompareAndSwap(ctx, []CASMutation{
+		{Key: []byte("a"), New: []byte("1")},
+		{Key: []byte("x"), New: []byte("2")},
+	})
+	s.Nil(err)
+	s.True(swapped)
+	val, ok := s.mustGet("x")
+	s.True(ok)
+	s.Equal([]byte("2"), val)
+
+	// A mismatched expectation leaves everything untouched.
+	swapped, current, err := s.store.CompareAndSwap(ctx, []CASMutation{
+		{Key: []byte("a"), Expected: []byte("1"), New: []byte("10")},
+		{Key: []byte("x"), Expected: []byte("3"), New: []byte("20")},
+		{Key: []byte("z"), New: []byte("30")},
+	})
+	s.Nil(err)
+	s.False(swapped)
+	s.Equal(map[string][]byte{"a": []byte("1"), "x": []byte("2")}, current)
+	val, _ = s.mustGet("a")
+	s.Equal([]byte("1"), val)
+	_, ok = s.mustGet("z")
+	s.False(ok)
+
+	// Update, delete and check an unchanged key at the same time.
+	swapped, _, err = s.store.CompareAndSwap(ctx, []CASMutation{
+		{Key: []byte("a"), Expected: []byte("1"), New: []byte("1")},
+		{Key: []byte("x"), Expected: []byte("2")},
+		{Key: []byte("z"), New: []byte("30")},
+	})
+	s.Nil(err)
+	s.True(swapped)
+	val, _ = s.mustGet("a")
+	s.Equal([]byte("1"), val)
+	_, ok = s.mustGet("x")
+	s.False(ok)
+	val, _ = s.mustGet("z")
+	s.Equal([]byte("30"), val)
+
+	_, _, err = s.store.CompareAndSwap(ctx, []CASMutation{{Key: []byte("a")}, {Key: []byte("a")}})
+	s.NotNil(err)
+}
@@ -35,9 +35,14 @@
 package tikv
 
 import (
+	"context"
+	"time"
+
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/internal/apicodec"
 	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"google.golang.org/grpc"
 )
 
 // Client is a client that sends RPC.
@@ -47,6 +52,77 @@ type Client = client.Client
 // ClientEventListener is a listener to handle events produced by `Client`.
 type ClientEventListener = client.ClientEventListener
 
+// ConnEventListener can be implemented by a ClientEventListener to observe the recycling of the idle connections.
+type ConnEventListener = client.ConnEventListener
+
+// OutdatedResponseListener can be implemented by a ClientEventListener to observe the batch responses whose requests
+// are already finished.
+type OutdatedResponseListener = client.OutdatedResponseListener
+
+// DrainEventListener can be implemented by a ClientEventListener to observe the stores draining the connections.
+type DrainEventListener = client.DrainEventListener
+
+// HealthFeedbackSimulator is a Client feeding synthetic HealthFeedback of the stores to its event listener, e.g. to
+// test the listeners and the logic driven by the slow scores deterministically.
+type HealthFeedbackSimulator = client.HealthFeedbackSimulator
+
+// SimulatedHealthFeedbackListener can be implemented by a ClientEventListener to handle the feedback fed by a
+// HealthFeedbackSimulator differently.
+type SimulatedHealthFeedbackListener = client.SimulatedHealthFeedbackListener
+
+// NewHealthFeedbackSimulator creates a HealthFeedbackSimulator wrapping c, which can be used to create a KVStore, e.g.
+// by NewTestTiKVStore, whose region cache receives the simulated feedback.
+func NewHealthFeedbackSimulator(c Client) *HealthFeedbackSimulator {
+	return client.NewHealthFeedbackSimulator(c)
+}
+
+// AdmissionController decides whether and when a request is put into the batch commands queue of a store.
+type AdmissionController = client.AdmissionController
+
+// AdmissionInfo is the information of a batch commands request to be admitted.
+type AdmissionInfo = client.AdmissionInfo
+
+// AdmissionFeedback can be implemented by an AdmissionController to learn the results of the admitted requests.
+type AdmissionFeedback = client.AdmissionFeedback
+
+// SLOClass is a class of requests sharing a target latency.
+type SLOClass = client.SLOClass
+
+// SLOShaperConfig is the config of an SLOShaper.
+type SLOShaperConfig = client.SLOShaperConfig
+
+// SLOShaper is an AdmissionController protecting the SLOClasses of higher priorities by throttling the ones of lower
+// priorities when a store slows down.
+type SLOShaper = client.SLOShaper
+
+// NewSLOShaper creates an SLOShaper of the classes, which is set by WithAdmissionController.
+func NewSLOShaper(cfg SLOShaperConfig) (*SLOShaper, error) {
+	return client.NewSLOShaper(cfg)
+}
+
+// WithSLOClass returns a context whose requests are shaped as the class by an SLOShaper.
+func WithSLOClass(ctx context.Context, class string) context.Context {
+	return client.WithSLOClass(ctx, class)
+}
+
+// BatchSchedulerPolicy decides how the requests sent to a store are batched, replacing the preset batch policies.
+type BatchSchedulerPolicy = client.BatchSchedulerPolicy
+
+// RegisterBatchSchedulerPolicy registers a batch policy named name, which can be set as config.TiKVClient.BatchPolicy
+// or by KVStore.SetBatchPolicy.
+func RegisterBatchSchedulerPolicy(name string, newPolicy func() BatchSchedulerPolicy) error {
+	return client.RegisterBatchSchedulerPolicy(name, newPolicy)
+}
+
+// ClientMetadata identifies the application instance sending the requests to TiKV, which is set by WithClientMetadata.
+type ClientMetadata = client.ClientMetadata
+
+// Credential is a token attached to the requests to TiKV.
+type Credential = client.Credential
+
+// CredentialProvider provides the credentials attached to the requests to TiKV.
+type CredentialProvider = client.CredentialProvider
+
 // ClientOpt defines the option to create RPC client.
 type ClientOpt = client.Opt
 
@@ -60,6 +136,40 @@ func WithCodec(codec apicodec.Codec) ClientOpt {
 	return client.WithCodec(codec)
 }
 
+// WithAdmissionController is used to set the admission controller of the batch commands requests.
+func WithAdmissionController(controller AdmissionController) ClientOpt {
+	return client.WithAdmissionController(controller)
+}
+
+// WithUnaryInterceptors appends the unary interceptors to the connections to TiKV, e.g. to inject auth tokens or
+// sign the requests.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOpt {
+	return client.WithUnaryInterceptors(interceptors...)
+}
+
+// WithStreamInterceptors appends the stream interceptors to the connections to TiKV.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) ClientOpt {
+	return client.WithStreamInterceptors(interceptors...)
+}
+
+// WithCredentialProvider is used to attach the credentials of the provider to every request to TiKV as the
+// "authorization" gRPC metadata. The credentials are refreshed before they expire, and only sent over TLS.
+func WithCredentialProvider(provider CredentialProvider) ClientOpt {
+	return client.WithCredentialProvider(provider)
+}
+
+// WithInsecureCredentialProvider is like WithCredentialProvider, but also sends the credentials over the insecure
+// connections, e.g. when the TLS is terminated by the authentication proxies in front of TiKV.
+func WithInsecureCredentialProvider(provider CredentialProvider) ClientOpt {
+	return client.WithInsecureCredentialProvider(provider)
+}
+
+// DefaultTimeout returns the timeout of the requests of the type when the caller doesn't specify one. It can be
+// overridden by config.TiKVClient.RequestTimeouts.
+func DefaultTimeout(cmd tikvrpc.CmdType) time.Duration {
+	return client.DefaultTimeout(cmd)
+}
+
 // Timeout durations.
 const (
 	ReadTimeoutMedium     = client.ReadTimeoutMedium
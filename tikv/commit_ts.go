@@ -0,0 +1,125 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+)
+
+// CommitTSStore persists the latest commit ts observed by a CommitTSCheckpoint, e.g. in a file or a database.
+type CommitTSStore interface {
+	// LoadCommitTS returns the persisted commit ts, or 0 if nothing is persisted.
+	LoadCommitTS(ctx context.Context) (uint64, error)
+	// SaveCommitTS persists the commit ts, which is always greater than the persisted one.
+	SaveCommitTS(ctx context.Context, ts uint64) error
+}
+
+// CommitTSCheckpoint tracks the latest commit ts observed by the client and persists it, so a stateless service can
+// read its own writes after restarting by reading from a snapshot returned by NewSnapshotAtLeast with the persisted
+// ts. The writes are only guaranteed to be visible after restarting if the checkpoint is flushed after they commit.
+type CommitTSCheckpoint struct {
+	store   *KVStore
+	persist CommitTSStore
+
+	observed atomic.Uint64
+
+	mu        sync.Mutex
+	persisted uint64
+}
+
+// NewCommitTSCheckpoint creates a CommitTSCheckpoint persisting the commit ts to persist.
+func NewCommitTSCheckpoint(store *KVStore, persist CommitTSStore) *CommitTSCheckpoint {
+	return &CommitTSCheckpoint{store: store, persist: persist}
+}
+
+// Load loads the persisted commit ts, which is usually called at startup. The loaded ts is also observed.
+func (c *CommitTSCheckpoint) Load(ctx context.Context) (uint64, error) {
+	ts, err := c.persist.LoadCommitTS(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	if ts > c.persisted {
+		c.persisted = ts
+	}
+	c.mu.Unlock()
+	c.Observe(ts)
+	return ts, nil
+}
+
+// Observe records a commit ts. It's not persisted until Flush is called.
+func (c *CommitTSCheckpoint) Observe(commitTS uint64) {
+	for {
+		observed := c.observed.Load()
+		if commitTS <= observed || c.observed.CompareAndSwap(observed, commitTS) {
+			return
+		}
+	}
+}
+
+// ObserveTxn records the commit ts of a committed transaction. Read-only and uncommitted transactions are ignored.
+func (c *CommitTSCheckpoint) ObserveTxn(txn *transaction.KVTxn) {
+	if txn.CommitTS() > 0 {
+		c.Observe(txn.CommitTS())
+	}
+}
+
+// Observed returns the latest commit ts observed.
+func (c *CommitTSCheckpoint) Observed() uint64 {
+	return c.observed.Load()
+}
+
+// Flush persists the latest commit ts observed if it's not persisted yet.
+func (c *CommitTSCheckpoint) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts := c.observed.Load()
+	if ts <= c.persisted {
+		return nil
+	}
+	if err := c.persist.SaveCommitTS(ctx, ts); err != nil {
+		return err
+	}
+	c.persisted = ts
+	return nil
+}
+
+// NewSnapshotAtLeast gets a snapshot at the latest ts which is greater than minTS, so it can read all the data
+// committed at or before minTS, e.g. a commit ts persisted by CommitTSCheckpoint. If the ts allocated by PD is not
+// greater than minTS, it's retried until it is, with the backoff bounded by ctx.
+func (s *KVStore) NewSnapshotAtLeast(ctx context.Context, minTS uint64) (*txnsnapshot.KVSnapshot, error) {
+	bo := retry.NewBackofferWithVars(ctx, transaction.TsoMaxBackoff, nil)
+	for {
+		ts, err := s.getTimestampWithRetry(bo, oracle.GlobalTxnScope)
+		if err != nil {
+			return nil, err
+		}
+		if ts > minTS {
+			return s.GetSnapshot(ts), nil
+		}
+		err = errors.Errorf("the allocated ts %d is not greater than the min ts %d", ts, minTS)
+		if err = bo.Backoff(retry.BoPDRPC, err); err != nil {
+			return nil, err
+		}
+	}
+}
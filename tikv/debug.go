@@ -0,0 +1,82 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+// DumpDebugInfo writes a human-readable snapshot of the internal state of the store to w for diagnosis, including
+// the batch commands queues, the gRPC connections, the backoff stats of the process, the region cache summary and the
+// number of in-flight commits. The format is not stable and shouldn't be parsed.
+func (s *KVStore) DumpDebugInfo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "cluster: %d, keyspace: %d\n", s.clusterID, s.getCodec().GetKeyspaceID())
+
+	fmt.Fprintln(bw, "\n[batch queues]")
+	for _, stat := range s.GetBatchQueueStats() {
+		fmt.Fprintf(bw, "%s pending=%d inflight=%d max_pending=%d max_inflight=%d conns=%d max_batch_size=%d\n",
+			stat.Target, stat.Pending, stat.Inflight, stat.MaxPending, stat.MaxInflight, stat.Conns, stat.MaxBatchSize)
+	}
+
+	fmt.Fprintln(bw, "\n[connections]")
+	for _, stat := range s.GetConnStats() {
+		fmt.Fprintf(bw, "%s state=%s connects=%d disconnects=%d state_changes=%d goaways=%d",
+			stat.Name, stat.State, stat.Connects, stat.Disconnects, stat.StateChanges, stat.GoAways)
+		if stat.LastError != "" {
+			fmt.Fprintf(bw, " last_error=%q last_error_time=%s", stat.LastError, stat.LastErrorTime.Format(time.RFC3339))
+		}
+		fmt.Fprintln(bw)
+	}
+
+	mem := GetMemoryUsage()
+	fmt.Fprintln(bw, "\n[memory]")
+	fmt.Fprintf(bw, "total=%d limit=%d txn_buffer=%d scan_buffer=%d batch_queue=%d\n",
+		mem.Total(), mem.Limit, mem.TxnBuffer, mem.ScanBuffer, mem.BatchQueue)
+
+	fmt.Fprintln(bw, "\n[backoff]")
+	backoffStats := retry.BackoffStats()
+	names := make([]string, 0, len(backoffStats))
+	for name := range backoffStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stat := backoffStats[name]
+		fmt.Fprintf(bw, "%s times=%d sleep_ms=%d\n", name, stat.Times, stat.SleepMS)
+	}
+
+	fmt.Fprintln(bw, "\n[region cache]")
+	summary := s.regionCache.Summary()
+	fmt.Fprintf(bw, "regions=%d valid=%d\n", summary.Regions, summary.ValidRegions)
+	for _, store := range summary.Stores {
+		fmt.Fprintf(bw, "store %d addr=%s type=%s state=%s liveness=%s\n",
+			store.ID, store.Addr, store.Type, store.ResolveState, store.Liveness)
+	}
+
+	fmt.Fprintln(bw, "\n[transactions]")
+	committing, background := transaction.InflightCommits()
+	fmt.Fprintf(bw, "committing=%d background_secondaries=%d\n", committing, background)
+
+	return bw.Flush()
+}
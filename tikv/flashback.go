@@ -0,0 +1,250 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/txnkv/rangetask"
+	"go.uber.org/zap"
+)
+
+// FlashbackStage is the stage of a flashback.
+type FlashbackStage string
+
+const (
+	// FlashbackStagePrepare is the stage that regions are locked for the flashback.
+	FlashbackStagePrepare FlashbackStage = "prepare"
+	// FlashbackStageFlashback is the stage that regions are flashed back to the version.
+	FlashbackStageFlashback FlashbackStage = "flashback"
+)
+
+// FlashbackProgress is the progress of a flashback stage.
+type FlashbackProgress struct {
+	Stage FlashbackStage
+	// CompletedRegions is how many regions have finished the stage so far.
+	CompletedRegions int
+}
+
+type flashbackOption struct {
+	concurrency int
+	onProgress  func(FlashbackProgress)
+}
+
+// FlashbackOpt is the option of the flashback.
+type FlashbackOpt func(*flashbackOption)
+
+// WithFlashbackConcurrency sets how many ranges are processed concurrently.
+func WithFlashbackConcurrency(concurrency int) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.concurrency = concurrency
+	}
+}
+
+// WithFlashbackProgress sets the callback which is called each time a region finishes the stage. The calls are
+// serialized, so the callback doesn't need to be thread-safe.
+func WithFlashbackProgress(onProgress func(FlashbackProgress)) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.onProgress = onProgress
+	}
+}
+
+const flashbackMaxBackoff = 300000
+
+// PrepareFlashbackToVersion prepares all regions in [startKey, endKey) for flashing back to `version`. The regions are
+// locked with `startTS`, which stops the resolved ts from advancing and rejects other requests until the flashback
+// is done. Empty keys mean the range is unbounded.
+//
+// It fails if `version` is older than the txn safe point, as the data at `version` may have been garbage collected.
+func (s *KVStore) PrepareFlashbackToVersion(ctx context.Context, startKey, endKey []byte, version, startTS uint64, opts ...FlashbackOpt) error {
+	if err := s.checkFlashback(ctx, version, startTS); err != nil {
+		return err
+	}
+	build := func(start, end []byte) *tikvrpc.Request {
+		return tikvrpc.NewRequest(tikvrpc.CmdPrepareFlashbackToVersion, &kvrpcpb.PrepareFlashbackToVersionRequest{
+			StartKey: start,
+			EndKey:   end,
+			StartTs:  startTS,
+			Version:  version,
+		})
+	}
+	return s.runFlashbackStage(ctx, FlashbackStagePrepare, startKey, endKey, startTS, build, opts)
+}
+
+// FlashbackToVersion flashes back all regions in [startKey, endKey) to `version` by writing new MVCC versions with
+// `startTS` and `commitTS`. The regions must have been prepared by PrepareFlashbackToVersion with the same `startTS`.
+// Empty keys mean the range is unbounded.
+func (s *KVStore) FlashbackToVersion(ctx context.Context, startKey, endKey []byte, version, startTS, commitTS uint64, opts ...FlashbackOpt) error {
+	if commitTS <= startTS {
+		return errors.Errorf("[flashback] commit ts %d must be greater than start ts %d", commitTS, startTS)
+	}
+	if err := s.checkFlashback(ctx, version, startTS); err != nil {
+		return err
+	}
+	build := func(start, end []byte) *tikvrpc.Request {
+		return tikvrpc.NewRequest(tikvrpc.CmdFlashbackToVersion, &kvrpcpb.FlashbackToVersionRequest{
+			Version:  version,
+			StartKey: start,
+			EndKey:   end,
+			StartTs:  startTS,
+			CommitTs: commitTS,
+		})
+	}
+	return s.runFlashbackStage(ctx, FlashbackStageFlashback, startKey, endKey, startTS, build, opts)
+}
+
+// checkFlashback checks that the flashback is supported by the cluster and `version` is still readable.
+func (s *KVStore) checkFlashback(ctx context.Context, version, startTS uint64) error {
+	if version >= startTS {
+		return errors.Errorf("[flashback] version %d must be less than start ts %d", version, startTS)
+	}
+	if !s.regionCache.SupportsFeature(ctx, locate.FeatureFlashback) {
+		return errors.New("[flashback] not supported by all TiKV stores")
+	}
+	gcState, err := s.GetGCState(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if version < gcState.TxnSafePoint {
+		return errors.Errorf("[flashback] version %d is older than the txn safe point %d", version, gcState.TxnSafePoint)
+	}
+	return nil
+}
+
+func (s *KVStore) runFlashbackStage(
+	ctx context.Context,
+	stage FlashbackStage,
+	startKey, endKey []byte,
+	startTS uint64,
+	build func(start, end []byte) *tikvrpc.Request,
+	opts []FlashbackOpt,
+) error {
+	// default concurrency 8
+	opt := &flashbackOption{concurrency: 8}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	var (
+		mu        sync.Mutex
+		completed int
+	)
+	handler := func(ctx context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+		return s.sendFlashbackOnRange(ctx, r, build, func() {
+			if opt.onProgress == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			completed++
+			opt.onProgress(FlashbackProgress{Stage: stage, CompletedRegions: completed})
+		})
+	}
+	runner := rangetask.NewRangeTaskRunnerWithID(
+		"flashback-"+string(stage),
+		fmt.Sprintf("flashback-%s-%d", stage, startTS),
+		s,
+		opt.concurrency,
+		handler,
+	)
+	if err := runner.RunOnRange(ctx, startKey, endKey); err != nil {
+		logutil.Logger(ctx).Warn("flashback failed",
+			zap.String("stage", string(stage)),
+			zap.Uint64("startTS", startTS),
+			zap.Int("completedRegions", runner.CompletedRegions()),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// sendFlashbackOnRange sends the flashback requests to each region in the range.
+func (s *KVStore) sendFlashbackOnRange(
+	ctx context.Context,
+	r kv.KeyRange,
+	build func(start, end []byte) *tikvrpc.Request,
+	onRegionDone func(),
+) (rangetask.TaskStat, error) {
+	startKey, rangeEndKey := r.StartKey, r.EndKey
+	var stat rangetask.TaskStat
+	bo := NewBackofferWithVars(ctx, flashbackMaxBackoff, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return stat, errors.WithStack(ctx.Err())
+		default:
+		}
+		if len(rangeEndKey) > 0 && bytes.Compare(startKey, rangeEndKey) >= 0 {
+			break
+		}
+
+		loc, err := s.regionCache.LocateKey(bo, startKey)
+		if err != nil {
+			return stat, err
+		}
+		endKey := loc.EndKey
+		isLast := len(endKey) == 0 || (len(rangeEndKey) > 0 && bytes.Compare(endKey, rangeEndKey) >= 0)
+		if isLast {
+			endKey = rangeEndKey
+		}
+
+		req := build(startKey, endKey)
+		resp, err := s.SendReq(bo, req, loc.Region, DefaultTimeout(req.Type))
+		if err != nil {
+			return stat, err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return stat, err
+		}
+		if regionErr != nil {
+			err = bo.Backoff(BoRegionMiss(), errors.New(regionErr.String()))
+			if err != nil {
+				return stat, err
+			}
+			continue
+		}
+		if resp.Resp == nil {
+			return stat, errors.WithStack(tikverr.ErrBodyMissing)
+		}
+		var errStr string
+		switch flashbackResp := resp.Resp.(type) {
+		case *kvrpcpb.PrepareFlashbackToVersionResponse:
+			errStr = flashbackResp.GetError()
+		case *kvrpcpb.FlashbackToVersionResponse:
+			errStr = flashbackResp.GetError()
+		}
+		if errStr != "" {
+			return stat, errors.Errorf("[flashback] region %d failed: %s", loc.Region.GetID(), errStr)
+		}
+		stat.CompletedRegions++
+		onRegionDone()
+		if isLast {
+			break
+		}
+		startKey = endKey
+	}
+	return stat, nil
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/txnkv/rangetask"
 	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"github.com/tikv/pd/client/clients/gc"
 	"github.com/tikv/pd/client/constants"
 	zap "go.uber.org/zap"
 )
@@ -94,6 +95,42 @@ func (s *KVStore) GC(ctx context.Context, expectedSafePoint uint64, opts ...GCOp
 	return s.pdClient.UpdateGCSafePoint(ctx, gcSafePoint)
 }
 
+// GCBarrierInfo is the information of a GC barrier, which blocks the txn safe point of the keyspace from
+// advancing beyond its ts.
+type GCBarrierInfo = gc.GCBarrierInfo
+
+// GCState is the GC state of a keyspace, including its txn safe point, GC safe point and GC barriers.
+type GCState = gc.GCState
+
+// GCBarrierTTLNeverExpire is the TTL of a GC barrier that never expires.
+const GCBarrierTTLNeverExpire = gc.TTLNeverExpire
+
+// SetGCBarrier creates or updates a GC barrier of the keyspace the store belongs to, so the snapshots at and after
+// `barrierTS` are protected from GC until the barrier is deleted or expires after `ttl`. It fails if `barrierTS`
+// is already behind the txn safe point.
+func (s *KVStore) SetGCBarrier(ctx context.Context, barrierID string, barrierTS uint64, ttl time.Duration) (*GCBarrierInfo, error) {
+	if barrierID == "" || barrierTS == 0 || ttl <= 0 {
+		return nil, errors.Errorf("invalid GC barrier, id: %q, ts: %d, ttl: %v", barrierID, barrierTS, ttl)
+	}
+	return s.gcStatesClient().SetGCBarrier(ctx, barrierID, barrierTS, ttl)
+}
+
+// DeleteGCBarrier deletes a GC barrier of the keyspace the store belongs to. It returns the deleted barrier, or nil
+// if the barrier doesn't exist.
+func (s *KVStore) DeleteGCBarrier(ctx context.Context, barrierID string) (*GCBarrierInfo, error) {
+	return s.gcStatesClient().DeleteGCBarrier(ctx, barrierID)
+}
+
+// GetGCState gets the GC state of the keyspace the store belongs to. The txn safe point in it is the effective safe
+// point of the keyspace, snapshots before which can't be read any more.
+func (s *KVStore) GetGCState(ctx context.Context) (GCState, error) {
+	return s.gcStatesClient().GetGCState(ctx)
+}
+
+func (s *KVStore) gcStatesClient() gc.GCStatesClient {
+	return s.pdClient.GetGCStatesClient(uint32(s.getCodec().GetKeyspaceID()))
+}
+
 type gcOption struct {
 	concurrency int
 }
@@ -259,7 +296,8 @@ func scanLocksInOneRegionWithStartKey(bo *retry.Backoffer, store Storage, startK
 			StartKey:   startKey,
 			EndKey:     loc.EndKey,
 		})
-		resp, err := store.SendReq(bo, req, loc.Region, ReadTimeoutMedium)
+		req.TrafficClass = tikvrpc.TrafficClassGC
+		resp, err := store.SendReq(bo, req, loc.Region, DefaultTimeout(req.Type))
 		if err != nil {
 			return nil, loc, err
 		}
@@ -343,6 +381,7 @@ func (s *KVStore) UnsafeDestroyRange(ctx context.Context, startKey []byte, endKe
 		StartKey: startKey,
 		EndKey:   endKey,
 	})
+	req.TrafficClass = tikvrpc.TrafficClassGC
 
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(stores))
@@ -0,0 +1,155 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// healthCheckMaxBackoff is the max backoff of locating a region in the health check.
+const healthCheckMaxBackoff = 2000
+
+// HealthCheckResult is the result of one item of the health check.
+type HealthCheckResult struct {
+	// Latency is how long the check takes.
+	Latency time.Duration
+	// Err is nil if the check passes.
+	Err error
+}
+
+// StoreHealthCheckResult is the result of checking a store.
+type StoreHealthCheckResult struct {
+	HealthCheckResult
+	StoreID uint64
+	Addr    string
+}
+
+// HealthReport is the report of KVStore.HealthCheck.
+type HealthReport struct {
+	// PD is the result of loading stores from PD.
+	PD HealthCheckResult
+	// TSO is the result of getting a timestamp.
+	TSO HealthCheckResult
+	// Stores are the results of sending an RPC to each TiKV store that is up.
+	Stores []StoreHealthCheckResult
+	// RegionCache is the result of locating the first region and its leader.
+	RegionCache HealthCheckResult
+}
+
+// Healthy returns whether all checks in the report pass.
+func (r *HealthReport) Healthy() bool {
+	if r.PD.Err != nil || r.TSO.Err != nil || r.RegionCache.Err != nil {
+		return false
+	}
+	for _, store := range r.Stores {
+		if store.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthCheck checks whether the store is able to serve requests, which is suitable for readiness probes. It checks
+// PD, TSO, each TiKV store that is up and the region cache, and reports the result of every check. Use ctx to bound
+// how long it takes.
+func (s *KVStore) HealthCheck(ctx context.Context) *HealthReport {
+	report := &HealthReport{}
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		start := time.Now()
+		_, err := s.oracle.GetTimestamp(ctx, &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+		report.TSO = HealthCheckResult{Latency: time.Since(start), Err: err}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		start := time.Now()
+		err := s.checkRegionCache(ctx)
+		report.RegionCache = HealthCheckResult{Latency: time.Since(start), Err: err}
+	}()
+
+	start := time.Now()
+	stores, err := s.pdClient.GetAllStores(ctx)
+	report.PD = HealthCheckResult{Latency: time.Since(start), Err: errors.WithStack(err)}
+	for _, store := range stores {
+		if store.GetState() != metapb.StoreState_Up || tikvrpc.GetStoreTypeByMeta(store) != tikvrpc.TiKV {
+			continue
+		}
+		report.Stores = append(report.Stores, StoreHealthCheckResult{StoreID: store.GetId(), Addr: store.GetAddress()})
+	}
+	for i := range report.Stores {
+		wg.Add(1)
+		go func(result *StoreHealthCheckResult) {
+			defer wg.Done()
+			start := time.Now()
+			err := s.checkStore(ctx, result.Addr)
+			result.HealthCheckResult = HealthCheckResult{Latency: time.Since(start), Err: err}
+		}(&report.Stores[i])
+	}
+
+	wg.Wait()
+	return report
+}
+
+// checkStore sends a lightweight request to the store.
+func (s *KVStore) checkStore(ctx context.Context, addr string) error {
+	req := tikvrpc.NewRequest(tikvrpc.CmdStoreSafeTS, &kvrpcpb.StoreSafeTSRequest{
+		KeyRange: &kvrpcpb.KeyRange{StartKey: []byte(""), EndKey: []byte("")},
+	})
+	req.TrafficClass = tikvrpc.TrafficClassSafeTS
+	resp, err := s.GetTiKVClient().SendRequest(ctx, addr, req, DefaultTimeout(req.Type))
+	if err != nil {
+		return err
+	}
+	if resp.Resp == nil {
+		return errors.New("empty response")
+	}
+	return nil
+}
+
+// checkRegionCache checks the first region and its leader can be located.
+func (s *KVStore) checkRegionCache(ctx context.Context) error {
+	bo := retry.NewBackofferWithVars(ctx, healthCheckMaxBackoff, nil)
+	for {
+		loc, err := s.regionCache.LocateKey(bo, nil)
+		if err != nil {
+			return err
+		}
+		rpcCtx, err := s.regionCache.GetTiKVRPCContext(bo, loc.Region, kv.ReplicaReadLeader, 0)
+		if err != nil {
+			return err
+		}
+		if rpcCtx != nil {
+			return nil
+		}
+		if err = bo.Backoff(retry.BoRegionMiss, errors.Errorf("region %d is stale", loc.Region.GetID())); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyspaceListBatchSize is the number of keyspaces loaded from PD in a single request by ListKeyspaces.
+	keyspaceListBatchSize = 256
+	// keyspacesPrefix is the PD HTTP API path of keyspaces.
+	keyspacesPrefix = "/pd/api/v2/keyspaces"
+)
+
+// ListKeyspaces returns the meta of all keyspaces in the cluster, ordered by keyspace ID.
+func ListKeyspaces(ctx context.Context, pdClient pd.Client) ([]*keyspacepb.KeyspaceMeta, error) {
+	var (
+		keyspaces []*keyspacepb.KeyspaceMeta
+		startID   uint32
+	)
+	for {
+		batch, err := pdClient.GetAllKeyspaces(ctx, startID, keyspaceListBatchSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		keyspaces = append(keyspaces, batch...)
+		if len(batch) < keyspaceListBatchSize {
+			return keyspaces, nil
+		}
+		startID = batch[len(batch)-1].GetId() + 1
+		if startID == 0 {
+			// The last keyspace ID wraps around.
+			return keyspaces, nil
+		}
+	}
+}
+
+// DescribeKeyspace returns the meta of the keyspace with the given name. Unlike NewCodecPDClientWithKeyspace, it
+// doesn't require the keyspace to be enabled.
+func DescribeKeyspace(ctx context.Context, pdClient pd.Client, name string) (*keyspacepb.KeyspaceMeta, error) {
+	meta, err := pdClient.LoadKeyspace(ctx, apicodec.BuildKeyspaceName(name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return meta, nil
+}
+
+// CreateKeyspace creates a keyspace with the given name and config through the HTTP API of the PD leader, and returns
+// the meta of the created keyspace. The gRPC client of PD doesn't support creating keyspaces, so the TLS config is
+// taken from the global config, the same as NewPDClient.
+func CreateKeyspace(ctx context.Context, pdClient pd.Client, name string, cfg map[string]string) (*keyspacepb.KeyspaceMeta, error) {
+	leaderURL := pdClient.GetLeaderURL()
+	if leaderURL == "" {
+		return nil, errors.New("PD leader is unknown")
+	}
+	httpClient := http.DefaultClient
+	if strings.HasPrefix(leaderURL, "https://") {
+		tlsConfig, err := config.GetGlobalConfig().Security.ToTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+	body, err := json.Marshal(struct {
+		Name   string            `json:"name"`
+		Config map[string]string `json:"config,omitempty"`
+	}{Name: name, Config: cfg})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(leaderURL, "/")+keyspacesPrefix, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("failed to create keyspace %s, status: %s, message: %s", name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	// The response encodes the keyspace state as a string, so load the meta with the gRPC client instead.
+	return DescribeKeyspace(ctx, pdClient, name)
+}
+
+// KeyspaceRanges are the key ranges of a keyspace encoded by API v2.
+type KeyspaceRanges struct {
+	// Raw is the range of the RawKV data of the keyspace.
+	Raw kv.KeyRange
+	// Txn is the range of the transactional data of the keyspace, including the table data and indexes of TiDB.
+	Txn kv.KeyRange
+}
+
+// GetKeyspaceRanges returns the raw and txn ranges of the keyspace encoded by API v2.
+func GetKeyspaceRanges(keyspaceID uint32) (KeyspaceRanges, error) {
+	var ranges KeyspaceRanges
+	for _, r := range []struct {
+		mode Mode
+		dst  *kv.KeyRange
+	}{{ModeRaw, &ranges.Raw}, {ModeTxn, &ranges.Txn}} {
+		codec, err := apicodec.NewCodecV2(r.mode, &keyspacepb.KeyspaceMeta{Id: keyspaceID})
+		if err != nil {
+			return KeyspaceRanges{}, err
+		}
+		r.dst.StartKey, r.dst.EndKey = codec.EncodeRange(nil, nil)
+	}
+	return ranges, nil
+}
+
+// The operations of the steps deleting the data of a keyspace.
+const (
+	// KeyspaceDeleteRange deletes the range by DeleteRange, which goes through Raft.
+	KeyspaceDeleteRange = "delete-range"
+	// KeyspaceUnsafeDestroyRange destroys the range by UnsafeDestroyRange on every store to free the disk space.
+	KeyspaceUnsafeDestroyRange = "unsafe-destroy-range"
+)
+
+// KeyspaceDeletionStep is a step of DeleteKeyspaceData.
+type KeyspaceDeletionStep struct {
+	KeyspaceID uint32
+	// Op is KeyspaceDeleteRange or KeyspaceUnsafeDestroyRange.
+	Op string
+	// Mode tells whether the range is the raw or txn range of the keyspace.
+	Mode Mode
+	// Range is the range to delete, encoded by API v2.
+	Range kv.KeyRange
+}
+
+// DeleteKeyspaceOptions are the options of DeleteKeyspaceData.
+type DeleteKeyspaceOptions struct {
+	// Concurrency is the concurrency of DeleteRange, 1 if it's not positive.
+	Concurrency int
+	// UnsafeDestroy destroys the txn and raw ranges by UnsafeDestroyRange after deleting the txn range. The keyspace
+	// must never be accessed again after it.
+	UnsafeDestroy bool
+	// Confirm is called before each step, and the deletion stops with the error it returns. It's required, e.g. to
+	// check the keyspace being deleted is disabled in PD.
+	Confirm func(ctx context.Context, step KeyspaceDeletionStep) error
+}
+
+// DeleteKeyspaceData deletes the data of the keyspace the store is bound to, e.g. when the tenant of the keyspace is
+// offboarded. The txn range is deleted by DeleteRange, then the txn and raw ranges are destroyed by
+// UnsafeDestroyRange if opts.UnsafeDestroy is set. The raw range can only be destroyed, as the regions of it are out
+// of the region cache of the store. It returns an error if the store isn't bound to a keyspace of API v2.
+func (s *KVStore) DeleteKeyspaceData(ctx context.Context, opts DeleteKeyspaceOptions) error {
+	codec := s.getCodec()
+	if codec.GetAPIVersion() != kvrpcpb.APIVersion_V2 || codec.GetKeyspaceMeta() == nil {
+		return errors.New("the data of a keyspace can only be deleted by a store bound to the keyspace of API v2")
+	}
+	if opts.Confirm == nil {
+		return errors.New("the steps deleting the data of a keyspace must be confirmed")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	meta := codec.GetKeyspaceMeta()
+	ranges, err := GetKeyspaceRanges(meta.GetId())
+	if err != nil {
+		return err
+	}
+	steps := []KeyspaceDeletionStep{{KeyspaceID: meta.GetId(), Op: KeyspaceDeleteRange, Mode: ModeTxn, Range: ranges.Txn}}
+	if opts.UnsafeDestroy {
+		steps = append(steps,
+			KeyspaceDeletionStep{KeyspaceID: meta.GetId(), Op: KeyspaceUnsafeDestroyRange, Mode: ModeTxn, Range: ranges.Txn},
+			KeyspaceDeletionStep{KeyspaceID: meta.GetId(), Op: KeyspaceUnsafeDestroyRange, Mode: ModeRaw, Range: ranges.Raw})
+	}
+	for _, step := range steps {
+		if err := opts.Confirm(ctx, step); err != nil {
+			return err
+		}
+		logutil.Logger(ctx).Info("delete keyspace data",
+			zap.String("keyspace", meta.GetName()),
+			zap.Uint32("keyspace-id", step.KeyspaceID),
+			zap.String("op", step.Op),
+			zap.Bool("raw", step.Mode == ModeRaw))
+		switch {
+		case step.Op == KeyspaceDeleteRange:
+			// The range is encoded from the empty range by the codec of the store.
+			_, err = s.DeleteRange(ctx, nil, nil, opts.Concurrency)
+		case step.Mode == ModeRaw:
+			var rawCodec Codec
+			rawCodec, err = apicodec.NewCodecV2(ModeRaw, meta)
+			if err == nil {
+				err = s.UnsafeDestroyRange(client.WithRequestCodec(ctx, rawCodec), nil, nil)
+			}
+		default:
+			err = s.UnsafeDestroyRange(ctx, nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
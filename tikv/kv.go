@@ -40,6 +40,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -74,6 +75,7 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 	atomicutil "go.uber.org/atomic"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 )
@@ -125,11 +127,20 @@ type KVStore struct {
 		sync.RWMutex
 		client Client
 	}
+	// recording is the recording of the requests started by StartRecording.
+	recording struct {
+		sync.Mutex
+		recorder *client.Recorder
+		file     *os.File
+		timer    *time.Timer
+	}
 	pdClient     pd.Client
 	pdHttpClient pdhttp.Client
 	regionCache  *locate.RegionCache
 	lockResolver *txnlock.LockResolver
 	txnLatches   *latch.LatchesScheduler
+	// lockCleanupScheduler cleans up the locks of failed transactions in background if it's not nil.
+	lockCleanupScheduler *transaction.LockCleanupScheduler
 
 	mock bool
 
@@ -151,6 +162,23 @@ type KVStore struct {
 
 	replicaReadSeed uint32 // this is used to load balance followers / learners when replica read is enabled
 
+	// pessimisticRetryStrategy is the default strategy of the transactions begun by the store.
+	pessimisticRetryStrategy transaction.PessimisticRetryStrategy
+	// resourceGroupName is the default resource group of the transactions and snapshots of the store.
+	resourceGroupName string
+	// expectedClusterID is the cluster ID set by WithExpectedClusterID, 0 means any.
+	expectedClusterID uint64
+	// valueTransformers transforms the values of the transactions and snapshots of the store.
+	valueTransformers *kv.ValueTransformers
+
+	// logger is the logger set by WithLogger, nil means the global logger.
+	logger *zap.Logger
+	// logLevel is the level set by SetLogLevel.
+	logLevel zap.AtomicLevel
+
+	loadAlerter   storeLoadAlerter
+	statsReporter storeStatsReporter
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -223,6 +251,67 @@ func WithUpdateInterval(updateInterval time.Duration) Option {
 	}
 }
 
+// WithDefaultPessimisticRetryStrategy sets the strategy that shapes the retries of blocked
+// pessimistic lock requests for all transactions begun by the store.
+func WithDefaultPessimisticRetryStrategy(strategy transaction.PessimisticRetryStrategy) Option {
+	return func(o *KVStore) {
+		o.pessimisticRetryStrategy = strategy
+	}
+}
+
+// WithDefaultResourceGroup sets the resource group of the transactions and snapshots of the store, unless it's
+// overridden by SetResourceGroupName of them or per request by util.WithResourceGroupName.
+func WithDefaultResourceGroup(name string) Option {
+	return func(o *KVStore) {
+		o.resourceGroupName = name
+	}
+}
+
+// WithValueTransformers makes the transactions and snapshots of the store transform the values written and restore the
+// values read with the transformers, e.g. to encrypt the values on the client side, see kv.ValueTransformers.
+func WithValueTransformers(t *kv.ValueTransformers) Option {
+	return func(o *KVStore) {
+		o.valueTransformers = t
+	}
+}
+
+// WithClientMetadata injects the identity of the application instance into the context of every request sent by the
+// store, so the slow logs and the dashboards of TiKV can attribute the requests to it. The request source is prefixed
+// by the app name, and the session alias of the source statement is set to "app/instance/version" if it's empty.
+func WithClientMetadata(md ClientMetadata) Option {
+	return func(o *KVStore) {
+		o.clientMu.client = client.NewMetadataClient(o.clientMu.client, md)
+	}
+}
+
+// WithStoreFallbackAddrs sets the function returning the fallback addresses of a store by its ID and the address
+// advertised in PD. When the client fails to dial or handshake with a store, the store is accessed by the next one of
+// its advertised address, its peer address if it's a TiKV store, and the fallback addresses. Without it, the stores
+// are only accessed by their advertised addresses.
+func WithStoreFallbackAddrs(f func(storeID uint64, addr string) []string) Option {
+	return func(o *KVStore) {
+		o.regionCache.SetStoreFallbackAddrs(f)
+	}
+}
+
+// WithExpectedClusterID makes NewKVStore fail with ErrClusterIDMismatch if the cluster ID of PD isn't id, e.g. when the
+// store is pointed to the PD of another environment by mistake.
+func WithExpectedClusterID(id uint64) Option {
+	return func(o *KVStore) {
+		o.expectedClusterID = id
+	}
+}
+
+// WithLockCleanupScheduler makes the store clean up the locks left by failed transactions with a
+// background scheduler, which queues at most capacity cleanup tasks, runs them with the given
+// number of workers and retries each failed task at most maxRetry times. If the queue is full,
+// the cleanup is run in a new goroutine without retry as if the scheduler is not enabled.
+func WithLockCleanupScheduler(capacity, workers, maxRetry int) Option {
+	return func(o *KVStore) {
+		o.lockCleanupScheduler = transaction.NewLockCleanupScheduler(capacity, workers, maxRetry)
+	}
+}
+
 // WithPDHTTPClient sets the PD HTTP client with the given PD addresses and options.
 // Source is to mark where the HTTP client is created, which is used for metrics and logs.
 func WithPDHTTPClient(
@@ -302,6 +391,7 @@ func NewKVStore(uuid string, pdClient pd.Client, spkv SafePointKV, tikvclient Cl
 		regionCache:     regionCache,
 		kv:              spkv,
 		replicaReadSeed: rand.Uint32(),
+		logLevel:        zap.NewAtomicLevelAt(zapcore.DebugLevel),
 		ctx:             ctx,
 		cancel:          cancel,
 		gP:              NewSpool(128, 10*time.Second),
@@ -318,6 +408,22 @@ func NewKVStore(uuid string, pdClient pd.Client, spkv SafePointKV, tikvclient Cl
 
 	store.lockResolver = txnlock.NewLockResolver(store)
 	loadOption(store, opt...)
+	if store.expectedClusterID != 0 && store.expectedClusterID != store.clusterID {
+		metrics.TiKVClusterIDMismatchCounter.WithLabelValues("pd").Inc()
+		// The store isn't started yet, only the parts started by NewKVStore and the options are closed.
+		store.cancel()
+		if store.lockCleanupScheduler != nil {
+			store.lockCleanupScheduler.Close()
+		}
+		if store.pdHttpClient != nil {
+			store.pdHttpClient.Close()
+		}
+		store.oracle.Close()
+		store.lockResolver.Close()
+		store.regionCache.Close()
+		store.gP.Close()
+		return nil, errors.WithStack(&tikverr.ErrClusterIDMismatch{Source: "pd", Expected: store.expectedClusterID, Actual: store.clusterID})
+	}
 
 	store.wg.Add(2)
 	go store.runTxnSafePointUpdater()
@@ -379,7 +485,7 @@ func (s *KVStore) runTxnSafePointUpdater() {
 				d = pollTxnSafePointInterval
 			} else {
 				metrics.TiKVLoadSafepointCounter.WithLabelValues("fail").Inc()
-				logutil.BgLogger().Error("fail to load txn safe point from pd", zap.Error(err))
+				s.Logger().Error("fail to load txn safe point from pd", zap.Error(err))
 				d = pollTxnSafePointQuickRepeatInterval
 			}
 		case <-s.ctx.Done():
@@ -403,6 +509,9 @@ func (s *KVStore) Begin(opts ...TxnOption) (txn *transaction.KVTxn, err error) {
 	if options.TxnScope == "" {
 		options.TxnScope = oracle.GlobalTxnScope
 	}
+	if options.PessimisticRetryStrategy == nil {
+		options.PessimisticRetryStrategy = s.pessimisticRetryStrategy
+	}
 	var (
 		startTS uint64
 	)
@@ -417,7 +526,15 @@ func (s *KVStore) Begin(opts ...TxnOption) (txn *transaction.KVTxn, err error) {
 	}
 
 	snapshot := txnsnapshot.NewTiKVSnapshot(s, startTS, s.nextReplicaReadSeed())
-	return transaction.NewTiKVTxn(s, snapshot, startTS, options)
+	txn, err = transaction.NewTiKVTxn(s, snapshot, startTS, options)
+	if err != nil {
+		return nil, err
+	}
+	if s.resourceGroupName != "" {
+		txn.SetResourceGroupName(s.resourceGroupName)
+	}
+	txn.SetValueTransformers(s.valueTransformers)
+	return txn, nil
 }
 
 // DeleteRange delete all versions of all keys in the range[startKey,endKey) immediately.
@@ -440,14 +557,24 @@ func (s *KVStore) DeleteRange(
 // Specially, it is useful to set ts to math.MaxUint64 to point get the latest committed data.
 func (s *KVStore) GetSnapshot(ts uint64) *txnsnapshot.KVSnapshot {
 	snapshot := txnsnapshot.NewTiKVSnapshot(s, ts, s.nextReplicaReadSeed())
+	if s.resourceGroupName != "" {
+		snapshot.SetResourceGroupName(s.resourceGroupName)
+	}
+	snapshot.SetValueTransformers(s.valueTransformers)
 	return snapshot
 }
 
 // Close store
 func (s *KVStore) Close() error {
 	defer s.gP.Close()
+	if err := s.StopRecording(); err != nil {
+		s.Logger().Warn("failed to stop recording requests", zap.Error(err))
+	}
 	s.close.Store(true)
 	s.cancel()
+	if s.lockCleanupScheduler != nil {
+		s.lockCleanupScheduler.Close()
+	}
 	s.wg.Wait()
 
 	s.oracle.Close()
@@ -626,6 +753,14 @@ func (s *KVStore) GetTiKVClient() (client Client) {
 	return s.clientMu.client
 }
 
+// SetBatchPolicy updates the batch policy of the requests sent to the stores at runtime, overriding
+// config.TiKVClient.BatchPolicy, e.g. to tune the batching during incidents. The policy is validated, and the batch
+// send loops switch to it before sending their next batches. It returns an error if the client isn't (a wrapper of)
+// the RPC client.
+func (s *KVStore) SetBatchPolicy(policy string) error {
+	return client.SetBatchPolicy(s.GetTiKVClient(), policy)
+}
+
 // GetMinSafeTS return the minimal safeTS of the storage with given txnScope.
 func (s *KVStore) GetMinSafeTS(txnScope string) uint64 {
 	if val, ok := s.minSafeTS.Load(txnScope); ok {
@@ -637,7 +772,7 @@ func (s *KVStore) GetMinSafeTS(txnScope string) uint64 {
 func (s *KVStore) setMinSafeTS(txnScope string, safeTS uint64) {
 	// ensure safeTS is not set to max uint64
 	if safeTS == math.MaxUint64 {
-		logutil.AssertWarn(logutil.BgLogger(), "skip setting min-safe-ts to max uint64", zap.String("txnScope", txnScope), zap.Stack("stack"))
+		logutil.AssertWarn(s.Logger(), "skip setting min-safe-ts to max uint64", zap.String("txnScope", txnScope), zap.Stack("stack"))
 		return
 	}
 	s.minSafeTS.Store(txnScope, safeTS)
@@ -658,6 +793,12 @@ func (s *KVStore) WaitGroup() *sync.WaitGroup {
 	return &s.wg
 }
 
+// GetLockCleanupScheduler returns the scheduler cleaning up locks of failed transactions,
+// nil if it's not enabled.
+func (s *KVStore) GetLockCleanupScheduler() *transaction.LockCleanupScheduler {
+	return s.lockCleanupScheduler
+}
+
 // TxnLatches returns txnLatches.
 func (s *KVStore) TxnLatches() *latch.LatchesScheduler {
 	return s.txnLatches
@@ -680,7 +821,7 @@ func (s *KVStore) getSafeTS(storeID uint64) (bool, uint64) {
 func (s *KVStore) setSafeTS(storeID, safeTS uint64) {
 	// ensure safeTS is not set to max uint64
 	if safeTS == math.MaxUint64 {
-		logutil.AssertWarn(logutil.BgLogger(), "skip setting safe-ts to max uint64", zap.Uint64("storeID", storeID), zap.Stack("stack"))
+		logutil.AssertWarn(s.Logger(), "skip setting safe-ts to max uint64", zap.Uint64("storeID", storeID), zap.Stack("stack"))
 		return
 	}
 	s.safeTSMap.Store(storeID, safeTS)
@@ -757,7 +898,7 @@ func (s *KVStore) updateSafeTS(ctx context.Context) {
 		_, storeMinResolvedTSs, err = s.getMinResolvedTSByStoresIDs(ctx, storeIDs)
 		if err != nil {
 			// If getting the minimum resolved timestamp from PD failed, log the error and need to get it from TiKV.
-			logutil.BgLogger().Debug("get resolved TS from PD failed", zap.Error(err), zap.Any("stores", storeIDs))
+			s.Logger().Debug("get resolved TS from PD failed", zap.Error(err), zap.Any("stores", storeIDs))
 		}
 	}
 
@@ -776,21 +917,21 @@ func (s *KVStore) updateSafeTS(ctx context.Context) {
 			)
 			// If getting the minimum resolved timestamp from PD failed or returned 0/MaxUint64, try to get it from TiKV.
 			if storeMinResolvedTSs == nil || !isValidSafeTS(storeMinResolvedTSs[storeID]) || err != nil {
-				resp, err := tikvClient.SendRequest(
-					ctx, storeAddr, tikvrpc.NewRequest(
-						tikvrpc.CmdStoreSafeTS, &kvrpcpb.StoreSafeTSRequest{
-							KeyRange: &kvrpcpb.KeyRange{
-								StartKey: []byte(""),
-								EndKey:   []byte(""),
-							},
-						}, kvrpcpb.Context{
-							RequestSource: util.RequestSourceFromCtx(ctx),
+				req := tikvrpc.NewRequest(
+					tikvrpc.CmdStoreSafeTS, &kvrpcpb.StoreSafeTSRequest{
+						KeyRange: &kvrpcpb.KeyRange{
+							StartKey: []byte(""),
+							EndKey:   []byte(""),
 						},
-					), client.ReadTimeoutShort,
+					}, kvrpcpb.Context{
+						RequestSource: util.RequestSourceFromCtx(ctx),
+					},
 				)
+				req.TrafficClass = tikvrpc.TrafficClassSafeTS
+				resp, err := tikvClient.SendRequest(ctx, storeAddr, req, client.DefaultTimeout(req.Type))
 				if err != nil {
 					metrics.TiKVSafeTSUpdateCounter.WithLabelValues("fail", storeIDStr).Inc()
-					logutil.BgLogger().Debug("update safeTS failed", zap.Error(err), zap.Uint64("store-id", storeID))
+					s.Logger().Debug("update safeTS failed", zap.Error(err), zap.Uint64("store-id", storeID))
 					return
 				}
 				safeTS = resp.Resp.(*kvrpcpb.StoreSafeTSResponse).GetSafeTs()
@@ -842,12 +983,12 @@ func (s *KVStore) getMinResolvedTSByStoresIDs(ctx context.Context, storeIDs []ui
 			return minResolvedTS, storeMinResolvedTSs, err
 		}
 		minResolvedTS = uint64(injectedTS)
-		logutil.BgLogger().Info("inject min resolved ts", zap.Uint64("ts", uint64(injectedTS)))
+		s.Logger().Info("inject min resolved ts", zap.Uint64("ts", uint64(injectedTS)))
 		// Currently we only have a store 1 in the test, so it's OK to inject the same min resolved TS for all stores here.
 		for storeID, v := range storeMinResolvedTSs {
 			if v != 0 && v != math.MaxUint64 {
 				storeMinResolvedTSs[storeID] = uint64(injectedTS)
-				logutil.BgLogger().Info("inject store min resolved ts", zap.Uint64("storeID", storeID), zap.Uint64("ts", uint64(injectedTS)))
+				s.Logger().Info("inject store min resolved ts", zap.Uint64("storeID", storeID), zap.Uint64("ts", uint64(injectedTS)))
 			}
 		}
 	}
@@ -868,7 +1009,7 @@ func (s *KVStore) updateGlobalTxnScopeTSFromPD(ctx context.Context) bool {
 	if s.pdHttpClient != nil && isGlobal {
 		clusterMinSafeTS, _, err := s.getMinResolvedTSByStoresIDs(ctx, nil)
 		if err != nil {
-			logutil.BgLogger().Debug("get resolved TS from PD failed", zap.Error(err))
+			s.Logger().Debug("get resolved TS from PD failed", zap.Error(err))
 		} else if isValidSafeTS(clusterMinSafeTS) {
 			// Update ts and metrics.
 			preClusterMinSafeTS := s.GetMinSafeTS(oracle.GlobalTxnScope)
@@ -993,6 +1134,13 @@ func WithDefaultPipelinedTxn() TxnOption {
 	}
 }
 
+// WithPessimisticRetryStrategy sets the strategy that shapes the retries of blocked pessimistic lock requests.
+func WithPessimisticRetryStrategy(strategy transaction.PessimisticRetryStrategy) TxnOption {
+	return func(st *transaction.TxnOptions) {
+		st.PessimisticRetryStrategy = strategy
+	}
+}
+
 // WithPipelinedTxn creates pipelined txn with specified parameters
 func WithPipelinedTxn(
 	flushConcurrency,
@@ -15,22 +15,38 @@
 package tikv
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/deadlock"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/testutils"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util"
+	"github.com/tikv/pd/client/constants"
 	pdhttp "github.com/tikv/pd/client/http"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestKV(t *testing.T) {
@@ -273,3 +289,690 @@ func (s *testKVSuite) TestMinSafeTsFromMixed2() {
 	s.Require().Equal(mockClient.tikvSafeTs, s.store.GetMinSafeTS("z1"))
 	s.Require().Equal(uint64(10), s.store.GetMinSafeTS("z2"))
 }
+
+func (s *testKVSuite) TestFlashbackChecks() {
+	ctx := context.Background()
+	err := s.store.PrepareFlashbackToVersion(ctx, nil, nil, 100, 100)
+	s.Require().ErrorContains(err, "must be less than start ts")
+	err = s.store.FlashbackToVersion(ctx, nil, nil, 100, 200, 200)
+	s.Require().ErrorContains(err, "must be greater than start ts")
+	// The mock stores don't report their versions, so they are considered not supporting flashback.
+	err = s.store.PrepareFlashbackToVersion(ctx, nil, nil, 100, 200)
+	s.Require().ErrorContains(err, "not supported")
+}
+
+func (s *testKVSuite) TestGCBarrier() {
+	ctx := context.Background()
+	_, err := s.store.SetGCBarrier(ctx, "", 10, time.Minute)
+	s.Require().Error(err)
+
+	barrier, err := s.store.SetGCBarrier(ctx, "b1", 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Equal(uint64(10), barrier.BarrierTS)
+	state, err := s.store.GetGCState(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(state.GCBarriers, 1)
+	s.Require().Equal("b1", state.GCBarriers[0].BarrierID)
+
+	barrier, err = s.store.DeleteGCBarrier(ctx, "b1")
+	s.Require().NoError(err)
+	s.Require().Equal("b1", barrier.BarrierID)
+	barrier, err = s.store.DeleteGCBarrier(ctx, "b1")
+	s.Require().NoError(err)
+	s.Require().Nil(barrier)
+}
+
+func (s *testKVSuite) TestGetSnapshotAt() {
+	ctx := context.Background()
+	snapshot, err := s.store.GetSnapshotAt(ctx, 100)
+	s.Require().NoError(err)
+	s.Require().NoError(snapshot.Release(ctx))
+	_, err = s.store.GetSnapshotAt(ctx, 100, WithSnapshotGCBarrier(time.Millisecond))
+	s.Require().ErrorContains(err, "should be at least 1s")
+
+	snapshot, err = s.store.GetSnapshotAt(ctx, 100, WithSnapshotGCBarrier(time.Minute))
+	s.Require().NoError(err)
+	state, err := s.store.GetGCState(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(state.GCBarriers, 1)
+	s.Require().Equal(uint64(100), state.GCBarriers[0].BarrierTS)
+
+	// The barrier blocks the txn safe point.
+	controller := s.store.pdClient.GetGCInternalController(constants.NullKeyspaceID)
+	res, err := controller.AdvanceTxnSafePoint(ctx, 200)
+	s.Require().NoError(err)
+	s.Require().Equal(uint64(100), res.NewTxnSafePoint)
+
+	s.Require().NoError(snapshot.Release(ctx))
+	s.Require().NoError(snapshot.Release(ctx))
+	state, err = s.store.GetGCState(ctx)
+	s.Require().NoError(err)
+	s.Require().Empty(state.GCBarriers)
+
+	_, err = controller.AdvanceTxnSafePoint(ctx, 200)
+	s.Require().NoError(err)
+	_, err = s.store.GetSnapshotAt(ctx, 150, WithSnapshotGCBarrier(time.Minute))
+	var expired *tikverr.ErrSnapshotExpired
+	s.Require().ErrorAs(err, &expired)
+	s.Require().Equal(uint64(150), expired.SnapshotTS)
+	s.Require().Equal(uint64(200), expired.TxnSafePoint)
+}
+
+type memCommitTSStore struct {
+	ts    uint64
+	saves int
+}
+
+func (m *memCommitTSStore) LoadCommitTS(context.Context) (uint64, error) {
+	return m.ts, nil
+}
+
+func (m *memCommitTSStore) SaveCommitTS(_ context.Context, ts uint64) error {
+	m.ts = ts
+	m.saves++
+	return nil
+}
+
+func (s *testKVSuite) TestCommitTSCheckpoint() {
+	ctx := context.Background()
+	persist := &memCommitTSStore{}
+	checkpoint := NewCommitTSCheckpoint(s.store, persist)
+	ts, err := checkpoint.Load(ctx)
+	s.Require().NoError(err)
+	s.Require().Zero(ts)
+
+	txn, err := s.store.Begin()
+	s.Require().NoError(err)
+	s.Require().NoError(txn.Set([]byte("k"), []byte("v")))
+	s.Require().NoError(txn.Commit(ctx))
+	checkpoint.ObserveTxn(txn)
+	checkpoint.Observe(txn.CommitTS() - 1)
+	s.Require().Equal(txn.CommitTS(), checkpoint.Observed())
+	s.Require().NoError(checkpoint.Flush(ctx))
+	s.Require().NoError(checkpoint.Flush(ctx))
+	s.Require().Equal(txn.CommitTS(), persist.ts)
+	s.Require().Equal(1, persist.saves)
+
+	// The service restarts and reads its own writes.
+	checkpoint = NewCommitTSCheckpoint(s.store, persist)
+	ts, err = checkpoint.Load(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal(txn.CommitTS(), ts)
+	snapshot, err := s.store.NewSnapshotAtLeast(ctx, ts)
+	s.Require().NoError(err)
+	val, err := snapshot.Get(ctx, []byte("k"))
+	s.Require().NoError(err)
+	s.Require().Equal([]byte("v"), val)
+
+	// The ts can't catch up with a min ts far in the future.
+	ctx1, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	future := oracle.GoTimeToTS(time.Now().Add(time.Hour))
+	_, err = s.store.NewSnapshotAtLeast(ctx1, future)
+	s.Require().Error(err)
+}
+
+func (s *testKVSuite) TestBoundedStalenessRead() {
+	ctx := context.Background()
+	txn, err := s.store.Begin()
+	s.Require().NoError(err)
+	s.Require().NoError(txn.Set([]byte("a1"), []byte("v1")))
+	s.Require().NoError(txn.Set([]byte("b1"), []byte("v2")))
+	s.Require().NoError(txn.Set([]byte("c1"), []byte("v3")))
+	s.Require().NoError(txn.Commit(ctx))
+	ranges := []kv.KeyRange{{StartKey: []byte("a"), EndKey: []byte("b")}, {StartKey: []byte("c"), EndKey: []byte("d")}}
+
+	// The safe ts is older than the staleness bound.
+	now, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().NoError(err)
+	s.store.setSafeTS(s.tikvStoreID, oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-time.Hour)))
+	ts, err := s.store.GetBoundedStalenessTS(ctx, ranges, time.Minute)
+	s.Require().NoError(err)
+	s.Require().False(ts.AllReplicasReady)
+	s.Require().GreaterOrEqual(ts.TS, oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-time.Minute)))
+
+	now, err = s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().NoError(err)
+	s.store.setSafeTS(s.tikvStoreID, now)
+	ts, err = s.store.GetBoundedStalenessTS(ctx, ranges, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Equal(BoundedStalenessTS{TS: now, AllReplicasReady: true}, ts)
+
+	mockClient := &staleReadMockClient{Client: s.store.GetTiKVClient()}
+	s.store.SetTiKVClient(mockClient)
+	var pairs []string
+	readTS, err := s.store.ScanRangesWithBoundedStaleness(ctx, ranges, time.Minute, func(key, value []byte) error {
+		pairs = append(pairs, string(key)+"="+string(value))
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(now, readTS)
+	s.Require().Equal([]string{"a1=v1", "c1=v3"}, pairs)
+	s.Require().NotEmpty(mockClient.staleReads)
+	for _, staleRead := range mockClient.staleReads {
+		s.Require().True(staleRead)
+	}
+
+	// The other scans of a staleness read only snapshot are not sent as stale reads.
+	mockClient.staleReads = nil
+	snapshot := s.store.GetSnapshot(readTS)
+	snapshot.SetIsStalenessReadOnly(true)
+	iter, err := snapshot.Iter([]byte("a"), []byte("b"))
+	s.Require().NoError(err)
+	iter.Close()
+	s.Require().Equal([]bool{false}, mockClient.staleReads)
+}
+
+type staleReadMockClient struct {
+	Client
+	staleReads []bool
+}
+
+func (c *staleReadMockClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type == tikvrpc.CmdScan {
+		c.staleReads = append(c.staleReads, req.StaleRead)
+	}
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+type lockWaitInfoMockClient struct {
+	Client
+	entries []*deadlock.WaitForEntry
+}
+
+func (c *lockWaitInfoMockClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type != tikvrpc.CmdLockWaitInfo {
+		return c.Client.SendRequest(ctx, addr, req, timeout)
+	}
+	return &tikvrpc.Response{Resp: &kvrpcpb.GetLockWaitInfoResponse{Entries: c.entries}}, nil
+}
+
+type resourceGroupMockClient struct {
+	Client
+	names []string
+}
+
+func (c *resourceGroupMockClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type == tikvrpc.CmdGet {
+		c.names = append(c.names, req.GetResourceControlContext().GetResourceGroupName())
+	}
+	return c.Client.SendRequest(ctx, addr, req, timeout)
+}
+
+func (s *testKVSuite) TestDefaultResourceGroup() {
+	WithDefaultResourceGroup("tenant1")(s.store)
+	mockClient := &resourceGroupMockClient{Client: s.store.GetTiKVClient()}
+	s.store.SetTiKVClient(mockClient)
+
+	snapshot := s.store.GetSnapshot(math.MaxUint64)
+	_, err := snapshot.Get(context.Background(), []byte("k"))
+	s.Require().True(tikverr.IsErrNotFound(err))
+	txn, err := s.store.Begin()
+	s.Require().NoError(err)
+	_, err = txn.Get(context.Background(), []byte("k"))
+	s.Require().True(tikverr.IsErrNotFound(err))
+	txn.SetResourceGroupName("tenant2")
+	_, err = txn.GetSnapshot().Get(context.Background(), []byte("k2"))
+	s.Require().True(tikverr.IsErrNotFound(err))
+	s.Equal([]string{"tenant1", "tenant1", "tenant2"}, mockClient.names)
+}
+
+func (s *testKVSuite) TestLockWaitChains() {
+	s.store.SetTiKVClient(&lockWaitInfoMockClient{
+		Client: s.store.GetTiKVClient(),
+		entries: []*deadlock.WaitForEntry{
+			{Txn: 20, WaitForTxn: 30, Key: []byte("k2"), WaitTime: 800},
+			{Txn: 10, WaitForTxn: 20, Key: []byte("k1"), WaitTime: 1500},
+			{Txn: 40, WaitForTxn: 50, Key: []byte("k3"), WaitTime: 100},
+			{Txn: 50, WaitForTxn: 40, Key: []byte("k4"), WaitTime: 200},
+		},
+	})
+
+	entries, err := s.store.GetLockWaitEntries(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(entries, 4)
+	s.Require().Equal(LockWaitEntry{StoreID: s.tikvStoreID, Txn: 10, WaitForTxn: 20, Key: []byte("k1"), WaitTime: 1500 * time.Millisecond}, entries[0])
+
+	var buf bytes.Buffer
+	s.Require().NoError(DumpLockWaitChains(&buf, entries))
+	s.Require().Equal(fmt.Sprintf(
+		"10 -[store %[1]d, key 6B31, 1.5s]-> 20 -[store %[1]d, key 6B32, 800ms]-> 30\n"+
+			"40 -[store %[1]d, key 6B33, 100ms]-> 50 -[store %[1]d, key 6B34, 200ms]-> 40 (deadlock)\n", s.tikvStoreID), buf.String())
+
+	entries, err = s.store.GetLockWaitEntries(context.Background(), 30)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Require().Equal(uint64(20), entries[0].Txn)
+}
+
+func (s *testKVSuite) TestSplitRangeByRegions() {
+	region, _, _, _ := s.cluster.GetRegionByKey([]byte("a"))
+	regionID := region.GetId()
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(regionID, region2, []byte("m"), newPeers, newPeers[0])
+	s.cluster.SplitRegionBuckets(regionID, [][]byte{{}, []byte("c"), []byte("e"), []byte("g"), []byte("i")}, 1)
+
+	ctx := context.Background()
+	ranges, err := SplitRangeByRegions(ctx, s.store.GetRegionCache(), []byte("b"), []byte("z"), 1)
+	s.Require().NoError(err)
+	s.Require().Len(ranges, 2)
+	s.Require().Equal([]byte("b"), ranges[0].StartKey)
+	s.Require().Equal([]byte("m"), ranges[0].EndKey)
+	s.Require().Equal(regionID, ranges[0].Region.GetID())
+	s.Require().Equal(s.tikvStoreID, ranges[0].LeaderStoreID)
+	s.Require().Equal(s.storeAddr(s.tikvStoreID), ranges[0].LeaderAddr)
+	s.Require().Equal([]byte("m"), ranges[1].StartKey)
+	s.Require().Equal([]byte("z"), ranges[1].EndKey)
+	s.Require().Equal(region2, ranges[1].Region.GetID())
+
+	// The first region is split by the buckets after "d".
+	ranges, err = SplitRangeByRegions(ctx, s.store.GetRegionCache(), []byte("d"), []byte("z"), 3)
+	s.Require().NoError(err)
+	s.Require().Len(ranges, 4)
+	s.Require().Equal([]byte("d"), ranges[0].StartKey)
+	s.Require().Equal([]byte("e"), ranges[0].EndKey)
+	s.Require().Equal([]byte("g"), ranges[1].EndKey)
+	s.Require().Equal([]byte("m"), ranges[2].EndKey)
+	s.Require().Equal(region2, ranges[3].Region.GetID())
+}
+
+func (s *testKVSuite) TestHealthCheck() {
+	mockClient := newStoreSafeTsMockClient(s)
+	s.store.SetTiKVClient(mockClient)
+
+	report := s.store.HealthCheck(context.Background())
+	s.Require().True(report.Healthy(), "%+v", report)
+	s.Require().NoError(report.PD.Err)
+	s.Require().NoError(report.TSO.Err)
+	s.Require().NoError(report.RegionCache.Err)
+	// The TiFlash store isn't checked.
+	s.Require().Len(report.Stores, 1)
+	s.Require().Equal(s.tikvStoreID, report.Stores[0].StoreID)
+	s.Require().GreaterOrEqual(atomic.LoadInt32(&mockClient.requestCount), int32(1))
+
+	report.Stores[0].Err = errors.New("unreachable")
+	s.Require().False(report.Healthy())
+}
+
+func (s *testKVSuite) TestHealthFeedbackSimulator() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	mocktikv.BootstrapWithSingleStore(cluster)
+	sim := NewHealthFeedbackSimulator(client)
+	store, err := NewTestTiKVStore(sim, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	defer store.Close()
+	_, err = store.GetRegionCache().LocateKey(retry.NewNoopBackoff(context.Background()), []byte("k"))
+	s.Require().Nil(err)
+	stores := store.GetRegionCache().GetStoresByType(tikvrpc.TiKV)
+	s.Require().NotEmpty(stores)
+	target := stores[0]
+
+	// The simulated slow scores are applied one after another without waiting for the update interval.
+	s.True(sim.Feed(target.StoreID(), 100))
+	s.True(target.GetHealthStatus().IsSlow())
+	s.Equal(int64(100), target.GetHealthStatus().GetHealthStatusDetail().TiKVSideSlowScore)
+	s.True(sim.Feed(target.StoreID(), 1))
+	s.False(target.GetHealthStatus().IsSlow())
+	s.Equal(int64(1), target.GetHealthStatus().GetHealthStatusDetail().TiKVSideSlowScore)
+}
+
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *recordingLogger) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.InfoLevel
+}
+
+func (l *recordingLogger) Log(level zapcore.Level, msg string, fields []zap.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf("%s %s %v", level, msg, enc.Fields))
+}
+
+func (s *testKVSuite) TestLogger() {
+	client, _, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	logger := &recordingLogger{}
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0, WithLogger(logger))
+	s.Require().Nil(err)
+	defer store.Close()
+
+	store.Logger().Debug("debug")
+	store.Logger().Info("info", zap.Int("k", 1))
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	s.Require().Equal([]string{
+		fmt.Sprintf("info info map[clusterID:%d k:1 keyspaceID:%d]", store.clusterID, uint32(store.getCodec().GetKeyspaceID())),
+	}, logger.logs)
+}
+
+func (s *testKVSuite) TestExpectedClusterID() {
+	client, _, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	clusterID := pdClient.GetClusterID(context.Background())
+	_, err = NewTestTiKVStore(client, pdClient, nil, nil, 0, WithExpectedClusterID(clusterID+1))
+	var errMismatch *tikverr.ErrClusterIDMismatch
+	s.Require().ErrorAs(err, &errMismatch)
+	s.Equal("pd", errMismatch.Source)
+	s.Equal(clusterID+1, errMismatch.Expected)
+	s.Equal(clusterID, errMismatch.Actual)
+
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0, WithExpectedClusterID(clusterID))
+	s.Require().Nil(err)
+	store.Close()
+}
+
+func (s *testKVSuite) TestSetLogLevel() {
+	client, _, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	logger := &recordingLogger{}
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0, WithLogger(logger))
+	s.Require().Nil(err)
+	defer store.Close()
+
+	store.SetLogLevel(zapcore.WarnLevel)
+	store.Logger().Info("info")
+	store.Logger().Warn("warn")
+	// Lowering the level can't enable the logs disabled by the underlying logger.
+	store.SetLogLevel(zapcore.DebugLevel)
+	store.Logger().Debug("debug")
+	store.Logger().Info("info")
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	var levels []string
+	for _, log := range logger.logs {
+		levels = append(levels, strings.Fields(log)[0])
+	}
+	s.Equal([]string{"warn", "info"}, levels)
+}
+
+func (s *testKVSuite) TestStoreLoadAlert() {
+	var alerts []StoreLoadAlert
+	var a storeLoadAlerter
+	a.cfg.Store(&StoreLoadAlertConfig{
+		MaxPending:  10,
+		MaxInflight: 100,
+		For:         3 * time.Second,
+		OnAlert:     func(alert StoreLoadAlert) { alerts = append(alerts, alert) },
+	})
+
+	start := time.Now()
+	a.check(start, []BatchQueueStat{{Target: "s1", Pending: 11}, {Target: "s2", Inflight: 100}})
+	a.check(start.Add(2*time.Second), []BatchQueueStat{{Target: "s1", Pending: 20}, {Target: "s2", Inflight: 101}})
+	s.Empty(alerts)
+	a.check(start.Add(3*time.Second), []BatchQueueStat{{Target: "s1", Pending: 20}, {Target: "s2", Inflight: 101}})
+	s.Equal([]StoreLoadAlert{{Addr: "s1", Pending: 20, Since: start}}, alerts)
+	// Alert only once until the store recovers.
+	a.check(start.Add(5*time.Second), []BatchQueueStat{{Target: "s1", Pending: 20}, {Target: "s2", Inflight: 101}})
+	s.Len(alerts, 2)
+	s.Equal(StoreLoadAlert{Addr: "s2", Inflight: 101, Since: start.Add(2 * time.Second)}, alerts[1])
+	a.check(start.Add(6*time.Second), []BatchQueueStat{{Target: "s1", Pending: 10}, {Target: "s2", Inflight: 101}})
+	s.Len(alerts, 3)
+	s.Equal(StoreLoadAlert{Addr: "s1", Pending: 10, Since: start, Recovered: true}, alerts[2])
+
+	a.cfg.Store(nil)
+	a.check(start.Add(7*time.Second), []BatchQueueStat{{Target: "s2"}})
+	s.Len(alerts, 3)
+}
+
+func (s *testKVSuite) TestStoreStatsReporter() {
+	var reports [][]StoreStats
+	s.store.statsReporter.cfg.Store(&storeStatsReporterConfig{
+		reporter: StoreStatsReporterFunc(func(_ context.Context, _ time.Duration, stats []StoreStats) error {
+			reports = append(reports, stats)
+			return nil
+		}),
+		interval: time.Second,
+	})
+
+	snapshot := s.store.GetSnapshot(math.MaxUint64)
+	for i := 0; i < 3; i++ {
+		_, err := snapshot.Get(context.Background(), []byte("k"))
+		s.Require().True(tikverr.IsErrNotFound(err))
+	}
+	s.store.reportStoreStats(time.Now())
+	s.Require().Len(reports, 1)
+	s.Require().Len(reports[0], 1)
+	stats := reports[0][0]
+	s.Equal(s.tikvStoreID, stats.StoreID)
+	s.Equal(s.storeAddr(s.tikvStoreID), stats.Addr)
+	s.Equal(int64(3), stats.Requests)
+	s.Equal(int64(0), stats.Errors)
+	s.LessOrEqual(stats.MaxLatency, stats.TotalLatency)
+	s.LessOrEqual(stats.AvgLatency(), stats.MaxLatency)
+
+	// The stats are reset after being reported, and the stores without RPCs are omitted.
+	s.store.reportStoreStats(time.Now())
+	s.Require().Len(reports, 2)
+	s.Empty(reports[1])
+}
+
+func (s *testKVSuite) TestRunawayWatchdog() {
+	var events []RunawayEvent
+	w := NewRunawayWatchdog(RunawayConfig{
+		MaxDuration:   time.Minute,
+		MaxRU:         100,
+		CheckInterval: time.Hour,
+		OnRunaway: func(event RunawayEvent) RunawayAction {
+			events = append(events, event)
+			if event.Name == "scan" {
+				return RunawayActionCancel
+			}
+			return RunawayActionNone
+		},
+	})
+	defer w.Close()
+
+	scanCtx, scanDone := w.Track(context.Background(), "scan")
+	defer scanDone()
+	ruDetails := util.NewRUDetailsWith(60, 30, 0)
+	getCtx, getDone := w.Track(context.WithValue(context.Background(), util.RUDetailsCtxKey, ruDetails), "get")
+	defer getDone()
+
+	start := time.Now()
+	w.check(start)
+	s.Empty(events)
+
+	// The RU consumed by get exceeds the threshold, but it's not canceled.
+	ruDetails.Merge(util.NewRUDetailsWith(10, 0, 0))
+	w.check(start)
+	s.Require().Len(events, 1)
+	s.Equal("get", events[0].Name)
+	s.Equal(RunawayReasonRU, events[0].Reason)
+	s.Equal(float64(100), events[0].RU)
+	s.NoError(getCtx.Err())
+
+	// The scan runs too long and is canceled, each operation is flagged only once.
+	w.check(start.Add(time.Minute))
+	s.Require().Len(events, 2)
+	s.Equal("scan", events[1].Name)
+	s.Equal(RunawayReasonDuration, events[1].Reason)
+	s.Error(scanCtx.Err())
+	var runawayErr *tikverr.ErrRunawayOperation
+	s.Require().ErrorAs(context.Cause(scanCtx), &runawayErr)
+	s.Equal("scan", runawayErr.Name)
+
+	// The finished operations are no longer tracked.
+	getDone()
+	s.ErrorIs(getCtx.Err(), context.Canceled)
+	s.ErrorIs(context.Cause(getCtx), context.Canceled)
+	w.mu.Lock()
+	s.Len(w.ops, 1)
+	w.mu.Unlock()
+}
+
+type batchCopStream struct {
+	tikvpb.Tikv_BatchCoprocessorClient
+	resps []*coprocessor.BatchResponse
+}
+
+func (s *batchCopStream) Recv() (*coprocessor.BatchResponse, error) {
+	if len(s.resps) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.resps[0]
+	s.resps = s.resps[1:]
+	return resp, nil
+}
+
+type batchCopMockClient struct {
+	Client
+	addr string
+	req  *coprocessor.BatchRequest
+}
+
+func (c *batchCopMockClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type != tikvrpc.CmdBatchCop {
+		return c.Client.SendRequest(ctx, addr, req, timeout)
+	}
+	c.addr, c.req = addr, req.BatchCop()
+	stream := &batchCopStream{resps: []*coprocessor.BatchResponse{
+		{Data: []byte("d2"), RetryRegions: []*metapb.Region{{Id: c.req.Regions[0].RegionId, RegionEpoch: c.req.Regions[0].RegionEpoch}}},
+	}}
+	return &tikvrpc.Response{Resp: &tikvrpc.BatchCopStreamResponse{
+		Tikv_BatchCoprocessorClient: stream,
+		BatchResponse:               &coprocessor.BatchResponse{Data: []byte("d1")},
+		Timeout:                     timeout,
+	}}, nil
+}
+
+func (s *testKVSuite) TestBatchCop() {
+	region, _, _, _ := s.cluster.GetRegionByKey([]byte("a"))
+	newPeers := s.cluster.AllocIDs(len(region.Peers))
+	newRegionID := s.cluster.AllocID()
+	s.cluster.Split(region.Id, newRegionID, []byte("m"), newPeers, newPeers[0])
+
+	bo := retry.NewBackofferWithVars(context.Background(), 1000, nil)
+	tasks, err := s.store.BuildBatchCopTasks(bo, []kv.KeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("c")},
+		{StartKey: []byte("k"), EndKey: []byte("z")},
+	}, LabelFilterAllTiFlashNode)
+	s.Require().NoError(err)
+	// All the regions are served by the only TiFlash store.
+	s.Require().Len(tasks, 1)
+	task := tasks[0]
+	s.Equal(s.tiflashStoreID, task.StoreID)
+	s.Equal(s.storeAddr(s.tiflashStoreID), task.Addr)
+	s.Require().Len(task.Regions, 2)
+	s.Equal(region.Id, task.Regions[0].RegionId)
+	s.Equal([]*coprocessor.KeyRange{{Start: []byte("a"), End: []byte("c")}, {Start: []byte("k"), End: []byte("m")}}, task.Regions[0].Ranges)
+	s.Equal(newRegionID, task.Regions[1].RegionId)
+	s.Equal([]*coprocessor.KeyRange{{Start: []byte("m"), End: []byte("z")}}, task.Regions[1].Ranges)
+
+	mockClient := &batchCopMockClient{Client: s.store.GetTiKVClient()}
+	s.store.SetTiKVClient(mockClient)
+	var data []string
+	req := &coprocessor.BatchRequest{}
+	retryRegions, err := s.store.SendBatchCop(context.Background(), task, req, time.Second,
+		func(resp *coprocessor.BatchResponse) error {
+			data = append(data, string(resp.Data))
+			return nil
+		})
+	s.Require().NoError(err)
+	s.Equal(task.Addr, mockClient.addr)
+	s.Equal(task.Regions, mockClient.req.Regions)
+	// The request of the caller is not modified.
+	s.Empty(req.Regions)
+	s.Equal([]string{"d1", "d2"}, data)
+	s.Require().Len(retryRegions, 1)
+	s.Equal(region.Id, retryRegions[0].Id)
+	// The region to retry is invalidated.
+	s.Nil(s.store.GetRegionCache().TryLocateKey([]byte("a")))
+	s.NotNil(s.store.GetRegionCache().TryLocateKey([]byte("m")))
+}
+
+func (s *testKVSuite) TestDumpDebugInfo() {
+	var buf bytes.Buffer
+	s.Nil(s.store.DumpDebugInfo(&buf))
+	out := buf.String()
+	for _, section := range []string{"[batch queues]", "[backoff]", "[region cache]", "[transactions]"} {
+		s.Contains(out, section)
+	}
+	s.Contains(out, fmt.Sprintf("store %d ", s.tikvStoreID))
+}
+
+func (s *testKVSuite) TestRecordAndReplay() {
+	path := filepath.Join(s.T().TempDir(), "records")
+	origin := s.store.GetTiKVClient()
+	s.Nil(s.store.StartRecording(path, 0, WithRecordedValues()))
+	s.NotEqual(origin, s.store.GetTiKVClient())
+	s.NotNil(s.store.StartRecording(path, 0))
+
+	txn, err := s.store.Begin()
+	s.Nil(err)
+	s.Nil(txn.Set([]byte("k"), []byte("v")))
+	s.Nil(txn.Commit(context.Background()))
+	snapshot := s.store.GetSnapshot(math.MaxUint64)
+	val, err := snapshot.Get(context.Background(), []byte("k"))
+	s.Nil(err)
+	s.Equal([]byte("v"), val)
+
+	s.Nil(s.store.StopRecording())
+	s.Nil(s.store.StopRecording())
+	s.Equal(origin, s.store.GetTiKVClient())
+
+	replay, err := NewReplayClient(path)
+	s.Nil(err)
+	s.Greater(replay.Remaining(), 0)
+
+	// Replay the responses against a fresh mock cluster, which doesn't have the key.
+	mockClient, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Nil(err)
+	defer mockClient.Close()
+	mocktikv.BootstrapWithSingleStore(cluster)
+	store, err := NewTestTiKVStore(replay, pdClient, nil, nil, 0)
+	s.Nil(err)
+	defer store.Close()
+	val, err = store.GetSnapshot(math.MaxUint64).Get(context.Background(), []byte("k"))
+	s.Nil(err)
+	s.Equal([]byte("v"), val)
+	// The only recorded Get response has been replayed.
+	_, err = replay.SendRequest(context.Background(), "", tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+	s.NotNil(err)
+}
+
+func (s *testKVSuite) TestRecordWindowAfterRestart() {
+	path := filepath.Join(s.T().TempDir(), "records")
+	origin := s.store.GetTiKVClient()
+	s.Nil(s.store.StartRecording(path, 50*time.Millisecond))
+	stale := s.store.recording.recorder
+	s.Nil(s.store.StopRecording())
+	s.Nil(s.store.StartRecording(path, 0))
+
+	// The window of the stopped recording expires, and its timer, which might have fired before it's stopped, can't
+	// stop the new recording.
+	time.Sleep(100 * time.Millisecond)
+	s.Nil(s.store.stopRecording(stale))
+	s.NotNil(s.store.recording.recorder)
+	s.NotEqual(origin, s.store.GetTiKVClient())
+	s.Nil(s.store.StopRecording())
+	s.Equal(origin, s.store.GetTiKVClient())
+}
+
+func (s *testKVSuite) TestRecordWithoutValues() {
+	path := filepath.Join(s.T().TempDir(), "records")
+	s.Nil(s.store.StartRecording(path, time.Hour))
+	txn, err := s.store.Begin()
+	s.Nil(err)
+	s.Nil(txn.Set([]byte("k"), []byte("v")))
+	s.Nil(txn.Commit(context.Background()))
+	s.Nil(s.store.StopRecording())
+
+	data, err := os.ReadFile(path)
+	s.Nil(err)
+	s.Contains(string(data), "Prewrite")
+	replay, err := NewReplayClient(path)
+	s.Nil(err)
+	resp, err := replay.SendRequest(context.Background(), "", tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{}), time.Second)
+	s.Nil(err)
+	s.IsType(&kvrpcpb.PrewriteResponse{}, resp.Resp)
+}
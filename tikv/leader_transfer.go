@@ -0,0 +1,45 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// TransferLeaderHint tells the client the leader of the region is transferred to the store, e.g. by an orchestrator
+// colocated with the client, so the region cache sends the requests of the region to the new leader at once instead
+// of learning it from a NotLeader error. It returns false if the region isn't cached or has no voter on the store. A
+// wrong hint costs a NotLeader retry, which corrects the cache.
+func (s *KVStore) TransferLeaderHint(regionID, targetStoreID uint64) bool {
+	return s.regionCache.HintLeader(regionID, targetStoreID)
+}
+
+// TransferLeader asks PD to transfer the leader of the region to the store by an operator. The operator runs
+// asynchronously and may fail, so the region cache isn't updated. It learns the new leader from the NotLeader error,
+// or the caller can call TransferLeaderHint once the leader is transferred. It requires the PD HTTP client set by
+// WithPDHTTPClient.
+func (s *KVStore) TransferLeader(ctx context.Context, regionID, targetStoreID uint64) error {
+	if s.pdHttpClient == nil {
+		return errors.New("transferring leader requires the PD HTTP client")
+	}
+	err := s.pdHttpClient.CreateOperators(ctx, map[string]any{
+		"name":        "transfer-leader",
+		"region_id":   regionID,
+		"to_store_id": targetStoreID,
+	})
+	return errors.WithStack(err)
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util/redact"
+	"go.uber.org/zap"
+)
+
+// LockWaitEntry is a pessimistic lock request waiting for the lock of another transaction in a TiKV store.
+type LockWaitEntry struct {
+	StoreID uint64
+	// Txn is the start ts of the waiting transaction, and WaitForTxn is the one holding the lock.
+	Txn              uint64
+	WaitForTxn       uint64
+	Key              []byte
+	KeyHash          uint64
+	WaitTime         time.Duration
+	ResourceGroupTag []byte
+}
+
+// GetLockWaitEntries queries the lock waits of all TiKV stores. If txnIDs are given, only the entries of which the
+// waiting or the waited transaction is one of them are returned. The stores failing to respond are skipped, and an
+// error is returned only if all of them fail.
+func (s *KVStore) GetLockWaitEntries(ctx context.Context, txnIDs ...uint64) ([]LockWaitEntry, error) {
+	filter := make(map[uint64]struct{}, len(txnIDs))
+	for _, id := range txnIDs {
+		filter[id] = struct{}{}
+	}
+	stores := s.regionCache.GetStoresByType(tikvrpc.TiKV)
+	tikvClient := s.GetTiKVClient()
+
+	var (
+		mu      sync.Mutex
+		entries []LockWaitEntry
+		lastErr error
+		failed  int
+		wg      sync.WaitGroup
+	)
+	for _, store := range stores {
+		wg.Add(1)
+		go func(storeID uint64, addr string) {
+			defer wg.Done()
+			storeEntries, err := getLockWaitEntries(ctx, tikvClient, storeID, addr)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				s.Logger().Warn("failed to get lock wait entries", zap.Uint64("store", storeID), zap.Error(err))
+				lastErr = err
+				failed++
+				return
+			}
+			for _, entry := range storeEntries {
+				if len(filter) > 0 {
+					_, waiting := filter[entry.Txn]
+					_, waited := filter[entry.WaitForTxn]
+					if !waiting && !waited {
+						continue
+					}
+				}
+				entries = append(entries, entry)
+			}
+		}(store.StoreID(), store.GetAddr())
+	}
+	wg.Wait()
+	if len(stores) > 0 && failed == len(stores) {
+		return nil, lastErr
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Txn != entries[j].Txn {
+			return entries[i].Txn < entries[j].Txn
+		}
+		return entries[i].WaitForTxn < entries[j].WaitForTxn
+	})
+	return entries, nil
+}
+
+func getLockWaitEntries(ctx context.Context, tikvClient Client, storeID uint64, addr string) ([]LockWaitEntry, error) {
+	req := tikvrpc.NewRequest(tikvrpc.CmdLockWaitInfo, &kvrpcpb.GetLockWaitInfoRequest{})
+	resp, err := tikvClient.SendRequest(ctx, addr, req, client.DefaultTimeout(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Resp == nil {
+		return nil, errors.Errorf("empty lock wait info response from store %d", storeID)
+	}
+	lockWaitResp := resp.Resp.(*kvrpcpb.GetLockWaitInfoResponse)
+	if lockWaitResp.GetError() != "" {
+		return nil, errors.Errorf("failed to get lock wait info from store %d: %s", storeID, lockWaitResp.GetError())
+	}
+	entries := make([]LockWaitEntry, 0, len(lockWaitResp.GetEntries()))
+	for _, entry := range lockWaitResp.GetEntries() {
+		entries = append(entries, LockWaitEntry{
+			StoreID:          storeID,
+			Txn:              entry.GetTxn(),
+			WaitForTxn:       entry.GetWaitForTxn(),
+			Key:              entry.GetKey(),
+			KeyHash:          entry.GetKeyHash(),
+			WaitTime:         time.Duration(entry.GetWaitTime()) * time.Millisecond,
+			ResourceGroupTag: entry.GetResourceGroupTag(),
+		})
+	}
+	return entries, nil
+}
+
+// DumpLockWaitChains writes the wait chains of the lock wait entries to w in a human-readable format for debugging
+// stuck pessimistic transactions, one chain per line from a transaction nobody waits for, e.g.
+//
+//	10 -[store 1, key 6B31, 1.5s]-> 20 -[store 1, key 6B32, 800ms]-> 30
+//
+// A chain ending with "(deadlock)" goes back to a transaction in it. The format is not stable and shouldn't be parsed.
+func DumpLockWaitChains(w io.Writer, entries []LockWaitEntry) error {
+	waits := make(map[uint64][]LockWaitEntry)
+	waited := make(map[uint64]bool)
+	for _, entry := range entries {
+		waits[entry.Txn] = append(waits[entry.Txn], entry)
+		waited[entry.WaitForTxn] = true
+	}
+	txns := make([]uint64, 0, len(waits))
+	for txn := range waits {
+		txns = append(txns, txn)
+	}
+	sort.Slice(txns, func(i, j int) bool { return txns[i] < txns[j] })
+
+	bw := bufio.NewWriter(w)
+	visited := make(map[uint64]bool)
+	var walk func(txn uint64, chain []string, inChain map[uint64]bool)
+	walk = func(txn uint64, chain []string, inChain map[uint64]bool) {
+		visited[txn] = true
+		if inChain[txn] {
+			fmt.Fprintln(bw, strings.Join(chain, " ")+" (deadlock)")
+			return
+		}
+		next, ok := waits[txn]
+		if !ok {
+			fmt.Fprintln(bw, strings.Join(chain, " "))
+			return
+		}
+		inChain[txn] = true
+		for _, entry := range next {
+			edge := fmt.Sprintf("-[store %d, key %s, %s]-> %d", entry.StoreID, redact.Key(entry.Key), entry.WaitTime, entry.WaitForTxn)
+			walk(entry.WaitForTxn, append(chain[:len(chain):len(chain)], edge), inChain)
+		}
+		delete(inChain, txn)
+	}
+	for _, txn := range txns {
+		if !waited[txn] {
+			walk(txn, []string{fmt.Sprint(txn)}, make(map[uint64]bool))
+		}
+	}
+	// The transactions left are all in cycles.
+	for _, txn := range txns {
+		if !visited[txn] {
+			walk(txn, []string{fmt.Sprint(txn)}, make(map[uint64]bool))
+		}
+	}
+	return bw.Flush()
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // WithLogContext returns a copy of context that is associated with a logger.
@@ -30,3 +31,34 @@ func WithLogContext(ctx context.Context, logger *zap.Logger) context.Context {
 func SetLogContextKey(key interface{}) {
 	logutil.CtxLogKey = key
 }
+
+// Logger is the destination of the logs written by a KVStore itself, e.g. the ones of the safe ts updates, the region
+// splits and the recordings. Implement it to separate these logs of multiple stores or to control their verbosity,
+// instead of using the global logger. The region cache, the RPC client and the transactions of the store still log to
+// the global logger or the logger of the context.
+type Logger = logutil.Sink
+
+// WithLogger sets the logger of the store, see Logger for the logs written to it. They're tagged with the cluster ID and
+// keyspace ID of the store.
+func WithLogger(logger Logger) Option {
+	return func(store *KVStore) {
+		store.logger = logutil.NewSinkLogger(logger)
+	}
+}
+
+// SetLogLevel sets the minimum level of the logs written to the logger of the store at runtime. It can only make the
+// logs less verbose than the level of the underlying logger, and doesn't affect the global logger.
+func (s *KVStore) SetLogLevel(level zapcore.Level) {
+	s.logLevel.SetLevel(level)
+}
+
+// Logger returns the logger of the store, which is the global logger if it's not set by WithLogger.
+func (s *KVStore) Logger() *zap.Logger {
+	logger := s.logger
+	if logger == nil {
+		// Don't cache the global logger, as it may be replaced.
+		logger = logutil.BgLogger()
+	}
+	logger = logutil.WithLevel(logger, s.logLevel)
+	return logger.With(zap.Uint64("clusterID", s.clusterID), zap.Uint32("keyspaceID", uint32(s.getCodec().GetKeyspaceID())))
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util"
+)
+
+// splitRangeMaxBackoff is the max backoff of locating the regions of a range.
+const splitRangeMaxBackoff = 20000
+
+// RegionAlignedRange is a sub-range of a range that doesn't span over regions.
+type RegionAlignedRange struct {
+	kv.KeyRange
+	// Region is the region containing the sub-range.
+	Region RegionVerID
+	// LeaderStoreID and LeaderAddr are the hints of where the region leader is.
+	LeaderStoreID uint64
+	LeaderAddr    string
+}
+
+// SplitRangeByRegions splits [startKey, endKey) into sub-ranges aligned to the region boundaries, in key order. If
+// maxRangesPerRegion is greater than 1, the part of a region is further split by its buckets into at most
+// maxRangesPerRegion sub-ranges. An empty endKey means the range is unbounded.
+//
+// Regions dropped from the cache while locating are loaded again. The result may still be out of date when it's
+// used, so the requests sent by the sub-ranges should handle region errors as usual.
+func SplitRangeByRegions(ctx context.Context, regionCache *RegionCache, startKey, endKey []byte, maxRangesPerRegion int) ([]RegionAlignedRange, error) {
+	bo := retry.NewBackofferWithVars(ctx, splitRangeMaxBackoff, nil)
+	type leaderLocation struct {
+		*KeyLocation
+		rpcCtx *RPCContext
+	}
+	var ranges []RegionAlignedRange
+	err := util.SplitRangeByRegions(startKey, endKey, func(key []byte) (leaderLocation, []byte, error) {
+		for {
+			loc, err := regionCache.LocateKey(bo, key)
+			if err != nil {
+				return leaderLocation{}, nil, err
+			}
+			rpcCtx, err := regionCache.GetTiKVRPCContext(bo, loc.Region, kv.ReplicaReadLeader, 0)
+			if err != nil {
+				return leaderLocation{}, nil, err
+			}
+			if rpcCtx == nil {
+				err = bo.Backoff(retry.BoRegionMiss, errors.Errorf("region %d is stale", loc.Region.GetID()))
+				if err != nil {
+					return leaderLocation{}, nil, err
+				}
+				continue
+			}
+			return leaderLocation{KeyLocation: loc, rpcCtx: rpcCtx}, loc.EndKey, nil
+		}
+	}, func(loc leaderLocation, regionStart, regionEnd []byte) error {
+		points := splitPointsInRegion(loc.KeyLocation, regionStart, regionEnd, maxRangesPerRegion)
+		start := regionStart
+		for i := 0; i <= len(points); i++ {
+			end := regionEnd
+			if i < len(points) {
+				end = points[i]
+			}
+			ranges = append(ranges, RegionAlignedRange{
+				KeyRange:      kv.KeyRange{StartKey: start, EndKey: end},
+				Region:        loc.Region,
+				LeaderStoreID: loc.rpcCtx.Store.StoreID(),
+				LeaderAddr:    loc.rpcCtx.Addr,
+			})
+			start = end
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// splitPointsInRegion picks at most maxRanges-1 bucket keys inside (startKey, endKey) as the split points, spreading
+// them evenly among the buckets.
+func splitPointsInRegion(loc *KeyLocation, startKey, endKey []byte, maxRanges int) [][]byte {
+	if maxRanges <= 1 || loc.Buckets == nil {
+		return nil
+	}
+	var candidates [][]byte
+	for _, key := range loc.Buckets.GetKeys() {
+		if bytes.Compare(key, startKey) <= 0 || (len(endKey) > 0 && bytes.Compare(key, endKey) >= 0) || len(key) == 0 {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+	if len(candidates) < maxRanges {
+		return candidates
+	}
+	// Pick maxRanges-1 points out of the len(candidates)+1 pieces.
+	points := make([][]byte, 0, maxRanges-1)
+	pieces := len(candidates) + 1
+	for i := 1; i < maxRanges; i++ {
+		points = append(points, candidates[i*pieces/maxRanges-1])
+	}
+	return points
+}
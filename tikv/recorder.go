@@ -0,0 +1,120 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/client"
+	"go.uber.org/zap"
+)
+
+// RPCRecord is a request sent to TiKV and its response, recorded by KVStore.StartRecording.
+type RPCRecord = client.RPCRecord
+
+// ReplayClient is a Client that responds to the requests with the responses recorded by KVStore.StartRecording. It
+// can be used to create a KVStore, e.g. by NewTestTiKVStore, to reproduce the recorded anomalies in tests.
+type ReplayClient = client.ReplayClient
+
+// NewReplayClient creates a ReplayClient from the records in the file written by KVStore.StartRecording.
+func NewReplayClient(path string) (*ReplayClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return client.NewReplayClient(f)
+}
+
+type recordOptions struct {
+	keepValues bool
+}
+
+// RecordOption configures KVStore.StartRecording.
+type RecordOption func(*recordOptions)
+
+// WithRecordedValues keeps the values in the records, which are removed by default.
+func WithRecordedValues() RecordOption {
+	return func(o *recordOptions) {
+		o.keepValues = true
+	}
+}
+
+// StartRecording records the requests sent to TiKV and their responses to the file at path, until StopRecording is
+// called or the window elapses. A non-positive window means no limit. The values are removed from the records unless
+// WithRecordedValues is set, and the keys are redacted if redact log is enabled. Only one recording can be in
+// progress at a time.
+func (s *KVStore) StartRecording(path string, window time.Duration, opts ...RecordOption) error {
+	var o recordOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s.recording.Lock()
+	defer s.recording.Unlock()
+	if s.recording.recorder != nil {
+		return errors.New("recording is already in progress")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	recorder := client.NewRecorder(f, o.keepValues)
+	s.clientMu.Lock()
+	s.clientMu.client = recorder.WrapClient(s.clientMu.client)
+	s.clientMu.Unlock()
+	s.recording.recorder, s.recording.file = recorder, f
+	if window > 0 {
+		s.recording.timer = time.AfterFunc(window, func() {
+			// The timer may fire while the recording is being stopped, in which case it can't be stopped, so it only
+			// stops the recording started along with it rather than a later one.
+			if err := s.stopRecording(recorder); err != nil {
+				s.Logger().Warn("failed to stop recording requests", zap.String("path", path), zap.Error(err))
+			}
+		})
+	}
+	s.Logger().Info("start recording requests", zap.String("path", path), zap.Duration("window", window))
+	return nil
+}
+
+// StopRecording stops the recording started by StartRecording and closes the file. It's a no-op if there is no
+// recording in progress.
+func (s *KVStore) StopRecording() error {
+	return s.stopRecording(nil)
+}
+
+// stopRecording stops the recording made by expected, or the one in progress if expected is nil.
+func (s *KVStore) stopRecording(expected *client.Recorder) error {
+	s.recording.Lock()
+	defer s.recording.Unlock()
+	recorder := s.recording.recorder
+	if recorder == nil || (expected != nil && recorder != expected) {
+		return nil
+	}
+	if s.recording.timer != nil {
+		s.recording.timer.Stop()
+	}
+	s.clientMu.Lock()
+	s.clientMu.client = recorder.Unwrap(s.clientMu.client)
+	s.clientMu.Unlock()
+	err := recorder.Close()
+	if closeErr := s.recording.file.Close(); err == nil {
+		err = errors.WithStack(closeErr)
+	}
+	s.recording.recorder, s.recording.file, s.recording.timer = nil, nil, nil
+	s.Logger().Info("stop recording requests", zap.Error(err))
+	return err
+}
@@ -60,6 +60,18 @@ type RegionVerID = locate.RegionVerID
 // RegionCache caches Regions loaded from PD.
 type RegionCache = locate.RegionCache
 
+// Feature is a capability of TiKV that some request fields or commands depend on.
+type Feature = locate.Feature
+
+const (
+	// FeatureBucketsVersionHint means TiKV checks the buckets version carried by requests.
+	FeatureBucketsVersionHint = locate.FeatureBucketsVersionHint
+	// FeatureHealthFeedback means TiKV feeds back its health status to the client.
+	FeatureHealthFeedback = locate.FeatureHealthFeedback
+	// FeatureFlashback means TiKV supports flashing back regions to a version.
+	FeatureFlashback = locate.FeatureFlashback
+)
+
 // KeyLocation is the region and range that a key is located.
 type KeyLocation = locate.KeyLocation
 
@@ -83,6 +95,15 @@ type RPCCancellerCtxKey = locate.RPCCancellerCtxKey
 // simply return the error to caller.
 type RegionRequestSender = locate.RegionRequestSender
 
+// SendReqPhase is a phase of the retry state machine of RegionRequestSender.
+type SendReqPhase = locate.SendReqPhase
+
+// SendReqTransition describes a transition of the retry state machine of RegionRequestSender.
+type SendReqTransition = locate.SendReqTransition
+
+// SendReqTransitionHook is called on every transition of the retry state machine of RegionRequestSender.
+type SendReqTransitionHook = locate.SendReqTransitionHook
+
 // StoreSelectorOption configures storeSelectorOp.
 type StoreSelectorOption = locate.StoreSelectorOption
 
@@ -187,6 +208,11 @@ func WithMatchStores(stores []uint64) StoreSelectorOption {
 	return locate.WithMatchStores(stores)
 }
 
+// WithLearnerReadFallback sets where ReplicaReadLearner requests go when no learner replica is available.
+func WithLearnerReadFallback(fallback kv.LearnerReadFallback) StoreSelectorOption {
+	return locate.WithLearnerReadFallback(fallback)
+}
+
 // NewRegionRequestRuntimeStats returns a new RegionRequestRuntimeStats.
 func NewRegionRequestRuntimeStats() *RegionRequestRuntimeStats {
 	return locate.NewRegionRequestRuntimeStats()
@@ -213,6 +239,26 @@ func SetStoreLivenessTimeout(t time.Duration) {
 	locate.SetStoreLivenessTimeout(t)
 }
 
+// SlowRPCTraceConfig is the config of a SlowRPCTracer.
+type SlowRPCTraceConfig = locate.SlowRPCTraceConfig
+
+// SlowRPCTracer writes the timelines of sampled slow requests to a rotating file.
+type SlowRPCTracer = locate.SlowRPCTracer
+
+// RPCTrace is the timeline of a request written by SlowRPCTracer.
+type RPCTrace = locate.RPCTrace
+
+// NewSlowRPCTracer creates a SlowRPCTracer.
+func NewSlowRPCTracer(cfg SlowRPCTraceConfig) (*SlowRPCTracer, error) {
+	return locate.NewSlowRPCTracer(cfg)
+}
+
+// SetSlowRPCTracer sets the tracer of the slow requests of the process, nil disables tracing. It returns the
+// previous tracer, which should be closed by the caller.
+func SetSlowRPCTracer(t *SlowRPCTracer) *SlowRPCTracer {
+	return locate.SetSlowRPCTracer(t)
+}
+
 // NewRegionCache creates a RegionCache.
 func NewRegionCache(pdClient pd.Client) *locate.RegionCache {
 	return locate.NewRegionCache(pdClient)
@@ -0,0 +1,164 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// regionInfoMaxBackoff is the max backoff of loading the regions of RegionInfoReader.
+const regionInfoMaxBackoff = 20000
+
+// RegionInfo is the metadata of a region. It's a copy of the region cache, so it doesn't change when the region does.
+type RegionInfo struct {
+	ID uint64
+	// StartKey and EndKey are the key range of the region, an empty EndKey means unbounded.
+	StartKey []byte
+	EndKey   []byte
+	// Epoch is the epoch of the region.
+	Epoch *metapb.RegionEpoch
+	// Peers are the peers of the region.
+	Peers []*metapb.Peer
+	// LeaderPeerID and LeaderStoreID are the leader known by the client, 0 if it's unknown.
+	LeaderPeerID  uint64
+	LeaderStoreID uint64
+}
+
+// StoreInfo is the metadata of a store. Like RegionInfo, it's a copy of the region cache.
+type StoreInfo struct {
+	ID       uint64
+	Addr     string
+	PeerAddr string
+	Type     tikvrpc.EndpointType
+	Labels   []*metapb.StoreLabel
+	// Reachable indicates whether the store is reachable according to its last health check.
+	Reachable bool
+}
+
+// RegionInfoReader reads the metadata of the regions and stores from the region cache of a KVStore, loading the
+// regions from PD if they aren't cached. It's a stable read-only API over RegionCache, whose methods may change
+// between versions.
+type RegionInfoReader struct {
+	regionCache *locate.RegionCache
+}
+
+// RegionInfoReader returns the RegionInfoReader of the store.
+func (s *KVStore) RegionInfoReader() *RegionInfoReader {
+	return &RegionInfoReader{regionCache: s.regionCache}
+}
+
+// LocateKey returns the region containing the key.
+func (r *RegionInfoReader) LocateKey(ctx context.Context, key []byte) (*RegionInfo, error) {
+	bo := retry.NewBackofferWithVars(ctx, regionInfoMaxBackoff, nil)
+	loc, err := r.regionCache.LocateKey(bo, key)
+	if err != nil {
+		return nil, err
+	}
+	return r.regionInfo(loc)
+}
+
+// GetRegionByID returns the region of the ID.
+func (r *RegionInfoReader) GetRegionByID(ctx context.Context, regionID uint64) (*RegionInfo, error) {
+	bo := retry.NewBackofferWithVars(ctx, regionInfoMaxBackoff, nil)
+	loc, err := r.regionCache.LocateRegionByID(bo, regionID)
+	if err != nil {
+		return nil, err
+	}
+	return r.regionInfo(loc)
+}
+
+// ScanRegions returns the regions overlapping [startKey, endKey) in order, an empty endKey means unbounded.
+func (r *RegionInfoReader) ScanRegions(ctx context.Context, startKey, endKey []byte) ([]*RegionInfo, error) {
+	bo := retry.NewBackofferWithVars(ctx, regionInfoMaxBackoff, nil)
+	regions, err := r.regionCache.LoadRegionsInKeyRange(bo, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*RegionInfo, 0, len(regions))
+	for _, region := range regions {
+		infos = append(infos, newRegionInfo(region))
+	}
+	return infos, nil
+}
+
+func (r *RegionInfoReader) regionInfo(loc *locate.KeyLocation) (*RegionInfo, error) {
+	region := r.regionCache.GetCachedRegionWithRLock(loc.Region)
+	if region == nil {
+		return nil, errors.Errorf("region %d is evicted from the cache", loc.Region.GetID())
+	}
+	return newRegionInfo(region), nil
+}
+
+func newRegionInfo(region *locate.Region) *RegionInfo {
+	meta := proto.Clone(region.GetMeta()).(*metapb.Region)
+	return &RegionInfo{
+		ID:            meta.GetId(),
+		StartKey:      meta.GetStartKey(),
+		EndKey:        meta.GetEndKey(),
+		Epoch:         meta.GetRegionEpoch(),
+		Peers:         meta.GetPeers(),
+		LeaderPeerID:  region.GetLeaderPeerID(),
+		LeaderStoreID: region.GetLeaderStoreID(),
+	}
+}
+
+// GetStore returns the store of the ID, or false if it isn't cached.
+func (r *RegionInfoReader) GetStore(storeID uint64) (*StoreInfo, bool) {
+	for _, store := range r.regionCache.GetAllStores() {
+		if store.StoreID() == storeID {
+			return newStoreInfo(store), true
+		}
+	}
+	return nil, false
+}
+
+// GetAllStores returns the TiKV and TiFlash stores cached.
+func (r *RegionInfoReader) GetAllStores() []*StoreInfo {
+	stores := r.regionCache.GetAllStores()
+	infos := make([]*StoreInfo, 0, len(stores))
+	for _, store := range stores {
+		infos = append(infos, newStoreInfo(store))
+	}
+	return infos
+}
+
+func newStoreInfo(store *locate.Store) *StoreInfo {
+	return &StoreInfo{
+		ID:        store.StoreID(),
+		Addr:      store.GetAddr(),
+		PeerAddr:  store.GetPeerAddr(),
+		Type:      store.StoreType(),
+		Labels:    cloneStoreLabels(store.GetLabels()),
+		Reachable: store.IsReachable(),
+	}
+}
+
+func cloneStoreLabels(labels []*metapb.StoreLabel) []*metapb.StoreLabel {
+	if labels == nil {
+		return nil
+	}
+	res := make([]*metapb.StoreLabel, 0, len(labels))
+	for _, label := range labels {
+		res = append(res, proto.Clone(label).(*metapb.StoreLabel))
+	}
+	return res
+}
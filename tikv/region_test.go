@@ -0,0 +1,358 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	pd "github.com/tikv/pd/client"
+	"github.com/tikv/pd/client/clients/gc"
+	"github.com/tikv/pd/client/constants"
+	pdhttp "github.com/tikv/pd/client/http"
+	"github.com/tikv/pd/client/pkg/caller"
+)
+
+func TestRegionRequestSimulator(t *testing.T) {
+	sim, err := testutils.NewRegionRequestSimulator(3)
+	require.Nil(t, err)
+	defer sim.Close()
+
+	leader := sim.StoreIDs[0]
+	newLeader := sim.StoreIDs[1]
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("k"), Version: 1})
+	result := sim.Send(retry.NewBackofferWithVars(context.Background(), 5000, nil), req, time.Second)
+	require.Nil(t, result.Err)
+	require.Equal(t, []uint64{leader}, result.Attempts)
+
+	// The leader moves to the second store and then is busy once.
+	sim.Cluster.ChangeLeader(sim.RegionID, sim.PeerIDs[1])
+	sim.Script(
+		testutils.SimulatedReply{StoreID: leader, RegionErr: &errorpb.Error{NotLeader: &errorpb.NotLeader{
+			RegionId: sim.RegionID,
+			Leader:   &metapb.Peer{Id: sim.PeerIDs[1], StoreId: newLeader},
+		}}},
+		testutils.SimulatedReply{StoreID: newLeader, RegionErr: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}},
+	)
+	req = tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("k"), Version: 1})
+	result = sim.Send(retry.NewBackofferWithVars(context.Background(), 5000, nil), req, time.Second)
+	require.Nil(t, result.Err)
+	regionErr, err := result.Resp.GetRegionError()
+	require.Nil(t, err)
+	require.Nil(t, regionErr)
+	require.Equal(t, []uint64{leader, newLeader, newLeader}, result.Attempts)
+	last := result.Transitions[len(result.Transitions)-1]
+	require.Equal(t, "Done", last.To.String())
+	require.Equal(t, newLeader, last.StoreID)
+	require.Equal(t, 3, last.SendTimes)
+}
+
+type keyspacePDClient struct {
+	pd.Client
+	sync.Mutex
+	leaderURL string
+	keyspaces []*keyspacepb.KeyspaceMeta
+}
+
+func (c *keyspacePDClient) GetLeaderURL() string { return c.leaderURL }
+
+func (c *keyspacePDClient) LoadKeyspace(ctx context.Context, name string) (*keyspacepb.KeyspaceMeta, error) {
+	c.Lock()
+	defer c.Unlock()
+	for _, meta := range c.keyspaces {
+		if meta.Name == name {
+			return meta, nil
+		}
+	}
+	return nil, errors.New("keyspace not found")
+}
+
+func (c *keyspacePDClient) GetAllKeyspaces(ctx context.Context, startID uint32, limit uint32) ([]*keyspacepb.KeyspaceMeta, error) {
+	c.Lock()
+	defer c.Unlock()
+	var res []*keyspacepb.KeyspaceMeta
+	for _, meta := range c.keyspaces {
+		if meta.Id >= startID && uint32(len(res)) < limit {
+			res = append(res, meta)
+		}
+	}
+	return res, nil
+}
+
+func TestKeyspaceHelpers(t *testing.T) {
+	pdClient := &keyspacePDClient{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string            `json:"name"`
+			Config map[string]string `json:"config"`
+		}
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/pd/api/v2/keyspaces", r.URL.Path)
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		pdClient.Lock()
+		defer pdClient.Unlock()
+		for _, meta := range pdClient.keyspaces {
+			if meta.Name == req.Name {
+				http.Error(w, "keyspace already exists", http.StatusInternalServerError)
+				return
+			}
+		}
+		pdClient.keyspaces = append(pdClient.keyspaces, &keyspacepb.KeyspaceMeta{
+			Id:     uint32(len(pdClient.keyspaces) + 1),
+			Name:   req.Name,
+			State:  keyspacepb.KeyspaceState_ENABLED,
+			Config: req.Config,
+		})
+	}))
+	defer server.Close()
+	pdClient.leaderURL = server.URL
+
+	ctx := context.Background()
+	for i := 0; i < keyspaceListBatchSize+1; i++ {
+		name := fmt.Sprintf("ks%d", i)
+		meta, err := CreateKeyspace(ctx, pdClient, name, map[string]string{"k": name})
+		require.Nil(t, err)
+		require.Equal(t, uint32(i+1), meta.Id)
+		require.Equal(t, name, meta.Config["k"])
+	}
+	_, err := CreateKeyspace(ctx, pdClient, "ks0", nil)
+	require.ErrorContains(t, err, "keyspace already exists")
+
+	meta, err := DescribeKeyspace(ctx, pdClient, "ks1")
+	require.Nil(t, err)
+	require.Equal(t, uint32(2), meta.Id)
+	_, err = DescribeKeyspace(ctx, pdClient, "unknown")
+	require.NotNil(t, err)
+
+	keyspaces, err := ListKeyspaces(ctx, pdClient)
+	require.Nil(t, err)
+	require.Len(t, keyspaces, keyspaceListBatchSize+1)
+	for i, meta := range keyspaces {
+		require.Equal(t, uint32(i+1), meta.Id)
+	}
+}
+
+type unsafeDestroyMockClient struct {
+	Client
+	sync.Mutex
+	ranges []kv.KeyRange
+}
+
+func (c *unsafeDestroyMockClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type != tikvrpc.CmdUnsafeDestroyRange {
+		return c.Client.SendRequest(ctx, addr, req, timeout)
+	}
+	c.Lock()
+	defer c.Unlock()
+	r := req.UnsafeDestroyRange()
+	c.ranges = append(c.ranges, kv.KeyRange{StartKey: r.StartKey, EndKey: r.EndKey})
+	return &tikvrpc.Response{Resp: &kvrpcpb.UnsafeDestroyRangeResponse{}}, nil
+}
+
+// keyspaceGCPDClient loads the GC states of the null keyspace for every keyspace, which isn't supported by the mock PD.
+type keyspaceGCPDClient struct {
+	*keyspacePDClient
+}
+
+func (c keyspaceGCPDClient) WithCallerComponent(caller.Component) pd.Client { return c }
+
+func (c keyspaceGCPDClient) GetGCStatesClient(keyspaceID uint32) gc.GCStatesClient {
+	return c.Client.GetGCStatesClient(constants.NullKeyspaceID)
+}
+
+func TestDeleteKeyspaceData(t *testing.T) {
+	ranges, err := GetKeyspaceRanges(1)
+	require.Nil(t, err)
+	require.Equal(t, kv.KeyRange{StartKey: []byte{'r', 0, 0, 1}, EndKey: []byte{'r', 0, 0, 2}}, ranges.Raw)
+	require.Equal(t, kv.KeyRange{StartKey: []byte{'x', 0, 0, 1}, EndKey: []byte{'x', 0, 0, 2}}, ranges.Txn)
+	_, err = GetKeyspaceRanges(1 << 24)
+	require.NotNil(t, err)
+
+	mockClient, cluster, mockPD, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	testutils.BootstrapWithSingleStore(cluster)
+	meta := keyspacepb.KeyspaceMeta{Id: 1, Name: "ks1", State: keyspacepb.KeyspaceState_DISABLED}
+	client := &unsafeDestroyMockClient{Client: mockClient}
+	store, err := NewTestKeyspaceTiKVStore(client, keyspaceGCPDClient{&keyspacePDClient{Client: mockPD, keyspaces: []*keyspacepb.KeyspaceMeta{&meta}}}, nil, nil, 0, meta)
+	require.Nil(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	txn, err := store.Begin()
+	require.Nil(t, err)
+	require.Nil(t, txn.Set([]byte("k"), []byte("v")))
+	require.Nil(t, txn.Commit(ctx))
+
+	require.ErrorContains(t, store.DeleteKeyspaceData(ctx, DeleteKeyspaceOptions{}), "must be confirmed")
+	var steps []KeyspaceDeletionStep
+	confirmErr := errors.New("keyspace is enabled")
+	confirm := func(ctx context.Context, step KeyspaceDeletionStep) error {
+		steps = append(steps, step)
+		if meta.State != keyspacepb.KeyspaceState_DISABLED {
+			return confirmErr
+		}
+		return nil
+	}
+	meta.State = keyspacepb.KeyspaceState_ENABLED
+	require.Equal(t, confirmErr, store.DeleteKeyspaceData(ctx, DeleteKeyspaceOptions{UnsafeDestroy: true, Confirm: confirm}))
+	require.Len(t, steps, 1)
+	snapshot := store.GetSnapshot(math.MaxUint64)
+	val, err := snapshot.Get(ctx, []byte("k"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("v"), val)
+
+	meta.State = keyspacepb.KeyspaceState_DISABLED
+	steps = nil
+	require.Nil(t, store.DeleteKeyspaceData(ctx, DeleteKeyspaceOptions{UnsafeDestroy: true, Confirm: confirm}))
+	require.Equal(t, []KeyspaceDeletionStep{
+		{KeyspaceID: 1, Op: KeyspaceDeleteRange, Mode: ModeTxn, Range: ranges.Txn},
+		{KeyspaceID: 1, Op: KeyspaceUnsafeDestroyRange, Mode: ModeTxn, Range: ranges.Txn},
+		{KeyspaceID: 1, Op: KeyspaceUnsafeDestroyRange, Mode: ModeRaw, Range: ranges.Raw},
+	}, steps)
+	_, err = snapshot.Get(ctx, []byte("k"))
+	require.True(t, tikverr.IsErrNotFound(err))
+	require.Equal(t, []kv.KeyRange{ranges.Txn, ranges.Raw}, client.ranges)
+
+	// A store of API v1 isn't bound to any keyspace.
+	mockClient, _, mockPD, err = testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	store1, err := NewTestTiKVStore(mockClient, mockPD, nil, nil, 0)
+	require.Nil(t, err)
+	defer store1.Close()
+	require.ErrorContains(t, store1.DeleteKeyspaceData(ctx, DeleteKeyspaceOptions{Confirm: confirm}), "API v2")
+}
+
+func TestRegionInfoReader(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	storeIDs, peerIDs, regionID, _ := testutils.BootstrapWithMultiStores(cluster, 2)
+	newRegionID, newPeerIDs := cluster.AllocID(), cluster.AllocIDs(2)
+	cluster.Split(regionID, newRegionID, []byte("m"), newPeerIDs, newPeerIDs[1])
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	reader := store.RegionInfoReader()
+	ctx := context.Background()
+	region, err := reader.LocateKey(ctx, []byte("a"))
+	require.Nil(t, err)
+	require.Equal(t, regionID, region.ID)
+	require.Empty(t, region.StartKey)
+	require.Equal(t, []byte("m"), region.EndKey)
+	require.Len(t, region.Peers, 2)
+	require.Equal(t, peerIDs[0], region.LeaderPeerID)
+	require.Equal(t, storeIDs[0], region.LeaderStoreID)
+	// The region is a copy, so changing it doesn't change the cache.
+	region.EndKey[0] = 'z'
+	region.Peers[0].Id = 0
+	region, err = reader.LocateKey(ctx, []byte("a"))
+	require.Nil(t, err)
+	require.Equal(t, []byte("m"), region.EndKey)
+	require.Equal(t, peerIDs[0], region.Peers[0].Id)
+
+	region, err = reader.GetRegionByID(ctx, newRegionID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("m"), region.StartKey)
+	require.Equal(t, newPeerIDs[1], region.LeaderPeerID)
+	require.Equal(t, storeIDs[1], region.LeaderStoreID)
+	require.NotNil(t, region.Epoch)
+
+	regions, err := reader.ScanRegions(ctx, nil, nil)
+	require.Nil(t, err)
+	require.Len(t, regions, 2)
+	require.Equal(t, []uint64{regionID, newRegionID}, []uint64{regions[0].ID, regions[1].ID})
+
+	require.Len(t, reader.GetAllStores(), 2)
+	storeInfo, ok := reader.GetStore(storeIDs[1])
+	require.True(t, ok)
+	require.Equal(t, storeIDs[1], storeInfo.ID)
+	require.Equal(t, cluster.GetStore(storeIDs[1]).GetAddress(), storeInfo.Addr)
+	require.Equal(t, tikvrpc.TiKV, storeInfo.Type)
+	_, ok = reader.GetStore(0)
+	require.False(t, ok)
+}
+
+type operatorPDHTTPClient struct {
+	pdhttp.Client
+	createOperator func(input map[string]any)
+}
+
+func (c *operatorPDHTTPClient) CreateOperators(ctx context.Context, input map[string]any) error {
+	c.createOperator(input)
+	return nil
+}
+
+func TestTransferLeader(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	storeIDs, peerIDs, regionID, _ := testutils.BootstrapWithMultiStores(cluster, 2)
+	var operators []map[string]any
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NotNil(t, store.TransferLeader(ctx, regionID, storeIDs[1]))
+	// The region isn't cached.
+	require.False(t, store.TransferLeaderHint(regionID, storeIDs[1]))
+	reader := store.RegionInfoReader()
+	region, err := reader.LocateKey(ctx, []byte("k"))
+	require.Nil(t, err)
+	require.Equal(t, storeIDs[0], region.LeaderStoreID)
+	require.False(t, store.TransferLeaderHint(regionID, 0))
+
+	store.pdHttpClient = &operatorPDHTTPClient{
+		Client: pdhttp.NewClientWithServiceDiscovery("test", nil),
+		createOperator: func(input map[string]any) {
+			operators = append(operators, input)
+			cluster.ChangeLeader(regionID, peerIDs[1])
+		},
+	}
+	require.Nil(t, store.TransferLeader(ctx, regionID, storeIDs[1]))
+	require.Equal(t, []map[string]any{{"name": "transfer-leader", "region_id": regionID, "to_store_id": storeIDs[1]}}, operators)
+	// The cache isn't updated until the leader is known to be transferred.
+	region, err = reader.GetRegionByID(ctx, regionID)
+	require.Nil(t, err)
+	require.Equal(t, storeIDs[0], region.LeaderStoreID)
+	require.True(t, store.TransferLeaderHint(regionID, storeIDs[1]))
+	region, err = reader.GetRegionByID(ctx, regionID)
+	require.Nil(t, err)
+	require.Equal(t, storeIDs[1], region.LeaderStoreID)
+
+	// The leader transferred externally is hinted.
+	cluster.ChangeLeader(regionID, peerIDs[0])
+	require.True(t, store.TransferLeaderHint(regionID, storeIDs[0]))
+	region, err = reader.GetRegionByID(ctx, regionID)
+	require.Nil(t, err)
+	require.Equal(t, storeIDs[0], region.LeaderStoreID)
+}
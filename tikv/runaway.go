@@ -0,0 +1,187 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/util"
+)
+
+const defaultRunawayCheckInterval = 100 * time.Millisecond
+
+// The thresholds exceeded by the runaway operations.
+const (
+	RunawayReasonDuration = "duration"
+	RunawayReasonRU       = "ru"
+)
+
+// RunawayAction is the action taken on a runaway operation.
+type RunawayAction int
+
+const (
+	// RunawayActionNone lets the runaway operation go on.
+	RunawayActionNone RunawayAction = iota
+	// RunawayActionCancel cancels the context of the runaway operation with an ErrRunawayOperation cause.
+	RunawayActionCancel
+)
+
+func (a RunawayAction) String() string {
+	if a == RunawayActionCancel {
+		return "cancel"
+	}
+	return "none"
+}
+
+// RunawayEvent describes an operation exceeding a threshold of the RunawayWatchdog.
+type RunawayEvent struct {
+	Name string
+	// Reason is the threshold exceeded, RunawayReasonDuration or RunawayReasonRU.
+	Reason  string
+	Start   time.Time
+	Elapsed time.Duration
+	// RU is the read and write RU consumed so far, which is only collected if the resource control is enabled.
+	RU float64
+}
+
+// RunawayConfig is the config of a RunawayWatchdog.
+type RunawayConfig struct {
+	// MaxDuration is the threshold of how long an operation runs, 0 means no threshold.
+	MaxDuration time.Duration
+	// MaxRU is the threshold of the RU consumed by an operation, 0 means no threshold.
+	MaxRU float64
+	// CheckInterval is the interval of checking the operations, 100ms by default.
+	CheckInterval time.Duration
+	// OnRunaway is called once for each operation exceeding a threshold, and the operation is canceled if it returns
+	// RunawayActionCancel. Nil means the runaway operations are only counted by the metrics. It's called by the
+	// watchdog goroutine, so it shouldn't block.
+	OnRunaway func(RunawayEvent) RunawayAction
+}
+
+type runawayOp struct {
+	name    string
+	start   time.Time
+	ru      *util.RUDetails
+	cancel  context.CancelCauseFunc
+	flagged bool
+}
+
+// RunawayWatchdog flags the operations, e.g. scans, exceeding the time or RU thresholds, and optionally cancels them,
+// which mirrors the runaway query handling of TiDB for the applications using the client directly.
+type RunawayWatchdog struct {
+	cfg RunawayConfig
+
+	mu  sync.Mutex
+	ops map[*runawayOp]struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunawayWatchdog creates a RunawayWatchdog and starts checking the operations tracked by it. Close must be called
+// to stop it.
+func NewRunawayWatchdog(cfg RunawayConfig) *RunawayWatchdog {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultRunawayCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &RunawayWatchdog{cfg: cfg, ops: make(map[*runawayOp]struct{}), cancel: cancel}
+	w.wg.Add(1)
+	go w.run(ctx)
+	return w
+}
+
+// Track starts tracking an operation, which should be run with the returned context. The RU of the requests sent with
+// the context is collected in the util.RUDetails of ctx, which is attached to it if absent. The returned function must
+// be called when the operation finishes.
+func (w *RunawayWatchdog) Track(ctx context.Context, name string) (context.Context, func()) {
+	ru, _ := ctx.Value(util.RUDetailsCtxKey).(*util.RUDetails)
+	if ru == nil {
+		ru = util.NewRUDetails()
+		ctx = context.WithValue(ctx, util.RUDetailsCtxKey, ru)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	op := &runawayOp{name: name, start: time.Now(), ru: ru, cancel: cancel}
+	w.mu.Lock()
+	w.ops[op] = struct{}{}
+	w.mu.Unlock()
+	return ctx, func() {
+		w.mu.Lock()
+		delete(w.ops, op)
+		w.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Close stops the watchdog. The operations tracked are no longer checked.
+func (w *RunawayWatchdog) Close() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *RunawayWatchdog) run(ctx context.Context) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *RunawayWatchdog) check(now time.Time) {
+	var events []RunawayEvent
+	var flagged []*runawayOp
+	w.mu.Lock()
+	for op := range w.ops {
+		if op.flagged {
+			continue
+		}
+		event := RunawayEvent{Name: op.name, Start: op.start, Elapsed: now.Sub(op.start), RU: op.ru.RRU() + op.ru.WRU()}
+		if w.cfg.MaxDuration > 0 && event.Elapsed >= w.cfg.MaxDuration {
+			event.Reason = RunawayReasonDuration
+		} else if w.cfg.MaxRU > 0 && event.RU >= w.cfg.MaxRU {
+			event.Reason = RunawayReasonRU
+		} else {
+			continue
+		}
+		op.flagged = true
+		events = append(events, event)
+		flagged = append(flagged, op)
+	}
+	w.mu.Unlock()
+
+	// Call the callback without holding the lock, so it can track new operations.
+	for i, event := range events {
+		action := RunawayActionNone
+		if w.cfg.OnRunaway != nil {
+			action = w.cfg.OnRunaway(event)
+		}
+		metrics.TiKVRunawayOperationCounter.WithLabelValues(event.Reason, action.String()).Inc()
+		if action == RunawayActionCancel {
+			flagged[i].cancel(&tikverr.ErrRunawayOperation{
+				Name: event.Name, Reason: event.Reason, Elapsed: event.Elapsed, RU: event.RU,
+			})
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+	"go.uber.org/zap"
+)
+
+// snapshotBarrierSeq makes the IDs of the GC barriers of snapshots unique in the process.
+var snapshotBarrierSeq atomic.Uint64
+
+// minSnapshotBarrierTTL is the min TTL of the GC barriers of snapshots, as PD keeps the TTL in seconds.
+const minSnapshotBarrierTTL = time.Second
+
+type snapshotAtOptions struct {
+	barrierTTL time.Duration
+}
+
+// SnapshotAtOpt is the option of GetSnapshotAt.
+type SnapshotAtOpt func(*snapshotAtOptions)
+
+// WithSnapshotGCBarrier protects the snapshot from GC until it's released, by a GC barrier at its ts. The barrier is
+// kept alive with the TTL, so it expires in about ttl if the process exits without releasing the snapshot. The TTL
+// should be at least 1s.
+func WithSnapshotGCBarrier(ttl time.Duration) SnapshotAtOpt {
+	return func(opts *snapshotAtOptions) {
+		opts.barrierTTL = ttl
+	}
+}
+
+// TimeTravelSnapshot is a snapshot at an explicit ts returned by GetSnapshotAt. It should be released after use if it's
+// protected by a GC barrier.
+type TimeTravelSnapshot struct {
+	*txnsnapshot.KVSnapshot
+
+	store     *KVStore
+	barrierID string
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	release   sync.Once
+	// releaseErr is the error of deleting the GC barrier, which is returned by every call of Release.
+	releaseErr error
+}
+
+// GetSnapshotAt gets a snapshot to read the data at ts, which is usually in the past. Unlike GetSnapshot, it fails
+// with ErrSnapshotExpired if ts is behind the txn safe point, as the data may have been garbage collected.
+//
+// Without WithSnapshotGCBarrier, the check only guarantees the snapshot is readable at the moment, and reading it
+// fails once GC advances beyond ts.
+func (s *KVStore) GetSnapshotAt(ctx context.Context, ts uint64, opts ...SnapshotAtOpt) (*TimeTravelSnapshot, error) {
+	var options snapshotAtOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.barrierTTL > 0 && options.barrierTTL < minSnapshotBarrierTTL {
+		return nil, errors.Errorf("the TTL of the GC barrier of the snapshot should be at least %s, but got %s",
+			minSnapshotBarrierTTL, options.barrierTTL)
+	}
+	if err := s.checkSnapshotTS(ctx, ts); err != nil {
+		return nil, err
+	}
+
+	snapshot := &TimeTravelSnapshot{
+		KVSnapshot: s.GetSnapshot(ts),
+		store:      s,
+	}
+	if options.barrierTTL <= 0 {
+		return snapshot, nil
+	}
+
+	barrierID := fmt.Sprintf("snapshot-%s-%d-%d", s.uuid, ts, snapshotBarrierSeq.Add(1))
+	if _, err := s.SetGCBarrier(ctx, barrierID, ts, options.barrierTTL); err != nil {
+		// The txn safe point may have advanced after the check.
+		if checkErr := s.checkSnapshotTS(ctx, ts); checkErr != nil {
+			return nil, checkErr
+		}
+		return nil, err
+	}
+	snapshot.barrierID = barrierID
+	keepAliveCtx, cancel := context.WithCancel(s.ctx)
+	snapshot.cancel = cancel
+	snapshot.wg.Add(1)
+	go func() {
+		defer snapshot.wg.Done()
+		snapshot.keepAlive(keepAliveCtx, ts, options.barrierTTL)
+	}()
+	return snapshot, nil
+}
+
+func (s *KVStore) checkSnapshotTS(ctx context.Context, ts uint64) error {
+	state, err := s.GetGCState(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if ts < state.TxnSafePoint {
+		return &tikverr.ErrSnapshotExpired{SnapshotTS: ts, TxnSafePoint: state.TxnSafePoint}
+	}
+	return nil
+}
+
+// keepAlive renews the GC barrier of the snapshot before it expires.
+func (s *TimeTravelSnapshot) keepAlive(ctx context.Context, ts uint64, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.store.SetGCBarrier(ctx, s.barrierID, ts, ttl); err != nil {
+				s.store.Logger().Warn("failed to renew the GC barrier of the snapshot",
+					zap.String("barrierID", s.barrierID), zap.Uint64("ts", ts), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Release deletes the GC barrier protecting the snapshot, if any. The snapshot can still be read after it's released,
+// but it may fail once GC advances beyond its ts. The barrier is only deleted once, so the later calls return the
+// error of the first one, and a barrier failed to be deleted expires after its TTL as it's no longer kept alive.
+func (s *TimeTravelSnapshot) Release(ctx context.Context) error {
+	if s.barrierID == "" {
+		return nil
+	}
+	s.release.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		if _, err := s.store.DeleteGCBarrier(ctx, s.barrierID); err != nil {
+			s.store.Logger().Warn("failed to delete the GC barrier of the snapshot",
+				zap.String("barrierID", s.barrierID), zap.Error(err))
+			s.releaseErr = errors.WithStack(err)
+		}
+	})
+	return s.releaseErr
+}
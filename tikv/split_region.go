@@ -83,7 +83,7 @@ func (s *KVStore) splitBatchRegionsReq(bo *Backoffer, keys [][]byte, scatter boo
 	}
 	// The first time it enters this function.
 	if bo.GetTotalSleep() == 0 {
-		logutil.BgLogger().Info("split batch regions request",
+		s.Logger().Info("split batch regions request",
 			zap.Int("split key count", len(keys)),
 			zap.Int("batch count", len(batches)),
 			zap.Uint64("first batch, region ID", batches[0].RegionID.GetID()),
@@ -120,7 +120,7 @@ func (s *KVStore) splitBatchRegionsReq(bo *Backoffer, keys [][]byte, scatter boo
 	for range batches {
 		batchResp := <-ch
 		if batchResp.Error != nil {
-			logutil.BgLogger().Info("batch split regions failed", zap.Error(batchResp.Error))
+			s.Logger().Info("batch split regions failed", zap.Error(batchResp.Error))
 			if err == nil {
 				err = batchResp.Error
 			}
@@ -154,7 +154,7 @@ func (s *KVStore) batchSendSingleRegion(bo *Backoffer, batch kvrpc.Batch, scatte
 	})
 
 	sender := locate.NewRegionRequestSender(s.regionCache, s.GetTiKVClient(), s.oracle)
-	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.ReadTimeoutShort)
+	resp, _, err := sender.SendReq(bo, req, batch.RegionID, client.DefaultTimeout(req.Type))
 
 	batchResp := kvrpc.BatchResult{Response: resp}
 	if err != nil {
@@ -189,7 +189,7 @@ func (s *KVStore) batchSendSingleRegion(bo *Backoffer, batch kvrpc.Batch, scatte
 	if len(spResp.Regions) > 0 {
 		newRegionLeft = logutil.Hex(spResp.Regions[0]).String()
 	}
-	logutil.BgLogger().Info("batch split regions complete",
+	s.Logger().Info("batch split regions complete",
 		zap.Uint64("batch region ID", batch.RegionID.GetID()),
 		zap.String("first at", redact.Key(batch.Keys[0])),
 		zap.String("first new region left", newRegionLeft),
@@ -201,14 +201,14 @@ func (s *KVStore) batchSendSingleRegion(bo *Backoffer, batch kvrpc.Batch, scatte
 
 	for i, r := range spResp.Regions {
 		if err = s.scatterRegion(bo, r.Id, tableID); err == nil {
-			logutil.BgLogger().Info("batch split regions, scatter region complete",
+			s.Logger().Info("batch split regions, scatter region complete",
 				zap.Uint64("batch region ID", batch.RegionID.GetID()),
 				zap.String("at", redact.Key(batch.Keys[i])),
 				zap.Stringer("new region left", logutil.Hex(r)))
 			continue
 		}
 
-		logutil.BgLogger().Info("batch split regions, scatter region failed",
+		s.Logger().Info("batch split regions, scatter region failed",
 			zap.Uint64("batch region ID", batch.RegionID.GetID()),
 			zap.String("at", redact.Key(batch.Keys[i])),
 			zap.Stringer("new region left", logutil.Hex(r)),
@@ -238,13 +238,13 @@ func (s *KVStore) SplitRegions(ctx context.Context, splitKeys [][]byte, scatter
 		for _, r := range spResp.Regions {
 			regionIDs = append(regionIDs, r.Id)
 		}
-		logutil.BgLogger().Info("split regions complete", zap.Int("region count", len(regionIDs)), zap.Uint64s("region IDs", regionIDs))
+		s.Logger().Info("split regions complete", zap.Int("region count", len(regionIDs)), zap.Uint64s("region IDs", regionIDs))
 	}
 	return regionIDs, err
 }
 
 func (s *KVStore) scatterRegion(bo *Backoffer, regionID uint64, tableID *int64) error {
-	logutil.BgLogger().Info("start scatter region",
+	s.Logger().Info("start scatter region",
 		zap.Uint64("regionID", regionID))
 	for {
 		opts := make([]opt.RegionsOption, 0, 1)
@@ -267,7 +267,7 @@ func (s *KVStore) scatterRegion(bo *Backoffer, regionID uint64, tableID *int64)
 			return err
 		}
 	}
-	logutil.BgLogger().Debug("scatter region complete",
+	s.Logger().Debug("scatter region complete",
 		zap.Uint64("regionID", regionID))
 	return nil
 }
@@ -281,7 +281,7 @@ func (s *KVStore) WaitScatterRegionFinish(ctx context.Context, regionID uint64,
 	if backOff <= 0 {
 		backOff = waitScatterRegionFinishBackoff
 	}
-	logutil.BgLogger().Info("wait scatter region",
+	s.Logger().Info("wait scatter region",
 		zap.Uint64("regionID", regionID), zap.Int("backoff(ms)", backOff))
 
 	bo := retry.NewBackofferWithVars(ctx, backOff, nil)
@@ -290,7 +290,7 @@ func (s *KVStore) WaitScatterRegionFinish(ctx context.Context, regionID uint64,
 		resp, err := s.pdClient.GetOperator(ctx, regionID)
 		if err == nil && resp != nil {
 			if !bytes.Equal(resp.Desc, []byte("scatter-region")) || resp.Status != pdpb.OperatorStatus_RUNNING {
-				logutil.BgLogger().Info("wait scatter region finished",
+				s.Logger().Info("wait scatter region finished",
 					zap.Uint64("regionID", regionID))
 				return nil
 			}
@@ -298,12 +298,12 @@ func (s *KVStore) WaitScatterRegionFinish(ctx context.Context, regionID uint64,
 				err = errors.WithStack(&tikverr.PDError{
 					Err: resp.Header.Error,
 				})
-				logutil.BgLogger().Warn("wait scatter region error",
+				s.Logger().Warn("wait scatter region error",
 					zap.Uint64("regionID", regionID), zap.Error(err))
 				return err
 			}
 			if logFreq%10 == 0 {
-				logutil.BgLogger().Info("wait scatter region",
+				s.Logger().Info("wait scatter region",
 					zap.Uint64("regionID", regionID),
 					zap.String("reverse", string(resp.Desc)),
 					zap.String("status", pdpb.OperatorStatus_name[int32(resp.Status)]))
@@ -0,0 +1,191 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/memctl"
+)
+
+const defaultStoreLoadCheckInterval = time.Second
+
+// BatchQueueStat is the stat of the batch commands queue to a store, including the high watermarks of the requests.
+type BatchQueueStat = client.BatchQueueStat
+
+// GetBatchQueueStats returns the stats of the batch commands queues to the stores, sorted by the address.
+func (s *KVStore) GetBatchQueueStats() []BatchQueueStat {
+	return client.GetBatchQueueStats(s.GetTiKVClient())
+}
+
+// ConnStat is the stat of a gRPC connection to a store, including the transport events beneath the connection.
+type ConnStat = client.ConnStat
+
+// GetConnStats returns the stats of the gRPC connections to the stores, sorted by the address.
+func (s *KVStore) GetConnStats() []ConnStat {
+	return client.GetConnStats(s.GetTiKVClient())
+}
+
+// MemoryUsage is the memory usage of the major allocations of the clients in the process, accounted against the
+// budget of config.TiKVClient.MemoryControl.
+type MemoryUsage = memctl.Usage
+
+// GetMemoryUsage returns the memory usage of the clients in the process. It's also reported by the
+// memory_usage_bytes metrics.
+func GetMemoryUsage() MemoryUsage {
+	return memctl.GetUsage()
+}
+
+// StoreLoadAlertConfig is the config of the alerts on the load of the stores.
+type StoreLoadAlertConfig struct {
+	// MaxPending is the threshold of the requests waiting in the batch queue of a store, 0 means no threshold.
+	MaxPending int
+	// MaxInflight is the threshold of the requests sent to a store but not responded, 0 means no threshold.
+	MaxInflight int
+	// For is how long the load of a store keeps above a threshold before OnAlert is called.
+	For time.Duration
+	// CheckInterval is the interval of checking the load of the stores, 1s by default.
+	CheckInterval time.Duration
+	// OnAlert is called when the load of a store has kept above a threshold for For, and called again with Recovered
+	// set once the load falls back below the thresholds.
+	OnAlert func(StoreLoadAlert)
+}
+
+// StoreLoadAlert is an alert on the load of a store.
+type StoreLoadAlert struct {
+	Addr     string
+	Pending  int
+	Inflight int
+	// Since is when the load got above the threshold.
+	Since     time.Time
+	Recovered bool
+}
+
+type storeLoadState struct {
+	since   time.Time
+	alerted bool
+}
+
+// storeLoadAlerter checks the load of the stores periodically with the config set by SetStoreLoadAlert.
+type storeLoadAlerter struct {
+	cfg   atomic.Pointer[StoreLoadAlertConfig]
+	start sync.Once
+	// states is only accessed by the checking goroutine.
+	states map[string]*storeLoadState
+}
+
+// SetStoreLoadAlert sets the alerts on the number of the pending and in-flight requests of each store, nil disables
+// them. The alerts let applications notice an overloaded or stuck store before the requests time out.
+func (s *KVStore) SetStoreLoadAlert(cfg *StoreLoadAlertConfig) {
+	s.loadAlerter.cfg.Store(cfg)
+	if cfg == nil {
+		return
+	}
+	s.loadAlerter.start.Do(func() {
+		s.wg.Add(1)
+		go s.runStoreLoadAlerter()
+	})
+}
+
+func (s *KVStore) runStoreLoadAlerter() {
+	defer s.wg.Done()
+	for {
+		interval := defaultStoreLoadCheckInterval
+		if cfg := s.loadAlerter.cfg.Load(); cfg != nil && cfg.CheckInterval > 0 {
+			interval = cfg.CheckInterval
+		}
+		select {
+		case now := <-time.After(interval):
+			s.loadAlerter.check(now, s.GetBatchQueueStats())
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *storeLoadAlerter) check(now time.Time, stats []BatchQueueStat) {
+	cfg := a.cfg.Load()
+	if cfg == nil {
+		// Don't alert the recovery of the stores after the alerts are disabled.
+		a.states = nil
+		return
+	}
+	if a.states == nil {
+		a.states = make(map[string]*storeLoadState)
+	}
+	seen := make(map[string]struct{}, len(stats))
+	for _, stat := range stats {
+		seen[stat.Target] = struct{}{}
+		overloaded := (cfg.MaxPending > 0 && stat.Pending > cfg.MaxPending) ||
+			(cfg.MaxInflight > 0 && stat.Inflight > cfg.MaxInflight)
+		state, ok := a.states[stat.Target]
+		if !overloaded {
+			if ok {
+				delete(a.states, stat.Target)
+				if state.alerted && cfg.OnAlert != nil {
+					cfg.OnAlert(StoreLoadAlert{
+						Addr: stat.Target, Pending: stat.Pending, Inflight: stat.Inflight, Since: state.since, Recovered: true,
+					})
+				}
+			}
+			continue
+		}
+		if !ok {
+			state = &storeLoadState{since: now}
+			a.states[stat.Target] = state
+		}
+		if !state.alerted && now.Sub(state.since) >= cfg.For {
+			state.alerted = true
+			if cfg.OnAlert != nil {
+				cfg.OnAlert(StoreLoadAlert{Addr: stat.Target, Pending: stat.Pending, Inflight: stat.Inflight, Since: state.since})
+			}
+		}
+	}
+	// The connections to the store are closed.
+	for target := range a.states {
+		if _, ok := seen[target]; !ok {
+			delete(a.states, target)
+		}
+	}
+}
+
+// WriteStallConfig is the config of the detection of the stores stalling the writes.
+type WriteStallConfig = locate.WriteStallConfig
+
+// WriteStall is a store stalling the writes, with the backoff suggested to the writers.
+type WriteStall = locate.WriteStall
+
+// SetWriteStallDetection detects the stores stalling the writes by the ServerIsBusy errors and the delays in the exec
+// details of the writes sent by the store, so the bulk writers can throttle themselves by GetStallingStores or
+// OnStall of cfg before the writes fail and retry massively. nil disables the detection.
+func (s *KVStore) SetWriteStallDetection(cfg *WriteStallConfig) {
+	if cfg == nil {
+		s.regionCache.SetWriteStallDetector(nil)
+		return
+	}
+	s.regionCache.SetWriteStallDetector(locate.NewWriteStallDetector(*cfg))
+}
+
+// GetStallingStores returns the stores stalling the writes detected by SetWriteStallDetection, sorted by the store ID.
+func (s *KVStore) GetStallingStores() []WriteStall {
+	if d := s.regionCache.GetWriteStallDetector(); d != nil {
+		return d.StallingStores()
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tikv/client-go/v2/internal/locate"
+	"go.uber.org/zap"
+)
+
+const defaultStoreStatsReportInterval = 10 * time.Second
+
+// StoreRPCStats is the statistics of the RPCs sent to a store observed by the client in a period.
+type StoreRPCStats = locate.StoreRPCStats
+
+// StoreStats is the client's view of the health of a store in a report period.
+type StoreStats struct {
+	StoreID uint64
+	Addr    string
+	StoreRPCStats
+	// ClientSideSlowScore is the slow score of the store calculated by the client from the latency of the requests.
+	ClientSideSlowScore int64
+	// TiKVSideSlowScore is the slow score reported by the store in the health feedback.
+	TiKVSideSlowScore int64
+}
+
+// StoreStatsReporter reports the stats of the stores observed by the client, e.g. to PD or to a dashboard, so the
+// cluster-level schedulers can take the client's view of the store health into account.
+type StoreStatsReporter interface {
+	// ReportStoreStats reports the stats of the stores in the period. The stores without any RPC in the period are
+	// omitted. It's called by a single goroutine, so a slow reporter delays the following reports.
+	ReportStoreStats(ctx context.Context, period time.Duration, stats []StoreStats) error
+}
+
+// StoreStatsReporterFunc is a function implementing StoreStatsReporter.
+type StoreStatsReporterFunc func(ctx context.Context, period time.Duration, stats []StoreStats) error
+
+// ReportStoreStats implements StoreStatsReporter.
+func (f StoreStatsReporterFunc) ReportStoreStats(ctx context.Context, period time.Duration, stats []StoreStats) error {
+	return f(ctx, period, stats)
+}
+
+type storeStatsReporterConfig struct {
+	reporter StoreStatsReporter
+	interval time.Duration
+}
+
+// storeStatsReporter reports the stats of the stores periodically with the reporter set by SetStoreStatsReporter.
+type storeStatsReporter struct {
+	cfg   atomic.Pointer[storeStatsReporterConfig]
+	start sync.Once
+	// lastReport is only accessed by the reporting goroutine.
+	lastReport time.Time
+}
+
+// SetStoreStatsReporter sets the reporter of the per-store latency and error stats observed by the client, which is
+// called every interval, 10s if interval is not positive. A nil reporter disables the reports.
+func (s *KVStore) SetStoreStatsReporter(reporter StoreStatsReporter, interval time.Duration) {
+	if reporter == nil {
+		s.statsReporter.cfg.Store(nil)
+		return
+	}
+	if interval <= 0 {
+		interval = defaultStoreStatsReportInterval
+	}
+	s.statsReporter.cfg.Store(&storeStatsReporterConfig{reporter: reporter, interval: interval})
+	s.statsReporter.start.Do(func() {
+		s.wg.Add(1)
+		go s.runStoreStatsReporter()
+	})
+}
+
+func (s *KVStore) runStoreStatsReporter() {
+	defer s.wg.Done()
+	s.statsReporter.lastReport = time.Now()
+	for {
+		interval := defaultStoreStatsReportInterval
+		if cfg := s.statsReporter.cfg.Load(); cfg != nil {
+			interval = cfg.interval
+		}
+		select {
+		case now := <-time.After(interval):
+			s.reportStoreStats(now)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *KVStore) reportStoreStats(now time.Time) {
+	// The stats are taken even if the reports are disabled, so the next report only covers its own period.
+	stats := collectStoreStats(s.regionCache.GetAllStores())
+	period := now.Sub(s.statsReporter.lastReport)
+	s.statsReporter.lastReport = now
+	cfg := s.statsReporter.cfg.Load()
+	if cfg == nil {
+		return
+	}
+	if err := cfg.reporter.ReportStoreStats(s.ctx, period, stats); err != nil {
+		s.Logger().Warn("report store stats failed", zap.Error(err))
+	}
+}
+
+func collectStoreStats(stores []*locate.Store) []StoreStats {
+	stats := make([]StoreStats, 0, len(stores))
+	for _, store := range stores {
+		health := store.GetHealthStatus()
+		rpcStats := health.TakeRPCStats()
+		if rpcStats.Requests == 0 {
+			continue
+		}
+		detail := health.GetHealthStatusDetail()
+		stats = append(stats, StoreStats{
+			StoreID:             store.StoreID(),
+			Addr:                store.GetAddr(),
+			StoreRPCStats:       rpcStats,
+			ClientSideSlowScore: detail.ClientSideSlowScore,
+			TiKVSideSlowScore:   detail.TiKVSideSlowScore,
+		})
+	}
+	return stats
+}
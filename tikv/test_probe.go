@@ -106,6 +106,12 @@ func (s StoreProbe) SetRegionCacheStore(id uint64, storeType tikvrpc.EndpointTyp
 	s.regionCache.SetRegionCacheStore(id, "", "", storeType, state, labels)
 }
 
+// SetMockStoreLiveness makes the health check of the stores ask live instead of requesting the stores, for testing
+// only.
+func (s StoreProbe) SetMockStoreLiveness(live func(storeID uint64) bool) {
+	s.regionCache.SetMockStoreLiveness(live)
+}
+
 // SetSafeTS is used to set safeTS for the store with `storeID`
 func (s StoreProbe) SetSafeTS(storeID, safeTS uint64) {
 	s.setSafeTS(storeID, safeTS)
@@ -42,6 +42,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pingcap/kvproto/pkg/keyspacepb"
 	"github.com/tikv/client-go/v2/internal/apicodec"
+	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/locate"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util/async"
@@ -56,7 +57,11 @@ type CodecClient struct {
 
 // SendRequest uses codec to encode request before send, and decode response before return.
 func (c *CodecClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
-	req, err := c.codec.EncodeRequest(req)
+	codec := c.codec
+	if reqCodec := client.GetRequestCodec(ctx); reqCodec != nil {
+		codec = reqCodec
+	}
+	req, err := codec.EncodeRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +69,7 @@ func (c *CodecClient) SendRequest(ctx context.Context, addr string, req *tikvrpc
 	if err != nil {
 		return nil, err
 	}
-	return c.codec.DecodeResponse(req, resp)
+	return codec.DecodeResponse(req, resp)
 }
 
 func (c *CodecClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
@@ -103,13 +108,16 @@ func NewTestTiKVStore(client Client, pdClient pd.Client, clientHijack func(Clien
 	uid := uuid.New().String()
 	spkv := NewMockSafePointKV()
 	tikvStore, err := NewKVStore(uid, pdCli, spkv, client, opt...)
+	if err != nil {
+		return nil, err
+	}
 
 	if txnLocalLatches > 0 {
 		tikvStore.EnableTxnLocalLatches(txnLocalLatches)
 	}
 
 	tikvStore.mock = true
-	return tikvStore, err
+	return tikvStore, nil
 }
 
 // NewTestTiKVStore creates a test store with Option
@@ -143,11 +151,14 @@ func NewTestKeyspaceTiKVStore(client Client, pdClient pd.Client, clientHijack fu
 	keyspaceIdStr := strconv.FormatUint(uint64(keyspaceMeta.Id), 10)
 	spkv := NewMockSafePointKV(WithPrefix(keyspaceIdStr))
 	tikvStore, err := NewKVStore(uid, pdCli, spkv, client, opt...)
+	if err != nil {
+		return nil, err
+	}
 
 	if txnLocalLatches > 0 {
 		tikvStore.EnableTxnLocalLatches(txnLocalLatches)
 	}
 
 	tikvStore.mock = true
-	return tikvStore, err
+	return tikvStore, nil
 }
@@ -0,0 +1,234 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/mpp"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
+)
+
+// errTiFlashReplicaNotFound means a region or its TiFlash replica isn't found in the region cache, so the batch
+// coprocessor tasks are built again after the region is loaded.
+var errTiFlashReplicaNotFound = errors.New("the TiFlash replica of a region is not found")
+
+// BatchCopTask is a batch coprocessor task sent to a TiFlash store, covering the regions replicated on it.
+type BatchCopTask struct {
+	StoreID uint64
+	Addr    string
+	Regions []*coprocessor.RegionInfo
+}
+
+// BuildBatchCopTasks splits the ranges by the regions and groups the regions by the TiFlash stores selected by
+// labelFilter, balancing the regions between their TiFlash replicas. Empty end keys mean unbounded. The tasks are
+// sorted by the address of the stores.
+func (s *KVStore) BuildBatchCopTasks(bo *Backoffer, ranges []kv.KeyRange, labelFilter LabelFilter) ([]*BatchCopTask, error) {
+	for {
+		tasks, retryable, err := s.buildBatchCopTasks(bo, ranges, labelFilter)
+		if err != nil || !retryable {
+			return tasks, err
+		}
+		if err = bo.Backoff(retry.BoRegionMiss, errTiFlashReplicaNotFound); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *KVStore) buildBatchCopTasks(bo *Backoffer, ranges []kv.KeyRange, labelFilter LabelFilter) ([]*BatchCopTask, bool, error) {
+	tasks := make(map[string]*BatchCopTask)
+	regions := make(map[uint64]*coprocessor.RegionInfo)
+	for _, r := range ranges {
+		if len(r.EndKey) > 0 && bytes.Compare(r.StartKey, r.EndKey) >= 0 {
+			continue
+		}
+		locs, err := s.regionCache.LocateKeyRange(bo, r.StartKey, r.EndKey)
+		if err != nil {
+			return nil, false, err
+		}
+		err = util.SplitRangeByRegions(r.StartKey, r.EndKey, locate.NextKeyLocation(locs), func(loc *KeyLocation, start, end []byte) error {
+			keyRange := &coprocessor.KeyRange{Start: start, End: end}
+			if region, ok := regions[loc.Region.GetID()]; ok {
+				region.Ranges = append(region.Ranges, keyRange)
+				return nil
+			}
+			rpcCtx, err := s.regionCache.GetTiFlashRPCContext(bo, loc.Region, true, labelFilter)
+			if err != nil {
+				return err
+			}
+			if rpcCtx == nil {
+				// The region or its TiFlash replica is not found in the cache, locate it again.
+				s.regionCache.InvalidateCachedRegion(loc.Region)
+				return errTiFlashReplicaNotFound
+			}
+			region := &coprocessor.RegionInfo{
+				RegionId: loc.Region.GetID(),
+				RegionEpoch: &metapb.RegionEpoch{
+					ConfVer: loc.Region.GetConfVer(),
+					Version: loc.Region.GetVer(),
+				},
+				Ranges: []*coprocessor.KeyRange{keyRange},
+			}
+			regions[region.RegionId] = region
+			task, ok := tasks[rpcCtx.Addr]
+			if !ok {
+				task = &BatchCopTask{StoreID: rpcCtx.Store.StoreID(), Addr: rpcCtx.Addr}
+				tasks[rpcCtx.Addr] = task
+			}
+			task.Regions = append(task.Regions, region)
+			return nil
+		})
+		if errors.Is(err, errTiFlashReplicaNotFound) {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	res := make([]*BatchCopTask, 0, len(tasks))
+	for _, task := range tasks {
+		res = append(res, task)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Addr < res[j].Addr })
+	return res, false, nil
+}
+
+// SendBatchCop sends the batch coprocessor request for the regions of the task to TiFlash and calls fn on each
+// streaming response. It returns the regions TiFlash asks to retry, e.g. because of the stale epochs, which are
+// invalidated in the region cache, so the caller can build new tasks for them with BuildBatchCopTasks. The timeout
+// bounds the wait for each streaming response. The req is not modified, so it can be shared by the tasks.
+func (s *KVStore) SendBatchCop(ctx context.Context, task *BatchCopTask, req *coprocessor.BatchRequest, timeout time.Duration,
+	fn func(*coprocessor.BatchResponse) error) (retryRegions []*metapb.Region, err error) {
+	batchReq := *req
+	batchReq.Regions = task.Regions
+	tikvReq := tikvrpc.NewRequest(tikvrpc.CmdBatchCop, &batchReq, kvrpcpb.Context{})
+	tikvReq.StoreTp = tikvrpc.TiFlash
+	resp, err := s.GetTiKVClient().SendRequest(ctx, task.Addr, tikvReq, timeout)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := resp.Resp.(*tikvrpc.BatchCopStreamResponse)
+	if !ok {
+		return nil, errors.Errorf("unexpected batch cop response %T", resp.Resp)
+	}
+	defer stream.Close()
+	for batchResp := stream.BatchResponse; batchResp != nil; {
+		if otherErr := batchResp.GetOtherError(); otherErr != "" {
+			return retryRegions, errors.Errorf("batch cop on %s: %s", task.Addr, otherErr)
+		}
+		retryRegions = append(retryRegions, batchResp.GetRetryRegions()...)
+		if err = fn(batchResp); err != nil {
+			return retryRegions, err
+		}
+		if batchResp, err = stream.Recv(); err != nil {
+			if errors.Cause(err) == io.EOF {
+				break
+			}
+			return retryRegions, err
+		}
+	}
+	for _, region := range retryRegions {
+		s.regionCache.InvalidateCachedRegion(locate.NewRegionVerID(region.GetId(), region.GetRegionEpoch().GetConfVer(), region.GetRegionEpoch().GetVersion()))
+	}
+	return retryRegions, nil
+}
+
+// DispatchMPPTask dispatches an MPP task to the TiFlash store at addr.
+func (s *KVStore) DispatchMPPTask(ctx context.Context, addr string, req *mpp.DispatchTaskRequest, timeout time.Duration) (*mpp.DispatchTaskResponse, error) {
+	resp, err := s.sendMPPRequest(ctx, addr, tikvrpc.CmdMPPTask, req, timeout)
+	if err != nil {
+		return nil, err
+	}
+	dispatchResp, ok := resp.Resp.(*mpp.DispatchTaskResponse)
+	if !ok {
+		return nil, errors.Errorf("unexpected dispatch mpp task response %T", resp.Resp)
+	}
+	if dispatchResp.GetError() != nil {
+		return dispatchResp, errors.Errorf("dispatch mpp task to %s: %s", addr, dispatchResp.GetError().GetMsg())
+	}
+	return dispatchResp, nil
+}
+
+// EstablishMPPConn establishes a connection to receive the data of an MPP task from the TiFlash store at addr, and
+// calls fn on each data packet until the task finishes. The timeout bounds the wait for each packet.
+func (s *KVStore) EstablishMPPConn(ctx context.Context, addr string, req *mpp.EstablishMPPConnectionRequest, timeout time.Duration,
+	fn func(*mpp.MPPDataPacket) error) error {
+	resp, err := s.sendMPPRequest(ctx, addr, tikvrpc.CmdMPPConn, req, timeout)
+	if err != nil {
+		return err
+	}
+	stream, ok := resp.Resp.(*tikvrpc.MPPStreamResponse)
+	if !ok {
+		return errors.Errorf("unexpected mpp connection response %T", resp.Resp)
+	}
+	defer stream.Close()
+	for packet := stream.MPPDataPacket; packet != nil; {
+		if packet.GetError() != nil {
+			return errors.Errorf("mpp connection to %s: %s", addr, packet.GetError().GetMsg())
+		}
+		if err = fn(packet); err != nil {
+			return err
+		}
+		if packet, err = stream.Recv(); err != nil {
+			if errors.Cause(err) == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// CancelMPPTask cancels the MPP tasks of the query on the TiFlash store at addr.
+func (s *KVStore) CancelMPPTask(ctx context.Context, addr string, req *mpp.CancelTaskRequest, timeout time.Duration) error {
+	resp, err := s.sendMPPRequest(ctx, addr, tikvrpc.CmdMPPCancel, req, timeout)
+	if err != nil {
+		return err
+	}
+	cancelResp, ok := resp.Resp.(*mpp.CancelTaskResponse)
+	if !ok {
+		return errors.Errorf("unexpected cancel mpp task response %T", resp.Resp)
+	}
+	if cancelErr := cancelResp.GetError(); cancelErr != nil {
+		return errors.Errorf("cancel mpp task on %s: %s", addr, cancelErr.GetMsg())
+	}
+	return nil
+}
+
+func (s *KVStore) sendMPPRequest(ctx context.Context, addr string, typ tikvrpc.CmdType, req interface{}, timeout time.Duration) (*tikvrpc.Response, error) {
+	tikvReq := tikvrpc.NewRequest(typ, req, kvrpcpb.Context{})
+	tikvReq.StoreTp = tikvrpc.TiFlash
+	resp, err := s.GetTiKVClient().SendRequest(ctx, addr, tikvReq, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Resp == nil {
+		return nil, errors.WithStack(tikverr.ErrBodyMissing)
+	}
+	return resp, nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+const (
+	// DefaultUpdateMaxRetry is the max number of retries of KVStore.Update on write conflicts.
+	DefaultUpdateMaxRetry = 10
+	// DefaultRunTxnMaxRetry is the default max number of retries of KVStore.RunTxn.
+	DefaultRunTxnMaxRetry = 10
+
+	txnHelperMaxBackoff = 20000
+)
+
+// isTxnConflictErr returns true if the error means the whole transaction can be
+// retried with a new start ts.
+func isTxnConflictErr(err error) bool {
+	var (
+		latchErr     *tikverr.ErrWriteConflictInLatch
+		retryableErr *tikverr.ErrRetryable
+	)
+	return tikverr.IsErrWriteConflict(err) || errors.As(err, &latchErr) || errors.As(err, &retryableErr)
+}
+
+type runTxnOptions struct {
+	maxRetry     int
+	beginOptions []TxnOption
+	setup        func(txn *transaction.KVTxn)
+
+	okCounter       prometheus.Counter
+	conflictCounter prometheus.Counter
+	errCounter      prometheus.Counter
+}
+
+// RunTxnOption configures KVStore.RunTxn.
+type RunTxnOption func(*runTxnOptions)
+
+// WithRunTxnMaxRetry sets the max number of retries on retryable errors. 0 disables retry.
+func WithRunTxnMaxRetry(maxRetry int) RunTxnOption {
+	return func(o *runTxnOptions) {
+		o.maxRetry = maxRetry
+	}
+}
+
+// WithRunTxnBeginOptions sets the options used to begin every attempt of the transaction.
+func WithRunTxnBeginOptions(opts ...TxnOption) RunTxnOption {
+	return func(o *runTxnOptions) {
+		o.beginOptions = opts
+	}
+}
+
+// WithRunTxnSetup sets a function that configures every attempt of the
+// transaction (e.g. SetPessimistic, SetEnable1PC) before it's passed to fn.
+func WithRunTxnSetup(setup func(txn *transaction.KVTxn)) RunTxnOption {
+	return func(o *runTxnOptions) {
+		o.setup = setup
+	}
+}
+
+// RunTxn runs fn in a transaction and commits it. If fn returns an error, the
+// transaction is rolled back and the error is returned.
+//
+// When fn or the commit fails with a retryable error (write conflicts), the
+// transaction is rolled back and fn is called again in a new transaction with
+// exponential backoff, up to DefaultRunTxnMaxRetry times by default. So fn may
+// be called more than once and must not have side effects outside the
+// transaction. ctx is checked before every attempt.
+func (s *KVStore) RunTxn(ctx context.Context, fn func(txn *transaction.KVTxn) error, opts ...RunTxnOption) error {
+	o := &runTxnOptions{
+		maxRetry:        DefaultRunTxnMaxRetry,
+		okCounter:       metrics.TxnHelperCounterRunTxnOK,
+		conflictCounter: metrics.TxnHelperCounterRunTxnConflict,
+		errCounter:      metrics.TxnHelperCounterRunTxnError,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return s.runTxn(ctx, fn, o)
+}
+
+func (s *KVStore) runTxn(ctx context.Context, fn func(txn *transaction.KVTxn) error, o *runTxnOptions) error {
+	bo := retry.NewBackofferWithVars(ctx, txnHelperMaxBackoff, nil)
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			o.errCounter.Inc()
+			return errors.WithStack(err)
+		}
+		err := s.runTxnOnce(ctx, fn, o)
+		if err == nil {
+			o.okCounter.Inc()
+			return nil
+		}
+		if !isTxnConflictErr(err) {
+			o.errCounter.Inc()
+			return err
+		}
+		o.conflictCounter.Inc()
+		if attempt >= o.maxRetry {
+			o.errCounter.Inc()
+			return errors.Wrapf(tikverr.ErrWriteConflictRetryExceeded, "%v", err)
+		}
+		if err = bo.Backoff(retry.BoWriteConflict, err); err != nil {
+			o.errCounter.Inc()
+			return err
+		}
+	}
+}
+
+func (s *KVStore) runTxnOnce(ctx context.Context, fn func(txn *transaction.KVTxn) error, o *runTxnOptions) error {
+	txn, err := s.Begin(o.beginOptions...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if txn.Valid() {
+			_ = txn.Rollback()
+		}
+	}()
+	if o.setup != nil {
+		o.setup(txn)
+	}
+	if err = fn(txn); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
+
+// Update atomically reads the value of key, computes a new value with fn and
+// writes it back. The old value passed to fn is nil if the key doesn't exist; if
+// fn returns a nil value the key is deleted.
+//
+// On write conflict the whole read-modify-write is retried with a new snapshot, up
+// to DefaultUpdateMaxRetry times with exponential backoff, so fn may be called
+// more than once and must not have side effects. The value written is returned.
+func (s *KVStore) Update(ctx context.Context, key []byte, fn func(old []byte) ([]byte, error)) ([]byte, error) {
+	var val []byte
+	err := s.runTxn(ctx, func(txn *transaction.KVTxn) error {
+		old, err := txn.Get(ctx, key)
+		if err != nil && !tikverr.IsErrNotFound(err) {
+			return err
+		}
+		if val, err = fn(old); err != nil {
+			return err
+		}
+		if val == nil {
+			return txn.Delete(key)
+		}
+		return txn.Set(key, val)
+	}, &runTxnOptions{
+		maxRetry:        DefaultUpdateMaxRetry,
+		setup:           func(txn *transaction.KVTxn) { txn.SetEnable1PC(true) },
+		okCounter:       metrics.TxnHelperCounterUpdateOK,
+		conflictCounter: metrics.TxnHelperCounterUpdateConflict,
+		errCounter:      metrics.TxnHelperCounterUpdateError,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func (s *testTxnHelperSuite) TestUpdate() {
+	ctx := context.Background()
+	incr := func(old []byte) ([]byte, error) {
+		var n uint64
+		if old != nil {
+			n = binary.BigEndian.Uint64(old)
+		}
+		return binary.BigEndian.AppendUint64(nil, n+1), nil
+	}
+
+	const workers, perWorker = 4, 5
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				_, err := s.store.Update(ctx, []byte("counter"), incr)
+				s.Nil(err)
+			}
+		}()
+	}
+	wg.Wait()
+	val, ok := s.mustGet("counter")
+	s.True(ok)
+	s.Equal(uint64(workers*perWorker), binary.BigEndian.Uint64(val))
+
+	// Returning nil deletes the key.
+	val, err := s.store.Update(ctx, []byte("counter"), func([]byte) ([]byte, error) { return nil, nil })
+	s.Nil(err)
+	s.Nil(val)
+	_, ok = s.mustGet("counter")
+	s.False(ok)
+}
+
+func (s *testTxnHelperSuite) TestRunTxn() {
+	ctx := context.Background()
+	encode := func(n uint64) []byte { return binary.BigEndian.AppendUint64(nil, n) }
+	s.Nil(s.store.RunTxn(ctx, func(txn *transaction.KVTxn) error {
+		s.Nil(txn.Set([]byte("a"), encode(100)))
+		return txn.Set([]byte("x"), encode(100))
+	}))
+
+	// Move balance between keys in different regions concurrently, the sum must be kept.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				err := s.store.RunTxn(ctx, func(txn *transaction.KVTxn) error {
+					vals, err := txn.BatchGet(ctx, [][]byte{[]byte("a"), []byte("x")})
+					if err != nil {
+						return err
+					}
+					a, x := binary.BigEndian.Uint64(vals["a"]), binary.BigEndian.Uint64(vals["x"])
+					if err = txn.Set([]byte("a"), encode(a-1)); err != nil {
+						return err
+					}
+					return txn.Set([]byte("x"), encode(x+1))
+				}, WithRunTxnMaxRetry(100))
+				s.Nil(err)
+			}
+		}()
+	}
+	wg.Wait()
+	a, _ := s.mustGet("a")
+	x, _ := s.mustGet("x")
+	s.Equal(uint64(80), binary.BigEndian.Uint64(a))
+	s.Equal(uint64(120), binary.BigEndian.Uint64(x))
+
+	// An error returned by fn rolls back the transaction.
+	errAbort := errors.New("abort")
+	err := s.store.RunTxn(ctx, func(txn *transaction.KVTxn) error {
+		s.Nil(txn.Set([]byte("a"), encode(0)))
+		return errAbort
+	})
+	s.ErrorIs(err, errAbort)
+	a, _ = s.mustGet("a")
+	s.Equal(uint64(80), binary.BigEndian.Uint64(a))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = s.store.RunTxn(canceled, func(txn *transaction.KVTxn) error { return nil })
+	s.ErrorIs(err, context.Canceled)
+}
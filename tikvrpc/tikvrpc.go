@@ -266,6 +266,8 @@ type Request struct {
 	InputRequestSource string
 	// AccessLocationAttr indicates the request is sent to a different zone.
 	AccessLocation kv.AccessLocationType
+	// TrafficClass tells whether the request is generated by the client itself, it's only used in metrics.
+	TrafficClass TrafficClass
 	// rev represents the revision of the request, it's increased when `Req.Context` gets patched.
 	rev uint32
 }
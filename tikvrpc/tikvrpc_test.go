@@ -35,7 +35,9 @@
 package tikvrpc
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"testing"
@@ -43,8 +45,10 @@ import (
 
 	"github.com/pingcap/kvproto/pkg/coprocessor"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/stretchr/testify/assert"
+	tikverr "github.com/tikv/client-go/v2/error"
 )
 
 func TestBatchResponse(t *testing.T) {
@@ -137,3 +141,72 @@ func TestTiDB51921(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	regionCtx := kvrpcpb.Context{RegionId: 1, RegionEpoch: &metapb.RegionEpoch{}, Peer: &metapb.Peer{}}
+	mutations := func(keys ...string) []*kvrpcpb.Mutation {
+		ms := make([]*kvrpcpb.Mutation, 0, len(keys))
+		for _, k := range keys {
+			ms = append(ms, &kvrpcpb.Mutation{Key: []byte(k)})
+		}
+		return ms
+	}
+	for _, c := range []struct {
+		req   *Request
+		field string
+	}{
+		{NewRequest(CmdGet, &kvrpcpb.GetRequest{Key: []byte("a"), Version: 1}, regionCtx), ""},
+		{NewRequest(CmdGet, &kvrpcpb.GetRequest{Key: []byte("a"), Version: 1}), "context.region_id"},
+		{NewRequest(CmdGet, &kvrpcpb.GetRequest{Version: 1}, regionCtx), "key"},
+		{NewRequest(CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, regionCtx), "version"},
+		{NewRequest(CmdGet, nil), "request body"},
+		{NewRequest(CmdScan, &kvrpcpb.ScanRequest{StartKey: []byte("a"), Limit: 1, Version: 1}, regionCtx), ""},
+		{NewRequest(CmdScan, &kvrpcpb.ScanRequest{StartKey: []byte("b"), EndKey: []byte("a"), Limit: 1, Version: 1}, regionCtx), "range"},
+		{NewRequest(CmdScan, &kvrpcpb.ScanRequest{StartKey: []byte("b"), EndKey: []byte("a"), Limit: 1, Version: 1, Reverse: true}, regionCtx), ""},
+		{NewRequest(CmdScan, &kvrpcpb.ScanRequest{StartKey: []byte("a"), EndKey: []byte("b"), Limit: 1, Version: 1, Reverse: true}, regionCtx), "range"},
+		{NewRequest(CmdPrewrite, &kvrpcpb.PrewriteRequest{Mutations: mutations("a", "b"), PrimaryLock: []byte("a"), StartVersion: 1}, regionCtx), ""},
+		{NewRequest(CmdPrewrite, &kvrpcpb.PrewriteRequest{Mutations: mutations("b", "a"), PrimaryLock: []byte("a"), StartVersion: 1}, regionCtx), "mutations[1].key"},
+		{NewRequest(CmdPrewrite, &kvrpcpb.PrewriteRequest{Mutations: mutations("a", "a"), PrimaryLock: []byte("a"), StartVersion: 1}, regionCtx), "mutations[1].key"},
+		{NewRequest(CmdPrewrite, &kvrpcpb.PrewriteRequest{Mutations: mutations("a"), StartVersion: 1}, regionCtx), "primary lock"},
+		{NewRequest(CmdPrewrite, &kvrpcpb.PrewriteRequest{Mutations: mutations("a"), PrimaryLock: []byte("a"), StartVersion: math.MaxUint64}, regionCtx), "start version"},
+		{NewRequest(CmdCommit, &kvrpcpb.CommitRequest{Keys: [][]byte{[]byte("a")}, StartVersion: 2, CommitVersion: 1}, regionCtx), "commit version"},
+		{NewRequest(CmdCommit, &kvrpcpb.CommitRequest{Keys: [][]byte{nil}, StartVersion: 1, CommitVersion: 2}, regionCtx), "keys[0]"},
+		{NewRequest(CmdResolveLock, &kvrpcpb.ResolveLockRequest{TxnInfos: []*kvrpcpb.TxnInfo{{}}}, regionCtx), ""},
+		{NewRequest(CmdRawDeleteRange, &kvrpcpb.RawDeleteRangeRequest{StartKey: []byte("a"), EndKey: []byte("a")}, regionCtx), "range"},
+		{NewRequest(CmdCop, &coprocessor.Request{Ranges: []*coprocessor.KeyRange{{Start: []byte("a"), End: []byte("b")}, {Start: []byte("c"), End: []byte("c")}}}, regionCtx), "ranges[1]"},
+		{NewRequest(CmdStoreSafeTS, &kvrpcpb.StoreSafeTSRequest{}), ""},
+		{NewRequest(CmdUnsafeDestroyRange, &kvrpcpb.UnsafeDestroyRangeRequest{StartKey: []byte("a"), EndKey: []byte("b")}), ""},
+	} {
+		err := c.req.Validate()
+		if c.field == "" {
+			assert.Nil(t, err, c.req.Type.String())
+			continue
+		}
+		var errInvalid *tikverr.ErrInvalidRequest
+		if assert.True(t, errors.As(err, &errInvalid), c.req.Type.String()) {
+			assert.Equal(t, c.field, errInvalid.Field)
+			assert.Equal(t, c.req.Type.String(), errInvalid.Type)
+		}
+	}
+	var nilReq *Request
+	assert.NotNil(t, nilReq.Validate())
+}
+
+func FuzzValidate(f *testing.F) {
+	f.Add(uint16(CmdPrewrite), []byte("a"), []byte("b"), uint64(1), uint64(2), false)
+	f.Add(uint16(CmdScan), []byte("b"), []byte("a"), uint64(0), uint64(0), true)
+	f.Fuzz(func(t *testing.T, typ uint16, k1, k2 []byte, ts1, ts2 uint64, reverse bool) {
+		for _, req := range []any{
+			&kvrpcpb.GetRequest{Key: k1, Version: ts1},
+			&kvrpcpb.ScanRequest{StartKey: k1, EndKey: k2, Version: ts1, Reverse: reverse},
+			&kvrpcpb.PrewriteRequest{Mutations: []*kvrpcpb.Mutation{{Key: k1}, nil, {Key: k2}}, PrimaryLock: k1, StartVersion: ts1, ForUpdateTs: ts2},
+			&kvrpcpb.CommitRequest{Keys: [][]byte{k1, k2}, StartVersion: ts1, CommitVersion: ts2},
+			&kvrpcpb.RawScanRequest{StartKey: k1, EndKey: k2, Reverse: reverse},
+			&coprocessor.Request{Ranges: []*coprocessor.KeyRange{nil, {Start: k1, End: k2}}},
+			(*kvrpcpb.PessimisticLockRequest)(nil),
+		} {
+			// Validate must not panic whatever the request is.
+			_ = NewRequest(CmdType(typ), req).Validate()
+		}
+	})
+}
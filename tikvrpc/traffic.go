@@ -0,0 +1,59 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikvrpc
+
+// TrafficClass tells whether a request is sent on behalf of the user or
+// generated internally by the client, so the load of each kind can be told
+// apart in metrics.
+type TrafficClass uint8
+
+// TrafficClass type enums.
+const (
+	// TrafficClassUser is the default class of requests.
+	TrafficClassUser TrafficClass = iota
+	// TrafficClassLockResolve is for checking and resolving locks of other transactions.
+	TrafficClassLockResolve
+	// TrafficClassHeartbeat is for keeping the primary lock of a transaction alive.
+	TrafficClassHeartbeat
+	// TrafficClassSafeTS is for probing the safe ts of stores.
+	TrafficClassSafeTS
+	// TrafficClassGC is for garbage collection.
+	TrafficClassGC
+
+	// NumTrafficClasses is the number of traffic classes.
+	NumTrafficClasses = int(TrafficClassGC) + 1
+)
+
+// Name returns the name of the traffic class used in metrics.
+func (c TrafficClass) Name() string {
+	switch c {
+	case TrafficClassUser:
+		return "user"
+	case TrafficClassLockResolve:
+		return "lock_resolve"
+	case TrafficClassHeartbeat:
+		return "heartbeat"
+	case TrafficClassSafeTS:
+		return "safe_ts"
+	case TrafficClassGC:
+		return "gc"
+	}
+	return "unknown"
+}
+
+// IsInternal returns true if the requests of the class are generated by the client itself.
+func (c TrafficClass) IsInternal() bool {
+	return c != TrafficClassUser
+}
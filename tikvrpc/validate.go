@@ -0,0 +1,236 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikvrpc
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// requestValidator checks the fields of a request and keeps the first violation.
+type requestValidator struct {
+	typ CmdType
+	err error
+}
+
+func (v *requestValidator) fail(field, reason string) {
+	if v.err == nil {
+		v.err = &tikverr.ErrInvalidRequest{Type: v.typ.String(), Field: field, Reason: reason}
+	}
+}
+
+func (v *requestValidator) key(field string, key []byte) {
+	if len(key) == 0 {
+		v.fail(field, "is empty")
+	}
+}
+
+func (v *requestValidator) keys(field string, keys [][]byte) {
+	if len(keys) == 0 {
+		v.fail(field, "is empty")
+	}
+	for i, key := range keys {
+		v.key(fmt.Sprintf("%s[%d]", field, i), key)
+	}
+}
+
+// mutations checks the mutations are not empty and their keys are in strictly ascending order.
+func (v *requestValidator) mutations(field string, mutations []*kvrpcpb.Mutation) {
+	if len(mutations) == 0 {
+		v.fail(field, "is empty")
+	}
+	var prev []byte
+	for i, m := range mutations {
+		name := fmt.Sprintf("%s[%d].key", field, i)
+		v.key(name, m.GetKey())
+		if i > 0 && bytes.Compare(prev, m.GetKey()) >= 0 {
+			v.fail(name, "is not in ascending order")
+		}
+		prev = m.GetKey()
+	}
+}
+
+// keyRange checks the range is not empty. The end key of a forward range or the start key of a reverse range can be
+// empty, which means unbounded.
+func (v *requestValidator) keyRange(field string, startKey, endKey []byte, reverse bool) {
+	if reverse {
+		if len(startKey) > 0 && bytes.Compare(endKey, startKey) >= 0 {
+			v.fail(field, "is empty, the end key is not smaller than the start key of the reverse range")
+		}
+		return
+	}
+	if len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0 {
+		v.fail(field, "is empty, the start key is not smaller than the end key")
+	}
+}
+
+func (v *requestValidator) readTS(field string, ts uint64) {
+	if ts == 0 {
+		v.fail(field, "is zero")
+	}
+}
+
+func (v *requestValidator) writeTS(field string, ts uint64) {
+	if ts == 0 {
+		v.fail(field, "is zero")
+	} else if ts == math.MaxUint64 {
+		v.fail(field, "is max uint64")
+	}
+}
+
+// context checks the region context of the requests sent to a specific region.
+func (v *requestValidator) context(ctx *kvrpcpb.Context) {
+	if ctx.GetRegionId() == 0 {
+		v.fail("context.region_id", "is zero")
+	}
+	if ctx.GetRegionEpoch() == nil {
+		v.fail("context.region_epoch", "is missing")
+	}
+	if ctx.GetPeer() == nil {
+		v.fail("context.peer", "is missing")
+	}
+}
+
+// Validate checks the request for the mistakes that can be found without sending it, e.g. empty keys, unordered
+// mutations, empty ranges, zero timestamps and missing region context. It returns ErrInvalidRequest for the first
+// violation. It never panics, whatever the request is, so it can be used on the requests built from untrusted input.
+func (req *Request) Validate() error {
+	if req == nil {
+		return &tikverr.ErrInvalidRequest{Type: CmdType(0).String(), Field: "request", Reason: "is nil"}
+	}
+	v := &requestValidator{typ: req.Type}
+	if req.Req == nil {
+		v.fail("request body", "is missing")
+		return v.err
+	}
+	regionScoped := true
+	switch r := req.Req.(type) {
+	case *kvrpcpb.GetRequest:
+		v.key("key", r.GetKey())
+		v.readTS("version", r.GetVersion())
+	case *kvrpcpb.ScanRequest:
+		v.keyRange("range", r.GetStartKey(), r.GetEndKey(), r.GetReverse())
+		v.readTS("version", r.GetVersion())
+		if r.GetLimit() == 0 {
+			v.fail("limit", "is zero")
+		}
+	case *kvrpcpb.BatchGetRequest:
+		v.keys("keys", r.GetKeys())
+		v.readTS("version", r.GetVersion())
+	case *kvrpcpb.PrewriteRequest:
+		v.mutations("mutations", r.GetMutations())
+		v.key("primary lock", r.GetPrimaryLock())
+		v.writeTS("start version", r.GetStartVersion())
+		if r.GetForUpdateTs() != 0 && r.GetForUpdateTs() < r.GetStartVersion() {
+			v.fail("for update ts", "is smaller than the start version")
+		}
+	case *kvrpcpb.FlushRequest:
+		v.mutations("mutations", r.GetMutations())
+		v.key("primary key", r.GetPrimaryKey())
+		v.writeTS("start ts", r.GetStartTs())
+	case *kvrpcpb.CommitRequest:
+		v.keys("keys", r.GetKeys())
+		v.writeTS("start version", r.GetStartVersion())
+		v.writeTS("commit version", r.GetCommitVersion())
+		if r.GetCommitVersion() <= r.GetStartVersion() {
+			v.fail("commit version", "is not greater than the start version")
+		}
+	case *kvrpcpb.PessimisticLockRequest:
+		if len(r.GetMutations()) == 0 {
+			v.fail("mutations", "is empty")
+		}
+		for i, m := range r.GetMutations() {
+			v.key(fmt.Sprintf("mutations[%d].key", i), m.GetKey())
+		}
+		v.key("primary lock", r.GetPrimaryLock())
+		v.writeTS("start version", r.GetStartVersion())
+		if r.GetForUpdateTs() < r.GetStartVersion() {
+			v.fail("for update ts", "is smaller than the start version")
+		}
+	case *kvrpcpb.PessimisticRollbackRequest:
+		v.keys("keys", r.GetKeys())
+		v.writeTS("start version", r.GetStartVersion())
+	case *kvrpcpb.BatchRollbackRequest:
+		v.keys("keys", r.GetKeys())
+		v.writeTS("start version", r.GetStartVersion())
+	case *kvrpcpb.CleanupRequest:
+		v.key("key", r.GetKey())
+		v.writeTS("start version", r.GetStartVersion())
+	case *kvrpcpb.TxnHeartBeatRequest:
+		v.key("primary lock", r.GetPrimaryLock())
+		v.writeTS("start version", r.GetStartVersion())
+	case *kvrpcpb.CheckTxnStatusRequest:
+		v.key("primary key", r.GetPrimaryKey())
+		v.writeTS("lock ts", r.GetLockTs())
+	case *kvrpcpb.CheckSecondaryLocksRequest:
+		v.keys("keys", r.GetKeys())
+		v.writeTS("start version", r.GetStartVersion())
+	case *kvrpcpb.ResolveLockRequest:
+		// The locks of multiple transactions can be resolved by the txn infos instead of the versions.
+		if len(r.GetTxnInfos()) == 0 {
+			v.writeTS("start version", r.GetStartVersion())
+			if r.GetCommitVersion() != 0 && r.GetCommitVersion() <= r.GetStartVersion() {
+				v.fail("commit version", "is not greater than the start version")
+			}
+		}
+	case *kvrpcpb.DeleteRangeRequest:
+		v.keyRange("range", r.GetStartKey(), r.GetEndKey(), false)
+	case *kvrpcpb.RawGetRequest:
+		v.key("key", r.GetKey())
+	case *kvrpcpb.RawBatchGetRequest:
+		v.keys("keys", r.GetKeys())
+	case *kvrpcpb.RawPutRequest:
+		v.key("key", r.GetKey())
+	case *kvrpcpb.RawBatchPutRequest:
+		if len(r.GetPairs()) == 0 {
+			v.fail("pairs", "is empty")
+		}
+		for i, pair := range r.GetPairs() {
+			v.key(fmt.Sprintf("pairs[%d].key", i), pair.GetKey())
+		}
+	case *kvrpcpb.RawDeleteRequest:
+		v.key("key", r.GetKey())
+	case *kvrpcpb.RawBatchDeleteRequest:
+		v.keys("keys", r.GetKeys())
+	case *kvrpcpb.RawDeleteRangeRequest:
+		v.keyRange("range", r.GetStartKey(), r.GetEndKey(), false)
+	case *kvrpcpb.RawScanRequest:
+		v.keyRange("range", r.GetStartKey(), r.GetEndKey(), r.GetReverse())
+		if r.GetLimit() == 0 {
+			v.fail("limit", "is zero")
+		}
+	case *kvrpcpb.RawCASRequest:
+		v.key("key", r.GetKey())
+	case *coprocessor.Request:
+		for i, ran := range r.GetRanges() {
+			v.keyRange(fmt.Sprintf("ranges[%d]", i), ran.GetStart(), ran.GetEnd(), false)
+		}
+	case *kvrpcpb.UnsafeDestroyRangeRequest:
+		v.keyRange("range", r.GetStartKey(), r.GetEndKey(), false)
+		regionScoped = false
+	default:
+		// The other requests, e.g. the store level requests, are sent as is.
+		regionScoped = false
+	}
+	if regionScoped {
+		v.context(&req.Context)
+	}
+	return v.err
+}
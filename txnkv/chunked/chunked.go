@@ -0,0 +1,328 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chunked stores the values larger than the raft entry limit by splitting them into the chunks stored under
+// the keys derived from their keys, and a manifest of the chunks stored at their keys. The chunks are written in the
+// same transaction as the manifest, so a value is always read as a whole from a transaction or a snapshot.
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// DefaultChunkSize is the default size of the chunks, far below the default raft entry limit of TiKV.
+const DefaultChunkSize = 1 << 20
+
+const (
+	// chunkKeySuffix is appended to the key of a chunked value, followed by the big-endian index of the chunk, to
+	// build the keys of the chunks.
+	chunkKeySuffix = "\x00\xffchunk"
+	// ChunkKeyOverhead is the number of the bytes a chunk key is longer than the key of its value.
+	ChunkKeyOverhead = len(chunkKeySuffix) + 4
+)
+
+// manifestMagic prefixes the manifest stored at the key of a chunked value.
+var manifestMagic = []byte("\x00\xffchunked\x01")
+
+// ChunkKey returns the key of the i-th chunk of the value of k.
+func ChunkKey(k []byte, i int) []byte {
+	key := make([]byte, 0, len(k)+ChunkKeyOverhead)
+	key = append(key, k...)
+	key = append(key, chunkKeySuffix...)
+	return binary.BigEndian.AppendUint32(key, uint32(i))
+}
+
+// IsChunkKey returns whether k is the key of a chunk.
+func IsChunkKey(k []byte) bool {
+	return len(k) >= ChunkKeyOverhead && string(k[len(k)-ChunkKeyOverhead:len(k)-4]) == chunkKeySuffix
+}
+
+// Manifest describes the chunks of a chunked value.
+type Manifest struct {
+	// Size is the size of the value.
+	Size int
+	// Chunks is the number of the chunks.
+	Chunks int
+	// Checksum is the CRC32 checksum of the value.
+	Checksum uint32
+}
+
+// Encode encodes the manifest to be stored at the key of the value.
+func (m Manifest) Encode() []byte {
+	buf := make([]byte, 0, len(manifestMagic)+2*binary.MaxVarintLen64+4)
+	buf = append(buf, manifestMagic...)
+	buf = binary.AppendUvarint(buf, uint64(m.Size))
+	buf = binary.AppendUvarint(buf, uint64(m.Chunks))
+	return binary.BigEndian.AppendUint32(buf, m.Checksum)
+}
+
+// DecodeManifest decodes the manifest of a chunked value, and returns false if v isn't a manifest.
+func DecodeManifest(v []byte) (Manifest, bool) {
+	if !bytes.HasPrefix(v, manifestMagic) {
+		return Manifest{}, false
+	}
+	v = v[len(manifestMagic):]
+	size, n := binary.Uvarint(v)
+	if n <= 0 {
+		return Manifest{}, false
+	}
+	v = v[n:]
+	chunks, n := binary.Uvarint(v)
+	if n <= 0 || len(v[n:]) != 4 {
+		return Manifest{}, false
+	}
+	return Manifest{Size: int(size), Chunks: int(chunks), Checksum: binary.BigEndian.Uint32(v[n:])}, true
+}
+
+// Split splits v into the chunks of at most chunkSize bytes, and returns the chunks and their manifest. The chunks
+// share the memory of v.
+func Split(v []byte, chunkSize int) ([][]byte, Manifest) {
+	n := (len(v) + chunkSize - 1) / chunkSize
+	chunks := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, v[i*chunkSize:min((i+1)*chunkSize, len(v))])
+	}
+	return chunks, Manifest{Size: len(v), Chunks: n, Checksum: crc32.ChecksumIEEE(v)}
+}
+
+// BatchGetter is the interface to read the chunks, e.g. a transaction or a snapshot.
+type BatchGetter interface {
+	// BatchGet gets the values of the keys, the keys not found are absent from the result.
+	BatchGet(ctx context.Context, keys [][]byte) (map[string][]byte, error)
+}
+
+// Reassemble replaces the manifests in values with the values reassembled from their chunks read by r. The other
+// values are left as is.
+func Reassemble(ctx context.Context, r BatchGetter, values map[string][]byte) error {
+	manifests := make(map[string]Manifest)
+	var keys [][]byte
+	for k, v := range values {
+		manifest, ok := DecodeManifest(v)
+		if !ok {
+			continue
+		}
+		manifests[k] = manifest
+		for i := 0; i < manifest.Chunks; i++ {
+			keys = append(keys, ChunkKey([]byte(k), i))
+		}
+	}
+	if len(manifests) == 0 {
+		return nil
+	}
+	chunks, err := r.BatchGet(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for k, manifest := range manifests {
+		v := make([]byte, 0, manifest.Size)
+		for i := 0; i < manifest.Chunks; i++ {
+			chunk, ok := chunks[string(ChunkKey([]byte(k), i))]
+			if !ok {
+				return errors.Errorf("chunk %d of the value of key %q is missing", i, k)
+			}
+			v = append(v, chunk...)
+		}
+		if len(v) != manifest.Size || crc32.ChecksumIEEE(v) != manifest.Checksum {
+			return errors.Errorf("the chunks of the value of key %q are corrupted", k)
+		}
+		values[k] = v
+	}
+	return nil
+}
+
+// Reader is the interface to read the chunked values, e.g. a transaction or a snapshot.
+type Reader interface {
+	BatchGetter
+	Get(ctx context.Context, k []byte) ([]byte, error)
+}
+
+// Writer is the interface to write the chunked values, e.g. a transaction.
+type Writer interface {
+	Reader
+	Set(k []byte, v []byte) error
+	Delete(k []byte) error
+}
+
+// Store reads and writes the values, chunking the values larger than the chunk size. It works on a transaction
+// whose own chunking isn't enabled by SetWriteSizeLimits, otherwise the transaction reassembles the values before the
+// Store sees the manifests.
+type Store struct {
+	chunkSize int
+}
+
+// NewStore creates a Store chunking the values larger than chunkSize, or DefaultChunkSize if it's not positive.
+func NewStore(chunkSize int) *Store {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &Store{chunkSize: chunkSize}
+}
+
+// ChunkSize returns the max size of a chunk.
+func (s *Store) ChunkSize() int {
+	return s.chunkSize
+}
+
+// Get gets the value of k, reassembling it if it's chunked. It returns ErrNotExist if k isn't found.
+func (s *Store) Get(ctx context.Context, r Reader, k []byte) ([]byte, error) {
+	v, err := r.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string][]byte{string(k): v}
+	if err = Reassemble(ctx, r, values); err != nil {
+		return nil, err
+	}
+	return values[string(k)], nil
+}
+
+// BatchGet gets the values of the keys, reassembling the chunked ones. The keys not found are absent from the
+// result.
+func (s *Store) BatchGet(ctx context.Context, r Reader, keys [][]byte) (map[string][]byte, error) {
+	values, err := r.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err = Reassemble(ctx, r, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Set sets the value of k, chunking it if it's larger than the chunk size. The chunks of the previous value of k no
+// longer used are deleted, which reads the previous value first.
+func (s *Store) Set(ctx context.Context, w Writer, k []byte, v []byte) error {
+	prevChunks, err := s.chunks(ctx, w, k)
+	if err != nil {
+		return err
+	}
+	chunks := 0
+	if len(v) > s.chunkSize {
+		values, manifest := Split(v, s.chunkSize)
+		for i, chunk := range values {
+			if err = w.Set(ChunkKey(k, i), chunk); err != nil {
+				return err
+			}
+		}
+		chunks = manifest.Chunks
+		v = manifest.Encode()
+	}
+	for i := chunks; i < prevChunks; i++ {
+		if err = w.Delete(ChunkKey(k, i)); err != nil {
+			return err
+		}
+	}
+	return w.Set(k, v)
+}
+
+// Delete deletes k and the chunks of its value.
+func (s *Store) Delete(ctx context.Context, w Writer, k []byte) error {
+	prevChunks, err := s.chunks(ctx, w, k)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < prevChunks; i++ {
+		if err = w.Delete(ChunkKey(k, i)); err != nil {
+			return err
+		}
+	}
+	return w.Delete(k)
+}
+
+// chunks returns the number of the chunks of the value of k, or 0 if it isn't chunked or found.
+func (s *Store) chunks(ctx context.Context, r Reader, k []byte) (int, error) {
+	v, err := r.Get(ctx, k)
+	if tikverr.IsErrNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	manifest, _ := DecodeManifest(v)
+	return manifest.Chunks, nil
+}
+
+// Iterator is the interface of the iterators of a transaction or a snapshot.
+type Iterator interface {
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Next() error
+	Close()
+}
+
+// chunkedIterator skips the chunk keys of an iterator and reassembles the chunked values.
+type chunkedIterator struct {
+	Iterator
+	ctx   context.Context
+	r     BatchGetter
+	value []byte
+}
+
+// NewIterator wraps it to skip the chunk keys and reassemble the chunked values from the chunks read by r. It closes
+// it if it fails.
+func NewIterator(ctx context.Context, r BatchGetter, it Iterator) (Iterator, error) {
+	c := &chunkedIterator{Iterator: it, ctx: ctx, r: r}
+	if err := c.skipChunks(); err != nil {
+		it.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (it *chunkedIterator) skipChunks() error {
+	for it.Iterator.Valid() && IsChunkKey(it.Iterator.Key()) {
+		if err := it.Iterator.Next(); err != nil {
+			return err
+		}
+	}
+	if !it.Iterator.Valid() {
+		it.value = nil
+		return nil
+	}
+	it.value = it.Iterator.Value()
+	if _, ok := DecodeManifest(it.value); !ok {
+		return nil
+	}
+	k := string(it.Iterator.Key())
+	values := map[string][]byte{k: it.value}
+	if err := Reassemble(it.ctx, it.r, values); err != nil {
+		return err
+	}
+	it.value = values[k]
+	return nil
+}
+
+// Value returns the reassembled value.
+func (it *chunkedIterator) Value() []byte {
+	return it.value
+}
+
+// Next moves to the next entry that isn't a chunk.
+func (it *chunkedIterator) Next() error {
+	if err := it.Iterator.Next(); err != nil {
+		return err
+	}
+	if err := it.skipChunks(); err != nil {
+		it.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunked_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/chunked"
+)
+
+func TestManifest(t *testing.T) {
+	v := bytes.Repeat([]byte("0123456789"), 3)
+	chunks, manifest := chunked.Split(v, 8)
+	require.Len(t, chunks, 4)
+	require.Equal(t, []byte("456789"), chunks[3])
+	decoded, ok := chunked.DecodeManifest(manifest.Encode())
+	require.True(t, ok)
+	require.Equal(t, manifest, decoded)
+	_, ok = chunked.DecodeManifest(v)
+	require.False(t, ok)
+	encoded := manifest.Encode()
+	_, ok = chunked.DecodeManifest(encoded[:len(encoded)-1])
+	require.False(t, ok)
+}
+
+func TestChunkedStore(t *testing.T) {
+	suite.Run(t, new(testChunkedStoreSuite))
+}
+
+type testChunkedStoreSuite struct {
+	suite.Suite
+	store *tikv.KVStore
+}
+
+func (s *testChunkedStoreSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+}
+
+func (s *testChunkedStoreSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testChunkedStoreSuite) TestSetGet() {
+	ctx := context.Background()
+	cs := chunked.NewStore(16)
+	large := bytes.Repeat([]byte("0123456789"), 5)
+	txn, err := s.store.Begin()
+	s.Nil(err)
+	s.Nil(cs.Set(ctx, txn, []byte("large"), large))
+	s.Nil(cs.Set(ctx, txn, []byte("small"), []byte("small")))
+	// The value is reassembled from the MemBuffer.
+	v, err := cs.Get(ctx, txn, []byte("large"))
+	s.Nil(err)
+	s.Equal(large, v)
+	s.Nil(txn.Commit(ctx))
+
+	// The value is reassembled from a snapshot.
+	ts, err := s.store.CurrentTimestamp("global")
+	s.Nil(err)
+	values, err := cs.BatchGet(ctx, s.store.GetSnapshot(ts), [][]byte{[]byte("large"), []byte("small"), []byte("none")})
+	s.Nil(err)
+	s.Equal(map[string][]byte{"large": large, "small": []byte("small")}, values)
+
+	// The chunks no longer used are deleted.
+	txn, err = s.store.Begin()
+	s.Nil(err)
+	s.Nil(cs.Set(ctx, txn, []byte("large"), large[:20]))
+	_, err = txn.Get(ctx, chunked.ChunkKey([]byte("large"), 1))
+	s.Nil(err)
+	_, err = txn.Get(ctx, chunked.ChunkKey([]byte("large"), 2))
+	s.True(tikverr.IsErrNotFound(err))
+	v, err = cs.Get(ctx, txn, []byte("large"))
+	s.Nil(err)
+	s.Equal(large[:20], v)
+	s.Nil(cs.Delete(ctx, txn, []byte("large")))
+	s.Nil(txn.Commit(ctx))
+
+	txn, err = s.store.Begin()
+	s.Nil(err)
+	it, err := txn.Iter([]byte("large"), []byte("small"))
+	s.Nil(err)
+	s.False(it.Valid())
+	it.Close()
+
+	// A missing chunk is reported.
+	s.Nil(txn.Set([]byte("broken"), chunked.Manifest{Size: 20, Chunks: 2}.Encode()))
+	s.Nil(txn.Set(chunked.ChunkKey([]byte("broken"), 0), large[:10]))
+	_, err = cs.Get(ctx, txn, []byte("broken"))
+	s.ErrorContains(err, "chunk 1")
+	s.Nil(txn.Rollback())
+}
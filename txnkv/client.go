@@ -22,10 +22,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
 	"github.com/tikv/client-go/v2/util"
+	"google.golang.org/grpc"
 )
 
 // Client is a txn client.
@@ -34,9 +36,14 @@ type Client struct {
 }
 
 type option struct {
-	apiVersion   kvrpcpb.APIVersion
-	keyspaceName string
-	spKVPrefix   string
+	apiVersion       kvrpcpb.APIVersion
+	apiVersionSet    bool
+	detectAPIVersion bool
+	keyspaceName     string
+	spKVPrefix       string
+	admission        tikv.AdmissionController
+	rpcOpts          []tikv.ClientOpt
+	storeOpts        []tikv.Option
 }
 
 // ClientOpt is factory to set the client options.
@@ -53,6 +60,16 @@ func WithKeyspace(keyspaceName string) ClientOpt {
 func WithAPIVersion(apiVersion kvrpcpb.APIVersion) ClientOpt {
 	return func(opt *option) {
 		opt.apiVersion = apiVersion
+		opt.apiVersionSet = true
+	}
+}
+
+// WithAPIVersionDetection is used to detect the api version from the storage config of the TiKV cluster when creating
+// the client. If the api version is also set by WithAPIVersion, the client fails to create with ErrAPIVersionMismatch
+// if it doesn't match the cluster. Otherwise, the detected api version is used.
+func WithAPIVersionDetection() ClientOpt {
+	return func(opt *option) {
+		opt.detectAPIVersion = true
 	}
 }
 
@@ -63,6 +80,58 @@ func WithSafePointKVPrefix(prefix string) ClientOpt {
 	}
 }
 
+// WithAdmissionController is used to set the admission controller of the requests to TiKV.
+func WithAdmissionController(controller tikv.AdmissionController) ClientOpt {
+	return func(opt *option) {
+		opt.admission = controller
+	}
+}
+
+// WithUnaryInterceptors appends the unary interceptors to the connections to TiKV.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOpt {
+	return func(opt *option) {
+		opt.rpcOpts = append(opt.rpcOpts, tikv.WithUnaryInterceptors(interceptors...))
+	}
+}
+
+// WithStreamInterceptors appends the stream interceptors to the connections to TiKV.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) ClientOpt {
+	return func(opt *option) {
+		opt.rpcOpts = append(opt.rpcOpts, tikv.WithStreamInterceptors(interceptors...))
+	}
+}
+
+// WithCredentialProvider is used to attach the credentials of the provider to every request to TiKV over TLS.
+func WithCredentialProvider(provider tikv.CredentialProvider) ClientOpt {
+	return func(opt *option) {
+		opt.rpcOpts = append(opt.rpcOpts, tikv.WithCredentialProvider(provider))
+	}
+}
+
+// WithInsecureCredentialProvider is like WithCredentialProvider, but also sends the credentials over the insecure
+// connections.
+func WithInsecureCredentialProvider(provider tikv.CredentialProvider) ClientOpt {
+	return func(opt *option) {
+		opt.rpcOpts = append(opt.rpcOpts, tikv.WithInsecureCredentialProvider(provider))
+	}
+}
+
+// WithDefaultResourceGroup sets the resource group of the transactions and snapshots of the client, see
+// tikv.WithDefaultResourceGroup.
+func WithDefaultResourceGroup(name string) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithDefaultResourceGroup(name))
+	}
+}
+
+// WithValueTransformers sets the transformers of the values of the transactions and snapshots of the client, see
+// tikv.WithValueTransformers.
+func WithValueTransformers(t *kv.ValueTransformers) ClientOpt {
+	return func(opt *option) {
+		opt.storeOpts = append(opt.storeOpts, tikv.WithValueTransformers(t))
+	}
+}
+
 // NewClient creates a txn client with pdAddrs.
 func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 	// Apply options.
@@ -78,6 +147,19 @@ func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 
 	pdClient = util.NewInterceptedPDClient(pdClient)
 
+	if opt.detectAPIVersion {
+		security := config.GetGlobalConfig().Security
+		if opt.apiVersionSet {
+			err = tikv.CheckAPIVersion(context.TODO(), pdClient, security, tikv.ModeTxn, opt.apiVersion)
+		} else {
+			opt.apiVersion, err = tikv.DetectAPIVersion(context.TODO(), pdClient, security, tikv.ModeTxn)
+		}
+		if err != nil {
+			pdClient.Close()
+			return nil, err
+		}
+	}
+
 	// Construct codec from options.
 	var codecCli *tikv.CodecPDClient
 	switch opt.apiVersion {
@@ -107,9 +189,14 @@ func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 		return nil, err
 	}
 
-	rpcClient := tikv.NewRPCClient(tikv.WithSecurity(cfg.Security), tikv.WithCodec(codecCli.GetCodec()))
+	rpcOpts := []tikv.ClientOpt{tikv.WithSecurity(cfg.Security), tikv.WithCodec(codecCli.GetCodec())}
+	if opt.admission != nil {
+		rpcOpts = append(rpcOpts, tikv.WithAdmissionController(opt.admission))
+	}
+	rpcOpts = append(rpcOpts, opt.rpcOpts...)
+	rpcClient := tikv.NewRPCClient(rpcOpts...)
 
-	s, err := tikv.NewKVStore(uuid, pdClient, spkv, rpcClient)
+	s, err := tikv.NewKVStore(uuid, pdClient, spkv, rpcClient, opt.storeOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +206,12 @@ func NewClient(pdAddrs []string, opts ...ClientOpt) (*Client, error) {
 	return &Client{KVStore: s}, nil
 }
 
+// NewClientForKeyspace creates a txn client in API v2 bound to the keyspace with the given name.
+func NewClientForKeyspace(pdAddrs []string, keyspaceName string, opts ...ClientOpt) (*Client, error) {
+	opts = append(opts, WithAPIVersion(kvrpcpb.APIVersion_V2), WithKeyspace(keyspaceName))
+	return NewClient(pdAddrs, opts...)
+}
+
 // GetTimestamp returns the current global timestamp.
 func (c *Client) GetTimestamp(ctx context.Context) (uint64, error) {
 	bo := retry.NewBackofferWithVars(ctx, transaction.TsoMaxBackoff, nil)
@@ -0,0 +1,324 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package locks implements a lease-based distributed lock on top of TiKV transactions.
+//
+// A lock is a single key whose value records the current holder, the lease
+// expiration and a fencing token. The token is increased every time the lock is
+// acquired, so a resource protected by the lock can reject requests from a
+// previous holder whose lease has expired. Lease expiration is measured with
+// the physical part of TSO timestamps, so it doesn't depend on the local clocks.
+package locks
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is the default lease duration of a lock.
+	DefaultTTL = 10 * time.Second
+	// DefaultRetryInterval is the default interval between two attempts of Mutex.Lock.
+	DefaultRetryInterval = 100 * time.Millisecond
+
+	// minHeartbeatInterval is the min interval between two renewals of a lease, so a tiny TTL doesn't make the
+	// heartbeat spin.
+	minHeartbeatInterval = 10 * time.Millisecond
+
+	recordHeaderLen = 16
+)
+
+// ErrLockHeld is returned by TryLock when the lock is held by another owner.
+type ErrLockHeld struct {
+	Key []byte
+	// Owner is the current holder of the lock, it's empty if the lock was acquired
+	// concurrently and the holder is unknown.
+	Owner string
+}
+
+func (e *ErrLockHeld) Error() string {
+	return fmt.Sprintf("lock %q is held by %q", e.Key, e.Owner)
+}
+
+// ErrLeaseLost is returned by Lease.Unlock when the lease has been lost.
+var ErrLeaseLost = errors.New("lock lease lost")
+
+// Storage is the subset of tikv.KVStore used by Mutex.
+type Storage interface {
+	Begin(opts ...tikv.TxnOption) (*transaction.KVTxn, error)
+}
+
+// Option configures a Mutex.
+type Option func(*Mutex)
+
+// WithTTL sets the lease duration of the lock.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Mutex) {
+		if ttl > 0 {
+			m.ttl = ttl
+		}
+	}
+}
+
+// WithOwner sets the owner name written to the lock record. A random UUID is used by default.
+func WithOwner(owner string) Option {
+	return func(m *Mutex) {
+		m.owner = owner
+	}
+}
+
+// WithRetryInterval sets the interval between two attempts of Mutex.Lock.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(m *Mutex) {
+		if interval > 0 {
+			m.retryInterval = interval
+		}
+	}
+}
+
+// Mutex is a distributed lock stored in a TiKV key.
+type Mutex struct {
+	store         Storage
+	key           []byte
+	owner         string
+	ttl           time.Duration
+	retryInterval time.Duration
+}
+
+// NewMutex creates a Mutex stored in key.
+func NewMutex(store Storage, key []byte, opts ...Option) *Mutex {
+	m := &Mutex{
+		store:         store,
+		key:           key,
+		owner:         uuid.New().String(),
+		ttl:           DefaultTTL,
+		retryInterval: DefaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Owner returns the owner name of the Mutex.
+func (m *Mutex) Owner() string {
+	return m.owner
+}
+
+// TryLock tries to acquire the lock once. It returns *ErrLockHeld if the lock is
+// held by another owner. On success, the lease is renewed in background until
+// Unlock is called or the lease is lost.
+func (m *Mutex) TryLock(ctx context.Context) (*Lease, error) {
+	token, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newLease(m, token), nil
+}
+
+// Lock acquires the lock, waiting until it's released or expired, or ctx is done.
+func (m *Mutex) Lock(ctx context.Context) (*Lease, error) {
+	for {
+		lease, err := m.TryLock(ctx)
+		var held *ErrLockHeld
+		if err == nil || !errors.As(err, &held) {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.WithStack(ctx.Err())
+		case <-time.After(m.retryInterval):
+		}
+	}
+}
+
+type record struct {
+	token    uint64
+	expireMs uint64
+	owner    string
+}
+
+func (r *record) encode() []byte {
+	buf := make([]byte, recordHeaderLen, recordHeaderLen+len(r.owner))
+	binary.BigEndian.PutUint64(buf, r.token)
+	binary.BigEndian.PutUint64(buf[8:], r.expireMs)
+	return append(buf, r.owner...)
+}
+
+func decodeRecord(val []byte) (*record, error) {
+	if len(val) < recordHeaderLen {
+		return nil, errors.New("invalid lock record")
+	}
+	return &record{
+		token:    binary.BigEndian.Uint64(val),
+		expireMs: binary.BigEndian.Uint64(val[8:]),
+		owner:    string(val[recordHeaderLen:]),
+	}, nil
+}
+
+// update runs fn against the current lock record in a transaction and writes the
+// returned record. now is the physical time of the transaction in milliseconds.
+func (m *Mutex) update(ctx context.Context, fn func(cur *record, now uint64) (*record, error)) error {
+	txn, err := m.store.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if txn.Valid() {
+			_ = txn.Rollback()
+		}
+	}()
+	txn.SetEnable1PC(true)
+
+	var cur *record
+	val, err := txn.Get(ctx, m.key)
+	if err != nil && !tikverr.IsErrNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if cur, err = decodeRecord(val); err != nil {
+			return errors.Wrapf(err, "key %q", m.key)
+		}
+	}
+	next, err := fn(cur, uint64(oracle.ExtractPhysical(txn.StartTS())))
+	if err != nil {
+		return err
+	}
+	if err = txn.Set(m.key, next.encode()); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
+
+func (m *Mutex) acquire(ctx context.Context) (token uint64, err error) {
+	err = m.update(ctx, func(cur *record, now uint64) (*record, error) {
+		if cur != nil && cur.expireMs > now && cur.owner != m.owner {
+			return nil, &ErrLockHeld{Key: m.key, Owner: cur.owner}
+		}
+		token = 1
+		if cur != nil {
+			token = cur.token + 1
+		}
+		return &record{token: token, expireMs: now + uint64(m.ttl.Milliseconds()), owner: m.owner}, nil
+	})
+	if tikverr.IsErrWriteConflict(err) {
+		// Someone else acquired or renewed the lock concurrently.
+		return 0, &ErrLockHeld{Key: m.key}
+	}
+	return token, err
+}
+
+// renew extends the lease, or releases it if release is true.
+func (m *Mutex) renew(ctx context.Context, token uint64, release bool) error {
+	return m.update(ctx, func(cur *record, now uint64) (*record, error) {
+		if cur == nil || cur.token != token || cur.owner != m.owner || cur.expireMs == 0 || (!release && cur.expireMs <= now) {
+			return nil, ErrLeaseLost
+		}
+		next := *cur
+		if release {
+			next.expireMs = 0
+		} else {
+			next.expireMs = now + uint64(m.ttl.Milliseconds())
+		}
+		return &next, nil
+	})
+}
+
+// Lease is a held lock. Its lease is renewed in background at a third of the TTL.
+type Lease struct {
+	mutex *Mutex
+	token uint64
+
+	lost     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newLease(m *Mutex, token uint64) *Lease {
+	l := &Lease{
+		mutex: m,
+		token: token,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+	l.wg.Add(1)
+	go l.heartbeat()
+	return l
+}
+
+// Token returns the fencing token of the lease. Tokens of the same lock are
+// strictly increasing across acquisitions.
+func (l *Lease) Token() uint64 {
+	return l.token
+}
+
+// Lost returns a channel which is closed when the lease can no longer be renewed,
+// which means the lock may have been acquired by another owner.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock stops renewing the lease and releases the lock. It returns ErrLeaseLost
+// if the lock is no longer held by this lease.
+func (l *Lease) Unlock(ctx context.Context) error {
+	l.stopHeartbeat()
+	return l.mutex.renew(ctx, l.token, true)
+}
+
+func (l *Lease) stopHeartbeat() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	l.wg.Wait()
+}
+
+func (l *Lease) heartbeat() {
+	defer l.wg.Done()
+	ttl := l.mutex.ttl
+	interval := max(ttl/3, minHeartbeatInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastRenew := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := l.mutex.renew(ctx, l.token, false)
+		cancel()
+		if err == nil {
+			lastRenew = time.Now()
+			continue
+		}
+		logutil.BgLogger().Warn("renew lock lease failed",
+			zap.ByteString("key", l.mutex.key), zap.Uint64("token", l.token), zap.Error(err))
+		if errors.Is(err, ErrLeaseLost) || time.Since(lastRenew) >= ttl {
+			close(l.lost)
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+func TestLocks(t *testing.T) {
+	suite.Run(t, new(testLocksSuite))
+}
+
+type testLocksSuite struct {
+	suite.Suite
+	store *tikv.KVStore
+}
+
+func (s *testLocksSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+}
+
+func (s *testLocksSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testLocksSuite) TestTryLockAndUnlock() {
+	ctx := context.Background()
+	m1 := NewMutex(s.store, []byte("lock"), WithOwner("m1"))
+	m2 := NewMutex(s.store, []byte("lock"), WithOwner("m2"))
+
+	l1, err := m1.TryLock(ctx)
+	s.Nil(err)
+	s.Equal(uint64(1), l1.Token())
+
+	_, err = m2.TryLock(ctx)
+	var held *ErrLockHeld
+	s.True(errors.As(err, &held))
+	s.Equal("m1", held.Owner)
+
+	s.Nil(l1.Unlock(ctx))
+	s.ErrorIs(l1.Unlock(ctx), ErrLeaseLost)
+
+	l2, err := m2.Lock(ctx)
+	s.Nil(err)
+	s.Equal(uint64(2), l2.Token())
+	s.Nil(l2.Unlock(ctx))
+}
+
+func (s *testLocksSuite) TestLeaseExpire() {
+	ctx := context.Background()
+	ttl := 300 * time.Millisecond
+	m1 := NewMutex(s.store, []byte("lock"), WithTTL(ttl))
+	m2 := NewMutex(s.store, []byte("lock"), WithTTL(ttl), WithRetryInterval(10*time.Millisecond))
+
+	l1, err := m1.TryLock(ctx)
+	s.Nil(err)
+	// The heartbeat keeps the lease alive for longer than the TTL.
+	time.Sleep(2 * ttl)
+	_, err = m2.TryLock(ctx)
+	s.NotNil(err)
+
+	// Simulate a crashed holder.
+	l1.stopHeartbeat()
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	l2, err := m2.Lock(lockCtx)
+	s.Nil(err)
+	s.Greater(l2.Token(), l1.Token())
+	s.ErrorIs(l1.Unlock(ctx), ErrLeaseLost)
+	s.Nil(l2.Unlock(ctx))
+
+	// The heartbeat interval of a tiny TTL is clamped.
+	m3 := NewMutex(s.store, []byte("lock"), WithTTL(time.Nanosecond))
+	l3, err := m3.TryLock(ctx)
+	s.Nil(err)
+	time.Sleep(3 * minHeartbeatInterval)
+	l3.stopHeartbeat()
+}
@@ -0,0 +1,163 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rangetask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// CheckpointStore persists the ranges completed by a Runner, so a long running task can be resumed after the process
+// restarts without processing the completed ranges again. The checkpoints are keyed by the identifier of the runner.
+type CheckpointStore interface {
+	// LoadCompleted returns the ranges completed by the runner.
+	LoadCompleted(ctx context.Context, id string) ([]kv.KeyRange, error)
+	// SaveCompleted persists a range completed by the runner. It may be called concurrently.
+	SaveCompleted(ctx context.Context, id string, r kv.KeyRange) error
+	// Clear removes the checkpoints of the runner after the whole range is completed.
+	Clear(ctx context.Context, id string) error
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps the checkpoints in a JSON file.
+type FileCheckpointStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore writing to the file at path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+func (s *FileCheckpointStore) load() (map[string][]kv.KeyRange, error) {
+	checkpoints := make(map[string][]kv.KeyRange)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return checkpoints, nil
+}
+
+func (s *FileCheckpointStore) save(checkpoints map[string][]kv.KeyRange) error {
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Write to a temporary file and rename it, so the checkpoints are not corrupted if the process crashes.
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, s.path))
+}
+
+// LoadCompleted implements CheckpointStore.
+func (s *FileCheckpointStore) LoadCompleted(_ context.Context, id string) ([]kv.KeyRange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoints, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return checkpoints[id], nil
+}
+
+// SaveCompleted implements CheckpointStore.
+func (s *FileCheckpointStore) SaveCompleted(_ context.Context, id string, r kv.KeyRange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoints, err := s.load()
+	if err != nil {
+		return err
+	}
+	checkpoints[id] = mergeRanges(append(checkpoints[id], r))
+	return s.save(checkpoints)
+}
+
+// Clear implements CheckpointStore.
+func (s *FileCheckpointStore) Clear(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoints, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := checkpoints[id]; !ok {
+		return nil
+	}
+	delete(checkpoints, id)
+	return s.save(checkpoints)
+}
+
+// mergeRanges sorts the ranges and merges the overlapping and adjacent ones. Empty end keys mean unbounded.
+func mergeRanges(ranges []kv.KeyRange) []kv.KeyRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].StartKey, ranges[j].StartKey) < 0
+	})
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if len(last.EndKey) == 0 {
+				break
+			}
+			if bytes.Compare(r.StartKey, last.EndKey) <= 0 {
+				if len(r.EndKey) == 0 || bytes.Compare(r.EndKey, last.EndKey) > 0 {
+					last.EndKey = r.EndKey
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subtractRanges returns the parts of r not covered by the completed ranges, which must be merged by mergeRanges.
+func subtractRanges(r kv.KeyRange, completed []kv.KeyRange) []kv.KeyRange {
+	var result []kv.KeyRange
+	cur := r.StartKey
+	for _, c := range completed {
+		if len(c.EndKey) > 0 && bytes.Compare(c.EndKey, cur) <= 0 {
+			continue
+		}
+		if len(r.EndKey) > 0 && bytes.Compare(c.StartKey, r.EndKey) >= 0 {
+			break
+		}
+		if bytes.Compare(c.StartKey, cur) > 0 {
+			result = append(result, kv.KeyRange{StartKey: cur, EndKey: c.StartKey})
+		}
+		if len(c.EndKey) == 0 {
+			return result
+		}
+		cur = c.EndKey
+	}
+	if len(r.EndKey) == 0 || bytes.Compare(cur, r.EndKey) < 0 {
+		result = append(result, kv.KeyRange{StartKey: cur, EndKey: r.EndKey})
+	}
+	return result
+}
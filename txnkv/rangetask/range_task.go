@@ -71,6 +71,7 @@ type Runner struct {
 	handler         TaskHandler
 	statLogInterval time.Duration
 	regionsPerTask  int
+	checkpoint      CheckpointStore
 
 	completedRegions int32
 	failedRegions    int32
@@ -135,6 +136,12 @@ func (s *Runner) SetStatLogInterval(interval time.Duration) {
 	s.statLogInterval = interval
 }
 
+// SetCheckpointStore sets the store to persist the completed ranges, which are skipped when the runner with the same
+// identifier runs again. The checkpoints are cleared after the whole range is completed.
+func (s *Runner) SetCheckpointStore(store CheckpointStore) {
+	s.checkpoint = store
+}
+
 // SetRegionsPerTask sets how many regions is in a divided task. Since regions may split and merge, it's possible that
 // a sub task contains not exactly specified number of regions.
 func (s *Runner) SetRegionsPerTask(regionsPerTask int) {
@@ -165,11 +172,21 @@ func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error
 		return nil
 	}
 
+	var completed []kv.KeyRange
+	if s.checkpoint != nil {
+		loaded, err := s.checkpoint.LoadCompleted(ctx, s.identifier)
+		if err != nil {
+			return err
+		}
+		completed = mergeRanges(loaded)
+	}
+
 	logutil.Logger(ctx).Info("range task started",
 		zap.String("name", s.identifier),
 		zap.String("startKey", redact.Key(startKey)),
 		zap.String("endKey", redact.Key(endKey)),
-		zap.Int("concurrency", s.concurrency))
+		zap.Int("concurrency", s.concurrency),
+		zap.Int("checkpoints", len(completed)))
 
 	// Periodically log the progress
 	statLogTicker := time.NewTicker(s.statLogInterval)
@@ -203,6 +220,7 @@ func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error
 
 	// Iterate all regions and send each region's range as a task to the workers.
 	key := startKey
+	allPushed := false
 Loop:
 	for {
 		select {
@@ -243,14 +261,18 @@ Loop:
 
 		pushTaskStartTime := time.Now()
 
-		select {
-		case taskCh <- task:
-		case <-ctx.Done():
-			break Loop
+		// Skip the parts completed before.
+		for _, r := range subtractRanges(*task, completed) {
+			select {
+			case taskCh <- &r:
+			case <-ctx.Done():
+				break Loop
+			}
 		}
 		metrics.TiKVRangeTaskPushDuration.WithLabelValues(s.name).Observe(time.Since(pushTaskStartTime).Seconds())
 
 		if isLast {
+			allPushed = true
 			break
 		}
 
@@ -274,6 +296,15 @@ Loop:
 		}
 	}
 
+	// The checkpoints are kept for resuming unless every subrange is completed.
+	if s.checkpoint != nil && allPushed && ctx.Err() == nil {
+		if err := s.checkpoint.Clear(ctx, s.identifier); err != nil {
+			logutil.Logger(ctx).Warn("failed to clear range task checkpoints",
+				zap.String("name", s.identifier),
+				zap.Error(err))
+		}
+	}
+
 	logutil.Logger(ctx).Info("range task finished",
 		zap.String("name", s.identifier),
 		zap.String("startKey", redact.Key(startKey)),
@@ -291,6 +322,7 @@ func (s *Runner) createWorker(taskCh chan *kv.KeyRange, wg *sync.WaitGroup) *ran
 		identifier: s.identifier,
 		store:      s.store,
 		handler:    s.handler,
+		checkpoint: s.checkpoint,
 		taskCh:     taskCh,
 		wg:         wg,
 
@@ -317,6 +349,7 @@ type rangeTaskWorker struct {
 	identifier string
 	store      storage
 	handler    TaskHandler
+	checkpoint CheckpointStore
 	taskCh     chan *kv.KeyRange
 	wg         *sync.WaitGroup
 
@@ -354,5 +387,15 @@ func (w *rangeTaskWorker) run(ctx context.Context, cancel context.CancelFunc) {
 			cancel()
 			break
 		}
+		if w.checkpoint != nil {
+			// Failing to save the checkpoint only makes the range processed again after restarts.
+			if err = w.checkpoint.SaveCompleted(ctx, w.identifier, *r); err != nil {
+				logutil.Logger(ctx).Warn("failed to save range task checkpoint",
+					zap.String("name", w.identifier),
+					zap.String("startKey", redact.Key(r.StartKey)),
+					zap.String("endKey", redact.Key(r.EndKey)),
+					zap.Error(err))
+			}
+		}
 	}
 }
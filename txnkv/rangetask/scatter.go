@@ -0,0 +1,182 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rangetask
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util"
+)
+
+const regionTaskMaxBackoff = 100000
+
+// RegionTask is the part of a range located in a single region.
+type RegionTask struct {
+	kv.KeyRange
+	// Region is the region the range was located in, it may be stale when the task is processed.
+	Region locate.RegionVerID
+}
+
+// RegionTaskFunc processes a RegionTask. If the response of the region has a region error, it should be returned
+// as regionErr, then the range is located again and retried after backing off. Returning err stops all the tasks.
+// The function may be called concurrently.
+type RegionTaskFunc = func(bo *retry.Backoffer, task RegionTask) (regionErr *errorpb.Error, err error)
+
+// RangeTasksStat is the statistics of RunRangeTasks.
+type RangeTasksStat struct {
+	// CompletedRegions is the number of the region tasks done.
+	CompletedRegions int
+	// RegionErrors is the number of the region errors retried.
+	RegionErrors int
+	// Backoff is the total backoff time of all the region tasks.
+	Backoff time.Duration
+	// Duration is the time spent on all the ranges.
+	Duration time.Duration
+}
+
+type rangeTasksStat struct {
+	completedRegions atomic.Int64
+	regionErrors     atomic.Int64
+	backoffMs        atomic.Int64
+}
+
+// RunRangeTasks splits the ranges by the regions and calls fn on each region task with at most concurrency workers.
+// The region errors returned by fn are retried by locating the range again, so a task may be split if the region has
+// been split. Empty end keys mean unbounded. It stops at the first error returned by fn or when ctx is done, and
+// returns the statistics of the tasks done so far.
+func RunRangeTasks(ctx context.Context, store storage, ranges []kv.KeyRange, concurrency int, fn RegionTaskFunc) (RangeTasksStat, error) {
+	if concurrency < 1 {
+		return RangeTasksStat{}, errors.Errorf("concurrency should be at least 1, got %d", concurrency)
+	}
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		stat     rangeTasksStat
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	taskCh := make(chan kv.KeyRange, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range taskCh {
+				if err := runRegionTasks(ctx, store, r, fn, &stat); err != nil {
+					setErr(err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Split the ranges by the cached regions, the tasks are located again before being processed.
+	for _, r := range ranges {
+		if len(r.EndKey) > 0 && bytes.Compare(r.StartKey, r.EndKey) >= 0 {
+			continue
+		}
+		bo := NewLocateRegionBackoffer(ctx)
+		locs, err := store.GetRegionCache().LocateKeyRange(bo, r.StartKey, r.EndKey)
+		if err != nil {
+			setErr(err)
+			break
+		}
+		err = util.SplitRangeByRegions(r.StartKey, r.EndKey, locate.NextKeyLocation(locs),
+			func(_ *locate.KeyLocation, start, end []byte) error {
+				select {
+				case taskCh <- kv.KeyRange{StartKey: start, EndKey: end}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		if err != nil {
+			if ctx.Err() == nil {
+				setErr(err)
+			}
+			break
+		}
+	}
+	close(taskCh)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return RangeTasksStat{
+		CompletedRegions: int(stat.completedRegions.Load()),
+		RegionErrors:     int(stat.regionErrors.Load()),
+		Backoff:          time.Duration(stat.backoffMs.Load()) * time.Millisecond,
+		Duration:         time.Since(start),
+	}, errors.WithStack(firstErr)
+}
+
+// runRegionTasks calls fn on each region in the range, retrying the region errors.
+func runRegionTasks(ctx context.Context, store storage, r kv.KeyRange, fn RegionTaskFunc, stat *rangeTasksStat) error {
+	bo := retry.NewBackofferWithVars(ctx, regionTaskMaxBackoff, nil)
+	defer func() {
+		stat.backoffMs.Add(int64(bo.GetTotalSleep()))
+	}()
+	return splitRegionTasks(ctx, bo, store, r, fn, stat)
+}
+
+// splitRegionTasks splits the range by the regions and calls fn on each of them. The part of a region meeting a
+// region error is split again after backing off, as the region may have been split.
+func splitRegionTasks(ctx context.Context, bo *retry.Backoffer, store storage, r kv.KeyRange, fn RegionTaskFunc,
+	stat *rangeTasksStat) error {
+	return util.SplitRangeByRegions(r.StartKey, r.EndKey, func(key []byte) (*locate.KeyLocation, []byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		loc, err := store.GetRegionCache().LocateKey(bo, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return loc, loc.EndKey, nil
+	}, func(loc *locate.KeyLocation, start, end []byte) error {
+		task := RegionTask{KeyRange: kv.KeyRange{StartKey: start, EndKey: end}, Region: loc.Region}
+		regionErr, err := fn(bo, task)
+		if err != nil {
+			return err
+		}
+		if regionErr != nil {
+			stat.regionErrors.Add(1)
+			store.GetRegionCache().InvalidateCachedRegion(loc.Region)
+			if err = bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String())); err != nil {
+				return err
+			}
+			return splitRegionTasks(ctx, bo, store, task.KeyRange, fn, stat)
+		}
+		stat.completedRegions.Add(1)
+		return nil
+	})
+}
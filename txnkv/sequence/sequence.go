@@ -0,0 +1,184 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequence
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+const (
+	// DefaultCacheSize is the number of IDs allocated from TiKV at once in NonMonotonic mode.
+	DefaultCacheSize = 1000
+
+	allocMaxBackoff = 20000
+)
+
+// Storage is the subset of tikv.KVStore used by Allocator.
+type Storage interface {
+	Begin(opts ...tikv.TxnOption) (*transaction.KVTxn, error)
+}
+
+// Mode controls the ordering guarantee of the allocated IDs.
+type Mode int
+
+const (
+	// NonMonotonic allocates IDs in batches and serves them from a local cache.
+	// IDs are unique across all allocators sharing the key, and increasing within
+	// one allocator, but not increasing across allocators.
+	NonMonotonic Mode = iota
+	// Monotonic allocates every ID from TiKV, so IDs are increasing across all
+	// allocators sharing the key at the cost of one transaction per allocation.
+	Monotonic
+)
+
+// Option configures an Allocator.
+type Option func(*Allocator)
+
+// WithCacheSize sets how many IDs are allocated from TiKV at once in NonMonotonic mode.
+func WithCacheSize(size uint64) Option {
+	return func(a *Allocator) {
+		if size > 0 {
+			a.cacheSize = size
+		}
+	}
+}
+
+// WithMode sets the mode of the allocator.
+func WithMode(mode Mode) Option {
+	return func(a *Allocator) {
+		a.mode = mode
+	}
+}
+
+// Allocator allocates IDs from a sequence whose state is persisted in a single TiKV key.
+// The key stores the largest allocated ID as an 8-byte big-endian integer. IDs start from 1.
+type Allocator struct {
+	store     Storage
+	key       []byte
+	cacheSize uint64
+	mode      Mode
+
+	mu struct {
+		sync.Mutex
+		// next and end are the cached ID range [next, end).
+		next uint64
+		end  uint64
+	}
+}
+
+// NewAllocator creates an Allocator that persists its state in key.
+func NewAllocator(store Storage, key []byte, opts ...Option) *Allocator {
+	a := &Allocator{
+		store:     store,
+		key:       key,
+		cacheSize: DefaultCacheSize,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Next returns the next ID of the sequence.
+func (a *Allocator) Next(ctx context.Context) (uint64, error) {
+	return a.NextN(ctx, 1)
+}
+
+// NextN reserves n consecutive IDs and returns the first one, the reserved IDs
+// are [first, first+n).
+func (a *Allocator) NextN(ctx context.Context, n uint64) (first uint64, err error) {
+	if n == 0 {
+		return 0, errors.New("sequence: cannot allocate 0 IDs")
+	}
+	if a.mode == Monotonic {
+		return a.alloc(ctx, n)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.mu.end-a.mu.next < n {
+		// The rest of the cached range is dropped to keep the returned IDs consecutive.
+		batch := max(n, a.cacheSize)
+		first, err = a.alloc(ctx, batch)
+		if err != nil {
+			return 0, err
+		}
+		a.mu.next, a.mu.end = first, first+batch
+	}
+	first = a.mu.next
+	a.mu.next += n
+	return first, nil
+}
+
+func (a *Allocator) alloc(ctx context.Context, n uint64) (uint64, error) {
+	bo := retry.NewBackofferWithVars(ctx, allocMaxBackoff, nil)
+	for {
+		first, err := a.allocOnce(ctx, n)
+		if err == nil {
+			return first, nil
+		}
+		var latchErr *tikverr.ErrWriteConflictInLatch
+		if !tikverr.IsErrWriteConflict(err) && !errors.As(err, &latchErr) {
+			return 0, err
+		}
+		if err = bo.Backoff(retry.BoWriteConflict, err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (a *Allocator) allocOnce(ctx context.Context, n uint64) (uint64, error) {
+	txn, err := a.store.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if txn.Valid() {
+			_ = txn.Rollback()
+		}
+	}()
+	txn.SetEnable1PC(true)
+
+	var base uint64
+	val, err := txn.Get(ctx, a.key)
+	if err != nil && !tikverr.IsErrNotFound(err) {
+		return 0, err
+	}
+	if err == nil {
+		if len(val) != 8 {
+			return 0, errors.Errorf("sequence: invalid value of key %q", a.key)
+		}
+		base = binary.BigEndian.Uint64(val)
+	}
+	if base > math.MaxUint64-n {
+		return 0, errors.Errorf("sequence: key %q is exhausted", a.key)
+	}
+	if err = txn.Set(a.key, binary.BigEndian.AppendUint64(nil, base+n)); err != nil {
+		return 0, err
+	}
+	if err = txn.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return base + 1, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sequence
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+func TestSequence(t *testing.T) {
+	suite.Run(t, new(testSequenceSuite))
+}
+
+type testSequenceSuite struct {
+	suite.Suite
+	store *tikv.KVStore
+}
+
+func (s *testSequenceSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+}
+
+func (s *testSequenceSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testSequenceSuite) TestNonMonotonic() {
+	ctx := context.Background()
+	a1 := NewAllocator(s.store, []byte("seq"), WithCacheSize(10))
+	a2 := NewAllocator(s.store, []byte("seq"), WithCacheSize(10))
+
+	id, err := a1.Next(ctx)
+	s.Nil(err)
+	s.Equal(uint64(1), id)
+	id, err = a2.Next(ctx)
+	s.Nil(err)
+	s.Equal(uint64(11), id)
+	id, err = a1.Next(ctx)
+	s.Nil(err)
+	s.Equal(uint64(2), id)
+
+	// A request larger than the rest of the cache allocates a new range.
+	id, err = a1.NextN(ctx, 9)
+	s.Nil(err)
+	s.Equal(uint64(21), id)
+	id, err = a1.Next(ctx)
+	s.Nil(err)
+	s.Equal(uint64(30), id)
+}
+
+func (s *testSequenceSuite) TestMonotonicConcurrent() {
+	ctx := context.Background()
+
+	const workers, perWorker = 4, 10
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[uint64]struct{})
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := NewAllocator(s.store, []byte("seq"), WithMode(Monotonic))
+			last := uint64(0)
+			for j := 0; j < perWorker; j++ {
+				id, err := a.Next(ctx)
+				s.Nil(err)
+				s.Greater(id, last)
+				last = id
+				mu.Lock()
+				seen[id] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	s.Len(seen, workers*perWorker)
+	for id := uint64(1); id <= workers*perWorker; id++ {
+		s.Contains(seen, id)
+	}
+}
@@ -121,6 +121,9 @@ type kvstore interface {
 	IsClose() bool
 	// Go run the function in a separate goroutine.
 	Go(f func()) error
+	// GetLockCleanupScheduler returns the scheduler cleaning up locks of failed transactions in
+	// background, nil if it's not enabled.
+	GetLockCleanupScheduler() *LockCleanupScheduler
 }
 
 // twoPhaseCommitter executes a two-phase commit protocol.
@@ -138,6 +141,11 @@ type twoPhaseCommitter struct {
 	txnSize             int
 	hasNoNeedCommitKeys bool
 	resourceGroupName   string
+	// dryRun makes initKeysAndMutations leave the transaction and the metrics untouched, see KVTxn.DryRun.
+	dryRun bool
+	// insertOnly tells whether all the mutations are inserts, whose prewrite requests are batched by
+	// TxnInsertOnlyCommitBatchSize if it's set.
+	insertOnly bool
 
 	primaryKey  []byte
 	forUpdateTS uint64
@@ -549,7 +557,7 @@ func (c *twoPhaseCommitter) checkSchemaOnAssertionFail(ctx context.Context, asse
 }
 
 func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
-	var size, putCnt, delCnt, lockCnt, checkCnt int
+	var size, putCnt, insertCnt, delCnt, lockCnt, checkCnt int
 
 	txn := c.txn
 	memBuf := txn.GetMemBuffer().GetMemDB()
@@ -597,6 +605,7 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 					op = kvrpcpb.Op_Put
 					if flags.HasPresumeKeyNotExists() {
 						op = kvrpcpb.Op_Insert
+						insertCnt++
 					}
 					putCnt++
 				}
@@ -640,6 +649,9 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 		}
 		c.mutations.Push(op, isPessimistic, mustExist, mustNotExist, flags.HasNeedConstraintCheckInPrewrite(), it.Handle())
 		size += len(key) + len(value)
+		if err = txn.writeSizeLimits.check(key, value); err != nil {
+			return err
+		}
 
 		if c.txn.assertionLevel != kvrpcpb.AssertionLevel_Off {
 			// Check mutations for pessimistic-locked keys with the read results of pessimistic lock requests.
@@ -662,19 +674,23 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 				if err1 != nil && assertionError == nil {
 					assertionError = errors.WithStack(err1)
 					c.stashedAssertionError = assertionError
-					c.txn.enableAsyncCommit = false
-					c.txn.enable1PC = false
+					if !c.dryRun {
+						c.txn.enableAsyncCommit = false
+						c.txn.enable1PC = false
+					}
 				}
 			}
 
 			// Update metrics
-			if mustExist {
+			switch {
+			case c.dryRun:
+			case mustExist:
 				metrics.PrewriteAssertionUsageCounterExist.Inc()
-			} else if mustNotExist {
+			case mustNotExist:
 				metrics.PrewriteAssertionUsageCounterNotExist.Inc()
-			} else if hasAssertUnknown {
+			case hasAssertUnknown:
 				metrics.PrewriteAssertionUsageCounterUnknown.Inc()
-			} else {
+			default:
 				metrics.PrewriteAssertionUsageCounterNone.Inc()
 			}
 		}
@@ -684,6 +700,11 @@ func (c *twoPhaseCommitter) initKeysAndMutations(ctx context.Context) error {
 		}
 	}
 
+	c.insertOnly = insertCnt > 0 && insertCnt == c.mutations.Len()
+	if c.dryRun {
+		c.txnSize = size
+		return nil
+	}
 	for _, key := range toUpdatePrewriteOnly {
 		memBuf.UpdateFlags(key, kv.SetPrewriteOnly)
 	}
@@ -960,8 +981,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 
 	batchBuilder := newBatched(c.primary())
 	for _, group := range groups {
-		batchBuilder.appendBatchMutationsBySize(group.region, group.mutations, sizeFunc,
-			int(kv.TxnCommitBatchSize.Load()))
+		batchBuilder.appendBatchMutationsBySize(group.region, group.mutations, sizeFunc, c.batchSizeLimit(action))
 	}
 	firstIsPrimary := batchBuilder.setPrimary()
 
@@ -1002,8 +1022,10 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 	}
 
 	if firstIsPrimary &&
-		((actionIsCommit && !c.isAsyncCommit()) || actionIsCleanup || actionIsPessimisticLock) {
+		((actionIsCommit && !c.isAsyncCommit()) || actionIsCleanup || actionIsPessimisticLock ||
+			(actionIsPrewrite && c.txn.strictPrimaryFirst)) {
 		// primary should be committed(not async commit)/cleanup/pessimistically locked first
+		// and prewritten first if the txn requires strict primary-first ordering.
 		err = c.doActionOnBatches(bo, action, batchBuilder.primaryBatch())
 		if err != nil {
 			return err
@@ -1017,7 +1039,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 	util.EvalFailpoint("afterPrimaryBatch")
 
 	// Already spawned a goroutine for async commit transaction.
-	if actionIsCommit && !actionCommit.retry && !c.isAsyncCommit() {
+	if actionIsCommit && !actionCommit.retry && !c.isAsyncCommit() && !c.txn.syncCommitSecondaries {
 		secondaryBo := retry.NewBackofferWithVars(c.store.Ctx(), CommitSecondaryMaxBackoff, c.txn.vars)
 		if c.store.IsClose() {
 			logutil.Logger(bo.GetCtx()).Warn("the store is closed",
@@ -1025,7 +1047,10 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 				zap.Uint64("sessionID", c.sessionID))
 			return nil
 		}
+		c.txn.secondaryCommit.spawned()
 		err = c.txn.spawnWithStorePool(func() {
+			var e error
+			defer func() { c.txn.secondaryCommit.finish(e) }()
 			if c.sessionID > 0 {
 				if v, err := util.EvalFailpoint("beforeCommitSecondaries"); err == nil {
 					if s, ok := v.(string); !ok {
@@ -1040,7 +1065,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 				}
 			}
 
-			e := c.doActionOnBatches(secondaryBo, action, batchBuilder.allBatches())
+			e = c.doActionOnBatches(secondaryBo, action, batchBuilder.allBatches())
 			if e != nil {
 				logutil.BgLogger().Debug("2PC async doActionOnBatches",
 					zap.Uint64("session", c.sessionID),
@@ -1050,6 +1075,7 @@ func (c *twoPhaseCommitter) doActionOnGroupMutations(bo *retry.Backoffer, action
 			}
 		})
 		if err != nil {
+			c.txn.secondaryCommit.finish(err)
 			logutil.BgLogger().Error("fail to create goroutine",
 				zap.Uint64("session", c.sessionID),
 				zap.Stringer("action type", action),
@@ -1125,6 +1151,12 @@ func (c *twoPhaseCommitter) calcActionConcurrency(
 	switch action.(type) {
 	case actionPipelinedFlush:
 		rateLim = min(rateLim, max(1, c.txn.pipelinedFlushConcurrency))
+	case actionPrewrite, actionCommit:
+		if c.txn.secondaryConcurrency > 0 {
+			rateLim = min(rateLim, c.txn.secondaryConcurrency)
+		} else if rateLim > config.GetGlobalConfig().CommitterConcurrency {
+			rateLim = config.GetGlobalConfig().CommitterConcurrency
+		}
 	default:
 		if rateLim > config.GetGlobalConfig().CommitterConcurrency {
 			rateLim = config.GetGlobalConfig().CommitterConcurrency
@@ -1133,6 +1165,16 @@ func (c *twoPhaseCommitter) calcActionConcurrency(
 	return rateLim
 }
 
+// batchSizeLimit returns the size limit of a batch of the action.
+func (c *twoPhaseCommitter) batchSizeLimit(action twoPhaseCommitAction) int {
+	if _, ok := action.(actionPrewrite); ok && c.insertOnly {
+		if size := kv.TxnInsertOnlyCommitBatchSize.Load(); size > 0 {
+			return int(size)
+		}
+	}
+	return int(kv.TxnCommitBatchSize.Load())
+}
+
 func (c *twoPhaseCommitter) keyValueSize(key, value []byte) int {
 	return len(key) + len(value)
 }
@@ -1238,7 +1280,13 @@ func (tm *ttlManager) run(c *twoPhaseCommitter, lockCtx *kv.LockCtx, isPipelined
 	tm.ch = make(chan struct{})
 	tm.lockCtx = lockCtx
 
-	go keepAlive(c, tm.ch, tm, c.primary(), lockCtx, isPipelinedTxn)
+	// The primary key must be got before the goroutine starts, as the mutations may be changed later.
+	primary := c.primary()
+	c.txn.bgWg.Add(1)
+	go func() {
+		defer c.txn.bgWg.Done()
+		keepAlive(c, tm.ch, tm, primary, lockCtx, isPipelinedTxn)
+	}()
 }
 
 func (tm *ttlManager) close() {
@@ -1483,13 +1531,14 @@ func sendTxnHeartBeat(
 		AdviseLockTtl: ttl,
 		MinCommitTs:   minCommitTS,
 	})
+	req.TrafficClass = tikvrpc.TrafficClassHeartbeat
 	for {
 		loc, err := store.GetRegionCache().LocateKey(bo, primary)
 		if err != nil {
 			return 0, false, err
 		}
 		req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-		resp, err := store.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		resp, err := store.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return 0, false, err
 		}
@@ -1609,60 +1658,80 @@ func (c *twoPhaseCommitter) cleanup(ctx context.Context) {
 		return
 	}
 	c.cleanWg.Add(1)
+	if scheduler := c.store.GetLockCleanupScheduler(); scheduler != nil {
+		c.txn.bgWg.Add(1)
+		task := &LockCleanupTask{
+			StartTS: c.startTS,
+			Run:     func() error { return c.cleanupLocks(ctx) },
+			Done: func() {
+				c.txn.bgWg.Done()
+				c.cleanWg.Done()
+			},
+		}
+		if scheduler.Schedule(task) {
+			return
+		}
+		c.txn.bgWg.Done()
+	}
 	c.txn.spawn(func() {
 		defer c.cleanWg.Done()
+		c.cleanupLocks(ctx)
+	})
+}
 
-		if _, err := util.EvalFailpoint("commitFailedSkipCleanup"); err == nil {
-			logutil.Logger(ctx).Info("[failpoint] injected skip cleanup secondaries on failure",
-				zap.Uint64("txnStartTS", c.startTS))
-			return
-		}
+// cleanupLocks rollbacks the locks written by the failed transaction.
+func (c *twoPhaseCommitter) cleanupLocks(ctx context.Context) error {
+	if _, err := util.EvalFailpoint("commitFailedSkipCleanup"); err == nil {
+		logutil.Logger(ctx).Info("[failpoint] injected skip cleanup secondaries on failure",
+			zap.Uint64("txnStartTS", c.startTS))
+		return nil
+	}
 
-		cleanupKeysCtx := context.WithValue(c.store.Ctx(), retry.TxnStartKey, ctx.Value(retry.TxnStartKey))
-		var err error
-		if c.txn.IsPipelined() {
-			if len(c.pipelinedCommitInfo.pipelinedStart) != 0 && len(c.pipelinedCommitInfo.pipelinedEnd) != 0 {
-				broadcastToAllStores(
-					c.txn,
-					c.store,
-					retry.NewBackofferWithVars(
-						ctx,
-						broadcastMaxBackoff,
-						c.txn.vars,
-					),
-					&kvrpcpb.TxnStatus{
-						StartTs:     c.startTS,
-						MinCommitTs: c.txn.committer.minCommitTSMgr.get(),
-						CommitTs:    0,
-						RolledBack:  true,
-						IsCompleted: false,
-					},
-					c.resourceGroupName,
-					c.resourceGroupTag,
-				)
-				c.resolveFlushedLocks(
-					retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars),
-					c.pipelinedCommitInfo.pipelinedStart,
-					c.pipelinedCommitInfo.pipelinedEnd,
-					false,
-				)
-			}
-		} else if !c.isOnePC() {
-			err = c.cleanupMutations(retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars), c.mutations)
-		} else if c.isPessimistic {
-			err = c.pessimisticRollbackMutations(retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars), c.mutations)
+	cleanupKeysCtx := context.WithValue(c.store.Ctx(), retry.TxnStartKey, ctx.Value(retry.TxnStartKey))
+	var err error
+	if c.txn.IsPipelined() {
+		if len(c.pipelinedCommitInfo.pipelinedStart) != 0 && len(c.pipelinedCommitInfo.pipelinedEnd) != 0 {
+			broadcastToAllStores(
+				c.txn,
+				c.store,
+				retry.NewBackofferWithVars(
+					ctx,
+					broadcastMaxBackoff,
+					c.txn.vars,
+				),
+				&kvrpcpb.TxnStatus{
+					StartTs:     c.startTS,
+					MinCommitTs: c.txn.committer.minCommitTSMgr.get(),
+					CommitTs:    0,
+					RolledBack:  true,
+					IsCompleted: false,
+				},
+				c.resourceGroupName,
+				c.resourceGroupTag,
+			)
+			c.resolveFlushedLocks(
+				retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars),
+				c.pipelinedCommitInfo.pipelinedStart,
+				c.pipelinedCommitInfo.pipelinedEnd,
+				false,
+			)
 		}
+	} else if !c.isOnePC() {
+		err = c.cleanupMutations(retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars), c.mutations)
+	} else if c.isPessimistic {
+		err = c.pessimisticRollbackMutations(retry.NewBackofferWithVars(cleanupKeysCtx, cleanupMaxBackoff, c.txn.vars), c.mutations)
+	}
 
-		if err != nil {
-			metrics.SecondaryLockCleanupFailureCounterRollback.Inc()
-			logutil.Logger(ctx).Info("2PC cleanup failed", zap.Error(err), zap.Uint64("txnStartTS", c.startTS),
-				zap.Bool("isPessimistic", c.isPessimistic), zap.Bool("isOnePC", c.isOnePC()))
-		} else {
-			logutil.Logger(ctx).Debug("2PC clean up done",
-				zap.Uint64("txnStartTS", c.startTS), zap.Bool("isPessimistic", c.isPessimistic),
-				zap.Bool("isOnePC", c.isOnePC()))
-		}
-	})
+	if err != nil {
+		metrics.SecondaryLockCleanupFailureCounterRollback.Inc()
+		logutil.Logger(ctx).Info("2PC cleanup failed", zap.Error(err), zap.Uint64("txnStartTS", c.startTS),
+			zap.Bool("isPessimistic", c.isPessimistic), zap.Bool("isOnePC", c.isOnePC()))
+	} else {
+		logutil.Logger(ctx).Debug("2PC clean up done",
+			zap.Uint64("txnStartTS", c.startTS), zap.Bool("isPessimistic", c.isPessimistic),
+			zap.Bool("isOnePC", c.isOnePC()))
+	}
+	return err
 }
 
 // execute executes the two-phase commit protocol.
@@ -1878,6 +1947,7 @@ func (c *twoPhaseCommitter) execute(ctx context.Context) (err error) {
 		}
 		c.commitTS = c.onePCCommitTS
 		c.txn.commitTS = c.commitTS
+		c.txn.onLogicallyCommitted(c.commitTS)
 		logutil.Logger(ctx).Debug("1PC protocol is used to commit this txn",
 			zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS),
 			zap.Uint64("session", c.sessionID))
@@ -1968,6 +2038,7 @@ func (c *twoPhaseCommitter) execute(ctx context.Context) (err error) {
 	if c.isAsyncCommit() {
 		// For async commit protocol, the commit is considered success here.
 		c.txn.commitTS = c.commitTS
+		c.txn.onLogicallyCommitted(c.commitTS)
 		logutil.Logger(ctx).Debug("2PC will use async commit protocol to commit this txn",
 			zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS),
 			zap.Uint64("sessionID", c.sessionID))
@@ -1977,9 +2048,9 @@ func (c *twoPhaseCommitter) execute(ctx context.Context) (err error) {
 				zap.Uint64("sessionID", c.sessionID))
 			return nil
 		}
-		c.txn.spawn(func() {
+		commitSecondaries := func() error {
 			if _, err := util.EvalFailpoint("asyncCommitDoNothing"); err == nil {
-				return
+				return nil
 			}
 			commitBo := retry.NewBackofferWithVars(c.store.Ctx(), CommitSecondaryMaxBackoff, c.txn.vars)
 			err := c.commitMutations(commitBo, c.mutations)
@@ -1987,6 +2058,15 @@ func (c *twoPhaseCommitter) execute(ctx context.Context) (err error) {
 				logutil.Logger(ctx).Warn("2PC async commit failed", zap.Uint64("sessionID", c.sessionID),
 					zap.Uint64("startTS", c.startTS), zap.Uint64("commitTS", c.commitTS), zap.Error(err))
 			}
+			return err
+		}
+		if c.txn.syncCommitSecondaries {
+			c.txn.secondaryCommit.err = commitSecondaries()
+			return nil
+		}
+		c.txn.secondaryCommit.spawned()
+		c.txn.spawn(func() {
+			c.txn.secondaryCommit.finish(commitSecondaries())
 		})
 		return nil
 	}
@@ -2024,6 +2104,9 @@ func (c *twoPhaseCommitter) commitTxn(ctx context.Context, commitDetail *util.Co
 		logutil.Logger(ctx).Debug("got some exceptions, but 2PC was still successful",
 			zap.Error(err),
 			zap.Uint64("txnStartTS", c.startTS))
+		if c.txn.syncCommitSecondaries {
+			c.txn.secondaryCommit.err = err
+		}
 	}
 	return nil
 }
@@ -78,7 +78,7 @@ func (action actionCleanup) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Ba
 	if c.resourceGroupTag == nil && c.resourceGroupTagger != nil {
 		c.resourceGroupTagger(req)
 	}
-	resp, err := c.store.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
+	resp, err := c.store.SendReq(bo, req, batch.region, client.DefaultTimeout(req.Type))
 	if err != nil {
 		return err
 	}
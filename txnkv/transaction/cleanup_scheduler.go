@@ -0,0 +1,169 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	lockCleanupRetryBaseInterval = time.Second
+	lockCleanupRetryMaxInterval  = 30 * time.Second
+)
+
+// LockCleanupTask cleans up the locks left by a transaction that fails to commit.
+type LockCleanupTask struct {
+	// StartTS is the start ts of the transaction that left the locks.
+	StartTS uint64
+	// Run cleans up the locks. It's retried if it returns an error.
+	Run func() error
+	// Done is called when the task finishes, fails or is dropped. It's optional.
+	Done func()
+}
+
+func (t *LockCleanupTask) done() {
+	if t.Done != nil {
+		t.Done()
+	}
+}
+
+// LockCleanupScheduler runs lock cleanup tasks in background. The tasks are queued in a bounded
+// queue and run by a fixed number of workers, each failed task is retried with exponential
+// backoff until it succeeds or runs out of retries.
+type LockCleanupScheduler struct {
+	tasks    chan *LockCleanupTask
+	maxRetry int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu struct {
+		sync.RWMutex
+		closed bool
+	}
+}
+
+// NewLockCleanupScheduler creates a LockCleanupScheduler that queues at most capacity tasks and
+// runs them with the given number of workers. A task is run at most maxRetry+1 times.
+func NewLockCleanupScheduler(capacity, workers, maxRetry int) *LockCleanupScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &LockCleanupScheduler{
+		tasks:    make(chan *LockCleanupTask, capacity),
+		maxRetry: maxRetry,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.run()
+	}
+	return s
+}
+
+// Schedule puts the task into the queue. It returns false without taking the task if the queue
+// is full or the scheduler is closed, the caller is responsible for the task in that case.
+func (s *LockCleanupScheduler) Schedule(task *LockCleanupTask) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.mu.closed {
+		metrics.LockCleanupTaskCounterRejected.Inc()
+		return false
+	}
+	select {
+	case s.tasks <- task:
+		metrics.TiKVLockCleanupPendingGauge.Inc()
+		return true
+	default:
+		metrics.LockCleanupTaskCounterRejected.Inc()
+		return false
+	}
+}
+
+// Pending returns the number of tasks waiting in the queue.
+func (s *LockCleanupScheduler) Pending() int {
+	return len(s.tasks)
+}
+
+// Close stops the workers. The tasks that haven't finished are dropped.
+func (s *LockCleanupScheduler) Close() {
+	s.mu.Lock()
+	if s.mu.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+	for {
+		select {
+		case task := <-s.tasks:
+			s.finish(task, metrics.LockCleanupTaskCounterDropped)
+		default:
+			return
+		}
+	}
+}
+
+func (s *LockCleanupScheduler) run() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case task := <-s.tasks:
+			s.runTask(task)
+		}
+	}
+}
+
+func (s *LockCleanupScheduler) runTask(task *LockCleanupTask) {
+	interval := lockCleanupRetryBaseInterval
+	for attempt := 0; ; attempt++ {
+		err := task.Run()
+		if err == nil {
+			s.finish(task, metrics.LockCleanupTaskCounterOK)
+			return
+		}
+		if attempt >= s.maxRetry {
+			logutil.BgLogger().Warn("lock cleanup task failed",
+				zap.Uint64("txnStartTS", task.StartTS), zap.Int("attempt", attempt+1), zap.Error(err))
+			s.finish(task, metrics.LockCleanupTaskCounterFailed)
+			return
+		}
+		metrics.LockCleanupTaskCounterRetry.Inc()
+		select {
+		case <-s.ctx.Done():
+			s.finish(task, metrics.LockCleanupTaskCounterDropped)
+			return
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, lockCleanupRetryMaxInterval)
+	}
+}
+
+func (s *LockCleanupScheduler) finish(task *LockCleanupTask, counter prometheus.Counter) {
+	metrics.TiKVLockCleanupPendingGauge.Dec()
+	counter.Inc()
+	task.done()
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockCleanupScheduler(t *testing.T) {
+	s := NewLockCleanupScheduler(1, 1, 1)
+
+	// A failed task is retried.
+	var runs atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(1)
+	require.True(t, s.Schedule(&LockCleanupTask{
+		Run: func() error {
+			if runs.Add(1) == 1 {
+				return errors.New("injected")
+			}
+			return nil
+		},
+		Done: wg.Done,
+	}))
+	wg.Wait()
+	require.Equal(t, int32(2), runs.Load())
+
+	// The queue is bounded.
+	block := make(chan struct{})
+	wg.Add(1)
+	require.True(t, s.Schedule(&LockCleanupTask{
+		Run:  func() error { <-block; return nil },
+		Done: wg.Done,
+	}))
+	require.Eventually(t, func() bool { return s.Pending() == 0 }, time.Second, time.Millisecond)
+	dropped := make(chan struct{})
+	require.True(t, s.Schedule(&LockCleanupTask{
+		Run:  func() error { return nil },
+		Done: func() { close(dropped) },
+	}))
+	require.False(t, s.Schedule(&LockCleanupTask{Run: func() error { return nil }}))
+
+	// The queued tasks are dropped on close.
+	close(block)
+	s.Close()
+	wg.Wait()
+	<-dropped
+	require.False(t, s.Schedule(&LockCleanupTask{Run: func() error { return nil }}))
+}
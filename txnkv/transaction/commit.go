@@ -113,7 +113,7 @@ func (action actionCommit) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Bac
 			tBegin = time.Now()
 		}
 
-		resp, _, err := sender.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
+		resp, _, err := sender.SendReq(bo, req, batch.region, client.DefaultTimeout(req.Type))
 		// If we fail to receive response for the request that commits primary key, it will be undetermined whether this
 		// transaction has been successfully committed.
 		// Under this circumstance, we can not declare the commit is complete (may lead to data lost), nor can we throw
@@ -240,10 +240,11 @@ func (action actionCommit) handleSingleBatch(c *twoPhaseCommitter, bo *retry.Bac
 	}
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	// Group that contains primary key is always the first.
 	// We mark transaction's status committed when we receive the first success response.
 	c.mu.committed = true
+	c.mu.Unlock()
+	c.txn.onLogicallyCommitted(c.commitTS)
 	return nil
 }
 
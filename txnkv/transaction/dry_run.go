@@ -0,0 +1,106 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+// DryRunReport is the estimate of the work of committing a transaction, made by KVTxn.DryRun.
+type DryRunReport struct {
+	// Mutations is the number of the mutations prewritten.
+	Mutations int
+	// Bytes is the total size of the keys and values of the mutations.
+	Bytes int
+	// Regions is the number of the regions the mutations belong to, by the region cache.
+	Regions int
+	// PrewriteRPCs is the number of the prewrite requests.
+	PrewriteRPCs int
+	// CommitRPCs is the number of the commit requests if the transaction is committed by 2PC, which are not sent if
+	// it's committed by async commit or 1PC.
+	CommitRPCs int
+	// ExistingKeys are the keys inserted by the transaction but existing in its snapshot, which fail the commit with
+	// ErrKeyExist.
+	ExistingKeys [][]byte
+}
+
+// DryRun does the client-side work of Commit without sending any prewrite or commit request, and reports the
+// estimated cost of the commit, e.g. to check a big write before committing it. It assembles the mutations as Commit
+// does, maps them to the regions by the region cache, and checks the keys inserted by the transaction against its
+// snapshot. The transaction is left as it was and can still be committed or rolled back.
+func (txn *KVTxn) DryRun(ctx context.Context) (*DryRunReport, error) {
+	if !txn.valid {
+		return nil, tikverr.ErrInvalidTxn
+	}
+	if txn.isPipelined {
+		return nil, errors.New("dry run isn't supported by pipelined transactions")
+	}
+	c, err := newTwoPhaseCommitter(txn, 0)
+	if err != nil {
+		return nil, err
+	}
+	c.dryRun = true
+	if txn.committer != nil {
+		c.primaryKey = txn.committer.primaryKey
+	}
+	if err = c.initKeysAndMutations(ctx); err != nil {
+		return nil, err
+	}
+	if c.stashedAssertionError != nil {
+		return nil, c.stashedAssertionError
+	}
+	report := &DryRunReport{Mutations: c.mutations.Len(), Bytes: c.txnSize}
+	if report.Mutations == 0 {
+		return report, nil
+	}
+
+	bo := retry.NewBackofferWithVars(ctx, int(PrewriteMaxBackoff.Load()), txn.vars)
+	groups, err := groupSortedMutationsByRegion(c.store.GetRegionCache(), bo, c.mutations)
+	if err != nil {
+		return nil, err
+	}
+	report.Regions = len(groups)
+	prewriteBatches, commitBatches := newBatched(c.primary()), newBatched(c.primary())
+	for _, group := range groups {
+		prewriteBatches.appendBatchMutationsBySize(group.region, group.mutations, c.keyValueSize, c.batchSizeLimit(actionPrewrite{}))
+		commitBatches.appendBatchMutationsBySize(group.region, group.mutations, c.keySize, c.batchSizeLimit(actionCommit{}))
+	}
+	report.PrewriteRPCs = len(prewriteBatches.allBatches())
+	report.CommitRPCs = len(commitBatches.allBatches())
+
+	var insertedKeys [][]byte
+	for i := 0; i < c.mutations.Len(); i++ {
+		if op := c.mutations.GetOp(i); op == kvrpcpb.Op_Insert || op == kvrpcpb.Op_CheckNotExists {
+			insertedKeys = append(insertedKeys, c.mutations.GetKey(i))
+		}
+	}
+	if len(insertedKeys) > 0 {
+		existing, err := txn.GetSnapshot().BatchGet(ctx, insertedKeys)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range insertedKeys {
+			if _, ok := existing[string(key)]; ok {
+				report.ExistingKeys = append(report.ExistingKeys, key)
+			}
+		}
+	}
+	return report, nil
+}
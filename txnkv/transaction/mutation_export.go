@@ -0,0 +1,176 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// mutationExportMagic starts the mutations exported by ExportMutations, followed by the version of the format.
+const (
+	mutationExportMagic   = "TXNM"
+	mutationExportVersion = 1
+)
+
+// The ops of the exported mutations.
+const (
+	exportOpPut byte = iota
+	exportOpDelete
+)
+
+// The flags of the exported mutations, only the ones meaningful to another transaction are exported.
+const (
+	exportFlagPresumeKNE byte = 1 << iota
+	exportFlagAssertExist
+	exportFlagAssertNotExist
+)
+
+// ExportMutations writes the puts and deletes buffered in the transaction to w in a portable format, which is
+// imported into another transaction by ImportMutations, e.g. one in another process committing the mutations built
+// by this one. The values are exported as they're stored in the buffer, i.e. after the ValueTransformer and value
+// chunking of the transaction. The keys only locked by the transaction aren't exported as the locks aren't
+// transferred.
+func (txn *KVTxn) ExportMutations(w io.Writer) error {
+	if !txn.valid {
+		return tikverr.ErrInvalidTxn
+	}
+	if txn.isPipelined {
+		return errors.New("exporting mutations isn't supported by pipelined transactions")
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(mutationExportMagic)
+	bw.WriteByte(mutationExportVersion)
+	memBuf := txn.GetMemBuffer().GetMemDB()
+	var err error
+	for it := memBuf.IterWithFlags(nil, nil); it.Valid(); err = it.Next() {
+		if err != nil {
+			return err
+		}
+		if !it.HasValue() {
+			continue
+		}
+		op := exportOpPut
+		if len(it.Value()) == 0 {
+			op = exportOpDelete
+		}
+		var flags byte
+		f := it.Flags()
+		if f.HasPresumeKeyNotExists() {
+			flags |= exportFlagPresumeKNE
+		}
+		if f.HasAssertExist() || f.HasAssertUnknown() {
+			flags |= exportFlagAssertExist
+		}
+		if f.HasAssertNotExist() || f.HasAssertUnknown() {
+			flags |= exportFlagAssertNotExist
+		}
+		bw.WriteByte(op)
+		bw.WriteByte(flags)
+		writeExportBytes(bw, it.Key())
+		if op == exportOpPut {
+			writeExportBytes(bw, it.Value())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(bw.Flush())
+}
+
+func writeExportBytes(w *bufio.Writer, b []byte) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	w.Write(b)
+}
+
+// ImportMutations reads the mutations exported by ExportMutations into the transaction, overwriting the ones of the
+// same keys. The imported values are stored as they are without the ValueTransformer of the transaction. In a
+// pessimistic transaction, the imported keys aren't locked until they're locked by LockKeys.
+func (txn *KVTxn) ImportMutations(r io.Reader) error {
+	if !txn.valid {
+		return tikverr.ErrInvalidTxn
+	}
+	br := bufio.NewReader(r)
+	header := make([]byte, len(mutationExportMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(mutationExportMagic)]) != mutationExportMagic {
+		return errors.New("invalid exported mutations")
+	}
+	if version := header[len(mutationExportMagic)]; version != mutationExportVersion {
+		return errors.Errorf("unsupported version %d of exported mutations", version)
+	}
+	memBuf := txn.GetMemBuffer()
+	for {
+		op, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		flags, err := br.ReadByte()
+		if err != nil {
+			return errors.WithStack(io.ErrUnexpectedEOF)
+		}
+		key, err := readExportBytes(br)
+		if err != nil {
+			return err
+		}
+		var ops []kv.FlagsOp
+		if flags&exportFlagPresumeKNE != 0 {
+			ops = append(ops, kv.SetPresumeKeyNotExists)
+		}
+		switch flags & (exportFlagAssertExist | exportFlagAssertNotExist) {
+		case exportFlagAssertExist:
+			ops = append(ops, kv.SetAssertExist)
+		case exportFlagAssertNotExist:
+			ops = append(ops, kv.SetAssertNotExist)
+		case exportFlagAssertExist | exportFlagAssertNotExist:
+			ops = append(ops, kv.SetAssertUnknown)
+		}
+		switch op {
+		case exportOpPut:
+			var value []byte
+			if value, err = readExportBytes(br); err != nil {
+				return err
+			}
+			err = memBuf.SetWithFlags(key, value, ops...)
+		case exportOpDelete:
+			err = memBuf.DeleteWithFlags(key, ops...)
+		default:
+			return errors.Errorf("invalid op %d of exported mutations", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readExportBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	// The buffer grows with the data read instead of being allocated by n, which may be corrupted.
+	b, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil || uint64(len(b)) != n {
+		return nil, errors.WithStack(io.ErrUnexpectedEOF)
+	}
+	return b, nil
+}
@@ -98,6 +98,7 @@ type diagnosticContext struct {
 	resolvingRecordToken *int
 	sender               *locate.RegionRequestSender
 	reqDuration          time.Duration
+	lockWaitInfo         *LockWaitInfo
 }
 
 func (action actionPessimisticLock) handleSingleBatch(
@@ -155,6 +156,13 @@ func (action actionPessimisticLock) handleSingleBatch(
 		if diagCtx.resolvingRecordToken != nil {
 			c.store.GetLockResolver().ResolveLocksDone(c.startTS, *diagCtx.resolvingRecordToken)
 		}
+		if info := diagCtx.lockWaitInfo; info != nil {
+			metrics.TiKVLockWaitHistogram.WithLabelValues(metrics.KeyRangeBucket(info.Key)).
+				Observe(time.Since(info.WaitStartTime).Seconds())
+			if c.txn.pessimisticRetryStrategy != nil {
+				c.txn.pessimisticRetryStrategy.Done(info)
+			}
+		}
 	}()
 	for {
 		// if lockWaitTime set, refine the request `WaitTimeout` field based on timeout limit
@@ -186,7 +194,7 @@ func (action actionPessimisticLock) handleSingleBatch(
 		}
 		sender := locate.NewRegionRequestSender(c.store.GetRegionCache(), c.store.GetTiKVClient(), c.store.GetOracle())
 		startTime := time.Now()
-		resp, _, err := sender.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
+		resp, _, err := sender.SendReq(bo, req, batch.region, client.DefaultTimeout(req.Type))
 		diagCtx.reqDuration = time.Since(startTime)
 		diagCtx.sender = sender
 		if action.LockCtx.Stats != nil {
@@ -375,6 +383,9 @@ func (action actionPessimisticLock) handlePessimisticLockResponseNormalMode(
 				return true, errors.WithStack(tikverr.ErrLockWaitTimeout)
 			}
 		}
+		if err = action.waitBeforeLockRetry(c, bo, locks, resolveLockRes.TTL, diagCtx); err != nil {
+			return true, err
+		}
 	}
 
 	return false, nil
@@ -501,6 +512,9 @@ func (action actionPessimisticLock) handlePessimisticLockResponseForceLockMode(
 						return true, errors.WithStack(tikverr.ErrLockWaitTimeout)
 					}
 				}
+				if err = action.waitBeforeLockRetry(c, bo, locks, resolveLockRes.TTL, diagCtx); err != nil {
+					return true, err
+				}
 			}
 			return false, nil
 		}
@@ -525,6 +539,50 @@ func (action actionPessimisticLock) handlePessimisticLockResponseForceLockMode(
 	return true, nil
 }
 
+// waitBeforeLockRetry records the contention of a request still blocked by
+// locks, and waits for the delay given by the transaction's
+// PessimisticRetryStrategy before the request is resent.
+func (action actionPessimisticLock) waitBeforeLockRetry(
+	c *twoPhaseCommitter, bo *retry.Backoffer, locks []*txnlock.Lock, msBeforeTxnExpired int64, diagCtx *diagnosticContext,
+) error {
+	info := diagCtx.lockWaitInfo
+	if info == nil {
+		info = &LockWaitInfo{
+			StartTS:       c.startTS,
+			Key:           locks[0].Key,
+			WaitStartTime: action.WaitStartTime,
+		}
+		diagCtx.lockWaitInfo = info
+	}
+	info.Locks = locks
+	info.Attempt++
+	info.MsBeforeTxnExpired = msBeforeTxnExpired
+	bucket := metrics.KeyRangeBucket(info.Key)
+	metrics.TiKVLockContentionCounter.WithLabelValues(bucket).Inc()
+
+	strategy := c.txn.pessimisticRetryStrategy
+	if strategy == nil {
+		return nil
+	}
+	delay := strategy.NextRetryDelay(info)
+	if lockWaitTime := action.LockWaitTime(); lockWaitTime > 0 && lockWaitTime != kv.LockAlwaysWait {
+		timeLeft := time.Duration(lockWaitTime)*time.Millisecond - time.Since(action.WaitStartTime)
+		delay = min(delay, max(timeLeft, 0))
+	}
+	metrics.TiKVPessimisticLockRetryDelayHistogram.WithLabelValues(strategy.Name(), bucket).Observe(delay.Seconds())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-bo.GetCtx().Done():
+		return errors.WithStack(bo.GetCtx().Err())
+	}
+}
+
 func (actionPessimisticLock) isInterruptible() bool {
 	return true
 }
@@ -545,7 +603,7 @@ func (actionPessimisticRollback) handleSingleBatch(
 	)
 	req.RequestSource = util.RequestSourceFromCtx(bo.GetCtx())
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-	resp, err := c.store.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
+	resp, err := c.store.SendReq(bo, req, batch.region, client.DefaultTimeout(req.Type))
 
 	if err != nil {
 		return err
@@ -0,0 +1,152 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+)
+
+// LockWaitInfo describes a pessimistic lock request that is still blocked by
+// other transactions after TiKV's lock waiting timed out.
+type LockWaitInfo struct {
+	// StartTS is the start ts of the waiting transaction.
+	StartTS uint64
+	// Key is the first key blocked in the request.
+	Key []byte
+	// Locks are the locks blocking the request.
+	Locks []*txnlock.Lock
+	// Attempt is the number of times the request has been blocked, starting from 1.
+	Attempt int
+	// WaitStartTime is when the transaction started to wait for the locks.
+	WaitStartTime time.Time
+	// MsBeforeTxnExpired is the min TTL left of the blocking locks.
+	MsBeforeTxnExpired int64
+}
+
+// PessimisticRetryStrategy decides how long a blocked pessimistic lock request
+// waits before it's sent again. The default behavior, used when no strategy is
+// set, is to resend immediately since TiKV already waited for the locks.
+type PessimisticRetryStrategy interface {
+	// Name identifies the strategy in metrics.
+	Name() string
+	// NextRetryDelay returns the delay before resending the blocked request.
+	NextRetryDelay(info *LockWaitInfo) time.Duration
+	// Done is called once the request that has been blocked finishes, whether it
+	// succeeded or not.
+	Done(info *LockWaitInfo)
+}
+
+type exponentialJitterRetryStrategy struct {
+	base time.Duration
+	cap  time.Duration
+}
+
+// NewExponentialJitterRetryStrategy creates a strategy that delays the n-th retry
+// by a random duration in [0, min(cap, base*2^(n-1))).
+func NewExponentialJitterRetryStrategy(base, cap time.Duration) PessimisticRetryStrategy {
+	return &exponentialJitterRetryStrategy{base: base, cap: cap}
+}
+
+func (s *exponentialJitterRetryStrategy) Name() string {
+	return "exponential_jitter"
+}
+
+func (s *exponentialJitterRetryStrategy) NextRetryDelay(info *LockWaitInfo) time.Duration {
+	upper := s.cap
+	if shift := info.Attempt - 1; shift < 32 && s.base<<shift < s.cap {
+		upper = s.base << shift
+	}
+	if upper <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(upper)))
+}
+
+func (s *exponentialJitterRetryStrategy) Done(*LockWaitInfo) {}
+
+type fairQueueRetryStrategy struct {
+	slot time.Duration
+	cap  time.Duration
+
+	mu sync.Mutex
+	// waiters maps the blocking txn to the wait start time of its waiters.
+	waiters map[uint64]map[uint64]time.Time
+	// blockers maps a waiting txn to the txns it's blocked by.
+	blockers map[uint64]map[uint64]struct{}
+}
+
+// NewFairQueueRetryStrategy creates a strategy that approximates a FIFO queue for
+// the waiters of the same transaction in this process: a waiter is delayed by
+// slot for each waiter that started waiting earlier, up to cap. It prevents
+// newly arrived transactions from repeatedly overtaking older ones when the
+// blocking transaction finishes.
+func NewFairQueueRetryStrategy(slot, cap time.Duration) PessimisticRetryStrategy {
+	return &fairQueueRetryStrategy{
+		slot:     slot,
+		cap:      cap,
+		waiters:  make(map[uint64]map[uint64]time.Time),
+		blockers: make(map[uint64]map[uint64]struct{}),
+	}
+}
+
+func (s *fairQueueRetryStrategy) Name() string {
+	return "fair_queue"
+}
+
+func (s *fairQueueRetryStrategy) NextRetryDelay(info *LockWaitInfo) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rank := 0
+	for _, lock := range info.Locks {
+		waiters, ok := s.waiters[lock.TxnID]
+		if !ok {
+			waiters = make(map[uint64]time.Time)
+			s.waiters[lock.TxnID] = waiters
+		}
+		if _, ok := waiters[info.StartTS]; !ok {
+			waiters[info.StartTS] = info.WaitStartTime
+			blockers, ok := s.blockers[info.StartTS]
+			if !ok {
+				blockers = make(map[uint64]struct{})
+				s.blockers[info.StartTS] = blockers
+			}
+			blockers[lock.TxnID] = struct{}{}
+		}
+		ahead := 0
+		for startTS, waitStart := range waiters {
+			if waitStart.Before(info.WaitStartTime) || (waitStart.Equal(info.WaitStartTime) && startTS < info.StartTS) {
+				ahead++
+			}
+		}
+		rank = max(rank, ahead)
+	}
+	return min(time.Duration(rank)*s.slot, s.cap)
+}
+
+func (s *fairQueueRetryStrategy) Done(info *LockWaitInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for blocker := range s.blockers[info.StartTS] {
+		delete(s.waiters[blocker], info.StartTS)
+		if len(s.waiters[blocker]) == 0 {
+			delete(s.waiters, blocker)
+		}
+	}
+	delete(s.blockers, info.StartTS)
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+)
+
+func TestExponentialJitterRetryStrategy(t *testing.T) {
+	s := NewExponentialJitterRetryStrategy(10*time.Millisecond, 50*time.Millisecond)
+	for attempt := 1; attempt <= 100; attempt++ {
+		delay := s.NextRetryDelay(&LockWaitInfo{Attempt: attempt})
+		upper := min(10*time.Millisecond<<min(attempt-1, 10), 50*time.Millisecond)
+		require.GreaterOrEqual(t, delay, time.Duration(0))
+		require.Less(t, delay, upper)
+	}
+}
+
+func TestFairQueueRetryStrategy(t *testing.T) {
+	s := NewFairQueueRetryStrategy(10*time.Millisecond, 25*time.Millisecond)
+	now := time.Now()
+	locks := []*txnlock.Lock{{TxnID: 1}}
+	waiters := make([]*LockWaitInfo, 4)
+	for i := range waiters {
+		waiters[i] = &LockWaitInfo{StartTS: uint64(10 + i), Locks: locks, WaitStartTime: now.Add(time.Duration(i) * time.Second)}
+		// The earlier a txn starts waiting, the shorter it's delayed.
+		require.Equal(t, min(time.Duration(i)*10*time.Millisecond, 25*time.Millisecond), s.NextRetryDelay(waiters[i]))
+	}
+	// A later retry doesn't change the position in the queue.
+	require.Equal(t, 10*time.Millisecond, s.NextRetryDelay(waiters[1]))
+
+	s.Done(waiters[0])
+	require.Equal(t, time.Duration(0), s.NextRetryDelay(waiters[1]))
+	require.Equal(t, 10*time.Millisecond, s.NextRetryDelay(waiters[2]))
+
+	for _, w := range waiters {
+		s.Done(w)
+	}
+	fq := s.(*fairQueueRetryStrategy)
+	require.Empty(t, fq.waiters)
+	require.Empty(t, fq.blockers)
+}
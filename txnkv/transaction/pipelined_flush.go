@@ -145,7 +145,7 @@ func (action actionPipelinedFlush) handleSingleBatch(
 			)
 			tBegin = time.Now()
 		}
-		resp, _, err := sender.SendReq(bo, req, batch.region, client.ReadTimeoutShort)
+		resp, _, err := sender.SendReq(bo, req, batch.region, client.DefaultTimeout(req.Type))
 		// Unexpected error occurs, return it
 		if err != nil {
 			return err
@@ -331,6 +331,7 @@ func (c *twoPhaseCommitter) commitFlushedMutations(bo *retry.Backoffer) error {
 	c.mu.Lock()
 	c.mu.committed = true
 	c.mu.Unlock()
+	c.txn.onLogicallyCommitted(commitTS)
 	logutil.Logger(bo.GetCtx()).Info(
 		"[pipelined dml] transaction is committed",
 		zap.Uint64("startTS", c.startTS),
@@ -82,6 +82,8 @@ func (action actionPrewrite) tiKVTxnRegionsNumHistogram() prometheus.Observer {
 func (c *twoPhaseCommitter) buildPrewriteRequest(batch batchMutations, txnSize uint64) *tikvrpc.Request {
 	m := batch.mutations
 	mutations := make([]*kvrpcpb.Mutation, m.Len())
+	blindWrite := c.insertOnly && c.txn.insertOnlyBlindWrite && !c.isPessimistic &&
+		c.txn.assertionLevel == kvrpcpb.AssertionLevel_Off
 	pessimisticActions := make([]kvrpcpb.PrewriteRequest_PessimisticAction, m.Len())
 	var forUpdateTSConstraints []*kvrpcpb.PrewriteRequest_ForUpdateTSConstraint
 
@@ -99,6 +101,9 @@ func (c *twoPhaseCommitter) buildPrewriteRequest(batch batchMutations, txnSize u
 			Value:     m.GetValue(i),
 			Assertion: assertion,
 		}
+		if blindWrite {
+			mutations[i].Op = kvrpcpb.Op_Put
+		}
 		if m.IsPessimisticLock(i) {
 			pessimisticActions[i] = kvrpcpb.PrewriteRequest_DO_PESSIMISTIC_CHECK
 		} else if m.NeedConstraintCheckInPrewrite(i) {
@@ -376,7 +381,7 @@ func (handler *prewrite1BatchReqHandler) beforeSend(reqBegin time.Time) {
 func (handler *prewrite1BatchReqHandler) sendReqAndCheck() (retryable bool, err error) {
 	reqBegin := time.Now()
 	handler.beforeSend(reqBegin)
-	resp, retryTimes, err := handler.sender.SendReq(handler.bo, handler.req, handler.batch.region, client.ReadTimeoutShort)
+	resp, retryTimes, err := handler.sender.SendReq(handler.bo, handler.req, handler.batch.region, client.DefaultTimeout(handler.req.Type))
 	// Unexpected error occurs, return it directly.
 	if err != nil {
 		return false, err
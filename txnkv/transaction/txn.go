@@ -59,6 +59,7 @@ import (
 	"github.com/tikv/client-go/v2/config/retry"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/internal/unionstore"
 	tikv "github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
@@ -125,6 +126,8 @@ type TxnOptions struct {
 	TxnScope     string
 	StartTS      *uint64
 	PipelinedTxn PipelinedTxnOptions
+	// PessimisticRetryStrategy shapes the retries of blocked pessimistic lock requests.
+	PessimisticRetryStrategy PessimisticRetryStrategy
 }
 
 // PrewriteEncounterLockPolicy specifies the policy when prewrite encounters locks.
@@ -169,6 +172,11 @@ type KVTxn struct {
 	schemaVer SchemaVer
 	// commitCallback is called after current transaction gets committed
 	commitCallback func(info string, err error)
+	// writeObserver is notified once after current transaction is committed successfully.
+	writeObserver WriteObserver
+	// observedMutations is a copy of the mutations for writeObserver, as the values in the MemBuffer are discarded
+	// before committing.
+	observedMutations CommitterMutations
 
 	// backgroundGoroutineLifecycleHooks tracks the lifecycle of background goroutines of a
 	// transaction. The `.Pre` will be executed before the start of each background goroutine,
@@ -211,27 +219,70 @@ type KVTxn struct {
 	flushBatchDurationEWMA ewma.MovingAverage
 
 	prewriteEncounterLockPolicy PrewriteEncounterLockPolicy
+
+	pessimisticRetryStrategy PessimisticRetryStrategy
+
+	// commitErrorDetails is the commit details collected before the commit fails.
+	commitErrorDetails *util.CommitDetails
+
+	// writeSizeLimits limits the sizes of the keys and values set in the transaction.
+	writeSizeLimits WriteSizeLimits
+	// chunkedKeys records the max number of the chunks of the values chunked by Set of the keys.
+	chunkedKeys map[string]int
+	// valueTransformers transforms the values set in the transaction and restores the values read.
+	valueTransformers *tikv.ValueTransformers
+
+	// strictPrimaryFirst makes prewrite finish the primary batch before the secondary ones.
+	strictPrimaryFirst bool
+	// insertOnlyBlindWrite makes the inserts of an insert-only transaction be prewritten as puts.
+	insertOnlyBlindWrite bool
+	// secondaryConcurrency limits the concurrency of prewriting and committing secondary batches.
+	// Zero means using CommitterConcurrency of the global config.
+	secondaryConcurrency int
+	// syncCommitSecondaries makes Commit wait for the secondary keys to be committed.
+	syncCommitSecondaries bool
+	// secondaryCommit tracks the secondary keys that are committed in background.
+	secondaryCommit secondaryCommit
+	// logicallyCommitted is notified once the commit ts of the transaction is decided and the transaction is
+	// committed, which may be earlier than the secondary keys are committed.
+	logicallyCommitted struct {
+		once     sync.Once
+		callback func(commitTS uint64)
+	}
+	// memTracker accounts the MemBuffer against the memory budget of the client, and memHook is the memory footprint
+	// change hook set by SetMemoryFootprintChangeHook.
+	memTracker *memctl.Tracker
+	memHook    atomic.Pointer[func(uint64)]
+
+	// offHeapMemBuffer indicates the MemBuffer is allocated off the Go heap and must be
+	// released by ReleaseMemBuffer.
+	offHeapMemBuffer bool
+	// bgWg tracks the background goroutines of the transaction that may access the MemBuffer.
+	bgWg sync.WaitGroup
 }
 
 // NewTiKVTxn creates a new KVTxn.
 func NewTiKVTxn(store kvstore, snapshot *txnsnapshot.KVSnapshot, startTS uint64, options *TxnOptions) (*KVTxn, error) {
 	cfg := config.GetGlobalConfig()
 	newTiKVTxn := &KVTxn{
-		snapshot:               snapshot,
-		store:                  store,
-		startTS:                startTS,
-		startTime:              time.Now(),
-		valid:                  true,
-		vars:                   tikv.DefaultVars,
-		scope:                  options.TxnScope,
-		enableAsyncCommit:      cfg.EnableAsyncCommit,
-		enable1PC:              cfg.Enable1PC,
-		diskFullOpt:            kvrpcpb.DiskFullOpt_NotAllowedOnFull,
-		RequestSource:          snapshot.RequestSource,
-		flushBatchDurationEWMA: ewma.NewMovingAverage(defaultEWMAAge),
+		snapshot:                 snapshot,
+		store:                    store,
+		startTS:                  startTS,
+		startTime:                time.Now(),
+		valid:                    true,
+		vars:                     tikv.DefaultVars,
+		scope:                    options.TxnScope,
+		enableAsyncCommit:        cfg.EnableAsyncCommit,
+		enable1PC:                cfg.Enable1PC,
+		diskFullOpt:              kvrpcpb.DiskFullOpt_NotAllowedOnFull,
+		RequestSource:            snapshot.RequestSource,
+		flushBatchDurationEWMA:   ewma.NewMovingAverage(defaultEWMAAge),
+		pessimisticRetryStrategy: options.PessimisticRetryStrategy,
+		secondaryCommit:          secondaryCommit{done: make(chan struct{})},
 	}
 	if !options.PipelinedTxn.Enable {
 		newTiKVTxn.us = unionstore.NewUnionStore(unionstore.NewMemDB(), snapshot)
+		newTiKVTxn.trackMemBuffer()
 		return newTiKVTxn, nil
 	}
 	if options.PipelinedTxn.FlushConcurrency == 0 {
@@ -280,22 +331,46 @@ func (txn *KVTxn) Get(ctx context.Context, k []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if txn.writeSizeLimits.chunkingEnabled() {
+		values := map[string][]byte{string(k): ret}
+		if err = txn.reassembleChunkedValues(ctx, values); err != nil {
+			return nil, err
+		}
+		ret = values[string(k)]
+	}
 
-	return ret, nil
+	return txn.valueTransformers.Restore(k, ret)
 }
 
 // BatchGet gets kv from the memory buffer of statement and transaction, and the kv storage.
 // Do not use len(value) == 0 or value == nil to represent non-exist.
 // If a key doesn't exist, there shouldn't be any corresponding entry in the result map.
 func (txn *KVTxn) BatchGet(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
-	return NewBufferBatchGetter(txn.GetMemBuffer(), txn.GetSnapshot()).BatchGet(ctx, keys)
+	values, err := NewBufferBatchGetter(txn.GetMemBuffer(), txn.GetSnapshot()).BatchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if txn.writeSizeLimits.chunkingEnabled() {
+		if err = txn.reassembleChunkedValues(ctx, values); err != nil {
+			return nil, err
+		}
+	}
+	if err = txn.valueTransformers.RestoreMap(values); err != nil {
+		return nil, err
+	}
+	return values, nil
 }
 
 // Set sets the value for key k as v into kv store.
 // v must NOT be nil or empty, otherwise it returns ErrCannotSetNilValue.
+// It returns ErrKeyTooLarge or ErrValueTooLarge if k or v exceeds the limits set by SetWriteSizeLimits.
 func (txn *KVTxn) Set(k []byte, v []byte) error {
 	txn.setCnt++
-	return txn.GetMemBuffer().Set(k, v)
+	v, err := txn.valueTransformers.Transform(k, v)
+	if err != nil {
+		return err
+	}
+	return txn.checkedSet(k, v)
 }
 
 // String implements fmt.Stringer interface.
@@ -312,17 +387,32 @@ func (txn *KVTxn) String() string {
 // It yields only keys that < upperBound. If upperBound is nil, it means the upperBound is unbounded.
 // The Iterator must be Closed after use.
 func (txn *KVTxn) Iter(k []byte, upperBound []byte) (unionstore.Iterator, error) {
-	return txn.us.Iter(k, upperBound)
+	it, err := txn.us.Iter(k, upperBound)
+	if err != nil {
+		return nil, err
+	}
+	if it, err = txn.chunkedIter(it); err != nil || txn.valueTransformers == nil {
+		return it, err
+	}
+	return newRestoringIterator(it, txn.valueTransformers)
 }
 
 // IterReverse creates a reversed Iterator positioned on the first entry which key is less than k.
 func (txn *KVTxn) IterReverse(k, lowerBound []byte) (unionstore.Iterator, error) {
-	return txn.us.IterReverse(k, lowerBound)
+	it, err := txn.us.IterReverse(k, lowerBound)
+	if err != nil {
+		return nil, err
+	}
+	if it, err = txn.chunkedIter(it); err != nil || txn.valueTransformers == nil {
+		return it, err
+	}
+	return newRestoringIterator(it, txn.valueTransformers)
 }
 
 // Delete removes the entry for key k from kv store.
+// If chunking is enabled by SetWriteSizeLimits, the chunks of its value are removed too.
 func (txn *KVTxn) Delete(k []byte) error {
-	return txn.GetMemBuffer().Delete(k)
+	return txn.checkedDelete(k)
 }
 
 // SetSchemaLeaseChecker sets a hook to check schema version.
@@ -407,6 +497,21 @@ func (txn *KVTxn) SetCommitCallback(f func(string, error)) {
 	txn.commitCallback = f
 }
 
+// WriteObserver observes the writes of committed transactions, e.g. to invalidate the caches of the written keys.
+type WriteObserver interface {
+	// OnCommitted is called once after the transaction is committed successfully, before Commit returns, with the
+	// final mutations of the transaction, including the lock and check-not-exists ones which don't write any data.
+	// Setting an observer makes the transaction keep a copy of the mutations until it's committed. The mutations are
+	// nil for pipelined transactions, which don't keep them after flushing. It's not called for
+	// failed or read-only transactions, including those whose commit result is undetermined.
+	OnCommitted(startTS, commitTS uint64, mutations CommitterMutations)
+}
+
+// SetWriteObserver sets up an observer that will be notified when the transaction is committed successfully.
+func (txn *KVTxn) SetWriteObserver(o WriteObserver) {
+	txn.writeObserver = o
+}
+
 // SetBackgroundGoroutineLifecycleHooks sets up the hooks to track the lifecycle of the background goroutines of a transaction.
 func (txn *KVTxn) SetBackgroundGoroutineLifecycleHooks(hooks LifecycleHooks) {
 	txn.backgroundGoroutineLifecycleHooks = hooks
@@ -418,11 +523,13 @@ func (txn *KVTxn) spawn(f func()) {
 		txn.backgroundGoroutineLifecycleHooks.Pre()
 	}
 	txn.store.WaitGroup().Add(1)
+	txn.bgWg.Add(1)
 	go func() {
 		if txn.backgroundGoroutineLifecycleHooks.Post != nil {
 			defer txn.backgroundGoroutineLifecycleHooks.Post()
 		}
 		defer txn.store.WaitGroup().Done()
+		defer txn.bgWg.Done()
 
 		f()
 	}()
@@ -434,20 +541,77 @@ func (txn *KVTxn) spawnWithStorePool(f func()) error {
 		txn.backgroundGoroutineLifecycleHooks.Pre()
 	}
 	txn.store.WaitGroup().Add(1)
+	txn.bgWg.Add(1)
 	err := txn.store.Go(func() {
 		if txn.backgroundGoroutineLifecycleHooks.Post != nil {
 			defer txn.backgroundGoroutineLifecycleHooks.Post()
 		}
 		defer txn.store.WaitGroup().Done()
+		defer txn.bgWg.Done()
 
 		f()
 	})
 	if err != nil {
 		txn.store.WaitGroup().Done()
+		txn.bgWg.Done()
 	}
 	return err
 }
 
+// secondaryCommit is a future of the commit of secondary keys.
+type secondaryCommit struct {
+	done chan struct{}
+	once sync.Once
+	// err is set by the committer before Commit returns if the secondary keys are committed synchronously.
+	err error
+	// callback is called with err right before done is closed.
+	callback func(err error)
+	// inBackground is set by the committer before it spawns the goroutine committing secondary keys.
+	inBackground bool
+	start        time.Time
+}
+
+// inflightCommits counts the commits in progress in the process.
+var inflightCommits struct {
+	// committing is the number of transactions in KVTxn.Commit.
+	committing atomic.Int64
+	// background is the number of transactions committing secondary keys in background.
+	background atomic.Int64
+}
+
+// InflightCommits returns the number of transactions being committed and the number of transactions committing
+// their secondary keys in background in the process.
+func InflightCommits() (committing, background int64) {
+	return inflightCommits.committing.Load(), inflightCommits.background.Load()
+}
+
+// spawned marks the secondary keys are going to be committed in background.
+func (s *secondaryCommit) spawned() {
+	s.inBackground = true
+	s.start = time.Now()
+	metrics.TiKVPendingSecondaryCommitGauge.Inc()
+	inflightCommits.background.Add(1)
+}
+
+func (s *secondaryCommit) finish(err error) {
+	s.once.Do(func() {
+		if s.inBackground {
+			metrics.TiKVPendingSecondaryCommitGauge.Dec()
+			inflightCommits.background.Add(-1)
+			if err != nil {
+				metrics.SecondaryCommitLagError.Observe(time.Since(s.start).Seconds())
+			} else {
+				metrics.SecondaryCommitLagOK.Observe(time.Since(s.start).Seconds())
+			}
+		}
+		s.err = err
+		if s.callback != nil {
+			s.callback(err)
+		}
+		close(s.done)
+	})
+}
+
 // SetEnableAsyncCommit indicates if the transaction will try to use async commit.
 func (txn *KVTxn) SetEnableAsyncCommit(b bool) {
 	txn.enableAsyncCommit = b
@@ -529,6 +693,125 @@ func (txn *KVTxn) SetPrewriteEncounterLockPolicy(policy PrewriteEncounterLockPol
 	txn.prewriteEncounterLockPolicy = policy
 }
 
+// SetPessimisticRetryStrategy sets the strategy that shapes the retries of pessimistic
+// lock requests still blocked by other transactions after lock waiting.
+func (txn *KVTxn) SetPessimisticRetryStrategy(strategy PessimisticRetryStrategy) {
+	txn.pessimisticRetryStrategy = strategy
+}
+
+// SetStrictPrimaryFirst sets whether prewrite should also finish the batch of the primary key before
+// sending the batches of secondary keys, so that no secondary lock is left behind if the primary key
+// can't be prewritten. It trades prewrite latency for fewer orphaned locks. Commit, cleanup and
+// pessimistic lock always handle the primary key first regardless of this option.
+func (txn *KVTxn) SetStrictPrimaryFirst(b bool) {
+	txn.strictPrimaryFirst = b
+}
+
+// SetInsertOnlyBlindWrite sets whether the inserts of the transaction are prewritten as puts if all its mutations
+// are inserts, which saves TiKV checking the existence of the keys. It's only safe if the keys are known not to
+// exist, e.g. when loading data into an empty range, since an existing key is overwritten instead of failing the
+// commit with ErrKeyExist. The write conflicts are still detected. It's ignored by pessimistic transactions and the
+// transactions with assertions, which check the existence anyway.
+func (txn *KVTxn) SetInsertOnlyBlindWrite(b bool) {
+	txn.insertOnlyBlindWrite = b
+}
+
+// SetSecondaryConcurrency sets the max number of batches of secondary keys that are prewritten or
+// committed in parallel. Zero means following CommitterConcurrency of the global config.
+func (txn *KVTxn) SetSecondaryConcurrency(concurrency int) {
+	txn.secondaryConcurrency = concurrency
+}
+
+// SetCommitSecondariesInBackground sets whether the secondary keys are committed in background once
+// the transaction is committed, which is the default. If it's disabled, Commit doesn't return until
+// all keys are committed. Use SecondariesCommitted to wait for the background commit.
+func (txn *KVTxn) SetCommitSecondariesInBackground(b bool) {
+	txn.syncCommitSecondaries = !b
+}
+
+// SecondariesCommitted returns a channel that is closed when Commit returns and, if the secondary keys
+// are committed in background, the background commit finishes. The channel is also closed when the
+// transaction is rolled back.
+func (txn *KVTxn) SecondariesCommitted() <-chan struct{} {
+	return txn.secondaryCommit.done
+}
+
+// SecondariesCommitError returns the error of committing the secondary keys after the transaction is
+// committed, either in background or in Commit if SetCommitSecondariesInBackground(false) is set. It
+// must be called after the channel returned by SecondariesCommitted is closed. The transaction is
+// committed even if it's not nil, the remaining locks will be resolved by the readers.
+func (txn *KVTxn) SecondariesCommitError() error {
+	return txn.secondaryCommit.err
+}
+
+// SetSecondariesCommittedCallback sets a callback which is called when the channel returned by
+// SecondariesCommitted is about to be closed, with the error of the background commit if any. It may
+// be called in a background goroutine and must be set before Commit.
+func (txn *KVTxn) SetSecondariesCommittedCallback(f func(err error)) {
+	txn.secondaryCommit.callback = f
+}
+
+// SetLogicallyCommittedCallback sets a callback which is called with the commit ts once the transaction is
+// logically committed, i.e. it's the point after which the transaction is guaranteed to be committed at that ts.
+// It's when all keys are prewritten for async commit, when the prewrite succeeds for 1PC, and when the primary key
+// is committed otherwise. It's called before the callback set by SetSecondariesCommittedCallback and before Commit
+// returns, and isn't called if the transaction fails or its commit result is undetermined. It must be set before
+// Commit.
+func (txn *KVTxn) SetLogicallyCommittedCallback(f func(commitTS uint64)) {
+	txn.logicallyCommitted.callback = f
+}
+
+func (txn *KVTxn) onLogicallyCommitted(commitTS uint64) {
+	if txn.logicallyCommitted.callback == nil {
+		return
+	}
+	txn.logicallyCommitted.once.Do(func() {
+		txn.logicallyCommitted.callback(commitTS)
+	})
+}
+
+// SetOffHeapMemBuffer makes the MemBuffer of the transaction allocate its index
+// off the Go heap, which reduces the GC pressure of large transactions. The keys and
+// values are still kept on the Go heap. It must be called before anything is written
+// to the transaction and isn't supported by pipelined transactions.
+//
+// The off-heap memory should be freed by ReleaseMemBuffer after the transaction is
+// committed or rolled back, otherwise it's only freed when the transaction is
+// garbage collected.
+func (txn *KVTxn) SetOffHeapMemBuffer() error {
+	if txn.isPipelined {
+		return errors.New("off-heap memory buffer is not supported by pipelined txn")
+	}
+	memBuffer, ok := txn.us.GetMemBuffer().(unionstore.OffHeapMemBuffer)
+	if !ok {
+		return errors.New("the memory buffer doesn't support off-heap allocation")
+	}
+	if memBuffer.Mem() > 0 {
+		return errors.New("off-heap memory buffer should be set before the transaction is written")
+	}
+	memBuffer.SetOffHeap()
+	txn.offHeapMemBuffer = true
+	return nil
+}
+
+// ReleaseMemBuffer waits for the background goroutines of the transaction and frees
+// the off-heap memory set by SetOffHeapMemBuffer. It must be called after the
+// transaction is committed or rolled back, the keys and values read from the
+// transaction remain valid after it. It's a no-op if the memory buffer is allocated
+// on the Go heap.
+func (txn *KVTxn) ReleaseMemBuffer() error {
+	if txn.valid {
+		return errors.New("the memory buffer can only be released after the transaction is committed or rolled back")
+	}
+	if !txn.offHeapMemBuffer {
+		return nil
+	}
+	txn.bgWg.Wait()
+	txn.us.GetMemBuffer().(unionstore.OffHeapMemBuffer).FreeOffHeap()
+	txn.offHeapMemBuffer = false
+	return nil
+}
+
 // IsPessimistic returns true if it is pessimistic.
 func (txn *KVTxn) IsPessimistic() bool {
 	return txn.isPessimistic
@@ -700,9 +983,23 @@ func (txn *KVTxn) InitPipelinedMemDB() error {
 	txn.committer.resourceGroupTagger = txn.resourceGroupTagger
 	txn.committer.resourceGroupName = txn.resourceGroupName
 	txn.us = unionstore.NewUnionStore(pipelinedMemDB, txn.snapshot)
+	txn.trackMemBuffer()
 	return nil
 }
 
+// trackMemBuffer accounts the MemBuffer against the memory budget of the client until the transaction is closed.
+func (txn *KVTxn) trackMemBuffer() {
+	if txn.memTracker == nil {
+		txn.memTracker = memctl.NewTracker(memctl.KindTxnBuffer)
+	}
+	txn.us.GetMemBuffer().SetMemoryFootprintChangeHook(func(mem uint64) {
+		txn.memTracker.Set(int64(mem))
+		if hook := txn.memHook.Load(); hook != nil {
+			(*hook)(mem)
+		}
+	})
+}
+
 func (txn *KVTxn) throttlePipelinedTxn() {
 	if txn.writeThrottleRatio >= 1 || txn.writeThrottleRatio < 0 {
 		logutil.BgLogger().Error(
@@ -742,8 +1039,17 @@ func (txn *KVTxn) GetScope() string {
 	return txn.scope
 }
 
+// CommitErrorDetails returns the commit details, e.g. the prewrite and commit time, the backoff types and the resolved
+// locks, collected before the commit of the transaction fails, or nil if it hasn't failed after the two-phase commit
+// starts. The error returned by Commit is left as is.
+func (txn *KVTxn) CommitErrorDetails() *util.CommitDetails {
+	return txn.commitErrorDetails
+}
+
 // Commit commits the transaction operations to KV store.
-func (txn *KVTxn) Commit(ctx context.Context) error {
+// If the commit fails after the two-phase commit starts, the commit details collected before the failure can be got
+// by CommitErrorDetails.
+func (txn *KVTxn) Commit(ctx context.Context) (err error) {
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("tikvTxn.Commit", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
@@ -754,7 +1060,14 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 	if !txn.valid {
 		return tikverr.ErrInvalidTxn
 	}
+	inflightCommits.committing.Add(1)
+	defer inflightCommits.committing.Add(-1)
 	defer txn.close()
+	defer func() {
+		if !txn.secondaryCommit.inBackground {
+			txn.secondaryCommit.finish(txn.secondaryCommit.err)
+		}
+	}()
 
 	ctx = context.WithValue(ctx, util.RequestSourceKey, *txn.RequestSource)
 
@@ -795,7 +1108,6 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 		ctx = interceptor.WithRPCInterceptor(ctx, txn.interceptor)
 	}
 
-	var err error
 	// If the txn use pessimistic lock, committer is initialized.
 	committer := txn.committer
 	if committer == nil {
@@ -828,12 +1140,18 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 	if !txn.isPipelined && committer.mutations.Len() == 0 {
 		return nil
 	}
+	if txn.writeObserver != nil && !txn.isPipelined {
+		txn.observedMutations = clonePlainMutations(committer.mutations)
+	}
 
 	defer func() {
 		detail := committer.getDetail()
 		detail.Mu.Lock()
 		metrics.TiKVTxnCommitBackoffSeconds.Observe(float64(detail.Mu.CommitBackoffTime) / float64(time.Second))
 		metrics.TiKVTxnCommitBackoffCount.Observe(float64(len(detail.Mu.PrewriteBackoffTypes) + len(detail.Mu.CommitBackoffTypes)))
+		if err != nil {
+			txn.commitErrorDetails = detail.Clone()
+		}
 		detail.Mu.Unlock()
 
 		ctxValue := ctx.Value(util.CommitDetailCtxKey)
@@ -854,6 +1172,7 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 		if val == nil || sessionID > 0 {
 			txn.onCommitted(err)
 		}
+		txn.notifyWriteObserver(err)
 		logutil.Logger(ctx).Debug("[kv] txnLatches disabled, 2pc directly", zap.Error(err))
 		return err
 	}
@@ -878,12 +1197,16 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 	if err == nil {
 		lock.SetCommitTS(committer.commitTS)
 	}
+	txn.notifyWriteObserver(err)
 	logutil.Logger(ctx).Debug("[kv] txnLatches enabled while txn retryable", zap.Error(err))
 	return err
 }
 
 func (txn *KVTxn) close() {
 	txn.valid = false
+	if txn.memTracker != nil {
+		txn.memTracker.Release()
+	}
 	txn.ClearDiskFullOpt()
 }
 
@@ -892,6 +1215,7 @@ func (txn *KVTxn) Rollback() error {
 	if !txn.valid {
 		return tikverr.ErrInvalidTxn
 	}
+	defer txn.secondaryCommit.finish(nil)
 
 	if txn.IsInAggressiveLockingMode() {
 		if len(txn.aggressiveLockingContext.currentLockedKeys) != 0 {
@@ -1049,6 +1373,22 @@ func (txn *KVTxn) onCommitted(err error) {
 	}
 }
 
+func (txn *KVTxn) notifyWriteObserver(err error) {
+	if txn.writeObserver == nil || err != nil {
+		return
+	}
+	txn.writeObserver.OnCommitted(txn.startTS, txn.commitTS, txn.observedMutations)
+}
+
+func clonePlainMutations(mutations CommitterMutations) *PlainMutations {
+	res := NewPlainMutations(mutations.Len())
+	for i := 0; i < mutations.Len(); i++ {
+		res.Push(mutations.GetOp(i), mutations.GetKey(i), slices.Clone(mutations.GetValue(i)), mutations.IsPessimisticLock(i),
+			mutations.IsAssertExists(i), mutations.IsAssertNotExist(i), mutations.NeedConstraintCheckInPrewrite(i))
+	}
+	return &res
+}
+
 // LockKeysWithWaitTime tries to lock the entries with the keys in KV store.
 // lockWaitTime in ms, 0 means nowait lock.
 func (txn *KVTxn) LockKeysWithWaitTime(ctx context.Context, lockWaitTime int64, keysInput ...[]byte) (err error) {
@@ -1780,8 +2120,10 @@ func (txn *KVTxn) asyncPessimisticRollback(ctx context.Context, keys [][]byte, s
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
 	txn.store.WaitGroup().Add(1)
+	txn.bgWg.Add(1)
 	go func() {
 		defer txn.store.WaitGroup().Done()
+		defer txn.bgWg.Done()
 		if val, err := util.EvalFailpoint("beforeAsyncPessimisticRollback"); err == nil {
 			if s, ok := val.(string); ok {
 				if s == "skip" {
@@ -1877,7 +2219,7 @@ func (txn *KVTxn) GetClusterID() uint64 {
 
 // SetMemoryFootprintChangeHook sets the hook function that is triggered when memdb grows
 func (txn *KVTxn) SetMemoryFootprintChangeHook(hook func(uint64)) {
-	txn.us.GetMemBuffer().SetMemoryFootprintChangeHook(hook)
+	txn.memHook.Store(&hook)
 }
 
 // Mem returns the current memory footprint
@@ -1900,9 +2242,9 @@ func (txn *KVTxn) SetExplicitRequestSourceType(tp string) {
 	txn.RequestSource.SetExplicitRequestSourceType(tp)
 }
 
-// MemHookSet returns whether the mem buffer has a memory footprint change hook set.
+// MemHookSet returns whether the mem buffer has a memory footprint change hook set by SetMemoryFootprintChangeHook.
 func (txn *KVTxn) MemHookSet() bool {
-	return txn.us.GetMemBuffer().MemHookSet()
+	return txn.memHook.Load() != nil
 }
 
 // LifecycleHooks is a struct that contains hooks for a background goroutine.
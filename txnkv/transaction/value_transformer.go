@@ -0,0 +1,70 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"github.com/tikv/client-go/v2/internal/unionstore"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// SetValueTransformers sets the transformers of the values of the transaction, see kv.ValueTransformers. The values
+// are transformed by Set and restored by Get, BatchGet, Iter and IterReverse, while the MemBuffer and the snapshot of
+// the transaction hold the transformed values. It must be set before the transaction is used.
+func (txn *KVTxn) SetValueTransformers(t *kv.ValueTransformers) {
+	txn.valueTransformers = t
+}
+
+// restoringIterator restores the values of an iterator of the transaction.
+type restoringIterator struct {
+	unionstore.Iterator
+	transformers *kv.ValueTransformers
+	value        []byte
+}
+
+func newRestoringIterator(it unionstore.Iterator, t *kv.ValueTransformers) (unionstore.Iterator, error) {
+	r := &restoringIterator{Iterator: it, transformers: t}
+	if err := r.restore(); err != nil {
+		it.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (it *restoringIterator) restore() error {
+	if !it.Iterator.Valid() {
+		it.value = nil
+		return nil
+	}
+	var err error
+	it.value, err = it.transformers.Restore(it.Iterator.Key(), it.Iterator.Value())
+	return err
+}
+
+// Value returns the restored value.
+func (it *restoringIterator) Value() []byte {
+	return it.value
+}
+
+// Next moves to the next entry and restores its value.
+func (it *restoringIterator) Next() error {
+	if err := it.Iterator.Next(); err != nil {
+		return err
+	}
+	if err := it.restore(); err != nil {
+		it.Close()
+		return err
+	}
+	return nil
+}
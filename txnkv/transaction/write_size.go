@@ -0,0 +1,156 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/unionstore"
+	"github.com/tikv/client-go/v2/txnkv/chunked"
+)
+
+// WriteSizeLimits limits the sizes of the keys and values set in a transaction. They're checked by Set, so an
+// oversized write fails early with ErrKeyTooLarge or ErrValueTooLarge instead of failing at prewrite. The writes to
+// the MemBuffer by the other paths are checked by Commit before prewrite. A non-positive limit means no limit.
+type WriteSizeLimits struct {
+	// MaxKeySize is the max size of a key.
+	MaxKeySize int
+	// MaxValueSize is the max size of a value.
+	MaxValueSize int
+	// ChunkSize enables chunking the values larger than MaxValueSize instead of rejecting them. Such a value is stored
+	// in the chunks of at most ChunkSize bytes in the format of package chunked, and Get and BatchGet of the
+	// transaction reassemble it. Iter and IterReverse of the transaction skip the chunk keys and reassemble the values
+	// too. Set and Delete delete the chunks of the previous value of the key only if it's chunked by the transaction
+	// itself, overwrite or delete the values chunked by the other transactions with chunked.Store instead. The
+	// snapshots see the chunk keys and manifests as is, read them with chunked.Store and chunked.NewIterator instead.
+	// ChunkSize must be positive and no more than MaxValueSize to enable chunking.
+	ChunkSize int
+}
+
+// SetWriteSizeLimits sets the limits of the sizes of the keys and values set in the transaction.
+func (txn *KVTxn) SetWriteSizeLimits(limits WriteSizeLimits) error {
+	if limits.ChunkSize > 0 && (limits.MaxValueSize <= 0 || limits.ChunkSize > limits.MaxValueSize) {
+		return errors.Errorf("chunk size %d must be no more than the max value size %d", limits.ChunkSize, limits.MaxValueSize)
+	}
+	txn.writeSizeLimits = limits
+	return nil
+}
+
+func (l *WriteSizeLimits) chunkingEnabled() bool {
+	return l.ChunkSize > 0
+}
+
+// checkKeySize checks the size of the key, including the suffix of the chunk keys if the value is chunked.
+func (l *WriteSizeLimits) checkKeySize(k []byte, chunkValue bool) error {
+	size := len(k)
+	if chunkValue {
+		size += chunked.ChunkKeyOverhead
+	}
+	if l.MaxKeySize > 0 && size > l.MaxKeySize {
+		return &tikverr.ErrKeyTooLarge{KeySize: size, Limit: l.MaxKeySize}
+	}
+	return nil
+}
+
+// check checks the sizes of an entry written to the MemBuffer, where the chunked values are already split.
+func (l *WriteSizeLimits) check(k []byte, v []byte) error {
+	if err := l.checkKeySize(k, false); err != nil {
+		return err
+	}
+	if l.MaxValueSize > 0 && len(v) > l.MaxValueSize {
+		return &tikverr.ErrValueTooLarge{Key: k, Size: len(v), Limit: l.MaxValueSize}
+	}
+	return nil
+}
+
+func (txn *KVTxn) checkedSet(k []byte, v []byte) error {
+	limits := &txn.writeSizeLimits
+	chunkValue := limits.MaxValueSize > 0 && len(v) > limits.MaxValueSize
+	if chunkValue && !limits.chunkingEnabled() {
+		return &tikverr.ErrValueTooLarge{Key: k, Size: len(v), Limit: limits.MaxValueSize}
+	}
+	if err := limits.checkKeySize(k, chunkValue); err != nil {
+		return err
+	}
+	memBuffer := txn.GetMemBuffer()
+	if !limits.chunkingEnabled() {
+		return memBuffer.Set(k, v)
+	}
+	prevChunks := txn.chunks(k)
+	chunks := 0
+	if chunkValue {
+		values, manifest := chunked.Split(v, limits.ChunkSize)
+		for i, chunk := range values {
+			if err := memBuffer.Set(chunked.ChunkKey(k, i), chunk); err != nil {
+				return err
+			}
+		}
+		chunks = manifest.Chunks
+		v = manifest.Encode()
+		if chunks > prevChunks {
+			if txn.chunkedKeys == nil {
+				txn.chunkedKeys = make(map[string]int)
+			}
+			txn.chunkedKeys[string(k)] = chunks
+		}
+	}
+	if err := txn.deleteChunks(k, chunks, prevChunks); err != nil {
+		return err
+	}
+	return memBuffer.Set(k, v)
+}
+
+// chunks returns the max number of the chunks of the values of k chunked by the transaction, or 0 if it never chunks
+// the values of k. The values in the MemBuffer aren't trusted as the manifests, and the count never decreases, since
+// the chunks may be restored by discarding a staging.
+func (txn *KVTxn) chunks(k []byte) int {
+	return txn.chunkedKeys[string(k)]
+}
+
+// deleteChunks deletes the chunks of k in [from, to).
+func (txn *KVTxn) deleteChunks(k []byte, from, to int) error {
+	for i := from; i < to; i++ {
+		if err := txn.GetMemBuffer().Delete(chunked.ChunkKey(k, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkedDelete deletes k and the chunks of its value if chunking is enabled.
+func (txn *KVTxn) checkedDelete(k []byte) error {
+	if txn.writeSizeLimits.chunkingEnabled() {
+		if err := txn.deleteChunks(k, 0, txn.chunks(k)); err != nil {
+			return err
+		}
+	}
+	return txn.GetMemBuffer().Delete(k)
+}
+
+// chunkedIter wraps the iterator of the transaction to skip the chunk keys and reassemble the chunked values if
+// chunking is enabled.
+func (txn *KVTxn) chunkedIter(it unionstore.Iterator) (unionstore.Iterator, error) {
+	if !txn.writeSizeLimits.chunkingEnabled() {
+		return it, nil
+	}
+	return chunked.NewIterator(context.Background(), NewBufferBatchGetter(txn.GetMemBuffer(), txn.GetSnapshot()), it)
+}
+
+// reassembleChunkedValues replaces the manifests in values with the values reassembled from their chunks.
+func (txn *KVTxn) reassembleChunkedValues(ctx context.Context, values map[string][]byte) error {
+	return chunked.Reassemble(ctx, NewBufferBatchGetter(txn.GetMemBuffer(), txn.GetSnapshot()), values)
+}
@@ -34,3 +34,6 @@ type SchemaVer = transaction.SchemaVer
 // MaxTxnTimeUse is the max time a Txn may use (in ms) from its begin to commit.
 // We use it to abort the transaction to guarantee GC worker will not influence it.
 const MaxTxnTimeUse = transaction.MaxTxnTimeUse
+
+// WriteSizeLimits limits the sizes of the keys and values set in a transaction.
+type WriteSizeLimits = transaction.WriteSizeLimits
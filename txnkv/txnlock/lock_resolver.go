@@ -16,7 +16,6 @@ package txnlock
 
 import (
 	"bytes"
-	"container/list"
 	"context"
 	"fmt"
 	"math"
@@ -40,7 +39,7 @@ import (
 	"go.uber.org/zap"
 )
 
-// ResolvedCacheSize is max number of cached txn status.
+// ResolvedCacheSize is the base size of the cache of resolved txn statuses.
 const ResolvedCacheSize = 2048
 
 const (
@@ -70,10 +69,9 @@ type LockResolver struct {
 		// use concurrency counting here to speed up checking
 		// whether we can free the resource used in `resolving`
 		resolvingConcurrency map[uint64]int
-		// resolved caches resolved txns (FIFO, txn id -> txnStatus).
-		resolved       map[uint64]TxnStatus
-		recentResolved *list.List
 	}
+	// cacheScope distinguishes the transactions of the cluster in the shared resolved cache.
+	cacheScope   string
 	testingKnobs struct {
 		meetLock func(locks []*Lock)
 	}
@@ -98,10 +96,11 @@ func NewLockResolver(store storage) *LockResolver {
 		store:                    store,
 		resolveLockLiteThreshold: config.GetGlobalConfig().TiKVClient.ResolveLockLiteThreshold,
 	}
-	r.mu.resolved = make(map[uint64]TxnStatus)
+	if s, ok := store.(interface{ UUID() string }); ok {
+		r.cacheScope = s.UUID()
+	}
 	r.mu.resolving = make(map[uint64][][]Lock)
 	r.mu.resolvingConcurrency = make(map[uint64]int)
-	r.mu.recentResolved = list.New()
 	r.asyncResolveCtx, r.asyncResolveCancel = context.WithCancel(context.Background())
 	return r
 }
@@ -203,28 +202,15 @@ func NewLock(l *kvrpcpb.LockInfo) *Lock {
 	}
 }
 
-func (lr *LockResolver) saveResolved(txnID uint64, status TxnStatus) {
-	lr.mu.Lock()
-	defer lr.mu.Unlock()
-
-	if _, ok := lr.mu.resolved[txnID]; ok {
-		return
-	}
-	lr.mu.resolved[txnID] = status
-	lr.mu.recentResolved.PushBack(txnID)
-	if len(lr.mu.resolved) > ResolvedCacheSize {
-		front := lr.mu.recentResolved.Front()
-		delete(lr.mu.resolved, front.Value.(uint64))
-		lr.mu.recentResolved.Remove(front)
-	}
+func (lr *LockResolver) saveResolved(txnID uint64, primary []byte, status TxnStatus) {
+	// Only the final state is shared, the action and the lock are the results of the request which resolved it, they
+	// mean nothing to the other callers.
+	final := TxnStatus{commitTS: status.commitTS}
+	sharedResolvedCache.put(resolvedTxnKey{scope: lr.cacheScope, txnID: txnID, primary: string(primary)}, final)
 }
 
-func (lr *LockResolver) getResolved(txnID uint64) (TxnStatus, bool) {
-	lr.mu.RLock()
-	defer lr.mu.RUnlock()
-
-	s, ok := lr.mu.resolved[txnID]
-	return s, ok
+func (lr *LockResolver) getResolved(txnID uint64, primary []byte) (TxnStatus, bool) {
+	return sharedResolvedCache.get(resolvedTxnKey{scope: lr.cacheScope, txnID: txnID, primary: string(primary)})
 }
 
 // BatchResolveLocks resolve locks in a batch.
@@ -320,8 +306,9 @@ func (lr *LockResolver) BatchResolveLocks(bo *retry.Backoffer, locks []*Lock, lo
 		},
 	)
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
+	req.TrafficClass = tikvrpc.TrafficClassLockResolve
 	startTime = time.Now()
-	resp, err := lr.store.SendReq(bo, req, loc, client.ReadTimeoutShort)
+	resp, err := lr.store.SendReq(bo, req, loc, client.DefaultTimeout(req.Type))
 	if err != nil {
 		return false, err
 	}
@@ -477,9 +464,16 @@ func (lr *LockResolver) resolveLocks(bo *retry.Backoffer, opts ResolveLocksOptio
 	// TODO: Maybe put it in LockResolver and share by all txns.
 	cleanTxns := make(map[uint64]map[locate.RegionVerID]struct{})
 	pessimisticCleanTxns := make(map[uint64]map[locate.RegionVerID]struct{})
+	checkedStatuses := lr.checkTxnStatuses(bo, locks, callerStartTS, detail)
 	var resolve func(*Lock, bool) (TxnStatus, error)
 	resolve = func(l *Lock, forceSyncCommit bool) (TxnStatus, error) {
-		status, err := lr.getTxnStatusFromLock(bo, l, callerStartTS, forceSyncCommit, detail)
+		var status TxnStatus
+		var err error
+		if checked, ok := checkedStatuses[newTxnStatusKey(l)]; ok && !forceSyncCommit {
+			status, err = checked.status, checked.err
+		} else {
+			status, err = lr.getTxnStatusFromLock(bo, l, callerStartTS, forceSyncCommit, detail)
+		}
 
 		if _, ok := errors.Cause(err).(primaryMismatch); ok {
 			if l.LockType != kvrpcpb.Op_PessimisticLock {
@@ -592,6 +586,76 @@ func (lr *LockResolver) resolveLocks(bo *retry.Backoffer, opts ResolveLocksOptio
 	}, nil
 }
 
+// checkTxnStatusConcurrency is the max number of CheckTxnStatus requests sent concurrently by checkTxnStatuses.
+const checkTxnStatusConcurrency = 16
+
+// txnStatusKey identifies the locks whose statuses are checked by the same CheckTxnStatus request.
+type txnStatusKey struct {
+	txnID    uint64
+	primary  string
+	lockType kvrpcpb.Op
+	// zeroTTL locks are resolved unconditionally, see getTxnStatusFromLock.
+	zeroTTL bool
+}
+
+func newTxnStatusKey(l *Lock) txnStatusKey {
+	return txnStatusKey{txnID: l.TxnID, primary: string(l.Primary), lockType: l.LockType, zeroTTL: l.TTL == 0}
+}
+
+type txnStatusResult struct {
+	status TxnStatus
+	err    error
+}
+
+// checkTxnStatuses checks the statuses of the transactions of the locks concurrently if there are more than one of
+// them, so that a read meeting the locks of many transactions doesn't wait for CheckTxnStatus one by one. Locks of the
+// same transaction share one request. It returns nil if there is only one transaction to check.
+func (lr *LockResolver) checkTxnStatuses(bo *retry.Backoffer, locks []*Lock, callerStartTS uint64, detail *util.ResolveLockDetail) map[txnStatusKey]txnStatusResult {
+	var pending []*Lock
+	seen := make(map[txnStatusKey]struct{}, len(locks))
+	for _, l := range locks {
+		key := newTxnStatusKey(l)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, ok := lr.getResolved(l.TxnID, l.Primary); !ok {
+			pending = append(pending, l)
+		}
+	}
+	if len(pending) <= 1 {
+		return nil
+	}
+
+	results := make(map[txnStatusKey]txnStatusResult, len(pending))
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		lastForkedBo atomic.Pointer[retry.Backoffer]
+		sem          = make(chan struct{}, checkTxnStatusConcurrency)
+	)
+	for _, l := range pending {
+		checkBo, cancel := bo.Fork()
+		defer cancel()
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			status, err := lr.getTxnStatusFromLock(checkBo, l, callerStartTS, false, detail)
+			lastForkedBo.Store(checkBo)
+			mu.Lock()
+			results[newTxnStatusKey(l)] = txnStatusResult{status: status, err: err}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	bo.UpdateUsingForked(lastForkedBo.Load())
+	return results
+}
+
 // Resolving returns the locks' information we are resolving currently.
 func (lr *LockResolver) Resolving() []ResolvingLock {
 	result := []ResolvingLock{}
@@ -745,7 +809,7 @@ func (e primaryMismatch) Error() string {
 // When rollbackIfNotExist is false, the caller should be careful with the txnNotFoundErr error.
 func (lr *LockResolver) getTxnStatus(bo *retry.Backoffer, txnID uint64, primary []byte,
 	callerStartTS, currentTS uint64, rollbackIfNotExist bool, forceSyncCommit bool, lockInfo *Lock) (TxnStatus, error) {
-	if s, ok := lr.getResolved(txnID); ok {
+	if s, ok := lr.getResolved(txnID, primary); ok {
 		return s, nil
 	}
 
@@ -777,13 +841,14 @@ func (lr *LockResolver) getTxnStatus(bo *retry.Backoffer, txnID uint64, primary
 			ResourceGroupName: util.ResourceGroupNameFromCtx(bo.GetCtx()),
 		},
 	})
+	req.TrafficClass = tikvrpc.TrafficClassLockResolve
 	for {
 		loc, err := lr.store.GetRegionCache().LocateKey(bo, primary)
 		if err != nil {
 			return status, err
 		}
 		req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-		resp, err := lr.store.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		resp, err := lr.store.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return status, err
 		}
@@ -836,7 +901,7 @@ func (lr *LockResolver) getTxnStatus(bo *retry.Backoffer, txnID uint64, primary
 
 			status.commitTS = cmdResp.CommitVersion
 			if status.StatusCacheable() {
-				lr.saveResolved(txnID, status)
+				lr.saveResolved(txnID, primary, status)
 			}
 		}
 
@@ -931,7 +996,8 @@ func (lr *LockResolver) checkSecondaries(bo *retry.Backoffer, txnID uint64, curK
 	})
 	metrics.LockResolverCountWithQueryCheckSecondaryLocks.Inc()
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-	resp, err := lr.store.SendReq(bo, req, curRegionID, client.ReadTimeoutShort)
+	req.TrafficClass = tikvrpc.TrafficClassLockResolve
+	resp, err := lr.store.SendReq(bo, req, curRegionID, client.DefaultTimeout(req.Type))
 	if err != nil {
 		return err
 	}
@@ -1020,7 +1086,7 @@ func (lr *LockResolver) resolveAsyncCommitLock(bo *retry.Backoffer, l *Lock, sta
 
 	status.commitTS = resolveData.commitTs
 	if status.StatusCacheable() {
-		lr.saveResolved(l.TxnID, status)
+		lr.saveResolved(l.TxnID, l.Primary, status)
 	}
 
 	logutil.BgLogger().Info("resolve async commit", zap.Uint64("startTS", l.TxnID), zap.Uint64("commitTS", status.commitTS))
@@ -1094,7 +1160,8 @@ func (lr *LockResolver) resolveRegionLocks(bo *retry.Backoffer, l *Lock, region
 		},
 	})
 	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-	resp, err := lr.store.SendReq(bo, req, region, client.ReadTimeoutShort)
+	req.TrafficClass = tikvrpc.TrafficClassLockResolve
+	resp, err := lr.store.SendReq(bo, req, region, client.DefaultTimeout(req.Type))
 	if err != nil {
 		return err
 	}
@@ -1177,8 +1244,9 @@ func (lr *LockResolver) resolveLock(bo *retry.Backoffer, l *Lock, status TxnStat
 			},
 		})
 		req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
+		req.TrafficClass = tikvrpc.TrafficClassLockResolve
 		req.RequestSource = util.RequestSourceFromCtx(bo.GetCtx())
-		resp, err := lr.store.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		resp, err := lr.store.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return err
 		}
@@ -1251,7 +1319,8 @@ func (lr *LockResolver) resolvePessimisticLock(bo *retry.Backoffer, l *Lock, pes
 			},
 		})
 		req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
-		resp, err := lr.store.SendReq(bo, req, loc.Region, client.ReadTimeoutShort)
+		req.TrafficClass = tikvrpc.TrafficClassLockResolve
+		resp, err := lr.store.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,100 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnlock
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/metrics"
+)
+
+// DefaultSharedResolvedCacheSize is the default max number of txn statuses cached by the process.
+const DefaultSharedResolvedCacheSize = 16 * ResolvedCacheSize
+
+// resolvedTxnKey identifies a transaction in the process. Transactions of different clusters may have the same ID.
+// The primary key is included because a pessimistic transaction may change its primary key after the lock of the
+// old one is rolled back, so the statuses checked with different primary keys may differ.
+type resolvedTxnKey struct {
+	scope   string
+	txnID   uint64
+	primary string
+}
+
+// resolvedTxnCache is a FIFO cache of the final statuses (committed or rolled back) of transactions.
+type resolvedTxnCache struct {
+	mu       sync.RWMutex
+	capacity int
+	statuses map[resolvedTxnKey]TxnStatus
+	recent   *list.List
+}
+
+func newResolvedTxnCache(capacity int) *resolvedTxnCache {
+	return &resolvedTxnCache{
+		capacity: capacity,
+		statuses: make(map[resolvedTxnKey]TxnStatus),
+		recent:   list.New(),
+	}
+}
+
+// sharedResolvedCache is shared by all LockResolvers, so the snapshots and transactions of the process don't check
+// the status of a resolved transaction again.
+var sharedResolvedCache = newResolvedTxnCache(DefaultSharedResolvedCacheSize)
+
+// SetSharedResolvedCacheSize sets the max number of txn statuses cached by the process. Statuses beyond the size are
+// evicted in FIFO order. The size can't be negative, and 0 disables the cache.
+func SetSharedResolvedCacheSize(size int) error {
+	if size < 0 {
+		return errors.Errorf("invalid shared resolved cache size %d", size)
+	}
+	sharedResolvedCache.mu.Lock()
+	defer sharedResolvedCache.mu.Unlock()
+	sharedResolvedCache.capacity = size
+	sharedResolvedCache.evict()
+	return nil
+}
+
+func (c *resolvedTxnCache) put(key resolvedTxnKey, status TxnStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.statuses[key]; ok {
+		return
+	}
+	c.statuses[key] = status
+	c.recent.PushBack(key)
+	c.evict()
+}
+
+func (c *resolvedTxnCache) evict() {
+	for len(c.statuses) > c.capacity {
+		front := c.recent.Front()
+		delete(c.statuses, front.Value.(resolvedTxnKey))
+		c.recent.Remove(front)
+	}
+}
+
+func (c *resolvedTxnCache) get(key resolvedTxnKey) (TxnStatus, bool) {
+	c.mu.RLock()
+	status, ok := c.statuses[key]
+	c.mu.RUnlock()
+	if ok {
+		metrics.LockResolverCountWithResolvedCacheHit.Inc()
+	} else {
+		metrics.LockResolverCountWithResolvedCacheMiss.Inc()
+	}
+	return status, ok
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+	"math/bits"
+	"sync"
+
+	"github.com/tikv/client-go/v2/config"
+)
+
+// ExistsBitmap is a compact bitmap of the existence of the keys passed to BatchExists, indexed by their positions.
+type ExistsBitmap []uint64
+
+func newExistsBitmap(n int) ExistsBitmap {
+	return make(ExistsBitmap, (n+63)/64)
+}
+
+func (b ExistsBitmap) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+// Exists returns whether the i-th key exists.
+func (b ExistsBitmap) Exists(i int) bool {
+	if i < 0 || i/64 >= len(b) {
+		return false
+	}
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// Count returns the number of the existing keys.
+func (b ExistsBitmap) Count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// BatchExists checks whether the keys exist in the snapshot, and returns a bitmap whose i-th bit is set if keys[i]
+// exists. It's cheaper than BatchGet if the values aren't needed, e.g. to check duplicates or constraints, as the
+// values are dropped as soon as they're received instead of being collected and cached in the snapshot. The keys
+// may contain duplicates.
+func (s *KVSnapshot) BatchExists(ctx context.Context, keys [][]byte) (ExistsBitmap, error) {
+	bitmap := newExistsBitmap(len(keys))
+	positions := make(map[string][]int, len(keys))
+	uniqueKeys := make([][]byte, 0, len(keys))
+	for i, k := range keys {
+		if _, ok := positions[string(k)]; !ok {
+			uniqueKeys = append(uniqueKeys, k)
+		}
+		positions[string(k)] = append(positions[string(k)], i)
+	}
+	markExists := func(k []byte) {
+		for _, i := range positions[string(k)] {
+			bitmap.set(i)
+		}
+	}
+
+	cached := make(map[string][]byte)
+	uniqueKeys = s.batchGetFromCache(uniqueKeys, cached)
+	for k, v := range cached {
+		if len(v) > 0 {
+			markExists([]byte(k))
+		}
+	}
+	if len(uniqueKeys) == 0 {
+		return bitmap, nil
+	}
+
+	bo := s.newBatchGetBackoffer(ctx)
+	var mu sync.Mutex
+	err := s.batchGetKeysByRegions(bo, uniqueKeys, BatchGetSnapshotTier, config.GetGlobalConfig().EnableAsyncBatchGet, func(k, v []byte) {
+		if len(v) == 0 {
+			return
+		}
+		mu.Lock()
+		markExists(k)
+		mu.Unlock()
+	})
+	s.recordBackoffInfo(bo)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.store.CheckVisibility(s.version); err != nil {
+		return nil, err
+	}
+	return bitmap, nil
+}
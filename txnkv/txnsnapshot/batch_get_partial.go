@@ -0,0 +1,120 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"go.uber.org/zap"
+)
+
+// BatchGetFailure is a part of the keys of BatchGetWithPartialResult that failed to be read.
+type BatchGetFailure struct {
+	// RegionID, StartKey and EndKey describe the region the keys belonged to when they were grouped. StartKey and
+	// EndKey are nil if the region is no longer in the region cache.
+	RegionID uint64
+	StartKey []byte
+	EndKey   []byte
+	Keys     [][]byte
+	Err      error
+}
+
+// BatchGetWithPartialResult is like BatchGet, but it doesn't fail the whole call when the keys in some regions fail
+// to be read, e.g. when all replicas of a region are down. It returns the values of the keys read successfully and
+// the failures, each of which covers the keys of a batch sent to a region. The failed keys are neither in the
+// returned map nor cached in the snapshot. It only returns an error if it fails before reading from any region, e.g.
+// failing to locate the keys.
+func (s *KVSnapshot) BatchGetWithPartialResult(ctx context.Context, keys [][]byte) (map[string][]byte, []BatchGetFailure, error) {
+	m := make(map[string][]byte)
+	keys = s.batchGetFromCache(keys, m)
+	if len(keys) == 0 {
+		if err := s.valueTransformers.RestoreMap(m); err != nil {
+			return nil, nil, err
+		}
+		return m, nil, nil
+	}
+
+	bo := s.newBatchGetBackoffer(ctx)
+	regionCache := s.store.GetRegionCache()
+	groups, _, err := regionCache.GroupKeysByRegion(bo, keys, nil)
+	s.recordBackoffInfo(bo)
+	if err != nil {
+		return nil, nil, err
+	}
+	var batches []batchKeys
+	for id, g := range groups {
+		batches = appendBatchKeysBySize(batches, id, g, func([]byte) int { return 1 }, batchGetSize)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures []BatchGetFailure
+		// succeeded are the keys read successfully, to update the snapshot cache.
+		succeeded = make([][]byte, 0, len(keys))
+	)
+	for _, batch := range batches {
+		wg.Add(1)
+		// Each batch has its own backoffer so that a failing region doesn't use up the backoff budget of the others.
+		go func(batch batchKeys, bo *retry.Backoffer) {
+			defer wg.Done()
+			defer s.recordBackoffInfo(bo)
+			growStackForBatchGetWorker()
+			values := make(map[string][]byte, len(batch.keys))
+			var valuesMu sync.Mutex
+			err := s.batchGetSingleRegion(bo, batch, BatchGetSnapshotTier, func(k, v []byte) {
+				if len(v) == 0 {
+					return
+				}
+				valuesMu.Lock()
+				values[string(k)] = v
+				valuesMu.Unlock()
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failure := BatchGetFailure{RegionID: batch.region.GetID(), Keys: batch.keys, Err: errors.WithStack(err)}
+				if region := regionCache.GetCachedRegionWithRLock(batch.region); region != nil {
+					failure.StartKey, failure.EndKey = region.StartKey(), region.EndKey()
+				}
+				failures = append(failures, failure)
+				logutil.BgLogger().Debug("snapshot BatchGetWithPartialResult failed in region",
+					zap.Uint64("regionID", batch.region.GetID()),
+					zap.Int("keys", len(batch.keys)),
+					zap.Uint64("txnStartTS", s.version),
+					zap.Error(err))
+				return
+			}
+			for k, v := range values {
+				m[k] = v
+			}
+			succeeded = append(succeeded, batch.keys...)
+		}(batch, s.newBatchGetBackoffer(ctx))
+	}
+	wg.Wait()
+
+	if err = s.store.CheckVisibility(s.version); err != nil {
+		return nil, nil, err
+	}
+	s.UpdateSnapshotCache(succeeded, m)
+	if err = s.valueTransformers.RestoreMap(m); err != nil {
+		return nil, nil, err
+	}
+	return m, failures, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util"
+)
+
+const (
+	parallelScanMaxBackoff = 20000
+	// parallelScanChunkSize is the number of the pairs passed from a worker to the caller at a time.
+	parallelScanChunkSize = 256
+	// parallelScanBufferedChunks is the number of the chunks buffered for each partition.
+	parallelScanBufferedChunks = 4
+)
+
+// ScanOrder is the order in which ParallelScan passes the pairs to the caller.
+type ScanOrder int
+
+const (
+	// ScanOrderKey passes the pairs in ascending key order, as if the range were scanned sequentially. The regions are
+	// still scanned in parallel, but a region scanned ahead is buffered until the regions before it are passed.
+	ScanOrderKey ScanOrder = iota
+	// ScanOrderCompletion passes the pairs as soon as they're scanned. The pairs of a region are in ascending key
+	// order, but the pairs of the different regions are interleaved. It's faster if the order doesn't matter.
+	ScanOrderCompletion
+)
+
+// ParallelScanOptions is the options of ParallelScan.
+type ParallelScanOptions struct {
+	// Concurrency is the number of the regions scanned at the same time, at least 1.
+	Concurrency int
+	// Order is the order of the pairs passed to the caller.
+	Order ScanOrder
+}
+
+type scanPair struct {
+	key, value []byte
+}
+
+type scanChunk struct {
+	pairs []scanPair
+	err   error
+}
+
+// ParallelScan scans the regions of [start, end) in parallel and calls fn on each key-value pair in the order chosen
+// by opts. An empty end means unbounded. fn is never called concurrently. It stops at the first error returned by
+// fn or met by a scan, or when ctx is done.
+func (s *KVSnapshot) ParallelScan(ctx context.Context, start, end []byte, opts ParallelScanOptions,
+	fn func(key, value []byte) error) error {
+	return s.parallelScan(ctx, []kv.KeyRange{{StartKey: start, EndKey: end}}, opts, fn)
+}
+
+// ScanRanges scans the disjoint ranges at the snapshot ts in parallel, as if they were scanned in one ParallelScan, and
+// calls fn on each key-value pair in the order chosen by opts. In key order, the pairs of all the ranges are passed in
+// ascending key order regardless of the order of ranges. Each range is split by the regions and the regions of all
+// the ranges share opts.Concurrency. The locks met in any range are resolved before the pairs behind them are passed,
+// so every pair is committed before the snapshot ts. An empty end key means unbounded, which is only allowed for the
+// last range. It returns an error without scanning if the ranges overlap.
+func (s *KVSnapshot) ScanRanges(ctx context.Context, ranges []kv.KeyRange, opts ParallelScanOptions,
+	fn func(key, value []byte) error) error {
+	sorted, err := sortDisjointRanges(ranges)
+	if err != nil {
+		return err
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	return s.parallelScan(ctx, sorted, opts, fn)
+}
+
+// sortDisjointRanges returns the non-empty ranges sorted by the start keys, or an error if they overlap.
+func sortDisjointRanges(ranges []kv.KeyRange) ([]kv.KeyRange, error) {
+	sorted := make([]kv.KeyRange, 0, len(ranges))
+	for _, r := range ranges {
+		if len(r.EndKey) > 0 && bytes.Compare(r.StartKey, r.EndKey) >= 0 {
+			continue
+		}
+		sorted = append(sorted, r)
+	}
+	slices.SortFunc(sorted, func(a, b kv.KeyRange) int {
+		return bytes.Compare(a.StartKey, b.StartKey)
+	})
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if len(prev.EndKey) == 0 || bytes.Compare(prev.EndKey, cur.StartKey) > 0 {
+			return nil, errors.Errorf("scan ranges [%q, %q) and [%q, %q) overlap",
+				prev.StartKey, prev.EndKey, cur.StartKey, cur.EndKey)
+		}
+	}
+	return sorted, nil
+}
+
+// parallelScan scans the sorted disjoint ranges split by the regions in parallel.
+func (s *KVSnapshot) parallelScan(ctx context.Context, ranges []kv.KeyRange, opts ParallelScanOptions,
+	fn func(key, value []byte) error) error {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	bo := retry.NewBackofferWithVars(ctx, parallelScanMaxBackoff, s.vars)
+	var partitions []kv.KeyRange
+	for _, rg := range ranges {
+		locs, err := s.store.GetRegionCache().LocateKeyRange(bo, rg.StartKey, rg.EndKey)
+		if err != nil {
+			return err
+		}
+		err = util.SplitRangeByRegions(rg.StartKey, rg.EndKey, locate.NextKeyLocation(locs),
+			func(_ *locate.KeyLocation, start, end []byte) error {
+				partitions = append(partitions, kv.KeyRange{StartKey: start, EndKey: end})
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	// In key order, each partition has its own channel which is drained in order. Otherwise, all the partitions share
+	// the same channel.
+	chs := make([]chan scanChunk, len(partitions))
+	if opts.Order == ScanOrderKey {
+		for i := range chs {
+			chs[i] = make(chan scanChunk, parallelScanBufferedChunks)
+		}
+	} else {
+		shared := make(chan scanChunk, parallelScanBufferedChunks*opts.Concurrency)
+		for i := range chs {
+			chs[i] = shared
+		}
+	}
+
+	taskCh := make(chan int)
+	for i := 0; i < opts.Concurrency && i < len(partitions); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range taskCh {
+				s.scanPartition(ctx, partitions[idx], chs[idx], opts.Order == ScanOrderKey)
+			}
+		}()
+	}
+	// The partitions are dispatched in order, so the partition drained by the caller in key order is always being
+	// scanned or done.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(taskCh)
+		for i := range partitions {
+			select {
+			case taskCh <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	consume := func(chunk scanChunk) error {
+		for _, pair := range chunk.pairs {
+			if err := fn(pair.key, pair.value); err != nil {
+				return err
+			}
+		}
+		return chunk.err
+	}
+	if opts.Order == ScanOrderKey {
+		for _, ch := range chs {
+			for {
+				var chunk scanChunk
+				var ok bool
+				select {
+				case chunk, ok = <-ch:
+				case <-ctx.Done():
+					return errors.WithStack(ctx.Err())
+				}
+				if !ok {
+					break
+				}
+				if err := consume(chunk); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// In completion order, a nil chunk marks a partition is done.
+	for done := 0; done < len(partitions); {
+		select {
+		case chunk := <-chs[0]:
+			if chunk.pairs == nil && chunk.err == nil {
+				done++
+				continue
+			}
+			if err := consume(chunk); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+	return nil
+}
+
+// scanPartition scans the range and sends the pairs in chunks to ch. If closeCh is true, ch is closed after the range
+// is scanned, otherwise an empty chunk is sent.
+func (s *KVSnapshot) scanPartition(ctx context.Context, r kv.KeyRange, ch chan<- scanChunk, closeCh bool) {
+	send := func(chunk scanChunk) bool {
+		select {
+		case ch <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	defer func() {
+		if closeCh {
+			close(ch)
+		} else {
+			send(scanChunk{})
+		}
+	}()
+	scanner, err := newScanner(s, r.StartKey, r.EndKey, s.scanBatchSize, false)
+	if err != nil {
+		send(scanChunk{err: err})
+		return
+	}
+	defer scanner.Close()
+	pairs := make([]scanPair, 0, parallelScanChunkSize)
+	for scanner.Valid() {
+		pairs = append(pairs, scanPair{key: scanner.Key(), value: scanner.Value()})
+		if len(pairs) == parallelScanChunkSize {
+			if !send(scanChunk{pairs: pairs}) {
+				return
+			}
+			pairs = make([]scanPair, 0, parallelScanChunkSize)
+		}
+		if err = scanner.Next(); err != nil {
+			send(scanChunk{pairs: pairs, err: err})
+			return
+		}
+	}
+	if len(pairs) > 0 {
+		send(scanChunk{pairs: pairs})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"bytes"
+	"math/rand"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+)
+
+// ReplicaMismatch is the mismatch between the values of a key read from two replicas at the same ts.
+type ReplicaMismatch struct {
+	Key      []byte
+	TS       uint64
+	RegionID uint64
+	// StoreID and Value are of the replica serving the read, a nil Value means the key doesn't exist.
+	StoreID uint64
+	Value   []byte
+	// OtherStoreID and OtherValue are of the replica verifying the read.
+	OtherStoreID uint64
+	OtherValue   []byte
+}
+
+type replicaConsistencyCheck struct {
+	rate       float64
+	onMismatch func(ReplicaMismatch)
+}
+
+// SetReplicaConsistencyCheck makes the fraction rate of the point gets of the snapshot also read the key from another
+// replica of the region and compare the values, calling onMismatch if they differ. Commit ts isn't compared as it's
+// not returned by TiKV. The verification is synchronous, so it adds the latency of a read to the sampled gets, and it's
+// skipped if the other replica fails to serve the read. Setting rate to 0 disables the check.
+func (s *KVSnapshot) SetReplicaConsistencyCheck(rate float64, onMismatch func(ReplicaMismatch)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rate <= 0 || onMismatch == nil {
+		s.mu.replicaCheck = nil
+		return
+	}
+	s.mu.replicaCheck = &replicaConsistencyCheck{rate: rate, onMismatch: onMismatch}
+}
+
+func (c *replicaConsistencyCheck) sample() bool {
+	return c != nil && rand.Float64() < c.rate
+}
+
+// checkReplicaConsistency reads k from a replica other than the one in servedBy, which has returned val.
+func (s *KVSnapshot) checkReplicaConsistency(bo *retry.Backoffer, cli *ClientHelper, check *replicaConsistencyCheck, regionID locate.RegionVerID, servedBy *locate.RPCContext, k, val []byte) {
+	region := s.store.GetRegionCache().GetCachedRegionWithRLock(regionID)
+	if region == nil || servedBy.Peer == nil {
+		return
+	}
+	storeID := servedBy.Peer.GetStoreId()
+	others := make([]uint64, 0, len(region.GetMeta().GetPeers()))
+	for _, peer := range region.GetMeta().GetPeers() {
+		if peer.GetStoreId() != storeID {
+			others = append(others, peer.GetStoreId())
+		}
+	}
+	if len(others) == 0 {
+		return
+	}
+
+	req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdGet,
+		&kvrpcpb.GetRequest{
+			Key:     k,
+			Version: s.version,
+		}, kv.ReplicaReadMixed, nil, kvrpcpb.Context{
+			Priority:       s.priority.ToPB(),
+			NotFillCache:   true,
+			IsolationLevel: s.isolationLevel.ToPB(),
+		})
+	req.InputRequestSource = s.GetRequestSource()
+	// The backoffs of the verification shouldn't be counted in the read.
+	resp, rpcCtx, _, err := cli.SendReqCtx(bo.Clone(), req, regionID, client.DefaultTimeout(req.Type), tikvrpc.TiKV, "", locate.WithMatchStores(others))
+	if err != nil {
+		logutil.Logger(bo.GetCtx()).Debug("skip checking replica consistency", zap.Uint64("region", regionID.GetID()), zap.Error(err))
+		return
+	}
+	if rpcCtx == nil || rpcCtx.Peer == nil || rpcCtx.Peer.GetStoreId() == storeID {
+		return
+	}
+	if regionErr, _ := resp.GetRegionError(); regionErr != nil || resp.Resp == nil {
+		return
+	}
+	getResp := resp.Resp.(*kvrpcpb.GetResponse)
+	if getResp.GetError() != nil {
+		return
+	}
+	otherVal := getResp.GetValue()
+	if bytes.Equal(val, otherVal) {
+		return
+	}
+	mismatch := ReplicaMismatch{
+		Key:          k,
+		TS:           s.version,
+		RegionID:     regionID.GetID(),
+		StoreID:      storeID,
+		OtherStoreID: rpcCtx.Peer.GetStoreId(),
+	}
+	if len(val) > 0 {
+		mismatch.Value = val
+	}
+	if len(otherVal) > 0 {
+		mismatch.OtherValue = otherVal
+	}
+	check.onMismatch(mismatch)
+}
@@ -37,6 +37,7 @@ package txnsnapshot
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
@@ -45,6 +46,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/locate"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/internal/memctl"
 	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
@@ -68,13 +70,90 @@ type Scanner struct {
 
 	valid bool
 	eof   bool
+
+	// memTracker accounts the cache against the memory budget of the client.
+	memTracker *memctl.Tracker
+	// lockTracker bounds the lock resolution of the scanner if it's not nil.
+	lockTracker *lockResolveTracker
+	// arena holds the cache if the scanner reuses its buffers, see KVSnapshot.SetScanBufferReuse.
+	arena *scanArena
+	// filter filters the pairs returned by the scanner, see KVSnapshot.SetScanFilter.
+	filter *ScanFilter
+	// staleRead sends the scan requests as stale reads, see WithStaleReadScan.
+	staleRead bool
+}
+
+// ScanOption is an option of the scanner created by KVSnapshot.IterWithOptions.
+type ScanOption func(*Scanner)
+
+// WithStaleReadScan makes the scanner of a staleness read only snapshot send the scan requests as stale reads in the
+// read replica scope of the snapshot, which are served by any replica ready at the read ts. A request falls back to
+// the leader after meeting a lock.
+func WithStaleReadScan() ScanOption {
+	return func(s *Scanner) {
+		s.staleRead = true
+	}
+}
+
+// LockResolveBudget bounds the work of resolving the locks met by KVSnapshot.ForEach. The zero values mean unlimited.
+type LockResolveBudget struct {
+	// MaxLocks is the max number of locks resolved.
+	MaxLocks int
+	// MaxDuration is the max time spent on resolving the locks, including the backoff waiting for them to expire.
+	MaxDuration time.Duration
+}
+
+type lockResolveTracker struct {
+	budget   LockResolveBudget
+	locks    int
+	duration time.Duration
+}
+
+// acquire is called before resolving a lock. It fails with ErrLockResolveBudgetExceeded if the budget is used up.
+func (t *lockResolveTracker) acquire(resumeKey []byte) error {
+	if t == nil {
+		return nil
+	}
+	if (t.budget.MaxLocks > 0 && t.locks >= t.budget.MaxLocks) ||
+		(t.budget.MaxDuration > 0 && t.duration >= t.budget.MaxDuration) {
+		return &tikverr.ErrLockResolveBudgetExceeded{
+			ResumeKey:     append([]byte(nil), resumeKey...),
+			ResolvedLocks: t.locks,
+			ResolveTime:   t.duration,
+		}
+	}
+	t.locks++
+	return nil
+}
+
+// release is called after resolving a lock started at start.
+func (t *lockResolveTracker) release(start time.Time) {
+	if t != nil {
+		t.duration += time.Since(start)
+	}
 }
 
 func newScanner(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize int, reverse bool) (*Scanner, error) {
+	return newScannerWithOptions(snapshot, startKey, endKey, batchSize, reverse, nil, false)
+}
+
+func newScannerWithOptions(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize int, reverse bool,
+	lockTracker *lockResolveTracker, reuseBuffers bool, opts ...ScanOption) (*Scanner, error) {
 	// It must be > 1. Otherwise scanner won't skipFirst.
 	if batchSize <= 1 {
 		batchSize = DefaultScanBatchSize
 	}
+	if err := memctl.CheckScan(batchSize, DefaultScanBatchSize); err != nil {
+		return nil, err
+	}
+	filter := snapshot.scanFilter
+	overlapped := true
+	if filter != nil {
+		if err := filter.validate(snapshot.keyOnly, snapshot.version); err != nil {
+			return nil, err
+		}
+		startKey, endKey, overlapped = filter.narrow(startKey, endKey)
+	}
 	scanner := &Scanner{
 		snapshot:     snapshot,
 		batchSize:    batchSize,
@@ -83,6 +162,19 @@ func newScanner(snapshot *KVSnapshot, startKey []byte, endKey []byte, batchSize
 		endKey:       endKey,
 		reverse:      reverse,
 		nextEndKey:   endKey,
+		memTracker:   memctl.NewTracker(memctl.KindScanBuffer),
+		lockTracker:  lockTracker,
+		filter:       filter,
+	}
+	for _, opt := range opts {
+		opt(scanner)
+	}
+	if !overlapped {
+		scanner.Close()
+		return scanner, nil
+	}
+	if reuseBuffers {
+		scanner.arena = getScanArena()
 	}
 	err := scanner.Next()
 	if tikverr.IsErrNotFound(err) {
@@ -155,8 +247,15 @@ func (s *Scanner) Next() error {
 		}
 		// Try to resolve the lock
 		if current.GetError() != nil {
+			if err := s.lockTracker.acquire(current.Key); err != nil {
+				s.Close()
+				return err
+			}
 			// 'current' would be modified if the lock being resolved
-			if err := s.resolveCurrentLock(bo, current); err != nil {
+			start := time.Now()
+			err := s.resolveCurrentLock(bo, current)
+			s.lockTracker.release(start)
+			if err != nil {
 				s.Close()
 				return err
 			}
@@ -168,6 +267,13 @@ func (s *Scanner) Next() error {
 				continue
 			}
 		}
+		if current.Value, err = s.snapshot.valueTransformers.Restore(current.Key, current.Value); err != nil {
+			s.Close()
+			return err
+		}
+		if !s.filter.match(current.Key, current.Value) {
+			continue
+		}
 		return nil
 	}
 }
@@ -175,6 +281,12 @@ func (s *Scanner) Next() error {
 // Close close iterator.
 func (s *Scanner) Close() {
 	s.valid = false
+	s.memTracker.Release()
+	if s.arena != nil {
+		s.cache = nil
+		putScanArena(s.arena)
+		s.arena = nil
+	}
 }
 
 func (s *Scanner) startTS() uint64 {
@@ -205,6 +317,8 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 	var err error
 	// the states in request need to keep when retry request.
 	var readType string
+	// a stale read falls back to the leader after meeting a lock.
+	var staleReadMeetLock bool
 	for {
 		if !s.reverse {
 			loc, err = s.snapshot.store.GetRegionCache().LocateKey(bo, s.nextStartKey)
@@ -232,7 +346,7 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			StartKey:   s.nextStartKey,
 			EndKey:     reqEndKey,
 			Limit:      uint32(s.batchSize),
-			Version:    s.startTS(),
+			Version:    s.filter.version(s.startTS()),
 			KeyOnly:    s.snapshot.keyOnly,
 			SampleStep: s.snapshot.sampleStep,
 		}
@@ -258,11 +372,19 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			req.IsRetryRequest = true
 		}
 		req.InputRequestSource = s.snapshot.GetRequestSource()
+		if s.staleRead && s.snapshot.mu.isStaleness {
+			req.TxnScope = s.snapshot.mu.readReplicaScope
+			req.ReadReplicaScope = s.snapshot.mu.readReplicaScope
+			if !staleReadMeetLock {
+				req.EnableStaleWithMixedReplicaRead()
+			}
+		}
 		if s.snapshot.mu.resourceGroupTag == nil && s.snapshot.mu.resourceGroupTagger != nil {
 			s.snapshot.mu.resourceGroupTagger(req)
 		}
+		scanChecksums := s.snapshot.mu.scanChecksums
 		s.snapshot.mu.RUnlock()
-		resp, _, err := sender.SendReq(bo, req, loc.Region, client.ReadTimeoutMedium)
+		resp, _, err := sender.SendReq(bo, req, loc.Region, client.DefaultTimeout(req.Type))
 		if err != nil {
 			return err
 		}
@@ -284,7 +406,7 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		}
 		cmdScanResp := resp.Resp.(*kvrpcpb.ScanResponse)
 
-		err = s.snapshot.store.CheckVisibility(s.startTS())
+		err = s.snapshot.store.CheckVisibility(s.filter.version(s.startTS()))
 		if err != nil {
 			return err
 		}
@@ -296,6 +418,16 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			if err != nil {
 				return err
 			}
+			// we need to read from leader after resolving the lock.
+			staleReadMeetLock = true
+			resumeKey := s.nextStartKey
+			if s.reverse {
+				resumeKey = s.nextEndKey
+			}
+			if err = s.lockTracker.acquire(resumeKey); err != nil {
+				return err
+			}
+			start := time.Now()
 			locks := []*txnlock.Lock{lock}
 			if resolvingRecordToken == nil {
 				token := s.snapshot.store.GetLockResolver().RecordResolvingLocks(locks, s.snapshot.version)
@@ -305,15 +437,13 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 				s.snapshot.store.GetLockResolver().UpdateResolvingLocks(locks, s.snapshot.version, *resolvingRecordToken)
 			}
 			msBeforeExpired, err := s.snapshot.store.GetLockResolver().ResolveLocks(bo, s.snapshot.version, locks)
+			if err == nil && msBeforeExpired > 0 {
+				err = bo.BackoffWithMaxSleepTxnLockFast(int(msBeforeExpired), errors.Errorf("key is locked during scanning"))
+			}
+			s.lockTracker.release(start)
 			if err != nil {
 				return err
 			}
-			if msBeforeExpired > 0 {
-				err = bo.BackoffWithMaxSleepTxnLockFast(int(msBeforeExpired), errors.Errorf("key is locked during scanning"))
-				if err != nil {
-					return err
-				}
-			}
 			continue
 		}
 
@@ -329,7 +459,21 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			}
 		}
 
+		if scanChecksums != nil {
+			scanChecksums.check(sreq, kvPairs)
+		}
+
+		if s.arena != nil {
+			kvPairs = s.arena.fill(kvPairs)
+		}
 		s.cache, s.idx = kvPairs, 0
+		var cacheSize int64
+		if memctl.Enabled() {
+			for _, pair := range kvPairs {
+				cacheSize += int64(len(pair.Key) + len(pair.Value))
+			}
+		}
+		s.memTracker.Set(cacheSize)
 		if len(kvPairs) < s.batchSize {
 			// No more data in current Region. Next getData() starts
 			// from current Region's endKey.
@@ -352,6 +496,9 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		lastKey := kvPairs[len(kvPairs)-1].GetKey()
 		if !s.reverse {
 			s.nextStartKey = kv.NextKey(lastKey)
+		} else if s.arena != nil {
+			// The arena is overwritten by the next batch.
+			s.nextEndKey = append([]byte(nil), lastKey...)
 		} else {
 			s.nextEndKey = lastKey
 		}
@@ -0,0 +1,96 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+)
+
+// maxPooledScanArenaSize is the max size of the buffer of an arena put back to the pool, so that a scan of huge
+// values doesn't pin the memory after it's done.
+const maxPooledScanArenaSize = 64 << 20
+
+var scanArenaPool = sync.Pool{New: func() any { return &scanArena{} }}
+
+// scanArena holds the pairs of a scan batch in the buffers reused by the following batches, so a long scan keeps
+// one buffer instead of the memory of every response.
+type scanArena struct {
+	buf   []byte
+	pairs []kvrpcpb.KvPair
+	ptrs  []*kvrpcpb.KvPair
+}
+
+func getScanArena() *scanArena {
+	return scanArenaPool.Get().(*scanArena)
+}
+
+func putScanArena(a *scanArena) {
+	if cap(a.buf) > maxPooledScanArenaSize {
+		return
+	}
+	clear(a.pairs)
+	clear(a.ptrs)
+	scanArenaPool.Put(a)
+}
+
+// fill copies the pairs into the arena, overwriting the previous batch, and returns the copies.
+func (a *scanArena) fill(kvPairs []*kvrpcpb.KvPair) []*kvrpcpb.KvPair {
+	size := 0
+	for _, pair := range kvPairs {
+		size += len(pair.Key) + len(pair.Value)
+	}
+	if cap(a.buf) < size {
+		a.buf = make([]byte, 0, size)
+	}
+	if cap(a.pairs) < len(kvPairs) {
+		a.pairs = make([]kvrpcpb.KvPair, len(kvPairs))
+		a.ptrs = make([]*kvrpcpb.KvPair, len(kvPairs))
+	}
+	a.buf, a.pairs, a.ptrs = a.buf[:0], a.pairs[:len(kvPairs)], a.ptrs[:len(kvPairs)]
+	for i, pair := range kvPairs {
+		a.pairs[i] = kvrpcpb.KvPair{Error: pair.Error, Key: a.append(pair.Key), Value: a.append(pair.Value)}
+		a.ptrs[i] = &a.pairs[i]
+	}
+	return a.ptrs
+}
+
+func (a *scanArena) append(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	start := len(a.buf)
+	a.buf = append(a.buf, b...)
+	return a.buf[start:len(a.buf):len(a.buf)]
+}
+
+// SetScanBufferReuse makes the iterators created by Iter and IterReverse and the scan of ForEach reuse their buffers
+// between batches, which reduces the allocations of large scans. The key and value of such an iterator are only valid
+// until the next call to Next, so the ones retained must be copied out, e.g. by Scanner.KeyCopy and
+// Scanner.ValueCopy. ParallelScan doesn't reuse the buffers.
+func (s *KVSnapshot) SetScanBufferReuse(b bool) {
+	s.scanBufferReuse = b
+}
+
+// KeyCopy appends the key to dst and returns it, to retain the key of a scanner reusing its buffers.
+func (s *Scanner) KeyCopy(dst []byte) []byte {
+	return append(dst, s.Key()...)
+}
+
+// ValueCopy appends the value to dst and returns it, to retain the value of a scanner reusing its buffers.
+func (s *Scanner) ValueCopy(dst []byte) []byte {
+	return append(dst, s.Value()...)
+}
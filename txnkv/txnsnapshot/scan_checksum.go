@@ -0,0 +1,148 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+)
+
+var scanChecksumTable = crc64.MakeTable(crc64.ECMA)
+
+// maxScanChecksumBatches is the max number of the batches whose checksums are kept by a snapshot. The checksums of
+// the oldest batches are dropped if there are more, so a long scan doesn't keep growing the memory of the snapshot.
+const maxScanChecksumBatches = 4096
+
+// ScanChecksumMismatch is the mismatch between the results of two scans of the same batch at the same ts.
+type ScanChecksumMismatch struct {
+	StartKey []byte
+	EndKey   []byte
+	Limit    uint32
+	Reverse  bool
+	TS       uint64
+	// Checksum and Pairs are of the latest scan, PrevChecksum and PrevPairs are of the first one.
+	Checksum     uint64
+	Pairs        int
+	PrevChecksum uint64
+	PrevPairs    int
+}
+
+// scanBatchKey identifies a scan batch, whose result is determined at the ts of the snapshot.
+type scanBatchKey struct {
+	startKey string
+	endKey   string
+	limit    uint32
+	reverse  bool
+	keyOnly  bool
+}
+
+type scanBatchChecksum struct {
+	checksum uint64
+	pairs    int
+}
+
+// scanChecksums records the checksums of the latest maxScanChecksumBatches scan batches of a snapshot.
+type scanChecksums struct {
+	mu      sync.Mutex
+	batches map[scanBatchKey]scanBatchChecksum
+	// order is the ring of the recorded batches by the recording order, and next is the index of the oldest one
+	// once the ring is full.
+	order      []scanBatchKey
+	next       int
+	onMismatch func(ScanChecksumMismatch)
+}
+
+// SetScanChecksum makes the scanners of the snapshot compute a client-side checksum of every batch, and compare it
+// with the checksum of the same batch scanned earlier by the snapshot, e.g. when a long scan is retried from a
+// checkpoint, calling onMismatch if they differ. TiKV doesn't return checksums of scan batches, and the batches with
+// unresolved locks aren't checked. The checksums of the latest batches are kept until the snapshot is released, so
+// the batches rescanned after many others may not be checked. nil disables the check.
+func (s *KVSnapshot) SetScanChecksum(onMismatch func(ScanChecksumMismatch)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if onMismatch == nil {
+		s.mu.scanChecksums = nil
+		return
+	}
+	s.mu.scanChecksums = &scanChecksums{
+		batches:    make(map[scanBatchKey]scanBatchChecksum),
+		onMismatch: onMismatch,
+	}
+}
+
+func checksumPairs(pairs []*kvrpcpb.KvPair) (uint64, bool) {
+	digest := crc64.New(scanChecksumTable)
+	var buf [binary.MaxVarintLen64]byte
+	for _, pair := range pairs {
+		if pair.GetError() != nil {
+			return 0, false
+		}
+		digest.Write(buf[:binary.PutUvarint(buf[:], uint64(len(pair.Key)))])
+		digest.Write(pair.Key)
+		digest.Write(buf[:binary.PutUvarint(buf[:], uint64(len(pair.Value)))])
+		digest.Write(pair.Value)
+	}
+	return digest.Sum64(), true
+}
+
+// check records the checksum of the batch scanned by req, or compares it with the recorded one.
+func (c *scanChecksums) check(req *kvrpcpb.ScanRequest, pairs []*kvrpcpb.KvPair) {
+	checksum, ok := checksumPairs(pairs)
+	if !ok {
+		return
+	}
+	key := scanBatchKey{
+		startKey: string(req.StartKey),
+		endKey:   string(req.EndKey),
+		limit:    req.Limit,
+		reverse:  req.Reverse,
+		keyOnly:  req.KeyOnly,
+	}
+	c.mu.Lock()
+	prev, ok := c.batches[key]
+	if !ok {
+		c.record(key, scanBatchChecksum{checksum: checksum, pairs: len(pairs)})
+	}
+	c.mu.Unlock()
+	if !ok || prev.checksum == checksum {
+		return
+	}
+	c.onMismatch(ScanChecksumMismatch{
+		StartKey:     req.StartKey,
+		EndKey:       req.EndKey,
+		Limit:        req.Limit,
+		Reverse:      req.Reverse,
+		TS:           req.Version,
+		Checksum:     checksum,
+		Pairs:        len(pairs),
+		PrevChecksum: prev.checksum,
+		PrevPairs:    prev.pairs,
+	})
+}
+
+// record records the checksum of the batch, dropping the oldest one if there are maxScanChecksumBatches batches.
+func (c *scanChecksums) record(key scanBatchKey, checksum scanBatchChecksum) {
+	if len(c.order) < maxScanChecksumBatches {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.batches, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % maxScanChecksumBatches
+	}
+	c.batches[key] = checksum
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnsnapshot
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/kv"
+)
+
+// ScanFilter filters the key-value pairs returned by the scanners of a snapshot, i.e. Iter, IterReverse and ForEach.
+//
+// KeyPrefix and Version are carried by the scan requests, so the pairs out of them are never sent by TiKV. The scan
+// requests of TiKV can't carry the other conditions, and the coprocessor filtering needs the executors built by the
+// SQL layer, so KeyRegex, MinValueLen and MaxValueLen fall back to be evaluated by the scanners after the pairs are
+// received, which doesn't reduce the network traffic.
+//
+// The filter only applies to the pairs read from TiKV. The iterators of the transactions merge them with the
+// MemBuffer of the transactions, whose entries aren't filtered.
+type ScanFilter struct {
+	// KeyPrefix limits the scans to the keys with the prefix if it's not empty.
+	KeyPrefix []byte
+	// Version reads the pairs as of the version instead of the version of the snapshot if it's not 0. It can't be
+	// greater than the version of the snapshot.
+	Version uint64
+	// KeyRegex matches the keys if it's not nil.
+	KeyRegex *regexp.Regexp
+	// MinValueLen and MaxValueLen bound the length of the values, 0 means unbounded. They can't be set for the
+	// key-only snapshots.
+	MinValueLen int
+	MaxValueLen int
+}
+
+// SetScanFilter sets the filter of the scanners of the snapshot created later, nil removes the filter.
+func (s *KVSnapshot) SetScanFilter(f *ScanFilter) {
+	s.scanFilter = f
+}
+
+func (f *ScanFilter) validate(keyOnly bool, snapshotVersion uint64) error {
+	if f.Version > snapshotVersion {
+		return errors.Errorf("the version %d of scan filter is greater than the snapshot version %d", f.Version, snapshotVersion)
+	}
+	if f.MinValueLen < 0 || f.MaxValueLen < 0 || (f.MaxValueLen > 0 && f.MinValueLen > f.MaxValueLen) {
+		return errors.Errorf("invalid value length bounds [%d, %d] of scan filter", f.MinValueLen, f.MaxValueLen)
+	}
+	if keyOnly && (f.MinValueLen > 0 || f.MaxValueLen > 0) {
+		return errors.New("the value length of key-only scans can't be filtered")
+	}
+	return nil
+}
+
+// narrow narrows the range [start, end) to the keys with KeyPrefix, and returns false if they don't overlap. An
+// empty end means unbounded.
+func (f *ScanFilter) narrow(start, end []byte) ([]byte, []byte, bool) {
+	if len(f.KeyPrefix) == 0 {
+		return start, end, true
+	}
+	if bytes.Compare(start, f.KeyPrefix) < 0 {
+		start = f.KeyPrefix
+	}
+	if prefixEnd := kv.PrefixNextKey(f.KeyPrefix); len(prefixEnd) > 0 && (len(end) == 0 || bytes.Compare(prefixEnd, end) < 0) {
+		end = prefixEnd
+	}
+	return start, end, len(end) == 0 || bytes.Compare(start, end) < 0
+}
+
+// version returns the version the scans read as of.
+func (f *ScanFilter) version(snapshotVersion uint64) uint64 {
+	if f == nil || f.Version == 0 {
+		return snapshotVersion
+	}
+	return f.Version
+}
+
+// match returns whether the pair passes the filter.
+func (f *ScanFilter) match(key, value []byte) bool {
+	if f == nil {
+		return true
+	}
+	if len(value) < f.MinValueLen || (f.MaxValueLen > 0 && len(value) > f.MaxValueLen) {
+		return false
+	}
+	if len(f.KeyPrefix) > 0 && !bytes.HasPrefix(key, f.KeyPrefix) {
+		return false
+	}
+	return f.KeyRegex == nil || f.KeyRegex.Match(key)
+}
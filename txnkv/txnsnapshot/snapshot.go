@@ -124,7 +124,15 @@ type KVSnapshot struct {
 	resolvedLocks   util.TSSet
 	committedLocks  util.TSSet
 	scanBatchSize   int
-	readTimeout     time.Duration
+	// lockResolveBudget bounds the lock resolution of ForEach.
+	lockResolveBudget LockResolveBudget
+	readTimeout       time.Duration
+	// scanBufferReuse makes the scanners reuse their buffers between batches.
+	scanBufferReuse bool
+	// valueTransformers restores the values read by the snapshot.
+	valueTransformers *kv.ValueTransformers
+	// scanFilter filters the pairs returned by the scanners, see SetScanFilter.
+	scanFilter *ScanFilter
 
 	// Cache the result of Get and BatchGet.
 	// The invariance is that calling Get or BatchGet multiple times using the same start ts,
@@ -148,6 +156,12 @@ type KVSnapshot struct {
 		replicaReadAdjuster ReplicaReadAdjuster
 		// MatchStoreLabels indicates the labels the store should be matched
 		matchStoreLabels []*metapb.StoreLabel
+		// learnerReadFallback is where ReplicaReadLearner requests go when no learner is available.
+		learnerReadFallback kv.LearnerReadFallback
+		// replicaCheck is set by SetReplicaConsistencyCheck.
+		replicaCheck *replicaConsistencyCheck
+		// scanChecksums is set by SetScanChecksum.
+		scanChecksums *scanChecksums
 		// resourceGroupTag is use to set the kv request resource group tag.
 		resourceGroupTag []byte
 		// resourceGroupTagger is use to set the kv request resource group tag if resourceGroupTag is nil.
@@ -180,7 +194,10 @@ func NewTiKVSnapshot(store kvstore, ts uint64, replicaReadSeed uint32) *KVSnapsh
 	}
 }
 
-const batchGetMaxBackoff = 20000
+const (
+	batchGetMaxBackoff  = 20000
+	refreshTSMaxBackoff = 20000
+)
 
 // SetSnapshotTS resets the timestamp for reads.
 func (s *KVSnapshot) SetSnapshotTS(ts uint64) {
@@ -198,6 +215,39 @@ func (s *KVSnapshot) SetSnapshotTS(ts uint64) {
 	s.resolvedLocks = util.TSSet{}
 }
 
+// RefreshTS fetches a new timestamp from PD and moves the snapshot to it in place,
+// so statement-level read committed implementations can reuse one snapshot across
+// statements. The timestamp is allocated in the snapshot's read replica scope, or the
+// global scope if it is not set. The snapshot's settings (replica read, resource group,
+// etc.) are kept, while the snapshot is invalidated as SetSnapshotTS does: the values
+// cached for the old ts and the committed transactions learned from lock resolution are
+// dropped. Stale read snapshots can't be refreshed. It returns the new ts.
+func (s *KVSnapshot) RefreshTS(ctx context.Context) (uint64, error) {
+	s.mu.RLock()
+	isStaleness := s.mu.isStaleness
+	scope := s.mu.readReplicaScope
+	s.mu.RUnlock()
+	if isStaleness {
+		return 0, errors.New("cannot refresh the ts of a stale read snapshot")
+	}
+	if scope == "" {
+		scope = oracle.GlobalTxnScope
+	}
+	bo := retry.NewBackofferWithVars(ctx, refreshTSMaxBackoff, nil)
+	for {
+		ts, err := s.store.GetOracle().GetTimestamp(bo.GetCtx(), &oracle.Option{TxnScope: scope})
+		if err == nil {
+			if ts != s.version {
+				s.SetSnapshotTS(ts)
+			}
+			return ts, nil
+		}
+		if err = bo.Backoff(retry.BoPDRPC, err); err != nil {
+			return 0, err
+		}
+	}
+}
+
 // IsInternal returns if the KvSnapshot is used by internal executions.
 func (s *KVSnapshot) IsInternal() bool {
 	return util.IsRequestSourceInternal(s.RequestSource)
@@ -224,40 +274,17 @@ const (
 func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTier int) (map[string][]byte, error) {
 	// Check the cached value first.
 	m := make(map[string][]byte)
-	s.mu.RLock()
-	if s.mu.cached != nil && readTier == BatchGetSnapshotTier {
-		tmp := make([][]byte, 0, len(keys))
-		for _, key := range keys {
-			if val, ok := s.mu.cached[string(key)]; ok {
-				atomic.AddInt64(&s.mu.hitCnt, 1)
-				if len(val) > 0 {
-					m[string(key)] = val
-				}
-			} else {
-				tmp = append(tmp, key)
-			}
-		}
-		keys = tmp
+	if readTier == BatchGetSnapshotTier {
+		keys = s.batchGetFromCache(keys, m)
 	}
-	s.mu.RUnlock()
-
 	if len(keys) == 0 {
+		if err := s.valueTransformers.RestoreMap(m); err != nil {
+			return nil, err
+		}
 		return m, nil
 	}
 
-	ctx = context.WithValue(ctx, retry.TxnStartKey, s.version)
-	if ctx.Value(util.RequestSourceKey) == nil {
-		ctx = context.WithValue(ctx, util.RequestSourceKey, *s.RequestSource)
-	}
-	bo := retry.NewBackofferWithVars(ctx, batchGetMaxBackoff, s.vars)
-	s.mu.RLock()
-	if s.mu.interceptor != nil {
-		// User has called snapshot.SetRPCInterceptor() to explicitly set an interceptor, we
-		// need to bind it to ctx so that the internal client can perceive and execute
-		// it before initiating an RPC request.
-		bo.SetCtx(interceptor.WithRPCInterceptor(bo.GetCtx(), s.mu.interceptor))
-	}
-	s.mu.RUnlock()
+	bo := s.newBatchGetBackoffer(ctx)
 	// Create a map to collect key-values from region servers.
 	var mu sync.Mutex
 	err := s.batchGetKeysByRegions(bo, keys, readTier, config.GetGlobalConfig().EnableAsyncBatchGet, func(k, v []byte) {
@@ -287,9 +314,50 @@ func (s *KVSnapshot) BatchGetWithTier(ctx context.Context, keys [][]byte, readTi
 	// Update the cache.
 	s.UpdateSnapshotCache(keys, m)
 
+	if err = s.valueTransformers.RestoreMap(m); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
+// batchGetFromCache collects the cached values of the keys into m, and returns the keys not cached.
+func (s *KVSnapshot) batchGetFromCache(keys [][]byte, m map[string][]byte) [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.mu.cached == nil {
+		return keys
+	}
+	tmp := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if val, ok := s.mu.cached[string(key)]; ok {
+			atomic.AddInt64(&s.mu.hitCnt, 1)
+			if len(val) > 0 {
+				m[string(key)] = val
+			}
+		} else {
+			tmp = append(tmp, key)
+		}
+	}
+	return tmp
+}
+
+func (s *KVSnapshot) newBatchGetBackoffer(ctx context.Context) *retry.Backoffer {
+	ctx = context.WithValue(ctx, retry.TxnStartKey, s.version)
+	if ctx.Value(util.RequestSourceKey) == nil {
+		ctx = context.WithValue(ctx, util.RequestSourceKey, *s.RequestSource)
+	}
+	bo := retry.NewBackofferWithVars(ctx, batchGetMaxBackoff, s.vars)
+	s.mu.RLock()
+	if s.mu.interceptor != nil {
+		// User has called snapshot.SetRPCInterceptor() to explicitly set an interceptor, we
+		// need to bind it to ctx so that the internal client can perceive and execute
+		// it before initiating an RPC request.
+		bo.SetCtx(interceptor.WithRPCInterceptor(bo.GetCtx(), s.mu.interceptor))
+	}
+	s.mu.RUnlock()
+	return bo
+}
+
 type batchKeys struct {
 	region locate.RegionVerID
 	keys   [][]byte
@@ -563,6 +631,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 		}
 		scope := s.mu.readReplicaScope
 		matchStoreLabels := s.mu.matchStoreLabels
+		learnerReadFallback := s.mu.learnerReadFallback
 		replicaAdjuster := s.mu.replicaReadAdjuster
 		s.mu.RUnlock()
 		req.TxnScope = scope
@@ -570,7 +639,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 		if isStaleness {
 			req.EnableStaleWithMixedReplicaRead()
 		}
-		timeout := client.ReadTimeoutMedium
+		timeout := client.DefaultTimeout(req.Type)
 		if useConfigurableKVTimeout && s.readTimeout > 0 {
 			useConfigurableKVTimeout = false
 			timeout = s.readTimeout
@@ -580,6 +649,9 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 		if len(matchStoreLabels) > 0 {
 			ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 		}
+		if learnerReadFallback != kv.LearnerReadFallbackAny {
+			ops = append(ops, locate.WithLearnerReadFallback(learnerReadFallback))
+		}
 		if req.ReplicaReadType.IsFollowerRead() && replicaAdjuster != nil {
 			op, readType := replicaAdjuster(len(pending))
 			if op != nil {
@@ -659,7 +731,7 @@ func (s *KVSnapshot) Get(ctx context.Context, k []byte) ([]byte, error) {
 			if len(value) == 0 {
 				return nil, tikverr.ErrNotExist
 			}
-			return value, nil
+			return s.valueTransformers.Restore(k, value)
 		}
 	}
 	if _, err := util.EvalFailpoint("snapshot-get-cache-fail"); err == nil {
@@ -694,7 +766,7 @@ func (s *KVSnapshot) Get(ctx context.Context, k []byte) ([]byte, error) {
 	if len(val) == 0 {
 		return nil, tikverr.ErrNotExist
 	}
-	return val, nil
+	return s.valueTransformers.Restore(k, val)
 }
 
 func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]byte, error) {
@@ -733,6 +805,8 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 	}
 	isStaleness := s.mu.isStaleness
 	matchStoreLabels := s.mu.matchStoreLabels
+	learnerReadFallback := s.mu.learnerReadFallback
+	replicaCheck := s.mu.replicaCheck
 	scope := s.mu.readReplicaScope
 	replicaAdjuster := s.mu.replicaReadAdjuster
 	s.mu.RUnlock()
@@ -745,6 +819,9 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 	if len(matchStoreLabels) > 0 {
 		ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 	}
+	if learnerReadFallback != kv.LearnerReadFallbackAny {
+		ops = append(ops, locate.WithLearnerReadFallback(learnerReadFallback))
+	}
 	if req.ReplicaReadType.IsFollowerRead() && replicaAdjuster != nil {
 		op, readType := replicaAdjuster(1)
 		if op != nil {
@@ -762,13 +839,13 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 		if err != nil {
 			return nil, err
 		}
-		timeout := client.ReadTimeoutShort
+		timeout := client.DefaultTimeout(req.Type)
 		if useConfigurableKVTimeout && s.readTimeout > 0 {
 			useConfigurableKVTimeout = false
 			timeout = s.readTimeout
 		}
 		req.MaxExecutionDurationMs = uint64(timeout.Milliseconds())
-		resp, _, _, err := cli.SendReqCtx(bo, req, loc.Region, timeout, tikvrpc.TiKV, "", ops...)
+		resp, rpcCtx, _, err := cli.SendReqCtx(bo, req, loc.Region, timeout, tikvrpc.TiKV, "", ops...)
 		if err != nil {
 			return nil, err
 		}
@@ -845,6 +922,9 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 			}
 			continue
 		}
+		if replicaCheck.sample() && rpcCtx != nil {
+			s.checkReplicaConsistency(bo, cli, replicaCheck, loc.Region, rpcCtx, k, val)
+		}
 		return val, nil
 	}
 }
@@ -861,16 +941,56 @@ func (s *KVSnapshot) mergeExecDetail(detail *kvrpcpb.ExecDetailsV2) {
 
 // Iter return a list of key-value pair after `k`.
 func (s *KVSnapshot) Iter(k []byte, upperBound []byte) (unionstore.Iterator, error) {
-	scanner, err := newScanner(s, k, upperBound, s.scanBatchSize, false)
+	scanner, err := newScannerWithOptions(s, k, upperBound, s.scanBatchSize, false, nil, s.scanBufferReuse)
+	return scanner, err
+}
+
+// IterWithOptions is like Iter, with the options of the scanner.
+func (s *KVSnapshot) IterWithOptions(k []byte, upperBound []byte, opts ...ScanOption) (unionstore.Iterator, error) {
+	scanner, err := newScannerWithOptions(s, k, upperBound, s.scanBatchSize, false, nil, s.scanBufferReuse, opts...)
 	return scanner, err
 }
 
 // IterReverse creates a reversed Iterator positioned on the first entry which key is less than k.
 func (s *KVSnapshot) IterReverse(k, lowerBound []byte) (unionstore.Iterator, error) {
-	scanner, err := newScanner(s, lowerBound, k, s.scanBatchSize, true)
+	scanner, err := newScannerWithOptions(s, lowerBound, k, s.scanBatchSize, true, nil, s.scanBufferReuse)
 	return scanner, err
 }
 
+// ForEach calls fn on each key-value pair in [start, end) in ascending order, stopping at the first error returned by
+// fn. An empty end means unbounded. Unlike Iter, the work of resolving the locks met in the range is bounded by the
+// budget set by SetLockResolveBudget. Once it's used up, ForEach returns ErrLockResolveBudgetExceeded with the key to
+// resume from, and all the pairs before the key have been passed to fn. The key and value must not be retained by fn
+// after it returns.
+func (s *KVSnapshot) ForEach(start, end []byte, fn func(key, value []byte) error) error {
+	tracker := &lockResolveTracker{budget: s.lockResolveBudget}
+	scanner, err := newScannerWithOptions(s, start, end, s.scanBatchSize, false, tracker, s.scanBufferReuse)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+	for scanner.Valid() {
+		if err = fn(scanner.Key(), scanner.Value()); err != nil {
+			return err
+		}
+		if err = scanner.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetValueTransformers sets the transformers restoring the values read by the snapshot, see kv.ValueTransformers. It
+// must be set before the snapshot is used.
+func (s *KVSnapshot) SetValueTransformers(t *kv.ValueTransformers) {
+	s.valueTransformers = t
+}
+
+// SetLockResolveBudget sets the budget of resolving the locks met by ForEach.
+func (s *KVSnapshot) SetLockResolveBudget(budget LockResolveBudget) {
+	s.lockResolveBudget = budget
+}
+
 // SetNotFillCache indicates whether tikv should skip filling cache when
 // loading data.
 func (s *KVSnapshot) SetNotFillCache(b bool) {
@@ -972,6 +1092,14 @@ func (s *KVSnapshot) SetMatchStoreLabels(labels []*metapb.StoreLabel) {
 	s.mu.matchStoreLabels = labels
 }
 
+// SetLearnerReadFallback sets where the requests go when no learner replica is available if the replica read type is
+// kv.ReplicaReadLearner. By default, they may go to any replica.
+func (s *KVSnapshot) SetLearnerReadFallback(fallback kv.LearnerReadFallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.learnerReadFallback = fallback
+}
+
 // SetResourceGroupTag sets resource group tag of the kv request.
 func (s *KVSnapshot) SetResourceGroupTag(tag []byte) {
 	s.mu.Lock()
@@ -21,6 +21,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/locate"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
 	"github.com/tikv/client-go/v2/util/async"
@@ -115,6 +116,7 @@ func (s *KVSnapshot) tryBatchGetSingleRegionUsingAsyncAPI(
 	isStaleness := s.mu.isStaleness
 	readReplicaScope := s.mu.readReplicaScope
 	matchStoreLabels := s.mu.matchStoreLabels
+	learnerReadFallback := s.mu.learnerReadFallback
 	replicaReadAdjuster := s.mu.replicaReadAdjuster
 	s.mu.RUnlock()
 
@@ -124,7 +126,7 @@ func (s *KVSnapshot) tryBatchGetSingleRegionUsingAsyncAPI(
 		req.EnableStaleWithMixedReplicaRead()
 	}
 
-	timeout := client.ReadTimeoutMedium
+	timeout := client.DefaultTimeout(req.Type)
 	if s.readTimeout > 0 {
 		timeout = s.readTimeout
 	}
@@ -134,6 +136,9 @@ func (s *KVSnapshot) tryBatchGetSingleRegionUsingAsyncAPI(
 	if len(matchStoreLabels) > 0 {
 		ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 	}
+	if learnerReadFallback != kv.LearnerReadFallbackAny {
+		ops = append(ops, locate.WithLearnerReadFallback(learnerReadFallback))
+	}
 	if req.ReplicaReadType.IsFollowerRead() && replicaReadAdjuster != nil {
 		op, readType := replicaReadAdjuster(len(batch.keys))
 		if op != nil {
@@ -252,6 +257,7 @@ func (s *KVSnapshot) retryBatchGetSingleRegionAfterAsyncAPI(
 		}
 		readReplicaScope := s.mu.readReplicaScope
 		matchStoreLabels := s.mu.matchStoreLabels
+		learnerReadFallback := s.mu.learnerReadFallback
 		replicaReadAdjuster := s.mu.replicaReadAdjuster
 		s.mu.RUnlock()
 
@@ -260,12 +266,15 @@ func (s *KVSnapshot) retryBatchGetSingleRegionAfterAsyncAPI(
 		if isStaleness {
 			req.EnableStaleWithMixedReplicaRead()
 		}
-		timeout := client.ReadTimeoutMedium
+		timeout := client.DefaultTimeout(req.Type)
 		req.MaxExecutionDurationMs = uint64(timeout.Milliseconds())
 		ops := make([]locate.StoreSelectorOption, 0, 2)
 		if len(matchStoreLabels) > 0 {
 			ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 		}
+		if learnerReadFallback != kv.LearnerReadFallbackAny {
+			ops = append(ops, locate.WithLearnerReadFallback(learnerReadFallback))
+		}
 		if req.ReplicaReadType.IsFollowerRead() && replicaReadAdjuster != nil {
 			op, readType := replicaReadAdjuster(len(batch.keys))
 			if op != nil {
@@ -338,6 +338,88 @@ type ExecDetails struct {
 	WaitKVRespDuration int64
 	WaitPDRespDuration int64
 	TrafficDetails
+	// ReqServed collects where the requests are finally served if it's set.
+	ReqServed *ReqServedDetails
+}
+
+// ReqServedInfo is the region, peer and store that finally served a request.
+type ReqServedInfo struct {
+	ReqType   string
+	RegionID  uint64
+	PeerID    uint64
+	StoreID   uint64
+	StoreAddr string
+	// AttemptedReplicas is the number of the replicas the request has been sent to, including the serving one.
+	AttemptedReplicas int
+	// Duration is the time spent on the request, including the retries and the backoffs.
+	Duration time.Duration
+}
+
+// String implements the fmt.Stringer interface.
+func (r ReqServedInfo) String() string {
+	return fmt.Sprintf("{type: %s, region: %d, peer: %d, store: %d, addr: %s, attempted_replicas: %d, time: %s}",
+		r.ReqType, r.RegionID, r.PeerID, r.StoreID, r.StoreAddr, r.AttemptedReplicas, FormatDuration(r.Duration))
+}
+
+// ReqServedDetails collects where the requests are finally served, so the slow requests can be correlated with the
+// stores serving them.
+type ReqServedDetails struct {
+	mu      sync.Mutex
+	count   int
+	slowest ReqServedInfo
+	stores  map[uint64]int
+}
+
+// Record records a served request.
+func (d *ReqServedDetails) Record(info ReqServedInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.count++
+	if info.Duration >= d.slowest.Duration {
+		d.slowest = info
+	}
+	if d.stores == nil {
+		d.stores = make(map[uint64]int)
+	}
+	d.stores[info.StoreID]++
+}
+
+// Count returns the number of the recorded requests.
+func (d *ReqServedDetails) Count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// Slowest returns the slowest recorded request.
+func (d *ReqServedDetails) Slowest() ReqServedInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.slowest
+}
+
+// StoreReqCounts returns the number of the recorded requests served by each store.
+func (d *ReqServedDetails) StoreReqCounts() map[uint64]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	counts := make(map[uint64]int, len(d.stores))
+	for storeID, n := range d.stores {
+		counts[storeID] = n
+	}
+	return counts
+}
+
+// String implements the fmt.Stringer interface.
+func (d *ReqServedDetails) String() string {
+	if d == nil {
+		return ""
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("req_served: {num: %d, slowest: %s}", d.count, d.slowest)
 }
 
 // TrafficDetails contains traffic detail info.
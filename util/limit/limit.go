@@ -0,0 +1,195 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package limit provides the concurrency limiters used by the client, so the applications can bound the concurrency
+// of their own operations, e.g. the maintenance tasks, globally, per store and per region in the same way.
+package limit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/util"
+)
+
+// Limiter limits the number of the concurrent operations to a fixed capacity, like the util.RateLimit used by the
+// client, with the operations canceled by their contexts.
+type Limiter struct {
+	rl *util.RateLimit
+}
+
+// NewLimiter creates a Limiter allowing at most n concurrent operations. n must be positive.
+func NewLimiter(n int) (*Limiter, error) {
+	if n <= 0 {
+		return nil, errors.Errorf("invalid limit %d, it must be positive", n)
+	}
+	return newLimiter(n), nil
+}
+
+func newLimiter(n int) *Limiter {
+	return &Limiter{rl: util.NewRateLimit(n)}
+}
+
+// Acquire acquires a token, blocking until a token is released or ctx is done. It returns the error of ctx if it's
+// done before a token is acquired.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if exit := l.rl.GetToken(ctx.Done()); exit {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// TryAcquire acquires a token without blocking, and returns whether a token is acquired.
+func (l *Limiter) TryAcquire() bool {
+	return l.rl.TryGetToken()
+}
+
+// Release releases a token acquired. It panics if no token is acquired.
+func (l *Limiter) Release() {
+	l.rl.PutToken()
+}
+
+// Capacity returns the max number of the concurrent operations.
+func (l *Limiter) Capacity() int {
+	return l.rl.GetCapacity()
+}
+
+// InUse returns the number of the tokens acquired.
+func (l *Limiter) InUse() int {
+	return l.rl.GetInUse()
+}
+
+// StoreLimitConfig is the config of a StoreLimiter. A non-positive limit means no limit.
+type StoreLimitConfig struct {
+	// Global is the max number of the concurrent operations in total.
+	Global int
+	// PerStore is the max number of the concurrent operations on a store.
+	PerStore int
+	// PerRegion is the max number of the concurrent operations on a region.
+	PerRegion int
+}
+
+type keyedLimiter struct {
+	*Limiter
+	// refs is the number of the operations holding or waiting for a token, the limiter is removed when it's 0.
+	refs int
+}
+
+// StoreLimiter limits the concurrent operations globally, per store and per region.
+type StoreLimiter struct {
+	cfg    StoreLimitConfig
+	global *Limiter
+
+	mu      sync.Mutex
+	stores  map[uint64]*keyedLimiter
+	regions map[uint64]*keyedLimiter
+}
+
+// NewStoreLimiter creates a StoreLimiter with the config.
+func NewStoreLimiter(cfg StoreLimitConfig) *StoreLimiter {
+	l := &StoreLimiter{
+		cfg:     cfg,
+		stores:  make(map[uint64]*keyedLimiter),
+		regions: make(map[uint64]*keyedLimiter),
+	}
+	if cfg.Global > 0 {
+		l.global = newLimiter(cfg.Global)
+	}
+	return l
+}
+
+func (l *StoreLimiter) ref(limiters map[uint64]*keyedLimiter, id uint64, n int) *keyedLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := limiters[id]
+	if !ok {
+		limiter = &keyedLimiter{Limiter: newLimiter(n)}
+		limiters[id] = limiter
+	}
+	limiter.refs++
+	return limiter
+}
+
+func (l *StoreLimiter) unref(limiters map[uint64]*keyedLimiter, id uint64, limiter *keyedLimiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter.refs--
+	if limiter.refs == 0 {
+		delete(limiters, id)
+	}
+}
+
+// Acquire acquires the tokens of the global, the store and the region limits for an operation on the region of the
+// store, blocking until all of them are acquired or ctx is done. A zero storeID or regionID skips the corresponding
+// limit. The returned function releases the tokens and must be called once the operation finishes.
+func (l *StoreLimiter) Acquire(ctx context.Context, storeID, regionID uint64) (release func(), err error) {
+	var releases []func()
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	if l.global != nil {
+		if err = l.global.Acquire(ctx); err != nil {
+			return nil, err
+		}
+		releases = append(releases, l.global.Release)
+	}
+	acquireKeyed := func(limiters map[uint64]*keyedLimiter, id uint64, n int) error {
+		if id == 0 || n <= 0 {
+			return nil
+		}
+		limiter := l.ref(limiters, id, n)
+		if err := limiter.Acquire(ctx); err != nil {
+			l.unref(limiters, id, limiter)
+			return err
+		}
+		releases = append(releases, func() {
+			limiter.Release()
+			l.unref(limiters, id, limiter)
+		})
+		return nil
+	}
+	if err = acquireKeyed(l.stores, storeID, l.cfg.PerStore); err != nil {
+		releaseAll()
+		return nil, err
+	}
+	if err = acquireKeyed(l.regions, regionID, l.cfg.PerRegion); err != nil {
+		releaseAll()
+		return nil, err
+	}
+	return releaseAll, nil
+}
+
+// StoreInUse returns the number of the operations running on the store.
+func (l *StoreLimiter) StoreInUse(storeID uint64) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limiter, ok := l.stores[storeID]; ok {
+		return limiter.InUse()
+	}
+	return 0
+}
+
+// GlobalInUse returns the number of the operations running in total, or 0 if there is no global limit.
+func (l *StoreLimiter) GlobalInUse() int {
+	if l.global == nil {
+		return 0
+	}
+	return l.global.InUse()
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		_, err := NewLimiter(n)
+		require.Error(t, err, n)
+	}
+	l, err := NewLimiter(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, l.Capacity())
+	require.PanicsWithValue(t, "put a redundant token", l.Release)
+
+	require.NoError(t, l.Acquire(context.Background()))
+	require.True(t, l.TryAcquire())
+	require.False(t, l.TryAcquire())
+	require.Equal(t, 2, l.InUse())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+
+	l.Release()
+	require.Equal(t, 1, l.InUse())
+	require.True(t, l.TryAcquire())
+}
+
+func TestStoreLimiter(t *testing.T) {
+	l := NewStoreLimiter(StoreLimitConfig{Global: 3, PerStore: 2, PerRegion: 1})
+	ctx := context.Background()
+	timeoutCtx := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	release1, err := l.Acquire(ctx, 1, 10)
+	require.NoError(t, err)
+	// The region limit is reached.
+	_, err = l.Acquire(timeoutCtx(), 1, 10)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	release2, err := l.Acquire(ctx, 1, 11)
+	require.NoError(t, err)
+	require.Equal(t, 2, l.StoreInUse(1))
+	// The store limit is reached.
+	_, err = l.Acquire(timeoutCtx(), 1, 12)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	release3, err := l.Acquire(ctx, 2, 20)
+	require.NoError(t, err)
+	// The global limit is reached.
+	_, err = l.Acquire(timeoutCtx(), 3, 0)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 3, l.GlobalInUse())
+
+	// The tokens of the failed attempts are released.
+	release1()
+	release2()
+	release3()
+	require.Equal(t, 0, l.GlobalInUse())
+	require.Equal(t, 0, l.StoreInUse(1))
+	require.Empty(t, l.stores)
+	require.Empty(t, l.regions)
+
+	// No limit is applied for the zero IDs and limits.
+	l = NewStoreLimiter(StoreLimitConfig{PerRegion: 1})
+	for i := 0; i < 3; i++ {
+		_, err = l.Acquire(ctx, 1, 0)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 0, l.GlobalInUse())
+	require.Empty(t, l.stores)
+}
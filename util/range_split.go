@@ -0,0 +1,46 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+)
+
+// SplitRangeByRegions splits [startKey, endKey) by the regions covering it in key order. locate returns the region
+// containing the key and the end key of the region, and fn is called with each region and the part of the range in
+// it. Empty end keys mean unbounded. It stops at the first error returned by locate or fn.
+func SplitRangeByRegions[R any](startKey, endKey []byte, locate func(key []byte) (region R, regionEndKey []byte, err error),
+	fn func(region R, start, end []byte) error) error {
+	key := startKey
+	for len(endKey) == 0 || bytes.Compare(key, endKey) < 0 {
+		region, regionEndKey, err := locate(key)
+		if err != nil {
+			return err
+		}
+		isLast := len(regionEndKey) == 0 || (len(endKey) > 0 && bytes.Compare(regionEndKey, endKey) >= 0)
+		end := regionEndKey
+		if isLast {
+			end = endKey
+		}
+		if err = fn(region, key, end); err != nil {
+			return err
+		}
+		if isLast {
+			return nil
+		}
+		key = regionEndKey
+	}
+	return nil
+}
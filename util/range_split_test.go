@@ -0,0 +1,64 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRangeByRegions(t *testing.T) {
+	// The regions are ["", "b"), ["b", "d"), ["d", "").
+	boundaries := []string{"b", "d"}
+	locate := func(key []byte) (int, []byte, error) {
+		for i, b := range boundaries {
+			if bytes.Compare(key, []byte(b)) < 0 {
+				return i, []byte(b), nil
+			}
+		}
+		return len(boundaries), nil, nil
+	}
+	split := func(start, end string) []string {
+		var parts []string
+		err := SplitRangeByRegions([]byte(start), []byte(end), locate, func(region int, start, end []byte) error {
+			parts = append(parts, string(rune('0'+region))+":"+string(start)+"-"+string(end))
+			return nil
+		})
+		require.NoError(t, err)
+		return parts
+	}
+	require.Equal(t, []string{"0:-b", "1:b-d", "2:d-"}, split("", ""))
+	require.Equal(t, []string{"0:a-b", "1:b-c"}, split("a", "c"))
+	require.Equal(t, []string{"1:b-d"}, split("b", "d"))
+	require.Equal(t, []string{"1:c-d", "2:d-e"}, split("c", "e"))
+	require.Empty(t, split("c", "c"))
+
+	errLocate := errors.New("locate")
+	err := SplitRangeByRegions([]byte("a"), nil, func(key []byte) (int, []byte, error) {
+		return 0, nil, errLocate
+	}, func(int, []byte, []byte) error { return nil })
+	require.ErrorIs(t, err, errLocate)
+	errFn := errors.New("fn")
+	calls := 0
+	err = SplitRangeByRegions(nil, nil, locate, func(int, []byte, []byte) error {
+		calls++
+		return errFn
+	})
+	require.ErrorIs(t, err, errFn)
+	require.Equal(t, 1, calls)
+}
@@ -58,6 +58,16 @@ func (r *RateLimit) GetToken(done <-chan struct{}) (exit bool) {
 	}
 }
 
+// TryGetToken acquires a token without blocking, and returns whether a token is acquired.
+func (r *RateLimit) TryGetToken() bool {
+	select {
+	case r.token <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
 // PutToken puts a token back.
 func (r *RateLimit) PutToken() {
 	select {
@@ -71,3 +81,8 @@ func (r *RateLimit) PutToken() {
 func (r *RateLimit) GetCapacity() int {
 	return r.capacity
 }
+
+// GetInUse returns the number of the tokens acquired.
+func (r *RateLimit) GetInUse() int {
+	return len(r.token)
+}